@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -17,11 +18,13 @@ import (
 // network. A method such as `Transact` does require a Tx and thus will
 // be flagged `true`.
 // Input specifies the required input parameters for this gives method.
+// Output specifies the values returned by the method, which can be
+// decoded using ABI.Unpack.
 type Method struct {
 	Name   string
 	Const  bool
 	Input  []Argument
-	Return Type // not yet implemented
+	Output []Argument
 }
 
 // Returns the methods string signature according to the ABI spec.
@@ -49,16 +52,19 @@ func (m Method) Id() []byte {
 }
 
 // Argument holds the name of the argument and the corresponding type.
-// Types are used when packing and testing arguments.
+// Types are used when packing and testing arguments. Indexed is only
+// used by event arguments, which are stored in the log topics if set.
 type Argument struct {
-	Name string
-	Type Type
+	Name    string
+	Type    Type
+	Indexed bool
 }
 
 func (a *Argument) UnmarshalJSON(data []byte) error {
 	var extarg struct {
-		Name string
-		Type string
+		Name    string
+		Type    string
+		Indexed bool
 	}
 	err := json.Unmarshal(data, &extarg)
 	if err != nil {
@@ -70,34 +76,36 @@ func (a *Argument) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	a.Name = extarg.Name
+	a.Indexed = extarg.Indexed
 
 	return nil
 }
 
 // The ABI holds information about a contract's context and available
 // invokable methods. It will allow you to type check function calls and
-// packs data accordingly.
+// packs data accordingly. Return values and event logs can be decoded
+// using Unpack and UnpackLog. The Constructor holds the arguments
+// appended to the contract's code when it is deployed.
 type ABI struct {
-	Methods map[string]Method
+	Constructor Method
+	Methods     map[string]Method
+	Events      map[string]Event
 }
 
 // tests, tests whether the given input would result in a successful
 // call. Checks argument list count and matches input to `input`.
-func (abi ABI) pack(name string, args ...interface{}) ([]byte, error) {
-	method := abi.Methods[name]
+func (abi ABI) pack(method Method, args ...interface{}) ([]byte, error) {
+	name := method.Name
 
-	var ret []byte
+	types := make([]Type, len(args))
+	values := make([]reflect.Value, len(args))
 	for i, a := range args {
-		input := method.Input[i]
-
-		packed, err := input.Type.pack(a)
-		if err != nil {
-			return nil, fmt.Errorf("`%s` %v", name, err)
-		}
-		ret = append(ret, packed...)
-
+		types[i], values[i] = method.Input[i].Type, reflect.ValueOf(a)
+	}
+	ret, err := packTuple(types, values)
+	if err != nil {
+		return nil, fmt.Errorf("`%s` %v", name, err)
 	}
-
 	return ret, nil
 }
 
@@ -106,8 +114,14 @@ func (abi ABI) pack(name string, args ...interface{}) ([]byte, error) {
 // of 4 bytes and arguments are all 32 bytes.
 // Method ids are created from the first 4 bytes of the hash of the
 // methods string signature. (signature = baz(uint32,string32))
+//
+// An empty name packs the arguments of the constructor, which have no
+// method id and are appended to the contract code on deployment.
 func (abi ABI) Pack(name string, args ...interface{}) ([]byte, error) {
 	method, exist := abi.Methods[name]
+	if name == "" {
+		method, exist = abi.Constructor, true
+	}
 	if !exist {
 		return nil, fmt.Errorf("method '%s' not found", name)
 	}
@@ -117,27 +131,84 @@ func (abi ABI) Pack(name string, args ...interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("argument count mismatch: %d for %d", len(args), len(method.Input))
 	}
 
-	arguments, err := abi.pack(name, args...)
+	arguments, err := abi.pack(method, args...)
 	if err != nil {
 		return nil, err
 	}
+	if name == "" {
+		return arguments, nil
+	}
 
 	// Set function id
-	packed := abi.Methods[name].Id()
+	packed := method.Id()
 	packed = append(packed, arguments...)
 
 	return packed, nil
 }
 
+// Unpack decodes the output of the named method, as returned by a call,
+// into v. If the method has a single output, v must be a pointer to a
+// value that can hold it. Multiple outputs are decoded into a pointer
+// to a struct, whose fields are matched to the capitalised output names,
+// or into a pointer to a []interface{}. If the list already holds a
+// pointer for every output, the values are stored through them.
+//
+// Integers are decoded into *big.Int or any Go integer type that can hold
+// them, addresses into common.Address or []byte and arrays into slices
+// or arrays of a matching element type.
+func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
+	method, exist := abi.Methods[name]
+	if !exist {
+		return fmt.Errorf("method '%s' not found", name)
+	}
+	if len(output) == 0 {
+		return fmt.Errorf("abi: unmarshalling empty output")
+	}
+	values, err := unpackTuple(method.Output, output)
+	if err != nil {
+		return err
+	}
+	return assign(v, method.Output, values)
+}
+
 func (abi *ABI) UnmarshalJSON(data []byte) error {
-	var methods []Method
-	if err := json.Unmarshal(data, &methods); err != nil {
+	var fields []struct {
+		Type      string
+		Name      string
+		Const     bool
+		Constant  bool
+		Anonymous bool
+		Input     []Argument
+		Inputs    []Argument
+		Output    []Argument
+		Outputs   []Argument
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
 		return err
 	}
 
 	abi.Methods = make(map[string]Method)
-	for _, method := range methods {
-		abi.Methods[method.Name] = method
+	abi.Events = make(map[string]Event)
+	for _, field := range fields {
+		switch field.Type {
+		case "constructor":
+			abi.Constructor = Method{
+				Input: append(field.Input, field.Inputs...),
+			}
+		case "", "function":
+			abi.Methods[field.Name] = Method{
+				Name:   field.Name,
+				Const:  field.Const || field.Constant,
+				Input:  append(field.Input, field.Inputs...),
+				Output: append(field.Output, field.Outputs...),
+			}
+		case "event":
+			abi.Events[field.Name] = Event{
+				Name:      field.Name,
+				Anonymous: field.Anonymous,
+				Input:     append(field.Input, field.Inputs...),
+			}
+		}
 	}
 
 	return nil
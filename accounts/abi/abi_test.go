@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
@@ -73,12 +74,12 @@ func TestReader(t *testing.T) {
 	exp := ABI{
 		Methods: map[string]Method{
 			"balance": Method{
-				"balance", true, nil, Type{},
+				"balance", true, nil, nil,
 			},
 			"send": Method{
 				"send", false, []Argument{
-					Argument{"amount", Uint256},
-				}, Type{},
+					Argument{"amount", Uint256, false},
+				}, nil,
 			},
 		},
 	}
@@ -219,7 +220,7 @@ func TestTestAddress(t *testing.T) {
 func TestMethodSignature(t *testing.T) {
 	String, _ := NewType("string")
 	String32, _ := NewType("string32")
-	m := Method{"foo", false, []Argument{Argument{"bar", String32}, Argument{"baz", String}}, Type{}}
+	m := Method{"foo", false, []Argument{Argument{"bar", String32, false}, Argument{"baz", String, false}}, nil}
 	exp := "foo(string32,string)"
 	if m.String() != exp {
 		t.Error("signature mismatch", exp, "!=", m.String())
@@ -231,7 +232,7 @@ func TestMethodSignature(t *testing.T) {
 	}
 
 	uintt, _ := NewType("uint")
-	m = Method{"foo", false, []Argument{Argument{"bar", uintt}}, Type{}}
+	m = Method{"foo", false, []Argument{Argument{"bar", uintt, false}}, nil}
 	exp = "foo(uint256)"
 	if m.String() != exp {
 		t.Error("signature mismatch", exp, "!=", m.String())
@@ -283,6 +284,36 @@ func TestMultiPack(t *testing.T) {
 	}
 }
 
+func TestPackConstructor(t *testing.T) {
+	abi, err := JSON(strings.NewReader(`[
+		{ "type" : "constructor", "inputs" : [ { "name" : "supply", "type" : "uint256" }, { "name" : "name", "type" : "string" } ] },
+		{ "type" : "function", "name" : "name", "constant" : true, "outputs" : [ { "name" : "", "type" : "string" } ] }
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abi.Methods) != 1 || len(abi.Constructor.Input) != 2 {
+		t.Fatalf("constructor not parsed: %+v", abi)
+	}
+
+	// constructor arguments are packed without a method id
+	packed, err := abi.Pack("", big.NewInt(10), "ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := common.Hex2Bytes("" +
+		"000000000000000000000000000000000000000000000000000000000000000a" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"6162000000000000000000000000000000000000000000000000000000000000")
+	if !bytes.Equal(packed, exp) {
+		t.Errorf("expected %x got %x", exp, packed)
+	}
+	if _, err := abi.Pack("", big.NewInt(10)); err == nil {
+		t.Errorf("expected error for constructor argument count mismatch")
+	}
+}
+
 func TestPackSlice(t *testing.T) {
 	abi, err := JSON(strings.NewReader(jsondata2))
 	if err != nil {
@@ -328,3 +359,195 @@ func TestPackSliceBig(t *testing.T) {
 		t.Errorf("expected %x got %x", sig, packed)
 	}
 }
+
+func TestNestedTypes(t *testing.T) {
+	tests := []struct {
+		typ, canonical string
+		dynamic        bool
+		headSize       int
+	}{
+		{"uint", "uint256", false, 32},
+		{"byte", "bytes1", false, 32},
+		{"bytes32", "bytes32", false, 32},
+		{"function", "function", false, 32},
+		{"uint[2]", "uint256[2]", false, 64},
+		{"uint[2][3]", "uint256[2][3]", false, 192},
+		{"uint[2][]", "uint256[2][]", true, 32},
+		{"uint[][2]", "uint256[][2]", true, 32},
+		{"string[2]", "string[2]", true, 32},
+		{"bytes3[2]", "bytes3[2]", false, 64},
+	}
+	for _, test := range tests {
+		typ, err := NewType(test.typ)
+		if err != nil {
+			t.Errorf("%s: %v", test.typ, err)
+			continue
+		}
+		if typ.String() != test.canonical {
+			t.Errorf("%s: canonical name mismatch: have %s, want %s", test.typ, typ, test.canonical)
+		}
+		if typ.isDynamic() != test.dynamic {
+			t.Errorf("%s: dynamic mismatch: have %v, want %v", test.typ, typ.isDynamic(), test.dynamic)
+		}
+		if typ.headSize() != test.headSize {
+			t.Errorf("%s: head size mismatch: have %d, want %d", test.typ, typ.headSize(), test.headSize)
+		}
+	}
+	// the outermost dimension is the last one
+	typ, _ := NewType("uint8[2][]")
+	if typ.SliceSize != -1 || typ.Elem.SliceSize != 2 || typ.Elem.Elem.T != UintTy {
+		t.Errorf("uint8[2][] parsed incorrectly: %+v", typ)
+	}
+
+	for _, invalid := range []string{"int7", "uint264", "bytes0", "bytes33", "foo", "uint[", "uint[a]", ""} {
+		if _, err := NewType(invalid); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
+
+// Tests packing against the examples of the ABI specification, which
+// were produced by the Solidity compiler.
+func TestPackSolidityVectors(t *testing.T) {
+	tests := []struct {
+		def    string
+		method string
+		args   []interface{}
+		packed string
+	}{
+		{
+			`[{ "name" : "baz", "input" : [ { "type" : "uint32" }, { "type" : "bool" } ] }]`,
+			"baz", []interface{}{uint32(69), true},
+			"cdcd77c0" +
+				"0000000000000000000000000000000000000000000000000000000000000045" +
+				"0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			`[{ "name" : "bar", "input" : [ { "type" : "bytes3[2]" } ] }]`,
+			"bar", []interface{}{[]string{"abc", "def"}},
+			"fce353f6" +
+				"6162630000000000000000000000000000000000000000000000000000000000" +
+				"6465660000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			`[{ "name" : "sam", "input" : [ { "type" : "bytes" }, { "type" : "bool" }, { "type" : "uint[]" } ] }]`,
+			"sam", []interface{}{[]byte("dave"), true, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}},
+			"a5643bf2" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"0000000000000000000000000000000000000000000000000000000000000004" +
+				"6461766500000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000003",
+		},
+		{
+			`[{ "name" : "f", "input" : [ { "type" : "uint" }, { "type" : "uint32[]" }, { "type" : "bytes10" }, { "type" : "bytes" } ] }]`,
+			"f", []interface{}{big.NewInt(0x123), []uint32{0x456, 0x789}, []byte("1234567890"), []byte("Hello, world!")},
+			"8be65246" +
+				"0000000000000000000000000000000000000000000000000000000000000123" +
+				"0000000000000000000000000000000000000000000000000000000000000080" +
+				"3132333435363738393000000000000000000000000000000000000000000000" +
+				"00000000000000000000000000000000000000000000000000000000000000e0" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000456" +
+				"0000000000000000000000000000000000000000000000000000000000000789" +
+				"000000000000000000000000000000000000000000000000000000000000000d" +
+				"48656c6c6f2c20776f726c642100000000000000000000000000000000000000",
+		},
+		{
+			`[{ "name" : "g", "input" : [ { "type" : "uint[][]" }, { "type" : "string[]" } ] }]`,
+			"g", []interface{}{
+				[][]*big.Int{{big.NewInt(1), big.NewInt(2)}, {big.NewInt(3)}},
+				[]string{"one", "two", "three"},
+			},
+			"2289b18c" +
+				"0000000000000000000000000000000000000000000000000000000000000040" +
+				"0000000000000000000000000000000000000000000000000000000000000140" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000040" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"00000000000000000000000000000000000000000000000000000000000000e0" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"6f6e650000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"74776f0000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000005" +
+				"7468726565000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			`[{ "name" : "neg", "input" : [ { "type" : "int8" }, { "type" : "int256" } ] }]`,
+			"neg", []interface{}{int8(-1), big.NewInt(-2)},
+			"d4c2885b" +
+				"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+				"fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe",
+		},
+	}
+	for _, test := range tests {
+		abi, err := JSON(strings.NewReader(test.def))
+		if err != nil {
+			t.Fatalf("%s: %v", test.method, err)
+		}
+		packed, err := abi.Pack(test.method, test.args...)
+		if err != nil {
+			t.Errorf("%s: pack error: %v", test.method, err)
+			continue
+		}
+		if want := common.Hex2Bytes(test.packed); !bytes.Equal(packed, want) {
+			t.Errorf("%s: packed data mismatch:\nhave %x\nwant %x", test.method, packed, want)
+		}
+	}
+}
+
+func TestPackRanges(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value interface{}
+		ok    bool
+	}{
+		{"uint8", uint8(255), true},
+		{"uint8", 256, false},
+		{"uint8", big.NewInt(256), false},
+		{"uint8", -1, false},
+		{"uint256", big.NewInt(-1), false},
+		{"int8", 127, true},
+		{"int8", 128, false},
+		{"int8", -128, true},
+		{"int8", -129, false},
+		{"int256", new(big.Int).Lsh(common.Big1, 255), false},
+		{"uint64", uint64(1<<64 - 1), true},
+		{"bytes2", []byte{1, 2}, true},
+		{"bytes2", []byte{1, 2, 3}, false},
+		{"bytes2", [3]byte{}, false},
+		{"bytes32", common.Hash{}, true},
+		{"address", common.Address{}, true},
+		{"address", make([]byte, 21), false},
+		{"function", [24]byte{}, true},
+		{"function", [20]byte{}, false},
+		{"uint[2]", []uint{1}, false},
+		{"bool", 1, false},
+	}
+	for _, test := range tests {
+		typ, err := NewType(test.typ)
+		if err != nil {
+			t.Fatalf("%s: %v", test.typ, err)
+		}
+		_, err = typ.pack(reflect.ValueOf(test.value))
+		if test.ok && err != nil {
+			t.Errorf("%s %v: unexpected error: %v", test.typ, test.value, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s %v: expected error", test.typ, test.value)
+		}
+	}
+}
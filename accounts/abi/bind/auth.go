@@ -0,0 +1,50 @@
+package bind
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var errNotAuthorized = errors.New("not authorized to sign this account")
+
+// NewKeyedTransactor is a utility method to easily create a transaction signer
+// from a single private key.
+func NewKeyedTransactor(key *ecdsa.PrivateKey) *TransactOpts {
+	keyAddr := common.BytesToAddress(crypto.PubkeyToAddress(key.PublicKey))
+	return &TransactOpts{
+		From: keyAddr,
+		Signer: func(address common.Address, tx *types.Transaction) error {
+			if address != keyAddr {
+				return errNotAuthorized
+			}
+			return tx.SignECDSA(key)
+		},
+	}
+}
+
+// NewManagerTransactor creates a transaction signer for an account of the
+// account manager. The account must be unlocked while transacting.
+func NewManagerTransactor(am *accounts.Manager, from common.Address) *TransactOpts {
+	return &TransactOpts{
+		From: from,
+		Signer: func(address common.Address, tx *types.Transaction) error {
+			if address != from {
+				return errNotAuthorized
+			}
+			var to []byte
+			if addr := tx.To(); addr != nil {
+				to = addr.Bytes()
+			}
+			sig, err := am.SignTx(accounts.Account{Address: from.Bytes()}, to, tx.Value(), tx.Gas(), tx.GasPrice(), tx.Hash().Bytes())
+			if err != nil {
+				return err
+			}
+			return tx.SetSignatureValues(sig)
+		},
+	}
+}
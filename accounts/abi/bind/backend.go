@@ -0,0 +1,62 @@
+package bind
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ContractCaller defines the methods needed to allow operating with contracts
+// on a read only basis.
+type ContractCaller interface {
+	// ContractCall executes a call to the contract with the given input data
+	// without creating a transaction and returns its output. If pending is
+	// set, the call is executed against the pending state instead of the
+	// state of the current block.
+	ContractCall(contract common.Address, data []byte, pending bool) ([]byte, error)
+}
+
+// ContractTransactor defines the methods needed to allow operating with
+// contracts on a write only basis. Transactions are signed by the caller
+// before they are handed to the backend.
+type ContractTransactor interface {
+	// PendingAccountNonce returns the nonce to use for the next transaction
+	// of the account, taking pending transactions into account.
+	PendingAccountNonce(account common.Address) (uint64, error)
+
+	// SuggestGasPrice returns the gas price to use if none was specified.
+	SuggestGasPrice() (*big.Int, error)
+
+	// EstimateGasLimit returns the gas needed to execute the given
+	// transaction against the pending state. A nil contract means the
+	// data is the code of a contract to create.
+	EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error)
+
+	// SendTransaction injects the signed transaction into the pending pool.
+	SendTransaction(tx *types.Transaction) error
+}
+
+// ContractFilterer defines the methods needed to access the logs emitted by
+// contracts. The topics follow the rules of core.Filter: each position lists
+// the accepted topics, an empty position matches all topics.
+type ContractFilterer interface {
+	// FilterLogs returns the logs of the blocks from earliest to latest
+	// matching the given addresses and topics. A block number of -1 stands
+	// for the current block.
+	FilterLogs(earliest, latest int64, addresses []common.Address, topics [][]common.Hash) (state.Logs, error)
+
+	// SubscribeLogs delivers the matching logs of new blocks to sink until
+	// the returned function is called.
+	SubscribeLogs(addresses []common.Address, topics [][]common.Hash, sink chan<- *state.Log) (unsubscribe func(), err error)
+}
+
+// ContractBackend defines the methods needed to work with contracts on a
+// read-write basis. It is implemented by xeth.XEth for a running node and by
+// backends.SimulatedBackend for testing.
+type ContractBackend interface {
+	ContractCaller
+	ContractTransactor
+	ContractFilterer
+}
@@ -0,0 +1,285 @@
+// Package backends contains implementations of the contract binding backends.
+package backends
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/event/filter"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Default gas price of transactions on the simulated chain.
+var simulatedGasPrice = big.NewInt(1)
+
+// SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
+// the background. Transactions are executed immediately on a pending block,
+// which is added to the chain without proof-of-work when Commit is called.
+// Its main purpose is to allow easily testing contract bindings.
+type SimulatedBackend struct {
+	database   *ethdb.MemDatabase
+	mux        *event.TypeMux
+	blockchain *core.ChainManager
+	processor  *core.BlockProcessor
+	filters    *filter.FilterManager
+
+	mu           sync.Mutex
+	pendingBlock *types.Block   // Block being built, including all sent transactions
+	pendingState *state.StateDB // State after the pending transactions
+	pendingGas   *big.Int       // Gas used by the pending transactions
+}
+
+// NewSimulatedBackend creates a new binding backend using a simulated blockchain
+// whose genesis state holds the given accounts.
+func NewSimulatedBackend(accounts ...core.GenesisAccount) *SimulatedBackend {
+	database, _ := ethdb.NewMemDatabase()
+	mux := new(event.TypeMux)
+
+	blockchain := core.NewChainManager(database, database, params.DefaultChainConfig, mux)
+	blockchain.ResetWithGenesisBlock(core.CustomGenesisBlock(database, accounts...))
+
+	txpool := core.NewTxPool(mux, blockchain.State, blockchain.PendingRules)
+	processor := core.NewBlockProcessor(database, database, consensus.NewProofOfWork(core.FakePow{}), txpool, blockchain, mux)
+	blockchain.SetProcessor(processor)
+
+	backend := &SimulatedBackend{
+		database:   database,
+		mux:        mux,
+		blockchain: blockchain,
+		processor:  processor,
+		filters:    filter.NewFilterManager(mux),
+	}
+	backend.filters.Start()
+	backend.rollback()
+	return backend
+}
+
+// Commit imports all the pending transactions as a single block and starts a
+// fresh new state.
+func (b *SimulatedBackend) Commit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	block := b.pendingBlock
+	block.Header().GasUsed = b.pendingGas
+	b.processor.Engine().Finalize(b.blockchain, b.pendingState, block)
+	b.pendingState.Update()
+	block.SetRoot(b.pendingState.Root())
+
+	if err := b.blockchain.InsertChain(types.Blocks{block}); err != nil {
+		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
+	}
+	b.rollback()
+}
+
+// Rollback aborts all pending transactions, reverting to the last committed state.
+func (b *SimulatedBackend) Rollback() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollback()
+}
+
+// rollback starts a new pending block on top of the current chain head.
+func (b *SimulatedBackend) rollback() {
+	parent := b.blockchain.CurrentBlock()
+
+	b.pendingBlock = core.NewBlockFromParent(b.blockchain.Config(), common.Address{}, parent)
+	b.pendingState = state.New(parent.Root(), b.database)
+	b.pendingGas = new(big.Int)
+
+	b.pendingState.GetOrNewStateObject(b.pendingBlock.Coinbase()).SetGasPool(b.pendingBlock.GasLimit())
+}
+
+// CodeAt returns the code associated with a certain account in the blockchain.
+func (b *SimulatedBackend) CodeAt(account common.Address, pending bool) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, _ := b.state(pending)
+	return statedb.GetCode(account)
+}
+
+// BalanceAt returns the balance of a certain account in the blockchain.
+func (b *SimulatedBackend) BalanceAt(account common.Address, pending bool) *big.Int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, _ := b.state(pending)
+	return statedb.GetBalance(account)
+}
+
+// ContractCall implements bind.ContractCaller, executing the call against the
+// current or pending state without changing it.
+func (b *SimulatedBackend) ContractCall(contract common.Address, data []byte, pending bool) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, block := b.state(pending)
+	statedb = statedb.Copy()
+
+	from := statedb.GetOrNewStateObject(common.Address{})
+	msg := callmsg{from: from, to: &contract, gas: new(big.Int).Set(block.GasLimit()), value: new(big.Int), data: data}
+	vmenv := core.NewEnv(statedb, b.blockchain, msg, block)
+
+	return vmenv.Call(from, contract, data, msg.gas, new(big.Int), msg.value)
+}
+
+// PendingAccountNonce implements bind.ContractTransactor, retrieving the nonce
+// of the account after the pending transactions.
+func (b *SimulatedBackend) PendingAccountNonce(account common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pendingState.GetNonce(account), nil
+}
+
+// SuggestGasPrice implements bind.ContractTransactor. The simulated chain
+// has a fixed gas price.
+func (b *SimulatedBackend) SuggestGasPrice() (*big.Int, error) {
+	return new(big.Int).Set(simulatedGasPrice), nil
+}
+
+// EstimateGasLimit implements bind.ContractTransactor, executing the
+// transaction on top of the pending state and returning the gas it used.
+func (b *SimulatedBackend) EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb := b.pendingState.Copy()
+	msg := callmsg{
+		from:  statedb.GetOrNewStateObject(sender),
+		to:    contract,
+		gas:   new(big.Int).Set(b.pendingBlock.GasLimit()),
+		value: value,
+		data:  data,
+	}
+	coinbase := statedb.GetOrNewStateObject(b.pendingBlock.Coinbase())
+	coinbase.SetGasPool(b.pendingBlock.GasLimit())
+
+	vmenv := core.NewEnv(statedb, b.blockchain, msg, b.pendingBlock)
+	_, gas, err := core.ApplyMessage(vmenv, msg, coinbase)
+	if err != nil {
+		return nil, err
+	}
+	return gas, nil
+}
+
+// SendTransaction implements bind.ContractTransactor, executing the signed
+// transaction on the pending block. Transactions that can't be included in
+// a block are rejected.
+func (b *SimulatedBackend) SendTransaction(tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := tx.From(); err != nil {
+		return fmt.Errorf("invalid transaction: %v", err)
+	}
+	snapshot := b.pendingState.Copy()
+	coinbase := b.pendingState.GetStateObject(b.pendingBlock.Coinbase())
+
+	b.pendingState.StartRecord(tx.Hash(), common.Hash{}, len(b.pendingBlock.Transactions()))
+	receipt, _, err := b.processor.ApplyTransaction(coinbase, b.pendingState, b.pendingBlock, tx, b.pendingGas, true)
+	if err != nil && (core.IsNonceErr(err) || state.IsGasLimitErr(err) || core.IsInvalidTxErr(err)) {
+		b.pendingState.Set(snapshot)
+		return err
+	}
+	b.pendingBlock.AddTransaction(tx)
+	b.pendingBlock.AddReceipt(receipt)
+
+	return nil
+}
+
+// FilterLogs implements bind.ContractFilterer, searching the committed blocks
+// for matching logs.
+func (b *SimulatedBackend) FilterLogs(earliest, latest int64, addresses []common.Address, topics [][]common.Hash) (state.Logs, error) {
+	filter := core.NewFilter(&filterBackend{b})
+	filter.SetEarliestBlock(earliest)
+	filter.SetLatestBlock(latest)
+	filter.SetAddress(addresses)
+	filter.SetTopics(topics)
+
+	return filter.Find(), nil
+}
+
+// SubscribeLogs implements bind.ContractFilterer, delivering the matching logs
+// of newly committed blocks to sink.
+func (b *SimulatedBackend) SubscribeLogs(addresses []common.Address, topics [][]common.Hash, sink chan<- *state.Log) (func(), error) {
+	quit := make(chan struct{})
+
+	filter := core.NewFilter(&filterBackend{b})
+	filter.SetAddress(addresses)
+	filter.SetTopics(topics)
+	filter.LogsCallback = func(logs state.Logs) {
+		for _, log := range logs {
+			select {
+			case sink <- log:
+			case <-quit:
+				return
+			}
+		}
+	}
+	id := b.filters.InstallFilter(filter)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			b.filters.UninstallFilter(id)
+		})
+	}, nil
+}
+
+// state returns the current or pending state along with the block it belongs to.
+func (b *SimulatedBackend) state(pending bool) (*state.StateDB, *types.Block) {
+	if pending {
+		return b.pendingState, b.pendingBlock
+	}
+	block := b.blockchain.CurrentBlock()
+	return state.New(block.Root(), b.database), block
+}
+
+// callmsg implements core.Message to allow passing it as a transaction simulator.
+type callmsg struct {
+	from  *state.StateObject
+	to    *common.Address // nil means contract creation
+	gas   *big.Int
+	value *big.Int
+	data  []byte
+}
+
+func (m callmsg) From() (common.Address, error) { return m.from.Address(), nil }
+func (m callmsg) Nonce() uint64                 { return m.from.Nonce() }
+func (m callmsg) To() *common.Address           { return m.to }
+func (m callmsg) GasPrice() *big.Int            { return new(big.Int) }
+func (m callmsg) Gas() *big.Int                 { return m.gas }
+func (m callmsg) Value() *big.Int               { return m.value }
+func (m callmsg) Data() []byte                  { return m.data }
+
+// filterBackend implements core.Backend for the log filters of the simulated
+// chain, which have no network.
+type filterBackend struct {
+	b *SimulatedBackend
+}
+
+func (fb *filterBackend) BlockProcessor() *core.BlockProcessor { return fb.b.processor }
+func (fb *filterBackend) ChainManager() *core.ChainManager     { return fb.b.blockchain }
+func (fb *filterBackend) TxPool() *core.TxPool                 { return nil }
+func (fb *filterBackend) PeerCount() int                       { return 0 }
+func (fb *filterBackend) IsListening() bool                    { return false }
+func (fb *filterBackend) Peers() []*p2p.Peer                   { return nil }
+func (fb *filterBackend) BlockDb() common.Database             { return fb.b.database }
+func (fb *filterBackend) StateDb() common.Database             { return fb.b.database }
+func (fb *filterBackend) EventMux() *event.TypeMux             { return fb.b.mux }
+
+var _ bind.ContractBackend = (*SimulatedBackend)(nil)
@@ -0,0 +1,153 @@
+package backends
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// storeABI describes a contract holding a single number, which is set by
+// the constructor and by set. Every update raises a Set event.
+const storeABI = `[
+	{ "type" : "constructor", "inputs" : [ { "name" : "initial", "type" : "uint256" } ] },
+	{ "type" : "function", "name" : "get", "constant" : true, "inputs" : [], "outputs" : [ { "name" : "", "type" : "uint256" } ] },
+	{ "type" : "function", "name" : "set", "constant" : false, "inputs" : [ { "name" : "value", "type" : "uint256" } ], "outputs" : [] },
+	{ "type" : "event", "name" : "Set", "inputs" : [ { "name" : "who", "type" : "address", "indexed" : true }, { "name" : "value", "type" : "uint256" } ] }
+]`
+
+// storeCode returns the hand assembled code of the store contract. The
+// runtime code treats calls with 4 bytes of input as get, all others as set.
+func storeCode(setEvent common.Hash) []byte {
+	init := "6020" + "605e" + "6000" + "39" + // CODECOPY(0, 0x5e, 32): constructor argument
+		"600051" + "600055" + // SSTORE(0, MLOAD(0))
+		"6045" + "6019" + "6000" + "39" + // CODECOPY(0, 0x19, 0x45): runtime code
+		"6045" + "6000" + "f3" // RETURN(0, 0x45)
+	runtime := "36" + "6004" + "14" + "6039" + "57" + // JUMPI(get, CALLDATASIZE == 4)
+		"600435" + "80" + "600055" + "600052" + // SSTORE(0, v), MSTORE(0, v)
+		"33" + "7f" + common.Bytes2Hex(setEvent[:]) + "6020" + "6000" + "a2" + "00" + // LOG2(0, 32, Set, CALLER), STOP
+		"5b" + "600054" + "600052" + "6020" + "6000" + "f3" // get: RETURN(0, 32) with MSTORE(0, SLOAD(0))
+	return common.Hex2Bytes(init + runtime)
+}
+
+func TestSimulatedBackend(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key)
+	sim := NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(1000000000)})
+
+	parsed, err := abi.JSON(strings.NewReader(storeABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, _, store, err := bind.DeployContract(auth, parsed, storeCode(parsed.Events["Set"].Id()), sim, big.NewInt(7))
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	if len(sim.CodeAt(addr, true)) == 0 || len(sim.CodeAt(addr, false)) != 0 {
+		t.Fatalf("contract code should only be pending before commit")
+	}
+	sim.Commit()
+	if len(sim.CodeAt(addr, false)) == 0 {
+		t.Fatalf("contract code missing after commit")
+	}
+
+	get := func(pending bool) int64 {
+		var value *big.Int
+		if err := store.Call(&bind.CallOpts{Pending: pending}, &value, "get"); err != nil {
+			t.Fatalf("failed to call get: %v", err)
+		}
+		return value.Int64()
+	}
+	if v := get(false); v != 7 {
+		t.Errorf("initial value mismatch: have %d, want 7", v)
+	}
+
+	// transactions are only visible in the pending state until committed
+	if _, err := store.Transact(auth, "set", big.NewInt(42)); err != nil {
+		t.Fatalf("failed to transact: %v", err)
+	}
+	if cur, pend := get(false), get(true); cur != 7 || pend != 42 {
+		t.Errorf("value mismatch before commit: current %d, pending %d", cur, pend)
+	}
+	sim.Commit()
+	if v := get(false); v != 42 {
+		t.Errorf("value mismatch after commit: have %d, want 42", v)
+	}
+
+	// rolled back transactions are dropped
+	if _, err := store.Transact(auth, "set", big.NewInt(99)); err != nil {
+		t.Fatalf("failed to transact: %v", err)
+	}
+	sim.Rollback()
+	sim.Commit()
+	if v := get(false); v != 42 {
+		t.Errorf("value mismatch after rollback: have %d, want 42", v)
+	}
+	if balance := sim.BalanceAt(auth.From, false); balance.Cmp(big.NewInt(1000000000)) >= 0 {
+		t.Errorf("gas not paid: balance %v", balance)
+	}
+}
+
+func TestSimulatedBackendLogs(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key)
+	sim := NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(1000000000)})
+
+	parsed, _ := abi.JSON(strings.NewReader(storeABI))
+	_, _, store, err := bind.DeployContract(auth, parsed, storeCode(parsed.Events["Set"].Id()), sim, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	sim.Commit()
+
+	type setEvent struct {
+		Who   common.Address
+		Value *big.Int
+	}
+	sink := make(chan *setEvent, 1)
+	unsubscribe, err := store.WatchLogs(sink, "Set", []interface{}{auth.From})
+	if err != nil {
+		t.Fatalf("failed to watch logs: %v", err)
+	}
+	defer unsubscribe()
+
+	for i := int64(1); i <= 3; i++ {
+		if _, err := store.Transact(auth, "set", big.NewInt(i)); err != nil {
+			t.Fatalf("failed to transact: %v", err)
+		}
+		sim.Commit()
+	}
+	// logs are posted asynchronously, their order is not guaranteed
+	seen := make(map[int64]bool)
+	for i := 0; i < 3; i++ {
+		select {
+		case event := <-sink:
+			if event.Who != auth.From {
+				t.Errorf("event sender mismatch: %+v", event)
+			}
+			seen[event.Value.Int64()] = true
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not delivered", i)
+		}
+	}
+	if !seen[1] || !seen[2] || !seen[3] {
+		t.Errorf("events missing: %v", seen)
+	}
+
+	logs, err := store.FilterLogs(nil, "Set", []interface{}{auth.From})
+	if err != nil {
+		t.Fatalf("failed to filter logs: %v", err)
+	}
+	if len(logs) != 3 {
+		t.Fatalf("log count mismatch: have %d, want 3", len(logs))
+	}
+	if logs, _ := store.FilterLogs(nil, "Set", []interface{}{common.Address{1}}); len(logs) != 0 {
+		t.Errorf("logs of other senders returned: %v", logs)
+	}
+}
@@ -0,0 +1,263 @@
+package bind
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignerFn signs the transaction on behalf of the given account.
+type SignerFn func(from common.Address, tx *types.Transaction) error
+
+// CallOpts is the collection of options to fine tune a contract call request.
+type CallOpts struct {
+	Pending bool // Whether to operate on the pending state or the last known one
+}
+
+// TransactOpts is the collection of authorization data required to create a
+// valid transaction. Nil values are filled in from the backend.
+type TransactOpts struct {
+	From   common.Address // Ethereum account to send the transaction from
+	Nonce  *big.Int       // Nonce to use for the transaction execution (nil = use pending state)
+	Signer SignerFn       // Method to use for signing the transaction (mandatory)
+
+	Value    *big.Int // Funds to transfer along the transaction (nil = 0 = no funds)
+	GasPrice *big.Int // Gas price to use for the transaction execution (nil = gas price oracle)
+	GasLimit *big.Int // Gas limit to set for the transaction execution (nil = estimate)
+}
+
+// FilterOpts is the block range to retrieve past contract logs from.
+type FilterOpts struct {
+	Start int64 // First block to include
+	End   int64 // Last block to include, -1 for the current block
+}
+
+// BoundContract is the base wrapper object that reflects a contract on the
+// Ethereum network. It contains a collection of methods that are used by the
+// generated bindings to operate on the contract.
+type BoundContract struct {
+	address    common.Address
+	abi        abi.ABI
+	caller     ContractCaller
+	transactor ContractTransactor
+	filterer   ContractFilterer
+}
+
+// NewBoundContract creates a low level contract interface through which calls,
+// transactions and log queries may be made.
+func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller, transactor ContractTransactor, filterer ContractFilterer) *BoundContract {
+	return &BoundContract{
+		address:    address,
+		abi:        abi,
+		caller:     caller,
+		transactor: transactor,
+		filterer:   filterer,
+	}
+}
+
+// DeployContract deploys a contract onto the Ethereum blockchain and binds the
+// deployment address with a Go wrapper.
+func DeployContract(opts *TransactOpts, abi abi.ABI, bytecode []byte, backend ContractBackend, params ...interface{}) (common.Address, *types.Transaction, *BoundContract, error) {
+	c := NewBoundContract(common.Address{}, abi, backend, backend, backend)
+
+	input, err := c.abi.Pack("", params...)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	tx, err := c.transact(opts, nil, append(common.CopyBytes(bytecode), input...))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	c.address = crypto.CreateAddress(opts.From, tx.Nonce())
+	return c.address, tx, c, nil
+}
+
+// Address returns the address the contract is bound to.
+func (c *BoundContract) Address() common.Address {
+	return c.address
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// decodes the output into result, following the rules of abi.ABI.Unpack.
+func (c *BoundContract) Call(opts *CallOpts, result interface{}, method string, params ...interface{}) error {
+	if opts == nil {
+		opts = new(CallOpts)
+	}
+	input, err := c.abi.Pack(method, params...)
+	if err != nil {
+		return err
+	}
+	output, err := c.caller.ContractCall(c.address, input, opts.Pending)
+	if err != nil {
+		return err
+	}
+	return c.abi.Unpack(result, method, output)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (c *BoundContract) Transact(opts *TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	input, err := c.abi.Pack(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	return c.transact(opts, &c.address, input)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (c *BoundContract) Transfer(opts *TransactOpts) (*types.Transaction, error) {
+	return c.transact(opts, &c.address, nil)
+}
+
+// transact executes an actual transaction invocation, first deriving any missing
+// authorization fields, and then scheduling the transaction for execution.
+func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, input []byte) (*types.Transaction, error) {
+	if opts.Signer == nil {
+		return nil, fmt.Errorf("no signer to authorize the transaction with")
+	}
+	value := opts.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	var nonce uint64
+	if opts.Nonce == nil {
+		var err error
+		if nonce, err = c.transactor.PendingAccountNonce(opts.From); err != nil {
+			return nil, fmt.Errorf("failed to retrieve account nonce: %v", err)
+		}
+	} else {
+		nonce = opts.Nonce.Uint64()
+	}
+	price := opts.GasPrice
+	if price == nil {
+		var err error
+		if price, err = c.transactor.SuggestGasPrice(); err != nil {
+			return nil, fmt.Errorf("failed to suggest gas price: %v", err)
+		}
+	}
+	gas := opts.GasLimit
+	if gas == nil {
+		var err error
+		if gas, err = c.transactor.EstimateGasLimit(opts.From, contract, value, input); err != nil {
+			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+		}
+	}
+
+	var tx *types.Transaction
+	if contract == nil {
+		tx = types.NewContractCreationTx(value, gas, price, input)
+	} else {
+		tx = types.NewTransactionMessage(*contract, value, gas, price, input)
+	}
+	tx.SetNonce(nonce)
+	if err := opts.Signer(opts.From, tx); err != nil {
+		return nil, err
+	}
+	if err := c.transactor.SendTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// FilterLogs retrieves the past logs of the named event within the block range
+// of opts. The query holds the accepted values of the indexed event arguments
+// in order, a nil entry accepts any value.
+func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]interface{}) (state.Logs, error) {
+	if opts == nil {
+		opts = &FilterOpts{End: -1}
+	}
+	topics, err := c.topics(name, query)
+	if err != nil {
+		return nil, err
+	}
+	return c.filterer.FilterLogs(opts.Start, opts.End, []common.Address{c.address}, topics)
+}
+
+// WatchLogs subscribes to new logs of the named event. Each log is decoded with
+// UnpackLog into a newly allocated value which is delivered to sink, a channel
+// of pointers to the event struct. If the struct has a Raw field of type
+// *state.Log, it is set to the original log. Logs that can't be decoded are
+// skipped. The returned function ends the subscription.
+func (c *BoundContract) WatchLogs(sink interface{}, name string, query ...[]interface{}) (func(), error) {
+	ch := reflect.ValueOf(sink)
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.SendDir == 0 || ch.Type().Elem().Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("bind: WatchLogs(invalid sink %T)", sink)
+	}
+	topics, err := c.topics(name, query)
+	if err != nil {
+		return nil, err
+	}
+	logs := make(chan *state.Log, 16)
+	unsubscribe, err := c.filterer.SubscribeLogs([]common.Address{c.address}, topics, logs)
+	if err != nil {
+		return nil, err
+	}
+
+	quit := make(chan struct{})
+	go func() {
+		elem := ch.Type().Elem().Elem()
+		for {
+			var log *state.Log
+			select {
+			case log = <-logs:
+			case <-quit:
+				return
+			}
+			event := reflect.New(elem)
+			if err := c.abi.UnpackLog(event.Interface(), name, log); err != nil {
+				continue
+			}
+			if raw := event.Elem().FieldByName("Raw"); raw.IsValid() && raw.Type() == reflect.TypeOf(log) {
+				raw.Set(reflect.ValueOf(log))
+			}
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{
+				{Dir: reflect.SelectSend, Chan: ch, Send: event},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(quit)},
+			})
+			if chosen == 1 {
+				return
+			}
+		}
+	}()
+	return func() {
+		unsubscribe()
+		close(quit)
+	}, nil
+}
+
+// UnpackLog decodes a log of the named event into v.
+func (c *BoundContract) UnpackLog(v interface{}, name string, log *state.Log) error {
+	return c.abi.UnpackLog(v, name, log)
+}
+
+// topics builds the topic filter of the named event from the accepted values
+// of its indexed arguments.
+func (c *BoundContract) topics(name string, query [][]interface{}) ([][]common.Hash, error) {
+	event, exist := c.abi.Events[name]
+	if !exist {
+		return nil, fmt.Errorf("event '%s' not found", name)
+	}
+	var indexed []abi.Argument
+	for _, input := range event.Input {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if len(query) > len(indexed) {
+		return nil, fmt.Errorf("event '%s' has %d indexed arguments, got %d", name, len(indexed), len(query))
+	}
+	topics, err := makeTopics(query)
+	if err != nil {
+		return nil, err
+	}
+	if event.Anonymous {
+		return topics, nil
+	}
+	return append([][]common.Hash{{event.Id()}}, topics...), nil
+}
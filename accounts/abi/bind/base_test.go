@@ -0,0 +1,211 @@
+package bind
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testBackend is a ContractBackend recording the requests made by the
+// bound contract.
+type testBackend struct {
+	output  []byte // returned by ContractCall
+	input   []byte
+	pending bool
+
+	sent []*types.Transaction
+
+	logs      state.Logs
+	addresses []common.Address
+	topics    [][]common.Hash
+	sink      chan<- *state.Log
+	unsubbed  bool
+}
+
+func (b *testBackend) ContractCall(contract common.Address, data []byte, pending bool) ([]byte, error) {
+	b.input, b.pending = data, pending
+	return b.output, nil
+}
+
+func (b *testBackend) PendingAccountNonce(account common.Address) (uint64, error) {
+	return 5, nil
+}
+
+func (b *testBackend) SuggestGasPrice() (*big.Int, error) {
+	return big.NewInt(100), nil
+}
+
+func (b *testBackend) EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error) {
+	return big.NewInt(int64(21000 + len(data))), nil
+}
+
+func (b *testBackend) SendTransaction(tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func (b *testBackend) FilterLogs(earliest, latest int64, addresses []common.Address, topics [][]common.Hash) (state.Logs, error) {
+	b.addresses, b.topics = addresses, topics
+	return b.logs, nil
+}
+
+func (b *testBackend) SubscribeLogs(addresses []common.Address, topics [][]common.Hash, sink chan<- *state.Log) (func(), error) {
+	b.addresses, b.topics, b.sink = addresses, topics, sink
+	return func() { b.unsubbed = true }, nil
+}
+
+func parseTokenABI(t *testing.T) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(tokenABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestDeployAndTransact(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth := NewKeyedTransactor(key)
+	backend := new(testBackend)
+
+	code := []byte{0x60, 0x60}
+	addr, tx, contract, err := DeployContract(auth, parseTokenABI(t), code, backend, big.NewInt(1), "x")
+	if err != nil {
+		t.Fatalf("deploy failed: %v", err)
+	}
+	if len(backend.sent) != 1 || backend.sent[0] != tx {
+		t.Fatalf("deploy transaction not sent")
+	}
+	if tx.To() != nil || tx.Nonce() != 5 || tx.GasPrice().Int64() != 100 {
+		t.Errorf("deploy transaction mismatch: %v", tx)
+	}
+	if !bytes.HasPrefix(tx.Data(), code) || len(tx.Data()) != len(code)+4*32 {
+		t.Errorf("constructor arguments not appended: %x", tx.Data())
+	}
+	if from, _ := tx.From(); from != auth.From {
+		t.Errorf("transaction signed by %x, want %x", from, auth.From)
+	}
+	if want := crypto.CreateAddress(auth.From, 5); addr != want || contract.Address() != want {
+		t.Errorf("contract address mismatch: have %x, want %x", addr, want)
+	}
+
+	auth.GasLimit = big.NewInt(50000)
+	tx, err = contract.Transact(auth, "transfer", common.Address{1}, uint64(2), [2][32]byte{})
+	if err != nil {
+		t.Fatalf("transact failed: %v", err)
+	}
+	if *tx.To() != addr || tx.Gas().Int64() != 50000 {
+		t.Errorf("transaction mismatch: %v", tx)
+	}
+	other, _ := crypto.GenerateKey()
+	auth.From = common.BytesToAddress(crypto.PubkeyToAddress(other.PublicKey))
+	if _, err := contract.Transact(auth, "transfer", common.Address{1}, uint64(2), [2][32]byte{}); err == nil {
+		t.Errorf("expected error for transaction from unauthorized account")
+	}
+}
+
+func TestCall(t *testing.T) {
+	backend := &testBackend{output: common.LeftPadBytes([]byte{0x03, 0xe8}, 32)}
+	contract := NewBoundContract(common.Address{1}, parseTokenABI(t), backend, backend, backend)
+
+	var balance *big.Int
+	if err := contract.Call(&CallOpts{Pending: true}, &balance, "balanceOf", common.Address{2}); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 1000", balance)
+	}
+	if !backend.pending || !bytes.Equal(backend.input[:4], parseTokenABI(t).Methods["balanceOf"].Id()) {
+		t.Errorf("call input mismatch: %x", backend.input)
+	}
+	if _, err := contract.Transact(new(TransactOpts), "transfer", common.Address{1}, uint64(2), [2][32]byte{}); err == nil {
+		t.Errorf("expected error for missing signer")
+	}
+}
+
+type tokenTransfer struct {
+	From  common.Address
+	Memo  common.Hash
+	Value *big.Int
+	Raw   *state.Log
+}
+
+func TestLogs(t *testing.T) {
+	parsed := parseTokenABI(t)
+	from := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	log := &state.Log{
+		Address: common.Address{1},
+		Topics:  []common.Hash{parsed.Events["Transfer"].Id(), common.BytesToHash(from[:]), common.BytesToHash(crypto.Sha3([]byte("memo")))},
+		Data:    common.LeftPadBytes([]byte{7}, 32),
+	}
+	backend := &testBackend{logs: state.Logs{log}}
+	contract := NewBoundContract(common.Address{1}, parsed, backend, backend, backend)
+
+	logs, err := contract.FilterLogs(nil, "Transfer", nil, []interface{}{"memo", "other"})
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("filter failed: %v", err)
+	}
+	want := [][]common.Hash{
+		{parsed.Events["Transfer"].Id()},
+		nil,
+		{common.BytesToHash(crypto.Sha3([]byte("memo"))), common.BytesToHash(crypto.Sha3([]byte("other")))},
+	}
+	if !reflect.DeepEqual(backend.topics, want) || !reflect.DeepEqual(backend.addresses, []common.Address{{1}}) {
+		t.Errorf("filter query mismatch: %v %v", backend.addresses, backend.topics)
+	}
+	if _, err := contract.FilterLogs(nil, "Transfer", nil, nil, nil); err == nil {
+		t.Errorf("expected error for too many indexed arguments")
+	}
+
+	sink := make(chan *tokenTransfer)
+	unsubscribe, err := contract.WatchLogs(sink, "Transfer")
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	backend.sink <- log
+	select {
+	case event := <-sink:
+		if event.From != from || event.Value.Int64() != 7 || event.Raw != log {
+			t.Errorf("event mismatch: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("event not delivered")
+	}
+	unsubscribe()
+	if !backend.unsubbed {
+		t.Errorf("backend subscription not ended")
+	}
+	if _, err := contract.WatchLogs(make(chan tokenTransfer), "Transfer"); err == nil {
+		t.Errorf("expected error for invalid sink")
+	}
+}
+
+func TestMakeTopics(t *testing.T) {
+	topics, err := makeTopics([][]interface{}{
+		{common.Address{1}, true, big.NewInt(-1), uint8(2), [4]byte{1, 2, 3, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []common.Hash{
+		common.BytesToHash(common.Address{1}.Bytes()),
+		common.BigToHash(big.NewInt(1)),
+		common.BytesToHash(bytes.Repeat([]byte{0xff}, 32)),
+		common.BigToHash(big.NewInt(2)),
+		common.BytesToHash(append([]byte{1, 2, 3, 4}, make([]byte, 28)...)),
+	}
+	if !reflect.DeepEqual(topics[0], want) {
+		t.Errorf("topics mismatch:\n  have: %x\n  want: %x", topics[0], want)
+	}
+	if _, err := makeTopics([][]interface{}{{struct{}{}}}); err == nil {
+		t.Errorf("expected error for unsupported topic type")
+	}
+}
@@ -0,0 +1,215 @@
+// Package bind generates Ethereum contract Go bindings.
+//
+// The generated code wraps a BoundContract, which packs the method arguments,
+// executes calls and transactions through a ContractBackend and decodes the
+// results and event logs using the accounts/abi package.
+package bind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// reserved holds the identifiers used by the generated code, which can't be
+// used as parameter names.
+var reserved = map[string]bool{
+	"opts": true, "auth": true, "backend": true, "sink": true, "err": true,
+	"parsed": true, "address": true, "tx": true, "contract": true, "out": true,
+	"log": true, "logs": true, "event": true, "events": true,
+}
+
+// Bind generates a Go wrapper around a contract ABI. The wrapper is a type
+// named typ in package pkg. If bytecode is given, a deploy function is
+// generated as well.
+func Bind(typ string, abiJSON string, bytecode string, pkg string) (string, error) {
+	evmABI, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return "", err
+	}
+	// compact the ABI so it can be embedded as a single line string
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(abiJSON)); err != nil {
+		return "", err
+	}
+	data := &tmplData{
+		Package:  pkg,
+		Type:     abi.Capitalise(typ),
+		InputABI: compact.String(),
+		InputBin: strings.TrimPrefix(strings.TrimSpace(bytecode), "0x"),
+	}
+	if data.Constructor, err = bindMethod(evmABI.Constructor); err != nil {
+		return "", fmt.Errorf("constructor: %v", err)
+	}
+
+	for _, name := range sortedMethods(evmABI.Methods) {
+		method, err := bindMethod(evmABI.Methods[name])
+		if err != nil {
+			return "", fmt.Errorf("method %s: %v", name, err)
+		}
+		// constant methods without outputs have nothing to return
+		if method.Original.Const && len(method.Outputs) > 0 {
+			data.Calls = append(data.Calls, method)
+		} else {
+			data.Transacts = append(data.Transacts, method)
+		}
+	}
+	for _, name := range sortedEvents(evmABI.Events) {
+		event, err := bindEvent(evmABI.Events[name])
+		if err != nil {
+			return "", fmt.Errorf("event %s: %v", name, err)
+		}
+		data.Events = append(data.Events, event)
+	}
+
+	var code bytes.Buffer
+	if err := template.Must(template.New("").Parse(tmplSource)).Execute(&code, data); err != nil {
+		return "", err
+	}
+	out, err := format.Source(code.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("%v\n%s", err, code.Bytes())
+	}
+	return string(out), nil
+}
+
+// bindMethod converts the arguments of an ABI method to Go parameters.
+func bindMethod(method abi.Method) (tmplMethod, error) {
+	m := tmplMethod{Original: method, Normalized: abi.Capitalise(method.Name)}
+	for i, input := range method.Input {
+		typ := bindType(input.Type)
+		if typ == "" {
+			return m, fmt.Errorf("unsupported type %v of input %d", input.Type, i)
+		}
+		m.Inputs = append(m.Inputs, tmplArg{Name: paramName(input.Name, i), Type: typ})
+	}
+	for i, output := range method.Output {
+		typ := bindType(output.Type)
+		if typ == "" {
+			return m, fmt.Errorf("unsupported type %v of output %d", output.Type, i)
+		}
+		m.Outputs = append(m.Outputs, tmplArg{Name: abi.Capitalise(output.Name), Type: typ})
+	}
+	return m, nil
+}
+
+// bindEvent converts the arguments of an ABI event to the fields of the event
+// struct and the filter parameters of its indexed arguments.
+func bindEvent(event abi.Event) (tmplEvent, error) {
+	e := tmplEvent{Original: event, Normalized: abi.Capitalise(event.Name)}
+	for i, input := range event.Input {
+		if abi.Capitalise(input.Name) == "" {
+			return e, fmt.Errorf("argument %d has no name", i)
+		}
+		typ := bindType(input.Type)
+		if typ == "" {
+			return e, fmt.Errorf("unsupported type %v of argument %d", input.Type, i)
+		}
+		field := tmplArg{Name: abi.Capitalise(input.Name), Type: typ}
+		if input.Indexed {
+			// only the hash of dynamic values is stored in the topics
+			topic := typ
+			switch input.Type.T {
+			case abi.SliceTy:
+				field.Type, topic = "common.Hash", "common.Hash"
+			case abi.StringTy, abi.BytesTy:
+				if input.Type.Size < 0 {
+					field.Type = "common.Hash"
+				}
+			}
+			e.Indexed = append(e.Indexed, tmplArg{Name: paramName(input.Name, i), Type: topic})
+		}
+		e.Fields = append(e.Fields, field)
+	}
+	return e, nil
+}
+
+// bindType returns the Go type that holds values of the ABI type, or the
+// empty string if the type is not supported.
+func bindType(kind abi.Type) string {
+	switch kind.T {
+	case abi.IntTy, abi.UintTy:
+		prefix := "int"
+		if kind.T == abi.UintTy {
+			prefix = "uint"
+		}
+		switch {
+		case kind.Size <= 8:
+			return prefix + "8"
+		case kind.Size <= 16:
+			return prefix + "16"
+		case kind.Size <= 32:
+			return prefix + "32"
+		case kind.Size <= 64:
+			return prefix + "64"
+		}
+		return "*big.Int"
+	case abi.BoolTy:
+		return "bool"
+	case abi.AddressTy:
+		return "common.Address"
+	case abi.StringTy:
+		return "string"
+	case abi.BytesTy:
+		return "[]byte"
+	case abi.FixedBytesTy, abi.FunctionTy:
+		return fmt.Sprintf("[%d]byte", kind.Size)
+	case abi.SliceTy:
+		elem := bindType(*kind.Elem)
+		if elem == "" {
+			return ""
+		}
+		if kind.SliceSize < 0 {
+			return "[]" + elem
+		}
+		return fmt.Sprintf("[%d]%s", kind.SliceSize, elem)
+	}
+	return ""
+}
+
+// paramName returns a valid Go parameter name for the argument at index i.
+func paramName(name string, i int) string {
+	switch {
+	case name == "":
+		return fmt.Sprintf("arg%d", i)
+	case token.Lookup(name).IsKeyword(), reserved[name], isRetName(name):
+		return name + "_"
+	}
+	return name
+}
+
+// isRetName reports whether name clashes with the variables holding the
+// outputs of a call.
+func isRetName(name string) bool {
+	if !strings.HasPrefix(name, "ret") {
+		return false
+	}
+	_, err := strconv.Atoi(name[3:])
+	return err == nil
+}
+
+func sortedMethods(methods map[string]abi.Method) []string {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedEvents(events map[string]abi.Event) []string {
+	names := make([]string, 0, len(events))
+	for name := range events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
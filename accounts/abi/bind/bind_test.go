@@ -0,0 +1,66 @@
+package bind
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const tokenABI = `[
+	{ "type" : "constructor", "inputs" : [ { "name" : "supply", "type" : "uint256" }, { "name" : "name", "type" : "string" } ] },
+	{ "type" : "function", "name" : "balanceOf", "constant" : true, "inputs" : [ { "name" : "owner", "type" : "address" } ], "outputs" : [ { "name" : "", "type" : "uint256" } ] },
+	{ "type" : "function", "name" : "info", "constant" : true, "inputs" : [], "outputs" : [ { "name" : "name", "type" : "string" }, { "name" : "", "type" : "uint8" } ] },
+	{ "type" : "function", "name" : "transfer", "constant" : false, "inputs" : [ { "name" : "_to", "type" : "address" }, { "name" : "type", "type" : "uint64" }, { "name" : "", "type" : "bytes32[2]" } ], "outputs" : [ { "name" : "", "type" : "bool" } ] },
+	{ "type" : "event", "name" : "Transfer", "inputs" : [ { "name" : "from", "type" : "address", "indexed" : true }, { "name" : "memo", "type" : "string", "indexed" : true }, { "name" : "value", "type" : "uint256" } ] }
+]`
+
+func TestBind(t *testing.T) {
+	code, err := Bind("token", tokenABI, "0x6060", "tokens")
+	if err != nil {
+		t.Fatalf("failed to generate binding: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "token.go", code, 0); err != nil {
+		t.Fatalf("generated code doesn't parse: %v\n%s", err, code)
+	}
+	want := []string{
+		"package tokens",
+		"const TokenBin = `0x6060`",
+		"func DeployToken(auth *bind.TransactOpts, backend bind.ContractBackend, supply *big.Int, name string) (common.Address, *types.Transaction, *Token, error)",
+		"func NewToken(address common.Address, backend bind.ContractBackend) (*Token, error)",
+		"func (_Token *Token) BalanceOf(opts *bind.CallOpts, owner common.Address) (*big.Int, error)",
+		"func (_Token *Token) Info(opts *bind.CallOpts) (string, uint8, error)",
+		"func (_Token *Token) Transfer(opts *bind.TransactOpts, _to common.Address, type_ uint64, arg2 [2][32]byte) (*types.Transaction, error)",
+		"func (_Token *Token) FilterTransfer(opts *bind.FilterOpts, from []common.Address, memo []string) ([]*TokenTransfer, error)",
+		"func (_Token *Token) WatchTransfer(sink chan<- *TokenTransfer, from []common.Address, memo []string) (func(), error)",
+		"Memo  common.Hash",
+	}
+	for _, s := range want {
+		if !strings.Contains(code, s) {
+			t.Errorf("generated code lacks %q", s)
+		}
+	}
+}
+
+func TestBindNoBytecode(t *testing.T) {
+	code, err := Bind("Token", tokenABI, "", "tokens")
+	if err != nil {
+		t.Fatalf("failed to generate binding: %v", err)
+	}
+	if strings.Contains(code, "DeployToken") || strings.Contains(code, "TokenBin") {
+		t.Errorf("deploy function generated without bytecode")
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`[{ "type" : "function", "name" : "f", "inputs" : [ { "name" : "a", "type" : "real" } ] }]`,
+		`[{ "type" : "event", "name" : "E", "inputs" : [ { "name" : "", "type" : "uint256" } ] }]`,
+	}
+	for i, abi := range tests {
+		if _, err := Bind("Test", abi, "", "test"); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package bind
+
+import "github.com/ethereum/go-ethereum/accounts/abi"
+
+// tmplData is the data structure required to fill the binding template.
+type tmplData struct {
+	Package     string       // Name of the package to place the generated file in
+	Type        string       // Type name of the main contract binding
+	InputABI    string       // JSON ABI used as the input to generate the binding from
+	InputBin    string       // Optional EVM bytecode used to generate a deploy function
+	Constructor tmplMethod   // Contract constructor for deploy parametrization
+	Calls       []tmplMethod // Contract calls that only read state data
+	Transacts   []tmplMethod // Contract calls that write state data
+	Events      []tmplEvent  // Contract events accessors
+}
+
+// tmplMethod is a wrapper around an abi.Method that contains a few preprocessed
+// and cached data fields.
+type tmplMethod struct {
+	Original   abi.Method // Original method as parsed by the abi package
+	Normalized string     // Normalized Go name of the method
+	Inputs     []tmplArg  // Go parameters of the method inputs
+	Outputs    []tmplArg  // Go types of the method outputs
+}
+
+// tmplEvent is a wrapper around an abi.Event that contains a few preprocessed
+// and cached data fields.
+type tmplEvent struct {
+	Original   abi.Event // Original event as parsed by the abi package
+	Normalized string    // Normalized Go name of the event
+	Fields     []tmplArg // Fields of the event struct
+	Indexed    []tmplArg // Filter parameters of the indexed arguments
+}
+
+// tmplArg is a single Go parameter or struct field.
+type tmplArg struct {
+	Name string
+	Type string
+}
+
+// tmplSource is the Go source template used to generate a contract binding.
+const tmplSource = `// This file is an automatically generated Go binding. Do not modify as any
+// change will likely be lost upon the next re-generation!
+
+package {{.Package}}
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = big.NewInt
+	_ = common.Big1
+	_ = (*state.Log)(nil)
+	_ = (*types.Transaction)(nil)
+)
+
+// {{.Type}}ABI is the input ABI used to generate the binding from.
+const {{.Type}}ABI = {{printf "%q" .InputABI}}
+{{if .InputBin}}
+// {{.Type}}Bin is the compiled bytecode used for deploying new contracts.
+const {{.Type}}Bin = ` + "`" + `0x{{.InputBin}}` + "`" + `
+
+// Deploy{{.Type}} deploys a new contract, binding an instance of {{.Type}} to it.
+func Deploy{{.Type}}(auth *bind.TransactOpts, backend bind.ContractBackend{{range .Constructor.Inputs}}, {{.Name}} {{.Type}}{{end}}) (common.Address, *types.Transaction, *{{.Type}}, error) {
+	parsed, err := abi.JSON(strings.NewReader({{.Type}}ABI))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex({{.Type}}Bin), backend{{range .Constructor.Inputs}}, {{.Name}}{{end}})
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &{{.Type}}{contract: contract}, nil
+}
+{{end}}
+// {{.Type}} is a Go binding around an Ethereum contract.
+type {{.Type}} struct {
+	contract *bind.BoundContract
+}
+
+// New{{.Type}} creates a new instance of {{.Type}}, bound to a specific deployed contract.
+func New{{.Type}}(address common.Address, backend bind.ContractBackend) (*{{.Type}}, error) {
+	parsed, err := abi.JSON(strings.NewReader({{.Type}}ABI))
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Type}}{contract: bind.NewBoundContract(address, parsed, backend, backend, backend)}, nil
+}
+
+// Address returns the address of the contract.
+func (_{{.Type}} *{{.Type}}) Address() common.Address {
+	return _{{.Type}}.contract.Address()
+}
+{{range .Calls}}
+// {{.Normalized}} is a free data retrieval call binding the contract method 0x{{printf "%x" .Original.Id}}.
+//
+// Signature: {{.Original}}
+func (_{{$.Type}} *{{$.Type}}) {{.Normalized}}(opts *bind.CallOpts{{range .Inputs}}, {{.Name}} {{.Type}}{{end}}) ({{range .Outputs}}{{.Type}}, {{end}}error) {
+	{{range $i, $out := .Outputs}}ret{{$i}} := new({{$out.Type}})
+	{{end}}{{if eq (len .Outputs) 1}}err := _{{$.Type}}.contract.Call(opts, ret0, "{{.Original.Name}}"{{range .Inputs}}, {{.Name}}{{end}}){{else}}out := []interface{}{ {{range $i, $out := .Outputs}}ret{{$i}}, {{end}} }
+	err := _{{$.Type}}.contract.Call(opts, &out, "{{.Original.Name}}"{{range .Inputs}}, {{.Name}}{{end}}){{end}}
+	return {{range $i, $out := .Outputs}}*ret{{$i}}, {{end}}err
+}
+{{end}}{{range .Transacts}}
+// {{.Normalized}} is a paid mutator transaction binding the contract method 0x{{printf "%x" .Original.Id}}.
+//
+// Signature: {{.Original}}
+func (_{{$.Type}} *{{$.Type}}) {{.Normalized}}(opts *bind.TransactOpts{{range .Inputs}}, {{.Name}} {{.Type}}{{end}}) (*types.Transaction, error) {
+	return _{{$.Type}}.contract.Transact(opts, "{{.Original.Name}}"{{range .Inputs}}, {{.Name}}{{end}})
+}
+{{end}}{{range .Events}}
+// {{$.Type}}{{.Normalized}} represents a {{.Original.Name}} event raised by the {{$.Type}} contract.
+type {{$.Type}}{{.Normalized}} struct {
+	{{range .Fields}}{{.Name}} {{.Type}}
+	{{end}}Raw *state.Log // Log the event was decoded from
+}
+
+// Filter{{.Normalized}} retrieves the past {{.Original.Name}} events within the block range,
+// optionally restricted to the given values of the indexed arguments.
+//
+// Signature: {{.Original}}
+func (_{{$.Type}} *{{$.Type}}) Filter{{.Normalized}}(opts *bind.FilterOpts{{range .Indexed}}, {{.Name}} []{{.Type}}{{end}}) ([]*{{$.Type}}{{.Normalized}}, error) {
+	{{range .Indexed}}var {{.Name}}Rule []interface{}
+	for _, {{.Name}}Item := range {{.Name}} {
+		{{.Name}}Rule = append({{.Name}}Rule, {{.Name}}Item)
+	}
+	{{end}}
+	logs, err := _{{$.Type}}.contract.FilterLogs(opts, "{{.Original.Name}}"{{range .Indexed}}, {{.Name}}Rule{{end}})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*{{$.Type}}{{.Normalized}}, 0, len(logs))
+	for _, log := range logs {
+		event := new({{$.Type}}{{.Normalized}})
+		if err := _{{$.Type}}.contract.UnpackLog(event, "{{.Original.Name}}", log); err != nil {
+			return nil, err
+		}
+		event.Raw = log
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Watch{{.Normalized}} delivers new {{.Original.Name}} events to sink until the returned
+// function is called, optionally restricted to the given values of the indexed arguments.
+//
+// Signature: {{.Original}}
+func (_{{$.Type}} *{{$.Type}}) Watch{{.Normalized}}(sink chan<- *{{$.Type}}{{.Normalized}}{{range .Indexed}}, {{.Name}} []{{.Type}}{{end}}) (func(), error) {
+	{{range .Indexed}}var {{.Name}}Rule []interface{}
+	for _, {{.Name}}Item := range {{.Name}} {
+		{{.Name}}Rule = append({{.Name}}Rule, {{.Name}}Item)
+	}
+	{{end}}
+	return _{{$.Type}}.contract.WatchLogs(sink, "{{.Original.Name}}"{{range .Indexed}}, {{.Name}}Rule{{end}})
+}
+{{end}}`
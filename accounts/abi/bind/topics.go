@@ -0,0 +1,66 @@
+package bind
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// makeTopics converts a filter query argument list into a filter topic set.
+func makeTopics(query [][]interface{}) ([][]common.Hash, error) {
+	topics := make([][]common.Hash, len(query))
+	for i, filter := range query {
+		for _, rule := range filter {
+			topic, err := makeTopic(rule)
+			if err != nil {
+				return nil, fmt.Errorf("indexed argument %d: %v", i, err)
+			}
+			topics[i] = append(topics[i], topic)
+		}
+	}
+	return topics, nil
+}
+
+// makeTopic encodes a single value the way it is stored in the log topics.
+// Value types are ABI encoded, strings and byte slices are hashed.
+func makeTopic(rule interface{}) (common.Hash, error) {
+	var topic common.Hash
+
+	switch rule := rule.(type) {
+	case common.Hash:
+		topic = rule
+	case common.Address:
+		topic = common.BytesToHash(rule[:])
+	case *big.Int:
+		copy(topic[:], abi.S256(rule))
+	case bool:
+		if rule {
+			topic[len(topic)-1] = 1
+		}
+	case string:
+		copy(topic[:], crypto.Sha3([]byte(rule)))
+	case []byte:
+		copy(topic[:], crypto.Sha3(rule))
+	default:
+		val := reflect.ValueOf(rule)
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			copy(topic[:], abi.S2S256(val.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			copy(topic[:], abi.U2U256(val.Uint()))
+		case reflect.Array:
+			if val.Type().Elem().Kind() != reflect.Uint8 || val.Len() > len(topic) {
+				return topic, fmt.Errorf("unsupported topic type %T", rule)
+			}
+			// fixed size byte arrays are right padded like bytesN
+			reflect.Copy(reflect.ValueOf(topic[:]), val)
+		default:
+			return topic, fmt.Errorf("unsupported topic type %T", rule)
+		}
+	}
+	return topic, nil
+}
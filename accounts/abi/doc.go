@@ -7,4 +7,7 @@
 // as unsigned slice to signed slice. Bit size type casting is also
 // handled. ints with a bit size of 32 will be properly cast to int256,
 // etc.
+//
+// Return values of methods and the topics and data of event logs can be
+// decoded into Go values using ABI.Unpack and ABI.UnpackLog.
 package abi
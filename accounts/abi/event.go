@@ -0,0 +1,95 @@
+package abi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Event is an event potentially triggered by the EVM's LOG mechanism.
+// Indexed arguments are stored in the topics of the log, all others
+// are ABI encoded in its data. Unless the event is Anonymous, the first
+// topic holds the event Id.
+type Event struct {
+	Name      string
+	Anonymous bool
+	Input     []Argument
+}
+
+// Returns the events string signature according to the ABI spec.
+//
+// Example
+//
+//     event Transfer(address indexed from, address indexed to, uint value)    =    "Transfer(address,address,uint256)"
+func (e Event) String() string {
+	types := make([]string, len(e.Input))
+	for i, input := range e.Input {
+		types[i] = input.Type.String()
+	}
+	return e.Name + "(" + strings.Join(types, ",") + ")"
+}
+
+// Id returns the hash of the event signature, which is used as the
+// first topic of the logs of non-anonymous events.
+func (e Event) Id() common.Hash {
+	return common.BytesToHash(crypto.Sha3([]byte(e.String())))
+}
+
+// UnpackLog decodes the arguments of the named event from the topics and
+// data of log into v, following the same rules as Unpack. Indexed
+// arguments of dynamic types (strings, bytes and arrays) are only
+// stored as the hash of their value and are decoded as common.Hash.
+func (abi ABI) UnpackLog(v interface{}, name string, log *state.Log) error {
+	event, exist := abi.Events[name]
+	if !exist {
+		return fmt.Errorf("event '%s' not found", name)
+	}
+	topics := log.Topics
+	if !event.Anonymous {
+		if len(topics) == 0 || topics[0] != event.Id() {
+			return fmt.Errorf("abi: log is not a '%s' event", name)
+		}
+		topics = topics[1:]
+	}
+
+	var nonIndexed []Argument
+	for _, input := range event.Input {
+		if !input.Indexed {
+			nonIndexed = append(nonIndexed, input)
+		}
+	}
+	var data []reflect.Value
+	if len(nonIndexed) > 0 {
+		var err error
+		if data, err = unpackTuple(nonIndexed, log.Data); err != nil {
+			return err
+		}
+	}
+
+	values := make([]reflect.Value, 0, len(event.Input))
+	for _, input := range event.Input {
+		if !input.Indexed {
+			values, data = append(values, data[0]), data[1:]
+			continue
+		}
+		if len(topics) == 0 {
+			return fmt.Errorf("abi: missing topic for indexed argument `%s`", input.Name)
+		}
+		topic := topics[0]
+		topics = topics[1:]
+		if input.Type.isDynamic() || input.Type.T == SliceTy {
+			values = append(values, reflect.ValueOf(topic))
+			continue
+		}
+		value, err := input.Type.unpack(topic[:], 0)
+		if err != nil {
+			return fmt.Errorf("`%s` %v", input.Name, err)
+		}
+		values = append(values, value)
+	}
+	return assign(v, event.Input, values)
+}
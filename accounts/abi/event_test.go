@@ -0,0 +1,124 @@
+package abi
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const eventABI = `[
+	{ "type" : "function", "name" : "transfer", "constant" : false, "inputs" : [ { "name" : "to", "type" : "address" }, { "name" : "value", "type" : "uint256" } ] },
+	{ "type" : "event", "name" : "Transfer", "inputs" : [ { "name" : "from", "type" : "address", "indexed" : true }, { "name" : "to", "type" : "address", "indexed" : true }, { "name" : "value", "type" : "uint256" } ] },
+	{ "type" : "event", "name" : "Named", "inputs" : [ { "name" : "name", "type" : "string", "indexed" : true }, { "name" : "memo", "type" : "string" }, { "name" : "flags", "type" : "bool[2]" } ] },
+	{ "type" : "event", "name" : "Anon", "anonymous" : true, "inputs" : [ { "name" : "id", "type" : "uint64", "indexed" : true } ] }
+]`
+
+func TestEventJSON(t *testing.T) {
+	abi, err := JSON(strings.NewReader(eventABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abi.Methods) != 1 || len(abi.Events) != 3 {
+		t.Fatalf("wrong number of methods/events: %d/%d", len(abi.Methods), len(abi.Events))
+	}
+	if m := abi.Methods["transfer"]; len(m.Input) != 2 || m.String() != "transfer(address,uint256)" {
+		t.Errorf("method mismatch: %v", m)
+	}
+	transfer := abi.Events["Transfer"]
+	if transfer.String() != "Transfer(address,address,uint256)" {
+		t.Errorf("signature mismatch: %s", transfer)
+	}
+	if !transfer.Input[0].Indexed || !transfer.Input[1].Indexed || transfer.Input[2].Indexed {
+		t.Errorf("indexed flags mismatch: %v", transfer.Input)
+	}
+	// the well known ERC20 Transfer event id
+	id := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	if transfer.Id() != id {
+		t.Errorf("event id mismatch: have %x, want %x", transfer.Id(), id)
+	}
+	if !abi.Events["Anon"].Anonymous {
+		t.Errorf("anonymous flag not parsed")
+	}
+}
+
+func TestUnpackLog(t *testing.T) {
+	abi, err := JSON(strings.NewReader(eventABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	to := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	log := &state.Log{
+		Topics: []common.Hash{abi.Events["Transfer"].Id(), common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:   common.LeftPadBytes([]byte{0x03, 0xe8}, 32),
+	}
+	var transfer struct {
+		From  common.Address
+		To    common.Address
+		Value *big.Int
+	}
+	if err := abi.UnpackLog(&transfer, "Transfer", log); err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+	if transfer.From != from || transfer.To != to || transfer.Value.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("event mismatch: %+v", transfer)
+	}
+
+	// logs of other events are rejected
+	log.Topics[0] = abi.Events["Named"].Id()
+	if err := abi.UnpackLog(&transfer, "Transfer", log); err == nil {
+		t.Errorf("expected error for wrong event id")
+	}
+	log.Topics = log.Topics[:2]
+	log.Topics[0] = abi.Events["Transfer"].Id()
+	if err := abi.UnpackLog(&transfer, "Transfer", log); err == nil {
+		t.Errorf("expected error for missing topic")
+	}
+}
+
+func TestUnpackLogDynamic(t *testing.T) {
+	abi, err := JSON(strings.NewReader(eventABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the non-indexed arguments are encoded like method arguments
+	data, err := packTuple([]Type{abi.Events["Named"].Input[1].Type, abi.Events["Named"].Input[2].Type},
+		[]reflect.Value{reflect.ValueOf("a memo"), reflect.ValueOf([]bool{true, false})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &state.Log{
+		Topics: []common.Hash{abi.Events["Named"].Id(), common.BytesToHash(crypto.Sha3([]byte("name")))},
+		Data:   data,
+	}
+	var named struct {
+		Name  []byte
+		Memo  string
+		Flags [2]bool
+	}
+	if err := abi.UnpackLog(&named, "Named", log); err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+	if !bytes.Equal(named.Name, crypto.Sha3([]byte("name"))) {
+		t.Errorf("indexed string hash mismatch: %x", named.Name)
+	}
+	if named.Memo != "a memo" || named.Flags != [2]bool{true, false} {
+		t.Errorf("event mismatch: %+v", named)
+	}
+
+	var id uint64
+	log = &state.Log{Topics: []common.Hash{common.BigToHash(big.NewInt(7))}}
+	if err := abi.UnpackLog(&id, "Anon", log); err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+	if id != 7 {
+		t.Errorf("anonymous event mismatch: have %d, want 7", id)
+	}
+}
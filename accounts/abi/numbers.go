@@ -9,8 +9,49 @@ import (
 
 var big_t = reflect.TypeOf(&big.Int{})
 var ubig_t = reflect.TypeOf(&big.Int{})
+var bool_t = reflect.TypeOf(false)
+var string_t = reflect.TypeOf("")
+var address_t = reflect.TypeOf(common.Address{})
 var byte_t = reflect.TypeOf(byte(0))
 var byte_ts = reflect.TypeOf([]byte(nil))
+var function_t = reflect.TypeOf([24]byte{})
+
+// fixed_bytes_t holds the types of bytes1 to bytes32, indexed by their size.
+var fixed_bytes_t = [...]reflect.Type{
+	nil,
+	reflect.TypeOf([1]byte{}),
+	reflect.TypeOf([2]byte{}),
+	reflect.TypeOf([3]byte{}),
+	reflect.TypeOf([4]byte{}),
+	reflect.TypeOf([5]byte{}),
+	reflect.TypeOf([6]byte{}),
+	reflect.TypeOf([7]byte{}),
+	reflect.TypeOf([8]byte{}),
+	reflect.TypeOf([9]byte{}),
+	reflect.TypeOf([10]byte{}),
+	reflect.TypeOf([11]byte{}),
+	reflect.TypeOf([12]byte{}),
+	reflect.TypeOf([13]byte{}),
+	reflect.TypeOf([14]byte{}),
+	reflect.TypeOf([15]byte{}),
+	reflect.TypeOf([16]byte{}),
+	reflect.TypeOf([17]byte{}),
+	reflect.TypeOf([18]byte{}),
+	reflect.TypeOf([19]byte{}),
+	reflect.TypeOf([20]byte{}),
+	reflect.TypeOf([21]byte{}),
+	reflect.TypeOf([22]byte{}),
+	reflect.TypeOf([23]byte{}),
+	reflect.TypeOf([24]byte{}),
+	reflect.TypeOf([25]byte{}),
+	reflect.TypeOf([26]byte{}),
+	reflect.TypeOf([27]byte{}),
+	reflect.TypeOf([28]byte{}),
+	reflect.TypeOf([29]byte{}),
+	reflect.TypeOf([30]byte{}),
+	reflect.TypeOf([31]byte{}),
+	reflect.TypeOf([32]byte{}),
+}
 var uint_t = reflect.TypeOf(uint(0))
 var uint8_t = reflect.TypeOf(uint8(0))
 var uint16_t = reflect.TypeOf(uint16(0))
@@ -38,28 +79,17 @@ var big_ts = reflect.TypeOf([]*big.Int(nil))
 
 // U256 will ensure unsigned 256bit on big nums
 func U256(n *big.Int) []byte {
-	return common.LeftPadBytes(common.U256(n).Bytes(), 32)
+	return common.LeftPadBytes(common.U256(new(big.Int).Set(n)).Bytes(), 32)
 }
 
+// S256 encodes the given number as a signed 256bit two's complement
+// integer. Negative numbers are sign extended.
 func S256(n *big.Int) []byte {
-	sint := common.S256(n)
-	ret := common.LeftPadBytes(sint.Bytes(), 32)
-	if sint.Cmp(common.Big0) < 0 {
-		for i, b := range ret {
-			if b == 0 {
-				ret[i] = 1
-				continue
-			}
-			break
-		}
-	}
-
-	return ret
+	return U256(common.S256(n))
 }
 
-// S256 will ensure signed 256bit on big nums
 func U2U256(n uint64) []byte {
-	return U256(big.NewInt(int64(n)))
+	return U256(new(big.Int).SetUint64(n))
 }
 
 func S2S256(n int64) []byte {
@@ -70,14 +100,11 @@ func S2S256(n int64) []byte {
 func packNum(value reflect.Value, to byte) []byte {
 	switch kind := value.Kind(); kind {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if to == UintTy {
-			return U2U256(value.Uint())
-		} else {
-			return S2S256(int64(value.Uint()))
-		}
+		// unsigned values have the same representation for both
+		return U2U256(value.Uint())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		if to == UintTy {
-			return U2U256(uint64(value.Int()))
+			return U256(big.NewInt(value.Int()))
 		} else {
 			return S2S256(value.Int())
 		}
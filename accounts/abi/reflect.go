@@ -0,0 +1,134 @@
+package abi
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// assign stores the decoded values of args in v, which must be a
+// non-nil pointer. See ABI.Unpack for the supported destinations.
+func assign(v interface{}, args []Argument, values []reflect.Value) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("abi: Unpack(non-pointer %T)", v)
+	}
+	dst := rv.Elem()
+
+	switch {
+	case dst.Kind() == reflect.Struct && dst.Type() != big_t.Elem():
+		for i, arg := range args {
+			name := Capitalise(arg.Name)
+			field := dst.FieldByName(name)
+			if !field.IsValid() || !field.CanSet() {
+				return fmt.Errorf("abi: field %s can't be found in the given value", name)
+			}
+			if err := set(field, values[i]); err != nil {
+				return fmt.Errorf("abi: field %s: %v", name, err)
+			}
+		}
+		return nil
+	case dst.Type() == reflect.TypeOf([]interface{}(nil)) && isPointerList(dst, len(values)):
+		for i := range values {
+			if err := set(dst.Index(i).Elem().Elem(), values[i]); err != nil {
+				return fmt.Errorf("abi: value %d: %v", i, err)
+			}
+		}
+		return nil
+	case dst.Type() == reflect.TypeOf([]interface{}(nil)):
+		list := make([]interface{}, len(values))
+		for i := range values {
+			list[i] = values[i].Interface()
+		}
+		dst.Set(reflect.ValueOf(list))
+		return nil
+	case len(values) == 1:
+		return set(dst, values[0])
+	}
+	return fmt.Errorf("abi: cannot unmarshal %d values into %v, need a struct or []interface{}", len(values), dst.Type())
+}
+
+// isPointerList reports whether list holds n non-nil pointers to store
+// the decoded values in.
+func isPointerList(list reflect.Value, n int) bool {
+	if list.Len() != n || n == 0 {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		elem := list.Index(i).Elem()
+		if elem.Kind() != reflect.Ptr || elem.IsNil() {
+			return false
+		}
+	}
+	return true
+}
+
+// set assigns the decoded value src to dst, converting between the
+// Go types that can hold the value.
+func set(dst, src reflect.Value) error {
+	dtype := dst.Type()
+	switch {
+	case dst.Kind() == reflect.Interface || src.Type().AssignableTo(dtype):
+		dst.Set(src)
+	case src.Type() == big_t:
+		return setInt(dst, src.Interface().(*big.Int))
+	case src.Kind() == reflect.Array && src.Type().Elem() == byte_t && dtype == byte_ts:
+		// fixed size byte sequences such as addresses and bytesN
+		data, _, _ := byteSequence(src)
+		dst.SetBytes(data)
+	case src.Kind() == reflect.Slice && src.Type() != byte_ts && dst.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(dtype, src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if err := set(slice.Index(i), src.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+	case src.Kind() == reflect.Slice && src.Type() != byte_ts && dst.Kind() == reflect.Array:
+		if src.Len() != dst.Len() {
+			return fmt.Errorf("cannot unmarshal %d elements into %v", src.Len(), dtype)
+		}
+		for i := 0; i < src.Len(); i++ {
+			if err := set(dst.Index(i), src.Index(i)); err != nil {
+				return err
+			}
+		}
+	case src.Type().ConvertibleTo(dtype):
+		dst.Set(src.Convert(dtype))
+	default:
+		return fmt.Errorf("cannot unmarshal %v into %v", src.Type(), dtype)
+	}
+	return nil
+}
+
+// setInt stores n in the integer dst, failing if it doesn't fit.
+func setInt(dst reflect.Value, n *big.Int) error {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.BitLen() > 63 || dst.OverflowInt(n.Int64()) {
+			return fmt.Errorf("value %v overflows %v", n, dst.Type())
+		}
+		dst.SetInt(n.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n.Sign() < 0 || n.BitLen() > 64 || dst.OverflowUint(n.Uint64()) {
+			return fmt.Errorf("value %v overflows %v", n, dst.Type())
+		}
+		dst.SetUint(n.Uint64())
+	default:
+		return fmt.Errorf("cannot unmarshal %v into %v", big_t, dst.Type())
+	}
+	return nil
+}
+
+// Capitalise makes the first character of an argument name upper case
+// so it matches an exported struct field. Leading underscores, often
+// used for Solidity parameter names, are dropped. Generated bindings use
+// it to name the fields values are unpacked into.
+func Capitalise(name string) string {
+	name = strings.TrimLeft(name, "_")
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -2,6 +2,7 @@ package abi
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -16,14 +17,22 @@ const (
 	SliceTy
 	AddressTy
 	RealTy
+	StringTy
+	BytesTy
+	FixedBytesTy
+	FunctionTy
 )
 
 // Type is the reflection of the supported argument type
 type Type struct {
-	Kind       reflect.Kind
-	Type       reflect.Type
-	Size       int
-	T          byte   // Our own type checking
+	Kind reflect.Kind
+	Type reflect.Type // Go type values of this type are unpacked into
+	Size int
+	T    byte // Our own type checking
+
+	Elem      *Type // element type of arrays
+	SliceSize int   // length of fixed size arrays, -1 for dynamic arrays
+
 	stringKind string // holds the unparsed string for deriving signatures
 }
 
@@ -31,92 +40,123 @@ type Type struct {
 //
 // Strings can be in the format of:
 //
-// 	Input  = Type [ "[" [ Number ] "]" ] Name .
-// 	Type   = [ "u" ] "int" [ Number ] .
+// 	Input  = Type { "[" [ Number ] "]" } Name .
+// 	Type   = [ "u" ] "int" [ Number ] | "bytes" [ Number ] | ... .
+//
+// Arrays can be nested, the last dimension is the outermost one, i.e.
+// uint[2][] is a dynamic array of uint[2] values.
 //
 // Examples:
 //
 //      string     int       uint       real
 //      string32   int8      uint8      uint[]
 //      address    int256    uint256    real[2]
+//      bytes      bytes32   function   uint[2][]
 func NewType(t string) (typ Type, err error) {
-	// 1. full string 2. type 3. (opt.) is slice 4. (opt.) size
-	freg, err := regexp.Compile("([a-zA-Z0-9]+)(\\[([0-9]*)?\\])?")
+	// 1. full string 2. element type 3. (opt.) size
+	freg, err := regexp.Compile("^(.+)\\[([0-9]*)\\]$")
 	if err != nil {
 		return Type{}, err
 	}
-	res := freg.FindAllStringSubmatch(t, -1)[0]
-	var (
-		isslice bool
-		size    int
-	)
-	switch {
-	case res[3] != "":
-		// err is ignored. Already checked for number through the regexp
-		size, _ = strconv.Atoi(res[3])
-		isslice = true
-	case res[2] != "":
-		isslice = true
-		size = -1
-	case res[0] == "":
-		return Type{}, fmt.Errorf("type parse error for `%s`", t)
+	if res := freg.FindStringSubmatch(t); res != nil {
+		size := -1
+		if res[2] != "" {
+			// err is ignored. Already checked for number through the regexp
+			size, _ = strconv.Atoi(res[2])
+		}
+		elem, err := NewType(res[1])
+		if err != nil {
+			return Type{}, err
+		}
+		if elem.Kind == reflect.Invalid {
+			return Type{}, fmt.Errorf("unsupported arg slice type: %s", t)
+		}
+		typ.Kind = reflect.Slice
+		typ.Type = reflect.SliceOf(elem.Type)
+		typ.Size = size
+		typ.T = SliceTy
+		typ.Elem = &elem
+		typ.SliceSize = size
+		typ.stringKind = elem.stringKind + t[len(res[1]):]
+		return typ, nil
 	}
 
-	treg, err := regexp.Compile("([a-zA-Z]+)([0-9]*)?")
+	treg, err := regexp.Compile("^([a-zA-Z]+)([0-9]*)$")
 	if err != nil {
 		return Type{}, err
 	}
-
-	parsedType := treg.FindAllStringSubmatch(res[1], -1)[0]
+	parsedType := treg.FindStringSubmatch(t)
+	if parsedType == nil {
+		return Type{}, fmt.Errorf("type parse error for `%s`", t)
+	}
 	vsize, _ := strconv.Atoi(parsedType[2])
 	vtype := parsedType[1]
 	// substitute canonical representation
-	if vsize == 0 && (vtype == "int" || vtype == "uint") {
+	switch {
+	case parsedType[2] == "" && (vtype == "int" || vtype == "uint"):
 		vsize = 256
 		t += "256"
+	case vtype == "byte":
+		vtype, vsize, parsedType[2] = "bytes", 1, "1"
+		t = "bytes1"
 	}
 
-	if isslice {
-		typ.Kind = reflect.Slice
-		typ.Size = size
-		switch vtype {
-		case "int":
-			typ.Type = big_ts
-		case "uint":
-			typ.Type = ubig_ts
-		default:
-			return Type{}, fmt.Errorf("unsupported arg slice type: %s", t)
+	switch vtype {
+	case "int", "uint":
+		if vsize < 8 || vsize > 256 || vsize%8 != 0 {
+			return Type{}, fmt.Errorf("unsupported arg type: %s", t)
 		}
-	} else {
-		switch vtype {
-		case "int":
-			typ.Kind = reflect.Ptr
-			typ.Type = big_t
-			typ.Size = 256
-			typ.T = IntTy
-		case "uint":
-			typ.Kind = reflect.Ptr
+		typ.Kind = reflect.Ptr
+		typ.Type = big_t
+		typ.Size = vsize
+		typ.T = IntTy
+		if vtype == "uint" {
 			typ.Type = ubig_t
-			typ.Size = 256
 			typ.T = UintTy
-		case "bool":
-			typ.Kind = reflect.Bool
-		case "real": // TODO
-			typ.Kind = reflect.Invalid
-		case "address":
+		}
+	case "bool":
+		typ.Kind = reflect.Bool
+		typ.Type = bool_t
+		typ.T = BoolTy
+	case "real": // TODO
+		typ.Kind = reflect.Invalid
+		typ.T = RealTy
+	case "address":
+		typ.Kind = reflect.Array
+		typ.Type = address_t
+		typ.Size = 20
+		typ.T = AddressTy
+	case "string":
+		typ.Kind = reflect.String
+		typ.Type = string_t
+		typ.Size = -1
+		if vsize > 0 {
+			typ.Size = 32
+		}
+		typ.T = StringTy
+	case "bytes":
+		if parsedType[2] == "" {
 			typ.Kind = reflect.Slice
 			typ.Type = byte_ts
-			typ.Size = 20
-			typ.T = AddressTy
-		case "string":
-			typ.Kind = reflect.String
 			typ.Size = -1
-			if vsize > 0 {
-				typ.Size = 32
-			}
-		default:
+			typ.T = BytesTy
+			break
+		}
+		if vsize < 1 || vsize > 32 {
 			return Type{}, fmt.Errorf("unsupported arg type: %s", t)
 		}
+		typ.Kind = reflect.Array
+		typ.Type = fixed_bytes_t[vsize]
+		typ.Size = vsize
+		typ.T = FixedBytesTy
+	case "function":
+		// an address followed by a function selector
+		typ.Kind = reflect.Array
+		typ.Type = function_t
+		typ.Size = 24
+		typ.T = FunctionTy
+	default:
+		return Type{}, fmt.Errorf("unsupported arg type: %s", t)
 	}
 	typ.stringKind = t
 
@@ -127,64 +167,321 @@ func (t Type) String() (out string) {
 	return t.stringKind
 }
 
+// isDynamic reports whether values of the type are encoded in the tail
+// of the argument list, referenced by an offset in the head.
+func (t Type) isDynamic() bool {
+	switch t.T {
+	case StringTy:
+		return t.Size < 0
+	case BytesTy:
+		return true
+	case SliceTy:
+		return t.SliceSize < 0 || t.Elem.isDynamic()
+	}
+	return false
+}
+
+// headSize returns the number of bytes a value of the type occupies in
+// the head of the argument list. Fixed size arrays of static types are
+// encoded in place.
+func (t Type) headSize() int {
+	if t.T == SliceTy && !t.isDynamic() {
+		return t.SliceSize * t.Elem.headSize()
+	}
+	return 32
+}
+
 // Test the given input parameter `v` and checks if it matches certain
 // criteria
 // * Big integers are checks for ptr types and if the given value is
 //   assignable
 // * Integer are checked for size
 // * Strings, addresses and bytes are checks for type and size
-func (t Type) pack(v interface{}) ([]byte, error) {
-	value := reflect.ValueOf(v)
-	switch kind := value.Kind(); kind {
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if t.Type != ubig_t {
-			return nil, fmt.Errorf("type mismatch: %s for %T", t.Type, v)
+//
+// Dynamic types are packed with their length prefix, the caller is
+// responsible for placing them in the tail of the argument list.
+func (t Type) pack(value reflect.Value) ([]byte, error) {
+	for value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil, fmt.Errorf("type mismatch: %s for nil", t)
+	}
+
+	switch t.T {
+	case IntTy, UintTy:
+		switch kind := value.Kind(); kind {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if err := t.checkRange(value); err != nil {
+				return nil, err
+			}
+			return packNum(value, t.T), nil
+		case reflect.Ptr:
+			// If the value is a ptr do a assign check (only used by
+			// big.Int for now)
+			if value.Type() != ubig_t {
+				return nil, fmt.Errorf("type mismatch: %s for %v", t, value.Type())
+			}
+			if err := t.checkRange(value); err != nil {
+				return nil, err
+			}
+			return packNum(value, t.T), nil
 		}
-		return packNum(value, t.T), nil
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if t.Type != ubig_t {
-			return nil, fmt.Errorf("type mismatch: %s for %T", t.Type, v)
+	case BoolTy:
+		if value.Kind() == reflect.Bool {
+			if value.Bool() {
+				return common.LeftPadBytes(common.Big1.Bytes(), 32), nil
+			}
+			return common.LeftPadBytes(common.Big0.Bytes(), 32), nil
 		}
-		return packNum(value, t.T), nil
-	case reflect.Ptr:
-		// If the value is a ptr do a assign check (only used by
-		// big.Int for now)
-		if t.Type == ubig_t && value.Type() != ubig_t {
-			return nil, fmt.Errorf("type mismatch: %s for %T", t.Type, v)
+	case AddressTy, FixedBytesTy, FunctionTy:
+		data, isArray, ok := byteSequence(value)
+		if !ok {
+			break
+		}
+		if len(data) > t.Size || (isArray && len(data) != t.Size) {
+			return nil, fmt.Errorf("%v out of bound. %d for %d", value.Kind(), len(data), t.Size)
+		}
+		if t.T == AddressTy {
+			return common.LeftPadBytes(data, 32), nil
+		}
+		return common.RightPadBytes(data, 32), nil
+	case StringTy, BytesTy:
+		data, _, ok := byteSequence(value)
+		if !ok {
+			break
 		}
-		return packNum(value, t.T), nil
-	case reflect.String:
-		if t.Size > -1 && value.Len() > t.Size {
-			return nil, fmt.Errorf("%v out of bound. %d for %d", value.Kind(), value.Len(), t.Size)
+		if !t.isDynamic() {
+			if len(data) > t.Size {
+				return nil, fmt.Errorf("%v out of bound. %d for %d", value.Kind(), len(data), t.Size)
+			}
+			return common.RightPadBytes(data, 32), nil
+		}
+		return packBytesSlice(data), nil
+	case SliceTy:
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			break
+		}
+		if t.SliceSize > -1 && value.Len() != t.SliceSize {
+			return nil, fmt.Errorf("%v out of bound. %d for %d", value.Kind(), value.Len(), t.SliceSize)
 		}
-		return []byte(common.LeftPadString(t.String(), 32)), nil
-	case reflect.Slice:
-		if t.Size > -1 && value.Len() > t.Size {
-			return nil, fmt.Errorf("%v out of bound. %d for %d", value.Kind(), value.Len(), t.Size)
+		types := make([]Type, value.Len())
+		values := make([]reflect.Value, value.Len())
+		for i := range types {
+			types[i], values[i] = *t.Elem, value.Index(i)
 		}
+		packed, err := packTuple(types, values)
+		if err != nil {
+			return nil, err
+		}
+		if t.SliceSize < 0 {
+			return append(packNum(reflect.ValueOf(value.Len()), UintTy), packed...), nil
+		}
+		return packed, nil
+	case RealTy:
+		return nil, fmt.Errorf("unsupported arg type: %s", t)
+	}
+	return nil, fmt.Errorf("type mismatch: %s for %v", t, value.Type())
+}
 
-		// Address is a special slice. The slice acts as one rather than a list of elements.
-		if t.T == AddressTy {
-			return common.LeftPadBytes(v.([]byte), 32), nil
+// checkRange verifies that the integer value fits into the bit size of
+// the type. Unsigned types don't take negative values.
+func (t Type) checkRange(value reflect.Value) error {
+	var n *big.Int
+	switch value.Kind() {
+	case reflect.Ptr:
+		n = value.Interface().(*big.Int)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = new(big.Int).SetUint64(value.Uint())
+	default:
+		n = big.NewInt(value.Int())
+	}
+	switch {
+	case t.T == UintTy && n.Sign() >= 0 && n.BitLen() <= t.Size:
+		return nil
+	case t.T == IntTy:
+		// -2^(size-1) <= n < 2^(size-1)
+		abs := new(big.Int).Abs(n)
+		if n.Sign() < 0 {
+			abs.Sub(abs, common.Big1)
 		}
+		if abs.BitLen() < t.Size {
+			return nil
+		}
+	}
+	return fmt.Errorf("%v out of bound for %s", n, t)
+}
 
-		// Signed / Unsigned check
-		if (t.T != IntTy && isSigned(value)) || (t.T == UintTy && isSigned(value)) {
-			return nil, fmt.Errorf("slice of incompatible types.")
+// byteSequence returns the content of byte slices, strings and byte
+// arrays such as common.Address.
+func byteSequence(value reflect.Value) (data []byte, isArray bool, ok bool) {
+	switch {
+	case value.Kind() == reflect.String:
+		return []byte(value.String()), false, true
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8:
+		return value.Bytes(), false, true
+	case value.Kind() == reflect.Array && value.Type().Elem().Kind() == reflect.Uint8:
+		data = make([]byte, value.Len())
+		for i := range data {
+			data[i] = byte(value.Index(i).Uint())
 		}
+		return data, true, true
+	}
+	return nil, false, false
+}
 
-		var packed []byte
-		for i := 0; i < value.Len(); i++ {
-			packed = append(packed, packNum(value.Index(i), t.T)...)
+// packBytesSlice packs the given bytes as a length prefixed, right
+// padded byte sequence.
+func packBytesSlice(data []byte) []byte {
+	packed := packNum(reflect.ValueOf(len(data)), UintTy)
+	return append(packed, common.RightPadBytes(data, (len(data)+31)/32*32)...)
+}
+
+// packTuple packs the given values according to types. Static values
+// are placed in the head, dynamic ones in the tail with their offset
+// stored in the head.
+func packTuple(types []Type, values []reflect.Value) ([]byte, error) {
+	var headLen int
+	for _, t := range types {
+		headLen += t.headSize()
+	}
+	var head, tail []byte
+	for i, t := range types {
+		packed, err := t.pack(values[i])
+		if err != nil {
+			return nil, err
 		}
-		return packed, nil
-	case reflect.Bool:
-		if value.Bool() {
-			return common.LeftPadBytes(common.Big1.Bytes(), 32), nil
+		if t.isDynamic() {
+			head = append(head, packNum(reflect.ValueOf(headLen+len(tail)), UintTy)...)
+			tail = append(tail, packed...)
 		} else {
-			return common.LeftPadBytes(common.Big0.Bytes(), 32), nil
+			head = append(head, packed...)
+		}
+	}
+	return append(head, tail...), nil
+}
+
+// unpack decodes the value of type t at the given offset in the head
+// of the encoded data. The value is returned as a t.Type.
+func (t Type) unpack(data []byte, offset int) (reflect.Value, error) {
+	if offset+32 > len(data) {
+		return reflect.Value{}, fmt.Errorf("abi: cannot unmarshal %s, insufficient data (%d bytes at offset %d)", t, len(data), offset)
+	}
+	word := data[offset : offset+32]
+	if t.isDynamic() {
+		start, err := readOffset(word, len(data))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return t.unpackTail(data[start:])
+	}
+
+	switch t.T {
+	case IntTy:
+		return reflect.ValueOf(common.S256(new(big.Int).SetBytes(word))), nil
+	case UintTy:
+		return reflect.ValueOf(new(big.Int).SetBytes(word)), nil
+	case BoolTy:
+		for _, b := range word[:31] {
+			if b != 0 {
+				return reflect.Value{}, fmt.Errorf("abi: improperly encoded boolean value")
+			}
+		}
+		switch word[31] {
+		case 0:
+			return reflect.ValueOf(false), nil
+		case 1:
+			return reflect.ValueOf(true), nil
+		}
+		return reflect.Value{}, fmt.Errorf("abi: improperly encoded boolean value")
+	case AddressTy:
+		return reflect.ValueOf(common.BytesToAddress(word)), nil
+	case FixedBytesTy, FunctionTy:
+		array := reflect.New(t.Type).Elem()
+		reflect.Copy(array, reflect.ValueOf(word[:t.Size]))
+		return array, nil
+	case StringTy:
+		end := len(word)
+		for end > 0 && word[end-1] == 0 {
+			end--
 		}
+		return reflect.ValueOf(string(word[:end])), nil
+	case SliceTy:
+		return t.unpackElements(t.SliceSize, data, offset)
 	}
+	return reflect.Value{}, fmt.Errorf("abi: cannot unmarshal %s", t)
+}
 
-	panic("unreached")
+// unpackTail decodes a dynamic value of type t from the start of data.
+func (t Type) unpackTail(data []byte) (reflect.Value, error) {
+	if t.T == SliceTy && t.SliceSize > -1 {
+		// fixed size array of dynamic elements, no length prefix
+		return t.unpackElements(t.SliceSize, data, 0)
+	}
+	if len(data) < 32 {
+		return reflect.Value{}, fmt.Errorf("abi: cannot unmarshal %s, insufficient data for length", t)
+	}
+	length, err := readOffset(data[:32], len(data))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	data = data[32:]
+
+	switch t.T {
+	case StringTy, BytesTy:
+		if length > len(data) {
+			return reflect.Value{}, fmt.Errorf("abi: cannot unmarshal %s, insufficient data (%d bytes for length %d)", t, len(data), length)
+		}
+		if t.T == StringTy {
+			return reflect.ValueOf(string(data[:length])), nil
+		}
+		return reflect.ValueOf(common.CopyBytes(data[:length])), nil
+	case SliceTy:
+		return t.unpackElements(length, data, 0)
+	}
+	return reflect.Value{}, fmt.Errorf("abi: cannot unmarshal %s", t)
+}
+
+// unpackElements decodes n array elements, starting at the given
+// offset in the head of data.
+func (t Type) unpackElements(n int, data []byte, offset int) (reflect.Value, error) {
+	if n > len(data)/32 {
+		// every element takes at least one word
+		return reflect.Value{}, fmt.Errorf("abi: cannot unmarshal %s, insufficient data for %d elements", t, n)
+	}
+	slice := reflect.MakeSlice(t.Type, n, n)
+	for i := 0; i < n; i++ {
+		elem, err := t.Elem.unpack(data, offset+i*t.Elem.headSize())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		slice.Index(i).Set(elem)
+	}
+	return slice, nil
+}
+
+// unpackTuple decodes the values of the given arguments from data.
+func unpackTuple(args []Argument, data []byte) ([]reflect.Value, error) {
+	values := make([]reflect.Value, len(args))
+	offset := 0
+	for i, arg := range args {
+		v, err := arg.Type.unpack(data, offset)
+		if err != nil {
+			return nil, fmt.Errorf("`%s` %v", arg.Name, err)
+		}
+		values[i] = v
+		offset += arg.Type.headSize()
+	}
+	return values, nil
+}
+
+// readOffset interprets word as an offset or length into data of the
+// given size.
+func readOffset(word []byte, size int) (int, error) {
+	n := new(big.Int).SetBytes(word)
+	if n.BitLen() > 31 || int(n.Int64()) > size {
+		return 0, fmt.Errorf("abi: offset %v out of bounds (%d bytes)", n, size)
+	}
+	return int(n.Int64()), nil
 }
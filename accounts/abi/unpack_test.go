@@ -0,0 +1,206 @@
+package abi
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that values packed as method inputs are unpacked into the
+// same values when decoded as method outputs.
+func TestUnpackRoundTrip(t *testing.T) {
+	addr := common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+	tests := []struct {
+		typ   string
+		input interface{}
+		into  interface{} // pointer to the destination
+		want  interface{}
+	}{
+		{"uint256", big.NewInt(1000), new(*big.Int), big.NewInt(1000)},
+		{"uint8", uint8(255), new(uint8), uint8(255)},
+		{"uint64", uint64(1 << 40), new(uint64), uint64(1 << 40)},
+		{"int256", big.NewInt(42), new(*big.Int), big.NewInt(42)},
+		{"int32", int32(42), new(int), int(42)},
+		{"bool", true, new(bool), true},
+		{"bool", false, new(interface{}), false},
+		{"address", addr, new(common.Address), addr},
+		{"address", addr.Bytes(), new([]byte), addr.Bytes()},
+		{"string", "hello world", new(string), "hello world"},
+		{"string", strings.Repeat("long string ", 10), new(string), strings.Repeat("long string ", 10)},
+		{"string", "", new(string), ""},
+		{"bytes", []byte{1, 2, 3}, new([]byte), []byte{1, 2, 3}},
+		{"bytes", []byte("as string"), new(string), "as string"},
+		{"uint256[2]", []*big.Int{big.NewInt(1), big.NewInt(2)}, new([]*big.Int), []*big.Int{big.NewInt(1), big.NewInt(2)}},
+		{"uint64[2]", []uint64{1, 2}, new([2]uint64), [2]uint64{1, 2}},
+		{"uint64[]", []uint64{1, 2, 3}, new([]uint64), []uint64{1, 2, 3}},
+		{"uint64[]", []uint64{}, new([]uint64), []uint64{}},
+		{"bool[]", []bool{true, false, true}, new([]bool), []bool{true, false, true}},
+		{"address[2]", []common.Address{addr, {}}, new([]common.Address), []common.Address{addr, {}}},
+		{"string[]", []string{"foo", "", "bar"}, new([]string), []string{"foo", "", "bar"}},
+		{"bytes[2]", [][]byte{{1}, {2, 3}}, new([][]byte), [][]byte{{1}, {2, 3}}},
+		{"int8", int8(-5), new(int8), int8(-5)},
+		{"int256", big.NewInt(-42), new(*big.Int), big.NewInt(-42)},
+		{"int64[]", []int64{-1, 0, 1}, new([]int64), []int64{-1, 0, 1}},
+		{"bytes1", []byte{0xff}, new([1]byte), [1]byte{0xff}},
+		{"bytes3", "abc", new([]byte), []byte("abc")},
+		{"bytes32", common.HexToHash("0x01"), new(common.Hash), common.HexToHash("0x01")},
+		{"function", [24]byte{1, 2, 23: 3}, new([24]byte), [24]byte{1, 2, 23: 3}},
+		{"uint8[2][]", [][2]uint8{{1, 2}, {3, 4}, {5, 6}}, new([][2]uint8), [][2]uint8{{1, 2}, {3, 4}, {5, 6}}},
+		{"uint[][2]", [][]uint{{1}, {2, 3}}, new([][]uint), [][]uint{{1}, {2, 3}}},
+		{"string[][]", [][]string{{"a", "b"}, {}, {"c"}}, new([][]string), [][]string{{"a", "b"}, {}, {"c"}}},
+		{"bytes2[2][2]", [][][]byte{{{1}, {2}}, {{3}, {4}}}, new([2][2][2]byte), [2][2][2]byte{{{1}, {2}}, {{3}, {4}}}},
+	}
+	for i, test := range tests {
+		abi, err := JSON(strings.NewReader(`[{ "name" : "method", "const" : true,
+			"input" : [ { "name" : "in", "type" : "` + test.typ + `" } ],
+			"output" : [ { "name" : "out", "type" : "` + test.typ + `" } ] }]`))
+		if err != nil {
+			t.Fatalf("test %d (%s): ABI error: %v", i, test.typ, err)
+		}
+		packed, err := abi.Pack("method", test.input)
+		if err != nil {
+			t.Errorf("test %d (%s): pack error: %v", i, test.typ, err)
+			continue
+		}
+		if err := abi.Unpack(test.into, "method", packed[4:]); err != nil {
+			t.Errorf("test %d (%s): unpack error: %v", i, test.typ, err)
+			continue
+		}
+		if have := reflect.ValueOf(test.into).Elem().Interface(); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("test %d (%s): value mismatch:\n  have: %v\n  want: %v", i, test.typ, have, test.want)
+		}
+	}
+}
+
+const multiOutputABI = `[{ "name" : "info", "const" : true,
+	"input" : [ { "name" : "balance", "type" : "uint256" }, { "name" : "name", "type" : "string" }, { "name" : "ok", "type" : "bool" }, { "name" : "ids", "type" : "uint32[]" } ],
+	"output" : [ { "name" : "balance", "type" : "uint256" }, { "name" : "name", "type" : "string" }, { "name" : "_ok", "type" : "bool" }, { "name" : "ids", "type" : "uint32[]" } ] }]`
+
+// Tests that multiple outputs are unpacked into structs and lists.
+func TestUnpackMultiple(t *testing.T) {
+	abi, err := JSON(strings.NewReader(multiOutputABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err := abi.Pack("info", big.NewInt(100), "test", true, []uint32{7, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info struct {
+		Balance *big.Int
+		Name    string
+		Ok      bool
+		Ids     []uint32
+	}
+	if err := abi.Unpack(&info, "info", packed[4:]); err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+	if info.Balance.Cmp(big.NewInt(100)) != 0 || info.Name != "test" || !info.Ok || !reflect.DeepEqual(info.Ids, []uint32{7, 8}) {
+		t.Errorf("struct mismatch: %+v", info)
+	}
+
+	var list []interface{}
+	if err := abi.Unpack(&list, "info", packed[4:]); err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+	want := []interface{}{big.NewInt(100), "test", true, []*big.Int{big.NewInt(7), big.NewInt(8)}}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("list mismatch:\n  have: %v\n  want: %v", list, want)
+	}
+
+	var (
+		balance uint64
+		name    string
+		ok      bool
+		ids     []uint32
+	)
+	ptrs := []interface{}{&balance, &name, &ok, &ids}
+	if err := abi.Unpack(&ptrs, "info", packed[4:]); err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+	if balance != 100 || name != "test" || !ok || !reflect.DeepEqual(ids, []uint32{7, 8}) {
+		t.Errorf("pointer list mismatch: %v %q %v %v", balance, name, ok, ids)
+	}
+
+	var missing struct{ Balance *big.Int }
+	if err := abi.Unpack(&missing, "info", packed[4:]); err == nil {
+		t.Errorf("expected error for struct with missing fields")
+	}
+	var single *big.Int
+	if err := abi.Unpack(&single, "info", packed[4:]); err == nil {
+		t.Errorf("expected error for single value destination")
+	}
+}
+
+// Tests unpacking of output encoded by the Solidity compiler.
+func TestUnpackSolidityOutput(t *testing.T) {
+	abi, err := JSON(strings.NewReader(`[{ "name" : "f", "const" : true,
+		"output" : [ { "name" : "a", "type" : "uint256" }, { "name" : "b", "type" : "string" }, { "name" : "c", "type" : "uint256[]" } ] }]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// function f() returns (uint a, string b, uint[] c) { return (1, "hello", [2, 3]); }
+	output := common.Hex2Bytes("" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000060" +
+		"00000000000000000000000000000000000000000000000000000000000000a0" +
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"68656c6c6f000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000003")
+	var result struct {
+		A uint64
+		B string
+		C []uint64
+	}
+	if err := abi.Unpack(&result, "f", output); err != nil {
+		t.Fatalf("unpack error: %v", err)
+	}
+	if result.A != 1 || result.B != "hello" || !reflect.DeepEqual(result.C, []uint64{2, 3}) {
+		t.Errorf("result mismatch: %+v", result)
+	}
+}
+
+func TestUnpackErrors(t *testing.T) {
+	abi, err := JSON(strings.NewReader(`[
+		{ "name" : "num", "const" : true, "output" : [ { "name" : "a", "type" : "uint256" } ] },
+		{ "name" : "flag", "const" : true, "output" : [ { "name" : "a", "type" : "bool" } ] },
+		{ "name" : "str", "const" : true, "output" : [ { "name" : "a", "type" : "string" } ] }
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	word := func(b byte) []byte { return common.LeftPadBytes([]byte{b}, 32) }
+	large := common.LeftPadBytes([]byte{1, 0}, 32)
+
+	var (
+		num  uint8
+		flag bool
+		str  string
+	)
+	tests := []struct {
+		into   interface{}
+		method string
+		output []byte
+	}{
+		{&num, "num", nil},                           // empty output
+		{&num, "num", word(1)[:31]},                  // short output
+		{&num, "num", large},                         // overflow
+		{num, "num", word(1)},                        // non-pointer
+		{&str, "num", word(1)},                       // type mismatch
+		{&flag, "flag", word(2)},                     // bad boolean
+		{&str, "str", word(64)},                      // offset out of bounds
+		{&str, "str", append(word(32), word(64)...)}, // length out of bounds
+		{&str, "doesntexist", word(1)},               // unknown method
+	}
+	for i, test := range tests {
+		if err := abi.Unpack(test.into, test.method, test.output); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
@@ -37,6 +37,8 @@ import (
 	"crypto/ecdsa"
 	crand "crypto/rand"
 	"errors"
+	"fmt"
+	"math/big"
 	"os"
 	"sync"
 	"time"
@@ -47,6 +49,7 @@ import (
 var (
 	ErrLocked = errors.New("account is locked")
 	ErrNoKeys = errors.New("no keys in store")
+	ErrNoHD   = errors.New("key store does not support HD wallets")
 )
 
 type Account struct {
@@ -61,7 +64,8 @@ type Manager struct {
 
 type unlocked struct {
 	*crypto.Key
-	abort chan struct{}
+	abort  chan struct{}
+	policy *PolicyStatus // nil if the account was unlocked without a policy
 }
 
 func NewManager(keyStore crypto.KeyStore2) *Manager {
@@ -98,15 +102,83 @@ func (am *Manager) DeleteAccount(address []byte, auth string) error {
 	return am.keyStore.DeleteKey(address, auth)
 }
 
+// Update re-encrypts the key of the account with a new passphrase. The new
+// key file replaces the old one only once it has been written completely.
+// For accounts derived from an HD wallet, the wallet seed is re-encrypted,
+// changing the passphrase of all accounts of the wallet.
+func (am *Manager) Update(addr []byte, authFrom, authTo string) error {
+	if ks, ok := am.keyStore.(crypto.HDKeyStore); ok {
+		if err := ks.UpdateHDWallet(addr, authFrom, authTo); err != crypto.ErrNoHDWallet {
+			return err
+		}
+	}
+	key, err := am.keyStore.GetKey(addr, authFrom)
+	if err != nil {
+		return err
+	}
+	return am.keyStore.StoreKey(key, authTo)
+}
+
+// Sign signs a hash with an unlocked account. Accounts unlocked with a
+// policy that limits the destination or value of transactions can only sign
+// through SignTx.
 func (am *Manager) Sign(a Account, toSign []byte) (signature []byte, err error) {
+	return am.sign(a.Address, nil, nil, toSign)
+}
+
+// SignTx signs the hash of a transaction sending value to the given address,
+// nil for contract creation, paying at most gas times gasPrice in fees. If
+// the account was unlocked with a policy, the transaction must be allowed by
+// it and both value and fee count against its maximum value.
+func (am *Manager) SignTx(a Account, to []byte, value, gas, gasPrice *big.Int, toSign []byte) (signature []byte, err error) {
+	cost := new(big.Int)
+	if value != nil {
+		cost.Set(value)
+	}
+	if gas != nil && gasPrice != nil {
+		cost.Add(cost, new(big.Int).Mul(gas, gasPrice))
+	}
+	return am.sign(a.Address, to, cost, toSign)
+}
+
+func (am *Manager) sign(addr, to []byte, cost *big.Int, toSign []byte) ([]byte, error) {
 	am.mutex.RLock()
-	unlockedKey, found := am.unlocked[string(a.Address)]
+	u, found := am.unlocked[string(addr)]
 	am.mutex.RUnlock()
 	if !found {
 		return nil, ErrLocked
 	}
-	signature, err = crypto.Sign(toSign, unlockedKey.PrivateKey)
-	return signature, err
+	if u.policy == nil {
+		return crypto.Sign(toSign, u.PrivateKey)
+	}
+
+	// the policy is checked and updated while holding the lock so that
+	// concurrent signers cannot exceed its limits.
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	if am.unlocked[string(addr)] != u {
+		return nil, ErrLocked
+	}
+	if !u.policy.Expiry.IsZero() && !time.Now().Before(u.policy.Expiry) {
+		am.drop(addr, u)
+		return nil, ErrLocked
+	}
+	if cost == nil {
+		if u.policy.restrictsTx() {
+			return nil, ErrPolicyTx
+		}
+		cost = new(big.Int)
+	} else if err := u.policy.allows(to, cost); err != nil {
+		return nil, err
+	}
+	signature, err := crypto.Sign(toSign, u.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if u.policy.add(cost) {
+		am.drop(addr, u)
+	}
+	return signature, nil
 }
 
 // TimedUnlock unlocks the account with the given address.
@@ -116,7 +188,7 @@ func (am *Manager) TimedUnlock(addr []byte, keyAuth string, timeout time.Duratio
 	if err != nil {
 		return err
 	}
-	u := am.addUnlocked(addr, key)
+	u := am.addUnlocked(addr, key, nil)
 	go am.dropLater(addr, u, timeout)
 	return nil
 }
@@ -129,10 +201,41 @@ func (am *Manager) Unlock(addr []byte, keyAuth string) error {
 	if err != nil {
 		return err
 	}
-	am.addUnlocked(addr, key)
+	am.addUnlocked(addr, key, nil)
 	return nil
 }
 
+// UnlockWithPolicy unlocks the account with the given address for signing
+// within the limits of policy. The account is locked again when the policy
+// expires or one of its limits is reached.
+func (am *Manager) UnlockWithPolicy(addr []byte, keyAuth string, policy UnlockPolicy) error {
+	key, err := am.keyStore.GetKey(addr, keyAuth)
+	if err != nil {
+		return err
+	}
+	status := (&PolicyStatus{UnlockPolicy: policy, Value: new(big.Int)}).copy()
+	u := am.addUnlocked(addr, key, status)
+	if !policy.Expiry.IsZero() {
+		go am.dropLater(addr, u, policy.Expiry.Sub(time.Now()))
+	}
+	return nil
+}
+
+// Policy returns the policy of an unlocked account and the signatures made
+// under it, or nil if the account was unlocked without a policy.
+func (am *Manager) Policy(addr []byte) (*PolicyStatus, error) {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	u, found := am.unlocked[string(addr)]
+	if !found {
+		return nil, ErrLocked
+	}
+	if u.policy == nil {
+		return nil, nil
+	}
+	return u.policy.copy(), nil
+}
+
 func (am *Manager) NewAccount(auth string) (Account, error) {
 	key, err := am.keyStore.GenerateNewKey(crand.Reader, auth)
 	if err != nil {
@@ -141,6 +244,45 @@ func (am *Manager) NewAccount(auth string) (Account, error) {
 	return Account{Address: key.Address}, nil
 }
 
+// NewHDWallet creates an HD wallet from a BIP39 mnemonic and its optional
+// passphrase. The wallet seed is encrypted with auth, which also unlocks the
+// accounts of the wallet. The first account, at m/44'/60'/0'/0/0, is derived
+// and returned.
+func (am *Manager) NewHDWallet(mnemonic, passphrase, auth string) (Account, error) {
+	ks, ok := am.keyStore.(crypto.HDKeyStore)
+	if !ok {
+		return Account{}, ErrNoHD
+	}
+	wallet, err := ks.NewHDWallet(mnemonic, passphrase, auth)
+	if err != nil {
+		return Account{}, err
+	}
+	return Account{Address: wallet.Addresses[0]}, nil
+}
+
+// DeriveAccount derives the next account of the HD wallet holding the
+// account with the given address.
+func (am *Manager) DeriveAccount(addr []byte, auth string) (Account, error) {
+	ks, ok := am.keyStore.(crypto.HDKeyStore)
+	if !ok {
+		return Account{}, ErrNoHD
+	}
+	derived, err := ks.DeriveHDAccount(addr, auth)
+	if err != nil {
+		return Account{}, err
+	}
+	return Account{Address: derived}, nil
+}
+
+// HDWallets returns the HD wallets of the key store.
+func (am *Manager) HDWallets() ([]*crypto.HDWallet, error) {
+	ks, ok := am.keyStore.(crypto.HDKeyStore)
+	if !ok {
+		return nil, ErrNoHD
+	}
+	return ks.GetHDWallets()
+}
+
 func (am *Manager) Accounts() ([]Account, error) {
 	addresses, err := am.keyStore.GetKeyAddresses()
 	if os.IsNotExist(err) {
@@ -157,8 +299,8 @@ func (am *Manager) Accounts() ([]Account, error) {
 	return accounts, err
 }
 
-func (am *Manager) addUnlocked(addr []byte, key *crypto.Key) *unlocked {
-	u := &unlocked{Key: key, abort: make(chan struct{})}
+func (am *Manager) addUnlocked(addr []byte, key *crypto.Key, policy *PolicyStatus) *unlocked {
+	u := &unlocked{Key: key, abort: make(chan struct{}), policy: policy}
 	am.mutex.Lock()
 	prev, found := am.unlocked[string(addr)]
 	if found {
@@ -194,6 +336,14 @@ func (am *Manager) dropLater(addr []byte, u *unlocked, timeout time.Duration) {
 	}
 }
 
+// drop locks an account whose policy limit was reached. The caller must
+// hold the write lock.
+func (am *Manager) drop(addr []byte, u *unlocked) {
+	close(u.abort)
+	zeroKey(u.PrivateKey)
+	delete(am.unlocked, string(addr))
+}
+
 // zeroKey zeroes a private key in memory.
 func zeroKey(k *ecdsa.PrivateKey) {
 	b := k.D.Bits()
@@ -235,3 +385,12 @@ func (am *Manager) ImportPreSaleKey(keyJSON []byte, password string) (acc Accoun
 	}
 	return Account{Address: key.Address}, nil
 }
+
+// TextHash returns the hash signed for arbitrary data, which is the hash of
+// "\x19Ethereum Signed Message:\n" followed by the length and the content of
+// the data. The prefix makes sure such a signature can't be used as the
+// signature of a transaction.
+func TextHash(data []byte) []byte {
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(data), data)
+	return crypto.Sha3([]byte(msg))
+}
@@ -1,17 +1,21 @@
 package accounts
 
 import (
+	"bytes"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/randentropy"
 )
 
 func TestSign(t *testing.T) {
-	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	dir, ks := tmpKeyStore(t, false)
 	defer os.RemoveAll(dir)
 
 	am := NewManager(ks)
@@ -27,7 +31,7 @@ func TestSign(t *testing.T) {
 }
 
 func TestTimedUnlock(t *testing.T) {
-	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePassphrase)
+	dir, ks := tmpKeyStore(t, true)
 	defer os.RemoveAll(dir)
 
 	am := NewManager(ks)
@@ -60,10 +64,232 @@ func TestTimedUnlock(t *testing.T) {
 	}
 }
 
-func tmpKeyStore(t *testing.T, new func(string) crypto.KeyStore2) (string, crypto.KeyStore2) {
+func TestUpdate(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	a1, err := am.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Update(a1.Address, "baz", "bar"); err == nil {
+		t.Fatal("Update should've failed with the wrong passphrase")
+	}
+	if err := am.Update(a1.Address, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(a1.Address, "foo"); err == nil {
+		t.Error("Unlock should've failed with the old passphrase")
+	}
+	if err := am.Unlock(a1.Address, "bar"); err != nil {
+		t.Errorf("Unlock failed with the new passphrase: %v", err)
+	}
+
+	// the passphrase of an HD wallet applies to all of its accounts
+	h1, err := am.NewHDWallet("legal winner thank year wave sausage worth useful legal winner thank yellow", "", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := am.DeriveAccount(h1.Address, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Update(h2.Address, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(h1.Address, "foo"); err == nil {
+		t.Error("Unlock should've failed with the old wallet passphrase")
+	}
+	if err := am.Unlock(h1.Address, "bar"); err != nil {
+		t.Errorf("Unlock failed with the new wallet passphrase: %v", err)
+	}
+	if accounts, _ := am.Accounts(); len(accounts) != 3 {
+		t.Errorf("account count mismatch after update: have %d, want 3", len(accounts))
+	}
+}
+
+func TestHDWalletSign(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	pass := "foo"
+	mnemonic := "legal winner thank year wave sausage worth useful legal winner thank yellow"
+	a1, err := am.NewHDWallet(mnemonic, "", pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := am.DeriveAccount(a1.Address, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := am.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 || !bytes.Equal(accounts[0].Address, a1.Address) || !bytes.Equal(accounts[1].Address, a2.Address) {
+		t.Fatalf("accounts mismatch: have %x, want %x and %x", accounts, a1.Address, a2.Address)
+	}
+
+	// derived accounts sign like stored keys once unlocked
+	toSign := randentropy.GetEntropyCSPRNG(32)
+	if _, err = am.Sign(a2, toSign); err != ErrLocked {
+		t.Fatal("Signing should've failed with ErrLocked before unlocking, got ", err)
+	}
+	if err = am.Unlock(a2.Address, pass); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := am.Sign(a2, toSign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(toSign, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); !bytes.Equal(signer, a2.Address) {
+		t.Errorf("signer mismatch: have %x, want %x", signer, a2.Address)
+	}
+}
+
+func TestHDWalletUnsupported(t *testing.T) {
+	dir, ks := tmpKeyStore(t, false)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	if _, err := am.NewHDWallet("legal winner thank year wave sausage worth useful legal winner thank yellow", "", ""); err != ErrNoHD {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNoHD)
+	}
+}
+
+func TestUnlockPolicyConcurrent(t *testing.T) {
+	dir, ks := tmpKeyStore(t, false)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	a1, _ := am.NewAccount("")
+	to := randentropy.GetEntropyCSPRNG(20)
+	tests := []struct {
+		policy UnlockPolicy
+		value  *big.Int
+	}{
+		{UnlockPolicy{MaxSignatures: 10}, big.NewInt(1)},
+		{UnlockPolicy{MaxValue: big.NewInt(100)}, big.NewInt(10)},
+		{UnlockPolicy{MaxSignatures: 10, MaxValue: big.NewInt(1000), To: [][]byte{to}}, big.NewInt(10)},
+	}
+	for i, tt := range tests {
+		if err := am.UnlockWithPolicy(a1.Address, "", tt.policy); err != nil {
+			t.Fatal(err)
+		}
+		// 50 signers race for the 10 signatures allowed by the policy
+		var wg sync.WaitGroup
+		errs := make(chan error, 50)
+		for j := 0; j < 50; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := am.SignTx(a1, to, tt.value, nil, nil, randentropy.GetEntropyCSPRNG(32))
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		signed := 0
+		for err := range errs {
+			switch err {
+			case nil:
+				signed++
+			case ErrLocked:
+			default:
+				t.Errorf("test %d: unexpected error %v", i, err)
+			}
+		}
+		if signed != 10 {
+			t.Errorf("test %d: signed %d transactions, want 10", i, signed)
+		}
+		if _, err := am.Policy(a1.Address); err != ErrLocked {
+			t.Errorf("test %d: account not locked after reaching the policy limit", i)
+		}
+	}
+}
+
+func TestUnlockPolicyLimits(t *testing.T) {
+	dir, ks := tmpKeyStore(t, false)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	a1, _ := am.NewAccount("")
+	to, other := randentropy.GetEntropyCSPRNG(20), randentropy.GetEntropyCSPRNG(20)
+	toSign := randentropy.GetEntropyCSPRNG(32)
+
+	policy := UnlockPolicy{MaxValue: big.NewInt(100), To: [][]byte{to}}
+	if err := am.UnlockWithPolicy(a1.Address, "", policy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.SignTx(a1, other, big.NewInt(1), nil, nil, toSign); err != ErrPolicyDestination {
+		t.Errorf("signing to other destination: have %v, want %v", err, ErrPolicyDestination)
+	}
+	if _, err := am.SignTx(a1, nil, big.NewInt(0), nil, nil, toSign); err != ErrPolicyDestination {
+		t.Errorf("signing contract creation: have %v, want %v", err, ErrPolicyDestination)
+	}
+	if _, err := am.Sign(a1, toSign); err != ErrPolicyTx {
+		t.Errorf("signing hash: have %v, want %v", err, ErrPolicyTx)
+	}
+	if _, err := am.SignTx(a1, to, big.NewInt(60), nil, nil, toSign); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.SignTx(a1, to, big.NewInt(60), nil, nil, toSign); err != ErrPolicyValue {
+		t.Errorf("signing above max value: have %v, want %v", err, ErrPolicyValue)
+	}
+	// fees count against the maximum value like the value sent
+	if _, err := am.SignTx(a1, to, big.NewInt(0), big.NewInt(21000), big.NewInt(1), toSign); err != ErrPolicyValue {
+		t.Errorf("signing with fee above max value: have %v, want %v", err, ErrPolicyValue)
+	}
+	if _, err := am.SignTx(a1, to, big.NewInt(10), big.NewInt(2), big.NewInt(10), toSign); err != nil {
+		t.Fatal(err)
+	}
+	status, err := am.Policy(a1.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Signatures != 2 || status.Value.Cmp(big.NewInt(90)) != 0 {
+		t.Errorf("policy status mismatch: have %d signatures of value %v, want 2 of 90", status.Signatures, status.Value)
+	}
+
+	// the policy expires like a timed unlock
+	policy = UnlockPolicy{Expiry: time.Now().Add(100 * time.Millisecond)}
+	if err := am.UnlockWithPolicy(a1.Address, "", policy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.Sign(a1, toSign); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if _, err := am.Sign(a1, toSign); err != ErrLocked {
+		t.Errorf("signing after expiry: have %v, want %v", err, ErrLocked)
+	}
+}
+
+func TestTextHash(t *testing.T) {
+	hash := TextHash([]byte("Hello Joe"))
+	want := common.FromHex("0xa080337ae51c4e064c189e113edd0ba391df9206e2f49db658bb32cf2911730b")
+	if !bytes.Equal(hash, want) {
+		t.Errorf("wrong hash: %x", hash)
+	}
+}
+
+func tmpKeyStore(t *testing.T, encrypted bool) (string, crypto.KeyStore2) {
 	d, err := ioutil.TempDir("", "eth-keystore-test")
 	if err != nil {
 		t.Fatal(err)
 	}
+	new := crypto.NewKeyStorePlain
+	if encrypted {
+		new = func(kd string) crypto.KeyStore2 {
+			return crypto.NewKeyStorePassphrase(kd, crypto.LightScryptN, crypto.LightScryptP)
+		}
+	}
 	return d, new(d)
 }
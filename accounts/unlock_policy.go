@@ -0,0 +1,84 @@
+package accounts
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrPolicyDestination = errors.New("destination not allowed by unlock policy")
+	ErrPolicyValue       = errors.New("value exceeds the limit of the unlock policy")
+	ErrPolicyTx          = errors.New("unlock policy only allows signing transactions")
+)
+
+// UnlockPolicy limits what can be signed with an unlocked account. The
+// account is locked again as soon as one of the limits is reached. Zero
+// fields impose no limit.
+type UnlockPolicy struct {
+	MaxSignatures int       // number of signatures
+	MaxValue      *big.Int  // total value and fees of the signed transactions
+	To            [][]byte  // allowed destination addresses
+	Expiry        time.Time // time at which the account is locked
+}
+
+// PolicyStatus is the policy of an unlocked account along with the
+// signatures made under it so far.
+type PolicyStatus struct {
+	UnlockPolicy
+	Signatures int
+	Value      *big.Int // value and fees spent so far
+}
+
+// restrictsTx reports whether the policy needs the destination or value
+// of what is signed.
+func (p *UnlockPolicy) restrictsTx() bool {
+	return p.MaxValue != nil || len(p.To) > 0
+}
+
+// allows checks a transaction to the given address, nil for contract
+// creation, which spends cost in value and fees against the policy.
+func (s *PolicyStatus) allows(to []byte, cost *big.Int) error {
+	if len(s.To) > 0 {
+		allowed := false
+		for _, addr := range s.To {
+			if to != nil && bytes.Equal(addr, to) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrPolicyDestination
+		}
+	}
+	if s.MaxValue != nil && new(big.Int).Add(s.Value, cost).Cmp(s.MaxValue) > 0 {
+		return ErrPolicyValue
+	}
+	return nil
+}
+
+// add records a signature of a transaction spending cost in value and fees
+// and reports whether a limit has been reached.
+func (s *PolicyStatus) add(cost *big.Int) bool {
+	s.Signatures++
+	s.Value.Add(s.Value, cost)
+	if s.MaxSignatures > 0 && s.Signatures >= s.MaxSignatures {
+		return true
+	}
+	return s.MaxValue != nil && s.Value.Cmp(s.MaxValue) >= 0
+}
+
+// copy returns a copy of the status that is safe to hand out.
+func (s *PolicyStatus) copy() *PolicyStatus {
+	cpy := *s
+	cpy.Value = new(big.Int).Set(s.Value)
+	if s.MaxValue != nil {
+		cpy.MaxValue = new(big.Int).Set(s.MaxValue)
+	}
+	cpy.To = make([][]byte, len(s.To))
+	for i, addr := range s.To {
+		cpy.To[i] = append([]byte{}, addr...)
+	}
+	return &cpy
+}
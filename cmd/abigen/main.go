@@ -0,0 +1,92 @@
+/*
+	This file is part of go-ethereum
+
+	go-ethereum is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	go-ethereum is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// abigen generates type-safe Go bindings for Ethereum contracts.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+var (
+	abiFlag = flag.String("abi", "", "path to the contract ABI JSON to generate bindings for")
+	binFlag = flag.String("bin", "", "path to the contract bytecode to generate a deploy function (optional)")
+	typFlag = flag.String("type", "", "Go type name of the binding (default = ABI file name)")
+	pkgFlag = flag.String("pkg", "", "Go package name to generate the binding into")
+	outFlag = flag.String("out", "", "output file for the generated binding (default = stdout)")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "-abi <file> -pkg <name> [-bin <file>] [-type <name>] [-out <file>]")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, `
+Generates a Go package wrapping the given contract ABI. The binding
+has call methods for constant functions, transaction methods for all
+others, filter and watch methods for events and, if the bytecode is
+given, a function deploying the contract.`)
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if *abiFlag == "" || *pkgFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: -abi and -pkg are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	abi, err := ioutil.ReadFile(*abiFlag)
+	if err != nil {
+		die(err)
+	}
+	var bin []byte
+	if *binFlag != "" {
+		if bin, err = ioutil.ReadFile(*binFlag); err != nil {
+			die(err)
+		}
+	}
+	typ := *typFlag
+	if typ == "" {
+		base := filepath.Base(*abiFlag)
+		typ = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	code, err := bind.Bind(typ, string(abi), string(bin), *pkgFlag)
+	if err != nil {
+		die(err)
+	}
+	if *outFlag == "" {
+		fmt.Print(code)
+		return
+	}
+	if err := ioutil.WriteFile(*outFlag, []byte(code), 0644); err != nil {
+		die(err)
+	}
+}
+
+func die(args ...interface{}) {
+	fmt.Fprintln(os.Stderr, args...)
+	os.Exit(1)
+}
@@ -71,7 +71,7 @@ func main() {
 		}
 	}
 
-	if _, err := discover.ListenUDP(nodeKey, *listenAddr, natm); err != nil {
+	if _, err := discover.ListenUDP(nodeKey, *listenAddr, natm, 0); err != nil {
 		log.Fatal(err)
 	}
 	select {}
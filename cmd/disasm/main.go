@@ -1,34 +1,93 @@
+// disasm disassembles EVM code given in hex, either from files, from the
+// command line or from stdin.
+//
+//	disasm code.hex
+//	disasm --hex 6060604052
+//	echo 6060604052 | disasm --json
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/asm"
 )
 
+var (
+	hexCode = flag.String("hex", "", "code to disassemble in hex")
+	jsonOut = flag.Bool("json", false, "print the instructions as JSON")
+)
+
+// instruction is the JSON form of a disassembled instruction.
+type instruction struct {
+	Pc  uint64 `json:"pc"`
+	Op  string `json:"op"`
+	Arg string `json:"arg,omitempty"`
+}
+
 func main() {
-	code, err := ioutil.ReadAll(os.Stdin)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [file ...]\n\nReads the code from stdin if neither --hex nor a file is given.\n\n", os.Args[0])
+		flag.PrintDefaults()
 	}
-	code = common.Hex2Bytes(string(code[:len(code)-1]))
-	fmt.Printf("%x\n", code)
+	flag.Parse()
 
-	for pc := uint64(0); pc < uint64(len(code)); pc++ {
-		op := vm.OpCode(code[pc])
-		fmt.Printf("%-5d  %v", pc, op)
+	var inputs []string
+	switch {
+	case *hexCode != "":
+		inputs = append(inputs, *hexCode)
+	case flag.NArg() == 0:
+		in, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		inputs = append(inputs, string(in))
+	}
+	for _, file := range flag.Args() {
+		in, err := ioutil.ReadFile(file)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		inputs = append(inputs, string(in))
+	}
 
-		switch op {
-		case vm.PUSH1, vm.PUSH2, vm.PUSH3, vm.PUSH4, vm.PUSH5, vm.PUSH6, vm.PUSH7, vm.PUSH8, vm.PUSH9, vm.PUSH10, vm.PUSH11, vm.PUSH12, vm.PUSH13, vm.PUSH14, vm.PUSH15, vm.PUSH16, vm.PUSH17, vm.PUSH18, vm.PUSH19, vm.PUSH20, vm.PUSH21, vm.PUSH22, vm.PUSH23, vm.PUSH24, vm.PUSH25, vm.PUSH26, vm.PUSH27, vm.PUSH28, vm.PUSH29, vm.PUSH30, vm.PUSH31, vm.PUSH32:
-			a := uint64(op) - uint64(vm.PUSH1) + 1
-			fmt.Printf("  => %x", code[pc+1:pc+1+a])
+	for _, in := range inputs {
+		code := common.FromHex(strings.TrimSpace(in))
+		if err := disassemble(code); err != nil {
+			fatalf("%v", err)
+		}
+	}
+}
 
-			pc += a
+func disassemble(code []byte) error {
+	var instrs []instruction
+	it := asm.NewInstructionIterator(code)
+	for it.Next() {
+		if *jsonOut {
+			in := instruction{Pc: it.PC(), Op: it.Op().String()}
+			if it.Arg() != nil {
+				in.Arg = common.ToHex(it.Arg())
+			}
+			instrs = append(instrs, in)
+		} else if it.Arg() != nil {
+			fmt.Printf("%05d: %v 0x%x\n", it.PC(), it.Op(), it.Arg())
+		} else {
+			fmt.Printf("%05d: %v\n", it.PC(), it.Op())
 		}
-		fmt.Println()
 	}
+	if *jsonOut {
+		out, _ := json.MarshalIndent(instrs, "", "  ")
+		fmt.Println(string(out))
+	}
+	return it.Error()
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Fatal: "+format+"\n", args...)
+	os.Exit(1)
 }
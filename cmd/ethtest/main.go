@@ -19,215 +19,116 @@
  * 	Jeffrey Wilcke <i@jev.io>
  */
 
+// ethtest runs JSON test fixtures of the ethereum tests repository and
+// prints a JSON summary of the results.
+//
+//	ethtest --test state files/StateTests/stExample.json
+//	cat vmtest.json | ethtest --test vm
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
-	"math/big"
 	"os"
-	"strconv"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/ethdb"
-	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
-	"github.com/ethereum/go-ethereum/tests/helper"
+	"github.com/ethereum/go-ethereum/tests"
 )
 
-type Log struct {
-	AddressF string   `json:"address"`
-	DataF    string   `json:"data"`
-	TopicsF  []string `json:"topics"`
-	BloomF   string   `json:"bloom"`
-}
+var (
+	testType = flag.String("test", "state", "type of the tests: state, vm, trie or securetrie")
+	skip     = flag.String("skip", "", "comma separated names of tests to skip")
+	debug    = flag.Bool("debug", false, "print VM debug output to stderr")
+)
 
-func (self Log) Address() []byte      { return common.Hex2Bytes(self.AddressF) }
-func (self Log) Data() []byte         { return common.Hex2Bytes(self.DataF) }
-func (self Log) RlpData() interface{} { return nil }
-func (self Log) Topics() [][]byte {
-	t := make([][]byte, len(self.TopicsF))
-	for i, topic := range self.TopicsF {
-		t[i] = common.Hex2Bytes(topic)
-	}
-	return t
+// runners are the test runners selectable with --test.
+var runners = map[string]func(r io.Reader, skipTests []string) (tests.Results, error){
+	"state": tests.RunStateTestsWithReader,
+	"vm":    tests.RunVmTestsWithReader,
+	"trie": func(r io.Reader, skipTests []string) (tests.Results, error) {
+		return tests.RunTrieTestsWithReader(r, false, skipTests)
+	},
+	"securetrie": func(r io.Reader, skipTests []string) (tests.Results, error) {
+		return tests.RunTrieTestsWithReader(r, true, skipTests)
+	},
 }
 
-type Account struct {
-	Balance string
-	Code    string
-	Nonce   string
-	Storage map[string]string
+// summary is the JSON output of ethtest.
+type summary struct {
+	Passed  int                      `json:"passed"`
+	Failed  int                      `json:"failed"`
+	Skipped int                      `json:"skipped"`
+	Results map[string]tests.Results `json:"results"` // by input file
 }
 
-func StateObjectFromAccount(db common.Database, addr string, account Account) *state.StateObject {
-	obj := state.NewStateObject(common.HexToAddress(addr), db)
-	obj.SetBalance(common.Big(account.Balance))
-
-	if common.IsHex(account.Code) {
-		account.Code = account.Code[2:]
+func (self *summary) add(file string, results tests.Results) {
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			self.Skipped++
+		case r.Pass:
+			self.Passed++
+		default:
+			self.Failed++
+		}
 	}
-	obj.SetCode(common.Hex2Bytes(account.Code))
-	obj.SetNonce(common.Big(account.Nonce).Uint64())
-
-	return obj
-}
-
-type VmTest struct {
-	Callcreates   interface{}
-	Env           Env
-	Exec          map[string]string
-	Transaction   map[string]string
-	Logs          []Log
-	Gas           string
-	Out           string
-	Post          map[string]Account
-	Pre           map[string]Account
-	PostStateRoot string
-}
-
-type Env struct {
-	CurrentCoinbase   string
-	CurrentDifficulty string
-	CurrentGasLimit   string
-	CurrentNumber     string
-	CurrentTimestamp  interface{}
-	PreviousHash      string
+	self.Results[file] = results
 }
 
-func RunVmTest(r io.Reader) (failed int) {
-	tests := make(map[string]VmTest)
-
-	data, _ := ioutil.ReadAll(r)
-	err := json.Unmarshal(data, &tests)
-	if err != nil {
-		log.Fatalln(err)
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [file ...]\n\nReads the tests from stdin if no file is given.\n\n", os.Args[0])
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	vm.Debug = true
-	glog.SetV(4)
-	glog.SetToStderr(true)
-	for name, test := range tests {
-		db, _ := ethdb.NewMemDatabase()
-		statedb := state.New(common.Hash{}, db)
-		for addr, account := range test.Pre {
-			obj := StateObjectFromAccount(db, addr, account)
-			statedb.SetStateObject(obj)
-		}
-
-		env := make(map[string]string)
-		env["currentCoinbase"] = test.Env.CurrentCoinbase
-		env["currentDifficulty"] = test.Env.CurrentDifficulty
-		env["currentGasLimit"] = test.Env.CurrentGasLimit
-		env["currentNumber"] = test.Env.CurrentNumber
-		env["previousHash"] = test.Env.PreviousHash
-		if n, ok := test.Env.CurrentTimestamp.(float64); ok {
-			env["currentTimestamp"] = strconv.Itoa(int(n))
-		} else {
-			env["currentTimestamp"] = test.Env.CurrentTimestamp.(string)
-		}
-
-		ret, logs, _, _ := helper.RunState(statedb, env, test.Transaction)
-		statedb.Sync()
-
-		rexp := helper.FromHex(test.Out)
-		if bytes.Compare(rexp, ret) != 0 {
-			glog.V(logger.Info).Infof("%s's return failed. Expected %x, got %x\n", name, rexp, ret)
-			failed = 1
-		}
-
-		for addr, account := range test.Post {
-			obj := statedb.GetStateObject(common.HexToAddress(addr))
-			if obj == nil {
-				continue
-			}
-
-			if len(test.Exec) == 0 {
-				if obj.Balance().Cmp(common.Big(account.Balance)) != 0 {
-					glog.V(logger.Info).Infof("%s's : (%x) balance failed. Expected %v, got %v => %v\n", name, obj.Address().Bytes()[:4], account.Balance, obj.Balance(), new(big.Int).Sub(common.Big(account.Balance), obj.Balance()))
-					failed = 1
-				}
-			}
-
-			for addr, value := range account.Storage {
-				v := obj.GetState(common.HexToHash(addr)).Bytes()
-				vexp := helper.FromHex(value)
-
-				if bytes.Compare(v, vexp) != 0 {
-					glog.V(logger.Info).Infof("%s's : (%x: %s) storage failed. Expected %x, got %x (%v %v)\n", name, obj.Address().Bytes()[0:4], addr, vexp, v, common.BigD(vexp), common.BigD(v))
-					failed = 1
-				}
-			}
-		}
+	run, ok := runners[*testType]
+	if !ok {
+		fatalf("unknown test type %q", *testType)
+	}
+	var skipTests []string
+	if *skip != "" {
+		skipTests = strings.Split(*skip, ",")
+	}
+	if *debug {
+		vm.Debug = true
+		glog.SetV(4)
+		glog.SetToStderr(true)
+	}
 
-		statedb.Sync()
-		//if !bytes.Equal(common.Hex2Bytes(test.PostStateRoot), statedb.Root()) {
-		if common.HexToHash(test.PostStateRoot) != statedb.Root() {
-			glog.V(logger.Info).Infof("%s's : Post state root failed. Expected %s, got %x", name, test.PostStateRoot, statedb.Root())
-			failed = 1
+	sum := summary{Results: make(map[string]tests.Results)}
+	if flag.NArg() == 0 {
+		results, err := run(os.Stdin, skipTests)
+		if err != nil {
+			fatalf("%v", err)
 		}
-
-		if len(test.Logs) > 0 {
-			if len(test.Logs) != len(logs) {
-				glog.V(logger.Info).Infof("log length failed. Expected %d, got %d", len(test.Logs), len(logs))
-				failed = 1
-			} else {
-				for i, log := range test.Logs {
-					if common.HexToAddress(log.AddressF) != logs[i].Address {
-						glog.V(logger.Info).Infof("'%s' log address failed. Expected %v got %x", name, log.AddressF, logs[i].Address)
-						failed = 1
-					}
-
-					if !bytes.Equal(logs[i].Data, helper.FromHex(log.DataF)) {
-						glog.V(logger.Info).Infof("'%s' log data failed. Expected %v got %x", name, log.DataF, logs[i].Data)
-						failed = 1
-					}
-
-					if len(log.TopicsF) != len(logs[i].Topics) {
-						glog.V(logger.Info).Infof("'%s' log topics length failed. Expected %d got %d", name, len(log.TopicsF), logs[i].Topics)
-						failed = 1
-					} else {
-						for j, topic := range log.TopicsF {
-							if common.HexToHash(topic) != logs[i].Topics[j] {
-								glog.V(logger.Info).Infof("'%s' log topic[%d] failed. Expected %v got %x", name, j, topic, logs[i].Topics[j])
-								failed = 1
-							}
-						}
-					}
-					genBloom := common.LeftPadBytes(types.LogsBloom(state.Logs{logs[i]}).Bytes(), 256)
-
-					if !bytes.Equal(genBloom, common.Hex2Bytes(log.BloomF)) {
-						glog.V(logger.Info).Infof("'%s' bloom failed.", name)
-						failed = 1
-					}
-				}
-			}
+		sum.add("stdin", results)
+	}
+	for _, file := range flag.Args() {
+		f, err := os.Open(file)
+		if err != nil {
+			fatalf("%v", err)
 		}
-
-		if failed == 1 {
-			glog.V(logger.Info).Infoln(string(statedb.Dump()))
+		results, err := run(f, skipTests)
+		f.Close()
+		if err != nil {
+			fatalf("%s: %v", file, err)
 		}
-
-		logger.Flush()
+		sum.add(file, results)
 	}
 
-	return
+	out, _ := json.MarshalIndent(sum, "", "  ")
+	fmt.Println(string(out))
+	if sum.Failed > 0 {
+		os.Exit(1)
+	}
 }
 
-func main() {
-	helper.Logger.SetLogLevel(5)
-	vm.Debug = true
-
-	if len(os.Args) > 1 {
-		os.Exit(RunVmTest(strings.NewReader(os.Args[1])))
-	} else {
-		os.Exit(RunVmTest(os.Stdin))
-	}
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Fatal: "+format+"\n", args...)
+	os.Exit(2)
 }
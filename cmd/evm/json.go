@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// jsonStep is the JSON form of a step of the execution.
+type jsonStep struct {
+	Pc      uint64   `json:"pc"`
+	Op      string   `json:"op"`
+	Gas     *big.Int `json:"gas"`
+	GasCost *big.Int `json:"gasCost"`
+	Memory  string   `json:"memory"`
+	Stack   []string `json:"stack"`
+	Depth   int      `json:"depth"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// jsonLog is the JSON form of a log created by the execution.
+type jsonLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// jsonResult is the JSON form of the outcome of the execution.
+type jsonResult struct {
+	Output  string    `json:"output"`
+	GasUsed *big.Int  `json:"gasUsed"`
+	Error   string    `json:"error,omitempty"`
+	Logs    []jsonLog `json:"logs"`
+	Root    string    `json:"root"`
+}
+
+// printJSON prints every step of the execution and then its result, one JSON
+// object per line, so that traces of different clients can be diffed.
+func printJSON(steps []vm.StructLog, ret []byte, gasUsed *big.Int, err error, statedb *state.StateDB) {
+	enc := json.NewEncoder(os.Stdout)
+	for _, step := range steps {
+		js := jsonStep{
+			Pc:      step.Pc,
+			Op:      step.Op.String(),
+			Gas:     step.Gas,
+			GasCost: step.GasCost,
+			Memory:  fmt.Sprintf("0x%x", step.Memory),
+			Stack:   make([]string, len(step.Stack)),
+			Depth:   step.Depth,
+		}
+		for i, item := range step.Stack {
+			js.Stack[i] = fmt.Sprintf("%#x", item)
+		}
+		if step.Err != nil {
+			js.Error = step.Err.Error()
+		}
+		enc.Encode(js)
+	}
+
+	result := jsonResult{
+		Output:  fmt.Sprintf("0x%x", ret),
+		GasUsed: gasUsed,
+		Logs:    []jsonLog{},
+		Root:    statedb.Root().Hex(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	for _, log := range statedb.Logs() {
+		jl := jsonLog{Address: log.Address.Hex(), Topics: make([]string, len(log.Topics)), Data: fmt.Sprintf("0x%x", log.Data)}
+		for i, topic := range log.Topics {
+			jl.Topics[i] = topic.Hex()
+		}
+		result.Logs = append(result.Logs, jl)
+	}
+	enc.Encode(result)
+}
@@ -22,31 +22,51 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math/big"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/asm"
 	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 var (
 	code     = flag.String("code", "", "evm code")
+	codefile = flag.String("codefile", "", "file with the evm code in hex, or assembly if it ends in .easm")
 	loglevel = flag.Int("log", 4, "log level")
 	gas      = flag.String("gas", "1000000000", "gas amount")
 	price    = flag.String("price", "0", "gas price")
 	value    = flag.String("value", "0", "tx value")
 	dump     = flag.Bool("dump", false, "dump state after run")
 	data     = flag.String("data", "", "data")
+
+	prestate = flag.String("prestate", "", "JSON file with the accounts of the state before the run")
+	sender   = flag.String("sender", "", "address of the caller")
+	receiver = flag.String("receiver", "", "address of the called contract")
+
+	number     = flag.Uint64("number", 0, "block number")
+	timestamp  = flag.Int64("time", time.Now().Unix(), "block time")
+	coinbase   = flag.String("coinbase", "", "block coinbase address (default: the caller)")
+	difficulty = flag.String("difficulty", "1", "block difficulty")
+	gaslimit   = flag.String("gaslimit", "1000000000", "block gas limit")
+
+	chainconfig = flag.String("chainconfig", "", "JSON file with the consensus rules of the chain (default: the main chain rules)")
+
+	jsonOut = flag.Bool("json", false, "print a JSON trace of every step and the result, one object per line")
 )
 
 func perr(v ...interface{}) {
@@ -57,21 +77,73 @@ func perr(v ...interface{}) {
 func main() {
 	flag.Parse()
 
-	logger.AddLogSystem(logger.NewStdLogSystem(os.Stdout, log.LstdFlags, logger.LogLevel(*loglevel)))
+	if *codefile != "" {
+		c, err := readCode(*codefile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		*code = common.Bytes2Hex(c)
+	}
+
+	if *jsonOut {
+		// the trace replaces the log output
+		vm.GenerateStructLogs = true
+	} else {
+		logger.AddLogSystem(logger.NewStdLogSystem(os.Stdout, log.LstdFlags, logger.LogLevel(*loglevel)))
+	}
 
 	db, _ := ethdb.NewMemDatabase()
 	statedb := state.New(common.Hash{}, db)
-	sender := statedb.CreateAccount(common.StringToAddress("sender"))
-	receiver := statedb.CreateAccount(common.StringToAddress("receiver"))
-	receiver.SetCode(common.Hex2Bytes(*code))
+	if *prestate != "" {
+		if err := loadPrestate(statedb, *prestate); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	from := statedb.GetOrNewStateObject(address(*sender, "sender"))
+	to := statedb.GetOrNewStateObject(address(*receiver, "receiver"))
+	if *code != "" {
+		to.SetCode(common.FromHex(*code))
+	}
 
-	vmenv := NewEnv(statedb, common.StringToAddress("evmuser"), common.Big(*value))
+	vmenv := NewEnv(statedb, from.Address(), common.Big(*value))
+	vmenv.number = new(big.Int).SetUint64(*number)
+	vmenv.time = *timestamp
+	vmenv.coinbase = address(*coinbase, "")
+	if *coinbase == "" {
+		vmenv.coinbase = from.Address()
+	}
+	vmenv.difficulty = common.Big(*difficulty)
+	vmenv.gasLimit = common.Big(*gaslimit)
+	if *chainconfig != "" {
+		config, err := params.LoadChainConfig(*chainconfig)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		vmenv.config = config
+	}
 
 	tstart := time.Now()
 
-	ret, e := vmenv.Call(sender, receiver.Address(), common.Hex2Bytes(*data), common.Big(*gas), common.Big(*price), common.Big(*value))
+	gasLeft := common.Big(*gas)
+	ret, e := vmenv.Call(from, to.Address(), common.FromHex(*data), gasLeft, common.Big(*price), common.Big(*value))
+	gasUsed := new(big.Int).Sub(common.Big(*gas), gasLeft)
 
 	logger.Flush()
+
+	if *jsonOut {
+		statedb.Update()
+		statedb.Sync()
+		printJSON(vmenv.structLogs, ret, gasUsed, e, statedb)
+		if *dump {
+			fmt.Println(string(statedb.Dump()))
+		}
+		return
+	}
+
 	if e != nil {
 		perr(e)
 	}
@@ -94,16 +166,91 @@ num gc:     %d
 	fmt.Printf("%x\n", ret)
 }
 
+// address parses a hex address. If it is empty, the address is derived from
+// name, which keeps the addresses of earlier versions of the tool.
+func address(hex, name string) common.Address {
+	if hex == "" {
+		return common.StringToAddress(name)
+	}
+	return common.HexToAddress(hex)
+}
+
+// prestateAccount is an account of the prestate file.
+type prestateAccount struct {
+	Balance string
+	Code    string
+	Nonce   string
+	Storage map[string]string
+}
+
+// loadPrestate adds the accounts of a prestate file to statedb. The file
+// holds an object mapping addresses to accounts, either at the top level or
+// under "alloc" like a genesis file:
+//
+//	{"alloc": {"0x0000000000000000000000000000000000000001": {
+//		"balance": "0x10", "nonce": "1", "code": "0x6001",
+//		"storage": {"0x00": "0x01"}
+//	}}}
+func loadPrestate(statedb *state.StateDB, file string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var genesis struct {
+		Alloc map[string]prestateAccount
+	}
+	if err := json.Unmarshal(content, &genesis); err != nil {
+		return fmt.Errorf("invalid prestate %s: %v", file, err)
+	}
+	accounts := genesis.Alloc
+	if accounts == nil {
+		if err := json.Unmarshal(content, &accounts); err != nil {
+			return fmt.Errorf("invalid prestate %s: %v", file, err)
+		}
+	}
+
+	for addr, account := range accounts {
+		obj := statedb.CreateAccount(common.HexToAddress(addr))
+		obj.SetBalance(common.Big(account.Balance))
+		obj.SetNonce(common.Big(account.Nonce).Uint64())
+		obj.SetCode(common.FromHex(account.Code))
+		for key, val := range account.Storage {
+			obj.SetState(common.HexToHash(key), common.NewValue(common.FromHex(val)))
+		}
+	}
+	return nil
+}
+
+// readCode reads the code in file, which is assembled if the file name ends
+// in .easm and hex encoded otherwise.
+func readCode(file string) ([]byte, error) {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(file, ".easm") {
+		return asm.Assemble(string(src))
+	}
+	return common.FromHex(strings.TrimSpace(string(src))), nil
+}
+
 type VMEnv struct {
 	state *state.StateDB
-	block *types.Block
 
 	transactor *common.Address
 	value      *big.Int
 
 	depth int
 	Gas   *big.Int
-	time  int64
+
+	number     *big.Int
+	time       int64
+	coinbase   common.Address
+	difficulty *big.Int
+	gasLimit   *big.Int
+	config     *params.ChainConfig
+
+	structLogs []vm.StructLog
 }
 
 func NewEnv(state *state.StateDB, transactor common.Address, value *big.Int) *VMEnv {
@@ -111,31 +258,42 @@ func NewEnv(state *state.StateDB, transactor common.Address, value *big.Int) *VM
 		state:      state,
 		transactor: &transactor,
 		value:      value,
+		number:     common.Big0,
 		time:       time.Now().Unix(),
+		coinbase:   transactor,
+		difficulty: common.Big1,
+		gasLimit:   big.NewInt(1000000000),
+		config:     params.DefaultChainConfig,
 	}
 }
 
 func (self *VMEnv) State() *state.StateDB    { return self.state }
 func (self *VMEnv) Origin() common.Address   { return *self.transactor }
-func (self *VMEnv) BlockNumber() *big.Int    { return common.Big0 }
-func (self *VMEnv) Coinbase() common.Address { return *self.transactor }
+func (self *VMEnv) BlockNumber() *big.Int    { return self.number }
+func (self *VMEnv) Coinbase() common.Address { return self.coinbase }
 func (self *VMEnv) Time() int64              { return self.time }
-func (self *VMEnv) Difficulty() *big.Int     { return common.Big1 }
-func (self *VMEnv) BlockHash() []byte        { return make([]byte, 32) }
+func (self *VMEnv) Difficulty() *big.Int     { return self.difficulty }
 func (self *VMEnv) Value() *big.Int          { return self.value }
-func (self *VMEnv) GasLimit() *big.Int       { return big.NewInt(1000000000) }
+func (self *VMEnv) GasLimit() *big.Int       { return self.gasLimit }
+func (self *VMEnv) Rules() *params.RuleSet   { return self.config.Rules(self.number) }
 func (self *VMEnv) VmType() vm.Type          { return vm.StdVmTy }
-func (self *VMEnv) Depth() int               { return 0 }
+func (self *VMEnv) Depth() int               { return self.depth }
 func (self *VMEnv) SetDepth(i int)           { self.depth = i }
+
+// GetHash returns a made up hash for the blocks before the current one, there
+// is no chain to take them from.
 func (self *VMEnv) GetHash(n uint64) common.Hash {
-	if self.block.Number().Cmp(big.NewInt(int64(n))) == 0 {
-		return self.block.Hash()
+	if new(big.Int).SetUint64(n).Cmp(self.number) >= 0 {
+		return common.Hash{}
 	}
-	return common.Hash{}
+	return common.BytesToHash(crypto.Sha3([]byte(new(big.Int).SetUint64(n).String())))
 }
 func (self *VMEnv) AddLog(log *state.Log) {
 	self.state.AddLog(log)
 }
+func (self *VMEnv) AddStructLog(log vm.StructLog) {
+	self.structLogs = append(self.structLogs, log)
+}
 func (self *VMEnv) Transfer(from, to vm.Account, amount *big.Int) error {
 	return vm.Transfer(from, to, amount)
 }
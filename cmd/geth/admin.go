@@ -2,11 +2,14 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/poa"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/logger/glog"
@@ -29,7 +32,9 @@ func (js *jsre) adminBindings() {
 	admin.Set("nodeInfo", js.nodeInfo)
 	admin.Set("peers", js.peers)
 	admin.Set("newAccount", js.newAccount)
+	admin.Set("updateAccount", js.updateAccount)
 	admin.Set("unlock", js.unlock)
+	admin.Set("unlockPolicy", js.unlockPolicy)
 	admin.Set("import", js.importChain)
 	admin.Set("export", js.exportChain)
 	admin.Set("verbosity", js.verbosity)
@@ -42,6 +47,9 @@ func (js *jsre) adminBindings() {
 	miner.Set("stop", js.stopMining)
 	miner.Set("hashrate", js.hashrate)
 	miner.Set("setExtra", js.setExtra)
+	miner.Set("signers", js.signers)
+	miner.Set("propose", js.propose)
+	miner.Set("discard", js.discard)
 
 	admin.Set("debug", struct{}{})
 	t, _ = admin.Get("debug")
@@ -66,6 +74,68 @@ func (js *jsre) setExtra(call otto.FunctionCall) otto.Value {
 	return otto.UndefinedValue()
 }
 
+// authority returns the proof of authority engine of the chain, or nil if
+// the chain is sealed differently.
+func (js *jsre) authority() *poa.Authority {
+	engine, ok := js.ethereum.BlockProcessor().Engine().(*poa.Authority)
+	if !ok {
+		fmt.Println("error: the chain is not sealed by authorised signers")
+		return nil
+	}
+	return engine
+}
+
+func (js *jsre) signers(otto.FunctionCall) otto.Value {
+	engine := js.authority()
+	if engine == nil {
+		return otto.UndefinedValue()
+	}
+	chain := js.ethereum.ChainManager()
+	signers, err := engine.Signers(chain, chain.CurrentBlock().Header())
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	addrs := make([]string, len(signers))
+	for i, signer := range signers {
+		addrs[i] = signer.Hex()
+	}
+	return js.re.ToVal(addrs)
+}
+
+func (js *jsre) propose(call otto.FunctionCall) otto.Value {
+	engine := js.authority()
+	if engine == nil {
+		return otto.FalseValue()
+	}
+	addr, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	authorize, err := call.Argument(1).ToBoolean()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	engine.Propose(common.HexToAddress(addr), authorize)
+	return otto.TrueValue()
+}
+
+func (js *jsre) discard(call otto.FunctionCall) otto.Value {
+	engine := js.authority()
+	if engine == nil {
+		return otto.FalseValue()
+	}
+	addr, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	engine.Discard(common.HexToAddress(addr))
+	return otto.TrueValue()
+}
+
 func (js *jsre) hashrate(otto.FunctionCall) otto.Value {
 	return js.re.ToVal(js.ethereum.Miner().HashRate())
 }
@@ -186,7 +256,19 @@ func (js *jsre) unlock(call otto.FunctionCall) otto.Value {
 	// if err != nil {
 	// 	utils.Fatalf("Unlock account failed '%v'", err)
 	// }
-	err = am.TimedUnlock(common.FromHex(addr), passphrase, time.Duration(seconds)*time.Second)
+	if arg := call.Argument(3); arg.IsObject() {
+		policy, err := toUnlockPolicy(arg.Object())
+		if err != nil {
+			fmt.Println(err)
+			return otto.FalseValue()
+		}
+		if seconds > 0 {
+			policy.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+		}
+		err = am.UnlockWithPolicy(common.FromHex(addr), passphrase, policy)
+	} else {
+		err = am.TimedUnlock(common.FromHex(addr), passphrase, time.Duration(seconds)*time.Second)
+	}
 	if err != nil {
 		fmt.Printf("Unlock account failed '%v'\n", err)
 		return otto.FalseValue()
@@ -194,6 +276,76 @@ func (js *jsre) unlock(call otto.FunctionCall) otto.Value {
 	return otto.TrueValue()
 }
 
+// toUnlockPolicy converts an object of the form
+// {maxSignatures: 10, maxValue: "1000000000000000000", to: ["0x..."]}
+// to an unlock policy. All fields are optional.
+func toUnlockPolicy(obj *otto.Object) (accounts.UnlockPolicy, error) {
+	var policy accounts.UnlockPolicy
+	if v, _ := obj.Get("maxSignatures"); v.IsDefined() {
+		n, err := v.ToInteger()
+		if err != nil || n <= 0 {
+			return policy, fmt.Errorf("invalid maxSignatures %v", v)
+		}
+		policy.MaxSignatures = int(n)
+	}
+	if v, _ := obj.Get("maxValue"); v.IsDefined() {
+		str, _ := v.ToString()
+		value, ok := new(big.Int).SetString(str, 0)
+		if !ok || value.Sign() < 0 {
+			return policy, fmt.Errorf("invalid maxValue %v", v)
+		}
+		policy.MaxValue = value
+	}
+	if v, _ := obj.Get("to"); v.IsDefined() {
+		list, _ := v.Export()
+		addrs, ok := list.([]interface{})
+		if !ok {
+			return policy, fmt.Errorf("to must be a list of addresses")
+		}
+		for _, addr := range addrs {
+			str, ok := addr.(string)
+			if !ok || len(common.FromHex(str)) != 20 {
+				return policy, fmt.Errorf("invalid address %v", addr)
+			}
+			policy.To = append(policy.To, common.FromHex(str))
+		}
+	}
+	return policy, nil
+}
+
+func (js *jsre) unlockPolicy(call otto.FunctionCall) otto.Value {
+	addr, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	status, err := js.ethereum.AccountManager().Policy(common.FromHex(addr))
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	if status == nil {
+		return otto.NullValue()
+	}
+	to := make([]string, len(status.To))
+	for i, addr := range status.To {
+		to[i] = common.ToHex(addr)
+	}
+	policy := map[string]interface{}{
+		"maxSignatures": status.MaxSignatures,
+		"to":            to,
+		"signatures":    status.Signatures,
+		"value":         status.Value.String(),
+	}
+	if status.MaxValue != nil {
+		policy["maxValue"] = status.MaxValue.String()
+	}
+	if !status.Expiry.IsZero() {
+		policy["expiry"] = status.Expiry.Unix()
+	}
+	return js.re.ToVal(policy)
+}
+
 func (js *jsre) newAccount(call otto.FunctionCall) otto.Value {
 	arg := call.Argument(0)
 	var passphrase string
@@ -228,6 +380,50 @@ func (js *jsre) newAccount(call otto.FunctionCall) otto.Value {
 	return js.re.ToVal(common.Bytes2Hex(acct.Address))
 }
 
+func (js *jsre) updateAccount(call otto.FunctionCall) otto.Value {
+	addr, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	var oldPassphrase, newPassphrase string
+	if call.Argument(1).IsUndefined() || call.Argument(2).IsUndefined() {
+		fmt.Println("Please enter the current passphrase now.")
+		oldPassphrase, err = readPassword("Passphrase: ", true)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		fmt.Println("Please enter a new passphrase now.")
+		newPassphrase, err = readPassword("New Passphrase: ", true)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		confirm, err := readPassword("Repeat New Passphrase: ", false)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		if newPassphrase != confirm {
+			fmt.Println("Passphrases did not match.")
+			return otto.FalseValue()
+		}
+	} else {
+		if oldPassphrase, err = call.Argument(1).ToString(); err != nil {
+			fmt.Println(err)
+			return otto.FalseValue()
+		}
+		if newPassphrase, err = call.Argument(2).ToString(); err != nil {
+			fmt.Println(err)
+			return otto.FalseValue()
+		}
+	}
+	am := js.ethereum.AccountManager()
+	if err := am.Update(common.FromHex(addr), oldPassphrase, newPassphrase); err != nil {
+		fmt.Printf("Could not update the account: %v\n", err)
+		return otto.FalseValue()
+	}
+	return otto.TrueValue()
+}
+
 func (js *jsre) nodeInfo(call otto.FunctionCall) otto.Value {
 	return js.re.ToVal(js.ethereum.NodeInfo())
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/miner"
+)
+
+// devBalance is the genesis balance of the developer account.
+var devBalance = new(big.Int).Mul(big.NewInt(1000000), common.Ether)
+
+// dev runs a throwaway chain for dapp development: it lives in a temporary
+// data directory which is removed on exit, the genesis block funds a new
+// developer account which is unlocked, and blocks are sealed without proof
+// of work as soon as transactions arrive. RPC and the console are started.
+func dev(ctx *cli.Context) {
+	utils.HandleInterrupt()
+	dir, err := ioutil.TempDir("", "geth-dev")
+	if err != nil {
+		utils.Fatalf("Could not create data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// utils.Fatalf exits without running deferred calls
+	fatalf := func(format string, args ...interface{}) {
+		os.RemoveAll(dir)
+		utils.Fatalf(format, args...)
+	}
+
+	// the passphrase is empty, the key is thrown away with the chain
+	am := accounts.NewManager(crypto.NewKeyStorePassphrase(path.Join(dir, "keys"), crypto.LightScryptN, crypto.LightScryptP))
+	account, err := am.NewAccount("")
+	if err != nil {
+		fatalf("Could not create developer account: %v", err)
+	}
+	if err := am.Unlock(account.Address, ""); err != nil {
+		fatalf("Could not unlock developer account: %v", err)
+	}
+	developer := common.BytesToAddress(account.Address)
+
+	cfg := utils.MakeEthConfig(ClientIdentifier, Version, ctx)
+	cfg.DataDir = dir
+	cfg.AccountManager = am
+	cfg.Etherbase = developer.Hex()
+	cfg.Engine = consensus.NewInstant()
+	cfg.MinerThreads = 0
+	cfg.Dial = false // stay off the network
+	cfg.NAT = nil
+	cfg.Port = "0"
+	ethereum, err := eth.New(cfg)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	ethereum.ResetWithGenesisBlock(core.DevGenesisBlock(ethereum.StateDb(), core.GenesisAccount{Address: developer, Balance: devBalance}))
+
+	period := time.Duration(ctx.GlobalInt(utils.DevPeriodFlag.Name)) * time.Second
+	ethereum.Miner().Register(miner.NewDevAgent(ethereum.BlockProcessor().Engine(), ethereum.ChainManager(), period))
+
+	utils.StartEthereum(ethereum)
+	// registered after the node's own handler, which stops it first
+	utils.RegisterInterrupt(func(os.Signal) { os.RemoveAll(dir) })
+	utils.StartRPC(ethereum, ctx)
+	if err := ethereum.StartMining(); err != nil {
+		fatalf("Could not start sealing: %v", err)
+	}
+
+	fmt.Printf("Developer mode, data directory %s (removed on exit)\n", dir)
+	fmt.Printf("Developer account %s holds %v ether and is unlocked\n", developer.Hex(), new(big.Int).Div(devBalance, common.Ether))
+	fmt.Printf("RPC listening on %s:%d\n", ctx.GlobalString(utils.RPCListenAddrFlag.Name), ctx.GlobalInt(utils.RPCPortFlag.Name))
+
+	repl := newJSRE(ethereum, ctx.String(utils.JSpathFlag.Name), true)
+	repl.interactive()
+
+	ethereum.Stop()
+	ethereum.WaitForShutdown()
+}
@@ -136,6 +136,27 @@ var net = web3.net;
 		utils.Fatalf("Error setting namespaces: %v", err)
 	}
 
+	// methods not known to ethereum.js are sent to the API directly.
+	_, err = js.re.Eval(`
+var personal = {};
+(function() {
+	var send = function(method, params) {
+		var response = jeth.send({ jsonrpc: "2.0", id: 0, method: method, params: params });
+		if (response.error) {
+			throw new Error(response.error.message);
+		}
+		return response.result;
+	};
+	eth.sign = function(address, data) { return send("eth_sign", [address, data]); };
+	eth.signTransaction = function(tx) { return send("eth_signTransaction", [tx]); };
+	eth.sendRawTransaction = function(tx) { return send("eth_sendRawTransaction", [tx]); };
+	personal.ecRecover = function(data, sig) { return send("personal_ecRecover", [data, sig]); };
+})();
+  `)
+	if err != nil {
+		utils.Fatalf("Error defining API methods: %v", err)
+	}
+
 	js.re.Eval(globalRegistrar + "registrar = new GlobalRegistrar(\"" + globalRegistrarAddr + "\");")
 }
 
@@ -10,8 +10,11 @@ import (
 	"github.com/robertkrimen/otto"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var port = 30300
@@ -159,6 +162,75 @@ func TestAccounts(t *testing.T) {
 		t.Errorf("expected addrs == [<default>, <new>], got %v (%v)", addrs, addr)
 	}
 
+	val, err = repl.re.Run(`admin.updateAccount("` + addr + `", "password", "newpassword")`)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if updated, _ := val.ToBoolean(); !updated {
+		t.Errorf("expected account update to succeed, got %v", val)
+	}
+
+	val, err = repl.re.Run(`admin.unlock("` + addr + `", "newpassword", 60, {maxSignatures: 3, maxValue: "1000", to: ["0x` + addr + `"]})`)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if unlocked, _ := val.ToBoolean(); !unlocked {
+		t.Errorf("expected unlock with policy to succeed, got %v", val)
+	}
+	val, err = repl.re.Run(`JSON.stringify(admin.unlockPolicy("` + addr + `"), ["maxSignatures", "maxValue", "to", "signatures", "value"])`)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	policy, _ := val.ToString()
+	want := `{"maxSignatures":3,"maxValue":"1000","signatures":0,"to":["0x` + addr + `"],"value":"0"}`
+	if policy != want {
+		t.Errorf("incorrect unlock policy, expected %s, got %s", want, policy)
+	}
+
+	val, err = repl.re.Run(`admin.unlock("e273f01c99144c438695e10f24926dc1f9fbf62d", "", 60)`)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	val, err = repl.re.Run(`personal.ecRecover("0x1234", eth.sign("0xe273f01c99144c438695e10f24926dc1f9fbf62d", "0x1234"))`)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if signer, _ := val.ToString(); signer != "0xe273f01c99144c438695e10f24926dc1f9fbf62d" {
+		t.Errorf("incorrect signer, expected 0xe273f01c99144c438695e10f24926dc1f9fbf62d, got %v", val)
+	}
+
+	val, err = repl.re.Run(`eth.signTransaction({from: "0xe273f01c99144c438695e10f24926dc1f9fbf62d", to: "0x` + addr + `", value: "0x10"})`)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	enc, _ := val.ToString()
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(enc), tx); err != nil {
+		t.Fatalf("could not decode signed transaction %q: %v", enc, err)
+	}
+	if from, _ := tx.From(); from != common.HexToAddress("0xe273f01c99144c438695e10f24926dc1f9fbf62d") {
+		t.Errorf("incorrect sender, expected 0xe273f01c99144c438695e10f24926dc1f9fbf62d, got %x", from)
+	}
+	if to := tx.To(); to == nil || *to != common.HexToAddress(addr) || tx.Value().Int64() != 16 {
+		t.Errorf("incorrect transaction %v", tx)
+	}
+	if pending := ethereum.TxPool().GetTransactions(); len(pending) != 0 {
+		t.Errorf("signed transaction was submitted: %v", pending)
+	}
+	// the transaction is decoded and validated by the pool, which rejects
+	// it because the test account has no funds
+	for input, want := range map[string]string{
+		enc:      "Account does not exist",
+		"0x1234": "invalid transaction: rlp: expected input list for types.Transaction",
+	} {
+		val, err = repl.re.Run(`try { eth.sendRawTransaction("` + input + `") } catch (e) { e.message }`)
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+		if msg, _ := val.ToString(); msg != want {
+			t.Errorf("incorrect error for %s, expected %q, got %q", input, want, msg)
+		}
+	}
 }
 
 func TestBlockChain(t *testing.T) {
@@ -22,9 +22,11 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"net/http"
 	"os"
 	"runtime"
@@ -39,8 +41,11 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/xeth"
 	"github.com/peterh/liner"
 	"path"
 )
@@ -96,7 +101,8 @@ The output of this command is supposed to be machine-readable.
 			Description: `
 
 Manage accounts lets you create new accounts, list all existing accounts,
-import a private key into a new account.
+import a private key into a new account, change the password of an account
+and back up or restore the key files.
 
 It supports interactive mode, when you are prompted for password as well as
 non-interactive mode where passwords are supplied via a given password file.
@@ -170,6 +176,84 @@ this import mechanism is not needed when you transfer an account between
 nodes.
 					`,
 				},
+				{
+					Action: accountUpdate,
+					Name:   "update",
+					Usage:  "change the passphrase of an existing account",
+					Description: `
+
+    ethereum account update <address>
+
+Re-encrypts the key of the account with a new passphrase. You are prompted for
+the current passphrase and the new one. For non-interactive use, the current
+passphrase is read from the --password file and the new one from the
+--newpassword file.
+
+The new key file is written completely before it replaces the old one, so the
+key is not lost if the update is interrupted.
+
+For accounts of an HD wallet, the passphrase of the whole wallet is changed.
+					`,
+				},
+				{
+					Action: accountBackup,
+					Name:   "backup",
+					Usage:  "copy all encrypted key files to a backup directory",
+					Description: `
+
+    ethereum account backup <dir>
+
+Copies every key file to <dir>, keeping them encrypted with their passphrases,
+and writes a manifest listing the files, the addresses of their accounts and
+the checksums of their contents.
+
+The manifest is written last. A directory that already holds a backup is not
+overwritten.
+					`,
+				},
+				{
+					Action: accountRestore,
+					Name:   "restore",
+					Usage:  "restore key files from a backup directory",
+					Description: `
+
+    ethereum account restore <dir>
+
+Copies the key files listed in the manifest of a backup made with
+'account backup' into the key store. The checksums of all files are verified
+first. Keys that are already in the key store are left untouched.
+					`,
+				},
+				{
+					Action: accountSignTx,
+					Name:   "sign-tx",
+					Usage:  "sign a transaction described in a JSON file",
+					Description: `
+
+    ethereum account sign-tx <file>
+
+Signs the transaction described in <file> with the key of its sender and
+prints the RLP encoding of the signed transaction, which can be submitted
+later with eth_sendRawTransaction. No node is started, so this command can
+be used on a machine that is not connected to the network.
+
+The description is a JSON object of the form
+
+    {
+      "from":     "0x...",
+      "to":       "0x...",
+      "nonce":    0,
+      "value":    "1000000000000000000",
+      "gas":      "21000",
+      "gasPrice": "50000000000",
+      "data":     "0x..."
+    }
+
+"from" and "nonce" are required. Amounts are decimal or 0x prefixed hex
+strings. Without "to", the transaction creates a contract. Gas and gas price
+default to the values used by eth_sendTransaction.
+					`,
+				},
 			},
 		},
 		{
@@ -219,9 +303,16 @@ JavaScript API. See https://github.com/ethereum/go-ethereum/wiki/Javascipt-Conso
 	app.Flags = []cli.Flag{
 		utils.UnlockedAccountFlag,
 		utils.PasswordFileFlag,
+		utils.NewPasswordFileFlag,
 		utils.BootnodesFlag,
 		utils.DataDirFlag,
 		utils.BlockchainVersionFlag,
+		utils.FastSyncFlag,
+		utils.LightServFlag,
+		utils.LightModeFlag,
+		utils.ChainConfigFlag,
+		utils.DevModeFlag,
+		utils.DevPeriodFlag,
 		utils.JSpathFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
@@ -273,6 +364,10 @@ func main() {
 }
 
 func run(ctx *cli.Context) {
+	if ctx.GlobalBool(utils.DevModeFlag.Name) {
+		dev(ctx)
+		return
+	}
 	utils.HandleInterrupt()
 	cfg := utils.MakeEthConfig(ClientIdentifier, Version, ctx)
 	ethereum, err := eth.New(cfg)
@@ -286,6 +381,10 @@ func run(ctx *cli.Context) {
 }
 
 func console(ctx *cli.Context) {
+	if ctx.GlobalBool(utils.DevModeFlag.Name) {
+		dev(ctx)
+		return
+	}
 	cfg := utils.MakeEthConfig(ClientIdentifier, Version, ctx)
 	ethereum, err := eth.New(cfg)
 	if err != nil {
@@ -369,7 +468,12 @@ func accountList(ctx *cli.Context) {
 }
 
 func getPassPhrase(ctx *cli.Context, desc string, confirmation bool) (passphrase string) {
-	passfile := ctx.GlobalString(utils.PasswordFileFlag.Name)
+	return readPassPhrase(ctx.GlobalString(utils.PasswordFileFlag.Name), desc, confirmation)
+}
+
+// readPassPhrase reads a passphrase from passfile, or prompts for it if no
+// file is given.
+func readPassPhrase(passfile string, desc string, confirmation bool) (passphrase string) {
 	if len(passfile) == 0 {
 		fmt.Println(desc)
 		auth, err := readPassword("Passphrase: ", true)
@@ -441,6 +545,130 @@ func accountImport(ctx *cli.Context) {
 	fmt.Printf("Address: %x\n", acct)
 }
 
+func accountUpdate(ctx *cli.Context) {
+	account := ctx.Args().First()
+	addr := common.FromHex(account)
+	if len(addr) == 0 {
+		utils.Fatalf("Invalid account address '%s'", account)
+	}
+	// the new passphrase can't come from the file holding the old one
+	oldfile, newfile := ctx.GlobalString(utils.PasswordFileFlag.Name), ctx.GlobalString(utils.NewPasswordFileFlag.Name)
+	if len(oldfile) > 0 && len(newfile) == 0 {
+		utils.Fatalf("--%s must be given along with --%s", utils.NewPasswordFileFlag.Name, utils.PasswordFileFlag.Name)
+	}
+	am := utils.GetAccountManager(ctx)
+	oldPassphrase := readPassPhrase(oldfile, "Please give the current password of the account.", false)
+	newPassphrase := readPassPhrase(newfile, "Please give a new password. Do not forget this password.", true)
+	if err := am.Update(addr, oldPassphrase, newPassphrase); err != nil {
+		utils.Fatalf("Could not update the account: %v", err)
+	}
+}
+
+func accountBackup(ctx *cli.Context) {
+	dir := ctx.Args().First()
+	if len(dir) == 0 {
+		utils.Fatalf("backup directory must be given as argument")
+	}
+	manifest, err := crypto.BackupKeyFiles(utils.GetKeyStoreDir(ctx), dir)
+	if err != nil {
+		utils.Fatalf("Could not back up the keys: %v", err)
+	}
+	for _, file := range manifest.Files {
+		for _, addr := range file.Addresses {
+			fmt.Printf("Address: %s\n", addr)
+		}
+	}
+	fmt.Printf("Backed up %d key files to %s\n", len(manifest.Files), dir)
+}
+
+func accountRestore(ctx *cli.Context) {
+	dir := ctx.Args().First()
+	if len(dir) == 0 {
+		utils.Fatalf("backup directory must be given as argument")
+	}
+	files, err := crypto.RestoreKeyFiles(dir, utils.GetKeyStoreDir(ctx))
+	if err != nil {
+		utils.Fatalf("Could not restore the keys: %v", err)
+	}
+	for _, file := range files {
+		for _, addr := range file.Addresses {
+			fmt.Printf("Address: %s\n", addr)
+		}
+	}
+	fmt.Printf("Restored %d key files from %s\n", len(files), dir)
+}
+
+// txDescription is the JSON description of a transaction to sign with
+// 'account sign-tx'.
+type txDescription struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Nonce    *uint64 `json:"nonce"`
+	Value    string  `json:"value"`
+	Gas      string  `json:"gas"`
+	GasPrice string  `json:"gasPrice"`
+	Data     string  `json:"data"`
+}
+
+func accountSignTx(ctx *cli.Context) {
+	file := ctx.Args().First()
+	if len(file) == 0 {
+		utils.Fatalf("transaction file must be given as argument")
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		utils.Fatalf("Could not read transaction file: %v", err)
+	}
+	var desc txDescription
+	if err := json.Unmarshal(content, &desc); err != nil {
+		utils.Fatalf("Invalid transaction file: %v", err)
+	}
+	from := common.FromHex(desc.From)
+	if len(from) != 20 {
+		utils.Fatalf("Invalid sender address '%s'", desc.From)
+	}
+	if desc.Nonce == nil {
+		utils.Fatalf("The nonce of the transaction must be given")
+	}
+	amount := func(name, str string) string {
+		if len(str) == 0 {
+			return "0"
+		}
+		n, ok := new(big.Int).SetString(str, 0)
+		if !ok || n.Sign() < 0 {
+			utils.Fatalf("Invalid %s '%s'", name, str)
+		}
+		return n.String()
+	}
+	if len(desc.To) > 0 && len(common.FromHex(desc.To)) != 20 {
+		utils.Fatalf("Invalid recipient address '%s'", desc.To)
+	}
+	tx := xeth.NewTransaction(desc.To, amount("value", desc.Value), amount("gas", desc.Gas), amount("gas price", desc.GasPrice), desc.Data)
+	tx.SetNonce(*desc.Nonce)
+
+	am := utils.GetAccountManager(ctx)
+	passphrase := getPassPhrase(ctx, "Please give the password of the sender account.", false)
+	if err := am.Unlock(from, passphrase); err != nil {
+		utils.Fatalf("Unlock account failed '%v'", err)
+	}
+	var to []byte
+	if tx.To() != nil {
+		to = tx.To().Bytes()
+	}
+	sig, err := am.SignTx(accounts.Account{Address: from}, to, tx.Value(), tx.Gas(), tx.GasPrice(), tx.Hash().Bytes())
+	if err != nil {
+		utils.Fatalf("Could not sign the transaction: %v", err)
+	}
+	if err := tx.SetSignatureValues(sig); err != nil {
+		utils.Fatalf("Could not sign the transaction: %v", err)
+	}
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		utils.Fatalf("Could not encode the transaction: %v", err)
+	}
+	fmt.Println(common.ToHex(enc))
+}
+
 func importchain(ctx *cli.Context) {
 	if len(ctx.Args()) != 1 {
 		utils.Fatalf("This command requires an argument.")
@@ -526,6 +754,8 @@ func upgradeDb(ctx *cli.Context) {
 
 	os.RemoveAll(path.Join(ctx.GlobalString(utils.DataDirFlag.Name), "blockchain"))
 
+	// the chain config was stored in the removed database
+	cfg.ChainConfig = ethereum.ChainManager().Config()
 	ethereum, err = eth.New(cfg)
 	if err != nil {
 		utils.Fatalf("%v\n", err)
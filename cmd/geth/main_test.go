@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestAccountUpdatePasswordFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geth-account-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	am := accounts.NewManager(crypto.NewKeyStorePassphrase(path.Join(dir, "keys"), crypto.StandardScryptN, crypto.StandardScryptP))
+	account, err := am.NewAccount("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldfile, newfile := path.Join(dir, "old.txt"), path.Join(dir, "new.txt")
+	ioutil.WriteFile(oldfile, []byte("old"), 0600)
+	ioutil.WriteFile(newfile, []byte("new"), 0600)
+
+	args := []string{"geth", "--datadir", dir, "--password", oldfile, "--newpassword", newfile, "account", "update", hex.EncodeToString(account.Address)}
+	if err := app.Run(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(account.Address, "new"); err != nil {
+		t.Errorf("new passphrase doesn't unlock the key: %v", err)
+	}
+	if err := am.Unlock(account.Address, "old"); err == nil {
+		t.Errorf("old passphrase still unlocks the key")
+	}
+}
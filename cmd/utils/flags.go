@@ -10,6 +10,7 @@ import (
 	"github.com/ethereum/ethash"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
@@ -18,6 +19,7 @@ import (
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/xeth"
 )
@@ -89,6 +91,32 @@ var (
 		Usage: "Blockchain version",
 		Value: core.BlockChainVersion,
 	}
+	FastSyncFlag = cli.BoolFlag{
+		Name:  "fast",
+		Usage: "Fast sync an empty chain by downloading the state of a recent block instead of processing all blocks",
+	}
+	LightServFlag = cli.BoolFlag{
+		Name:  "lightserv",
+		Usage: "Serve light nodes over the les protocol",
+	}
+	LightModeFlag = cli.BoolFlag{
+		Name:  "light",
+		Usage: "Run as a light node, keeping only block headers and retrieving state on demand",
+	}
+	ChainConfigFlag = cli.StringFlag{
+		Name:  "chainconfig",
+		Usage: "JSON file with the consensus rules of the chain, or a genesis file holding them under \"config\" (default: the rules stored with the chain, or the main chain rules)",
+	}
+
+	// developer mode
+	DevModeFlag = cli.BoolFlag{
+		Name:  "dev",
+		Usage: "Developer mode: a throwaway chain with an unlocked, pre-funded account, sealing blocks for new transactions without proof of work, with RPC and the console enabled",
+	}
+	DevPeriodFlag = cli.IntFlag{
+		Name:  "devperiod",
+		Usage: "Seconds between empty blocks in developer mode (0 = only seal blocks with transactions)",
+	}
 
 	// miner settings
 	MinerThreadsFlag = cli.IntFlag{
@@ -116,6 +144,11 @@ var (
 		Usage: "Path to password file for (un)locking an existing account.",
 		Value: "",
 	}
+	NewPasswordFileFlag = cli.StringFlag{
+		Name:  "newpassword",
+		Usage: "Path to password file with the new password for 'account update'.",
+		Value: "",
+	}
 
 	// logging and debug settings
 	LogFileFlag = cli.StringFlag{
@@ -248,6 +281,10 @@ func MakeEthConfig(clientID, version string, ctx *cli.Context) *eth.Config {
 		ProtocolVersion:    ctx.GlobalInt(ProtocolVersionFlag.Name),
 		BlockChainVersion:  ctx.GlobalInt(BlockchainVersionFlag.Name),
 		SkipBcVersionCheck: false,
+		FastSync:           ctx.GlobalBool(FastSyncFlag.Name),
+		LightServ:          ctx.GlobalBool(LightServFlag.Name),
+		LightMode:          ctx.GlobalBool(LightModeFlag.Name),
+		ChainConfig:        GetChainConfig(ctx),
 		NetworkId:          ctx.GlobalInt(NetworkIdFlag.Name),
 		LogFile:            ctx.GlobalString(LogFileFlag.Name),
 		LogLevel:           ctx.GlobalInt(LogLevelFlag.Name),
@@ -266,6 +303,20 @@ func MakeEthConfig(clientID, version string, ctx *cli.Context) *eth.Config {
 	}
 }
 
+// GetChainConfig returns the consensus rules set by ChainConfigFlag, or nil
+// if the flag is not set and the rules stored with the chain apply.
+func GetChainConfig(ctx *cli.Context) *params.ChainConfig {
+	file := ctx.GlobalString(ChainConfigFlag.Name)
+	if file == "" {
+		return nil
+	}
+	config, err := params.LoadChainConfig(file)
+	if err != nil {
+		Fatalf("Option %q: %v", ChainConfigFlag.Name, err)
+	}
+	return config
+}
+
 func GetChain(ctx *cli.Context) (*core.ChainManager, common.Database, common.Database) {
 	dataDir := ctx.GlobalString(DataDirFlag.Name)
 
@@ -285,18 +336,26 @@ func GetChain(ctx *cli.Context) (*core.ChainManager, common.Database, common.Dat
 	}
 
 	eventMux := new(event.TypeMux)
-	chainManager := core.NewChainManager(blockDb, stateDb, eventMux)
+	chainConfig, err := core.SetupChainConfig(blockDb, core.GenesisBlock(stateDb).Hash(), GetChainConfig(ctx))
+	if err != nil {
+		Fatalf("Could not set up chain: %v", err)
+	}
+	chainManager := core.NewChainManager(blockDb, stateDb, chainConfig, eventMux)
 	pow := ethash.New(chainManager)
-	txPool := core.NewTxPool(eventMux, chainManager.State)
-	blockProcessor := core.NewBlockProcessor(stateDb, extraDb, pow, txPool, chainManager, eventMux)
+	txPool := core.NewTxPool(eventMux, chainManager.State, chainManager.PendingRules)
+	blockProcessor := core.NewBlockProcessor(stateDb, extraDb, consensus.NewProofOfWork(pow), txPool, chainManager, eventMux)
 	chainManager.SetProcessor(blockProcessor)
 
 	return chainManager, blockDb, stateDb
 }
 
+// GetKeyStoreDir returns the directory holding the key files.
+func GetKeyStoreDir(ctx *cli.Context) string {
+	return path.Join(ctx.GlobalString(DataDirFlag.Name), "keys")
+}
+
 func GetAccountManager(ctx *cli.Context) *accounts.Manager {
-	dataDir := ctx.GlobalString(DataDirFlag.Name)
-	ks := crypto.NewKeyStorePassphrase(path.Join(dataDir, "keys"))
+	ks := crypto.NewKeyStorePassphrase(GetKeyStoreDir(ctx), crypto.StandardScryptN, crypto.StandardScryptP)
 	return accounts.NewManager(ks)
 }
 
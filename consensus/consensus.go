@@ -0,0 +1,111 @@
+// Package consensus defines the interface of the consensus engines, which
+// decide who may create a block and what makes a block valid beyond its
+// transactions.
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	ErrInvalidNumber = errors.New("block number invalid")
+	ErrFutureBlock   = errors.New("block time is in the future")
+	ErrOldTimestamp  = errors.New("block time stamp equal to previous")
+)
+
+// ChainReader gives an engine access to the chain the blocks it handles are
+// part of.
+type ChainReader interface {
+	// Config returns the consensus rules of the chain.
+	Config() *params.ChainConfig
+	// CurrentBlock returns the head of the chain.
+	CurrentBlock() *types.Block
+	// GetBlock returns the block with the given hash, or nil if it is unknown.
+	GetBlock(hash common.Hash) *types.Block
+	// GetAncestors returns up to length ancestors of block, parent first.
+	GetAncestors(block *types.Block, length int) []*types.Block
+}
+
+// Engine is a consensus engine. The block processor and the miner use it for
+// everything the consensus algorithm decides.
+type Engine interface {
+	// VerifyHeader checks whether header is valid as a child of parent.
+	VerifyHeader(chain ChainReader, header, parent *types.Header) error
+
+	// VerifyUncles checks whether the uncles of block are valid.
+	VerifyUncles(chain ChainReader, block *types.Block) error
+
+	// Prepare sets the consensus fields of header, a new block on top of
+	// parent, before its transactions are applied.
+	Prepare(chain ChainReader, header, parent *types.Header) error
+
+	// Finalize applies the state changes which follow the transactions of
+	// block, such as the block rewards.
+	Finalize(chain ChainReader, statedb *state.StateDB, block *types.Block)
+
+	// Seal creates the proof which makes block valid. It blocks until the
+	// block is sealed, or returns a nil block if stop is closed first.
+	Seal(chain ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error)
+}
+
+// PoW is implemented by the engines based on proof of work.
+type PoW interface {
+	Engine
+
+	// Hashrate returns the number of hashes per second of the sealing
+	// search.
+	Hashrate() int64
+}
+
+// SignerFn signs hash with the key of the account signer.
+type SignerFn func(signer common.Address, hash []byte) ([]byte, error)
+
+// Authorizer is implemented by the engines which seal blocks with the
+// signature of an authorised account instead of a proof of work.
+type Authorizer interface {
+	Engine
+
+	// Authorize sets the account which seals the blocks of this node and
+	// the function signing with its key.
+	Authorize(signer common.Address, sign SignerFn)
+}
+
+// VerifyHeaderFields checks the fields of header which are the same for all
+// engines: the size of the extra data, the gas limit, the block number and
+// the time stamp.
+func VerifyHeaderFields(header, parent *types.Header) error {
+	if big.NewInt(int64(len(header.Extra))).Cmp(params.MaximumExtraDataSize) == 1 {
+		return fmt.Errorf("Block extra data too long (%d)", len(header.Extra))
+	}
+
+	// header.gasLimit - parent.gasLimit <= parent.gasLimit / GasLimitBoundDivisor
+	a := new(big.Int).Sub(header.GasLimit, parent.GasLimit)
+	a.Abs(a)
+	b := new(big.Int).Div(parent.GasLimit, params.GasLimitBoundDivisor)
+	if !(a.Cmp(b) < 0) || (header.GasLimit.Cmp(params.MinGasLimit) == -1) {
+		return fmt.Errorf("GasLimit check failed for block %v (%v > %v)", header.GasLimit, a, b)
+	}
+
+	// Allow future blocks up to 4 seconds
+	if int64(header.Time) > time.Now().Unix()+4 {
+		return ErrFutureBlock
+	}
+
+	if new(big.Int).Sub(header.Number, parent.Number).Cmp(big.NewInt(1)) != 0 {
+		return ErrInvalidNumber
+	}
+
+	if header.Time <= parent.Time {
+		return ErrOldTimestamp
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package consensus
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CalcDifficulty returns the difficulty of block, a child of parent, using
+// the difficulty algorithm of the rules of the block.
+func CalcDifficulty(config *params.ChainConfig, block, parent *types.Header) *big.Int {
+	var diff *big.Int
+	switch config.Rules(block.Number).Difficulty {
+	case params.HomesteadDifficulty:
+		diff = calcDifficultyHomestead(block, parent)
+	default:
+		diff = calcDifficultyFrontier(block, parent)
+	}
+
+	if diff.Cmp(params.MinimumDifficulty) < 0 {
+		return params.MinimumDifficulty
+	}
+
+	return diff
+}
+
+func calcDifficultyFrontier(block, parent *types.Header) *big.Int {
+	diff := new(big.Int)
+
+	adjust := new(big.Int).Div(parent.Difficulty, params.DifficultyBoundDivisor)
+	if big.NewInt(int64(block.Time)-int64(parent.Time)).Cmp(params.DurationLimit) < 0 {
+		diff.Add(parent.Difficulty, adjust)
+	} else {
+		diff.Sub(parent.Difficulty, adjust)
+	}
+
+	return diff
+}
+
+// calcDifficultyHomestead adjusts the difficulty in proportion to the block
+// time: up for blocks within 10 seconds, unchanged for 10 to 19 seconds and
+// down by one step for every further 10 seconds, at most 99 steps.
+//
+//	diff = parent_diff + parent_diff / 2048 * max(1 - (time - parent_time) / 10, -99)
+func calcDifficultyHomestead(block, parent *types.Header) *big.Int {
+	x := int64(1) - (int64(block.Time)-int64(parent.Time))/10
+	if x < -99 {
+		x = -99
+	}
+
+	adjust := new(big.Int).Div(parent.Difficulty, params.DifficultyBoundDivisor)
+	adjust.Mul(adjust, big.NewInt(x))
+
+	return new(big.Int).Add(parent.Difficulty, adjust)
+}
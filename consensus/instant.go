@@ -0,0 +1,39 @@
+package consensus
+
+import (
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Instant is the engine of development chains. Blocks follow the difficulty
+// and reward rules of proof of work, but are sealed at once without a proof,
+// so the miner decides alone when a block is made.
+type Instant struct {
+	rules *ProofOfWork
+}
+
+// NewInstant creates an engine which seals blocks without a proof of work.
+func NewInstant() *Instant {
+	return &Instant{rules: &ProofOfWork{}}
+}
+
+func (self *Instant) VerifyHeader(chain ChainReader, header, parent *types.Header) error {
+	return self.rules.VerifyHeader(chain, header, parent)
+}
+
+func (self *Instant) VerifyUncles(chain ChainReader, block *types.Block) error {
+	return self.rules.VerifyUncles(chain, block)
+}
+
+func (self *Instant) Prepare(chain ChainReader, header, parent *types.Header) error {
+	return self.rules.Prepare(chain, header, parent)
+}
+
+func (self *Instant) Finalize(chain ChainReader, statedb *state.StateDB, block *types.Block) {
+	self.rules.Finalize(chain, statedb, block)
+}
+
+// Seal returns block as it is.
+func (self *Instant) Seal(chain ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	return block, nil
+}
@@ -0,0 +1,461 @@
+// Package poa implements proof of authority, a consensus engine for private
+// networks where a set of authorised signers take turns sealing blocks at a
+// fixed interval. The signers vote on adding and dropping signers in the
+// headers of the blocks they seal.
+//
+// The extra data of a header is 32 bytes of vanity, followed by the vote of
+// its signer and the 65 byte signature of the header. A vote is the 20 byte
+// address voted on and a byte which is 1 to authorise and 0 to drop it. The
+// blocks of every epoch boundary list the current signers instead of a vote
+// and reset all votes.
+package poa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	extraVanity   = 32 // bytes of extra data the sealer may fill freely
+	extraSeal     = 65 // bytes of the signature at the end of the extra data
+	addressLength = 20
+	voteLength    = addressLength + 1 // address voted on and 1 to authorise or 0 to drop it
+
+	// wiggleTime is the delay per signer with which signers out of turn
+	// seal, so that they don't all produce a block at the same time.
+	wiggleTime = 500 * time.Millisecond
+
+	snapshotCache = 128 // number of blocks whose snapshots are kept
+)
+
+var (
+	diffInTurn = big.NewInt(2) // difficulty of blocks sealed in turn, preferred by the total difficulty
+	diffNoTurn = big.NewInt(1) // difficulty of blocks sealed out of turn
+)
+
+var (
+	ErrUnauthorized    = errors.New("signer not authorised")
+	ErrSignedRecently  = errors.New("signer signed one of the recent blocks")
+	ErrUnknownAncestor = errors.New("unknown ancestor")
+	ErrMissingSig      = errors.New("extra data too short for vanity and signature")
+	ErrNoSigner        = errors.New("no signer authorised to seal")
+)
+
+// Authority is the proof of authority engine.
+type Authority struct {
+	config *params.AuthorityConfig
+
+	mu        sync.Mutex
+	snapshots map[common.Hash]*Snapshot
+	proposals map[common.Address]bool // addresses to vote on, true to authorise
+
+	signMu sync.RWMutex
+	signer common.Address
+	signFn consensus.SignerFn
+}
+
+// New creates a proof of authority engine with the given settings. The
+// signers of the config are those of the genesis block.
+func New(config *params.AuthorityConfig) *Authority {
+	if config.Epoch == 0 {
+		cpy := *config
+		cpy.Epoch = params.DefaultAuthorityEpoch
+		config = &cpy
+	}
+	return &Authority{
+		config:    config,
+		snapshots: make(map[common.Hash]*Snapshot),
+		proposals: make(map[common.Address]bool),
+	}
+}
+
+// Authorize sets the account which seals blocks of this node.
+func (self *Authority) Authorize(signer common.Address, sign consensus.SignerFn) {
+	self.signMu.Lock()
+	defer self.signMu.Unlock()
+
+	self.signer = signer
+	self.signFn = sign
+}
+
+// Propose makes the blocks sealed by this node vote on authorising or
+// dropping addr whenever that would change the signers, until the proposal
+// is discarded.
+func (self *Authority) Propose(addr common.Address, authorize bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.proposals[addr] = authorize
+}
+
+// Discard stops voting on addr.
+func (self *Authority) Discard(addr common.Address) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	delete(self.proposals, addr)
+}
+
+// Proposals returns the addresses this node votes on.
+func (self *Authority) Proposals() map[common.Address]bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	proposals := make(map[common.Address]bool, len(self.proposals))
+	for addr, authorize := range self.proposals {
+		proposals[addr] = authorize
+	}
+	return proposals
+}
+
+// Signers returns the signers authorised to seal the children of header.
+func (self *Authority) Signers(chain consensus.ChainReader, header *types.Header) ([]common.Address, error) {
+	snap, err := self.snapshot(chain, header)
+	if err != nil {
+		return nil, err
+	}
+	return snap.Signers, nil
+}
+
+func (self *Authority) checkpoint(number uint64) bool {
+	return number%self.config.Epoch == 0
+}
+
+// VerifyHeader checks the fields common to all engines, the signature and
+// the turn of the signer.
+func (self *Authority) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	if err := consensus.VerifyHeaderFields(header, parent); err != nil {
+		return err
+	}
+	if header.Time < parent.Time+self.config.Period {
+		return fmt.Errorf("block sealed %ds after its parent, the period is %ds", header.Time-parent.Time, self.config.Period)
+	}
+	if (header.MixDigest != common.Hash{}) || header.Nonce != [8]byte{} {
+		return errors.New("mix digest and nonce must be zero")
+	}
+
+	number := header.Number.Uint64()
+	snap, err := self.snapshot(chain, parent)
+	if err != nil {
+		return err
+	}
+	signer, err := ecrecover(header)
+	if err != nil {
+		return err
+	}
+	if !snap.IsSigner(signer) {
+		return ErrUnauthorized
+	}
+	if snap.SignedRecently(number, signer) {
+		return ErrSignedRecently
+	}
+	want := diffNoTurn
+	if snap.InTurn(number, signer) {
+		want = diffInTurn
+	}
+	if header.Difficulty.Cmp(want) != 0 {
+		return fmt.Errorf("difficulty %v, want %v", header.Difficulty, want)
+	}
+
+	vote, signers, err := self.parseExtra(header)
+	if err != nil {
+		return err
+	}
+	if signers != nil && !equalSigners(signers, snap.Signers) {
+		return errors.New("checkpoint lists the wrong signers")
+	}
+	if vote != nil {
+		vote.Signer = signer
+	}
+	self.store(snap.apply(number, header.Hash(), signer, vote, self.checkpoint(number)))
+	return nil
+}
+
+// VerifyUncles fails for any uncle, there is no use for them without proof
+// of work.
+func (self *Authority) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// Prepare sets the difficulty and the time of header and adds a vote, or
+// at epoch boundaries the signers, to its extra data.
+func (self *Authority) Prepare(chain consensus.ChainReader, header, parent *types.Header) error {
+	header.Nonce = [8]byte{}
+	header.MixDigest = common.Hash{}
+
+	number := header.Number.Uint64()
+	snap, err := self.snapshot(chain, parent)
+	if err != nil {
+		return err
+	}
+	self.signMu.RLock()
+	signer := self.signer
+	self.signMu.RUnlock()
+
+	header.Difficulty = new(big.Int).Set(diffNoTurn)
+	if snap.InTurn(number, signer) {
+		header.Difficulty.Set(diffInTurn)
+	}
+	if header.Time < parent.Time+self.config.Period {
+		header.Time = parent.Time + self.config.Period
+	}
+
+	extra := make([]byte, extraVanity, extraVanity+extraSeal)
+	copy(extra, header.Extra)
+	if self.checkpoint(number) {
+		for _, signer := range snap.Signers {
+			extra = append(extra, signer[:]...)
+		}
+	} else {
+		self.mu.Lock()
+		for addr, authorize := range self.proposals {
+			if snap.validVote(addr, authorize) {
+				extra = append(extra, addr[:]...)
+				if authorize {
+					extra = append(extra, 1)
+				} else {
+					extra = append(extra, 0)
+				}
+				break
+			}
+		}
+		self.mu.Unlock()
+	}
+	header.Extra = append(extra, make([]byte, extraSeal)...)
+	return nil
+}
+
+// Finalize does nothing: signers are not rewarded, they only collect the
+// fees of the transactions.
+func (self *Authority) Finalize(chain consensus.ChainReader, statedb *state.StateDB, block *types.Block) {
+}
+
+// Seal signs block with the key of the authorised account once its time
+// has come. Blocks out of turn are sealed with a random delay.
+func (self *Authority) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errors.New("cannot seal the genesis block")
+	}
+	if self.config.Period == 0 && len(block.Transactions()) == 0 {
+		// without a period, blocks are only sealed for transactions
+		return nil, nil
+	}
+
+	self.signMu.RLock()
+	signer, signFn := self.signer, self.signFn
+	self.signMu.RUnlock()
+	if signFn == nil {
+		return nil, ErrNoSigner
+	}
+
+	parent := chain.GetBlock(header.ParentHash)
+	if parent == nil {
+		return nil, ErrUnknownAncestor
+	}
+	snap, err := self.snapshot(chain, parent.Header())
+	if err != nil {
+		return nil, err
+	}
+	if !snap.IsSigner(signer) {
+		return nil, ErrUnauthorized
+	}
+	if snap.SignedRecently(number, signer) {
+		glog.V(logger.Debug).Infof("poa: signed recently, waiting for others to seal block #%d\n", number)
+		return nil, nil
+	}
+
+	delay := time.Unix(int64(header.Time), 0).Sub(time.Now())
+	if !snap.InTurn(number, signer) {
+		wiggle := time.Duration(len(snap.Signers)/2+1) * wiggleTime
+		delay += time.Duration(rand.Int63n(int64(wiggle)))
+	}
+	select {
+	case <-stop:
+		return nil, nil
+	case <-time.After(delay):
+	}
+
+	if len(header.Extra) < extraVanity+extraSeal {
+		return nil, ErrMissingSig
+	}
+	sig, err := signFn(signer, sigHash(header).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	// the extra data may be shared with the block of the worker
+	extra := make([]byte, len(header.Extra))
+	copy(extra, header.Extra)
+	copy(extra[len(extra)-extraSeal:], sig)
+	header.Extra = extra
+	return block, nil
+}
+
+// snapshot returns the state of the authority after header. It is built
+// from the closest known snapshot or checkpoint among the ancestors of
+// header.
+func (self *Authority) snapshot(chain consensus.ChainReader, header *types.Header) (*Snapshot, error) {
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		hash, number := header.Hash(), header.Number.Uint64()
+
+		self.mu.Lock()
+		snap = self.snapshots[hash]
+		self.mu.Unlock()
+		if snap != nil {
+			break
+		}
+
+		switch {
+		case number == 0:
+			signers := make([]common.Address, len(self.config.Signers))
+			for i, signer := range self.config.Signers {
+				signers[i] = common.HexToAddress(signer)
+			}
+			snap = newSnapshot(number, hash, signers)
+		case self.checkpoint(number):
+			signer, err := ecrecover(header)
+			if err != nil {
+				return nil, err
+			}
+			_, signers, err := self.parseExtra(header)
+			if err != nil {
+				return nil, err
+			}
+			snap = newSnapshot(number, hash, signers)
+			snap.Recents[number] = signer
+		default:
+			headers = append(headers, header)
+			parent := chain.GetBlock(header.ParentHash)
+			if parent == nil {
+				return nil, ErrUnknownAncestor
+			}
+			header = parent.Header()
+		}
+	}
+	self.store(snap)
+
+	// replay the blocks after the snapshot, oldest first
+	for i := len(headers) - 1; i >= 0; i-- {
+		header := headers[i]
+		signer, err := ecrecover(header)
+		if err != nil {
+			return nil, err
+		}
+		vote, _, err := self.parseExtra(header)
+		if err != nil {
+			return nil, err
+		}
+		if vote != nil {
+			vote.Signer = signer
+		}
+		number := header.Number.Uint64()
+		snap = snap.apply(number, header.Hash(), signer, vote, self.checkpoint(number))
+		self.store(snap)
+	}
+	return snap, nil
+}
+
+// store caches snap, dropping the snapshots of old blocks.
+func (self *Authority) store(snap *Snapshot) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.snapshots[snap.Hash] = snap
+	for hash, old := range self.snapshots {
+		if old.Number+snapshotCache < snap.Number {
+			delete(self.snapshots, hash)
+		}
+	}
+}
+
+// parseExtra returns the vote of header, or the signers it lists if it is
+// a checkpoint.
+func (self *Authority) parseExtra(header *types.Header) (*Vote, []common.Address, error) {
+	if len(header.Extra) < extraVanity+extraSeal {
+		return nil, nil, ErrMissingSig
+	}
+	data := header.Extra[extraVanity : len(header.Extra)-extraSeal]
+
+	if self.checkpoint(header.Number.Uint64()) {
+		if len(data) == 0 || len(data)%addressLength != 0 {
+			return nil, nil, errors.New("checkpoint without a list of signers")
+		}
+		signers := make([]common.Address, len(data)/addressLength)
+		for i := range signers {
+			signers[i] = common.BytesToAddress(data[i*addressLength : (i+1)*addressLength])
+		}
+		return nil, signers, nil
+	}
+
+	switch {
+	case len(data) == 0:
+		return nil, nil, nil
+	case len(data) != voteLength || data[addressLength] > 1:
+		return nil, nil, errors.New("invalid vote")
+	}
+	vote := &Vote{
+		Address:   common.BytesToAddress(data[:addressLength]),
+		Authorize: data[addressLength] == 1,
+	}
+	return vote, nil, nil
+}
+
+// sigHash returns the hash signed by the signer of header, which is the
+// hash of the header without the signature.
+func sigHash(header *types.Header) common.Hash {
+	cpy := *header
+	if len(cpy.Extra) >= extraSeal {
+		cpy.Extra = cpy.Extra[:len(cpy.Extra)-extraSeal]
+	}
+	return cpy.Hash()
+}
+
+// ecrecover returns the signer of header.
+func ecrecover(header *types.Header) (common.Address, error) {
+	if len(header.Extra) < extraVanity+extraSeal {
+		return common.Address{}, ErrMissingSig
+	}
+	sig := header.Extra[len(header.Extra)-extraSeal:]
+	pub, err := crypto.Ecrecover(sigHash(header).Bytes(), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(pub) == 0 || pub[0] != 4 {
+		return common.Address{}, errors.New("invalid signature")
+	}
+	return common.BytesToAddress(crypto.Sha3(pub[1:])[12:]), nil
+}
+
+func equalSigners(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i][:], b[i][:]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,287 @@
+package poa
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testGenesis is the empty block every test chain starts from.
+var testGenesis = types.NewBlockWithHeader(&types.Header{
+	Number:     big.NewInt(0),
+	Difficulty: big.NewInt(1),
+	GasLimit:   params.GenesisGasLimit,
+})
+
+// testChain is an in-memory chain of blocks without state. The zero value is
+// a chain holding only testGenesis.
+type testChain struct {
+	blocks map[common.Hash]*types.Block
+	head   *types.Block
+}
+
+func (self *testChain) Config() *params.ChainConfig                   { return params.DefaultChainConfig }
+func (self *testChain) GetAncestors(*types.Block, int) []*types.Block { return nil }
+
+func (self *testChain) CurrentBlock() *types.Block {
+	if self.head == nil {
+		return testGenesis
+	}
+	return self.head
+}
+
+func (self *testChain) GetBlock(hash common.Hash) *types.Block {
+	if hash == testGenesis.Hash() {
+		return testGenesis
+	}
+	return self.blocks[hash]
+}
+
+func (self *testChain) insert(block *types.Block) {
+	if self.blocks == nil {
+		self.blocks = make(map[common.Hash]*types.Block)
+	}
+	self.blocks[block.Hash()] = block
+	self.head = block
+}
+
+type testSigner struct {
+	key  *ecdsa.PrivateKey
+	addr common.Address
+}
+
+func newTestSigners(t *testing.T, n int) []*testSigner {
+	signers := make([]*testSigner, n)
+	for i := range signers {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers[i] = &testSigner{key, common.BytesToAddress(crypto.PubkeyToAddress(key.PublicKey))}
+	}
+	return signers
+}
+
+func (self *testSigner) sign(signer common.Address, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, self.key)
+}
+
+func testConfig(epoch uint64, signers ...*testSigner) *params.AuthorityConfig {
+	config := &params.AuthorityConfig{Period: 1, Epoch: epoch}
+	for _, s := range signers {
+		config.Signers = append(config.Signers, s.addr.Hex())
+	}
+	return config
+}
+
+// newHeader prepares the next block of chain to be sealed by signer.
+func newHeader(t *testing.T, engine *Authority, chain *testChain, signer *testSigner) (*types.Header, *types.Header) {
+	parent := chain.CurrentBlock().Header()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   new(big.Int).Set(parent.GasLimit),
+		GasUsed:    new(big.Int),
+		Time:       parent.Time + 1,
+		Extra:      []byte("vanity"),
+	}
+	engine.Authorize(signer.addr, signer.sign)
+	if err := engine.Prepare(chain, header, parent); err != nil {
+		t.Fatal(err)
+	}
+	return header, parent
+}
+
+// seal signs header as signer without waiting for its time.
+func seal(header *types.Header, signer *testSigner) {
+	sig, _ := signer.sign(signer.addr, sigHash(header).Bytes())
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+}
+
+// add seals the next block of chain by signer, verifies and inserts it.
+func add(t *testing.T, engine *Authority, chain *testChain, signer *testSigner) *types.Header {
+	header, parent := newHeader(t, engine, chain, signer)
+	seal(header, signer)
+	if err := engine.VerifyHeader(chain, header, parent); err != nil {
+		t.Fatalf("block %v by %x: %v", header.Number, signer.addr[:4], err)
+	}
+	chain.insert(types.NewBlockWithHeader(header))
+	return header
+}
+
+func TestSealAndVerify(t *testing.T) {
+	signers := newTestSigners(t, 3)
+	engine := New(testConfig(0, signers...))
+	chain := new(testChain)
+
+	sorted, _ := engine.Signers(chain, chain.CurrentBlock().Header())
+	var inTurn *testSigner
+	for _, s := range signers {
+		if s.addr == sorted[1] {
+			inTurn = s
+		}
+	}
+
+	header, parent := newHeader(t, engine, chain, inTurn)
+	if header.Difficulty.Cmp(diffInTurn) != 0 {
+		t.Errorf("difficulty in turn %v, want %v", header.Difficulty, diffInTurn)
+	}
+	if string(header.Extra[:6]) != "vanity" || len(header.Extra) != extraVanity+extraSeal {
+		t.Errorf("extra data %x", header.Extra)
+	}
+	block, err := engine.Seal(chain, types.NewBlockWithHeader(header), make(chan struct{}))
+	if err != nil || block == nil {
+		t.Fatalf("seal failed: block %v, error %v", block, err)
+	}
+	if err := engine.VerifyHeader(chain, block.Header(), parent); err != nil {
+		t.Errorf("sealed block invalid: %v", err)
+	}
+	if signer, _ := ecrecover(block.Header()); signer != inTurn.addr {
+		t.Errorf("signer %x, want %x", signer, inTurn.addr)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	signers := newTestSigners(t, 3)
+	outsider := newTestSigners(t, 1)[0]
+	engine := New(testConfig(0, signers...))
+	chain := new(testChain)
+	add(t, engine, chain, signers[0])
+
+	// signers may sign one of every two blocks
+	header, parent := newHeader(t, engine, chain, signers[0])
+	seal(header, signers[0])
+	if err := engine.VerifyHeader(chain, header, parent); err != ErrSignedRecently {
+		t.Errorf("signing twice in a row: got error %v, want %v", err, ErrSignedRecently)
+	}
+
+	header, parent = newHeader(t, engine, chain, outsider)
+	seal(header, outsider)
+	if err := engine.VerifyHeader(chain, header, parent); err != ErrUnauthorized {
+		t.Errorf("outsider: got error %v, want %v", err, ErrUnauthorized)
+	}
+
+	header, parent = newHeader(t, engine, chain, signers[1])
+	seal(header, signers[1])
+	header.GasUsed = big.NewInt(1)
+	if err := engine.VerifyHeader(chain, header, parent); err != ErrUnauthorized {
+		t.Errorf("modified after signing: got error %v, want %v", err, ErrUnauthorized)
+	}
+
+	header, parent = newHeader(t, engine, chain, signers[1])
+	header.Difficulty = big.NewInt(3)
+	seal(header, signers[1])
+	if err := engine.VerifyHeader(chain, header, parent); err == nil {
+		t.Errorf("wrong difficulty accepted")
+	}
+
+	header, parent = newHeader(t, engine, chain, signers[1])
+	header.Time = parent.Time
+	seal(header, signers[1])
+	if err := engine.VerifyHeader(chain, header, parent); err == nil {
+		t.Errorf("block within the period accepted")
+	}
+}
+
+func TestVoting(t *testing.T) {
+	signers := newTestSigners(t, 3)
+	a, b, c := signers[0], signers[1], signers[2]
+	engine := New(testConfig(0, a, b))
+	chain := new(testChain)
+
+	// two of two signers have to agree on adding c
+	engine.Propose(c.addr, true)
+	add(t, engine, chain, a)
+	if sorted, _ := engine.Signers(chain, chain.CurrentBlock().Header()); len(sorted) != 2 {
+		t.Fatalf("signers after one vote: %x", sorted)
+	}
+	add(t, engine, chain, b)
+	if sorted, _ := engine.Signers(chain, chain.CurrentBlock().Header()); len(sorted) != 3 {
+		t.Fatalf("signers after two votes: %x", sorted)
+	}
+
+	// the proposal is done, the next block carries no vote
+	header := add(t, engine, chain, c)
+	if len(header.Extra) != extraVanity+extraSeal {
+		t.Errorf("vote for a signer: extra data %x", header.Extra)
+	}
+
+	// two of three signers have to agree on dropping a
+	engine.Discard(c.addr)
+	engine.Propose(a.addr, false)
+	add(t, engine, chain, b)
+	add(t, engine, chain, c)
+	sorted, _ := engine.Signers(chain, chain.CurrentBlock().Header())
+	if len(sorted) != 2 || engine.mustSnapshot(t, chain).IsSigner(a.addr) {
+		t.Errorf("signers after dropping a: %x", sorted)
+	}
+	if len(engine.mustSnapshot(t, chain).Votes) != 0 {
+		t.Errorf("votes left after the vote passed: %v", engine.mustSnapshot(t, chain).Votes)
+	}
+	// b and c drop c, the last signer cannot vote itself out
+	engine.Discard(a.addr)
+	engine.Propose(c.addr, false)
+	add(t, engine, chain, b)
+	add(t, engine, chain, c)
+	engine.Discard(c.addr)
+	engine.Propose(b.addr, false)
+	header = add(t, engine, chain, b)
+	if len(header.Extra) != extraVanity+extraSeal {
+		t.Errorf("vote to drop the last signer: extra data %x", header.Extra)
+	}
+	snap := engine.mustSnapshot(t, chain)
+	if len(snap.Signers) != 1 || !snap.IsSigner(b.addr) {
+		t.Fatalf("signers after dropping c: %x", snap.Signers)
+	}
+	vote := &Vote{Signer: b.addr, Address: b.addr}
+	if next := snap.apply(snap.Number+1, common.Hash{}, b.addr, vote, false); len(next.Signers) != 1 || len(next.Votes) != 0 {
+		t.Errorf("vote to drop the last signer applied: signers %x, votes %v", next.Signers, next.Votes)
+	}
+	if new(Snapshot).InTurn(1, b.addr) {
+		t.Errorf("signer in turn without signers")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	signers := newTestSigners(t, 3)
+	a, b, c := signers[0], signers[1], signers[2]
+	engine := New(testConfig(3, a, b, c))
+	chain := new(testChain)
+
+	engine.Propose(c.addr, false)
+	add(t, engine, chain, a)
+	engine.Discard(c.addr)
+	add(t, engine, chain, b)
+	add(t, engine, chain, c) // block 3 lists the signers
+	checkpoint := chain.CurrentBlock().Header()
+	if len(checkpoint.Extra) != extraVanity+3*addressLength+extraSeal {
+		t.Fatalf("checkpoint extra data %x", checkpoint.Extra)
+	}
+
+	// a restarted node starts from the checkpoint
+	fresh := New(testConfig(3, a, b))
+	sorted, err := fresh.Signers(chain, checkpoint)
+	if err != nil || len(sorted) != 3 {
+		t.Errorf("signers from checkpoint: %x, error %v", sorted, err)
+	}
+
+	// the vote of a before the checkpoint no longer counts
+	engine.Propose(c.addr, false)
+	add(t, engine, chain, b)
+	if snap := engine.mustSnapshot(t, chain); len(snap.Votes) != 1 || !snap.IsSigner(c.addr) {
+		t.Errorf("after one vote: signers %x, votes %v", snap.Signers, snap.Votes)
+	}
+}
+
+func (self *Authority) mustSnapshot(t *testing.T, chain *testChain) *Snapshot {
+	snap, err := self.snapshot(chain, chain.CurrentBlock().Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
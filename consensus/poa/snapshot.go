@@ -0,0 +1,170 @@
+package poa
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Vote is the vote of a signer to authorise or to drop Address.
+type Vote struct {
+	Signer    common.Address
+	Address   common.Address
+	Authorize bool
+}
+
+// Snapshot is the state of the authority after a block: who may sign the
+// next blocks, who signed recently and the votes cast since the last
+// checkpoint.
+type Snapshot struct {
+	Number  uint64
+	Hash    common.Hash
+	Signers []common.Address          // sorted, the order decides whose turn it is
+	Recents map[uint64]common.Address // signers of the last blocks, by block number
+	Votes   []*Vote                   // open votes, in the order they were cast
+}
+
+func newSnapshot(number uint64, hash common.Hash, signers []common.Address) *Snapshot {
+	snap := &Snapshot{
+		Number:  number,
+		Hash:    hash,
+		Signers: make([]common.Address, len(signers)),
+		Recents: make(map[uint64]common.Address),
+	}
+	copy(snap.Signers, signers)
+	sort.Sort(addresses(snap.Signers))
+	return snap
+}
+
+func (self *Snapshot) copy() *Snapshot {
+	cpy := newSnapshot(self.Number, self.Hash, self.Signers)
+	for number, signer := range self.Recents {
+		cpy.Recents[number] = signer
+	}
+	cpy.Votes = make([]*Vote, len(self.Votes))
+	copy(cpy.Votes, self.Votes)
+	return cpy
+}
+
+// IsSigner returns whether addr is an authorised signer.
+func (self *Snapshot) IsSigner(addr common.Address) bool {
+	for _, signer := range self.Signers {
+		if signer == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// InTurn returns whether it is the turn of signer to sign block number.
+func (self *Snapshot) InTurn(number uint64, signer common.Address) bool {
+	if len(self.Signers) == 0 {
+		return false
+	}
+	return self.Signers[number%uint64(len(self.Signers))] == signer
+}
+
+// limit is the number of consecutive blocks of which a signer may sign only
+// one.
+func (self *Snapshot) limit() uint64 {
+	return uint64(len(self.Signers)/2 + 1)
+}
+
+// SignedRecently returns whether signer has to wait for others before it
+// may sign block number.
+func (self *Snapshot) SignedRecently(number uint64, signer common.Address) bool {
+	for seen, recent := range self.Recents {
+		if recent == signer && number < seen+self.limit() {
+			return true
+		}
+	}
+	return false
+}
+
+// validVote returns whether a vote about addr changes anything. Votes to drop
+// the last signer are invalid, nobody could sign the next block.
+func (self *Snapshot) validVote(addr common.Address, authorize bool) bool {
+	if !authorize && len(self.Signers) == 1 {
+		return false
+	}
+	return self.IsSigner(addr) != authorize
+}
+
+// apply returns the snapshot after the block with the given header, signed
+// by signer. The signer must be allowed to sign the block.
+func (self *Snapshot) apply(number uint64, hash common.Hash, signer common.Address, vote *Vote, checkpoint bool) *Snapshot {
+	snap := self.copy()
+	snap.Number, snap.Hash = number, hash
+
+	if checkpoint {
+		// a checkpoint starts afresh, like a snapshot loaded from it
+		snap.Votes = nil
+		snap.Recents = make(map[uint64]common.Address)
+	}
+	for seen := range snap.Recents {
+		if seen+snap.limit() <= number {
+			delete(snap.Recents, seen)
+		}
+	}
+	snap.Recents[number] = signer
+
+	if vote == nil {
+		return snap
+	}
+	// a signer has one vote per address, the latest counts
+	snap.discard(func(v *Vote) bool { return v.Signer == signer && v.Address == vote.Address })
+	if !snap.validVote(vote.Address, vote.Authorize) {
+		return snap
+	}
+	snap.Votes = append(snap.Votes, vote)
+
+	tally := 0
+	for _, v := range snap.Votes {
+		if v.Address == vote.Address && v.Authorize == vote.Authorize {
+			tally++
+		}
+	}
+	if tally <= len(snap.Signers)/2 {
+		return snap
+	}
+
+	// the majority agrees, change the signers
+	if vote.Authorize {
+		snap.Signers = append(snap.Signers, vote.Address)
+		sort.Sort(addresses(snap.Signers))
+	} else {
+		for i, s := range snap.Signers {
+			if s == vote.Address {
+				snap.Signers = append(snap.Signers[:i], snap.Signers[i+1:]...)
+				break
+			}
+		}
+		// the votes of a dropped signer no longer count
+		snap.discard(func(v *Vote) bool { return v.Signer == vote.Address })
+		for seen := range snap.Recents {
+			if seen+snap.limit() <= number {
+				delete(snap.Recents, seen)
+			}
+		}
+	}
+	snap.discard(func(v *Vote) bool { return v.Address == vote.Address })
+	return snap
+}
+
+// discard removes the votes matching drop.
+func (self *Snapshot) discard(drop func(*Vote) bool) {
+	votes := self.Votes[:0]
+	for _, v := range self.Votes {
+		if !drop(v) {
+			votes = append(votes, v)
+		}
+	}
+	self.Votes = votes
+}
+
+type addresses []common.Address
+
+func (self addresses) Len() int           { return len(self) }
+func (self addresses) Less(i, j int) bool { return bytes.Compare(self[i][:], self[j][:]) < 0 }
+func (self addresses) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
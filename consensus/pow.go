@@ -0,0 +1,145 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/pow"
+	"gopkg.in/fatih/set.v0"
+)
+
+// maxUncles is the number of uncles a block may include.
+const maxUncles = 2
+
+// ProofOfWork is the engine of the main chain. Blocks are sealed by a proof
+// of work search of pow at the difficulty of the chain rules, and the miners
+// of blocks and of their uncles are paid the block reward.
+type ProofOfWork struct {
+	pow pow.PoW // nil for blocks without a proof, see Instant
+}
+
+// NewProofOfWork creates a proof of work engine using pow to seal and verify
+// blocks, e.g. ethash or ezp.
+func NewProofOfWork(pow pow.PoW) *ProofOfWork {
+	return &ProofOfWork{pow: pow}
+}
+
+// PoW returns the proof of work algorithm of the engine.
+func (self *ProofOfWork) PoW() pow.PoW {
+	return self.pow
+}
+
+func (self *ProofOfWork) Hashrate() int64 {
+	if self.pow == nil {
+		return 0
+	}
+	return self.pow.GetHashrate()
+}
+
+func (self *ProofOfWork) VerifyHeader(chain ChainReader, header, parent *types.Header) error {
+	if err := VerifyHeaderFields(header, parent); err != nil {
+		return err
+	}
+
+	expd := CalcDifficulty(chain.Config(), header, parent)
+	if expd.Cmp(header.Difficulty) != 0 {
+		return fmt.Errorf("Difficulty check failed for block %v, %v", header.Difficulty, expd)
+	}
+
+	// Verify the nonce of the block. Return an error if it's not valid
+	if self.pow != nil && !self.pow.Verify(types.NewBlockWithHeader(header)) {
+		return fmt.Errorf("Block's nonce is invalid (= %x)", header.Nonce)
+	}
+
+	return nil
+}
+
+// VerifyUncles checks that the uncles of block are unique, are children of
+// one of its last seven ancestors and are valid blocks themselves.
+func (self *ProofOfWork) VerifyUncles(chain ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > maxUncles {
+		return fmt.Errorf("Block can only contain %d uncles (contained %v)", maxUncles, len(block.Uncles()))
+	}
+
+	ancestors := set.New()
+	uncles := set.New()
+	ancestorHeaders := make(map[common.Hash]*types.Header)
+	for _, ancestor := range chain.GetAncestors(block, 7) {
+		ancestorHeaders[ancestor.Hash()] = ancestor.Header()
+		ancestors.Add(ancestor.Hash())
+		// Include ancestors uncles in the uncle set. Uncles must be unique.
+		for _, uncle := range ancestor.Uncles() {
+			uncles.Add(uncle.Hash())
+		}
+	}
+
+	uncles.Add(block.Hash())
+	for _, uncle := range block.Uncles() {
+		if uncles.Has(uncle.Hash()) {
+			// Error not unique
+			return fmt.Errorf("Uncle not unique")
+		}
+
+		uncles.Add(uncle.Hash())
+
+		if ancestors.Has(uncle.Hash()) {
+			return fmt.Errorf("Uncle is ancestor")
+		}
+
+		if !ancestors.Has(uncle.ParentHash) {
+			return fmt.Errorf("Uncle's parent unknown (%x)", uncle.ParentHash[0:4])
+		}
+
+		if err := self.VerifyHeader(chain, uncle, ancestorHeaders[uncle.ParentHash]); err != nil {
+			return fmt.Errorf("invalid uncle: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (self *ProofOfWork) Prepare(chain ChainReader, header, parent *types.Header) error {
+	header.Difficulty = CalcDifficulty(chain.Config(), header, parent)
+	return nil
+}
+
+// Finalize credits the miner of block and the miners of its uncles with the
+// block reward of the rules of the block.
+func (self *ProofOfWork) Finalize(chain ChainReader, statedb *state.StateDB, block *types.Block) {
+	blockReward := chain.Config().Rules(block.Number()).BlockReward
+	reward := new(big.Int).Set(blockReward)
+
+	for _, uncle := range block.Uncles() {
+		num := new(big.Int).Add(big.NewInt(8), uncle.Number)
+		num.Sub(num, block.Number())
+
+		r := new(big.Int)
+		r.Mul(blockReward, num)
+		r.Div(r, big.NewInt(8))
+
+		statedb.AddBalance(uncle.Coinbase, r)
+
+		reward.Add(reward, new(big.Int).Div(blockReward, big.NewInt(32)))
+	}
+
+	// Get the account associated with the coinbase
+	statedb.AddBalance(block.Header().Coinbase, reward)
+}
+
+// Seal searches for the nonce of block. The block is returned with the nonce
+// and mix digest set.
+func (self *ProofOfWork) Seal(chain ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	if self.pow == nil {
+		return block, nil
+	}
+	nonce, mixDigest, _ := self.pow.Search(block, stop)
+	if nonce == 0 {
+		return nil, nil
+	}
+	block.SetNonce(nonce)
+	block.Header().MixDigest = common.BytesToHash(mixDigest)
+	return block, nil
+}
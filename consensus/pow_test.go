@@ -0,0 +1,122 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testChain is a chain without blocks, only its rules are known.
+type testChain struct {
+	config *params.ChainConfig
+}
+
+func (self testChain) Config() *params.ChainConfig                   { return self.config }
+func (self testChain) CurrentBlock() *types.Block                    { return nil }
+func (self testChain) GetBlock(common.Hash) *types.Block             { return nil }
+func (self testChain) GetAncestors(*types.Block, int) []*types.Block { return nil }
+
+// forkConfig returns a chain config which switches from the frontier rules to
+// the homestead difficulty and a higher block reward at the given block.
+func forkConfig(block int64) *params.ChainConfig {
+	config := &params.ChainConfig{Forks: []*params.RuleSet{
+		params.FrontierRules,
+		{
+			Name:        "test",
+			Block:       big.NewInt(block),
+			Difficulty:  params.HomesteadDifficulty,
+			BlockReward: big.NewInt(5e18),
+		},
+	}}
+	if err := config.Validate(); err != nil {
+		panic(err)
+	}
+	return config
+}
+
+func TestForkDifficulty(t *testing.T) {
+	config := forkConfig(5)
+	tests := []struct {
+		number, time int64
+		want         int64
+	}{
+		// frontier: down by 1/2048 from 8 seconds on
+		{4, 15, 1000000 - 488},
+		{4, 100, 1000000 - 488},
+		// homestead: unchanged up to 19 seconds, down one step per 10 more
+		{5, 15, 1000000},
+		{5, 100, 1000000 - 9*488},
+		{6, 100, 1000000 - 9*488},
+	}
+	for _, test := range tests {
+		parent := &types.Header{Number: big.NewInt(test.number - 1), Difficulty: big.NewInt(1000000), Time: 1000}
+		block := &types.Header{Number: big.NewInt(test.number), Time: 1000 + uint64(test.time)}
+		if diff := CalcDifficulty(config, block, parent); diff.Int64() != test.want {
+			t.Errorf("block %d after %ds: difficulty %v, want %d", test.number, test.time, diff, test.want)
+		}
+	}
+}
+
+func TestForkBlockReward(t *testing.T) {
+	engine := NewProofOfWork(nil)
+	chain := testChain{forkConfig(5)}
+	for number, want := range map[int64]*big.Int{4: big.NewInt(1.5e18), 5: big.NewInt(5e18)} {
+		db, _ := ethdb.NewMemDatabase()
+		statedb := state.New(common.Hash{}, db)
+		block := types.NewBlock(common.Hash{}, common.StringToAddress("miner"), common.Hash{}, common.Big1, 0, nil)
+		block.Header().Number = big.NewInt(number)
+
+		engine.Finalize(chain, statedb, block)
+		if balance := statedb.GetBalance(block.Coinbase()); balance.Cmp(want) != 0 {
+			t.Errorf("block %d: reward %v, want %v", number, balance, want)
+		}
+	}
+}
+
+func TestUncleReward(t *testing.T) {
+	engine := NewProofOfWork(nil)
+	chain := testChain{params.DefaultChainConfig}
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb := state.New(common.Hash{}, db)
+	block := types.NewBlock(common.Hash{}, common.StringToAddress("miner"), common.Hash{}, common.Big1, 0, nil)
+	block.Header().Number = big.NewInt(10)
+	uncle := &types.Header{Number: big.NewInt(9), Coinbase: common.StringToAddress("uncle")}
+	block.SetUncles([]*types.Header{uncle})
+
+	engine.Finalize(chain, statedb, block)
+	// the uncle gets 7/8 of the reward, the miner 1/32 extra for including it
+	if balance, want := statedb.GetBalance(uncle.Coinbase), big.NewInt(1.3125e18); balance.Cmp(want) != 0 {
+		t.Errorf("uncle reward %v, want %v", balance, want)
+	}
+	if balance, want := statedb.GetBalance(block.Coinbase()), big.NewInt(1.546875e18); balance.Cmp(want) != 0 {
+		t.Errorf("miner reward %v, want %v", balance, want)
+	}
+}
+
+func TestInstantSeal(t *testing.T) {
+	engine := NewInstant()
+	chain := testChain{params.DefaultChainConfig}
+	parent := &types.Header{Number: big.NewInt(1), Difficulty: params.MinimumDifficulty, GasLimit: params.GenesisGasLimit, GasUsed: new(big.Int), Time: 1000}
+	header := &types.Header{ParentHash: parent.Hash(), Number: big.NewInt(2), GasLimit: parent.GasLimit, GasUsed: new(big.Int), Time: 1001}
+	if err := engine.Prepare(chain, header, parent); err != nil {
+		t.Fatal(err)
+	}
+	block, err := engine.Seal(chain, types.NewBlockWithHeader(header), make(chan struct{}))
+	if err != nil || block == nil {
+		t.Fatalf("seal failed: block %v, error %v", block, err)
+	}
+	// no nonce is searched for, the block is valid as it is
+	if err := engine.VerifyHeader(chain, block.Header(), parent); err != nil {
+		t.Errorf("sealed block invalid: %v", err)
+	}
+	header.Time = parent.Time
+	if err := engine.VerifyHeader(chain, header, parent); err == nil {
+		t.Errorf("block with the time of its parent accepted")
+	}
+}
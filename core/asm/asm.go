@@ -0,0 +1,92 @@
+// Package asm provides an EVM instruction iterator, a disassembler and a
+// small assembler.
+package asm
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// InstructionIterator iterates over the instructions of EVM code.
+//
+//	it := asm.NewInstructionIterator(code)
+//	for it.Next() {
+//		fmt.Println(it.PC(), it.Op(), it.Arg())
+//	}
+//	if err := it.Error(); err != nil {
+//		...
+//	}
+type InstructionIterator struct {
+	code    []byte
+	pc      uint64
+	arg     []byte
+	op      vm.OpCode
+	err     error
+	started bool
+}
+
+// NewInstructionIterator creates an iterator over the instructions of code.
+func NewInstructionIterator(code []byte) *InstructionIterator {
+	return &InstructionIterator{code: code}
+}
+
+// Next moves to the next instruction. It returns false at the end of the
+// code or if the code ends in the middle of the immediate of a PUSH.
+func (self *InstructionIterator) Next() bool {
+	if self.err != nil || self.pc >= uint64(len(self.code)) {
+		return false
+	}
+
+	if self.started {
+		// skip the instruction and its immediate
+		self.pc += uint64(len(self.arg)) + 1
+	} else {
+		self.started = true
+	}
+	if self.pc >= uint64(len(self.code)) {
+		return false
+	}
+
+	self.op = vm.OpCode(self.code[self.pc])
+	self.arg = nil
+	if self.op.IsPush() {
+		start := self.pc + 1
+		end := start + uint64(self.op-vm.PUSH1) + 1
+		if end > uint64(len(self.code)) {
+			self.err = fmt.Errorf("incomplete %v instruction at %d", self.op, self.pc)
+			return false
+		}
+		self.arg = self.code[start:end]
+	}
+	return true
+}
+
+// Error returns the error which stopped the iteration, if any.
+func (self *InstructionIterator) Error() error { return self.err }
+
+// PC returns the position of the current instruction in the code.
+func (self *InstructionIterator) PC() uint64 { return self.pc }
+
+// Op returns the op code of the current instruction.
+func (self *InstructionIterator) Op() vm.OpCode { return self.op }
+
+// Arg returns the immediate of the current instruction, which is nil for all
+// instructions but PUSH.
+func (self *InstructionIterator) Arg() []byte { return self.arg }
+
+// Disassemble returns a line of text for each instruction of script. If the
+// script ends in the middle of an instruction, the lines of the instructions
+// before it are returned along with an error.
+func Disassemble(script []byte) ([]string, error) {
+	var asm []string
+	it := NewInstructionIterator(script)
+	for it.Next() {
+		if it.Arg() != nil {
+			asm = append(asm, fmt.Sprintf("%05d: %v 0x%x", it.PC(), it.Op(), it.Arg()))
+		} else {
+			asm = append(asm, fmt.Sprintf("%05d: %v", it.PC(), it.Op()))
+		}
+	}
+	return asm, it.Error()
+}
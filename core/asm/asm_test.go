@@ -0,0 +1,74 @@
+package asm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestInstructionIterator(t *testing.T) {
+	code := common.Hex2Bytes("6001610203015b00")
+	want := []struct {
+		pc  uint64
+		op  vm.OpCode
+		arg []byte
+	}{
+		{0, vm.PUSH1, []byte{0x01}},
+		{2, vm.PUSH2, []byte{0x02, 0x03}},
+		{5, vm.ADD, nil},
+		{6, vm.JUMPDEST, nil},
+		{7, vm.STOP, nil},
+	}
+
+	it := NewInstructionIterator(code)
+	for i, w := range want {
+		if !it.Next() {
+			t.Fatalf("iteration stopped at instruction %d: %v", i, it.Error())
+		}
+		if it.PC() != w.pc || it.Op() != w.op || !bytes.Equal(it.Arg(), w.arg) {
+			t.Errorf("instruction %d: got %d %v %x, want %d %v %x", i, it.PC(), it.Op(), it.Arg(), w.pc, w.op, w.arg)
+		}
+	}
+	if it.Next() {
+		t.Errorf("iteration continued after the end of the code")
+	}
+	if it.Error() != nil {
+		t.Errorf("unexpected error: %v", it.Error())
+	}
+}
+
+func TestInstructionIteratorIncompletePush(t *testing.T) {
+	it := NewInstructionIterator(common.Hex2Bytes("00620102"))
+	if !it.Next() || it.Op() != vm.STOP {
+		t.Fatalf("expected STOP, got %v (%v)", it.Op(), it.Error())
+	}
+	if it.Next() {
+		t.Fatalf("incomplete PUSH3 was returned")
+	}
+	if it.Error() == nil {
+		t.Fatalf("expected an error for the incomplete PUSH3")
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	lines, err := Disassemble(common.Hex2Bytes("60606040525b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"00000: PUSH1 0x60",
+		"00002: PUSH1 0x40",
+		"00004: MSTORE",
+		"00005: JUMPDEST",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %q", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: got %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package asm
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Assemble compiles EVM assembly into code. The source has one instruction
+// per line, optionally preceded by a label definition:
+//
+//	; count down from 10
+//	        PUSH 10         ; PUSH picks the smallest width for its value
+//	loop:                   ; a label marks a JUMPDEST
+//	        PUSH1 1         ; PUSH1 to PUSH32 are padded to their width
+//	        SWAP1
+//	        SUB
+//	        DUP1
+//	        PUSH @loop      ; @label pushes the position of a label
+//	        JUMPI
+//
+// Everything after a ';' is a comment. Instruction names are case
+// insensitive, numbers are decimal or hex with a 0x prefix.
+func Assemble(src string) ([]byte, error) {
+	items, labels, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Label references use the same width, which must be large enough for
+	// the position of every label. Wider references move the labels, so the
+	// layout is repeated until the width fits.
+	var positions map[string]uint64
+	for width := 1; ; width++ {
+		positions = layout(items, width)
+		if len(new(big.Int).SetUint64(maxPosition(positions)).Bytes()) <= width {
+			for i := range items {
+				if items[i].label != "" && items[i].width == 0 {
+					items[i].width = width
+				}
+			}
+			break
+		}
+	}
+
+	var code []byte
+	for _, it := range items {
+		switch {
+		case it.def != "":
+			code = append(code, byte(vm.JUMPDEST))
+		case it.label != "":
+			if _, ok := labels[it.label]; !ok {
+				return nil, fmt.Errorf("line %d: undefined label %q", it.line, it.label)
+			}
+			arg := new(big.Int).SetUint64(positions[it.label]).Bytes()
+			if len(arg) > it.width {
+				return nil, fmt.Errorf("line %d: position of label %q does not fit in %d bytes", it.line, it.label, it.width)
+			}
+			code = append(code, byte(vm.PUSH1)+byte(it.width-1))
+			code = append(code, common.LeftPadBytes(arg, it.width)...)
+		case it.op.IsPush():
+			code = append(code, byte(it.op))
+			code = append(code, common.LeftPadBytes(it.arg, it.width)...)
+		default:
+			code = append(code, byte(it.op))
+		}
+	}
+	return code, nil
+}
+
+// item is a parsed line of assembly: an instruction or a label definition.
+type item struct {
+	line  int
+	op    vm.OpCode
+	arg   []byte // immediate of a PUSH
+	label string // label pushed by a PUSH
+	width int    // width of a PUSH, 0 if not known yet
+	def   string // label defined at this position
+}
+
+// size returns the number of bytes of the item in the code, using
+// labelWidth for label references of unknown width.
+func (it item) size(labelWidth int) uint64 {
+	switch {
+	case it.def != "":
+		return 1
+	case it.label != "" && it.width == 0:
+		return uint64(labelWidth) + 1
+	case it.op.IsPush():
+		return uint64(it.width) + 1
+	}
+	return 1
+}
+
+func layout(items []item, labelWidth int) map[string]uint64 {
+	positions := make(map[string]uint64)
+	var pc uint64
+	for _, it := range items {
+		if it.def != "" {
+			positions[it.def] = pc
+		}
+		pc += it.size(labelWidth)
+	}
+	return positions
+}
+
+func maxPosition(positions map[string]uint64) (max uint64) {
+	for _, pos := range positions {
+		if pos > max {
+			max = pos
+		}
+	}
+	return max
+}
+
+func parse(src string) ([]item, map[string]int, error) {
+	var (
+		items  []item
+		labels = make(map[string]int) // line of the definition
+	)
+	for i, line := range strings.Split(src, "\n") {
+		lineno := i + 1
+		if c := strings.Index(line, ";"); c >= 0 {
+			line = line[:c]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if name := fields[0]; strings.HasSuffix(name, ":") {
+			name = strings.TrimSuffix(name, ":")
+			if name == "" || strings.HasPrefix(name, "@") {
+				return nil, nil, fmt.Errorf("line %d: invalid label %q", lineno, fields[0])
+			}
+			if prev, ok := labels[name]; ok {
+				return nil, nil, fmt.Errorf("line %d: label %q already defined on line %d", lineno, name, prev)
+			}
+			labels[name] = lineno
+			items = append(items, item{line: lineno, def: name})
+			fields = fields[1:]
+			if len(fields) == 0 {
+				continue
+			}
+		}
+
+		it, err := parseInstruction(fields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		it.line = lineno
+		items = append(items, it)
+	}
+	return items, labels, nil
+}
+
+func parseInstruction(fields []string) (item, error) {
+	name := strings.ToUpper(fields[0])
+	if name == "PUSH" {
+		if len(fields) != 2 {
+			return item{}, fmt.Errorf("PUSH takes one argument")
+		}
+		return parsePush(0, fields[1])
+	}
+
+	op, ok := vm.StringToOp(name)
+	if !ok {
+		return item{}, fmt.Errorf("unknown instruction %q", fields[0])
+	}
+	if op.IsPush() {
+		if len(fields) != 2 {
+			return item{}, fmt.Errorf("%v takes one argument", op)
+		}
+		return parsePush(int(op-vm.PUSH1)+1, fields[1])
+	}
+	if len(fields) != 1 {
+		return item{}, fmt.Errorf("%v takes no arguments", op)
+	}
+	return item{op: op}, nil
+}
+
+// parsePush parses the argument of a PUSH of the given width, where a zero
+// width means the smallest width the value fits in.
+func parsePush(width int, arg string) (item, error) {
+	if strings.HasPrefix(arg, "@") {
+		if len(arg) == 1 {
+			return item{}, fmt.Errorf("missing label name")
+		}
+		return item{op: vm.PUSH1, label: arg[1:], width: width}, nil
+	}
+
+	var (
+		value = new(big.Int)
+		ok    bool
+	)
+	if strings.HasPrefix(arg, "0x") || strings.HasPrefix(arg, "0X") {
+		_, ok = value.SetString(arg[2:], 16)
+	} else {
+		_, ok = value.SetString(arg, 10)
+	}
+	if !ok || value.Sign() < 0 {
+		return item{}, fmt.Errorf("invalid number %q", arg)
+	}
+	b := value.Bytes()
+	if width == 0 {
+		width = len(b)
+		if width == 0 {
+			width = 1
+		}
+	}
+	if len(b) > 32 {
+		return item{}, fmt.Errorf("%s is larger than 32 bytes", arg)
+	}
+	if len(b) > width {
+		return item{}, fmt.Errorf("%s does not fit in %d bytes", arg, width)
+	}
+	return item{op: vm.PUSH1 + vm.OpCode(width-1), arg: b, width: width}, nil
+}
@@ -0,0 +1,82 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		src  string
+		code string
+	}{
+		{"STOP", "00"},
+		{"push 0\nPUSH 0x0102 ; comment\nPUSH 256", "6000610102610100"},
+		{"PUSH3 1", "62000001"},
+		{"PUSH 010\nPUSH 0XfF", "600a60ff"},
+		{"PUSH32 0x01", "7f" + strings.Repeat("00", 31) + "01"},
+		{
+			// labels may be used before they are defined
+			"PUSH @end\nJUMP\nstart: PUSH1 1\nend:\nPUSH @start",
+			"6006" + "56" + "5b" + "6001" + "5b" + "6003",
+		},
+		{"l: PUSH2 @l", "5b610000"},
+	}
+	for i, test := range tests {
+		code, err := Assemble(test.src)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if want := common.Hex2Bytes(test.code); !bytes.Equal(code, want) {
+			t.Errorf("test %d: got %x, want %x", i, code, want)
+		}
+	}
+}
+
+func TestAssembleLabelWidth(t *testing.T) {
+	// a label beyond position 255 needs two byte references, which moves
+	// the label again
+	src := "PUSH @end\n" + strings.Repeat("STOP\n", 254) + "end:"
+	code, err := Assemble(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 3+254+1 {
+		t.Fatalf("got %d bytes of code, want %d", len(code), 3+254+1)
+	}
+	if !bytes.Equal(code[:3], []byte{0x61, 0x01, 0x01}) {
+		t.Errorf("got label reference %x, want 610101", code[:3])
+	}
+	if code[257] != 0x5b {
+		t.Errorf("label at 257 is %x, want JUMPDEST", code[257])
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"FOO", "line 1: unknown instruction \"FOO\""},
+		{"\nADD 1", "line 2: ADD takes no arguments"},
+		{"PUSH", "line 1: PUSH takes one argument"},
+		{"PUSH1 256", "line 1: 256 does not fit in 1 bytes"},
+		{"PUSH -1", "line 1: invalid number \"-1\""},
+		{"PUSH 0b1", "line 1: invalid number \"0b1\""},
+		{"PUSH 0x", "line 1: invalid number \"0x\""},
+		{"PUSH 0x-1", "line 1: invalid number \"0x-1\""},
+		{"PUSH 0x" + strings.Repeat("ff", 33), "line 1: 0x" + strings.Repeat("ff", 33) + " is larger than 32 bytes"},
+		{"PUSH @nowhere", "line 1: undefined label \"nowhere\""},
+		{"a:\na:", "line 2: label \"a\" already defined on line 1"},
+	}
+	for _, test := range tests {
+		_, err := Assemble(test.src)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+		}
+	}
+}
@@ -4,18 +4,15 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
-	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
-	"github.com/ethereum/go-ethereum/params"
-	"github.com/ethereum/go-ethereum/pow"
 	"github.com/ethereum/go-ethereum/rlp"
-	"gopkg.in/fatih/set.v0"
 )
 
 const (
@@ -35,8 +32,8 @@ type BlockProcessor struct {
 	bc *ChainManager
 	// non-persistent key/value memory storage
 	mem map[string]*big.Int
-	// Proof of work used for validating
-	Pow pow.PoW
+	// Consensus engine used for validating
+	engine consensus.Engine
 
 	txpool *TxPool
 
@@ -50,12 +47,12 @@ type BlockProcessor struct {
 	eventMux *event.TypeMux
 }
 
-func NewBlockProcessor(db, extra common.Database, pow pow.PoW, txpool *TxPool, chainManager *ChainManager, eventMux *event.TypeMux) *BlockProcessor {
+func NewBlockProcessor(db, extra common.Database, engine consensus.Engine, txpool *TxPool, chainManager *ChainManager, eventMux *event.TypeMux) *BlockProcessor {
 	sm := &BlockProcessor{
 		db:       db,
 		extraDb:  extra,
 		mem:      make(map[string]*big.Int),
-		Pow:      pow,
+		engine:   engine,
 		bc:       chainManager,
 		eventMux: eventMux,
 		txpool:   txpool,
@@ -114,6 +111,11 @@ func (self *BlockProcessor) ChainManager() *ChainManager {
 	return self.bc
 }
 
+// Engine returns the consensus engine blocks are validated with.
+func (self *BlockProcessor) Engine() consensus.Engine {
+	return self.engine
+}
+
 func (self *BlockProcessor) ApplyTransactions(coinbase *state.StateObject, statedb *state.StateDB, block *types.Block, txs types.Transactions, transientProcess bool) (types.Receipts, error) {
 	var (
 		receipts      types.Receipts
@@ -181,11 +183,6 @@ func (sm *BlockProcessor) processWithParent(block, parent *types.Block) (td *big
 		return
 	}
 
-	// There can be at most two uncles
-	if len(block.Uncles()) > 2 {
-		return nil, nil, ValidationError("Block can only contain one uncle (contained %v)", len(block.Uncles()))
-	}
-
 	receipts, err := sm.TransitionState(state, parent, block, false)
 	if err != nil {
 		return
@@ -217,11 +214,11 @@ func (sm *BlockProcessor) processWithParent(block, parent *types.Block) (td *big
 	}
 
 	// Verify uncles
-	if err = sm.VerifyUncles(state, block, parent); err != nil {
+	if err = sm.engine.VerifyUncles(sm.bc, block); err != nil {
 		return
 	}
-	// Accumulate static rewards; block reward, uncle's and uncle inclusion.
-	AccumulateRewards(state, block)
+	// Apply the engine's state changes, e.g. block reward, uncle's and uncle inclusion.
+	sm.engine.Finalize(sm.bc, state, block)
 
 	// Commit state objects/accounts to a temporary trie (does not save)
 	// used to calculate the state root.
@@ -239,6 +236,9 @@ func (sm *BlockProcessor) processWithParent(block, parent *types.Block) (td *big
 	// Remove transactions from the pool
 	sm.txpool.RemoveSet(block.Transactions())
 
+	// Store the receipts so they can be served to fast syncing peers
+	sm.bc.writeReceipts(block.Hash(), receipts)
+
 	// This puts transactions in a extra db for rpc
 	for i, tx := range block.Transactions() {
 		putTx(sm.extraDb, tx, block, uint64(i))
@@ -247,105 +247,10 @@ func (sm *BlockProcessor) processWithParent(block, parent *types.Block) (td *big
 	return td, state.Logs(), nil
 }
 
-// Validates the current block. Returns an error if the block was invalid,
-// an uncle or anything that isn't on the current block chain.
-// Validation validates easy over difficult (dagger takes longer time = difficult)
+// ValidateHeader validates block, a child of parent, using the consensus
+// engine.
 func (sm *BlockProcessor) ValidateHeader(block, parent *types.Header) error {
-	if big.NewInt(int64(len(block.Extra))).Cmp(params.MaximumExtraDataSize) == 1 {
-		return fmt.Errorf("Block extra data too long (%d)", len(block.Extra))
-	}
-
-	expd := CalcDifficulty(block, parent)
-	if expd.Cmp(block.Difficulty) != 0 {
-		return fmt.Errorf("Difficulty check failed for block %v, %v", block.Difficulty, expd)
-	}
-
-	// block.gasLimit - parent.gasLimit <= parent.gasLimit / GasLimitBoundDivisor
-	a := new(big.Int).Sub(block.GasLimit, parent.GasLimit)
-	a.Abs(a)
-	b := new(big.Int).Div(parent.GasLimit, params.GasLimitBoundDivisor)
-	if !(a.Cmp(b) < 0) || (block.GasLimit.Cmp(params.MinGasLimit) == -1) {
-		return fmt.Errorf("GasLimit check failed for block %v (%v > %v)", block.GasLimit, a, b)
-	}
-
-	// Allow future blocks up to 10 seconds
-	if int64(block.Time) > time.Now().Unix()+4 {
-		return BlockFutureErr
-	}
-
-	if new(big.Int).Sub(block.Number, parent.Number).Cmp(big.NewInt(1)) != 0 {
-		return BlockNumberErr
-	}
-
-	if block.Time <= parent.Time {
-		return BlockEqualTSErr //ValidationError("Block timestamp equal or less than previous block (%v - %v)", block.Time, parent.Time)
-	}
-
-	// Verify the nonce of the block. Return an error if it's not valid
-	if !sm.Pow.Verify(types.NewBlockWithHeader(block)) {
-		return ValidationError("Block's nonce is invalid (= %x)", block.Nonce)
-	}
-
-	return nil
-}
-
-func AccumulateRewards(statedb *state.StateDB, block *types.Block) {
-	reward := new(big.Int).Set(BlockReward)
-
-	for _, uncle := range block.Uncles() {
-		num := new(big.Int).Add(big.NewInt(8), uncle.Number)
-		num.Sub(num, block.Number())
-
-		r := new(big.Int)
-		r.Mul(BlockReward, num)
-		r.Div(r, big.NewInt(8))
-
-		statedb.AddBalance(uncle.Coinbase, r)
-
-		reward.Add(reward, new(big.Int).Div(BlockReward, big.NewInt(32)))
-	}
-
-	// Get the account associated with the coinbase
-	statedb.AddBalance(block.Header().Coinbase, reward)
-}
-
-func (sm *BlockProcessor) VerifyUncles(statedb *state.StateDB, block, parent *types.Block) error {
-	ancestors := set.New()
-	uncles := set.New()
-	ancestorHeaders := make(map[common.Hash]*types.Header)
-	for _, ancestor := range sm.bc.GetAncestors(block, 7) {
-		ancestorHeaders[ancestor.Hash()] = ancestor.Header()
-		ancestors.Add(ancestor.Hash())
-		// Include ancestors uncles in the uncle set. Uncles must be unique.
-		for _, uncle := range ancestor.Uncles() {
-			uncles.Add(uncle.Hash())
-		}
-	}
-
-	uncles.Add(block.Hash())
-	for _, uncle := range block.Uncles() {
-		if uncles.Has(uncle.Hash()) {
-			// Error not unique
-			return UncleError("Uncle not unique")
-		}
-
-		uncles.Add(uncle.Hash())
-
-		if ancestors.Has(uncle.Hash()) {
-			return UncleError("Uncle is ancestor")
-		}
-
-		if !ancestors.Has(uncle.ParentHash) {
-			return UncleError(fmt.Sprintf("Uncle's parent unknown (%x)", uncle.ParentHash[0:4]))
-		}
-
-		if err := sm.ValidateHeader(uncle, ancestorHeaders[uncle.ParentHash]); err != nil {
-			return ValidationError(fmt.Sprintf("%v", err))
-		}
-
-	}
-
-	return nil
+	return sm.engine.VerifyHeader(sm.bc, block, parent)
 }
 
 func (sm *BlockProcessor) GetLogs(block *types.Block) (logs state.Logs, err error) {
@@ -5,8 +5,10 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/pow/ezp"
 )
 
@@ -14,8 +16,8 @@ func proc() (*BlockProcessor, *ChainManager) {
 	db, _ := ethdb.NewMemDatabase()
 	var mux event.TypeMux
 
-	chainMan := NewChainManager(db, db, &mux)
-	return NewBlockProcessor(db, db, ezp.New(), nil, chainMan, &mux), chainMan
+	chainMan := NewChainManager(db, db, params.DefaultChainConfig, &mux)
+	return NewBlockProcessor(db, db, consensus.NewProofOfWork(ezp.New()), nil, chainMan, &mux), chainMan
 }
 
 func TestNumber(t *testing.T) {
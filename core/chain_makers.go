@@ -5,9 +5,11 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/pow"
 )
 
@@ -29,8 +31,8 @@ var (
 
 // Utility functions for making chains on the fly
 // Exposed for sake of testing from other packages (eg. go-ethash)
-func NewBlockFromParent(addr common.Address, parent *types.Block) *types.Block {
-	return newBlockFromParent(addr, parent)
+func NewBlockFromParent(config *params.ChainConfig, addr common.Address, parent *types.Block) *types.Block {
+	return newBlockFromParent(config, addr, parent)
 }
 
 func MakeBlock(bman *BlockProcessor, parent *types.Block, i int, db common.Database, seed int) *types.Block {
@@ -53,16 +55,36 @@ func NewCanonical(n int, db common.Database) (*BlockProcessor, error) {
 	return newCanonical(n, db)
 }
 
+// MakeState writes a state of 32 accounts with balances and nonces to db,
+// every fourth of them a contract with code and storage, and returns its
+// root. It is served by the peers of the synchronisation tests.
+func MakeState(db common.Database) common.Hash {
+	statedb := state.New(common.Hash{}, db)
+	for i := byte(1); i <= 32; i++ {
+		obj := statedb.GetOrNewStateObject(common.BytesToAddress([]byte{i}))
+		obj.AddBalance(big.NewInt(int64(i) * 1000))
+		obj.SetNonce(uint64(i))
+		if i%4 == 0 {
+			obj.SetCode([]byte{i, i, i})
+			obj.SetState(common.BytesToHash([]byte{i}), common.NewValue([]byte{i + 1}))
+			obj.SetState(common.BytesToHash([]byte{i, i}), common.NewValue([]byte{i + 2}))
+		}
+	}
+	statedb.Update()
+	statedb.Sync()
+	return statedb.Root()
+}
+
 // block time is fixed at 10 seconds
-func newBlockFromParent(addr common.Address, parent *types.Block) *types.Block {
+func newBlockFromParent(config *params.ChainConfig, addr common.Address, parent *types.Block) *types.Block {
 	block := types.NewBlock(parent.Hash(), addr, parent.Root(), common.BigPow(2, 32), 0, nil)
 	block.SetUncles(nil)
 	block.SetTransactions(nil)
 	block.SetReceipts(nil)
 
 	header := block.Header()
-	header.Difficulty = CalcDifficulty(block.Header(), parent.Header())
 	header.Number = new(big.Int).Add(parent.Header().Number, common.Big1)
+	header.Difficulty = consensus.CalcDifficulty(config, block.Header(), parent.Header())
 	header.Time = parent.Header().Time + 10
 	header.GasLimit = CalcGasLimit(parent, block)
 
@@ -76,11 +98,11 @@ func newBlockFromParent(addr common.Address, parent *types.Block) *types.Block {
 func makeBlock(bman *BlockProcessor, parent *types.Block, i int, db common.Database, seed int) *types.Block {
 	var addr common.Address
 	addr[0], addr[19] = byte(seed), byte(i)
-	block := newBlockFromParent(addr, parent)
+	block := newBlockFromParent(bman.bc.config, addr, parent)
 	state := state.New(block.Root(), db)
 	cbase := state.GetOrNewStateObject(addr)
 	cbase.SetGasPool(CalcGasLimit(parent, block))
-	cbase.AddBalance(BlockReward)
+	bman.engine.Finalize(bman.bc, state, block)
 	state.Update()
 	block.SetRoot(state.Root())
 	return block
@@ -108,7 +130,7 @@ func makeChain(bman *BlockProcessor, parent *types.Block, max int, db common.Dat
 // Create a new chain manager starting from given block
 // Effectively a fork factory
 func newChainManager(block *types.Block, eventMux *event.TypeMux, db common.Database) *ChainManager {
-	bc := &ChainManager{blockDb: db, stateDb: db, genesisBlock: GenesisBlock(db), eventMux: eventMux}
+	bc := &ChainManager{blockDb: db, stateDb: db, genesisBlock: GenesisBlock(db), config: params.DefaultChainConfig, eventMux: eventMux}
 	bc.futureBlocks = NewBlockCache(1000)
 	if block == nil {
 		bc.Reset()
@@ -122,8 +144,8 @@ func newChainManager(block *types.Block, eventMux *event.TypeMux, db common.Data
 // block processor with fake pow
 func newBlockProcessor(db common.Database, cman *ChainManager, eventMux *event.TypeMux) *BlockProcessor {
 	chainMan := newChainManager(nil, eventMux, db)
-	txpool := NewTxPool(eventMux, chainMan.State)
-	bman := NewBlockProcessor(db, db, FakePow{}, txpool, chainMan, eventMux)
+	txpool := NewTxPool(eventMux, chainMan.State, chainMan.PendingRules)
+	bman := NewBlockProcessor(db, db, consensus.NewProofOfWork(FakePow{}), txpool, chainMan, eventMux)
 	return bman
 }
 
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
@@ -24,6 +25,8 @@ var (
 
 	blockHashPre = []byte("block-hash-")
 	blockNumPre  = []byte("block-num-")
+	receiptsPre  = []byte("receipts-")
+	configPre    = []byte("chain-config-")
 )
 
 const blockCacheLimit = 10000
@@ -32,23 +35,6 @@ type StateQuery interface {
 	GetAccount(addr []byte) *state.StateObject
 }
 
-func CalcDifficulty(block, parent *types.Header) *big.Int {
-	diff := new(big.Int)
-
-	adjust := new(big.Int).Div(parent.Difficulty, params.DifficultyBoundDivisor)
-	if big.NewInt(int64(block.Time)-int64(parent.Time)).Cmp(params.DurationLimit) < 0 {
-		diff.Add(parent.Difficulty, adjust)
-	} else {
-		diff.Sub(parent.Difficulty, adjust)
-	}
-
-	if diff.Cmp(params.MinimumDifficulty) < 0 {
-		return params.MinimumDifficulty
-	}
-
-	return diff
-}
-
 func CalculateTD(block, parent *types.Block) *big.Int {
 	td := new(big.Int).Add(parent.Td, block.Header().Difficulty)
 
@@ -78,6 +64,7 @@ type ChainManager struct {
 	processor    types.BlockProcessor
 	eventMux     *event.TypeMux
 	genesisBlock *types.Block
+	config       *params.ChainConfig
 	// Last known total difficulty
 	mu            sync.RWMutex
 	tsmu          sync.RWMutex
@@ -94,8 +81,8 @@ type ChainManager struct {
 	quit chan struct{}
 }
 
-func NewChainManager(blockDb, stateDb common.Database, mux *event.TypeMux) *ChainManager {
-	bc := &ChainManager{blockDb: blockDb, stateDb: stateDb, genesisBlock: GenesisBlock(stateDb), eventMux: mux, quit: make(chan struct{}), cache: NewBlockCache(blockCacheLimit)}
+func NewChainManager(blockDb, stateDb common.Database, config *params.ChainConfig, mux *event.TypeMux) *ChainManager {
+	bc := &ChainManager{blockDb: blockDb, stateDb: stateDb, genesisBlock: GenesisBlock(stateDb), config: config, eventMux: mux, quit: make(chan struct{}), cache: NewBlockCache(blockCacheLimit)}
 	bc.setLastBlock()
 	bc.transState = bc.State().Copy()
 	// Take ownership of this particular state
@@ -141,6 +128,17 @@ func (self *ChainManager) SetProcessor(proc types.BlockProcessor) {
 	self.processor = proc
 }
 
+// Config returns the consensus rules of the chain.
+func (self *ChainManager) Config() *params.ChainConfig {
+	return self.config
+}
+
+// PendingRules returns the rules of the block following the current one,
+// which new transactions are validated against.
+func (self *ChainManager) PendingRules() *params.RuleSet {
+	return self.config.Rules(new(big.Int).Add(self.CurrentBlock().Number(), common.Big1))
+}
+
 func (self *ChainManager) State() *state.StateDB {
 	return state.New(self.CurrentBlock().Root(), self.stateDb)
 }
@@ -228,8 +226,8 @@ func (bc *ChainManager) NewBlock(coinbase common.Address) *types.Block {
 	parent := bc.currentBlock
 	if parent != nil {
 		header := block.Header()
-		header.Difficulty = CalcDifficulty(block.Header(), parent.Header())
 		header.Number = new(big.Int).Add(parent.Header().Number, common.Big1)
+		header.Difficulty = consensus.CalcDifficulty(bc.config, block.Header(), parent.Header())
 		header.GasLimit = CalcGasLimit(parent, block)
 
 	}
@@ -276,6 +274,15 @@ func (bc *ChainManager) ResetWithGenesisBlock(gb *types.Block) {
 	bc.insert(bc.genesisBlock)
 	bc.currentBlock = bc.genesisBlock
 	bc.makeCache()
+
+	// The pending states still refer to the previous genesis
+	td := gb.Td
+	if td == nil {
+		td = new(big.Int)
+	}
+	bc.setTotalDifficulty(td)
+	bc.setTransState(state.New(gb.Root(), bc.stateDb))
+	bc.setTxState(state.New(gb.Root(), bc.stateDb))
 }
 
 // Export writes the active chain to the given writer.
@@ -345,6 +352,28 @@ func (self *ChainManager) GetBlockHashesFromHash(hash common.Hash, max uint64) (
 	return
 }
 
+// GetHeadersFromHash returns the header of the block with the given hash
+// followed by the headers of up to max-1 of its ancestors, in descending
+// order of block number.
+func (self *ChainManager) GetHeadersFromHash(hash common.Hash, max uint64) (headers []*types.Header) {
+	for block := self.GetBlock(hash); block != nil && uint64(len(headers)) < max; block = self.GetBlock(block.ParentHash()) {
+		headers = append(headers, block.Header())
+		if block.Header().Number.Cmp(common.Big0) <= 0 {
+			break
+		}
+	}
+	return
+}
+
+// GetHeader returns the header of the block with the given hash or nil if
+// the block is unknown.
+func (self *ChainManager) GetHeader(hash common.Hash) *types.Header {
+	if block := self.GetBlock(hash); block != nil {
+		return block.Header()
+	}
+	return nil
+}
+
 func (self *ChainManager) GetBlock(hash common.Hash) *types.Block {
 	if block := self.cache.Get(hash); block != nil {
 		return block
@@ -362,6 +391,92 @@ func (self *ChainManager) GetBlock(hash common.Hash) *types.Block {
 	return (*types.Block)(&block)
 }
 
+// GetBlockReceipts returns the receipts of the block with the given hash or
+// nil if they are unknown.
+func (self *ChainManager) GetBlockReceipts(hash common.Hash) types.Receipts {
+	data, _ := self.blockDb.Get(append(receiptsPre, hash[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	var receipts types.Receipts
+	if err := rlp.DecodeBytes(data, &receipts); err != nil {
+		glog.V(logger.Error).Infof("invalid receipts RLP for hash %x: %v", hash, err)
+		return nil
+	}
+	return receipts
+}
+
+func (self *ChainManager) writeReceipts(hash common.Hash, receipts types.Receipts) {
+	enc, _ := rlp.EncodeToBytes(receipts)
+	self.blockDb.Put(append(receiptsPre, hash[:]...), enc)
+}
+
+// GetNodeData returns the state trie node or contract code stored under the
+// given hash or nil if it is not present in the state database.
+func (self *ChainManager) GetNodeData(hash common.Hash) []byte {
+	data, _ := self.stateDb.Get(hash[:])
+	return data
+}
+
+// InsertReceiptChain writes a contiguous, ascending chain of blocks together
+// with their receipts without executing any of the transactions. The block
+// headers are expected to be validated by the caller; the bodies and receipts
+// are checked against the header roots. The chain head is left untouched, use
+// FastSyncCommitHead once the state of a block became available.
+func (self *ChainManager) InsertReceiptChain(chain types.Blocks, receipts []types.Receipts) error {
+	if len(chain) != len(receipts) {
+		return fmt.Errorf("receipt chain length mismatch: %d blocks, %d receipt sets", len(chain), len(receipts))
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for i, block := range chain {
+		parent := self.GetBlock(block.ParentHash())
+		if parent == nil {
+			return ParentError(block.ParentHash())
+		}
+		if sha := types.DeriveSha(block.Transactions()); sha != block.Header().TxHash {
+			return fmt.Errorf("validating transaction root. received=%x got=%x", block.Header().TxHash, sha)
+		}
+		if sha := types.DeriveSha(receipts[i]); sha != block.Header().ReceiptHash {
+			return fmt.Errorf("validating receipt root. received=%x got=%x", block.Header().ReceiptHash, sha)
+		}
+		block.Td = CalculateTD(block, parent)
+
+		self.write(block)
+		self.writeReceipts(block.Hash(), receipts[i])
+		self.blockDb.Put(append(blockNumPre, block.Number().Bytes()...), block.Hash().Bytes())
+	}
+	if len(chain) > 0 && glog.V(logger.Info) {
+		start, end := chain[0], chain[len(chain)-1]
+		glog.Infof("imported %d receipt chain block(s). #%v [%x / %x]\n", len(chain), end.Number(), start.Hash().Bytes()[:4], end.Hash().Bytes()[:4])
+	}
+	return nil
+}
+
+// FastSyncCommitHead sets the head of the canonical chain to a block inserted
+// through InsertReceiptChain. The state trie of the block must be present.
+func (self *ChainManager) FastSyncCommitHead(hash common.Hash) error {
+	block := self.GetBlock(hash)
+	if block == nil {
+		return fmt.Errorf("non existent block %x", hash[:4])
+	}
+	if len(self.GetNodeData(block.Root())) == 0 {
+		return fmt.Errorf("non existent state %x for block %x", block.Root().Bytes()[:4], hash[:4])
+	}
+	self.mu.Lock()
+	self.setTotalDifficulty(block.Td)
+	self.insert(block)
+	self.setTransState(state.New(block.Root(), self.stateDb))
+	self.setTxState(state.New(block.Root(), self.stateDb))
+	self.mu.Unlock()
+
+	glog.V(logger.Info).Infof("committed fast sync head #%v (%x)\n", block.Number(), hash[:4])
+	go self.eventMux.Post(ChainHeadEvent{block})
+
+	return nil
+}
+
 func (self *ChainManager) GetBlockByNumber(num uint64) *types.Block {
 	self.mu.RLock()
 	defer self.mu.RUnlock()
@@ -9,10 +9,14 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 func init() {
@@ -254,8 +258,8 @@ func TestChainInsertions(t *testing.T) {
 	}
 
 	var eventMux event.TypeMux
-	chainMan := NewChainManager(db, db, &eventMux)
-	txPool := NewTxPool(&eventMux, chainMan.State)
+	chainMan := NewChainManager(db, db, params.DefaultChainConfig, &eventMux)
+	txPool := NewTxPool(&eventMux, chainMan.State, chainMan.PendingRules)
 	blockMan := NewBlockProcessor(db, db, nil, txPool, chainMan, &eventMux)
 	chainMan.SetProcessor(blockMan)
 
@@ -300,8 +304,8 @@ func TestChainMultipleInsertions(t *testing.T) {
 		}
 	}
 	var eventMux event.TypeMux
-	chainMan := NewChainManager(db, db, &eventMux)
-	txPool := NewTxPool(&eventMux, chainMan.State)
+	chainMan := NewChainManager(db, db, params.DefaultChainConfig, &eventMux)
+	txPool := NewTxPool(&eventMux, chainMan.State, chainMan.PendingRules)
 	blockMan := NewBlockProcessor(db, db, nil, txPool, chainMan, &eventMux)
 	chainMan.SetProcessor(blockMan)
 	done := make(chan bool, max)
@@ -329,7 +333,7 @@ func TestGetAncestors(t *testing.T) {
 
 	db, _ := ethdb.NewMemDatabase()
 	var eventMux event.TypeMux
-	chainMan := NewChainManager(db, db, &eventMux)
+	chainMan := NewChainManager(db, db, params.DefaultChainConfig, &eventMux)
 	chain, err := loadChain("valid1", t)
 	if err != nil {
 		fmt.Println(err)
@@ -343,3 +347,70 @@ func TestGetAncestors(t *testing.T) {
 	ancestors := chainMan.GetAncestors(chain[len(chain)-1], 4)
 	fmt.Println(ancestors)
 }
+
+func TestReceiptChainInsertion(t *testing.T) {
+	srcDb, _ := ethdb.NewMemDatabase()
+	bman, err := newCanonical(5, srcDb)
+	if err != nil {
+		t.Fatal("could not make new canonical chain:", err)
+	}
+	var (
+		blocks   types.Blocks
+		receipts []types.Receipts
+	)
+	for i := uint64(1); i <= 5; i++ {
+		block := bman.bc.GetBlockByNumber(i)
+		blocks = append(blocks, block)
+		receipts = append(receipts, bman.bc.GetBlockReceipts(block.Hash()))
+	}
+	pivot := blocks[len(blocks)-1]
+
+	dstDb, _ := ethdb.NewMemDatabase()
+	chainMan := newChainManager(nil, &event.TypeMux{}, dstDb)
+
+	// Tampered receipts must be rejected
+	if err := chainMan.InsertReceiptChain(blocks[:1], []types.Receipts{{types.NewReceipt(nil, common.Big1)}}); err == nil {
+		t.Errorf("invalid receipts accepted")
+	}
+	if err := chainMan.InsertReceiptChain(blocks, receipts); err != nil {
+		t.Fatal("failed to insert receipt chain:", err)
+	}
+	if chainMan.CurrentBlock().Hash() != chainMan.Genesis().Hash() {
+		t.Errorf("head moved by receipt chain insertion")
+	}
+	for i, block := range blocks {
+		if !chainMan.HasBlock(block.Hash()) {
+			t.Errorf("block %d not inserted", i)
+		}
+		if have := types.DeriveSha(chainMan.GetBlockReceipts(block.Hash())); have != block.Header().ReceiptHash {
+			t.Errorf("block %d: receipt root mismatch: have %x, want %x", i, have, block.Header().ReceiptHash)
+		}
+	}
+	// The head can only be committed once the state is present
+	if err := chainMan.FastSyncCommitHead(pivot.Hash()); err == nil {
+		t.Errorf("head committed without state")
+	}
+	sched := state.NewStateSync(pivot.Root(), dstDb)
+	for queue := sched.Missing(0); len(queue) > 0; queue = sched.Missing(0) {
+		results := make([]trie.SyncResult, len(queue))
+		for i, hash := range queue {
+			data, _ := srcDb.Get(hash[:])
+			results[i] = trie.SyncResult{Hash: hash, Data: data}
+		}
+		if _, err := sched.Process(results); err != nil {
+			t.Fatal("failed to process state:", err)
+		}
+	}
+	if err := chainMan.FastSyncCommitHead(pivot.Hash()); err != nil {
+		t.Fatal("failed to commit head:", err)
+	}
+	if chainMan.CurrentBlock().Hash() != pivot.Hash() {
+		t.Errorf("head mismatch: have %x, want %x", chainMan.CurrentBlock().Hash(), pivot.Hash())
+	}
+	if chainMan.Td().Cmp(bman.bc.Td()) != 0 {
+		t.Errorf("td mismatch: have %v, want %v", chainMan.Td(), bman.bc.Td())
+	}
+	if chainMan.State().GetBalance(pivot.Coinbase()).Cmp(bman.bc.State().GetBalance(pivot.Coinbase())) != 0 {
+		t.Errorf("state mismatch after commit")
+	}
+}
@@ -29,20 +29,21 @@ func NewExecution(env vm.Environment, address *common.Address, input []byte, gas
 func (self *Execution) Call(codeAddr common.Address, caller vm.ContextRef) ([]byte, error) {
 	// Retrieve the executing code
 	code := self.env.State().GetCode(codeAddr)
+	codeHash := self.env.State().GetCodeHash(codeAddr)
 
-	return self.exec(&codeAddr, code, caller)
+	return self.exec(&codeAddr, codeHash, code, caller)
 }
 
 func (self *Execution) Create(caller vm.ContextRef) (ret []byte, err error, account *state.StateObject) {
 	// Input must be nil for create
 	code := self.input
 	self.input = nil
-	ret, err = self.exec(nil, code, caller)
+	ret, err = self.exec(nil, common.Hash{}, code, caller)
 	account = self.env.State().GetStateObject(*self.address)
 	return
 }
 
-func (self *Execution) exec(contextAddr *common.Address, code []byte, caller vm.ContextRef) (ret []byte, err error) {
+func (self *Execution) exec(contextAddr *common.Address, codeHash common.Hash, code []byte, caller vm.ContextRef) (ret []byte, err error) {
 	start := time.Now()
 
 	env := self.env
@@ -87,7 +88,7 @@ func (self *Execution) exec(contextAddr *common.Address, code []byte, caller vm.
 	}
 
 	context := vm.NewContext(caller, to, self.value, self.Gas, self.price)
-	context.SetCallCode(contextAddr, code)
+	context.SetCallCode(contextAddr, codeHash, code)
 
 	ret, err = evm.Run(context, self.input)
 	evm.Printf("message call took %v", time.Since(start)).Endl()
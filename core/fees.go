@@ -1,7 +0,0 @@
-package core
-
-import (
-	"math/big"
-)
-
-var BlockReward *big.Int = big.NewInt(1.5e+18)
@@ -110,12 +110,12 @@ func (self *Filter) Find() state.Logs {
 
 func includes(addresses []common.Address, a common.Address) bool {
 	for _, addr := range addresses {
-		if addr != a {
-			return false
+		if addr == a {
+			return true
 		}
 	}
 
-	return true
+	return false
 }
 
 func (self *Filter) FilterLogs(logs state.Logs) state.Logs {
@@ -128,18 +128,14 @@ Logs:
 			continue
 		}
 
-		logTopics := make([]common.Hash, len(self.topics))
-		copy(logTopics, log.Topics)
-
+		// Each position matches any of its topics, an empty
+		// position matches everything.
 		for i, topics := range self.topics {
-			for _, topic := range topics {
-				var match bool
-				if log.Topics[i] == topic {
-					match = true
-				}
-				if !match {
-					continue Logs
-				}
+			if len(topics) == 0 {
+				continue
+			}
+			if len(log.Topics) <= i || !includesTopic(topics, log.Topics[i]) {
+				continue Logs
 			}
 		}
 
@@ -149,6 +145,16 @@ Logs:
 	return ret
 }
 
+func includesTopic(topics []common.Hash, t common.Hash) bool {
+	for _, topic := range topics {
+		if topic == t {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (self *Filter) bloomFilter(block *types.Block) bool {
 	if len(self.address) > 0 {
 		var included bool
@@ -165,6 +171,9 @@ func (self *Filter) bloomFilter(block *types.Block) bool {
 	}
 
 	for _, sub := range self.topics {
+		if len(sub) == 0 {
+			continue
+		}
 		var included bool
 		for _, topic := range sub {
 			if types.BloomLookup(block.Bloom(), topic) {
@@ -1 +1,54 @@
 package core
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+func TestFilterLogs(t *testing.T) {
+	var (
+		addr1  = common.HexToAddress("0x01")
+		addr2  = common.HexToAddress("0x02")
+		topic1 = common.HexToHash("0x11")
+		topic2 = common.HexToHash("0x22")
+		topic3 = common.HexToHash("0x33")
+	)
+	logs := state.Logs{
+		{Address: addr1, Topics: []common.Hash{topic1}},
+		{Address: addr1, Topics: []common.Hash{topic1, topic2}},
+		{Address: addr2, Topics: []common.Hash{topic2, topic3}},
+		{Address: addr2},
+	}
+	tests := []struct {
+		address []common.Address
+		topics  [][]common.Hash
+		want    []int
+	}{
+		{nil, nil, []int{0, 1, 2, 3}},
+		{[]common.Address{addr1}, nil, []int{0, 1}},
+		{[]common.Address{addr1, addr2}, nil, []int{0, 1, 2, 3}},
+		{nil, [][]common.Hash{{topic1}}, []int{0, 1}},
+		{nil, [][]common.Hash{{topic1, topic2}}, []int{0, 1, 2}},
+		{nil, [][]common.Hash{nil, {topic2}}, []int{1}},
+		{nil, [][]common.Hash{nil, {topic2, topic3}}, []int{1, 2}},
+		{[]common.Address{addr2}, [][]common.Hash{{topic1}}, nil},
+	}
+	for i, test := range tests {
+		filter := NewFilter(nil)
+		filter.SetAddress(test.address)
+		filter.SetTopics(test.topics)
+
+		have := filter.FilterLogs(logs)
+		if len(have) != len(test.want) {
+			t.Errorf("test %d: got %d logs, want %d", i, len(have), len(test.want))
+			continue
+		}
+		for j, idx := range test.want {
+			if have[j] != logs[idx] {
+				t.Errorf("test %d: log %d mismatch: have %v, want %v", i, j, have[j], logs[idx])
+			}
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -19,7 +22,38 @@ var ZeroHash256 = make([]byte, 32)
 var ZeroHash160 = make([]byte, 20)
 var ZeroHash512 = make([]byte, 64)
 
+// GenesisAccount is an account in the state of the genesis block.
+type GenesisAccount struct {
+	Address common.Address
+	Balance *big.Int
+	Code    []byte
+}
+
 func GenesisBlock(db common.Database) *types.Block {
+	var accounts map[string]struct {
+		Balance string
+		Code    string
+	}
+	err := json.Unmarshal(genesisData, &accounts)
+	if err != nil {
+		fmt.Println("enable to decode genesis json data:", err)
+		os.Exit(1)
+	}
+
+	alloc := make([]GenesisAccount, 0, len(accounts))
+	for addr, account := range accounts {
+		alloc = append(alloc, GenesisAccount{
+			Address: common.HexToAddress(addr),
+			Balance: common.Big(account.Balance),
+			Code:    common.FromHex(account.Code),
+		})
+	}
+	return CustomGenesisBlock(db, alloc...)
+}
+
+// CustomGenesisBlock creates a genesis block whose state holds only the
+// given accounts. It is meant for private and simulated chains.
+func CustomGenesisBlock(db common.Database, accounts ...GenesisAccount) *types.Block {
 	genesis := types.NewBlock(common.Hash{}, common.Address{}, common.Hash{}, params.GenesisDifficulty, 42, nil)
 	genesis.Header().Number = common.Big0
 	genesis.Header().GasLimit = params.GenesisGasLimit
@@ -32,22 +66,13 @@ func GenesisBlock(db common.Database) *types.Block {
 	genesis.SetTransactions(types.Transactions{})
 	genesis.SetReceipts(types.Receipts{})
 
-	var accounts map[string]struct {
-		Balance string
-		Code    string
-	}
-	err := json.Unmarshal(genesisData, &accounts)
-	if err != nil {
-		fmt.Println("enable to decode genesis json data:", err)
-		os.Exit(1)
-	}
-
 	statedb := state.New(genesis.Root(), db)
-	for addr, account := range accounts {
-		codedAddr := common.Hex2Bytes(addr)
-		accountState := statedb.CreateAccount(common.BytesToAddress(codedAddr))
-		accountState.SetBalance(common.Big(account.Balance))
-		accountState.SetCode(common.FromHex(account.Code))
+	for _, account := range accounts {
+		accountState := statedb.CreateAccount(account.Address)
+		if account.Balance != nil {
+			accountState.SetBalance(account.Balance)
+		}
+		accountState.SetCode(account.Code)
 		statedb.UpdateStateObject(accountState)
 	}
 	statedb.Sync()
@@ -56,6 +81,56 @@ func GenesisBlock(db common.Database) *types.Block {
 	return genesis
 }
 
+// DevGenesisBlock creates a genesis block for development chains like
+// CustomGenesisBlock, but with the lowest difficulty and the current time, so
+// that the difficulty of the first blocks follows their block times.
+func DevGenesisBlock(db common.Database, accounts ...GenesisAccount) *types.Block {
+	genesis := CustomGenesisBlock(db, accounts...)
+	genesis.Header().Difficulty = new(big.Int).Set(params.MinimumDifficulty)
+	genesis.Header().Time = uint64(time.Now().Unix())
+	return genesis
+}
+
+// SetupChainConfig returns the consensus rules of the chain starting at the
+// given genesis block. The rules are stored with the genesis block when the
+// chain is set up, the main chain rules if config is nil. Later the stored
+// rules are returned if config is nil, and a config contradicting them is an
+// error: blocks would be validated with other rules than before.
+func SetupChainConfig(db common.Database, genesis common.Hash, config *params.ChainConfig) (*params.ChainConfig, error) {
+	key := append(configPre, genesis[:]...)
+	stored, _ := db.Get(key)
+	if len(stored) == 0 {
+		if config == nil {
+			config = params.DefaultChainConfig
+		}
+		enc, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		db.Put(key, enc)
+		return config, nil
+	}
+
+	if config == nil {
+		config = new(params.ChainConfig)
+		if err := json.Unmarshal(stored, config); err != nil {
+			return nil, fmt.Errorf("stored chain config: %v", err)
+		}
+		if err := config.Validate(); err != nil {
+			return nil, fmt.Errorf("stored chain config: %v", err)
+		}
+		return config, nil
+	}
+	enc, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(enc, stored) {
+		return nil, fmt.Errorf("chain config differs from the one stored with genesis block %x", genesis[:4])
+	}
+	return config, nil
+}
+
 var genesisData = []byte(`{
 	"0000000000000000000000000000000000000001": {"balance": "1"},
 	"0000000000000000000000000000000000000002": {"balance": "1"},
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestDevGenesisBlock(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	addr := common.HexToAddress("01")
+	genesis := DevGenesisBlock(db, GenesisAccount{Address: addr, Balance: big.NewInt(1000)})
+
+	if genesis.Difficulty().Cmp(params.MinimumDifficulty) != 0 {
+		t.Errorf("difficulty mismatch: have %v, want %v", genesis.Difficulty(), params.MinimumDifficulty)
+	}
+	if age := time.Now().Unix() - genesis.Time(); age < 0 || age > 5 {
+		t.Errorf("time %d is not the current time", genesis.Time())
+	}
+	if genesis.Hash() == CustomGenesisBlock(db, GenesisAccount{Address: addr, Balance: big.NewInt(1000)}).Hash() {
+		t.Errorf("hash not updated with the header")
+	}
+}
+
+func TestSetupChainConfig(t *testing.T) {
+	newConfig := func(sload int64) *params.ChainConfig {
+		config := &params.ChainConfig{Forks: []*params.RuleSet{
+			params.FrontierRules,
+			{Block: big.NewInt(5), Gas: &params.GasTable{SLoad: big.NewInt(sload)}},
+		}}
+		if err := config.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		return config
+	}
+	genesis, other := common.HexToHash("01"), common.HexToHash("02")
+	db, _ := ethdb.NewMemDatabase()
+
+	// a new chain stores the given rules, later starts load them
+	if _, err := SetupChainConfig(db, genesis, newConfig(200)); err != nil {
+		t.Fatal(err)
+	}
+	config, err := SetupChainConfig(db, genesis, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sload := config.Rules(big.NewInt(5)).Gas.SLoad; sload.Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("stored rules not loaded: sload %v at block 5, want 200", sload)
+	}
+	if _, err := SetupChainConfig(db, genesis, newConfig(200)); err != nil {
+		t.Errorf("same rules refused: %v", err)
+	}
+	if _, err := SetupChainConfig(db, genesis, newConfig(300)); err == nil {
+		t.Errorf("rules contradicting the stored ones accepted")
+	}
+	if _, err := SetupChainConfig(db, genesis, params.DefaultChainConfig); err == nil {
+		t.Errorf("main chain rules accepted for a chain with other rules")
+	}
+
+	// a new chain without given rules gets the main chain rules
+	config, err = SetupChainConfig(db, other, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != params.DefaultChainConfig {
+		t.Errorf("new chain without rules: have %v, want the main chain rules", config)
+	}
+	if _, err := SetupChainConfig(db, other, newConfig(200)); err == nil {
+		t.Errorf("rules accepted for a chain set up with the main chain rules")
+	}
+}
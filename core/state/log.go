@@ -28,6 +28,20 @@ func (self *Log) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, []interface{}{self.Address, self.Topics, self.Data})
 }
 
+func (self *Log) DecodeRLP(s *rlp.Stream) error {
+	var l struct {
+		Address common.Address
+		Topics  []common.Hash
+		Data    []byte
+	}
+	if err := s.Decode(&l); err != nil {
+		return err
+	}
+	self.Address, self.Topics, self.Data = l.Address, l.Topics, l.Data
+
+	return nil
+}
+
 func (self *Log) String() string {
 	return fmt.Sprintf(`log: %x %x %x`, self.Address, self.Topics, self.Data)
 }
@@ -316,6 +316,7 @@ func (self *StateObject) Code() []byte {
 
 func (self *StateObject) SetCode(code []byte) {
 	self.code = code
+	self.codeHash = nil
 	self.dirty = true
 }
 
@@ -342,8 +343,13 @@ func (c *StateObject) RlpEncode() []byte {
 	return common.Encode([]interface{}{c.nonce, c.balance, c.Root(), c.CodeHash()})
 }
 
+// CodeHash returns the hash of the code, which is computed once after the
+// code has been set.
 func (c *StateObject) CodeHash() common.Bytes {
-	return crypto.Sha3(c.code)
+	if len(c.codeHash) == 0 {
+		c.codeHash = crypto.Sha3(c.code)
+	}
+	return c.codeHash
 }
 
 func (c *StateObject) RlpDecode(data []byte) {
@@ -107,6 +107,15 @@ func (self *StateDB) GetCode(addr common.Address) []byte {
 	return nil
 }
 
+func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	stateObject := self.GetStateObject(addr)
+	if stateObject != nil {
+		return common.BytesToHash(stateObject.CodeHash())
+	}
+
+	return common.Hash{}
+}
+
 func (self *StateDB) GetState(a common.Address, b common.Hash) []byte {
 	stateObject := self.GetStateObject(a)
 	if stateObject != nil {
@@ -116,6 +125,23 @@ func (self *StateDB) GetState(a common.Address, b common.Hash) []byte {
 	return nil
 }
 
+// GetProof returns the merkle proof of the account at addr within the state
+// trie, proving its absence if it doesn't exist.
+func (self *StateDB) GetProof(addr common.Address) [][]byte {
+	return self.trie.Prove(addr[:])
+}
+
+// GetStorageProof returns the merkle proof of the storage slot key within the
+// storage trie of the account at addr, or nil if the account doesn't exist.
+func (self *StateDB) GetStorageProof(addr common.Address, key common.Hash) [][]byte {
+	stateObject := self.GetStateObject(addr)
+	if stateObject != nil {
+		return stateObject.State.trie.Prove(key[:])
+	}
+
+	return nil
+}
+
 func (self *StateDB) IsDeleted(addr common.Address) bool {
 	stateObject := self.GetStateObject(addr)
 	if stateObject != nil {
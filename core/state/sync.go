@@ -0,0 +1,61 @@
+package state
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// StateSync is the main state synchronisation scheduler, which provides yet the
+// unknown state hashes to retrieve, accepts node data associated with said hashes
+// and reconstructs the state database step by step until all is done.
+type StateSync trie.TrieSync
+
+// NewStateSync create a new state trie download scheduler. Besides the account
+// trie itself it also schedules the storage tries and the code of every account
+// encountered.
+func NewStateSync(root common.Hash, database trie.Backend) *StateSync {
+	var syncer *trie.TrieSync
+
+	callback := func(leaf []byte, parent common.Hash) error {
+		var obj struct {
+			Nonce    uint64
+			Balance  *big.Int
+			Root     common.Hash
+			CodeHash []byte
+		}
+		if err := rlp.Decode(bytes.NewReader(leaf), &obj); err != nil {
+			return err
+		}
+		syncer.AddSubTrie(obj.Root, parent, nil)
+		syncer.AddRawEntry(common.BytesToHash(obj.CodeHash), parent)
+
+		return nil
+	}
+	syncer = trie.NewTrieSync(root, database, callback)
+	return (*StateSync)(syncer)
+}
+
+// Missing retrieves up to max of the known but not yet requested state hashes.
+func (s *StateSync) Missing(max int) []common.Hash {
+	return (*trie.TrieSync)(s).Missing(max)
+}
+
+// Reschedule puts undelivered state hashes back into the retrieval queue.
+func (s *StateSync) Reschedule(hashes []common.Hash) {
+	(*trie.TrieSync)(s).Reschedule(hashes)
+}
+
+// Process injects a batch of retrieved trie nodes data, returning the number
+// of entries committed to the database and any error that occurred.
+func (s *StateSync) Process(list []trie.SyncResult) (int, error) {
+	return (*trie.TrieSync)(s).Process(list)
+}
+
+// Pending returns the number of state entries currently pending for download.
+func (s *StateSync) Pending() int {
+	return (*trie.TrieSync)(s).Pending()
+}
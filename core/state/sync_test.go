@@ -0,0 +1,117 @@
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// testAccount is the data associated with an account used by the state tests.
+type testAccount struct {
+	address common.Address
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+	storage map[common.Hash][]byte
+}
+
+// makeTestState create a sample test state to test node-wise reconstruction.
+func makeTestState() (*ethdb.MemDatabase, common.Hash, []*testAccount) {
+	db, _ := ethdb.NewMemDatabase()
+	state := New(common.Hash{}, db)
+
+	accounts := []*testAccount{}
+	for i := byte(0); i < 96; i++ {
+		obj := state.GetOrNewStateObject(common.BytesToAddress([]byte{i}))
+		acc := &testAccount{address: common.BytesToAddress([]byte{i}), storage: make(map[common.Hash][]byte)}
+
+		obj.AddBalance(big.NewInt(int64(11 * i)))
+		acc.balance = big.NewInt(int64(11 * i))
+
+		obj.SetNonce(uint64(42 * i))
+		acc.nonce = uint64(42 * i)
+
+		if i%3 == 0 {
+			obj.SetCode([]byte{i, i, i, i, i})
+			acc.code = []byte{i, i, i, i, i}
+		}
+		if i%5 == 0 {
+			for j := byte(1); j < 4; j++ {
+				key, value := common.BytesToHash([]byte{i, j}), []byte{i + j}
+				obj.SetState(key, common.NewValue(value))
+				acc.storage[key] = value
+			}
+		}
+		accounts = append(accounts, acc)
+	}
+	state.Update()
+	state.Sync()
+
+	return db, state.Root(), accounts
+}
+
+// checkStateAccounts cross references a reconstructed state with an expected
+// account array.
+func checkStateAccounts(t *testing.T, db common.Database, root common.Hash, accounts []*testAccount) {
+	state := New(root, db)
+	for i, acc := range accounts {
+		if balance := state.GetBalance(acc.address); balance.Cmp(acc.balance) != 0 {
+			t.Errorf("account %d: balance mismatch: have %v, want %v", i, balance, acc.balance)
+		}
+		if nonce := state.GetNonce(acc.address); nonce != acc.nonce {
+			t.Errorf("account %d: nonce mismatch: have %v, want %v", i, nonce, acc.nonce)
+		}
+		if code := state.GetCode(acc.address); !bytes.Equal(code, acc.code) {
+			t.Errorf("account %d: code mismatch: have %x, want %x", i, code, acc.code)
+		}
+		for key, value := range acc.storage {
+			if have := state.GetState(acc.address, key); !bytes.Equal(have, value) {
+				t.Errorf("account %d: storage %x mismatch: have %x, want %x", i, key, have, value)
+			}
+		}
+	}
+}
+
+// Tests that an empty state is not scheduled for syncing.
+func TestEmptyStateSync(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	empty := common.BytesToHash(New(common.Hash{}, db).Trie().Root())
+
+	if req := NewStateSync(empty, db).Missing(1); len(req) != 0 {
+		t.Errorf("content requested for empty state: %v", req)
+	}
+}
+
+// Tests that given a root hash, a state can sync iteratively on a single thread,
+// requesting retrieval tasks and returning all of them in one go.
+func TestIterativeStateSyncIndividual(t *testing.T) { testIterativeStateSync(t, 1) }
+func TestIterativeStateSyncBatched(t *testing.T)    { testIterativeStateSync(t, 100) }
+
+func testIterativeStateSync(t *testing.T, batch int) {
+	srcDb, srcRoot, srcAccounts := makeTestState()
+
+	dstDb, _ := ethdb.NewMemDatabase()
+	sched := NewStateSync(srcRoot, dstDb)
+
+	for queue := sched.Missing(batch); len(queue) > 0; queue = sched.Missing(batch) {
+		results := make([]trie.SyncResult, len(queue))
+		for i, hash := range queue {
+			data, _ := srcDb.Get(hash[:])
+			if data == nil {
+				t.Fatalf("failed to retrieve node data for %x", hash)
+			}
+			results[i] = trie.SyncResult{Hash: hash, Data: data}
+		}
+		if _, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process results: %v", err)
+		}
+	}
+	if pending := sched.Pending(); pending != 0 {
+		t.Fatalf("sync finished with %d pending requests", pending)
+	}
+	checkStateAccounts(t, dstDb, srcRoot, srcAccounts)
+}
@@ -74,13 +74,20 @@ func MessageGasValue(msg Message) *big.Int {
 	return new(big.Int).Mul(msg.Gas(), msg.GasPrice())
 }
 
-func IntrinsicGas(msg Message) *big.Int {
-	igas := new(big.Int).Set(params.TxGas)
+// IntrinsicGas returns the gas a message costs before any code is run, at
+// the prices of the given gas table.
+func IntrinsicGas(table *params.GasTable, msg Message) *big.Int {
+	var igas *big.Int
+	if MessageCreatesContract(msg) {
+		igas = new(big.Int).Set(table.TxCreate)
+	} else {
+		igas = new(big.Int).Set(table.Tx)
+	}
 	for _, byt := range msg.Data() {
 		if byt != 0 {
-			igas.Add(igas, params.TxDataNonZeroGas)
+			igas.Add(igas, table.TxDataNonZero)
 		} else {
-			igas.Add(igas, params.TxDataZeroGas)
+			igas.Add(igas, table.TxDataZero)
 		}
 	}
 
@@ -191,7 +198,7 @@ func (self *StateTransition) transitionState() (ret []byte, usedGas *big.Int, er
 	)
 
 	// Pay intrinsic gas
-	if err = self.UseGas(IntrinsicGas(self.msg)); err != nil {
+	if err = self.UseGas(IntrinsicGas(self.env.Rules().Gas, self.msg)); err != nil {
 		return nil, nil, InvalidTxError(err)
 	}
 
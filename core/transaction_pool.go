@@ -12,6 +12,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/params"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -48,6 +49,8 @@ type TxPool struct {
 	quit chan bool
 	// The state function which will allow us to do some pre checkes
 	currentState func() *state.StateDB
+	// The rules of the next block, which the transactions are checked against
+	pendingRules func() *params.RuleSet
 	// The actual pool
 	txs           map[common.Hash]*types.Transaction
 	invalidHashes *set.Set
@@ -57,7 +60,7 @@ type TxPool struct {
 	eventMux *event.TypeMux
 }
 
-func NewTxPool(eventMux *event.TypeMux, currentStateFn func() *state.StateDB) *TxPool {
+func NewTxPool(eventMux *event.TypeMux, currentStateFn func() *state.StateDB, pendingRulesFn func() *params.RuleSet) *TxPool {
 	return &TxPool{
 		txs:           make(map[common.Hash]*types.Transaction),
 		queueChan:     make(chan *types.Transaction, txPoolQueueSize),
@@ -65,6 +68,7 @@ func NewTxPool(eventMux *event.TypeMux, currentStateFn func() *state.StateDB) *T
 		eventMux:      eventMux,
 		invalidHashes: set.New(),
 		currentState:  currentStateFn,
+		pendingRules:  pendingRulesFn,
 	}
 }
 
@@ -93,7 +97,7 @@ func (pool *TxPool) ValidateTransaction(tx *types.Transaction) error {
 		return ErrInsufficientFunds
 	}
 
-	if tx.GasLimit.Cmp(IntrinsicGas(tx)) < 0 {
+	if tx.GasLimit.Cmp(IntrinsicGas(pool.pendingRules().Gas, tx)) < 0 {
 		return ErrIntrinsicGas
 	}
 
@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 func transaction() *types.Transaction {
@@ -23,7 +24,7 @@ func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 
 	var m event.TypeMux
 	key, _ := crypto.GenerateKey()
-	return NewTxPool(&m, func() *state.StateDB { return statedb }), key
+	return NewTxPool(&m, func() *state.StateDB { return statedb }, func() *params.RuleSet { return params.FrontierRules }), key
 }
 
 func TestInvalidTransactions(t *testing.T) {
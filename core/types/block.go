@@ -149,6 +149,18 @@ func NewBlockWithHeader(header *Header) *Block {
 	return &Block{header: header}
 }
 
+// WithBody returns a new block with the given header and body contents.
+// Unlike SetTransactions and SetUncles, the header's TxHash and UncleHash
+// are left untouched, so the caller is responsible for checking that the
+// body matches them.
+func (self *Block) WithBody(transactions []*Transaction, uncles []*Header) *Block {
+	return &Block{
+		header:       self.header,
+		transactions: transactions,
+		uncles:       uncles,
+	}
+}
+
 func (self *Block) ValidateFields() error {
 	if self.header == nil {
 		return fmt.Errorf("header is nil")
@@ -211,7 +223,13 @@ func (self *Block) Uncles() []*Header {
 
 func (self *Block) SetUncles(uncleHeaders []*Header) {
 	self.uncles = uncleHeaders
-	self.header.UncleHash = rlpHash(uncleHeaders)
+	self.header.UncleHash = CalcUncleHash(uncleHeaders)
+}
+
+// CalcUncleHash returns the hash committed to by a header's UncleHash
+// field for the given list of uncle headers.
+func CalcUncleHash(uncles []*Header) common.Hash {
+	return rlpHash(uncles)
 }
 
 func (self *Block) Transactions() Transactions {
@@ -140,7 +140,12 @@ func (tx *Transaction) PublicKey() []byte {
 	return pubkey
 }
 
+// SetSignatureValues sets V, R and S from a 65 byte [R || S || V] signature
+// with a recovery id V of 0 or 1.
 func (tx *Transaction) SetSignatureValues(sig []byte) error {
+	if len(sig) != 65 || sig[64] > 1 {
+		return fmt.Errorf("invalid signature: %x", sig)
+	}
 	tx.R = common.Bytes2Big(sig[:32])
 	tx.S = common.Bytes2Big(sig[32:64])
 	tx.V = sig[64] + 27
@@ -153,8 +158,7 @@ func (tx *Transaction) SignECDSA(prv *ecdsa.PrivateKey) error {
 	if err != nil {
 		return err
 	}
-	tx.SetSignatureValues(sig)
-	return nil
+	return tx.SetSignatureValues(sig)
 }
 
 // TODO: remove
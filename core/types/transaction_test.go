@@ -109,3 +109,26 @@ func TestRecipientNormal(t *testing.T) {
 		t.Error("derived address doesn't match")
 	}
 }
+
+func TestSetSignatureValuesInvalid(t *testing.T) {
+	key, _ := defaultTestKey()
+	sig, err := crypto.Sign(emptyTx.Hash().Bytes(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badV := common.CopyBytes(sig)
+	badV[64] = 27
+	for _, bad := range [][]byte{nil, sig[:64], append(common.CopyBytes(sig), 0), badV} {
+		tx := *emptyTx
+		if err := tx.SetSignatureValues(bad); err == nil {
+			t.Errorf("no error for signature %x", bad)
+		}
+		if tx.R.Sign() != 0 || tx.S.Sign() != 0 {
+			t.Errorf("signature %x: values set", bad)
+		}
+	}
+	tx := *emptyTx
+	if err := tx.SetSignatureValues(sig); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+}
@@ -1,25 +1,94 @@
 package vm
 
 import (
-	"math/big"
+	"sync"
 
-	"gopkg.in/fatih/set.v0"
+	"github.com/ethereum/go-ethereum/common"
 )
 
+// jumpdestCacheSize is the number of contracts whose jump destinations are
+// kept in the cache shared by all VMs.
+const jumpdestCacheSize = 1024
+
+var jumpdests = newDestinations(jumpdestCacheSize)
+
+// bitvec is a bit vector with a bit set for every position of code that holds
+// a JUMPDEST instruction.
+type bitvec []byte
+
+func (bits bitvec) set(pos uint64) {
+	bits[pos/8] |= 0x80 >> (pos % 8)
+}
+
+// has checks whether dest is a valid jump destination.
+func (bits bitvec) has(dest *word) bool {
+	if !dest.IsUint64() {
+		return false
+	}
+	pos := dest.Uint64()
+	if pos/8 >= uint64(len(bits)) {
+		return false
+	}
+	return bits[pos/8]&(0x80>>(pos%8)) != 0
+}
+
+// destinations caches the jump destinations of contract code, keyed by the
+// hash of the code. When the cache is full, the oldest entry is dropped.
 type destinations struct {
-	set *set.Set
+	size   int
+	hashes []common.Hash // in insertion order, used as a ring
+	next   int           // position of the oldest hash once the ring is full
+	dests  map[common.Hash]bitvec
+
+	mu sync.RWMutex
 }
 
-func (d *destinations) Has(dest *big.Int) bool {
-	return d.set.Has(string(dest.Bytes()))
+func newDestinations(size int) *destinations {
+	return &destinations{size: size, dests: make(map[common.Hash]bitvec, size)}
+}
+
+// get returns the jump destinations of the code with the given hash, analysing
+// the code if it isn't cached. Code with a zero hash, like the init code of a
+// contract, is analysed but not cached.
+func (d *destinations) get(hash common.Hash, code []byte) bitvec {
+	if hash == (common.Hash{}) {
+		return analyseJumpDests(code)
+	}
+
+	d.mu.RLock()
+	bits, ok := d.dests[hash]
+	d.mu.RUnlock()
+	if ok {
+		return bits
+	}
+
+	bits = analyseJumpDests(code)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.dests[hash]; !ok {
+		if len(d.hashes) < d.size {
+			d.hashes = append(d.hashes, hash)
+		} else {
+			delete(d.dests, d.hashes[d.next])
+			d.hashes[d.next] = hash
+			d.next = (d.next + 1) % d.size
+		}
+		d.dests[hash] = bits
+	}
+	return bits
 }
 
-func (d *destinations) Add(dest *big.Int) {
-	d.set.Add(string(dest.Bytes()))
+func (d *destinations) len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.dests)
 }
 
-func analyseJumpDests(code []byte) (dests *destinations) {
-	dests = &destinations{set.New()}
+// analyseJumpDests finds the JUMPDEST instructions of code, skipping the data
+// of PUSH instructions.
+func analyseJumpDests(code []byte) bitvec {
+	bits := make(bitvec, len(code)/8+1)
 
 	for pc := uint64(0); pc < uint64(len(code)); pc++ {
 		var op OpCode = OpCode(code[pc])
@@ -29,8 +98,8 @@ func analyseJumpDests(code []byte) (dests *destinations) {
 
 			pc += a
 		case JUMPDEST:
-			dests.Add(big.NewInt(int64(pc)))
+			bits.set(pc)
 		}
 	}
-	return
+	return bits
 }
@@ -0,0 +1,92 @@
+package vm
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestJumpDestAnalysis(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), byte(JUMPDEST), // 0: JUMPDEST in push data
+		byte(JUMPDEST),                    // 2
+		byte(PUSH2), 0x01, byte(JUMPDEST), // 3: JUMPDEST in push data
+		byte(STOP), byte(JUMPDEST), // 7
+		byte(JUMPDEST), // 8, in the second byte of the bit vector
+		byte(PUSH32),   // 9: push data runs past the end of the code
+	}
+	dests := analyseJumpDests(code)
+	for pos := 0; pos < len(code)+16; pos++ {
+		want := pos == 2 || pos == 7 || pos == 8
+		if has := dests.has(new(word).SetUint64(uint64(pos))); has != want {
+			t.Errorf("position %d: has jump destination %v, want %v", pos, has, want)
+		}
+	}
+	if dests.has(&word{2, 1}) {
+		t.Errorf("position 2^64+2 is a jump destination")
+	}
+}
+
+func TestJumpDestCache(t *testing.T) {
+	cache := newDestinations(4)
+
+	// concurrent VMs analysing the same contracts
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			code := []byte{byte(PUSH1), byte(i % 8), byte(JUMPDEST)}
+			hash := common.BytesToHash(crypto.Sha3(code))
+			if !cache.get(hash, code).has(new(word).SetUint64(2)) {
+				t.Errorf("code %x: missing jump destination", code)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := cache.len(); n != 4 {
+		t.Errorf("cache holds %d entries, want 4", n)
+	}
+
+	// the cached analysis is returned for the same code
+	code := []byte{byte(JUMPDEST), byte(STOP)}
+	hash := common.BytesToHash(crypto.Sha3(code))
+	dests := cache.get(hash, code)
+	if cached := cache.get(hash, code); &cached[0] != &dests[0] {
+		t.Errorf("code was analysed again")
+	}
+	// code without hash is not cached
+	cache.get(common.Hash{}, code)
+	if cache.get(common.Hash{}, code); cache.len() != 4 {
+		t.Errorf("code without hash was cached")
+	}
+}
+
+// benchCode returns 4KB of code mixing push instructions and their data.
+func benchCode() []byte {
+	code := make([]byte, 4096)
+	for i := range code {
+		code[i] = byte(i)
+	}
+	return code
+}
+
+func BenchmarkJumpDestAnalysis(b *testing.B) {
+	code := benchCode()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		analyseJumpDests(code)
+	}
+}
+
+func BenchmarkJumpDestCached(b *testing.B) {
+	code := benchCode()
+	cache := newDestinations(16)
+	hash := common.BytesToHash(crypto.Sha3(code))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.get(hash, code)
+	}
+}
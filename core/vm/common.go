@@ -6,6 +6,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // Global Debug flag indicating Debug VM (full logging)
@@ -16,6 +17,7 @@ type Type byte
 const (
 	StdVmTy Type = iota
 	JitVmTy
+	CompiledVmTy
 	MaxVmTy
 
 	LogTyPretty byte = 0x1
@@ -35,9 +37,16 @@ var (
 )
 
 func NewVm(env Environment) VirtualMachine {
+	if GenerateStructLogs {
+		// only the interpreter reports its steps
+		return New(env)
+	}
+
 	switch env.VmType() {
 	case JitVmTy:
 		return NewJitVm(env)
+	case CompiledVmTy:
+		return NewCompiledVm(env)
 	default:
 		glog.V(0).Infoln("unsupported vm type %d", env.VmType())
 		fallthrough
@@ -46,12 +55,27 @@ func NewVm(env Environment) VirtualMachine {
 	}
 }
 
-func calcMemSize(off, l *big.Int) *big.Int {
-	if l.Cmp(common.Big0) == 0 {
+// disabledOps returns the op codes which the rules turn into invalid
+// instructions, or nil if all op codes are enabled.
+func disabledOps(rules *params.RuleSet) *[256]bool {
+	if len(rules.DisabledOps) == 0 {
+		return nil
+	}
+	var ops [256]bool
+	for _, name := range rules.DisabledOps {
+		if op, ok := StringToOp(name); ok {
+			ops[op] = true
+		}
+	}
+	return &ops
+}
+
+func calcMemSize(off, l *word) *big.Int {
+	if l.IsZero() {
 		return common.Big0
 	}
 
-	return new(big.Int).Add(off, l)
+	return new(big.Int).Add(off.Big(), l.Big())
 }
 
 // Simple helper
@@ -70,12 +94,24 @@ func toValue(val *big.Int) interface{} {
 	return val
 }
 
-func getData(data []byte, start, size *big.Int) []byte {
-	dlen := big.NewInt(int64(len(data)))
+// getData returns size bytes of data from start, padded with zeros where it
+// runs past the end of data.
+func getData(data []byte, start, size uint64) []byte {
+	length := uint64(len(data))
+	if start > length {
+		start = length
+	}
+	end := start + size
+	if end > length || end < start {
+		end = length
+	}
+	return common.RightPadBytes(data[start:end], int(size))
+}
 
-	s := common.BigMin(start, dlen)
-	e := common.BigMin(new(big.Int).Add(s, size), dlen)
-	return common.RightPadBytes(data[s.Uint64():e.Uint64()], int(size.Uint64()))
+// wordToAddress returns the address held in the lower 20 bytes of w.
+func wordToAddress(w *word) common.Address {
+	b := w.Bytes32()
+	return common.BytesToAddress(b[12:])
 }
 
 func UseGas(gas, amount *big.Int) bool {
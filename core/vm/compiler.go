@@ -0,0 +1,195 @@
+package vm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// programCacheSize is the number of compiled contracts kept in the cache
+// shared by all compiled VMs.
+const programCacheSize = 1024
+
+var programs = newProgramCache(programCacheSize)
+
+// instruction is a decoded operation of a program.
+type instruction struct {
+	op    OpCode
+	pc    uint64 // position of the instruction in the code
+	value word   // immediate of PUSH instructions
+
+	// minStack is the number of stack items the instruction needs and
+	// pop/push are used for the stack limit check, see baseCheck.
+	minStack, pop, push int
+
+	// dynamic instructions have gas costs that depend on the stack, the
+	// memory or the state. They are calculated at run time and not part
+	// of the static gas.
+	dynamic bool
+	static  uint64 // gas of the instruction itself
+	rest    uint64 // static gas of the following instructions in the block
+	leader  bool   // whether the instruction starts a basic block
+}
+
+// program is contract code decoded into basic blocks. A basic block starts at
+// the beginning of the code, at a JUMPDEST or after a jump and ends with the
+// next jump, halting instruction or the start of the next block.
+type program struct {
+	instrs []instruction
+	dests  []int // instruction index of every JUMPDEST position, -1 elsewhere
+}
+
+// jumpTarget returns the index of the instruction at the jump destination
+// dest, or -1 if dest is not a valid destination.
+func (p *program) jumpTarget(dest *word) int {
+	if !dest.IsUint64() || dest.Uint64() >= uint64(len(p.dests)) {
+		return -1
+	}
+	return p.dests[dest.Uint64()]
+}
+
+// compile decodes code into a program.
+func compile(code []byte) *program {
+	prog := &program{
+		instrs: make([]instruction, 0, len(code)+1),
+		dests:  make([]int, len(code)),
+	}
+	for i := range prog.dests {
+		prog.dests[i] = -1
+	}
+
+	leader := true
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := OpCode(code[pc])
+		in := newInstruction(op, pc)
+		in.leader = leader || op == JUMPDEST
+
+		if op >= PUSH1 && op <= PUSH32 {
+			a := uint64(op - PUSH1 + 1)
+			in.value.SetBytes(getData(code, pc+1, a))
+			pc += a
+		}
+		if op == JUMPDEST {
+			prog.dests[in.pc] = len(prog.instrs)
+		}
+		prog.instrs = append(prog.instrs, in)
+
+		switch op {
+		case JUMP, JUMPI, STOP, RETURN, SUICIDE:
+			leader = true
+		default:
+			leader = false
+		}
+	}
+	// Running off the end of the code stops the execution.
+	stop := newInstruction(STOP, uint64(len(code)))
+	stop.leader = leader
+	prog.instrs = append(prog.instrs, stop)
+
+	// Sum up the static gas of the blocks, back to front.
+	var rest uint64
+	for i := len(prog.instrs) - 1; i >= 0; i-- {
+		in := &prog.instrs[i]
+		in.rest = rest
+		if in.leader {
+			rest = 0
+		} else {
+			rest += in.static
+		}
+	}
+	return prog
+}
+
+func newInstruction(op OpCode, pc uint64) instruction {
+	in := instruction{op: op, pc: pc}
+
+	// Follow baseCheck and calculateGasAndSize.
+	base := op
+	if op >= PUSH1 && op <= PUSH32 {
+		base = PUSH1
+	}
+	if op >= DUP1 && op <= DUP16 {
+		base = DUP1
+	}
+	if r, ok := _baseCheck[base]; ok {
+		in.minStack, in.pop, in.push = r.stackPop, r.stackPop, r.stackPush
+		in.static = r.gas.Uint64()
+	}
+
+	switch op {
+	case SWAP1, SWAP2, SWAP3, SWAP4, SWAP5, SWAP6, SWAP7, SWAP8, SWAP9, SWAP10, SWAP11, SWAP12, SWAP13, SWAP14, SWAP15, SWAP16:
+		in.minStack = int(op - SWAP1 + 2)
+		in.static = GasFastestStep.Uint64()
+	case DUP1, DUP2, DUP3, DUP4, DUP5, DUP6, DUP7, DUP8, DUP9, DUP10, DUP11, DUP12, DUP13, DUP14, DUP15, DUP16:
+		in.minStack = int(op - DUP1 + 1)
+		in.static = GasFastestStep.Uint64()
+	case LOG0, LOG1, LOG2, LOG3, LOG4, EXP, SSTORE, SUICIDE, MLOAD, MSTORE8, MSTORE, RETURN, SHA3,
+		CALLDATACOPY, CODECOPY, EXTCODECOPY, CREATE, CALL, CALLCODE,
+		// programs are shared by all rule sets, the gas table is
+		// consulted at run time
+		BALANCE, EXTCODESIZE, SLOAD:
+		in.dynamic = true
+		in.static = 0
+	}
+	return in
+}
+
+// checkStack validates the stack before the instruction is executed.
+func (in *instruction) checkStack(st *stack) error {
+	if err := st.require(in.minStack); err != nil {
+		return err
+	}
+	if in.push > 0 && len(st.data)-in.pop+in.push > int(params.StackLimit.Int64())+1 {
+		return fmt.Errorf("stack limit reached %d (%d)", len(st.data), params.StackLimit.Int64())
+	}
+	return nil
+}
+
+// programCache caches compiled programs, keyed by the hash of their code.
+// When the cache is full, the oldest entry is dropped.
+type programCache struct {
+	size     int
+	hashes   []common.Hash // in insertion order, used as a ring
+	next     int           // position of the oldest hash once the ring is full
+	programs map[common.Hash]*program
+
+	mu sync.RWMutex
+}
+
+func newProgramCache(size int) *programCache {
+	return &programCache{size: size, programs: make(map[common.Hash]*program, size)}
+}
+
+// get returns the program of the code with the given hash, compiling the
+// code if it isn't cached. Code with a zero hash, like the init code of a
+// contract, is compiled but not cached.
+func (c *programCache) get(hash common.Hash, code []byte) *program {
+	if hash == (common.Hash{}) {
+		return compile(code)
+	}
+
+	c.mu.RLock()
+	prog, ok := c.programs[hash]
+	c.mu.RUnlock()
+	if ok {
+		return prog
+	}
+
+	prog = compile(code)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.programs[hash]; !ok {
+		if len(c.hashes) < c.size {
+			c.hashes = append(c.hashes, hash)
+		} else {
+			delete(c.programs, c.hashes[c.next])
+			c.hashes[c.next] = hash
+			c.next = (c.next + 1) % c.size
+		}
+		c.programs[hash] = prog
+	}
+	return prog
+}
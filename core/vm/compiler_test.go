@@ -0,0 +1,94 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestCompile(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), 0x06, // 0
+		byte(PUSH1), 0x01, // 2
+		byte(ADD),               // 4
+		byte(JUMP),              // 5
+		byte(JUMPDEST),          // 6
+		byte(PUSH2), 0x5b, 0x5b, // 7: JUMPDESTs in push data
+		byte(MSTORE), // 10
+		byte(STOP),   // 11
+	}
+	want := []instruction{
+		{op: PUSH1, pc: 0, value: word{6}, static: 3, rest: 14, leader: true},
+		{op: PUSH1, pc: 2, value: word{1}, static: 3, rest: 11},
+		{op: ADD, pc: 4, static: 3, rest: 8},
+		{op: JUMP, pc: 5, static: 8},
+		{op: JUMPDEST, pc: 6, static: 1, rest: 3, leader: true},
+		{op: PUSH2, pc: 7, value: word{0x5b5b}, static: 3},
+		{op: MSTORE, pc: 10, dynamic: true},
+		{op: STOP, pc: 11},
+		{op: STOP, pc: 12, leader: true}, // end of the code
+	}
+
+	prog := compile(code)
+	if len(prog.instrs) != len(want) {
+		t.Fatalf("compiled %d instructions, want %d", len(prog.instrs), len(want))
+	}
+	for i, in := range prog.instrs {
+		w := want[i]
+		if in.op != w.op || in.pc != w.pc || in.value != w.value {
+			t.Errorf("instruction %d: got %v at %d (%v), want %v at %d (%v)", i, in.op, in.pc, &in.value, w.op, w.pc, &w.value)
+		}
+		if in.static != w.static || in.rest != w.rest || in.leader != w.leader || in.dynamic != w.dynamic {
+			t.Errorf("instruction %d (%v): got gas %d+%d leader %v dynamic %v, want gas %d+%d leader %v dynamic %v",
+				i, in.op, in.static, in.rest, in.leader, in.dynamic, w.static, w.rest, w.leader, w.dynamic)
+		}
+	}
+
+	for pos := uint64(0); pos < uint64(len(code))+2; pos++ {
+		want := -1
+		if pos == 6 {
+			want = 4
+		}
+		if target := prog.jumpTarget(new(word).SetUint64(pos)); target != want {
+			t.Errorf("jump target of %d: got %d, want %d", pos, target, want)
+		}
+	}
+	if target := prog.jumpTarget(&word{6, 1}); target != -1 {
+		t.Errorf("jump target of 2^64+6: got %d, want -1", target)
+	}
+}
+
+func TestCompileTruncatedPush(t *testing.T) {
+	prog := compile([]byte{byte(PUSH3), 0x01, 0x02})
+	if len(prog.instrs) != 2 {
+		t.Fatalf("compiled %d instructions, want 2", len(prog.instrs))
+	}
+	if v := prog.instrs[0].value; v != (word{0x010200}) {
+		t.Errorf("push value: got %v, want %v", &v, &word{0x010200})
+	}
+	if stop := prog.instrs[1]; stop.op != STOP || stop.pc != 3 {
+		t.Errorf("got %v at %d, want STOP at 3", stop.op, stop.pc)
+	}
+}
+
+func TestProgramCache(t *testing.T) {
+	cache := newProgramCache(2)
+
+	code := []byte{byte(PUSH1), 0x01, byte(STOP)}
+	hash := common.BytesToHash(crypto.Sha3(code))
+	if prog := cache.get(hash, code); prog != cache.get(hash, code) {
+		t.Errorf("code was compiled again")
+	}
+	// code without hash is not cached
+	if prog := cache.get(common.Hash{}, code); prog == cache.get(common.Hash{}, code) {
+		t.Errorf("code without hash was cached")
+	}
+	for i := 0; i < 3; i++ {
+		code := []byte{byte(PUSH1), byte(i)}
+		cache.get(common.BytesToHash(crypto.Sha3(code)), code)
+	}
+	if n := len(cache.programs); n != 2 {
+		t.Errorf("cache holds %d programs, want 2", n)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -21,8 +22,11 @@ type Environment interface {
 	Time() int64
 	Difficulty() *big.Int
 	GasLimit() *big.Int
+	// Rules returns the consensus rules of the current block.
+	Rules() *params.RuleSet
 	Transfer(from, to Account, amount *big.Int) error
 	AddLog(*state.Log)
+	AddStructLog(StructLog)
 
 	VmType() Type
 
@@ -21,7 +21,7 @@ var (
 	GasContractByte = big.NewInt(200)
 )
 
-func baseCheck(op OpCode, stack *stack, gas *big.Int) error {
+func baseCheck(op OpCode, stack *stack, gas *big.Int, table *params.GasTable) error {
 	// PUSH and DUP are a bit special. They all cost the same but we do want to have checking on stack push limit
 	// PUSH is also allowed to calculate the same price for all PUSHes
 	// DUP requirements are handled elsewhere (except for the stack limit check)
@@ -42,11 +42,31 @@ func baseCheck(op OpCode, stack *stack, gas *big.Int) error {
 			return fmt.Errorf("stack limit reached %d (%d)", len(stack.data), params.StackLimit.Int64())
 		}
 
-		gas.Add(gas, r.gas)
+		gas.Add(gas, basePrice(op, r.gas, table))
 	}
 	return nil
 }
 
+// basePrice returns the static gas of op, which is taken from the gas table
+// of the rules for the operations listed in it.
+func basePrice(op OpCode, gas *big.Int, table *params.GasTable) *big.Int {
+	switch op {
+	case BALANCE:
+		return table.Balance
+	case EXTCODESIZE:
+		return table.ExtcodeSize
+	case EXTCODECOPY:
+		return table.ExtcodeCopy
+	case SLOAD:
+		return table.SLoad
+	case CALL, CALLCODE:
+		return table.Calls
+	case SUICIDE:
+		return table.Suicide
+	}
+	return gas
+}
+
 func toWordSize(size *big.Int) *big.Int {
 	tmp := new(big.Int)
 	tmp.Add(size, u256(31))
@@ -0,0 +1,42 @@
+package vm
+
+import "math/big"
+
+// GenerateStructLogs makes the interpreter report every step it executes to
+// Environment.AddStructLog. Other VM types run the interpreter instead while
+// it is set.
+var GenerateStructLogs bool
+
+// StructLog is a step of the execution reported by the interpreter.
+type StructLog struct {
+	Pc      uint64
+	Op      OpCode
+	Gas     *big.Int // gas left before the step
+	GasCost *big.Int
+	Memory  []byte
+	Stack   []*big.Int // bottom item first
+	Depth   int
+	Err     error
+}
+
+// newStructLog captures the state of the interpreter before a step.
+func newStructLog(env Environment, pc uint64, op OpCode, gas, cost *big.Int, mem *Memory, st *stack, err error) StructLog {
+	memory := make([]byte, mem.Len())
+	copy(memory, mem.Data())
+
+	items := make([]*big.Int, st.len())
+	for i := range items {
+		items[i] = st.data[i].Big()
+	}
+
+	return StructLog{
+		Pc:      pc,
+		Op:      op,
+		Gas:     new(big.Int).Set(gas),
+		GasCost: new(big.Int).Set(cost),
+		Memory:  memory,
+		Stack:   items,
+		Depth:   env.Depth(),
+		Err:     err,
+	}
+}
@@ -2,7 +2,6 @@ package vm
 
 import (
 	"fmt"
-	"math/big"
 )
 
 func newStack() *stack {
@@ -10,24 +9,25 @@ func newStack() *stack {
 }
 
 type stack struct {
-	data []*big.Int
+	data []word
 	ptr  int
 }
 
-func (st *stack) push(d *big.Int) {
+func (st *stack) push(d *word) {
 	// NOTE push limit (1024) is checked in baseCheck
-	stackItem := new(big.Int).Set(d)
 	if len(st.data) > st.ptr {
-		st.data[st.ptr] = stackItem
+		st.data[st.ptr] = *d
 	} else {
-		st.data = append(st.data, stackItem)
+		st.data = append(st.data, *d)
 	}
 	st.ptr++
 }
 
-func (st *stack) pop() (ret *big.Int) {
+// pop removes the top item from the stack. The returned word points into the
+// stack and is overwritten by the next push.
+func (st *stack) pop() (ret *word) {
 	st.ptr--
-	ret = st.data[st.ptr]
+	ret = &st.data[st.ptr]
 	return
 }
 
@@ -40,11 +40,12 @@ func (st *stack) swap(n int) {
 }
 
 func (st *stack) dup(n int) {
-	st.push(st.data[st.len()-n])
+	st.push(&st.data[st.len()-n])
 }
 
-func (st *stack) peek() *big.Int {
-	return st.data[st.len()-1]
+// peek returns the top item of the stack, which can be modified in place.
+func (st *stack) peek() *word {
+	return &st.data[st.len()-1]
 }
 
 func (st *stack) require(n int) error {
@@ -57,8 +58,8 @@ func (st *stack) require(n int) error {
 func (st *stack) Print() {
 	fmt.Println("### stack ###")
 	if len(st.data) > 0 {
-		for i, val := range st.data {
-			fmt.Printf("%-3d  %v\n", i, val)
+		for i := range st.data {
+			fmt.Printf("%-3d  %v\n", i, &st.data[i])
 		}
 	} else {
 		fmt.Println("-- empty --")
@@ -2,6 +2,8 @@ package vm
 
 import (
 	"fmt"
+
+	"github.com/ethereum/go-ethereum/params"
 )
 
 type OpCode byte
@@ -332,3 +334,27 @@ func (o OpCode) String() string {
 
 	return str
 }
+
+var stringToOp = make(map[string]OpCode, len(opCodeToString))
+
+func init() {
+	for op, str := range opCodeToString {
+		stringToOp[str] = op
+	}
+	params.IsOpCode = func(name string) bool {
+		_, ok := stringToOp[name]
+		return ok
+	}
+}
+
+// StringToOp returns the op code with the given name, e.g. "PUSH1".
+func StringToOp(str string) (OpCode, bool) {
+	op, ok := stringToOp[str]
+	return op, ok
+}
+
+// IsPush reports whether the op code is one of the PUSH instructions, which
+// are followed by an immediate value in the code.
+func (o OpCode) IsPush() bool {
+	return o >= PUSH1 && o <= PUSH32
+}
@@ -74,20 +74,21 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 	var (
 		op OpCode
 
-		destinations = analyseJumpDests(context.Code)
+		destinations = jumpdests.get(context.CodeHash, context.Code)
+		disabled     = disabledOps(self.env.Rules())
 		mem          = NewMemory()
 		stack        = newStack()
-		pc           = new(big.Int)
+		pc           = uint64(0)
 		statedb      = self.env.State()
 
-		jump = func(from *big.Int, to *big.Int) error {
-			nop := context.GetOp(to)
-			if !destinations.Has(to) {
+		jump = func(from uint64, to *word) error {
+			if !destinations.has(to) {
+				nop := context.GetOp(to.SaturatingUint64())
 				return fmt.Errorf("invalid jump destination (%v) %v", nop, to)
 			}
 
 			self.Printf(" ~> %v", to)
-			pc = to
+			pc = to.Uint64()
 
 			self.Endl()
 
@@ -101,15 +102,20 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 	}
 
 	for {
-		// The base for all big integer arithmetic
-		base := new(big.Int)
-
 		// Get the memory location of pc
 		op = context.GetOp(pc)
 
 		self.Printf("(pc) %-3d -o- %-14s (m) %-4d (s) %-4d ", pc, op.String(), mem.Len(), stack.len())
-		newMemSize, gas, err := self.calculateGasAndSize(context, caller, op, statedb, mem, stack)
+		if disabled != nil && disabled[op] {
+			self.Printf("(pc) %-3v Invalid opcode %x\n", pc, op).Endl()
+
+			return nil, fmt.Errorf("Invalid opcode %x", op)
+		}
+		newMemSize, gas, err := calculateGasAndSize(self.env, context, caller, op, statedb, mem, stack)
 		if err != nil {
+			if GenerateStructLogs {
+				self.env.AddStructLog(newStructLog(self.env, pc, op, context.Gas, common.Big0, mem, stack, err))
+			}
 			return nil, err
 		}
 
@@ -119,321 +125,231 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 			self.Endl()
 
 			tmp := new(big.Int).Set(context.Gas)
+			err := OOG(gas, tmp)
+			if GenerateStructLogs {
+				self.env.AddStructLog(newStructLog(self.env, pc, op, tmp, gas, mem, stack, err))
+			}
 
 			context.UseGas(context.Gas)
 
-			return context.Return(nil), OOG(gas, tmp)
+			return context.Return(nil), err
+		}
+		if GenerateStructLogs {
+			self.env.AddStructLog(newStructLog(self.env, pc, op, new(big.Int).Add(context.Gas, gas), gas, mem, stack, nil))
 		}
 
 		mem.Resize(newMemSize.Uint64())
 
+		// Operations pop their first operands and replace the last one on
+		// the stack with the result.
 		switch op {
 		// 0x20 range
 		case ADD:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v + %v", y, x)
 
-			base.Add(x, y)
-
-			U256(base)
+			y.Add(x, y)
 
-			self.Printf(" = %v", base)
-			// pop result back on the stack
-			stack.push(base)
+			self.Printf(" = %v", y)
 		case SUB:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v - %v", y, x)
 
-			base.Sub(x, y)
+			y.Sub(x, y)
 
-			U256(base)
-
-			self.Printf(" = %v", base)
-			// pop result back on the stack
-			stack.push(base)
+			self.Printf(" = %v", y)
 		case MUL:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v * %v", y, x)
 
-			base.Mul(x, y)
-
-			U256(base)
+			y.Mul(x, y)
 
-			self.Printf(" = %v", base)
-			// pop result back on the stack
-			stack.push(base)
+			self.Printf(" = %v", y)
 		case DIV:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v / %v", x, y)
 
-			if y.Cmp(common.Big0) != 0 {
-				base.Div(x, y)
-			}
-
-			U256(base)
+			y.Div(x, y)
 
-			self.Printf(" = %v", base)
-			// pop result back on the stack
-			stack.push(base)
+			self.Printf(" = %v", y)
 		case SDIV:
-			x, y := S256(stack.pop()), S256(stack.pop())
-
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v / %v", x, y)
 
-			if y.Cmp(common.Big0) == 0 {
-				base.Set(common.Big0)
-			} else {
-				n := new(big.Int)
-				if new(big.Int).Mul(x, y).Cmp(common.Big0) < 0 {
-					n.SetInt64(-1)
-				} else {
-					n.SetInt64(1)
-				}
-
-				base.Div(x.Abs(x), y.Abs(y)).Mul(base, n)
-
-				U256(base)
-			}
+			y.SDiv(x, y)
 
-			self.Printf(" = %v", base)
-			stack.push(base)
+			self.Printf(" = %v", y)
 		case MOD:
-			x, y := stack.pop(), stack.pop()
-
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v %% %v", x, y)
 
-			if y.Cmp(common.Big0) == 0 {
-				base.Set(common.Big0)
-			} else {
-				base.Mod(x, y)
-			}
-
-			U256(base)
+			y.Mod(x, y)
 
-			self.Printf(" = %v", base)
-			stack.push(base)
+			self.Printf(" = %v", y)
 		case SMOD:
-			x, y := S256(stack.pop()), S256(stack.pop())
-
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v %% %v", x, y)
 
-			if y.Cmp(common.Big0) == 0 {
-				base.Set(common.Big0)
-			} else {
-				n := new(big.Int)
-				if x.Cmp(common.Big0) < 0 {
-					n.SetInt64(-1)
-				} else {
-					n.SetInt64(1)
-				}
-
-				base.Mod(x.Abs(x), y.Abs(y)).Mul(base, n)
+			y.SMod(x, y)
 
-				U256(base)
-			}
-
-			self.Printf(" = %v", base)
-			stack.push(base)
+			self.Printf(" = %v", y)
 
 		case EXP:
-			x, y := stack.pop(), stack.pop()
-
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v ** %v", x, y)
 
-			base.Exp(x, y, Pow256)
+			y.Exp(x, y)
 
-			U256(base)
-
-			self.Printf(" = %v", base)
-
-			stack.push(base)
+			self.Printf(" = %v", y)
 		case SIGNEXTEND:
-			back := stack.pop()
-			if back.Cmp(big.NewInt(31)) < 0 {
-				bit := uint(back.Uint64()*8 + 7)
-				num := stack.pop()
-				mask := new(big.Int).Lsh(common.Big1, bit)
-				mask.Sub(mask, common.Big1)
-				if common.BitTest(num, int(bit)) {
-					num.Or(num, mask.Not(mask))
-				} else {
-					num.And(num, mask)
-				}
+			back, num := stack.pop(), stack.peek()
 
-				num = U256(num)
+			num.SignExtend(back, num)
 
-				self.Printf(" = %v", num)
-
-				stack.push(num)
-			}
+			self.Printf(" = %v", num)
 		case NOT:
-			stack.push(U256(new(big.Int).Not(stack.pop())))
-			//base.Sub(Pow256, stack.pop()).Sub(base, common.Big1)
-			//base = U256(base)
-			//stack.push(base)
+			x := stack.peek()
+			x.Not(x)
 		case LT:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v < %v", x, y)
 			// x < y
-			if x.Cmp(y) < 0 {
-				stack.push(common.BigTrue)
+			if x.Lt(y) {
+				y.SetUint64(1)
 			} else {
-				stack.push(common.BigFalse)
+				y.SetUint64(0)
 			}
 		case GT:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v > %v", x, y)
 
 			// x > y
-			if x.Cmp(y) > 0 {
-				stack.push(common.BigTrue)
+			if x.Gt(y) {
+				y.SetUint64(1)
 			} else {
-				stack.push(common.BigFalse)
+				y.SetUint64(0)
 			}
 
 		case SLT:
-			x, y := S256(stack.pop()), S256(stack.pop())
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v < %v", x, y)
 			// x < y
-			if x.Cmp(S256(y)) < 0 {
-				stack.push(common.BigTrue)
+			if x.Slt(y) {
+				y.SetUint64(1)
 			} else {
-				stack.push(common.BigFalse)
+				y.SetUint64(0)
 			}
 		case SGT:
-			x, y := S256(stack.pop()), S256(stack.pop())
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v > %v", x, y)
 
 			// x > y
-			if x.Cmp(y) > 0 {
-				stack.push(common.BigTrue)
+			if x.Sgt(y) {
+				y.SetUint64(1)
 			} else {
-				stack.push(common.BigFalse)
+				y.SetUint64(0)
 			}
 
 		case EQ:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v == %v", y, x)
 
 			// x == y
-			if x.Cmp(y) == 0 {
-				stack.push(common.BigTrue)
+			if x.Eq(y) {
+				y.SetUint64(1)
 			} else {
-				stack.push(common.BigFalse)
+				y.SetUint64(0)
 			}
 		case ISZERO:
-			x := stack.pop()
-			if x.Cmp(common.BigFalse) > 0 {
-				stack.push(common.BigFalse)
+			x := stack.peek()
+			if x.IsZero() {
+				x.SetUint64(1)
 			} else {
-				stack.push(common.BigTrue)
+				x.SetUint64(0)
 			}
 
 			// 0x10 range
 		case AND:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v & %v", y, x)
 
-			stack.push(base.And(x, y))
+			y.And(x, y)
 		case OR:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v | %v", x, y)
 
-			stack.push(base.Or(x, y))
+			y.Or(x, y)
 		case XOR:
-			x, y := stack.pop(), stack.pop()
+			x, y := stack.pop(), stack.peek()
 			self.Printf(" %v ^ %v", x, y)
 
-			stack.push(base.Xor(x, y))
+			y.Xor(x, y)
 		case BYTE:
-			th, val := stack.pop(), stack.pop()
-
-			if th.Cmp(big.NewInt(32)) < 0 {
-				byt := big.NewInt(int64(common.LeftPadBytes(val.Bytes(), 32)[th.Int64()]))
+			th, val := stack.pop(), stack.peek()
 
-				base.Set(byt)
-			} else {
-				base.Set(common.BigFalse)
-			}
-
-			self.Printf(" => 0x%x", base.Bytes())
+			val.Byte(th, val)
 
-			stack.push(base)
+			self.Printf(" => 0x%x", val.Bytes())
 		case ADDMOD:
-			x := stack.pop()
-			y := stack.pop()
-			z := stack.pop()
-
-			if z.Cmp(Zero) > 0 {
-				add := new(big.Int).Add(x, y)
-				base.Mod(add, z)
-
-				base = U256(base)
-			}
+			x, y, z := stack.pop(), stack.pop(), stack.peek()
+			self.Printf(" %v + %v %% %v", x, y, z)
 
-			self.Printf(" %v + %v %% %v = %v", x, y, z, base)
+			z.AddMod(x, y, z)
 
-			stack.push(base)
+			self.Printf(" = %v", z)
 		case MULMOD:
-			x := stack.pop()
-			y := stack.pop()
-			z := stack.pop()
-
-			if z.Cmp(Zero) > 0 {
-				mul := new(big.Int).Mul(x, y)
-				base.Mod(mul, z)
-
-				U256(base)
-			}
+			x, y, z := stack.pop(), stack.pop(), stack.peek()
+			self.Printf(" %v * %v %% %v", x, y, z)
 
-			self.Printf(" %v + %v %% %v = %v", x, y, z, base)
+			z.MulMod(x, y, z)
 
-			stack.push(base)
+			self.Printf(" = %v", z)
 
 			// 0x20 range
 		case SHA3:
 			offset, size := stack.pop(), stack.pop()
-			data := crypto.Sha3(mem.Get(offset.Int64(), size.Int64()))
+			data := crypto.Sha3(mem.Get(int64(offset.Uint64()), int64(size.Uint64())))
 
-			stack.push(common.BigD(data))
+			stack.push(new(word).SetBytes(data))
 
 			self.Printf(" => (%v) %x", size, data)
 			// 0x30 range
 		case ADDRESS:
-			stack.push(common.Bytes2Big(context.Address().Bytes()))
+			stack.push(new(word).SetBytes(context.Address().Bytes()))
 
 			self.Printf(" => %x", context.Address())
 		case BALANCE:
-			addr := common.BigToAddress(stack.pop())
+			addr := wordToAddress(stack.peek())
 			balance := statedb.GetBalance(addr)
 
-			stack.push(balance)
+			stack.peek().SetBig(balance)
 
 			self.Printf(" => %v (%x)", balance, addr)
 		case ORIGIN:
 			origin := self.env.Origin()
 
-			stack.push(origin.Big())
+			stack.push(new(word).SetBytes(origin.Bytes()))
 
 			self.Printf(" => %x", origin)
 		case CALLER:
 			caller := context.caller.Address()
-			stack.push(common.Bytes2Big(caller.Bytes()))
+			stack.push(new(word).SetBytes(caller.Bytes()))
 
 			self.Printf(" => %x", caller)
 		case CALLVALUE:
-			stack.push(value)
+			stack.push(new(word).SetBig(value))
 
 			self.Printf(" => %v", value)
 		case CALLDATALOAD:
-			data := getData(callData, stack.pop(), common.Big32)
+			x := stack.peek()
+			data := getData(callData, x.SaturatingUint64(), 32)
 
 			self.Printf(" => 0x%x", data)
 
-			stack.push(common.Bytes2Big(data))
+			x.SetBytes(data)
 		case CALLDATASIZE:
-			l := int64(len(callData))
-			stack.push(big.NewInt(l))
+			l := uint64(len(callData))
+			stack.push(new(word).SetUint64(l))
 
 			self.Printf(" => %d", l)
 		case CALLDATACOPY:
@@ -442,7 +358,7 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 				cOff = stack.pop()
 				l    = stack.pop()
 			)
-			data := getData(callData, cOff, l)
+			data := getData(callData, cOff.SaturatingUint64(), l.Uint64())
 
 			mem.Set(mOff.Uint64(), l.Uint64(), data)
 
@@ -450,21 +366,21 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 		case CODESIZE, EXTCODESIZE:
 			var code []byte
 			if op == EXTCODESIZE {
-				addr := common.BigToAddress(stack.pop())
+				addr := wordToAddress(stack.pop())
 
 				code = statedb.GetCode(addr)
 			} else {
 				code = context.Code
 			}
 
-			l := big.NewInt(int64(len(code)))
-			stack.push(l)
+			l := uint64(len(code))
+			stack.push(new(word).SetUint64(l))
 
 			self.Printf(" => %d", l)
 		case CODECOPY, EXTCODECOPY:
 			var code []byte
 			if op == EXTCODECOPY {
-				addr := common.BigToAddress(stack.pop())
+				addr := wordToAddress(stack.pop())
 				code = statedb.GetCode(addr)
 			} else {
 				code = context.Code
@@ -476,64 +392,64 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 				l    = stack.pop()
 			)
 
-			codeCopy := getData(code, cOff, l)
+			codeCopy := getData(code, cOff.SaturatingUint64(), l.Uint64())
 
 			mem.Set(mOff.Uint64(), l.Uint64(), codeCopy)
 
 			self.Printf(" => [%v, %v, %v] %x", mOff, cOff, l, codeCopy)
 		case GASPRICE:
-			stack.push(context.Price)
+			stack.push(new(word).SetBig(context.Price))
 
 			self.Printf(" => %x", context.Price)
 
 			// 0x40 range
 		case BLOCKHASH:
-			num := stack.pop()
+			num := stack.peek()
 
 			n := new(big.Int).Sub(self.env.BlockNumber(), common.Big257)
-			if num.Cmp(n) > 0 && num.Cmp(self.env.BlockNumber()) < 0 {
-				stack.push(self.env.GetHash(num.Uint64()).Big())
+			if num.IsUint64() && num.Big().Cmp(n) > 0 && num.Big().Cmp(self.env.BlockNumber()) < 0 {
+				num.SetBytes(self.env.GetHash(num.Uint64()).Bytes())
 			} else {
-				stack.push(common.Big0)
+				num.SetUint64(0)
 			}
 
 			self.Printf(" => 0x%x", stack.peek().Bytes())
 		case COINBASE:
 			coinbase := self.env.Coinbase()
 
-			stack.push(coinbase.Big())
+			stack.push(new(word).SetBytes(coinbase.Bytes()))
 
 			self.Printf(" => 0x%x", coinbase)
 		case TIMESTAMP:
 			time := self.env.Time()
 
-			stack.push(big.NewInt(time))
+			stack.push(new(word).SetUint64(uint64(time)))
 
 			self.Printf(" => 0x%x", time)
 		case NUMBER:
 			number := self.env.BlockNumber()
 
-			stack.push(U256(number))
+			stack.push(new(word).SetBig(number))
 
 			self.Printf(" => 0x%x", number.Bytes())
 		case DIFFICULTY:
 			difficulty := self.env.Difficulty()
 
-			stack.push(difficulty)
+			stack.push(new(word).SetBig(difficulty))
 
 			self.Printf(" => 0x%x", difficulty.Bytes())
 		case GASLIMIT:
 			self.Printf(" => %v", self.env.GasLimit())
 
-			stack.push(self.env.GasLimit())
+			stack.push(new(word).SetBig(self.env.GasLimit()))
 
 			// 0x50 range
 		case PUSH1, PUSH2, PUSH3, PUSH4, PUSH5, PUSH6, PUSH7, PUSH8, PUSH9, PUSH10, PUSH11, PUSH12, PUSH13, PUSH14, PUSH15, PUSH16, PUSH17, PUSH18, PUSH19, PUSH20, PUSH21, PUSH22, PUSH23, PUSH24, PUSH25, PUSH26, PUSH27, PUSH28, PUSH29, PUSH30, PUSH31, PUSH32:
-			a := big.NewInt(int64(op - PUSH1 + 1))
-			byts := getData(code, new(big.Int).Add(pc, big.NewInt(1)), a)
+			a := uint64(op - PUSH1 + 1)
+			byts := getData(code, pc+1, a)
 			// push value to stack
-			stack.push(common.Bytes2Big(byts))
-			pc.Add(pc, a)
+			stack.push(new(word).SetBytes(byts))
+			pc += a
 
 			self.Printf(" => 0x%x", byts)
 		case POP:
@@ -553,44 +469,44 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 			topics := make([]common.Hash, n)
 			mStart, mSize := stack.pop(), stack.pop()
 			for i := 0; i < n; i++ {
-				topics[i] = common.BigToHash(stack.pop()) //common.LeftPadBytes(stack.pop().Bytes(), 32)
+				topics[i] = common.Hash(stack.pop().Bytes32())
 			}
 
-			data := mem.Get(mStart.Int64(), mSize.Int64())
+			data := mem.Get(int64(mStart.Uint64()), int64(mSize.Uint64()))
 			log := state.NewLog(context.Address(), topics, data, self.env.BlockNumber().Uint64())
 			//log := &Log{context.Address(), topics, data, self.env.BlockNumber().Uint64()}
 			self.env.AddLog(log)
 
 			self.Printf(" => %v", log)
 		case MLOAD:
-			offset := stack.pop()
-			val := common.BigD(mem.Get(offset.Int64(), 32))
-			stack.push(val)
+			offset := stack.peek()
+			offset.SetBytes(mem.Get(int64(offset.Uint64()), 32))
 
-			self.Printf(" => 0x%x", val.Bytes())
+			self.Printf(" => 0x%x", offset.Bytes())
 		case MSTORE: // Store the value at stack top-1 in to memory at location stack top
 			// pop value of the stack
 			mStart, val := stack.pop(), stack.pop()
-			mem.Set(mStart.Uint64(), 32, common.BigToBytes(val, 256))
+			b := val.Bytes32()
+			mem.Set(mStart.Uint64(), 32, b[:])
 
 			self.Printf(" => 0x%x", val)
 		case MSTORE8:
-			off, val := stack.pop().Int64(), stack.pop().Int64()
+			off, val := stack.pop().Uint64(), stack.pop().Uint64()
 
 			mem.store[off] = byte(val & 0xff)
 
 			self.Printf(" => [%v] 0x%x", off, mem.store[off])
 		case SLOAD:
-			loc := common.BigToHash(stack.pop())
-			val := common.Bytes2Big(statedb.GetState(context.Address(), loc))
-			stack.push(val)
+			x := stack.peek()
+			loc := common.Hash(x.Bytes32())
+			x.SetBytes(statedb.GetState(context.Address(), loc))
 
-			self.Printf(" {0x%x : 0x%x}", loc, val.Bytes())
+			self.Printf(" {0x%x : 0x%x}", loc, x.Bytes())
 		case SSTORE:
-			loc := common.BigToHash(stack.pop())
+			loc := common.Hash(stack.pop().Bytes32())
 			val := stack.pop()
 
-			statedb.SetState(context.Address(), loc, val)
+			statedb.SetState(context.Address(), loc, val.Big())
 
 			self.Printf(" {0x%x : 0x%x}", loc, val.Bytes())
 		case JUMP:
@@ -602,7 +518,7 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 		case JUMPI:
 			pos, cond := stack.pop(), stack.pop()
 
-			if cond.Cmp(common.BigTrue) >= 0 {
+			if !cond.IsZero() {
 				if err := jump(pc, pos); err != nil {
 					return nil, err
 				}
@@ -614,21 +530,20 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 
 		case JUMPDEST:
 		case PC:
-			//stack.push(big.NewInt(int64(pc)))
-			stack.push(pc)
+			stack.push(new(word).SetUint64(pc))
 		case MSIZE:
-			stack.push(big.NewInt(int64(mem.Len())))
+			stack.push(new(word).SetUint64(uint64(mem.Len())))
 		case GAS:
-			stack.push(context.Gas)
+			stack.push(new(word).SetBig(context.Gas))
 
 			self.Printf(" => %x", context.Gas)
 			// 0x60 range
 		case CREATE:
 
 			var (
-				value        = stack.pop()
+				value        = stack.pop().Big()
 				offset, size = stack.pop(), stack.pop()
-				input        = mem.Get(offset.Int64(), size.Int64())
+				input        = mem.Get(int64(offset.Uint64()), int64(size.Uint64()))
 				gas          = new(big.Int).Set(context.Gas)
 				addr         common.Address
 			)
@@ -637,7 +552,7 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 			context.UseGas(context.Gas)
 			ret, suberr, ref := self.env.Create(context, input, gas, price, value)
 			if suberr != nil {
-				stack.push(common.BigFalse)
+				stack.push(new(word))
 
 				self.Printf(" (*) 0x0 %v", suberr)
 			} else {
@@ -649,25 +564,24 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 				}
 				addr = ref.Address()
 
-				stack.push(addr.Big())
+				stack.push(new(word).SetBytes(addr.Bytes()))
 
 			}
 
 		case CALL, CALLCODE:
-			gas := stack.pop()
+			gas := stack.pop().Big()
 			// pop gas and value of the stack.
-			addr, value := stack.pop(), stack.pop()
-			value = U256(value)
+			addr, value := stack.pop(), stack.pop().Big()
 			// pop input size and offset
 			inOffset, inSize := stack.pop(), stack.pop()
 			// pop return size and offset
-			retOffset, retSize := stack.pop(), stack.pop()
+			retOffset, retSize := stack.pop().Uint64(), stack.pop().Uint64()
 
-			address := common.BigToAddress(addr)
+			address := wordToAddress(addr)
 			self.Printf(" => %x", address).Endl()
 
 			// Get the arguments from the memory
-			args := mem.Get(inOffset.Int64(), inSize.Int64())
+			args := mem.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
 			if len(value.Bytes()) > 0 {
 				gas.Add(gas, params.CallStipend)
@@ -684,24 +598,24 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 			}
 
 			if err != nil {
-				stack.push(common.BigFalse)
+				stack.push(new(word))
 
 				self.Printf("%v").Endl()
 			} else {
-				stack.push(common.BigTrue)
+				stack.push(new(word).SetUint64(1))
 
-				mem.Set(retOffset.Uint64(), retSize.Uint64(), ret)
+				mem.Set(retOffset, retSize, ret)
 			}
 			self.Printf("resume %x (%v)", context.Address(), context.Gas)
 		case RETURN:
 			offset, size := stack.pop(), stack.pop()
-			ret := mem.Get(offset.Int64(), size.Int64())
+			ret := mem.Get(int64(offset.Uint64()), int64(size.Uint64()))
 
 			self.Printf(" => [%v, %v] (%d) 0x%x", offset, size, len(ret), ret).Endl()
 
 			return context.Return(ret), nil
 		case SUICIDE:
-			receiver := statedb.GetOrNewStateObject(common.BigToAddress(stack.pop()))
+			receiver := statedb.GetOrNewStateObject(wordToAddress(stack.pop()))
 			balance := statedb.GetBalance(context.Address())
 
 			self.Printf(" => (%x) %v", receiver.Address().Bytes()[:4], balance)
@@ -721,18 +635,19 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 			return nil, fmt.Errorf("Invalid opcode %x", op)
 		}
 
-		pc.Add(pc, One)
+		pc++
 
 		self.Endl()
 	}
 }
 
-func (self *Vm) calculateGasAndSize(context *Context, caller ContextRef, op OpCode, statedb *state.StateDB, mem *Memory, stack *stack) (*big.Int, *big.Int, error) {
+func calculateGasAndSize(env Environment, context *Context, caller ContextRef, op OpCode, statedb *state.StateDB, mem *Memory, stack *stack) (*big.Int, *big.Int, error) {
 	var (
 		gas                 = new(big.Int)
 		newMemSize *big.Int = new(big.Int)
 	)
-	err := baseCheck(op, stack, gas)
+	table := env.Rules().Gas
+	err := baseCheck(op, stack, gas, table)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -760,15 +675,15 @@ func (self *Vm) calculateGasAndSize(context *Context, caller ContextRef, op OpCo
 			return nil, nil, err
 		}
 
-		mSize, mStart := stack.data[stack.len()-2], stack.data[stack.len()-1]
+		mSize, mStart := &stack.data[stack.len()-2], &stack.data[stack.len()-1]
 
 		gas.Add(gas, params.LogGas)
 		gas.Add(gas, new(big.Int).Mul(big.NewInt(int64(n)), params.LogTopicGas))
-		gas.Add(gas, new(big.Int).Mul(mSize, params.LogDataGas))
+		gas.Add(gas, new(big.Int).Mul(mSize.Big(), params.LogDataGas))
 
 		newMemSize = calcMemSize(mStart, mSize)
 	case EXP:
-		gas.Add(gas, new(big.Int).Mul(big.NewInt(int64(len(stack.data[stack.len()-2].Bytes()))), params.ExpByteGas))
+		gas.Add(gas, new(big.Int).Mul(big.NewInt(int64(stack.data[stack.len()-2].ByteLen())), table.ExpByte))
 	case SSTORE:
 		err := stack.require(2)
 		if err != nil {
@@ -776,13 +691,13 @@ func (self *Vm) calculateGasAndSize(context *Context, caller ContextRef, op OpCo
 		}
 
 		var g *big.Int
-		y, x := stack.data[stack.len()-2], stack.data[stack.len()-1]
-		val := statedb.GetState(context.Address(), common.BigToHash(x))
-		if len(val) == 0 && len(y.Bytes()) > 0 {
+		y, x := &stack.data[stack.len()-2], &stack.data[stack.len()-1]
+		val := statedb.GetState(context.Address(), common.Hash(x.Bytes32()))
+		if len(val) == 0 && !y.IsZero() {
 			// 0 => non 0
 			g = params.SstoreSetGas
-		} else if len(val) > 0 && len(y.Bytes()) == 0 {
-			statedb.Refund(self.env.Origin(), params.SstoreRefundGas)
+		} else if len(val) > 0 && y.IsZero() {
+			statedb.Refund(env.Origin(), params.SstoreRefundGas)
 
 			g = params.SstoreClearGas
 		} else {
@@ -792,54 +707,54 @@ func (self *Vm) calculateGasAndSize(context *Context, caller ContextRef, op OpCo
 		gas.Set(g)
 	case SUICIDE:
 		if !statedb.IsDeleted(context.Address()) {
-			statedb.Refund(self.env.Origin(), params.SuicideRefundGas)
+			statedb.Refund(env.Origin(), params.SuicideRefundGas)
 		}
 	case MLOAD:
-		newMemSize = calcMemSize(stack.peek(), u256(32))
+		newMemSize = calcMemSize(stack.peek(), &word{32})
 	case MSTORE8:
-		newMemSize = calcMemSize(stack.peek(), u256(1))
+		newMemSize = calcMemSize(stack.peek(), &word{1})
 	case MSTORE:
-		newMemSize = calcMemSize(stack.peek(), u256(32))
+		newMemSize = calcMemSize(stack.peek(), &word{32})
 	case RETURN:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-2])
+		newMemSize = calcMemSize(stack.peek(), &stack.data[stack.len()-2])
 	case SHA3:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-2])
+		newMemSize = calcMemSize(stack.peek(), &stack.data[stack.len()-2])
 
-		words := toWordSize(stack.data[stack.len()-2])
+		words := toWordSize(stack.data[stack.len()-2].Big())
 		gas.Add(gas, words.Mul(words, params.Sha3WordGas))
 	case CALLDATACOPY:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-3])
+		newMemSize = calcMemSize(stack.peek(), &stack.data[stack.len()-3])
 
-		words := toWordSize(stack.data[stack.len()-3])
+		words := toWordSize(stack.data[stack.len()-3].Big())
 		gas.Add(gas, words.Mul(words, params.CopyGas))
 	case CODECOPY:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-3])
+		newMemSize = calcMemSize(stack.peek(), &stack.data[stack.len()-3])
 
-		words := toWordSize(stack.data[stack.len()-3])
+		words := toWordSize(stack.data[stack.len()-3].Big())
 		gas.Add(gas, words.Mul(words, params.CopyGas))
 	case EXTCODECOPY:
-		newMemSize = calcMemSize(stack.data[stack.len()-2], stack.data[stack.len()-4])
+		newMemSize = calcMemSize(&stack.data[stack.len()-2], &stack.data[stack.len()-4])
 
-		words := toWordSize(stack.data[stack.len()-4])
+		words := toWordSize(stack.data[stack.len()-4].Big())
 		gas.Add(gas, words.Mul(words, params.CopyGas))
 
 	case CREATE:
-		newMemSize = calcMemSize(stack.data[stack.len()-2], stack.data[stack.len()-3])
+		newMemSize = calcMemSize(&stack.data[stack.len()-2], &stack.data[stack.len()-3])
 	case CALL, CALLCODE:
-		gas.Add(gas, stack.data[stack.len()-1])
+		gas.Add(gas, stack.data[stack.len()-1].Big())
 
 		if op == CALL {
-			if self.env.State().GetStateObject(common.BigToAddress(stack.data[stack.len()-2])) == nil {
+			if env.State().GetStateObject(wordToAddress(&stack.data[stack.len()-2])) == nil {
 				gas.Add(gas, params.CallNewAccountGas)
 			}
 		}
 
-		if len(stack.data[stack.len()-3].Bytes()) > 0 {
+		if !stack.data[stack.len()-3].IsZero() {
 			gas.Add(gas, params.CallValueTransferGas)
 		}
 
-		x := calcMemSize(stack.data[stack.len()-6], stack.data[stack.len()-7])
-		y := calcMemSize(stack.data[stack.len()-4], stack.data[stack.len()-5])
+		x := calcMemSize(&stack.data[stack.len()-6], &stack.data[stack.len()-7])
+		y := calcMemSize(&stack.data[stack.len()-4], &stack.data[stack.len()-5])
 
 		newMemSize = common.BigMax(x, y)
 	}
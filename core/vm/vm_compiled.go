@@ -0,0 +1,429 @@
+package vm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CompiledVm runs contracts compiled into programs of basic blocks (see
+// compile). PUSH immediates and jump destinations are resolved once per
+// code hash and the static gas of a block is paid at its start, so the
+// interpreter loop only deals with the stack and the dynamic gas costs.
+//
+// The results are identical to Vm. If the gas left doesn't cover a whole
+// block, or a dynamic cost can't be paid out of what is left after the
+// block, the gas of the rest of the block is paid per instruction instead.
+type CompiledVm struct {
+	env Environment
+
+	logStr string
+	debug  bool
+}
+
+func NewCompiledVm(env Environment) *CompiledVm {
+	return &CompiledVm{env: env, debug: Debug}
+}
+
+func (self *CompiledVm) Run(context *Context, callData []byte) (ret []byte, err error) {
+	self.env.SetDepth(self.env.Depth() + 1)
+	defer self.env.SetDepth(self.env.Depth() - 1)
+
+	var (
+		caller = context.caller
+		code   = context.Code
+		value  = context.value
+		price  = context.Price
+	)
+
+	self.Printf("(%d) (%x) %x (code=%d) gas: %v (d) %x", self.env.Depth(), caller.Address().Bytes()[:4], context.Address(), len(code), context.Gas, callData).Endl()
+
+	defer func() {
+		if err != nil {
+			self.Printf(" %v", err).Endl()
+			// In case of a VM exception (known exceptions) all gas consumed (panics NOT included).
+			context.UseGas(context.Gas)
+
+			ret = context.Return(nil)
+		}
+	}()
+
+	if context.CodeAddr != nil {
+		if p := Precompiled[context.CodeAddr.Str()]; p != nil {
+			return self.RunPrecompiled(p, callData, context)
+		}
+	}
+
+	// Don't bother with the execution if there's no code.
+	if len(code) == 0 {
+		return context.Return(nil), nil
+	}
+
+	var (
+		prog     = programs.get(context.CodeHash, code)
+		disabled = disabledOps(self.env.Rules())
+		mem      = NewMemory()
+		stack    = newStack()
+		statedb  = self.env.State()
+
+		i      int      // index of the current instruction
+		fast   bool     // whether the static gas of the block has been paid
+		static big.Int  // static gas being paid
+		tmp    *big.Int // gas left when running out of gas
+	)
+	for {
+		in := &prog.instrs[i]
+		if disabled != nil && disabled[in.op] {
+			return nil, fmt.Errorf("Invalid opcode %x", in.op)
+		}
+
+		if in.leader {
+			fast = context.UseGas(static.SetUint64(in.static + in.rest))
+		}
+		if in.dynamic {
+			newMemSize, dyngas, err := calculateGasAndSize(self.env, context, caller, in.op, statedb, mem, stack)
+			if err != nil {
+				return nil, err
+			}
+			// Vm would still have the static gas of the rest of the block.
+			if fast && context.Gas.Cmp(dyngas) < 0 {
+				context.ReturnGas(static.SetUint64(in.rest), price)
+				fast = false
+			}
+			if !context.UseGas(dyngas) {
+				tmp = new(big.Int).Set(context.Gas)
+				context.UseGas(context.Gas)
+
+				return context.Return(nil), OOG(dyngas, tmp)
+			}
+			mem.Resize(newMemSize.Uint64())
+		} else {
+			if err := in.checkStack(stack); err != nil {
+				return nil, err
+			}
+			if !fast && !context.UseGas(static.SetUint64(in.static)) {
+				tmp = new(big.Int).Set(context.Gas)
+				context.UseGas(context.Gas)
+
+				return context.Return(nil), OOG(&static, tmp)
+			}
+		}
+
+		// Operations pop their first operands and replace the last one on
+		// the stack with the result.
+		switch in.op {
+		case ADD:
+			x, y := stack.pop(), stack.peek()
+			y.Add(x, y)
+		case SUB:
+			x, y := stack.pop(), stack.peek()
+			y.Sub(x, y)
+		case MUL:
+			x, y := stack.pop(), stack.peek()
+			y.Mul(x, y)
+		case DIV:
+			x, y := stack.pop(), stack.peek()
+			y.Div(x, y)
+		case SDIV:
+			x, y := stack.pop(), stack.peek()
+			y.SDiv(x, y)
+		case MOD:
+			x, y := stack.pop(), stack.peek()
+			y.Mod(x, y)
+		case SMOD:
+			x, y := stack.pop(), stack.peek()
+			y.SMod(x, y)
+		case EXP:
+			x, y := stack.pop(), stack.peek()
+			y.Exp(x, y)
+		case SIGNEXTEND:
+			back, num := stack.pop(), stack.peek()
+			num.SignExtend(back, num)
+		case NOT:
+			x := stack.peek()
+			x.Not(x)
+		case LT:
+			x, y := stack.pop(), stack.peek()
+			setBool(y, x.Lt(y))
+		case GT:
+			x, y := stack.pop(), stack.peek()
+			setBool(y, x.Gt(y))
+		case SLT:
+			x, y := stack.pop(), stack.peek()
+			setBool(y, x.Slt(y))
+		case SGT:
+			x, y := stack.pop(), stack.peek()
+			setBool(y, x.Sgt(y))
+		case EQ:
+			x, y := stack.pop(), stack.peek()
+			setBool(y, x.Eq(y))
+		case ISZERO:
+			x := stack.peek()
+			setBool(x, x.IsZero())
+		case AND:
+			x, y := stack.pop(), stack.peek()
+			y.And(x, y)
+		case OR:
+			x, y := stack.pop(), stack.peek()
+			y.Or(x, y)
+		case XOR:
+			x, y := stack.pop(), stack.peek()
+			y.Xor(x, y)
+		case BYTE:
+			th, val := stack.pop(), stack.peek()
+			val.Byte(th, val)
+		case ADDMOD:
+			x, y, z := stack.pop(), stack.pop(), stack.peek()
+			z.AddMod(x, y, z)
+		case MULMOD:
+			x, y, z := stack.pop(), stack.pop(), stack.peek()
+			z.MulMod(x, y, z)
+
+		case SHA3:
+			offset, size := stack.pop(), stack.pop()
+			data := crypto.Sha3(mem.Get(int64(offset.Uint64()), int64(size.Uint64())))
+
+			stack.push(new(word).SetBytes(data))
+
+		case ADDRESS:
+			stack.push(new(word).SetBytes(context.Address().Bytes()))
+		case BALANCE:
+			x := stack.peek()
+			x.SetBig(statedb.GetBalance(wordToAddress(x)))
+		case ORIGIN:
+			stack.push(new(word).SetBytes(self.env.Origin().Bytes()))
+		case CALLER:
+			stack.push(new(word).SetBytes(caller.Address().Bytes()))
+		case CALLVALUE:
+			stack.push(new(word).SetBig(value))
+		case CALLDATALOAD:
+			x := stack.peek()
+			x.SetBytes(getData(callData, x.SaturatingUint64(), 32))
+		case CALLDATASIZE:
+			stack.push(new(word).SetUint64(uint64(len(callData))))
+		case CALLDATACOPY:
+			mOff, cOff, l := stack.pop(), stack.pop(), stack.pop()
+			mem.Set(mOff.Uint64(), l.Uint64(), getData(callData, cOff.SaturatingUint64(), l.Uint64()))
+		case CODESIZE:
+			stack.push(new(word).SetUint64(uint64(len(code))))
+		case EXTCODESIZE:
+			x := stack.peek()
+			x.SetUint64(uint64(len(statedb.GetCode(wordToAddress(x)))))
+		case CODECOPY, EXTCODECOPY:
+			copyCode := code
+			if in.op == EXTCODECOPY {
+				copyCode = statedb.GetCode(wordToAddress(stack.pop()))
+			}
+			mOff, cOff, l := stack.pop(), stack.pop(), stack.pop()
+			mem.Set(mOff.Uint64(), l.Uint64(), getData(copyCode, cOff.SaturatingUint64(), l.Uint64()))
+		case GASPRICE:
+			stack.push(new(word).SetBig(context.Price))
+
+		case BLOCKHASH:
+			num := stack.peek()
+
+			n := new(big.Int).Sub(self.env.BlockNumber(), common.Big257)
+			if num.IsUint64() && num.Big().Cmp(n) > 0 && num.Big().Cmp(self.env.BlockNumber()) < 0 {
+				num.SetBytes(self.env.GetHash(num.Uint64()).Bytes())
+			} else {
+				num.SetUint64(0)
+			}
+		case COINBASE:
+			stack.push(new(word).SetBytes(self.env.Coinbase().Bytes()))
+		case TIMESTAMP:
+			stack.push(new(word).SetUint64(uint64(self.env.Time())))
+		case NUMBER:
+			stack.push(new(word).SetBig(self.env.BlockNumber()))
+		case DIFFICULTY:
+			stack.push(new(word).SetBig(self.env.Difficulty()))
+		case GASLIMIT:
+			stack.push(new(word).SetBig(self.env.GasLimit()))
+
+		case PUSH1, PUSH2, PUSH3, PUSH4, PUSH5, PUSH6, PUSH7, PUSH8, PUSH9, PUSH10, PUSH11, PUSH12, PUSH13, PUSH14, PUSH15, PUSH16, PUSH17, PUSH18, PUSH19, PUSH20, PUSH21, PUSH22, PUSH23, PUSH24, PUSH25, PUSH26, PUSH27, PUSH28, PUSH29, PUSH30, PUSH31, PUSH32:
+			stack.push(&in.value)
+		case POP:
+			stack.pop()
+		case DUP1, DUP2, DUP3, DUP4, DUP5, DUP6, DUP7, DUP8, DUP9, DUP10, DUP11, DUP12, DUP13, DUP14, DUP15, DUP16:
+			stack.dup(int(in.op - DUP1 + 1))
+		case SWAP1, SWAP2, SWAP3, SWAP4, SWAP5, SWAP6, SWAP7, SWAP8, SWAP9, SWAP10, SWAP11, SWAP12, SWAP13, SWAP14, SWAP15, SWAP16:
+			stack.swap(int(in.op - SWAP1 + 2))
+		case LOG0, LOG1, LOG2, LOG3, LOG4:
+			n := int(in.op - LOG0)
+			topics := make([]common.Hash, n)
+			mStart, mSize := stack.pop(), stack.pop()
+			for i := 0; i < n; i++ {
+				topics[i] = common.Hash(stack.pop().Bytes32())
+			}
+
+			data := mem.Get(int64(mStart.Uint64()), int64(mSize.Uint64()))
+			self.env.AddLog(state.NewLog(context.Address(), topics, data, self.env.BlockNumber().Uint64()))
+		case MLOAD:
+			offset := stack.peek()
+			offset.SetBytes(mem.Get(int64(offset.Uint64()), 32))
+		case MSTORE:
+			mStart, val := stack.pop(), stack.pop()
+			b := val.Bytes32()
+			mem.Set(mStart.Uint64(), 32, b[:])
+		case MSTORE8:
+			off, val := stack.pop().Uint64(), stack.pop().Uint64()
+			mem.store[off] = byte(val & 0xff)
+		case SLOAD:
+			x := stack.peek()
+			x.SetBytes(statedb.GetState(context.Address(), common.Hash(x.Bytes32())))
+		case SSTORE:
+			loc := common.Hash(stack.pop().Bytes32())
+			statedb.SetState(context.Address(), loc, stack.pop().Big())
+		case JUMP:
+			to := stack.pop()
+			if i = prog.jumpTarget(to); i < 0 {
+				return nil, fmt.Errorf("invalid jump destination (%v) %v", context.GetOp(to.SaturatingUint64()), to)
+			}
+			continue
+		case JUMPI:
+			pos, cond := stack.pop(), stack.pop()
+			if !cond.IsZero() {
+				if i = prog.jumpTarget(pos); i < 0 {
+					return nil, fmt.Errorf("invalid jump destination (%v) %v", context.GetOp(pos.SaturatingUint64()), pos)
+				}
+				continue
+			}
+		case JUMPDEST:
+		case PC:
+			stack.push(new(word).SetUint64(in.pc))
+		case MSIZE:
+			stack.push(new(word).SetUint64(uint64(mem.Len())))
+		case GAS:
+			left := new(word).SetBig(context.Gas)
+			if fast {
+				// Vm hasn't paid for the rest of the block yet.
+				left.Add(left, new(word).SetUint64(in.rest))
+			}
+			stack.push(left)
+
+		case CREATE:
+			// CREATE passes on all gas, including the static gas of the
+			// rest of the block.
+			if fast {
+				context.ReturnGas(static.SetUint64(in.rest), price)
+				fast = false
+			}
+			var (
+				value        = stack.pop().Big()
+				offset, size = stack.pop(), stack.pop()
+				input        = mem.Get(int64(offset.Uint64()), int64(size.Uint64()))
+				gas          = new(big.Int).Set(context.Gas)
+			)
+			context.UseGas(context.Gas)
+			ret, suberr, ref := self.env.Create(context, input, gas, price, value)
+			if suberr != nil {
+				stack.push(new(word))
+			} else {
+				// gas < len(ret) * CreateDataGas == NO_CODE
+				dataGas := big.NewInt(int64(len(ret)))
+				dataGas.Mul(dataGas, params.CreateDataGas)
+				if context.UseGas(dataGas) {
+					ref.SetCode(ret)
+				}
+				stack.push(new(word).SetBytes(ref.Address().Bytes()))
+			}
+
+		case CALL, CALLCODE:
+			var (
+				gas                = stack.pop().Big()
+				address            = wordToAddress(stack.pop())
+				value              = stack.pop().Big()
+				inOffset, inSize   = stack.pop(), stack.pop()
+				retOffset, retSize = stack.pop().Uint64(), stack.pop().Uint64()
+				args               = mem.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+			)
+			if value.Sign() > 0 {
+				gas.Add(gas, params.CallStipend)
+			}
+
+			var (
+				ret []byte
+				err error
+			)
+			if in.op == CALLCODE {
+				ret, err = self.env.CallCode(context, address, args, gas, price, value)
+			} else {
+				ret, err = self.env.Call(context, address, args, gas, price, value)
+			}
+
+			if err != nil {
+				stack.push(new(word))
+			} else {
+				stack.push(new(word).SetUint64(1))
+
+				mem.Set(retOffset, retSize, ret)
+			}
+		case RETURN:
+			offset, size := stack.pop(), stack.pop()
+			ret := mem.Get(int64(offset.Uint64()), int64(size.Uint64()))
+
+			return context.Return(ret), nil
+		case SUICIDE:
+			receiver := statedb.GetOrNewStateObject(wordToAddress(stack.pop()))
+			balance := statedb.GetBalance(context.Address())
+
+			receiver.AddBalance(balance)
+
+			statedb.Delete(context.Address())
+
+			return context.Return(nil), nil
+		case STOP:
+			return context.Return(nil), nil
+		default:
+			return nil, fmt.Errorf("Invalid opcode %x", in.op)
+		}
+
+		i++
+	}
+}
+
+// setBool sets x to 1 if b is true and to 0 otherwise.
+func setBool(x *word, b bool) {
+	if b {
+		x.SetUint64(1)
+	} else {
+		x.SetUint64(0)
+	}
+}
+
+func (self *CompiledVm) RunPrecompiled(p *PrecompiledAccount, callData []byte, context *Context) (ret []byte, err error) {
+	gas := p.Gas(len(callData))
+	if context.UseGas(gas) {
+		ret = p.Call(callData)
+
+		return context.Return(ret), nil
+	}
+	tmp := new(big.Int).Set(context.Gas)
+
+	return nil, OOG(gas, tmp)
+}
+
+func (self *CompiledVm) Printf(format string, v ...interface{}) VirtualMachine {
+	if self.debug {
+		self.logStr += fmt.Sprintf(format, v...)
+	}
+
+	return self
+}
+
+func (self *CompiledVm) Endl() VirtualMachine {
+	if self.debug {
+		glog.V(0).Infoln(self.logStr)
+		self.logStr = ""
+	}
+
+	return self
+}
+
+func (self *CompiledVm) Env() Environment {
+	return self.env
+}
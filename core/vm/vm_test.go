@@ -1,3 +1,31 @@
 package vm
 
 // Tests have been removed in favour of general tests. If anything implementation specific needs testing, put it here
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestDisabledOps(t *testing.T) {
+	config := &params.ChainConfig{Forks: []*params.RuleSet{
+		params.FrontierRules,
+		{Block: big.NewInt(10), DisabledOps: []string{"CALLCODE"}},
+	}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if ops := disabledOps(config.Rules(big.NewInt(10))); ops == nil || !ops[CALLCODE] || ops[CALL] {
+		t.Errorf("disabled op codes %v", ops)
+	}
+	if ops := disabledOps(config.Rules(big.NewInt(9))); ops != nil {
+		t.Errorf("op codes disabled before the fork")
+	}
+
+	config.Forks[1] = &params.RuleSet{Block: big.NewInt(10), DisabledOps: []string{"CALCODE"}}
+	if err := config.Validate(); err == nil {
+		t.Errorf("unknown op code accepted")
+	}
+}
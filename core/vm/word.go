@@ -0,0 +1,693 @@
+package vm
+
+import (
+	"math"
+	"math/big"
+)
+
+// word is a 256 bit unsigned integer, the native value of the EVM. It is
+// stored as four 64 bit limbs, least significant first, so all arithmetic
+// wraps around modulo 2^256 without any allocations.
+//
+// Signed operations interpret the value as two's complement. Like big.Int,
+// methods set the receiver to the result and return it; the operands may
+// alias the receiver.
+type word [4]uint64
+
+// SetUint64 sets z to x.
+func (z *word) SetUint64(x uint64) *word {
+	*z = word{x}
+	return z
+}
+
+// SetBytes interprets b as a big endian number and sets z to its value. Only
+// the last 32 bytes of b are used.
+func (z *word) SetBytes(b []byte) *word {
+	if len(b) > 32 {
+		b = b[len(b)-32:]
+	}
+	*z = word{}
+	for i, j := len(b)-1, 0; i >= 0; i, j = i-1, j+1 {
+		z[j/8] |= uint64(b[i]) << (8 * uint(j%8))
+	}
+	return z
+}
+
+// SetBig sets z to x modulo 2^256. Negative values are stored as their two's
+// complement.
+func (z *word) SetBig(x *big.Int) *word {
+	if x.Sign() >= 0 && x.BitLen() <= 64 {
+		return z.SetUint64(x.Uint64())
+	}
+	z.SetBytes(x.Bytes())
+	if x.Sign() < 0 {
+		z.Neg(z)
+	}
+	return z
+}
+
+// Big returns the value of z as a big.Int.
+func (z *word) Big() *big.Int {
+	if z.IsUint64() {
+		return new(big.Int).SetUint64(z[0])
+	}
+	b := z.Bytes32()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// Bytes32 returns the value of z as 32 big endian bytes.
+func (z *word) Bytes32() (b [32]byte) {
+	for i := 0; i < 32; i++ {
+		b[31-i] = byte(z[i/8] >> (8 * uint(i%8)))
+	}
+	return b
+}
+
+// Bytes returns the value of z as big endian bytes without leading zeros.
+func (z *word) Bytes() []byte {
+	b := z.Bytes32()
+	return b[32-z.ByteLen():]
+}
+
+// Uint64 returns the lowest 64 bits of z.
+func (z *word) Uint64() uint64 {
+	return z[0]
+}
+
+// SaturatingUint64 returns z as a uint64, or math.MaxUint64 if it doesn't fit.
+func (z *word) SaturatingUint64() uint64 {
+	if !z.IsUint64() {
+		return math.MaxUint64
+	}
+	return z[0]
+}
+
+// IsUint64 reports whether z can be represented as a uint64.
+func (z *word) IsUint64() bool {
+	return z[1]|z[2]|z[3] == 0
+}
+
+// IsZero reports whether z is zero.
+func (z *word) IsZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+// BitLen returns the length of z in bits.
+func (z *word) BitLen() int {
+	for i := 3; i >= 0; i-- {
+		if z[i] != 0 {
+			return 64*i + len64(z[i])
+		}
+	}
+	return 0
+}
+
+// ByteLen returns the length of z in bytes.
+func (z *word) ByteLen() int {
+	return (z.BitLen() + 7) / 8
+}
+
+// negative reports whether the two's complement value of z is negative.
+func (z *word) negative() bool {
+	return z[3]>>63 == 1
+}
+
+// Cmp compares x and y as unsigned values and returns -1, 0 or +1.
+func (x *word) Cmp(y *word) int {
+	for i := 3; i >= 0; i-- {
+		switch {
+		case x[i] < y[i]:
+			return -1
+		case x[i] > y[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Eq reports whether x equals y.
+func (x *word) Eq(y *word) bool {
+	return *x == *y
+}
+
+// Lt reports whether x < y as unsigned values.
+func (x *word) Lt(y *word) bool {
+	return x.Cmp(y) < 0
+}
+
+// Gt reports whether x > y as unsigned values.
+func (x *word) Gt(y *word) bool {
+	return x.Cmp(y) > 0
+}
+
+// Slt reports whether x < y as signed values.
+func (x *word) Slt(y *word) bool {
+	xneg, yneg := x.negative(), y.negative()
+	if xneg != yneg {
+		return xneg
+	}
+	return x.Cmp(y) < 0
+}
+
+// Sgt reports whether x > y as signed values.
+func (x *word) Sgt(y *word) bool {
+	return y.Slt(x)
+}
+
+// Add sets z to x + y.
+func (z *word) Add(x, y *word) *word {
+	var carry uint64
+	z[0], carry = add64(x[0], y[0], 0)
+	z[1], carry = add64(x[1], y[1], carry)
+	z[2], carry = add64(x[2], y[2], carry)
+	z[3], _ = add64(x[3], y[3], carry)
+	return z
+}
+
+// Sub sets z to x - y.
+func (z *word) Sub(x, y *word) *word {
+	var borrow uint64
+	z[0], borrow = sub64(x[0], y[0], 0)
+	z[1], borrow = sub64(x[1], y[1], borrow)
+	z[2], borrow = sub64(x[2], y[2], borrow)
+	z[3], _ = sub64(x[3], y[3], borrow)
+	return z
+}
+
+// Neg sets z to -x.
+func (z *word) Neg(x *word) *word {
+	return z.Sub(&word{}, x)
+}
+
+// Abs sets z to the absolute value of the two's complement value x.
+func (z *word) Abs(x *word) *word {
+	if x.negative() {
+		return z.Neg(x)
+	}
+	*z = *x
+	return z
+}
+
+// Mul sets z to x * y.
+func (z *word) Mul(x, y *word) *word {
+	var (
+		res              word
+		carry            uint64
+		res1, res2, res3 uint64
+	)
+	carry, res[0] = mul64(x[0], y[0])
+	carry, res1 = umulHop(carry, x[1], y[0])
+	carry, res2 = umulHop(carry, x[2], y[0])
+	res3 = x[3]*y[0] + carry
+
+	carry, res[1] = umulHop(res1, x[0], y[1])
+	carry, res2 = umulStep(res2, x[1], y[1], carry)
+	res3 = res3 + x[2]*y[1] + carry
+
+	carry, res[2] = umulHop(res2, x[0], y[2])
+	res3 = res3 + x[1]*y[2] + carry
+
+	res[3] = res3 + x[0]*y[3]
+
+	*z = res
+	return z
+}
+
+// Div sets z to x / y, or zero if y is zero.
+func (z *word) Div(x, y *word) *word {
+	if y.IsZero() || x.Lt(y) {
+		*z = word{}
+		return z
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x[0] / y[0])
+	}
+	var quot word
+	udivrem(quot[:], x[:], y)
+	*z = quot
+	return z
+}
+
+// Mod sets z to x % y, or zero if y is zero.
+func (z *word) Mod(x, y *word) *word {
+	if y.IsZero() {
+		*z = word{}
+		return z
+	}
+	if x.Lt(y) {
+		*z = *x
+		return z
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x[0] % y[0])
+	}
+	var quot word
+	*z = udivrem(quot[:], x[:], y)
+	return z
+}
+
+// SDiv sets z to the signed division x / y, truncated towards zero, or zero
+// if y is zero.
+func (z *word) SDiv(x, y *word) *word {
+	neg := x.negative() != y.negative()
+
+	var xabs, yabs word
+	z.Div(xabs.Abs(x), yabs.Abs(y))
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// SMod sets z to the signed remainder of x / y, which has the sign of x, or
+// zero if y is zero.
+func (z *word) SMod(x, y *word) *word {
+	neg := x.negative()
+
+	var xabs, yabs word
+	z.Mod(xabs.Abs(x), yabs.Abs(y))
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// AddMod sets z to (x + y) % m without overflowing, or zero if m is zero.
+func (z *word) AddMod(x, y, m *word) *word {
+	if m.IsZero() {
+		*z = word{}
+		return z
+	}
+	var sum [5]uint64
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		sum[i], carry = add64(x[i], y[i], carry)
+	}
+	sum[4] = carry
+
+	var quot [5]uint64
+	*z = udivrem(quot[:], sum[:], m)
+	return z
+}
+
+// MulMod sets z to (x * y) % m without overflowing, or zero if m is zero.
+func (z *word) MulMod(x, y, m *word) *word {
+	if m.IsZero() {
+		*z = word{}
+		return z
+	}
+	var prod [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := mul64(x[i], y[j])
+			lo, c := add64(lo, prod[i+j], 0)
+			hi += c
+			lo, c = add64(lo, carry, 0)
+			hi += c
+			prod[i+j], carry = lo, hi
+		}
+		prod[i+4] = carry
+	}
+
+	var quot [8]uint64
+	*z = udivrem(quot[:], prod[:], m)
+	return z
+}
+
+// Exp sets z to base ** exponent.
+func (z *word) Exp(base, exponent *word) *word {
+	var (
+		res = word{1}
+		b   = *base
+		e   = *exponent
+		n   = e.BitLen()
+	)
+	// an even base raised to 256 or more has at least 256 trailing zeros
+	if b[0]&1 == 0 && n > 8 {
+		*z = word{}
+		return z
+	}
+	for i := 0; i < n; i++ {
+		if e[i/64]>>uint(i%64)&1 == 1 {
+			res.Mul(&res, &b)
+		}
+		if i < n-1 {
+			b.Mul(&b, &b)
+		}
+	}
+	*z = res
+	return z
+}
+
+// SignExtend sets z to x sign extended from the byte at position back,
+// counting from the least significant byte. x is returned unchanged if back
+// is 31 or larger.
+func (z *word) SignExtend(back, x *word) *word {
+	*z = *x
+	if !back.IsUint64() || back[0] >= 31 {
+		return z
+	}
+	var (
+		bit  = uint(back[0]*8 + 7)
+		limb = bit / 64
+		off  = bit % 64
+	)
+	if z[limb]>>off&1 == 1 {
+		z[limb] |= ^uint64(0) << off
+		for i := limb + 1; i < 4; i++ {
+			z[i] = ^uint64(0)
+		}
+	} else {
+		z[limb] &= 1<<off - 1
+		for i := limb + 1; i < 4; i++ {
+			z[i] = 0
+		}
+	}
+	return z
+}
+
+// Byte sets z to the byte of x at position n, counting from the most
+// significant byte, or zero if n is 32 or larger.
+func (z *word) Byte(n, x *word) *word {
+	if !n.IsUint64() || n[0] >= 32 {
+		*z = word{}
+		return z
+	}
+	pos := 31 - uint(n[0])
+	return z.SetUint64(x[pos/8] >> (8 * (pos % 8)) & 0xff)
+}
+
+// Not sets z to the bitwise complement of x.
+func (z *word) Not(x *word) *word {
+	z[0], z[1], z[2], z[3] = ^x[0], ^x[1], ^x[2], ^x[3]
+	return z
+}
+
+// And sets z to x & y.
+func (z *word) And(x, y *word) *word {
+	z[0], z[1], z[2], z[3] = x[0]&y[0], x[1]&y[1], x[2]&y[2], x[3]&y[3]
+	return z
+}
+
+// Or sets z to x | y.
+func (z *word) Or(x, y *word) *word {
+	z[0], z[1], z[2], z[3] = x[0]|y[0], x[1]|y[1], x[2]|y[2], x[3]|y[3]
+	return z
+}
+
+// Xor sets z to x ^ y.
+func (z *word) Xor(x, y *word) *word {
+	z[0], z[1], z[2], z[3] = x[0]^y[0], x[1]^y[1], x[2]^y[2], x[3]^y[3]
+	return z
+}
+
+// Lsh sets z to x << n.
+func (z *word) Lsh(x *word, n uint) *word {
+	if n >= 256 {
+		*z = word{}
+		return z
+	}
+	var (
+		res   word
+		limbs = n / 64
+		off   = n % 64
+	)
+	for i := 3; i >= int(limbs); i-- {
+		res[i] = x[i-int(limbs)] << off
+		if off > 0 && i > int(limbs) {
+			res[i] |= x[i-int(limbs)-1] >> (64 - off)
+		}
+	}
+	*z = res
+	return z
+}
+
+// Rsh sets z to x >> n, filling in zeros.
+func (z *word) Rsh(x *word, n uint) *word {
+	if n >= 256 {
+		*z = word{}
+		return z
+	}
+	var (
+		res   word
+		limbs = n / 64
+		off   = n % 64
+	)
+	for i := 0; i < 4-int(limbs); i++ {
+		res[i] = x[i+int(limbs)] >> off
+		if off > 0 && i+int(limbs) < 3 {
+			res[i] |= x[i+int(limbs)+1] << (64 - off)
+		}
+	}
+	*z = res
+	return z
+}
+
+// SRsh sets z to x >> n, filling in the sign bit of x.
+func (z *word) SRsh(x *word, n uint) *word {
+	if !x.negative() {
+		return z.Rsh(x, n)
+	}
+	if n >= 256 {
+		return z.Not(&word{})
+	}
+	var mask word
+	mask.Not(&mask).Lsh(&mask, 256-n)
+	return z.Rsh(x, n).Or(z, &mask)
+}
+
+func (z *word) String() string {
+	return z.Big().String()
+}
+
+// umulHop returns the high and low limb of z + x * y.
+func umulHop(z, x, y uint64) (hi, lo uint64) {
+	hi, lo = mul64(x, y)
+	lo, carry := add64(lo, z, 0)
+	hi += carry
+	return hi, lo
+}
+
+// umulStep returns the high and low limb of z + x * y + carry.
+func umulStep(z, x, y, carry uint64) (hi, lo uint64) {
+	hi, lo = mul64(x, y)
+	lo, carry = add64(lo, carry, 0)
+	hi += carry
+	lo, carry = add64(lo, z, 0)
+	hi += carry
+	return hi, lo
+}
+
+// udivrem divides u by d, storing the quotient in quot and returning the
+// remainder. It implements Knuth's algorithm D on 64 bit limbs. u can hold up
+// to 8 limbs, quot must have room for as many limbs as u and d must not be
+// zero.
+func udivrem(quot, u []uint64, d *word) (rem word) {
+	dLen := 0
+	for i := 3; i >= 0; i-- {
+		if d[i] != 0 {
+			dLen = i + 1
+			break
+		}
+	}
+	uLen := 0
+	for i := len(u) - 1; i >= 0; i-- {
+		if u[i] != 0 {
+			uLen = i + 1
+			break
+		}
+	}
+	if uLen < dLen {
+		copy(rem[:], u)
+		return rem
+	}
+
+	// Normalise the divisor so its top bit is set and shift the dividend by
+	// the same amount into an extra limb.
+	shift := uint(64 - len64(d[dLen-1]))
+
+	var dnStorage word
+	dn := dnStorage[:dLen]
+	for i := dLen - 1; i > 0; i-- {
+		dn[i] = d[i]<<shift | d[i-1]>>(64-shift)
+	}
+	dn[0] = d[0] << shift
+
+	var unStorage [9]uint64
+	un := unStorage[:uLen+1]
+	un[uLen] = u[uLen-1] >> (64 - shift)
+	for i := uLen - 1; i > 0; i-- {
+		un[i] = u[i]<<shift | u[i-1]>>(64-shift)
+	}
+	un[0] = u[0] << shift
+
+	if dLen == 1 {
+		r := un[uLen]
+		for j := uLen - 1; j >= 0; j-- {
+			quot[j], r = div64(r, un[j], dn[0])
+		}
+		return *rem.SetUint64(r >> shift)
+	}
+
+	udivremKnuth(quot, un, dn)
+
+	for i := 0; i < dLen-1; i++ {
+		rem[i] = un[i]>>shift | un[i+1]<<(64-shift)
+	}
+	rem[dLen-1] = un[dLen-1] >> shift
+	return rem
+}
+
+// udivremKnuth divides the normalised u by the normalised d of at least two
+// limbs, storing the quotient in quot and leaving the shifted remainder in u.
+func udivremKnuth(quot, u, d []uint64) {
+	var (
+		dh = d[len(d)-1]
+		dl = d[len(d)-2]
+	)
+	for j := len(u) - len(d) - 1; j >= 0; j-- {
+		u2 := u[j+len(d)]
+		u1 := u[j+len(d)-1]
+		u0 := u[j+len(d)-2]
+
+		// Estimate the quotient digit from the top limbs, it is at most one
+		// too large after the correction.
+		var qhat, rhat uint64
+		if u2 >= dh {
+			qhat = ^uint64(0)
+		} else {
+			qhat, rhat = div64(u2, u1, dh)
+			ph, pl := mul64(qhat, dl)
+			if ph > rhat || (ph == rhat && pl > u0) {
+				qhat--
+			}
+		}
+
+		// Subtract qhat * d and add d back if the estimate was too large.
+		borrow := subMulTo(u[j:], d, qhat)
+		u[j+len(d)] = u2 - borrow
+		if u2 < borrow {
+			qhat--
+			u[j+len(d)] += addTo(u[j:], d)
+		}
+		quot[j] = qhat
+	}
+}
+
+// subMulTo computes x -= y * m and returns the borrow out of len(y) limbs.
+func subMulTo(x, y []uint64, m uint64) uint64 {
+	var borrow uint64
+	for i := 0; i < len(y); i++ {
+		s, c1 := sub64(x[i], borrow, 0)
+		ph, pl := mul64(y[i], m)
+		t, c2 := sub64(s, pl, 0)
+		x[i] = t
+		borrow = ph + c1 + c2
+	}
+	return borrow
+}
+
+// addTo computes x += y and returns the carry out of len(y) limbs.
+func addTo(x, y []uint64) uint64 {
+	var carry uint64
+	for i := 0; i < len(y); i++ {
+		x[i], carry = add64(x[i], y[i], carry)
+	}
+	return carry
+}
+
+// The limb arithmetic below only uses plain uint64 operations so it builds
+// with the Go releases we support.
+
+// add64 returns x + y + carry and the carry out, carry must be 0 or 1.
+func add64(x, y, carry uint64) (sum, carryOut uint64) {
+	sum = x + y
+	if sum < x {
+		carryOut = 1
+	}
+	sum += carry
+	if sum < carry {
+		carryOut = 1
+	}
+	return sum, carryOut
+}
+
+// sub64 returns x - y - borrow and the borrow out, borrow must be 0 or 1.
+func sub64(x, y, borrow uint64) (diff, borrowOut uint64) {
+	diff = x - y
+	if x < y {
+		borrowOut = 1
+	}
+	if diff < borrow {
+		borrowOut = 1
+	}
+	diff -= borrow
+	return diff, borrowOut
+}
+
+// mul64 returns the high and low limb of x * y, multiplying 32 bit halves.
+func mul64(x, y uint64) (hi, lo uint64) {
+	const mask32 = 1<<32 - 1
+	x0, x1 := x&mask32, x>>32
+	y0, y1 := y&mask32, y>>32
+	w0 := x0 * y0
+	t := x1*y0 + w0>>32
+	w1, w2 := t&mask32, t>>32
+	w1 += x0 * y1
+	hi = x1*y1 + w2 + w1>>32
+	lo = x * y
+	return hi, lo
+}
+
+// div64 returns the quotient and remainder of the two limb number hi, lo
+// divided by y, which must be larger than hi. It divides the normalised
+// values in 32 bit halves (Hacker's Delight, divlu).
+func div64(hi, lo, y uint64) (quo, rem uint64) {
+	if hi == 0 {
+		return lo / y, lo % y
+	}
+	const (
+		two32  = 1 << 32
+		mask32 = two32 - 1
+	)
+	s := uint(64 - len64(y))
+	y <<= s
+	yn1, yn0 := y>>32, y&mask32
+	un32 := hi<<s | lo>>(64-s)
+	un10 := lo << s
+	un1, un0 := un10>>32, un10&mask32
+
+	q1 := un32 / yn1
+	rhat := un32 - q1*yn1
+	for q1 >= two32 || q1*yn0 > two32*rhat+un1 {
+		q1--
+		rhat += yn1
+		if rhat >= two32 {
+			break
+		}
+	}
+	un21 := un32*two32 + un1 - q1*y
+
+	q0 := un21 / yn1
+	rhat = un21 - q0*yn1
+	for q0 >= two32 || q0*yn0 > two32*rhat+un0 {
+		q0--
+		rhat += yn1
+		if rhat >= two32 {
+			break
+		}
+	}
+	return q1*two32 + q0, (un21*two32 + un0 - q0*y) >> s
+}
+
+// len64 returns the number of bits needed to represent x.
+func len64(x uint64) (n int) {
+	for ; x >= 1<<16; x >>= 16 {
+		n += 16
+	}
+	for ; x != 0; x >>= 1 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,337 @@
+package vm
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	tt256   = new(big.Int).Lsh(common.Big1, 256)
+	tt256m1 = new(big.Int).Sub(tt256, common.Big1)
+	tt255   = new(big.Int).Lsh(common.Big1, 255)
+)
+
+// randomBig returns a random 256 bit value, biased towards the edge cases of
+// the arithmetic: small numbers, powers of two and their neighbours, values
+// spanning a few limbs and values close to the signed and unsigned limits.
+func randomBig(rnd *rand.Rand) *big.Int {
+	x := new(big.Int)
+	switch rnd.Intn(6) {
+	case 0:
+		x.SetInt64(rnd.Int63n(4))
+	case 1:
+		x.Lsh(common.Big1, uint(rnd.Intn(257)))
+		x.Add(x, big.NewInt(rnd.Int63n(3)-1))
+	case 2:
+		x.Rand(rnd, tt256)
+		x.Rsh(x, uint(rnd.Intn(256)))
+	case 3:
+		x.Sub(tt256, big.NewInt(rnd.Int63n(4)+1))
+	case 4:
+		x.Add(tt255, big.NewInt(rnd.Int63n(3)-1))
+	default:
+		x.Rand(rnd, tt256)
+	}
+	return x.And(x, tt256m1)
+}
+
+func toSigned(x *big.Int) *big.Int {
+	if x.Cmp(tt255) < 0 {
+		return new(big.Int).Set(x)
+	}
+	return new(big.Int).Sub(x, tt256)
+}
+
+func boolBig(b bool) *big.Int {
+	if b {
+		return big.NewInt(1)
+	}
+	return big.NewInt(0)
+}
+
+// The reference implementations below follow the big.Int based interpreter.
+var binaryOps = []struct {
+	name string
+	op   func(z, x, y *word) *word
+	ref  func(x, y *big.Int) *big.Int
+}{
+	{"Add", (*word).Add, func(x, y *big.Int) *big.Int { return new(big.Int).Add(x, y) }},
+	{"Sub", (*word).Sub, func(x, y *big.Int) *big.Int { return new(big.Int).Sub(x, y) }},
+	{"Mul", (*word).Mul, func(x, y *big.Int) *big.Int { return new(big.Int).Mul(x, y) }},
+	{"Div", (*word).Div, func(x, y *big.Int) *big.Int {
+		if y.Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Div(x, y)
+	}},
+	{"Mod", (*word).Mod, func(x, y *big.Int) *big.Int {
+		if y.Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Mod(x, y)
+	}},
+	{"SDiv", (*word).SDiv, func(x, y *big.Int) *big.Int {
+		sx, sy := toSigned(x), toSigned(y)
+		if sy.Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Quo(sx, sy)
+	}},
+	{"SMod", (*word).SMod, func(x, y *big.Int) *big.Int {
+		sx, sy := toSigned(x), toSigned(y)
+		if sy.Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Rem(sx, sy)
+	}},
+	{"Exp", (*word).Exp, func(x, y *big.Int) *big.Int { return new(big.Int).Exp(x, y, tt256) }},
+	{"SignExtend", (*word).SignExtend, func(back, num *big.Int) *big.Int {
+		if back.Cmp(big.NewInt(31)) >= 0 {
+			return new(big.Int).Set(num)
+		}
+		bit := uint(back.Uint64()*8 + 7)
+		mask := new(big.Int).Lsh(common.Big1, bit)
+		mask.Sub(mask, common.Big1)
+		if num.Bit(int(bit)) > 0 {
+			return new(big.Int).Or(num, mask.Not(mask))
+		}
+		return new(big.Int).And(num, mask)
+	}},
+	{"Byte", (*word).Byte, func(th, val *big.Int) *big.Int {
+		if th.Cmp(big.NewInt(32)) >= 0 {
+			return new(big.Int)
+		}
+		return big.NewInt(int64(common.LeftPadBytes(val.Bytes(), 32)[th.Int64()]))
+	}},
+	{"And", (*word).And, func(x, y *big.Int) *big.Int { return new(big.Int).And(x, y) }},
+	{"Or", (*word).Or, func(x, y *big.Int) *big.Int { return new(big.Int).Or(x, y) }},
+	{"Xor", (*word).Xor, func(x, y *big.Int) *big.Int { return new(big.Int).Xor(x, y) }},
+}
+
+var ternaryOps = []struct {
+	name string
+	op   func(z, x, y, m *word) *word
+	ref  func(x, y, m *big.Int) *big.Int
+}{
+	{"AddMod", (*word).AddMod, func(x, y, m *big.Int) *big.Int {
+		if m.Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Mod(new(big.Int).Add(x, y), m)
+	}},
+	{"MulMod", (*word).MulMod, func(x, y, m *big.Int) *big.Int {
+		if m.Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Mod(new(big.Int).Mul(x, y), m)
+	}},
+}
+
+var compareOps = []struct {
+	name string
+	op   func(x, y *word) bool
+	ref  func(x, y *big.Int) bool
+}{
+	{"Lt", (*word).Lt, func(x, y *big.Int) bool { return x.Cmp(y) < 0 }},
+	{"Gt", (*word).Gt, func(x, y *big.Int) bool { return x.Cmp(y) > 0 }},
+	{"Slt", (*word).Slt, func(x, y *big.Int) bool { return toSigned(x).Cmp(toSigned(y)) < 0 }},
+	{"Sgt", (*word).Sgt, func(x, y *big.Int) bool { return toSigned(x).Cmp(toSigned(y)) > 0 }},
+	{"Eq", (*word).Eq, func(x, y *big.Int) bool { return x.Cmp(y) == 0 }},
+}
+
+func TestWordArithmetic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		x, y, m := randomBig(rnd), randomBig(rnd), randomBig(rnd)
+
+		var wx, wy, wm word
+		wx.SetBig(x)
+		wy.SetBig(y)
+		wm.SetBig(m)
+		if wx.Big().Cmp(x) != 0 {
+			t.Fatalf("SetBig(%v).Big() = %v", x, &wx)
+		}
+
+		for _, test := range binaryOps {
+			want := new(big.Int).And(test.ref(x, y), tt256m1)
+			if have := test.op(new(word), &wx, &wy); have.Big().Cmp(want) != 0 {
+				t.Errorf("%s(%#x, %#x) = %#x, want %#x", test.name, x, y, have.Big(), want)
+			}
+			// the result may be stored in one of the operands
+			z := wy
+			if have := test.op(&z, &wx, &z); have.Big().Cmp(want) != 0 {
+				t.Errorf("%s(%#x, %#x) in place = %#x, want %#x", test.name, x, y, have.Big(), want)
+			}
+		}
+		for _, test := range ternaryOps {
+			want := test.ref(x, y, m)
+			if have := test.op(new(word), &wx, &wy, &wm); have.Big().Cmp(want) != 0 {
+				t.Errorf("%s(%#x, %#x, %#x) = %#x, want %#x", test.name, x, y, m, have.Big(), want)
+			}
+			z := wm
+			if have := test.op(&z, &wx, &wy, &z); have.Big().Cmp(want) != 0 {
+				t.Errorf("%s(%#x, %#x, %#x) in place = %#x, want %#x", test.name, x, y, m, have.Big(), want)
+			}
+		}
+		for _, test := range compareOps {
+			if have, want := test.op(&wx, &wy), test.ref(x, y); have != want {
+				t.Errorf("%s(%#x, %#x) = %v, want %v", test.name, x, y, have, want)
+			}
+		}
+
+		if have, want := new(word).Not(&wx).Big(), new(big.Int).Xor(x, tt256m1); have.Cmp(want) != 0 {
+			t.Errorf("Not(%#x) = %#x, want %#x", x, have, want)
+		}
+		n := uint(rnd.Intn(260))
+		if have, want := new(word).Lsh(&wx, n).Big(), new(big.Int).And(new(big.Int).Lsh(x, n), tt256m1); have.Cmp(want) != 0 {
+			t.Errorf("Lsh(%#x, %d) = %#x, want %#x", x, n, have, want)
+		}
+		if have, want := new(word).Rsh(&wx, n).Big(), new(big.Int).Rsh(x, n); have.Cmp(want) != 0 {
+			t.Errorf("Rsh(%#x, %d) = %#x, want %#x", x, n, have, want)
+		}
+		want := new(big.Int).Rsh(toSigned(x), n)
+		if have := new(word).SRsh(&wx, n).Big(); have.Cmp(want.And(want, tt256m1)) != 0 {
+			t.Errorf("SRsh(%#x, %d) = %#x, want %#x", x, n, have, want)
+		}
+	}
+}
+
+func TestWordConversion(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		x := randomBig(rnd)
+
+		var w word
+		w.SetBytes(x.Bytes())
+		if w.Big().Cmp(x) != 0 {
+			t.Errorf("SetBytes(%x) = %#x", x.Bytes(), w.Big())
+		}
+		if b := w.Bytes32(); new(big.Int).SetBytes(b[:]).Cmp(x) != 0 {
+			t.Errorf("%#x: Bytes32 = %x", x, b)
+		}
+		if have, want := w.Bytes(), x.Bytes(); string(have) != string(want) {
+			t.Errorf("%#x: Bytes = %x, want %x", x, have, want)
+		}
+		if have, want := w.BitLen(), x.BitLen(); have != want {
+			t.Errorf("%#x: BitLen = %d, want %d", x, have, want)
+		}
+		// negative values are stored as two's complement
+		neg := new(big.Int).Neg(x)
+		if have, want := w.SetBig(neg).Big(), new(big.Int).And(neg, tt256m1); have.Cmp(want) != 0 {
+			t.Errorf("SetBig(%v) = %#x, want %#x", neg, have, want)
+		}
+	}
+	// only the last 32 bytes are used
+	b := make([]byte, 40)
+	b[0], b[39] = 0xff, 0x01
+	if w := new(word).SetBytes(b); *w != (word{1}) {
+		t.Errorf("SetBytes(%x) = %v, want 1", b, w)
+	}
+}
+
+func TestLimbArithmetic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	edges := []uint64{0, 1, 2, 1<<32 - 1, 1 << 32, 1<<63 - 1, 1 << 63, 1<<64 - 1}
+	limb := func() uint64 {
+		if rnd.Intn(2) == 0 {
+			return edges[rnd.Intn(len(edges))]
+		}
+		return uint64(rnd.Int63())<<1 | uint64(rnd.Intn(2))
+	}
+	two64 := new(big.Int).Lsh(common.Big1, 64)
+	split := func(x *big.Int) (hi, lo uint64) {
+		return new(big.Int).Rsh(x, 64).Uint64(), new(big.Int).Mod(x, two64).Uint64()
+	}
+	for i := 0; i < 10000; i++ {
+		x, y, c := limb(), limb(), uint64(rnd.Intn(2))
+		bx, by, bc := new(big.Int).SetUint64(x), new(big.Int).SetUint64(y), new(big.Int).SetUint64(c)
+
+		sum := new(big.Int).Add(bx, by)
+		sum.Add(sum, bc)
+		wantc, want := split(sum)
+		if have, havec := add64(x, y, c); have != want || havec != wantc {
+			t.Errorf("add64(%#x, %#x, %d) = %#x, %d, want %#x, %d", x, y, c, have, havec, want, wantc)
+		}
+		diff := new(big.Int).Sub(bx, by)
+		diff.Sub(diff, bc)
+		want, wantb := new(big.Int).Mod(diff, two64).Uint64(), uint64(0)
+		if diff.Sign() < 0 {
+			wantb = 1
+		}
+		if have, haveb := sub64(x, y, c); have != want || haveb != wantb {
+			t.Errorf("sub64(%#x, %#x, %d) = %#x, %d, want %#x, %d", x, y, c, have, haveb, want, wantb)
+		}
+		wanthi, wantlo := split(new(big.Int).Mul(bx, by))
+		if hi, lo := mul64(x, y); hi != wanthi || lo != wantlo {
+			t.Errorf("mul64(%#x, %#x) = %#x, %#x, want %#x, %#x", x, y, hi, lo, wanthi, wantlo)
+		}
+		if y != 0 {
+			hi := limb() % y
+			u := new(big.Int).Lsh(new(big.Int).SetUint64(hi), 64)
+			u.Add(u, bx)
+			q, r := new(big.Int).QuoRem(u, by, new(big.Int))
+			if haveq, haver := div64(hi, x, y); haveq != q.Uint64() || haver != r.Uint64() {
+				t.Errorf("div64(%#x, %#x, %#x) = %#x, %#x, want %v, %v", hi, x, y, haveq, haver, q, r)
+			}
+		}
+		if have, want := len64(x), bx.BitLen(); have != want {
+			t.Errorf("len64(%#x) = %d, want %d", x, have, want)
+		}
+	}
+}
+
+// benchArgs returns the operands of the benchmarks: full size values with a
+// divisor and modulus smaller than the dividend.
+func benchArgs() (x, y, m *big.Int) {
+	rnd := rand.New(rand.NewSource(1))
+	return new(big.Int).Rand(rnd, tt256), new(big.Int).Rand(rnd, tt255), new(big.Int).Rand(rnd, tt255)
+}
+
+func benchmarkWord(b *testing.B, op func(z, x, y *word) *word) {
+	x, y, _ := benchArgs()
+	var wx, wy, z word
+	wx.SetBig(x)
+	wy.SetBig(y)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		op(&z, &wx, &wy)
+	}
+}
+
+func benchmarkBig(b *testing.B, op func(x, y *big.Int) *big.Int) {
+	x, y, _ := benchArgs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		common.U256(op(x, y))
+	}
+}
+
+func BenchmarkWordMul(b *testing.B) { benchmarkWord(b, (*word).Mul) }
+func BenchmarkBigMul(b *testing.B)  { benchmarkBig(b, binaryOps[2].ref) }
+func BenchmarkWordDiv(b *testing.B) { benchmarkWord(b, (*word).Div) }
+func BenchmarkBigDiv(b *testing.B)  { benchmarkBig(b, binaryOps[3].ref) }
+func BenchmarkWordExp(b *testing.B) { benchmarkWord(b, (*word).Exp) }
+func BenchmarkBigExp(b *testing.B)  { benchmarkBig(b, binaryOps[7].ref) }
+
+func BenchmarkWordMulMod(b *testing.B) {
+	x, y, m := benchArgs()
+	var wx, wy, wm, z word
+	wx.SetBig(x)
+	wy.SetBig(y)
+	wm.SetBig(m)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		z.MulMod(&wx, &wy, &wm)
+	}
+}
+
+func BenchmarkBigMulMod(b *testing.B) {
+	x, y, m := benchArgs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ternaryOps[1].ref(x, y, m)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 type VMEnv struct {
@@ -15,12 +16,23 @@ type VMEnv struct {
 	msg   Message
 	depth int
 	chain *ChainManager
+	rules *params.RuleSet
 	typ   vm.Type
+
+	logs []vm.StructLog
 }
 
+// NewEnv creates the environment to run msg in as part of block. The rules of
+// the block are taken from the configuration of chain, or are the main chain
+// rules without a chain.
 func NewEnv(state *state.StateDB, chain *ChainManager, msg Message, block *types.Block) *VMEnv {
+	config := params.DefaultChainConfig
+	if chain != nil {
+		config = chain.Config()
+	}
 	return &VMEnv{
 		chain: chain,
+		rules: config.Rules(block.Number()),
 		state: state,
 		block: block,
 		msg:   msg,
@@ -34,6 +46,7 @@ func (self *VMEnv) Coinbase() common.Address { return self.block.Coinbase() }
 func (self *VMEnv) Time() int64              { return self.block.Time() }
 func (self *VMEnv) Difficulty() *big.Int     { return self.block.Difficulty() }
 func (self *VMEnv) GasLimit() *big.Int       { return self.block.GasLimit() }
+func (self *VMEnv) Rules() *params.RuleSet   { return self.rules }
 func (self *VMEnv) Value() *big.Int          { return self.msg.Value() }
 func (self *VMEnv) State() *state.StateDB    { return self.state }
 func (self *VMEnv) Depth() int               { return self.depth }
@@ -50,6 +63,15 @@ func (self *VMEnv) GetHash(n uint64) common.Hash {
 func (self *VMEnv) AddLog(log *state.Log) {
 	self.state.AddLog(log)
 }
+func (self *VMEnv) AddStructLog(log vm.StructLog) {
+	self.logs = append(self.logs, log)
+}
+
+// StructLogs returns the steps reported by the interpreter, see
+// vm.GenerateStructLogs.
+func (self *VMEnv) StructLogs() []vm.StructLog {
+	return self.logs
+}
 func (self *VMEnv) Transfer(from, to vm.Account, amount *big.Int) error {
 	return vm.Transfer(from, to, amount)
 }
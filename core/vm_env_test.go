@@ -0,0 +1,148 @@
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestStructLogs(t *testing.T) {
+	vm.GenerateStructLogs = true
+	defer func() { vm.GenerateStructLogs = false }()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb := state.New(common.Hash{}, db)
+	caller := statedb.CreateAccount(common.StringToAddress("caller"))
+	contract := statedb.CreateAccount(common.StringToAddress("contract"))
+	contract.SetCode(common.Hex2Bytes("6001600201")) // PUSH1 1 PUSH1 2 ADD
+
+	msg := types.NewTransactionMessage(contract.Address(), common.Big0, big.NewInt(100), common.Big0, nil)
+	block := types.NewBlock(common.Hash{}, common.Address{}, common.Hash{}, common.Big1, 0, nil)
+	env := NewEnv(statedb, nil, msg, block)
+	// the compiled VM doesn't report steps and is replaced by the interpreter
+	env.SetVmType(vm.CompiledVmTy)
+
+	if _, err := env.Call(caller, contract.Address(), nil, big.NewInt(100), common.Big0, common.Big0); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		pc    uint64
+		op    vm.OpCode
+		gas   int64
+		stack []int64
+	}{
+		{0, vm.PUSH1, 100, nil},
+		{2, vm.PUSH1, 97, []int64{1}},
+		{4, vm.ADD, 94, []int64{1, 2}},
+		{5, vm.STOP, 91, []int64{3}},
+	}
+	logs := env.StructLogs()
+	if len(logs) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(logs), len(want))
+	}
+	for i, w := range want {
+		log := logs[i]
+		if log.Pc != w.pc || log.Op != w.op || log.Gas.Int64() != w.gas || log.Depth != 1 || log.Err != nil {
+			t.Errorf("step %d: got %v at %d with gas %v depth %d err %v, want %v at %d with gas %d depth 1",
+				i, log.Op, log.Pc, log.Gas, log.Depth, log.Err, w.op, w.pc, w.gas)
+		}
+		if len(log.Stack) != len(w.stack) {
+			t.Errorf("step %d: got stack %v, want %v", i, log.Stack, w.stack)
+			continue
+		}
+		for j := range w.stack {
+			if log.Stack[j].Int64() != w.stack[j] {
+				t.Errorf("step %d: got stack %v, want %v", i, log.Stack, w.stack)
+				break
+			}
+		}
+	}
+}
+
+func TestForkRules(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	chain := newChainManager(nil, &event.TypeMux{}, db)
+	chain.config = &params.ChainConfig{Forks: []*params.RuleSet{
+		params.FrontierRules,
+		{
+			Block:       big.NewInt(5),
+			Gas:         &params.GasTable{SLoad: big.NewInt(200), TxCreate: big.NewInt(53000)},
+			DisabledOps: []string{"CALLCODE"},
+		},
+	}}
+	if err := chain.config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	newBlock := func(number int64) *types.Block {
+		block := types.NewBlock(common.Hash{}, common.Address{}, common.Hash{}, common.Big1, 0, nil)
+		block.Header().Number = big.NewInt(number)
+		block.Header().GasLimit = big.NewInt(1000000)
+		return block
+	}
+	run := func(typ vm.Type, number int64, code string) (*big.Int, error) {
+		statedb := state.New(common.Hash{}, db)
+		caller := statedb.CreateAccount(common.StringToAddress("caller"))
+		contract := statedb.CreateAccount(common.StringToAddress("contract"))
+		contract.SetCode(common.Hex2Bytes(code))
+
+		msg := types.NewTransactionMessage(contract.Address(), common.Big0, big.NewInt(10000), common.Big0, nil)
+		env := NewEnv(statedb, chain, msg, newBlock(number))
+		env.SetVmType(typ)
+
+		gas := big.NewInt(10000)
+		_, err := env.Call(caller, contract.Address(), nil, gas, common.Big0, common.Big0)
+		return new(big.Int).Sub(big.NewInt(10000), gas), err
+	}
+
+	for _, typ := range []vm.Type{vm.StdVmTy, vm.CompiledVmTy} {
+		// PUSH1 0 SLOAD
+		for number, want := range map[int64]int64{4: 3 + 50, 5: 3 + 200} {
+			used, err := run(typ, number, "600054")
+			if err != nil {
+				t.Fatalf("vm %d block %d: SLOAD failed: %v", typ, number, err)
+			}
+			if used.Int64() != want {
+				t.Errorf("vm %d block %d: SLOAD used %v gas, want %d", typ, number, used, want)
+			}
+		}
+
+		// CALLCODE with all arguments zero
+		code := "6000600060006000600060006000f2"
+		if _, err := run(typ, 4, code); err != nil {
+			t.Errorf("vm %d block 4: CALLCODE failed: %v", typ, err)
+		}
+		if _, err := run(typ, 5, code); err == nil || !strings.HasPrefix(err.Error(), "Invalid opcode") {
+			t.Errorf("vm %d block 5: CALLCODE error %v, want invalid opcode", typ, err)
+		}
+	}
+
+	// intrinsic gas of a contract creation
+	key, _ := crypto.GenerateKey()
+	for number, want := range map[int64]int64{4: 21000, 5: 53000} {
+		statedb := state.New(common.Hash{}, db)
+		block := newBlock(number)
+		coinbase := statedb.GetOrNewStateObject(block.Coinbase())
+		coinbase.SetGasPool(block.GasLimit())
+
+		tx := types.NewContractCreationTx(common.Big0, big.NewInt(100000), common.Big0, nil)
+		tx.SignECDSA(key)
+		_, used, err := ApplyMessage(NewEnv(statedb, chain, tx, block), tx, coinbase)
+		if err != nil {
+			t.Fatalf("block %d: contract creation failed: %v", number, err)
+		}
+		if used.Int64() != want {
+			t.Errorf("block %d: contract creation used %v gas, want %d", number, used, want)
+		}
+	}
+}
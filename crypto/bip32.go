@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BIP32 hierarchical deterministic keys on secp256k1, see
+// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki
+
+// HardenedKeyStart is the index of the first hardened child key. Hardened keys
+// can only be derived from the private parent key.
+const HardenedKeyStart uint32 = 0x80000000
+
+var (
+	ErrInvalidChildKey = errors.New("derived key is invalid, use the next index")
+	ErrInvalidSeedLen  = errors.New("seed must be 16 to 64 bytes long")
+
+	masterKeySalt = []byte("Bitcoin seed")
+
+	// serialization versions of mainnet extended keys (xprv and xpub)
+	xprvVersion = []byte{0x04, 0x88, 0xad, 0xe4}
+	xpubVersion = []byte{0x04, 0x88, 0xb2, 0x1e}
+)
+
+// ExtendedKey is a private key of a BIP32 key tree along with the chain code
+// needed to derive its children.
+type ExtendedKey struct {
+	key       []byte // 32 byte private key
+	chainCode []byte
+	depth     byte
+	parentFP  []byte // fingerprint of the parent key
+	index     uint32 // index of the key within its parent
+}
+
+// NewMasterKey creates the root key of the key tree generated from seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrInvalidSeedLen
+	}
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key, chainCode := sum[:32], sum[32:]
+	if k := new(big.Int).SetBytes(key); k.Sign() == 0 || k.Cmp(S256().N) >= 0 {
+		return nil, ErrInvalidChildKey
+	}
+	return &ExtendedKey{key: key, chainCode: chainCode, parentFP: make([]byte, 4)}, nil
+}
+
+// Child derives the child key at index i. Indices from HardenedKeyStart on
+// derive hardened keys.
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	var data []byte
+	if i >= HardenedKeyStart {
+		data = append([]byte{0}, k.key...)
+	} else {
+		data = k.PublicKey()
+	}
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, i)
+	data = append(data, index...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	// the child key is the parent key tweaked by the left half of the hmac
+	n := S256().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, ErrInvalidChildKey
+	}
+	child := tweak.Add(tweak, new(big.Int).SetBytes(k.key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, ErrInvalidChildKey
+	}
+	return &ExtendedKey{
+		key:       common.LeftPadBytes(child.Bytes(), 32),
+		chainCode: sum[32:],
+		depth:     k.depth + 1,
+		parentFP:  k.fingerprint(),
+		index:     i,
+	}, nil
+}
+
+// Derive derives the descendant key at path, relative to k.
+func (k *ExtendedKey) Derive(path DerivationPath) (*ExtendedKey, error) {
+	var err error
+	for _, i := range path {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ECDSA returns the private key.
+func (k *ExtendedKey) ECDSA() *ecdsa.PrivateKey {
+	return ToECDSA(k.key)
+}
+
+// PublicKey returns the compressed public key.
+func (k *ExtendedKey) PublicKey() []byte {
+	x, y := S256().ScalarBaseMult(k.key)
+	pub := []byte{0x02 + byte(y.Bit(0))}
+	return append(pub, common.LeftPadBytes(x.Bytes(), 32)...)
+}
+
+// Address returns the Ethereum address of the key.
+func (k *ExtendedKey) Address() common.Address {
+	return common.BytesToAddress(PubkeyToAddress(k.ECDSA().PublicKey))
+}
+
+// String returns the base58 serialization of the extended private key (xprv).
+func (k *ExtendedKey) String() string {
+	return k.serialize(xprvVersion, append([]byte{0}, k.key...))
+}
+
+// PublicString returns the base58 serialization of the extended public key
+// (xpub), which allows deriving the non-hardened public child keys.
+func (k *ExtendedKey) PublicString() string {
+	return k.serialize(xpubVersion, k.PublicKey())
+}
+
+// Zero clears the private key and chain code from memory.
+func (k *ExtendedKey) Zero() {
+	for i := range k.key {
+		k.key[i] = 0
+	}
+	for i := range k.chainCode {
+		k.chainCode[i] = 0
+	}
+}
+
+// fingerprint identifies the key as the parent of its children.
+func (k *ExtendedKey) fingerprint() []byte {
+	return Ripemd160(Sha256(k.PublicKey()))[:4]
+}
+
+func (k *ExtendedKey) serialize(version, key []byte) string {
+	data := make([]byte, 0, 82)
+	data = append(data, version...)
+	data = append(data, k.depth)
+	data = append(data, k.parentFP...)
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, k.index)
+	data = append(data, index...)
+	data = append(data, k.chainCode...)
+	data = append(data, key...)
+	data = append(data, Sha256(Sha256(data))[:4]...)
+
+	return base58Encode(data)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes data in the alphabet used by Bitcoin. Leading zero
+// bytes are kept as leading '1' characters.
+func base58Encode(data []byte) string {
+	var out []byte
+	x, mod, radix := new(big.Int).SetBytes(data), new(big.Int), big.NewInt(58)
+	for x.Sign() > 0 {
+		x.DivMod(x, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,192 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Official BIP32 test vectors 1 to 3:
+// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vectors
+var bip32Vectors = []struct {
+	seed string
+	keys []bip32VectorKey
+}{
+	{
+		seed: "000102030405060708090a0b0c0d0e0f",
+		keys: []bip32VectorKey{
+			{
+				"m",
+				"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8",
+				"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+			},
+			{
+				"m/0'",
+				"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+				"xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+			},
+			{
+				"m/0'/1",
+				"xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+				"xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+			},
+			{
+				"m/0'/1/2'",
+				"xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+				"xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM",
+			},
+			{
+				"m/0'/1/2'/2",
+				"xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+				"xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334",
+			},
+			{
+				"m/0'/1/2'/2/1000000000",
+				"xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+				"xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76",
+			},
+		},
+	},
+	{
+		seed: "fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542",
+		keys: []bip32VectorKey{
+			{
+				"m",
+				"xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+				"xprv9s21ZrQH143K31xYSDQpPDxsXRTUcvj2iNHm5NUtrGiGG5e2DtALGdso3pGz6ssrdK4PFmM8NSpSBHNqPqm55Qn3LqFtT2emdEXVYsCzC2U",
+			},
+			{
+				"m/0",
+				"xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH",
+				"xprv9vHkqa6EV4sPZHYqZznhT2NPtPCjKuDKGY38FBWLvgaDx45zo9WQRUT3dKYnjwih2yJD9mkrocEZXo1ex8G81dwSM1fwqWpWkeS3v86pgKt",
+			},
+			{
+				"m/0/2147483647'",
+				"xpub6ASAVgeehLbnwdqV6UKMHVzgqAG8Gr6riv3Fxxpj8ksbH9ebxaEyBLZ85ySDhKiLDBrQSARLq1uNRts8RuJiHjaDMBU4Zn9h8LZNnBC5y4a",
+				"xprv9wSp6B7kry3Vj9m1zSnLvN3xH8RdsPP1Mh7fAaR7aRLcQMKTR2vidYEeEg2mUCTAwCd6vnxVrcjfy2kRgVsFawNzmjuHc2YmYRmagcEPdU9",
+			},
+			{
+				"m/0/2147483647'/1",
+				"xpub6DF8uhdarytz3FWdA8TvFSvvAh8dP3283MY7p2V4SeE2wyWmG5mg5EwVvmdMVCQcoNJxGoWaU9DCWh89LojfZ537wTfunKau47EL2dhHKon",
+				"xprv9zFnWC6h2cLgpmSA46vutJzBcfJ8yaJGg8cX1e5StJh45BBciYTRXSd25UEPVuesF9yog62tGAQtHjXajPPdbRCHuWS6T8XA2ECKADdw4Ef",
+			},
+			{
+				"m/0/2147483647'/1/2147483646'",
+				"xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL",
+				"xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc",
+			},
+			{
+				"m/0/2147483647'/1/2147483646'/2",
+				"xpub6FnCn6nSzZAw5Tw7cgR9bi15UV96gLZhjDstkXXxvCLsUXBGXPdSnLFbdpq8p9HmGsApME5hQTZ3emM2rnY5agb9rXpVGyy3bdW6EEgAtqt",
+				"xprvA2nrNbFZABcdryreWet9Ea4LvTJcGsqrMzxHx98MMrotbir7yrKCEXw7nadnHM8Dq38EGfSh6dqA9QWTyefMLEcBYJUuekgW4BYPJcr9E7j",
+			},
+		},
+	},
+	{
+		seed: "4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be",
+		keys: []bip32VectorKey{
+			{
+				"m",
+				"xpub661MyMwAqRbcEZVB4dScxMAdx6d4nFc9nvyvH3v4gJL378CSRZiYmhRoP7mBy6gSPSCYk6SzXPTf3ND1cZAceL7SfJ1Z3GC8vBgp2epUt13",
+				"xprv9s21ZrQH143K25QhxbucbDDuQ4naNntJRi4KUfWT7xo4EKsHt2QJDu7KXp1A3u7Bi1j8ph3EGsZ9Xvz9dGuVrtHHs7pXeTzjuxBrCmmhgC6",
+			},
+			{
+				"m/0'",
+				"xpub68NZiKmJWnxxS6aaHmn81bvJeTESw724CRDs6HbuccFQN9Ku14VQrADWgqbhhTHBaohPX4CjNLf9fq9MYo6oDaPPLPxSb7gwQN3ih19Zm4Y",
+				"xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L",
+			},
+		},
+	},
+}
+
+type bip32VectorKey struct {
+	path, xpub, xprv string
+}
+
+func TestBIP32Vectors(t *testing.T) {
+	for i, test := range bip32Vectors {
+		seed, _ := hex.DecodeString(test.seed)
+		master, err := NewMasterKey(seed)
+		if err != nil {
+			t.Fatalf("vector %d: NewMasterKey error: %v", i+1, err)
+		}
+		for _, want := range test.keys {
+			path, err := ParseDerivationPath(want.path)
+			if err != nil {
+				t.Fatalf("vector %d: %v", i+1, err)
+			}
+			key, err := master.Derive(path)
+			if err != nil {
+				t.Fatalf("vector %d, %s: Derive error: %v", i+1, want.path, err)
+			}
+			if xprv := key.String(); xprv != want.xprv {
+				t.Errorf("vector %d, %s: xprv mismatch:\nhave %s\nwant %s", i+1, want.path, xprv, want.xprv)
+			}
+			if xpub := key.PublicString(); xpub != want.xpub {
+				t.Errorf("vector %d, %s: xpub mismatch:\nhave %s\nwant %s", i+1, want.path, xpub, want.xpub)
+			}
+		}
+	}
+}
+
+// Tests the first account of the BIP44 Ethereum path, which matches the
+// address other BIP39/BIP44 wallets derive from the same mnemonic.
+func TestBIP44EthereumAccount(t *testing.T) {
+	seed := MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := master.Derive(DefaultBasePath.Child(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "9858effd232b4033e47d90003d41ec34ecaeda94"
+	if have := hex.EncodeToString(key.Address().Bytes()); have != want {
+		t.Errorf("address mismatch: have %s, want %s", have, want)
+	}
+}
+
+func TestDerivationPath(t *testing.T) {
+	tests := []struct {
+		input string
+		path  DerivationPath
+		str   string
+	}{
+		{"m", nil, "m"},
+		{"m/44'/60'/0'/0", DefaultBasePath, "m/44'/60'/0'/0"},
+		{"m/44h/60h/0h/0/7", DefaultBasePath.Child(7), "m/44'/60'/0'/0/7"},
+		{"m/0/2147483647'", DerivationPath{0, 1<<32 - 1}, "m/0/2147483647'"},
+	}
+	for i, test := range tests {
+		path, err := ParseDerivationPath(test.input)
+		if err != nil {
+			t.Errorf("test %d: parse error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(path, test.path) {
+			t.Errorf("test %d: path mismatch: have %v, want %v", i, []uint32(path), []uint32(test.path))
+		}
+		if path.String() != test.str {
+			t.Errorf("test %d: string mismatch: have %s, want %s", i, path, test.str)
+		}
+	}
+	for _, input := range []string{"", "44'/60'", "m/", "m/x", "m/2147483648", "m/-1"} {
+		if _, err := ParseDerivationPath(input); err == nil {
+			t.Errorf("no error for invalid path %q", input)
+		}
+	}
+
+	enc, err := json.Marshal(DefaultBasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec DerivationPath
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec, DefaultBasePath) {
+		t.Errorf("JSON round trip mismatch: have %v, want %v", dec, DefaultBasePath)
+	}
+}
@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// BIP39 mnemonic sentences, see
+// https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+//
+// A sentence encodes 128 to 256 bits of entropy followed by a checksum of one
+// bit per 32 bits of entropy, using 11 bits per word. The seed derived from
+// the sentence is the root of a BIP32 key tree.
+//
+// Only the English word list is supported. The sentence and the passphrase
+// are used as given, callers must pass them in Unicode NFKD form if they
+// contain non-ASCII characters.
+
+var (
+	ErrMnemonicEntropy  = errors.New("mnemonic entropy must be 128 to 256 bits, a multiple of 32")
+	ErrMnemonicLength   = errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	ErrMnemonicChecksum = errors.New("mnemonic checksum mismatch")
+)
+
+var bip39Index = make(map[string]int64, len(bip39Words))
+
+func init() {
+	for i, word := range bip39Words {
+		bip39Index[word] = int64(i)
+	}
+}
+
+// NewMnemonic encodes the entropy as a BIP39 mnemonic sentence.
+func NewMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrMnemonicEntropy
+	}
+	// append the checksum bits to the entropy
+	checksumBits := uint(bits / 32)
+	checksum := Sha256(entropy)[0] >> (8 - checksumBits)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, checksumBits)
+	data.Or(data, big.NewInt(int64(checksum)))
+
+	// split into 11 bit words, starting from the end
+	words := make([]string, (bits+int(checksumBits))/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		index := new(big.Int).And(data, mask)
+		words[i] = bip39Words[index.Int64()]
+		data.Rsh(data, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes a BIP39 mnemonic sentence, verifying its checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrMnemonicLength
+	}
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := bip39Index[word]
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic word %q", word)
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(index))
+	}
+	checksumBits := uint(len(words) / 3)
+	checksum := new(big.Int).And(data, big.NewInt(1<<checksumBits-1))
+	data.Rsh(data, checksumBits)
+
+	entropy := make([]byte, int(checksumBits)*4)
+	raw := data.Bytes()
+	copy(entropy[len(entropy)-len(raw):], raw)
+
+	if Sha256(entropy)[0]>>(8-checksumBits) != byte(checksum.Int64()) {
+		return nil, ErrMnemonicChecksum
+	}
+	return entropy, nil
+}
+
+// ValidMnemonic reports whether the sentence is a well formed BIP39 mnemonic.
+func ValidMnemonic(mnemonic string) bool {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err == nil
+}
+
+// MnemonicToSeed derives the 64 byte BIP32 seed of a mnemonic sentence,
+// protected by an optional passphrase. The sentence is not validated so
+// that seeds of sentences in other languages can be derived as well.
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase), 2048, 64, sha512.New)
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DerivationPath is the list of child indices leading from a BIP32 root key
+// to one of its descendants.
+type DerivationPath []uint32
+
+// DefaultBasePath is the BIP44 path m/44'/60'/0'/0 of the external accounts
+// of the first Ethereum wallet. Account i of the wallet is at DefaultBasePath/i.
+var DefaultBasePath = DerivationPath{HardenedKeyStart + 44, HardenedKeyStart + 60, HardenedKeyStart, 0}
+
+// ParseDerivationPath parses a path of the form m/44'/60'/0'/0/1, with
+// hardened indices marked by a trailing ' or h.
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	elems := strings.Split(strings.TrimSpace(path), "/")
+	if elems[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q does not start at the root m", path)
+	}
+	var result DerivationPath
+	for _, elem := range elems[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h") {
+			offset, elem = HardenedKeyStart, elem[:len(elem)-1]
+		}
+		index, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", elem, path)
+		}
+		result = append(result, uint32(index)+offset)
+	}
+	return result, nil
+}
+
+// Child returns the path of the child at index i.
+func (p DerivationPath) Child(i uint32) DerivationPath {
+	return append(append(DerivationPath{}, p...), i)
+}
+
+// String returns the path in the notation accepted by ParseDerivationPath.
+func (p DerivationPath) String() string {
+	result := "m"
+	for _, index := range p {
+		if index >= HardenedKeyStart {
+			result += fmt.Sprintf("/%d'", index-HardenedKeyStart)
+		} else {
+			result += fmt.Sprintf("/%d", index)
+		}
+	}
+	return result
+}
+
+// MarshalJSON encodes the path as a JSON string.
+func (p DerivationPath) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(p.String())), nil
+}
+
+// UnmarshalJSON parses a path encoded as a JSON string.
+func (p *DerivationPath) UnmarshalJSON(data []byte) error {
+	str, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+	*p, err = ParseDerivationPath(str)
+	return err
+}
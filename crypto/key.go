@@ -47,16 +47,37 @@ type plainKeyJSON struct {
 	PrivateKey []byte
 }
 
-type cipherJSON struct {
-	Salt       []byte
-	IV         []byte
-	CipherText []byte
+type encryptedKeyJSONV3 struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Id      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams cipherparamsJSON       `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type cipherparamsJSON struct {
+	IV string `json:"iv"`
 }
 
-type encryptedKeyJSON struct {
+// encoding of encrypted keys before version 3
+type encryptedKeyJSONV1 struct {
 	Id      []byte
 	Address []byte
-	Crypto  cipherJSON
+	Crypto  cipherJSONV1
+}
+
+type cipherJSONV1 struct {
+	Salt       []byte
+	IV         []byte
+	CipherText []byte
 }
 
 func (k *Key) MarshalJSON() (j []byte, err error) {
@@ -0,0 +1,159 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// KeyBackupManifestFile is the name of the manifest in a key backup directory.
+const KeyBackupManifestFile = "manifest.json"
+
+// KeyBackupManifest lists the files of a key store backup. The files are
+// copied as they are, so encrypted keys stay encrypted with their passphrase.
+type KeyBackupManifest struct {
+	Created time.Time       `json:"created"`
+	Files   []KeyBackupFile `json:"files"`
+}
+
+// KeyBackupFile is a key or HD wallet file in a backup.
+type KeyBackupFile struct {
+	Path      string   `json:"path"`      // relative to the key store directory
+	Addresses []string `json:"addresses"` // hex addresses of the accounts in the file
+	SHA256    string   `json:"sha256"`    // hex checksum of the file content
+}
+
+// BackupKeyFiles copies every key file and HD wallet file of the key store
+// directory to backupDir, along with a manifest listing them. The manifest
+// is written last, so a backup without manifest is incomplete. An existing
+// backup is never overwritten.
+func BackupKeyFiles(keysDirPath, backupDir string) (*KeyBackupManifest, error) {
+	manifestPath := filepath.Join(backupDir, KeyBackupManifestFile)
+	if _, err := os.Stat(manifestPath); err == nil {
+		return nil, fmt.Errorf("backup already exists in %s", backupDir)
+	}
+	manifest := &KeyBackupManifest{Created: time.Now().UTC()}
+
+	// plain and encrypted keys are in directories named by their address
+	addresses, err := GetKeyAddresses(keysDirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addresses {
+		name := hex.EncodeToString(addr)
+		file, err := backupFile(keysDirPath, backupDir, filepath.Join(name, name))
+		if err != nil {
+			return nil, err
+		}
+		file.Addresses = []string{name}
+		manifest.Files = append(manifest.Files, file)
+	}
+	// HD wallets are in the hd directory, named by their id
+	wallets, err := keyStorePassphrase{keysDirPath: keysDirPath}.GetHDWallets()
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range wallets {
+		file, err := backupFile(keysDirPath, backupDir, filepath.Join("hd", w.Id.String()))
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range w.Addresses {
+			file.Addresses = append(file.Addresses, hex.EncodeToString(addr))
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(manifestPath, content); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// RestoreKeyFiles copies the files listed in the manifest of a backup made by
+// BackupKeyFiles into the key store directory and returns the restored files.
+// All checksums are verified before any file is copied. Files already present
+// in the key store with the same content are skipped, files with a different
+// content are an error.
+func RestoreKeyFiles(backupDir, keysDirPath string) ([]KeyBackupFile, error) {
+	content, err := ioutil.ReadFile(filepath.Join(backupDir, KeyBackupManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(KeyBackupManifest)
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %v", err)
+	}
+
+	var restore []KeyBackupFile
+	contents := make(map[string][]byte)
+	for _, file := range manifest.Files {
+		if filepath.IsAbs(file.Path) || filepath.Clean(file.Path) != file.Path || file.Path[0] == '.' {
+			return nil, fmt.Errorf("invalid path %q in backup manifest", file.Path)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(backupDir, file.Path))
+		if err != nil {
+			return nil, err
+		}
+		if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) != file.SHA256 {
+			return nil, fmt.Errorf("checksum mismatch for backup file %s", file.Path)
+		}
+		existing, err := ioutil.ReadFile(filepath.Join(keysDirPath, file.Path))
+		switch {
+		case os.IsNotExist(err):
+			restore = append(restore, file)
+			contents[file.Path] = content
+		case err != nil:
+			return nil, err
+		case !bytes.Equal(existing, content):
+			return nil, fmt.Errorf("key store file %s differs from the backup", file.Path)
+		}
+	}
+	for _, file := range restore {
+		dst := filepath.Join(keysDirPath, file.Path)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(dst, contents[file.Path]); err != nil {
+			return nil, err
+		}
+	}
+	return restore, nil
+}
+
+// backupFile copies a file of the key store directory to the same relative
+// path in the backup directory.
+func backupFile(keysDirPath, backupDir, rel string) (KeyBackupFile, error) {
+	content, err := ioutil.ReadFile(filepath.Join(keysDirPath, rel))
+	if err != nil {
+		return KeyBackupFile{}, err
+	}
+	dst := filepath.Join(backupDir, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return KeyBackupFile{}, err
+	}
+	if err := writeFileAtomic(dst, content); err != nil {
+		return KeyBackupFile{}, err
+	}
+	sum := sha256.Sum256(content)
+	return KeyBackupFile{Path: rel, SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+// writeFileAtomic writes a new file readable only by the user and renames it
+// over path.
+func writeFileAtomic(path string, content []byte) error {
+	if err := ioutil.WriteFile(path+".tmp", content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
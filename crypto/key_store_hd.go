@@ -0,0 +1,293 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"code.google.com/p/go-uuid/uuid"
+)
+
+/*
+
+HD wallets are stored next to the plain keys of the passphrase key store, one
+file per wallet in the hd directory. The BIP39 seed of a wallet is encrypted
+the same way as a private key, and migrated to the current version of the
+encryption when the wallet is unlocked. The base derivation path and the
+addresses of the accounts derived so far are stored in plain text, so the
+accounts of a wallet can be listed without the passphrase, just like the
+addresses of keys.
+
+Account i of a wallet is the key at the base path followed by i, which is the
+BIP44 path m/44'/60'/0'/0/i for wallets created with NewHDWallet.
+
+*/
+
+var ErrNoHDWallet = errors.New("address does not belong to an HD wallet")
+
+// HDWallet describes a hierarchical deterministic wallet held by a key store.
+type HDWallet struct {
+	Id        uuid.UUID
+	Path      DerivationPath // base path of the accounts
+	Addresses [][]byte       // addresses of the derived accounts, in order
+}
+
+type encryptedHDWalletJSON struct {
+	Id        []byte
+	Path      DerivationPath
+	Addresses [][]byte
+	Crypto    json.RawMessage // cryptoJSON, or cipherJSONV1 before version 3
+	Version   int
+}
+
+// HDKeyStore is implemented by key stores that can hold HD wallets. The
+// accounts derived from the wallets are included in GetKeyAddresses and
+// their keys are returned by GetKey, unlocking them with the wallet auth.
+type HDKeyStore interface {
+	KeyStore2
+	// create a wallet from a BIP39 mnemonic and its optional passphrase,
+	// storing the seed encrypted with auth and deriving the first account
+	NewHDWallet(mnemonic, passphrase, auth string) (*HDWallet, error)
+	// derive the next account of the wallet holding the given address
+	DeriveHDAccount(walletAddr []byte, auth string) ([]byte, error)
+	// re-encrypt the seed of the wallet holding the given address
+	UpdateHDWallet(walletAddr []byte, oldAuth, newAuth string) error
+	GetHDWallets() ([]*HDWallet, error)
+}
+
+func (ks keyStorePassphrase) NewHDWallet(mnemonic, passphrase, auth string) (*HDWallet, error) {
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	seed := MnemonicToSeed(mnemonic, passphrase)
+	defer zeroBytes(seed)
+
+	w := &encryptedHDWalletJSON{Id: uuid.NewRandom(), Path: DefaultBasePath}
+	key, err := deriveHDKey(seed, w.Path.Child(0))
+	if err != nil {
+		return nil, err
+	}
+	w.Addresses = [][]byte{key.Address}
+	if err := ks.encryptHDSeed(w, seed, auth); err != nil {
+		return nil, err
+	}
+	if err := ks.writeHDWallet(w); err != nil {
+		return nil, err
+	}
+	return w.wallet(), nil
+}
+
+func (ks keyStorePassphrase) DeriveHDAccount(walletAddr []byte, auth string) ([]byte, error) {
+	w, _, err := ks.findHDWallet(walletAddr)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := ks.decryptHDSeed(w, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	key, err := deriveHDKey(seed, w.Path.Child(uint32(len(w.Addresses))))
+	if err != nil {
+		return nil, err
+	}
+	w.Addresses = append(w.Addresses, key.Address)
+	if w.Version != keyVersion {
+		if err := ks.encryptHDSeed(w, seed, auth); err != nil {
+			return nil, err
+		}
+	}
+	if err := ks.writeHDWallet(w); err != nil {
+		return nil, err
+	}
+	return key.Address, nil
+}
+
+func (ks keyStorePassphrase) UpdateHDWallet(walletAddr []byte, oldAuth, newAuth string) error {
+	w, _, err := ks.findHDWallet(walletAddr)
+	if err != nil {
+		return err
+	}
+	seed, err := ks.decryptHDSeed(w, oldAuth)
+	if err != nil {
+		return err
+	}
+	defer zeroBytes(seed)
+
+	if err := ks.encryptHDSeed(w, seed, newAuth); err != nil {
+		return err
+	}
+	return ks.writeHDWallet(w)
+}
+
+func (ks keyStorePassphrase) GetHDWallets() ([]*HDWallet, error) {
+	ws, err := ks.readHDWallets()
+	if err != nil {
+		return nil, err
+	}
+	wallets := make([]*HDWallet, len(ws))
+	for i, w := range ws {
+		wallets[i] = w.wallet()
+	}
+	return wallets, nil
+}
+
+// getHDKey returns the key of an account derived from an HD wallet.
+func (ks keyStorePassphrase) getHDKey(keyAddr []byte, auth string) (*Key, error) {
+	w, index, err := ks.findHDWallet(keyAddr)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := ks.decryptHDSeed(w, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	// migrate wallets of previous versions now that the passphrase is known
+	if w.Version != keyVersion {
+		if err := ks.encryptHDSeed(w, seed, auth); err != nil {
+			return nil, err
+		}
+		if err := ks.writeHDWallet(w); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := deriveHDKey(seed, w.Path.Child(uint32(index)))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(key.Address, keyAddr) {
+		return nil, fmt.Errorf("HD wallet %x derived address %x instead of %x", w.Id, key.Address, keyAddr)
+	}
+	key.Id = uuid.UUID(w.Id)
+	return key, nil
+}
+
+// findHDWallet returns the wallet holding the address and the index of the
+// address within the wallet.
+func (ks keyStorePassphrase) findHDWallet(addr []byte) (*encryptedHDWalletJSON, int, error) {
+	ws, err := ks.readHDWallets()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, w := range ws {
+		for i, a := range w.Addresses {
+			if bytes.Equal(a, addr) {
+				return w, i, nil
+			}
+		}
+	}
+	return nil, 0, ErrNoHDWallet
+}
+
+// encryptHDSeed encrypts the seed of the wallet with the current version of
+// the key encryption.
+func (ks keyStorePassphrase) encryptHDSeed(w *encryptedHDWalletJSON, seed []byte, auth string) error {
+	c, err := ks.encryptSecret(seed, auth)
+	if err != nil {
+		return err
+	}
+	if w.Crypto, err = json.Marshal(c); err != nil {
+		return err
+	}
+	w.Version = keyVersion
+	return nil
+}
+
+func (ks keyStorePassphrase) decryptHDSeed(w *encryptedHDWalletJSON, auth string) ([]byte, error) {
+	switch w.Version {
+	case keyVersion:
+		var c cryptoJSON
+		if err := json.Unmarshal(w.Crypto, &c); err != nil {
+			return nil, err
+		}
+		return decryptSecret(c, auth)
+	case 0:
+		var c cipherJSONV1
+		if err := json.Unmarshal(w.Crypto, &c); err != nil {
+			return nil, err
+		}
+		return decryptSecretV1(c, auth)
+	}
+	return nil, fmt.Errorf("unsupported HD wallet version %d", w.Version)
+}
+
+func (ks keyStorePassphrase) hdWalletDir() string {
+	return path.Join(ks.keysDirPath, "hd")
+}
+
+func (ks keyStorePassphrase) readHDWallets() ([]*encryptedHDWalletJSON, error) {
+	fileInfos, err := ioutil.ReadDir(ks.hdWalletDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var wallets []*encryptedHDWalletJSON
+	for _, fileInfo := range fileInfos {
+		if uuid.Parse(fileInfo.Name()) == nil {
+			continue // skip temporary files
+		}
+		content, err := ioutil.ReadFile(path.Join(ks.hdWalletDir(), fileInfo.Name()))
+		if err != nil {
+			return nil, err
+		}
+		w := new(encryptedHDWalletJSON)
+		if err := json.Unmarshal(content, w); err != nil {
+			return nil, fmt.Errorf("HD wallet %s: %v", fileInfo.Name(), err)
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, nil
+}
+
+// writeHDWallet replaces the wallet file by renaming a new file over it, so
+// the wallet is never left half written.
+func (ks keyStorePassphrase) writeHDWallet(w *encryptedHDWalletJSON) error {
+	content, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ks.hdWalletDir(), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path.Join(ks.hdWalletDir(), uuid.UUID(w.Id).String()), content)
+}
+
+func (w *encryptedHDWalletJSON) wallet() *HDWallet {
+	return &HDWallet{Id: uuid.UUID(w.Id), Path: w.Path, Addresses: w.Addresses}
+}
+
+// deriveHDKey derives the key at path from a BIP32 seed.
+func deriveHDKey(seed []byte, path DerivationPath) (*Key, error) {
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	defer master.Zero()
+
+	ext, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	defer ext.Zero()
+
+	privateKey := ext.ECDSA()
+	return &Key{
+		Address:    PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}, nil
+}
+
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -26,38 +26,44 @@
 This key store behaves as KeyStorePlain with the difference that
 the private key is encrypted and on disk uses another JSON encoding.
 
+The encoding is version 3 of the Web3 Secret Storage format [1], which is
+shared with the other Ethereum clients and their tools.
+
 Cryptography:
 
-1. Encryption key is scrypt derived key from user passphrase. Scrypt parameters
-   (work factors) [1][2] are defined as constants below.
-2. Scrypt salt is 32 random bytes from CSPRNG. It is appended to ciphertext.
-3. Checksum is SHA3 of the private key bytes.
-4. Plaintext is concatenation of private key bytes and checksum.
-5. Encryption algo is AES 256 CBC [3][4]
-6. CBC IV is 16 random bytes from CSPRNG. It is appended to ciphertext.
-7. Plaintext padding is PKCS #7 [5][6]
+1. Encryption key is derived from the user passphrase by a KDF, scrypt [2] by
+   default or PBKDF2-HMAC-SHA256 [3]. The KDF name and parameters, including
+   the 32 random bytes of salt, are stored in the file.
+2. The first 16 bytes of the derived key are the key of AES-128 in CTR mode [4].
+   The CTR IV is 16 random bytes from CSPRNG, stored in the file.
+3. The MAC is the SHA3 of the second 16 bytes of the derived key followed by
+   the ciphertext. It is verified before decryption, so neither a wrong
+   passphrase nor a modified file yields a wrong key.
+4. Plaintext is the private key bytes.
 
 Encoding:
 
-1. On disk, ciphertext, salt and IV are encoded in a nested JSON object.
-   cat a key file to see the structure.
-2. byte arrays are base64 JSON strings.
-3. The EC private key bytes are in uncompressed form [7].
-   They are a big-endian byte slice of the absolute value of D [8][9].
-4. The checksum is the last 32 bytes of the plaintext byte array and the
-   private key is the preceeding bytes.
+1. On disk, the address, the key id and the ciphertext along with the cipher
+   and KDF parameters are encoded in a JSON object. cat a key file to see the
+   structure.
+2. byte arrays are hex JSON strings.
+3. The EC private key bytes are in uncompressed form [5].
+   They are a big-endian byte slice of the absolute value of D [6][7],
+   left padded to 32 bytes.
+
+Key files of the previous version (AES-256-CBC with a SHA3 checksum appended
+to the plaintext and fixed scrypt parameters) can still be decrypted. They are
+rewritten in the current version when they are unlocked.
 
 References:
 
-1. http://www.tarsnap.com/scrypt/scrypt-slides.pdf
-2. http://stackoverflow.com/questions/11126315/what-are-optimal-scrypt-work-factors
-3. http://en.wikipedia.org/wiki/Advanced_Encryption_Standard
-4. http://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher-block_chaining_.28CBC.29
-5. https://leanpub.com/gocrypto/read#leanpub-auto-block-cipher-modes
-6. http://tools.ietf.org/html/rfc2315
-7. http://bitcoin.stackexchange.com/questions/3059/what-is-a-compressed-bitcoin-key
-8. http://golang.org/pkg/crypto/ecdsa/#PrivateKey
-9. https://golang.org/pkg/math/big/#Int.Bytes
+1. https://github.com/ethereum/wiki/wiki/Web3-Secret-Storage-Definition
+2. http://www.tarsnap.com/scrypt/scrypt-slides.pdf
+3. https://tools.ietf.org/html/rfc2898#section-5.2
+4. http://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Counter_.28CTR.29
+5. http://bitcoin.stackexchange.com/questions/3059/what-is-a-compressed-bitcoin-key
+6. http://golang.org/pkg/crypto/ecdsa/#PrivateKey
+7. https://golang.org/pkg/math/big/#Int.Bytes
 
 */
 
@@ -67,32 +73,75 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
 
 	"code.google.com/p/go-uuid/uuid"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto/randentropy"
+	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
 )
 
 const (
-	// 2^18 / 8 / 1 uses 256MB memory and approx 1s CPU time on a modern CPU.
-	scryptN     = 1 << 18
-	scryptr     = 8
-	scryptp     = 1
-	scryptdkLen = 32
+	keyVersion = 3
+
+	// StandardScryptN and StandardScryptP, with r = 8, use 256MB memory and
+	// approx 1s CPU time on a modern CPU.
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+
+	// LightScryptN and LightScryptP, with r = 8, use 4MB memory and approx
+	// 100ms CPU time on a modern CPU. They are meant for tests.
+	LightScryptN = 1 << 12
+	LightScryptP = 6
+
+	// StandardPBKDF2C is the iteration count of PBKDF2 key files, as used by
+	// the other clients.
+	StandardPBKDF2C = 1 << 18
+
+	scryptR     = 8
+	scryptDKLen = 32
+
+	// parameters of key files of the previous version
+	scryptNV1 = 1 << 18
+	scryptPV1 = 1
+
+	// limits of the KDF parameters read from key files, so that a crafted
+	// file cannot make unlocking take unbounded memory or time
+	maxKDFDKLen = 64
+	maxScryptNR = 1 << 23 // n * r, 1GB of memory
+	maxScryptP  = 16
+	maxPBKDF2C  = 1 << 22
 )
 
+var ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
 type keyStorePassphrase struct {
 	keysDirPath string
+	scryptN     int
+	scryptP     int
+	pbkdf2C     int // PBKDF2 iterations, keys are encrypted with scrypt if 0
+}
+
+// NewKeyStorePassphrase creates a key store encrypting keys with a scrypt
+// key derivation of the given work factors, usually StandardScryptN and
+// StandardScryptP.
+func NewKeyStorePassphrase(path string, scryptN, scryptP int) KeyStore2 {
+	return &keyStorePassphrase{keysDirPath: path, scryptN: scryptN, scryptP: scryptP}
 }
 
-func NewKeyStorePassphrase(path string) KeyStore2 {
-	return &keyStorePassphrase{path}
+// NewKeyStorePassphrasePBKDF2 creates a key store encrypting keys with a
+// PBKDF2-HMAC-SHA256 key derivation of c iterations, usually
+// StandardPBKDF2C. Key files encrypted with scrypt can still be read.
+func NewKeyStorePassphrasePBKDF2(path string, c int) KeyStore2 {
+	return &keyStorePassphrase{keysDirPath: path, pbkdf2C: c}
 }
 
 func (ks keyStorePassphrase) GenerateNewKey(rand io.Reader, auth string) (key *Key, err error) {
@@ -100,7 +149,12 @@ func (ks keyStorePassphrase) GenerateNewKey(rand io.Reader, auth string) (key *K
 }
 
 func (ks keyStorePassphrase) GetKey(keyAddr []byte, auth string) (key *Key, err error) {
-	keyBytes, keyId, err := DecryptKey(ks, keyAddr, auth)
+	keyBytes, keyId, version, err := decryptKey(ks, keyAddr, auth)
+	if os.IsNotExist(err) {
+		if key, hdErr := ks.getHDKey(keyAddr, auth); hdErr != ErrNoHDWallet {
+			return key, hdErr
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -109,44 +163,42 @@ func (ks keyStorePassphrase) GetKey(keyAddr []byte, auth string) (key *Key, err
 		Address:    keyAddr,
 		PrivateKey: ToECDSA(keyBytes),
 	}
-	return key, err
+	if !bytes.Equal(PubkeyToAddress(key.PrivateKey.PublicKey), keyAddr) {
+		return nil, fmt.Errorf("key content mismatch: have address %x, want %x", PubkeyToAddress(key.PrivateKey.PublicKey), keyAddr)
+	}
+	// migrate keys of previous versions now that the passphrase is known
+	if version != keyVersion {
+		if err := ks.StoreKey(key, auth); err != nil {
+			return nil, fmt.Errorf("could not migrate key to version %d: %v", keyVersion, err)
+		}
+	}
+	return key, nil
 }
 
 func (ks keyStorePassphrase) GetKeyAddresses() (addresses [][]byte, err error) {
-	return GetKeyAddresses(ks.keysDirPath)
+	if addresses, err = GetKeyAddresses(ks.keysDirPath); err != nil {
+		return nil, err
+	}
+	wallets, err := ks.readHDWallets()
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range wallets {
+		addresses = append(addresses, w.Addresses...)
+	}
+	return addresses, nil
 }
 
 func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
-	authArray := []byte(auth)
-	salt := randentropy.GetEntropyMixed(32)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
+	cryptoStruct, err := ks.encryptSecret(common.LeftPadBytes(FromECDSA(key.PrivateKey), 32), auth)
 	if err != nil {
 		return err
 	}
-
-	keyBytes := FromECDSA(key.PrivateKey)
-	keyBytesHash := Sha3(keyBytes)
-	toEncrypt := PKCS7Pad(append(keyBytes, keyBytesHash...))
-
-	AES256Block, err := aes.NewCipher(derivedKey)
-	if err != nil {
-		return err
-	}
-
-	iv := randentropy.GetEntropyMixed(aes.BlockSize) // 16
-	AES256CBCEncrypter := cipher.NewCBCEncrypter(AES256Block, iv)
-	cipherText := make([]byte, len(toEncrypt))
-	AES256CBCEncrypter.CryptBlocks(cipherText, toEncrypt)
-
-	cipherStruct := cipherJSON{
-		salt,
-		iv,
-		cipherText,
-	}
-	keyStruct := encryptedKeyJSON{
-		key.Id,
-		key.Address,
-		cipherStruct,
+	keyStruct := encryptedKeyJSONV3{
+		hex.EncodeToString(key.Address),
+		cryptoStruct,
+		key.Id.String(),
+		keyVersion,
 	}
 	keyJSON, err := json.Marshal(keyStruct)
 	if err != nil {
@@ -158,7 +210,7 @@ func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
 
 func (ks keyStorePassphrase) DeleteKey(keyAddr []byte, auth string) (err error) {
 	// only delete if correct passphrase is given
-	_, _, err = DecryptKey(ks, keyAddr, auth)
+	_, _, _, err = decryptKey(ks, keyAddr, auth)
 	if err != nil {
 		return err
 	}
@@ -167,34 +219,201 @@ func (ks keyStorePassphrase) DeleteKey(keyAddr []byte, auth string) (err error)
 	return os.RemoveAll(keyDirPath)
 }
 
-func DecryptKey(ks keyStorePassphrase, keyAddr []byte, auth string) (keyBytes []byte, keyId []byte, err error) {
+// decryptKey decrypts the key file of the address, returning the private key
+// bytes, the key id and the version of the file.
+func decryptKey(ks keyStorePassphrase, keyAddr []byte, auth string) (keyBytes []byte, keyId []byte, version int, err error) {
 	fileContent, err := GetKeyFile(ks.keysDirPath, keyAddr)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
+	}
+	// files of the first version have no version field
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(fileContent, &m); err != nil {
+		return nil, nil, 0, err
+	}
+	if v, ok := m["version"].(float64); ok {
+		version = int(v)
+	}
+
+	switch version {
+	case keyVersion:
+		k := new(encryptedKeyJSONV3)
+		if err := json.Unmarshal(fileContent, k); err != nil {
+			return nil, nil, 0, err
+		}
+		if keyId = uuid.Parse(k.Id); keyId == nil {
+			return nil, nil, 0, fmt.Errorf("invalid key id %q", k.Id)
+		}
+		keyBytes, err = decryptSecret(k.Crypto, auth)
+	case 0:
+		k := new(encryptedKeyJSONV1)
+		if err := json.Unmarshal(fileContent, k); err != nil {
+			return nil, nil, 0, err
+		}
+		keyId = k.Id
+		keyBytes, err = decryptSecretV1(k.Crypto, auth)
+	default:
+		return nil, nil, 0, fmt.Errorf("unsupported key version %d", version)
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return keyBytes, keyId, version, nil
+}
+
+// encryptSecret encrypts data with a key derived from the passphrase, as
+// described at the top of this file.
+func (ks keyStorePassphrase) encryptSecret(data []byte, auth string) (cryptoJSON, error) {
+	salt := randentropy.GetEntropyMixed(32)
+	kdf, kdfParams := "scrypt", map[string]interface{}{
+		"n":     ks.scryptN,
+		"r":     scryptR,
+		"p":     ks.scryptP,
+		"dklen": scryptDKLen,
+		"salt":  hex.EncodeToString(salt),
+	}
+	if ks.pbkdf2C > 0 {
+		kdf, kdfParams = "pbkdf2", map[string]interface{}{
+			"c":     ks.pbkdf2C,
+			"prf":   "hmac-sha256",
+			"dklen": scryptDKLen,
+			"salt":  hex.EncodeToString(salt),
+		}
+	}
+	derivedKey, err := kdfKey(cryptoJSON{KDF: kdf, KDFParams: kdfParams}, auth)
+	if err != nil {
+		return cryptoJSON{}, err
+	}
+
+	iv := randentropy.GetEntropyMixed(aes.BlockSize) // 16
+	cipherText, err := aesCTRXOR(derivedKey[:16], data, iv)
+	if err != nil {
+		return cryptoJSON{}, err
 	}
+	mac := Sha3(derivedKey[16:32], cipherText)
+
+	return cryptoJSON{
+		Cipher:       "aes-128-ctr",
+		CipherText:   hex.EncodeToString(cipherText),
+		CipherParams: cipherparamsJSON{IV: hex.EncodeToString(iv)},
+		KDF:          kdf,
+		KDFParams:    kdfParams,
+		MAC:          hex.EncodeToString(mac),
+	}, nil
+}
 
-	keyProtected := new(encryptedKeyJSON)
-	err = json.Unmarshal(fileContent, keyProtected)
+// decryptSecret reverses encryptSecret, verifying the MAC before decrypting.
+func decryptSecret(c cryptoJSON, auth string) ([]byte, error) {
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("cipher not supported: %v", c.Cipher)
+	}
+	mac, err := hex.DecodeString(c.MAC)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(c.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	// the MAC does not cover the IV, a wrong length must not reach AES
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid IV length %d", len(iv))
+	}
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	derivedKey, err := kdfKey(c, auth)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(Sha3(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrDecrypt
+	}
+	return aesCTRXOR(derivedKey[:16], cipherText, iv)
+}
 
-	keyId = keyProtected.Id
-	salt := keyProtected.Crypto.Salt
-	iv := keyProtected.Crypto.IV
-	cipherText := keyProtected.Crypto.CipherText
+// kdfKey derives the encryption key of the passphrase with the KDF and the
+// parameters of the encrypted data.
+func kdfKey(c cryptoJSON, auth string) ([]byte, error) {
+	salt, err := hex.DecodeString(kdfString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, err
+	}
+	dkLen := kdfInt(c.KDFParams, "dklen")
+	if dkLen < 32 || dkLen > maxKDFDKLen {
+		return nil, fmt.Errorf("invalid derived key length %d", dkLen)
+	}
+
+	switch c.KDF {
+	case "scrypt":
+		n, r, p := kdfInt(c.KDFParams, "n"), kdfInt(c.KDFParams, "r"), kdfInt(c.KDFParams, "p")
+		if n <= 0 || r <= 0 || n > maxScryptNR/r || p <= 0 || p > maxScryptP {
+			return nil, fmt.Errorf("scrypt parameters out of range: n %d, r %d, p %d", n, r, p)
+		}
+		return scrypt.Key([]byte(auth), salt, n, r, p, dkLen)
+	case "pbkdf2":
+		if prf := kdfString(c.KDFParams, "prf"); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("PBKDF2 PRF not supported: %v", prf)
+		}
+		iter := kdfInt(c.KDFParams, "c")
+		if iter <= 0 || iter > maxPBKDF2C {
+			return nil, fmt.Errorf("invalid PBKDF2 iteration count %d", iter)
+		}
+		return pbkdf2.Key([]byte(auth), salt, iter, dkLen, sha256.New), nil
+	}
+	return nil, fmt.Errorf("KDF not supported: %v", c.KDF)
+}
 
-	authArray := []byte(auth)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
+// kdfInt returns a numeric KDF parameter, or 0 if it's missing or doesn't
+// fit 32 bits. JSON numbers are decoded as float64.
+func kdfInt(params map[string]interface{}, name string) int {
+	switch v := params[name].(type) {
+	case float64:
+		if v < -(1<<31) || v >= 1<<31 {
+			return 0
+		}
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func kdfString(params map[string]interface{}, name string) string {
+	s, _ := params[name].(string)
+	return s
+}
+
+// decryptSecretV1 decrypts data encrypted by the previous version of the key
+// store, AES-256-CBC with the SHA3 checksum of the data appended to it.
+func decryptSecretV1(c cipherJSONV1, auth string) ([]byte, error) {
+	derivedKey, err := scrypt.Key([]byte(auth), c.Salt, scryptNV1, scryptR, scryptPV1, scryptDKLen)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	plainText, err := aesCBCDecrypt(derivedKey, cipherText, iv)
+	plainText, err := aesCBCDecrypt(derivedKey, c.CipherText, c.IV)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if len(plainText) < 32 {
+		return nil, errors.New("Decryption failed: plaintext too short")
+	}
+	data := plainText[:len(plainText)-32]
+	dataHash := plainText[len(plainText)-32:]
+	if !bytes.Equal(Sha3(data), dataHash) {
+		return nil, errors.New("Decryption failed: checksum mismatch")
 	}
-	keyBytes = plainText[:len(plainText)-32]
-	keyBytesHash := plainText[len(plainText)-32:]
-	if !bytes.Equal(Sha3(keyBytes), keyBytesHash) {
-		err = errors.New("Decryption failed: checksum mismatch")
-		return nil, nil, err
+	return data, nil
+}
+
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
-	return keyBytes, keyId, err
+	stream := cipher.NewCTR(aesBlock, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
 }
@@ -109,7 +109,8 @@ func WriteKeyFile(addr []byte, keysDirPath string, content []byte) (err error) {
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(keyFilePath, content, 0600) // read, write for user
+	// write a new file first so a key being replaced is never lost
+	return writeFileAtomic(keyFilePath, content)
 }
 
 func GetKeyAddresses(keysDirPath string) (addresses [][]byte, err error) {
@@ -117,13 +118,12 @@ func GetKeyAddresses(keysDirPath string) (addresses [][]byte, err error) {
 	if err != nil {
 		return nil, err
 	}
-	addresses = make([][]byte, len(fileInfos))
-	for i, fileInfo := range fileInfos {
+	for _, fileInfo := range fileInfos {
 		address, err := hex.DecodeString(fileInfo.Name())
 		if err != nil {
 			continue
 		}
-		addresses[i] = address
+		addresses = append(addresses, address)
 	}
-	return addresses, err
+	return addresses, nil
 }
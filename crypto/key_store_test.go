@@ -1,10 +1,17 @@
 package crypto
 
 import (
-	"github.com/ethereum/go-ethereum/crypto/randentropy"
-	"github.com/ethereum/go-ethereum/common"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/randentropy"
 )
 
 func TestKeyStorePlain(t *testing.T) {
@@ -36,7 +43,7 @@ func TestKeyStorePlain(t *testing.T) {
 }
 
 func TestKeyStorePassphrase(t *testing.T) {
-	ks := NewKeyStorePassphrase(common.DefaultDataDir())
+	ks := NewKeyStorePassphrase(common.DefaultDataDir(), LightScryptN, LightScryptP)
 	pass := "foo"
 	k1, err := ks.GenerateNewKey(randentropy.Reader, pass)
 	if err != nil {
@@ -62,7 +69,7 @@ func TestKeyStorePassphrase(t *testing.T) {
 }
 
 func TestKeyStorePassphraseDecryptionFail(t *testing.T) {
-	ks := NewKeyStorePassphrase(common.DefaultDataDir())
+	ks := NewKeyStorePassphrase(common.DefaultDataDir(), LightScryptN, LightScryptP)
 	pass := "foo"
 	k1, err := ks.GenerateNewKey(randentropy.Reader, pass)
 	if err != nil {
@@ -90,10 +97,365 @@ func TestImportPreSaleKey(t *testing.T) {
 	// python pyethsaletool.py genwallet
 	// with password "foo"
 	fileContent := "{\"encseed\": \"26d87f5f2bf9835f9a47eefae571bc09f9107bb13d54ff12a4ec095d01f83897494cf34f7bed2ed34126ecba9db7b62de56c9d7cd136520a0427bfb11b8954ba7ac39b90d4650d3448e31185affcd74226a68f1e94b1108e6e0a4a91cdd83eba\", \"ethaddr\": \"d4584b5f6229b7be90727b0fc8c6b91bb427821f\", \"email\": \"gustav.simonsson@gmail.com\", \"btcaddr\": \"1EVknXyFC68kKNLkh6YnKzW41svSRoaAcx\"}"
-	ks := NewKeyStorePassphrase(common.DefaultDataDir())
+	ks := NewKeyStorePassphrase(common.DefaultDataDir(), LightScryptN, LightScryptP)
 	pass := "foo"
 	_, err := ImportPreSaleKey(ks, []byte(fileContent), pass)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
+
+func TestKeyStorePassphraseHDWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-hd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePassphrase(dir, LightScryptN, LightScryptP).(HDKeyStore)
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	pass := "foo"
+	wallet, err := ks.NewHDWallet(mnemonic, "", pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := common.FromHex("9858effd232b4033e47d90003d41ec34ecaeda94")
+	if len(wallet.Addresses) != 1 || !bytes.Equal(wallet.Addresses[0], first) {
+		t.Fatalf("wrong first account: %x", wallet.Addresses)
+	}
+
+	// derived accounts are listed and unlocked like stored keys
+	second, err := ks.DeriveHDAccount(first, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := ks.GetKeyAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, [][]byte{first, second}) {
+		t.Fatalf("address list mismatch: have %x, want %x", addrs, [][]byte{first, second})
+	}
+	key, err := ks.GetKey(second, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(PubkeyToAddress(key.PrivateKey.PublicKey), second) {
+		t.Errorf("key does not match address %x", second)
+	}
+	if _, err := ks.GetKey(first, "bar"); err == nil {
+		t.Error("no error for wrong passphrase")
+	}
+
+	// the seed is only stored encrypted
+	wallets, err := ks.GetHDWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wallets) != 1 || !reflect.DeepEqual(wallets[0].Addresses, addrs) || wallets[0].Path.String() != "m/44'/60'/0'/0" {
+		t.Fatalf("wallet mismatch: %+v", wallets)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "hd", wallets[0].Id.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := MnemonicToSeed(mnemonic, "")
+	if bytes.Contains(content, seed) || bytes.Contains(content, []byte(hex.EncodeToString(seed))) {
+		t.Error("wallet file contains the plain seed")
+	}
+}
+
+// Test vectors of the Web3 Secret Storage Definition, encrypting the same
+// key with the passphrase "testpassword".
+var v3Vectors = []string{
+	`{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf": "pbkdf2",
+			"kdfparams": {"c": 262144, "dklen": 32, "prf": "hmac-sha256", "salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},
+			"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`,
+	`{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "83dbcc02d8ccb40e466191a123791e0e"},
+			"ciphertext": "d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c",
+			"kdf": "scrypt",
+			"kdfparams": {"dklen": 32, "n": 262144, "r": 1, "p": 8, "salt": "ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"},
+			"mac": "2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`,
+}
+
+func TestV3Vectors(t *testing.T) {
+	want := "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+	for i, vector := range v3Vectors {
+		k := new(encryptedKeyJSONV3)
+		if err := json.Unmarshal([]byte(vector), k); err != nil {
+			t.Fatalf("vector %d: %v", i, err)
+		}
+		keyBytes, err := decryptSecret(k.Crypto, "testpassword")
+		if err != nil {
+			t.Errorf("vector %d: decryption error: %v", i, err)
+		} else if hex.EncodeToString(keyBytes) != want {
+			t.Errorf("vector %d: key mismatch: have %x, want %s", i, keyBytes, want)
+		}
+		if _, err := decryptSecret(k.Crypto, "wrongpassword"); err != ErrDecrypt {
+			t.Errorf("vector %d: error mismatch for wrong passphrase: have %v, want %v", i, err, ErrDecrypt)
+		}
+	}
+}
+
+func TestKeyStorePassphraseV3(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-v3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePassphrase(dir, LightScryptN, LightScryptP)
+	k1, err := ks.GenerateNewKey(randentropy.Reader, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := GetKeyFile(dir, k1.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := new(encryptedKeyJSONV3)
+	if err := json.Unmarshal(content, k); err != nil {
+		t.Fatal(err)
+	}
+	if k.Version != 3 || k.Crypto.Cipher != "aes-128-ctr" || k.Crypto.KDF != "scrypt" || kdfInt(k.Crypto.KDFParams, "n") != LightScryptN {
+		t.Fatalf("unexpected key file: %s", content)
+	}
+	if k.Address != hex.EncodeToString(k1.Address) || k.Id != k1.Id.String() {
+		t.Errorf("address or id mismatch in key file: %s", content)
+	}
+	if _, err := ks.GetKey(k1.Address, "bar"); err != ErrDecrypt {
+		t.Errorf("error mismatch for wrong passphrase: have %v, want %v", err, ErrDecrypt)
+	}
+
+	// a modified ciphertext fails the MAC check
+	cipherText, _ := hex.DecodeString(k.Crypto.CipherText)
+	cipherText[0] ^= 1
+	k.Crypto.CipherText = hex.EncodeToString(cipherText)
+	content, _ = json.Marshal(k)
+	if err := WriteKeyFile(k1.Address, dir, content); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.GetKey(k1.Address, "foo"); err != ErrDecrypt {
+		t.Errorf("error mismatch for modified key file: have %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestKeyStorePassphrasePBKDF2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-pbkdf2-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePassphrasePBKDF2(dir, 1024)
+	k1, err := ks.GenerateNewKey(randentropy.Reader, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := GetKeyFile(dir, k1.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := new(encryptedKeyJSONV3)
+	if err := json.Unmarshal(content, k); err != nil {
+		t.Fatal(err)
+	}
+	if k.Crypto.KDF != "pbkdf2" || kdfInt(k.Crypto.KDFParams, "c") != 1024 || kdfString(k.Crypto.KDFParams, "prf") != "hmac-sha256" {
+		t.Fatalf("unexpected key file: %s", content)
+	}
+
+	// the KDF is read from the file, any passphrase key store decrypts it
+	rs := NewKeyStorePassphrase(dir, LightScryptN, LightScryptP)
+	k2, err := rs.GetKey(k1.Address, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(k1.PrivateKey, k2.PrivateKey) {
+		t.Errorf("key mismatch after decryption")
+	}
+	if _, err := rs.GetKey(k1.Address, "bar"); err != ErrDecrypt {
+		t.Errorf("error mismatch for wrong passphrase: have %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestKeyStorePassphraseInvalidParams(t *testing.T) {
+	tests := []struct {
+		vector int
+		name   string
+		value  interface{}
+	}{
+		// wrong IV lengths, which the MAC doesn't cover
+		{1, "iv", "83dbcc02d8ccb40e466191a123791e"},
+		{1, "iv", "83dbcc02d8ccb40e466191a123791e0e00"},
+		// KDF parameters too costly to try
+		{1, "dklen", float64(1 << 30)},
+		{1, "n", float64(1 << 30)},
+		{1, "r", float64(1e20)},
+		{1, "p", float64(1 << 20)},
+		{0, "c", float64(1 << 30)},
+	}
+	for i, tt := range tests {
+		k := new(encryptedKeyJSONV3)
+		if err := json.Unmarshal([]byte(v3Vectors[tt.vector]), k); err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if tt.name == "iv" {
+			k.Crypto.CipherParams.IV = tt.value.(string)
+		} else {
+			k.Crypto.KDFParams[tt.name] = tt.value
+		}
+		if _, err := decryptSecret(k.Crypto, "testpassword"); err == nil || err == ErrDecrypt {
+			t.Errorf("test %d: %s %v: error mismatch: have %v, want invalid parameter", i, tt.name, tt.value, err)
+		}
+	}
+}
+
+// Key and HD wallet files of the previous key store version, encrypted with
+// the passphrase "foo".
+const (
+	v1KeyFile    = `{"Id":"0zuihAvKQFSgLJUW38b4dw==","Address":"AIru2k2AVHHfmypbDzigw7y6eGs=","Crypto":{"Salt":"AAcOFRwjKjE4P0ZNVFtiaXB3foWMk5qhqK+2vcTL0tk=","IV":"AA0aJzRBTltodYKPnKm2ww==","CipherText":"IzWQuWX/j0TAqmTvV7l99glNfqlrif6IyvtaUPiVezCBcdBy/jfQKMySnLXmyjyBxyttwWhVXdXUIjJhHTI8XcoI90RImJcjLhEYJ4Mm7zE="}}`
+	v1WalletFile = `{"Id":"0zuihAvKQFSgLJUW38b4dw==","Path":"m/44'/60'/0'/0","Addresses":["mFjv/SMrQDPkfZAAPUHsNOyu2pQ="],"Crypto":{"Salt":"AAcOFRwjKjE4P0ZNVFtiaXB3foWMk5qhqK+2vcTL0tk=","IV":"AA0aJzRBTltodYKPnKm2ww==","CipherText":"5ZDDwM0CUnm6IIXFdzx15js7Mt2tlrzbneluFCYGlOXGMulZYXbZ9svKsY+YeBdfiVm7UwY0KicqXXJZOq4acXsXJneql0+fI0Ev8Ia61KasT7NTHWplzgm1u4dLTrcrja1ez4fmV7dZU4IzUdfPtQ=="}}`
+)
+
+func TestKeyStorePassphraseMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-migration-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyAddr := common.FromHex("008aeeda4d805471df9b2a5b0f38a0c3bcba786b")
+	if err := WriteKeyFile(keyAddr, dir, []byte(v1KeyFile)); err != nil {
+		t.Fatal(err)
+	}
+	walletAddr := common.FromHex("9858effd232b4033e47d90003d41ec34ecaeda94")
+	walletFile := filepath.Join(dir, "hd", "d33ba284-0bca-4054-a02c-9516dfc6f877")
+	if err := os.MkdirAll(filepath.Dir(walletFile), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(walletFile, []byte(v1WalletFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks := NewKeyStorePassphrase(dir, LightScryptN, LightScryptP)
+	for _, addr := range [][]byte{keyAddr, walletAddr} {
+		if _, err := ks.GetKey(addr, "bar"); err == nil {
+			t.Fatalf("%x: no error for wrong passphrase", addr)
+		}
+		// unlocking rewrites the file, the key must be the same afterwards
+		k1, err := ks.GetKey(addr, "foo")
+		if err != nil {
+			t.Fatalf("%x: %v", addr, err)
+		}
+		k2, err := ks.GetKey(addr, "foo")
+		if err != nil {
+			t.Fatalf("%x: %v", addr, err)
+		}
+		if !reflect.DeepEqual(k1, k2) || !bytes.Equal(PubkeyToAddress(k2.PrivateKey.PublicKey), addr) {
+			t.Errorf("%x: key changed by migration", addr)
+		}
+	}
+
+	content, err := GetKeyFile(dir, keyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version struct{ Version int }
+	if json.Unmarshal(content, &version); version.Version != 3 {
+		t.Errorf("key file not migrated: %s", content)
+	}
+	if content, err = ioutil.ReadFile(walletFile); err != nil {
+		t.Fatal(err)
+	}
+	if json.Unmarshal(content, &version); version.Version != 3 {
+		t.Errorf("wallet file not migrated: %s", content)
+	}
+}
+
+func TestKeyBackupRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-backup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keysDir, backupDir, restoreDir := filepath.Join(dir, "keys"), filepath.Join(dir, "backup"), filepath.Join(dir, "restore")
+
+	ks := NewKeyStorePassphrase(keysDir, LightScryptN, LightScryptP).(HDKeyStore)
+	k1, err := ks.GenerateNewKey(randentropy.Reader, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := ks.NewHDWallet("legal winner thank year wave sausage worth useful legal winner thank yellow", "", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := BackupKeyFiles(keysDir, backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 2 {
+		t.Fatalf("manifest file count mismatch: have %d, want 2", len(manifest.Files))
+	}
+	if _, err := BackupKeyFiles(keysDir, backupDir); err == nil {
+		t.Error("existing backup was overwritten")
+	}
+
+	// the restored key store holds the same encrypted keys
+	restored, err := RestoreKeyFiles(backupDir, restoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("restored file count mismatch: have %d, want 2", len(restored))
+	}
+	rs := NewKeyStorePassphrase(restoreDir, LightScryptN, LightScryptP)
+	addrs, err := rs.GetKeyAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, [][]byte{k1.Address, wallet.Addresses[0]}) {
+		t.Errorf("restored addresses mismatch: have %x, want %x and %x", addrs, k1.Address, wallet.Addresses[0])
+	}
+	if _, err := rs.GetKey(k1.Address, "foo"); err != nil {
+		t.Errorf("could not unlock restored key: %v", err)
+	}
+	if _, err := rs.GetKey(wallet.Addresses[0], "bar"); err != nil {
+		t.Errorf("could not unlock restored wallet: %v", err)
+	}
+
+	// restoring again skips the present keys, unless they have changed
+	if restored, err = RestoreKeyFiles(backupDir, keysDir); err != nil || len(restored) != 0 {
+		t.Errorf("restore into the original key store: have %d files, err %v", len(restored), err)
+	}
+	if err := ks.StoreKey(k1, "baz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreKeyFiles(backupDir, keysDir); err == nil {
+		t.Error("no error for key file differing from the backup")
+	}
+
+	// modified backups are rejected
+	keyFile := filepath.Join(backupDir, manifest.Files[0].Path)
+	content, _ := ioutil.ReadFile(keyFile)
+	ioutil.WriteFile(keyFile, append(content, ' '), 0600)
+	if _, err := RestoreKeyFiles(backupDir, filepath.Join(dir, "restore2")); err == nil {
+		t.Error("no error for modified backup file")
+	}
+}
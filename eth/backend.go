@@ -4,29 +4,40 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/ethereum/ethash"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/blockpool"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/poa"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/pow"
 	"github.com/ethereum/go-ethereum/whisper"
 )
 
+const (
+	txBatchInterval = 100 * time.Millisecond // Time to collect transactions before broadcasting them
+	maxTxBatch      = 256                    // Maximum number of transactions broadcast in one batch
+)
+
 var (
 	jsonlogger = logger.NewJsonLogger()
 
@@ -45,6 +56,19 @@ type Config struct {
 
 	BlockChainVersion  int
 	SkipBcVersionCheck bool // e.g. blockchain export
+	FastSync           bool // fast sync an empty chain by downloading the state of a recent block
+	LightServ          bool // serve light nodes over the les protocol
+	LightMode          bool // run as a light node, keeping only a header chain
+
+	// ChainConfig holds the consensus rules of the chain, which are stored
+	// with its genesis block. If nil, the stored rules are used, or the rules
+	// of the main chain for a new chain.
+	ChainConfig *params.ChainConfig
+
+	// Engine is the consensus engine which verifies and seals blocks.
+	// If nil, blocks are sealed by ethash proof of work, or by the signers
+	// of ChainConfig if it has an authority section.
+	Engine consensus.Engine
 
 	DataDir  string
 	LogFile  string
@@ -131,11 +155,15 @@ type Ethereum struct {
 	txPool         *core.TxPool
 	chainManager   *core.ChainManager
 	blockPool      *blockpool.BlockPool
+	downloader     *downloader.Downloader
 	accountManager *accounts.Manager
 	whisper        *whisper.Whisper
+	lesServer      *les.Server
+	lightNode      *les.LightNode
 	pow            *ethash.Ethash
 
 	net      *p2p.Server
+	peers    *peerSet
 	eventMux *event.TypeMux
 	txSub    event.Subscription
 	blockSub event.Subscription
@@ -207,27 +235,59 @@ func New(config *Config) (*Ethereum, error) {
 		netVersionId:   config.NetworkId,
 	}
 
-	eth.chainManager = core.NewChainManager(blockDb, stateDb, eth.EventMux())
+	chainConfig, err := core.SetupChainConfig(blockDb, core.GenesisBlock(stateDb).Hash(), config.ChainConfig)
+	if err != nil {
+		return nil, err
+	}
+	eth.chainManager = core.NewChainManager(blockDb, stateDb, chainConfig, eth.EventMux())
 	eth.pow = ethash.New(eth.chainManager)
-	eth.txPool = core.NewTxPool(eth.EventMux(), eth.chainManager.State)
-	eth.blockProcessor = core.NewBlockProcessor(stateDb, extraDb, eth.pow, eth.txPool, eth.chainManager, eth.EventMux())
+	engine := config.Engine
+	if engine == nil && chainConfig.Authority != nil {
+		engine = poa.New(chainConfig.Authority)
+	}
+	verifyPoW := eth.pow.Verify
+	if engine == nil {
+		engine = consensus.NewProofOfWork(eth.pow)
+	} else {
+		// blocks without proof of work are verified on import only
+		verifyPoW = func(pow.Block) bool { return true }
+	}
+	eth.txPool = core.NewTxPool(eth.EventMux(), eth.chainManager.State, eth.chainManager.PendingRules)
+	eth.blockProcessor = core.NewBlockProcessor(stateDb, extraDb, engine, eth.txPool, eth.chainManager, eth.EventMux())
 	eth.chainManager.SetProcessor(eth.blockProcessor)
 	eth.whisper = whisper.New()
 	eth.shhVersionId = int(eth.whisper.Version())
-	eth.miner = miner.New(eth, eth.pow, config.MinerThreads)
+	eth.miner = miner.New(eth, eth.accountManager, config.MinerThreads)
 
 	hasBlock := eth.chainManager.HasBlock
 	insertChain := eth.chainManager.InsertChain
 	td := eth.chainManager.Td()
-	eth.blockPool = blockpool.New(hasBlock, insertChain, eth.pow.Verify, eth.EventMux(), td)
+	eth.blockPool = blockpool.New(hasBlock, insertChain, verifyPoW, eth.EventMux(), td)
+	syncMode := downloader.FullSync
+	if config.FastSync {
+		syncMode = downloader.FastSync
+	}
+	eth.downloader = downloader.New(syncMode, stateDb, hasBlock, eth.chainManager.GetHeader, eth.blockProcessor.ValidateHeader, insertChain, eth.chainManager.InsertReceiptChain, eth.chainManager.FastSyncCommitHead, eth.chainManager.Td)
 
 	netprv, err := config.nodeKey()
 	if err != nil {
 		return nil, err
 	}
 
-	ethProto := EthProtocol(config.ProtocolVersion, config.NetworkId, eth.txPool, eth.chainManager, eth.blockPool)
-	protocols := []p2p.Protocol{ethProto}
+	var protocols []p2p.Protocol
+	if config.LightMode {
+		// light nodes only keep a header chain, retrieving anything else on demand
+		eth.lightNode = les.NewLightNode(config.NetworkId, blockDb, eth.chainManager.Genesis(), eth.blockProcessor.ValidateHeader)
+		protocols = append(protocols, eth.lightNode.Protocol())
+	} else {
+		eth.peers = newPeerSet()
+		ethProto := EthProtocol(config.ProtocolVersion, config.NetworkId, eth.txPool, eth.chainManager, eth.blockPool, eth.downloader, eth.peers)
+		protocols = append(protocols, ethProto)
+		if config.LightServ {
+			eth.lesServer = les.NewServer(config.NetworkId, eth.chainManager, stateDb, eth.EventMux())
+			protocols = append(protocols, eth.lesServer.Protocol())
+		}
+	}
 	if config.Shh {
 		protocols = append(protocols, eth.whisper.Protocol())
 	}
@@ -241,6 +301,10 @@ func New(config *Config) (*Ethereum, error) {
 		NoDial:         !config.Dial,
 		BootstrapNodes: config.parseBootNodes(),
 	}
+	if config.LightMode {
+		// don't waste connections on nodes that can't serve us
+		eth.net.RequiredCaps = []p2p.Cap{{Name: "les", Version: les.ProtocolVersion}}
+	}
 	if len(config.Port) > 0 {
 		eth.net.ListenAddr = ":" + config.Port
 	}
@@ -310,7 +374,9 @@ func (s *Ethereum) PeersInfo() (peersinfo []*PeerInfo) {
 
 func (s *Ethereum) ResetWithGenesisBlock(gb *types.Block) {
 	s.chainManager.ResetWithGenesisBlock(gb)
-	s.pow.UpdateCache(0, true)
+	if engine, ok := s.blockProcessor.Engine().(*consensus.ProofOfWork); ok && engine.PoW() == pow.PoW(s.pow) {
+		s.pow.UpdateCache(0, true)
+	}
 }
 
 func (s *Ethereum) StartMining() error {
@@ -322,7 +388,10 @@ func (s *Ethereum) StartMining() error {
 
 	}
 
-	s.miner.Start(eb)
+	if err := s.miner.Start(eb); err != nil {
+		glog.V(logger.Error).Infoln(err)
+		return err
+	}
 	return nil
 }
 
@@ -350,7 +419,9 @@ func (s *Ethereum) ChainManager() *core.ChainManager     { return s.chainManager
 func (s *Ethereum) BlockProcessor() *core.BlockProcessor { return s.blockProcessor }
 func (s *Ethereum) TxPool() *core.TxPool                 { return s.txPool }
 func (s *Ethereum) BlockPool() *blockpool.BlockPool      { return s.blockPool }
+func (s *Ethereum) Downloader() *downloader.Downloader   { return s.downloader }
 func (s *Ethereum) Whisper() *whisper.Whisper            { return s.whisper }
+func (s *Ethereum) LightNode() *les.LightNode            { return s.lightNode }
 func (s *Ethereum) EventMux() *event.TypeMux             { return s.eventMux }
 func (s *Ethereum) BlockDb() common.Database             { return s.blockDb }
 func (s *Ethereum) StateDb() common.Database             { return s.stateDb }
@@ -385,6 +456,9 @@ func (s *Ethereum) Start() error {
 	if s.whisper != nil {
 		s.whisper.Start()
 	}
+	if s.lesServer != nil {
+		s.lesServer.Start()
+	}
 
 	// broadcast transactions
 	s.txSub = s.eventMux.Subscribe(core.TxPreEvent{})
@@ -430,9 +504,13 @@ func (s *Ethereum) Stop() {
 	s.txPool.Stop()
 	s.eventMux.Stop()
 	s.blockPool.Stop()
+	s.downloader.Stop()
 	if s.whisper != nil {
 		s.whisper.Stop()
 	}
+	if s.lesServer != nil {
+		s.lesServer.Stop()
+	}
 
 	glog.V(logger.Info).Infoln("Server stopped")
 	close(s.shutdownChan)
@@ -444,13 +522,43 @@ func (s *Ethereum) WaitForShutdown() {
 }
 
 // now tx broadcasting is taken out of txPool
-// handled here via subscription, efficiency?
+// handled here via subscription. transactions are collected for a short
+// while and sent in batches, each peer only receiving the ones it doesn't
+// know about yet
 func (self *Ethereum) txBroadcastLoop() {
-	// automatically stops if unsubscribe
-	for obj := range self.txSub.Chan() {
-		event := obj.(core.TxPreEvent)
-		self.net.BroadcastLimited("eth", TxMsg, math.Sqrt, []*types.Transaction{event.Tx})
-		self.syncAccounts(event.Tx)
+	ticker := time.NewTicker(txBatchInterval)
+	defer ticker.Stop()
+
+	var batch types.Transactions
+	for {
+		select {
+		case obj, ok := <-self.txSub.Chan():
+			// automatically stops if unsubscribe
+			if !ok {
+				return
+			}
+			event := obj.(core.TxPreEvent)
+			batch = append(batch, event.Tx)
+			self.syncAccounts(event.Tx)
+
+			if len(batch) < maxTxBatch {
+				break
+			}
+			self.broadcastTransactions(batch)
+			batch = nil
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				self.broadcastTransactions(batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+func (self *Ethereum) broadcastTransactions(txs types.Transactions) {
+	if self.peers != nil {
+		self.peers.broadcastTransactions(txs)
 	}
 }
 
@@ -473,7 +581,9 @@ func (self *Ethereum) blockBroadcastLoop() {
 	for obj := range self.blockSub.Chan() {
 		switch ev := obj.(type) {
 		case core.ChainHeadEvent:
-			self.net.BroadcastLimited("eth", NewBlockMsg, math.Sqrt, []interface{}{ev.Block, ev.Block.Td})
+			if self.peers != nil {
+				self.peers.broadcastBlock(ev.Block, ev.Block.Td)
+			}
 		}
 	}
 }
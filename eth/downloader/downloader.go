@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"errors"
 	"math"
 	"math/big"
 	"sync"
@@ -9,20 +10,48 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
-	"gopkg.in/fatih/set.v0"
 )
 
 const (
-	maxBlockFetch       = 256 // Amount of max blocks to be fetched per chunk
+	maxHeaderFetch      = 512 // Amount of max headers to be fetched per request
+	maxBlockFetch       = 128 // Amount of max block bodies to be fetched per chunk
+	maxReceiptFetch     = 256 // Amount of max block receipts to be fetched per chunk
+	maxStateFetch       = 384 // Amount of max state trie nodes to be fetched per chunk
 	minDesiredPeerCount = 3   // Amount of peers desired to start syncing
+	headerTTL           = 5 * time.Second
+
+	// fsMinFullBlocks is the number of blocks below the head of the remote
+	// chain that are still fully processed during fast sync. The block
+	// right below them is the pivot whose state is downloaded.
+	fsMinFullBlocks = 64
+)
+
+// SyncMode represents the synchronisation mode of the downloader.
+type SyncMode int
+
+const (
+	FullSync SyncMode = iota // Synchronise the entire chain by processing every block
+	FastSync                 // Download the state of a recent pivot block and process only the blocks after it
+)
+
+var (
+	errTimeout          = errors.New("timeout")
+	errEmptyHeaderChain = errors.New("peer delivered empty header set")
+	errBadHeaderChain   = errors.New("peer delivered non contiguous header chain")
+	errUnknownAncestor  = errors.New("header chain doesn't link to a known block")
+	errNoFastPeers      = errors.New("no peers able to serve state data")
 )
 
 type hashCheckFn func(common.Hash) bool
+type headerFetchFn func(common.Hash) *types.Header
+type headerCheckFn func(header, parent *types.Header) error
 type chainInsertFn func(types.Blocks) error
-type hashIterFn func() (common.Hash, error)
+type receiptChainInsertFn func(types.Blocks, []types.Receipts) error
+type headCommitFn func(common.Hash) error
 type currentTdFn func() *big.Int
 
 type Downloader struct {
@@ -30,47 +59,93 @@ type Downloader struct {
 	queue *queue
 	peers peers
 
+	mode    SyncMode        // Synchronisation mode to use on an empty chain
+	stateDb common.Database // Database the state of the fast sync pivot is written to
+	pivot   *types.Header   // Fast sync pivot block of the current synchronisation, if any
+
 	// Callbacks
-	hasBlock    hashCheckFn
-	insertChain chainInsertFn
-	currentTd   currentTdFn
+	hasBlock       hashCheckFn
+	getHeader      headerFetchFn
+	validateHeader headerCheckFn
+	insertChain    chainInsertFn
+	insertReceipts receiptChainInsertFn
+	commitHead     headCommitFn
+	currentTd      currentTdFn
 
 	// Status
-	fetchingHashes    int32
+	fetchingHeaders   int32
 	downloadingBlocks int32
+	syncingState      int32
 	processingBlocks  int32
 
 	// Channels
 	newPeerCh chan *peer
 	syncCh    chan syncPack
-	HashCh    chan []common.Hash
-	blockCh   chan blockPack
+	headerCh  chan headerPack
+	bodyCh    chan bodyPack
+	receiptCh chan receiptPack
+	stateCh   chan statePack
 	quit      chan struct{}
 }
 
-type blockPack struct {
+type headerPack struct {
+	peerId  string
+	headers []*types.Header
+}
+
+type bodyPack struct {
+	peerId       string
+	transactions [][]*types.Transaction
+	uncles       [][]*types.Header
+}
+
+type receiptPack struct {
+	peerId   string
+	receipts [][]*types.Receipt
+}
+
+type statePack struct {
 	peerId string
-	blocks []*types.Block
+	data   [][]byte
 }
 
+// syncPack requests a synchronisation with peer, starting at the header
+// with the given hash (the most recent block we don't have).
 type syncPack struct {
-	peer          *peer
-	hash          common.Hash
-	ignoreInitial bool
+	peer *peer
+	hash common.Hash
 }
 
-func New(hasBlock hashCheckFn, insertChain chainInsertFn, currentTd currentTdFn) *Downloader {
+// New creates a downloader which synchronises the chain header first: it
+// fetches the header chain from the best peer, validates it using
+// validateHeader (which is expected to check the proof of work as well) and
+// then downloads the block bodies in parallel from all available peers.
+//
+// In fast sync mode an empty chain is synchronised without executing the
+// transactions of old blocks: the receipts of all blocks up to a recent pivot
+// are downloaded and stored through insertReceipts, the state of the pivot is
+// retrieved node by node into stateDb and commitHead makes the pivot the head
+// of the chain. Only the blocks after the pivot are processed by insertChain.
+func New(mode SyncMode, stateDb common.Database, hasBlock hashCheckFn, getHeader headerFetchFn, validateHeader headerCheckFn, insertChain chainInsertFn, insertReceipts receiptChainInsertFn, commitHead headCommitFn, currentTd currentTdFn) *Downloader {
 	downloader := &Downloader{
-		queue:       newqueue(),
-		peers:       make(peers),
-		hasBlock:    hasBlock,
-		insertChain: insertChain,
-		currentTd:   currentTd,
-		newPeerCh:   make(chan *peer, 1),
-		syncCh:      make(chan syncPack, 1),
-		HashCh:      make(chan []common.Hash, 1),
-		blockCh:     make(chan blockPack, 1),
-		quit:        make(chan struct{}),
+		queue:          newqueue(),
+		peers:          make(peers),
+		mode:           mode,
+		stateDb:        stateDb,
+		hasBlock:       hasBlock,
+		getHeader:      getHeader,
+		validateHeader: validateHeader,
+		insertChain:    insertChain,
+		insertReceipts: insertReceipts,
+		commitHead:     commitHead,
+		currentTd:      currentTd,
+		newPeerCh:      make(chan *peer, 1),
+		syncCh:         make(chan syncPack, 1),
+		headerCh:       make(chan headerPack, 1),
+		bodyCh:         make(chan bodyPack, 1),
+		receiptCh:      make(chan receiptPack, 1),
+		stateCh:        make(chan statePack, 1),
+		quit:           make(chan struct{}),
 	}
 	go downloader.peerHandler()
 	go downloader.update()
@@ -78,14 +153,24 @@ func New(hasBlock hashCheckFn, insertChain chainInsertFn, currentTd currentTdFn)
 	return downloader
 }
 
-func (d *Downloader) RegisterPeer(id string, td *big.Int, hash common.Hash, getHashes hashFetcherFn, getBlocks blockFetcherFn) error {
+// Stop terminates the downloader's background processes.
+func (d *Downloader) Stop() {
+	close(d.quit)
+}
+
+// RegisterPeer adds a new peer to the set of peers used for synchronisation.
+// getHeaders is used to request the header of a block and its ancestors,
+// getBodies to request the bodies of a set of blocks. getReceipts and
+// getNodeData request block receipts and state entries for fast sync; they
+// are nil for peers unable to serve them.
+func (d *Downloader) RegisterPeer(id string, td *big.Int, hash common.Hash, getHeaders func(common.Hash) error, getBodies func([]common.Hash) error, getReceipts func([]common.Hash) error, getNodeData func([]common.Hash) error) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	glog.V(logger.Detail).Infoln("Register peer", id)
 
 	// Create a new peer and add it to the list of known peers
-	peer := newPeer(id, td, hash, getHashes, getBlocks)
+	peer := newPeer(id, td, hash, getHeaders, getBodies, getReceipts, getNodeData)
 	// add peer to our peer set
 	d.peers[id] = peer
 	// broadcast new peer
@@ -131,7 +216,10 @@ func (d *Downloader) selectPeer(p *peer) {
 	// Make sure it's doing neither. Once done we can restart the
 	// downloading process if the TD is higher. For now just get on
 	// with whatever is going on. This prevents unecessary switching.
-	if !(d.isFetchingHashes() || d.isDownloadingBlocks() || d.isProcessing()) {
+	if p == nil {
+		return
+	}
+	if !(d.isFetchingHeaders() || d.isDownloadingBlocks() || d.isSyncingState() || d.isProcessing()) {
 		// selected peer must be better than our own
 		// XXX we also check the peer's recent hash to make sure we
 		// don't have it. Some peers report (i think) incorrect TD.
@@ -140,7 +228,7 @@ func (d *Downloader) selectPeer(p *peer) {
 		}
 
 		glog.V(logger.Detail).Infoln("New peer with highest TD =", p.td)
-		d.syncCh <- syncPack{p, p.recentHash, false}
+		d.syncCh <- syncPack{p, p.recentHash}
 	}
 }
 
@@ -154,14 +242,16 @@ out:
 			// Start the fetcher. This will block the update entirely
 			// interupts need to be send to the appropriate channels
 			// respectively.
-			if err := d.startFetchingHashes(selectedPeer, sync.hash, sync.ignoreInitial); err != nil {
-				// handle error
-				glog.V(logger.Debug).Infoln("Error fetching hashes:", err)
-				// XXX Reset
+			if err := d.startFetchingHeaders(selectedPeer, sync.hash); err != nil {
+				// The header chain of the peer couldn't be retrieved or is
+				// invalid, so don't use the peer any longer.
+				glog.V(logger.Debug).Infoln("Error fetching headers:", err)
+				d.UnregisterPeer(selectedPeer.id)
+				selectedPeer.demote()
 				break
 			}
 
-			// Start fetching blocks in paralel. The strategy is simple
+			// Start fetching bodies in paralel. The strategy is simple
 			// take any available peers, seserve a chunk for each peer available,
 			// let the peer deliver the chunkn and periodically check if a peer
 			// has timedout. When done downloading, process blocks.
@@ -171,6 +261,18 @@ out:
 				break
 			}
 
+			// When fast syncing retrieve the state of the pivot block before
+			// anything is written to the chain. Without it none of the
+			// downloaded blocks can be used.
+			if d.pivot != nil {
+				if err := d.syncState(d.pivot.Root); err != nil {
+					glog.V(logger.Debug).Infoln("Error syncing state:", err)
+					d.pivot = nil
+					d.queue.reset()
+					break
+				}
+			}
+
 			glog.V(logger.Detail).Infoln("Sync completed")
 
 			d.process()
@@ -180,61 +282,106 @@ out:
 	}
 }
 
-// XXX Make synchronous
-func (d *Downloader) startFetchingHashes(p *peer, hash common.Hash, ignoreInitial bool) error {
-	glog.V(logger.Debug).Infof("Downloading hashes (%x) from %s", hash.Bytes()[:4], p.id)
+// startFetchingHeaders retrieves the header chain of p, starting at the
+// header of hash and walking towards the genesis block until a block known
+// to the local chain is found. The headers are checked to form a contiguous
+// chain and are then validated, oldest first, against their parents before
+// their bodies are scheduled for download.
+func (d *Downloader) startFetchingHeaders(p *peer, hash common.Hash) error {
+	glog.V(logger.Debug).Infof("Downloading headers (%x) from %s", hash.Bytes()[:4], p.id)
+
+	// Drop any stale delivery left over from a previous fetch
+	select {
+	case <-d.headerCh:
+	default:
+	}
+	atomic.StoreInt32(&d.fetchingHeaders, 1)
+	defer atomic.StoreInt32(&d.fetchingHeaders, 0)
 
 	start := time.Now()
 
-	// We ignore the initial hash in some cases (e.g. we received a block without it's parent)
-	// In such circumstances we don't need to download the block so don't add it to the queue.
-	if !ignoreInitial {
-		// Add the hash to the queue first
-		d.queue.hashPool.Add(hash)
-	}
-
-	// Get the first batch of hashes
-	p.getHashes(hash)
-	atomic.StoreInt32(&d.fetchingHashes, 1)
+	var (
+		headers  []*types.Header // unknown headers, newest first
+		ancestor *types.Header   // most recent header known locally
+		next     = hash          // hash of the next expected header
+	)
+	// Get the first batch of headers
+	p.getHeaders(next)
+	timeout := time.NewTimer(headerTTL)
+	defer timeout.Stop()
 
 out:
 	for {
 		select {
-		case hashes := <-d.HashCh:
-			var done bool // determines whether we're done fetching hashes (i.e. common hash found)
-			hashSet := set.New()
-			for _, hash := range hashes {
-				if d.hasBlock(hash) {
-					glog.V(logger.Debug).Infof("Found common hash %x\n", hash[:4])
-
-					done = true
-					break
+		case pack := <-d.headerCh:
+			// Ignore headers sent by any peer but the selected one
+			if pack.peerId != p.id {
+				break
+			}
+			if len(pack.headers) == 0 {
+				return errEmptyHeaderChain
+			}
+			for _, header := range pack.headers {
+				if header.Hash() != next {
+					return errBadHeaderChain
 				}
+				if d.hasBlock(next) {
+					glog.V(logger.Debug).Infof("Found common ancestor %x\n", next[:4])
 
-				hashSet.Add(hash)
-			}
-			d.queue.put(hashSet)
-
-			// Add hashes to the chunk set
-			// Check if we're done fetching
-			if !done && len(hashes) > 0 {
-				//fmt.Println("re-fetch. current =", d.queue.hashPool.Size())
-				// Get the next set of hashes
-				p.getHashes(hashes[len(hashes)-1])
-				atomic.StoreInt32(&d.fetchingHashes, 1)
-			} else {
-				atomic.StoreInt32(&d.fetchingHashes, 0)
-				break out
+					ancestor = d.getHeader(next)
+					break out
+				}
+				if header.Number.Cmp(common.Big0) <= 0 {
+					return errUnknownAncestor
+				}
+				headers = append(headers, header)
+				next = header.ParentHash
 			}
+			// Get the next batch of headers
+			p.getHeaders(next)
+			timeout.Reset(headerTTL)
+
+		case <-timeout.C:
+			return errTimeout
+
+		case <-d.quit:
+			return errTimeout
+		}
+	}
+	if ancestor == nil {
+		return errUnknownAncestor
+	}
+
+	// Validate the header chain oldest first. Validation includes the
+	// proof of work so an invalid chain is caught before any of its
+	// bodies are downloaded.
+	parent := ancestor
+	for i := len(headers) - 1; i >= 0; i-- {
+		if err := d.validateHeader(headers[i], parent); err != nil {
+			return err
 		}
+		parent = headers[i]
 	}
-	glog.V(logger.Detail).Infof("Downloaded hashes (%d). Took %v\n", d.queue.hashPool.Size(), time.Since(start))
+	d.queue.putHeaders(headers)
+
+	// Fast sync an empty chain if the remote chain is long enough. Receipts
+	// are needed for all blocks up to the pivot, the ones after it will be
+	// processed and regenerate their receipts.
+	d.pivot = nil
+	if d.mode == FastSync && p.fastSync() && ancestor.Number.Cmp(common.Big0) == 0 && len(headers) > fsMinFullBlocks {
+		d.pivot = headers[fsMinFullBlocks]
+		d.queue.putReceipts(headers[fsMinFullBlocks:])
+
+		glog.V(logger.Debug).Infof("Fast syncing to pivot #%v (%x)\n", d.pivot.Number, d.pivot.Hash().Bytes()[:4])
+	}
+
+	glog.V(logger.Detail).Infof("Downloaded headers (%d). Took %v\n", len(headers), time.Since(start))
 
 	return nil
 }
 
 func (d *Downloader) startFetchingBlocks(p *peer) error {
-	glog.V(logger.Detail).Infoln("Downloading", d.queue.hashPool.Size(), "blocks")
+	glog.V(logger.Detail).Infoln("Downloading", d.queue.hashPool.Size(), "block bodies")
 	atomic.StoreInt32(&d.downloadingBlocks, 1)
 
 	start := time.Now()
@@ -244,14 +391,41 @@ func (d *Downloader) startFetchingBlocks(p *peer) error {
 out:
 	for {
 		select {
-		case blockPack := <-d.blockCh:
-			d.peers[blockPack.peerId].promote()
-			d.queue.deliver(blockPack.peerId, blockPack.blocks)
-			d.peers.setState(blockPack.peerId, idleState)
+		case bodyPack := <-d.bodyCh:
+			peer := d.peers.getPeer(bodyPack.peerId)
+			if err := d.queue.deliver(bodyPack.peerId, bodyPack.transactions, bodyPack.uncles); err != nil {
+				// The peer delivered bodies that don't belong to the
+				// requested headers. Drop it, the remaining bodies will
+				// be picked up by other peers.
+				glog.V(logger.Debug).Infof("Bad bodies from peer %s: %v\n", bodyPack.peerId, err)
+				d.UnregisterPeer(bodyPack.peerId)
+				if peer != nil {
+					peer.demote()
+				}
+				break
+			}
+			if peer != nil {
+				peer.promote()
+			}
+			d.peers.setState(bodyPack.peerId, idleState)
+		case receiptPack := <-d.receiptCh:
+			peer := d.peers.getPeer(receiptPack.peerId)
+			if err := d.queue.deliverReceipts(receiptPack.peerId, receiptPack.receipts); err != nil {
+				glog.V(logger.Debug).Infof("Bad receipts from peer %s: %v\n", receiptPack.peerId, err)
+				d.UnregisterPeer(receiptPack.peerId)
+				if peer != nil {
+					peer.demote()
+				}
+				break
+			}
+			if peer != nil {
+				peer.promote()
+			}
+			d.peers.setState(receiptPack.peerId, idleState)
 		case <-ticker.C:
 			// If there are unrequested hashes left start fetching
 			// from the available peers.
-			if d.queue.hashPool.Size() > 0 {
+			if d.queue.hashPool.Size() > 0 || d.queue.receiptPool.Size() > 0 {
 				availablePeers := d.peers.get(idleState)
 				if len(availablePeers) == 0 {
 					glog.V(logger.Detail).Infoln("No peers available out of", len(d.peers))
@@ -260,24 +434,32 @@ out:
 				for _, peer := range availablePeers {
 					// Get a possible chunk. If nil is returned no chunk
 					// could be returned due to no hashes available.
-					chunk := d.queue.get(peer, maxBlockFetch)
-					if chunk == nil {
+					if chunk := d.queue.get(peer, maxBlockFetch); chunk != nil {
+						//fmt.Println("fetching for", peer.id)
+						// XXX make fetch blocking.
+						// Fetch the chunk and check for error. If the peer was somehow
+						// already fetching a chunk due to a bug, it will be returned to
+						// the queue
+						if err := peer.fetch(chunk); err != nil {
+							// log for tracing
+							glog.V(logger.Debug).Infof("peer %s received double work (state = %v)\n", peer.id, peer.state)
+							d.queue.deliver(peer.id, nil, nil)
+						}
 						continue
 					}
-
-					//fmt.Println("fetching for", peer.id)
-					// XXX make fetch blocking.
-					// Fetch the chunk and check for error. If the peer was somehow
-					// already fetching a chunk due to a bug, it will be returned to
-					// the queue
-					if err := peer.fetch(chunk); err != nil {
-						// log for tracing
-						glog.V(logger.Debug).Infof("peer %s received double work (state = %v)\n", peer.id, peer.state)
-						d.queue.put(chunk.hashes)
+					// No bodies left, help out with the receipts if possible
+					if !peer.fastSync() {
+						continue
+					}
+					if chunk := d.queue.getReceipts(peer, maxReceiptFetch); chunk != nil {
+						if err := peer.fetchReceipts(chunk); err != nil {
+							glog.V(logger.Debug).Infof("peer %s received double work (state = %v)\n", peer.id, peer.state)
+							d.queue.deliverReceipts(peer.id, nil)
+						}
 					}
 				}
 				atomic.StoreInt32(&d.downloadingBlocks, 1)
-			} else if len(d.queue.fetching) == 0 {
+			} else if len(d.queue.fetching) == 0 && len(d.queue.receiptFetching) == 0 {
 				// When there are no more queue and no more `fetching`. We can
 				// safely assume we're done. Another part of the process will  check
 				// for parent errors and will re-request anything that's missing
@@ -286,17 +468,19 @@ out:
 				break out
 			} else {
 				// Check for bad peers. Bad peers may indicate a peer not responding
-				// to a `getBlocks` message. A timeout of 5 seconds is set. Peers
+				// to a `getBodies` message. A timeout of 5 seconds is set. Peers
 				// that badly or poorly behave are removed from the peer set (not banned).
 				// Bad peers are excluded from the available peer set and therefor won't be
 				// reused. XXX We could re-introduce peers after X time.
 				d.queue.mu.Lock()
 				var badPeers []string
-				for pid, chunk := range d.queue.fetching {
-					if time.Since(chunk.itime) > 5*time.Second {
-						badPeers = append(badPeers, pid)
-						// remove peer as good peer from peer list
-						d.UnregisterPeer(pid)
+				for _, fetching := range []map[string]*chunk{d.queue.fetching, d.queue.receiptFetching} {
+					for pid, chunk := range fetching {
+						if time.Since(chunk.itime) > 5*time.Second {
+							badPeers = append(badPeers, pid)
+							// remove peer as good peer from peer list
+							d.UnregisterPeer(pid)
+						}
 					}
 				}
 				d.queue.mu.Unlock()
@@ -310,7 +494,8 @@ out:
 					// 1) Time for them to respond;
 					// 2) Measure their speed;
 					// 3) Amount and availability.
-					d.queue.deliver(pid, nil)
+					d.queue.deliver(pid, nil, nil)
+					d.queue.deliverReceipts(pid, nil)
 					if peer := d.peers[pid]; peer != nil {
 						peer.demote()
 					}
@@ -326,6 +511,96 @@ out:
 	return nil
 }
 
+// syncState retrieves the state trie rooted at root, including all storage
+// tries and contract code, from the fast sync capable peers. Every entry is
+// verified against its hash and only written to the state database once all
+// of the entries it references are present.
+func (d *Downloader) syncState(root common.Hash) error {
+	glog.V(logger.Debug).Infof("Syncing state %x", root.Bytes()[:4])
+
+	// Drop any stale delivery left over from a previous sync
+	select {
+	case <-d.stateCh:
+	default:
+	}
+	atomic.StoreInt32(&d.syncingState, 1)
+	defer atomic.StoreInt32(&d.syncingState, 0)
+
+	start := time.Now()
+	sched := state.NewStateSync(root, d.stateDb)
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case statePack := <-d.stateCh:
+			peer := d.peers.getPeer(statePack.peerId)
+			results, err := d.queue.deliverState(statePack.peerId, statePack.data)
+			// The results are verified against the requested hashes, so
+			// failing to process them means the state itself is broken.
+			if _, err := sched.Process(results); err != nil {
+				return err
+			}
+			if err != nil {
+				glog.V(logger.Debug).Infof("Bad state from peer %s: %v\n", statePack.peerId, err)
+				d.UnregisterPeer(statePack.peerId)
+				if peer != nil {
+					peer.demote()
+				}
+				break
+			}
+			if peer != nil {
+				peer.promote()
+			}
+			d.peers.setState(statePack.peerId, idleState)
+		case <-ticker.C:
+			d.queue.putState(sched.Missing(0))
+
+			if d.queue.statePool.Size() > 0 {
+				if !d.peers.canFastSync() {
+					return errNoFastPeers
+				}
+				for _, peer := range d.peers.fastPeers() {
+					chunk := d.queue.getState(peer, maxStateFetch)
+					if chunk == nil {
+						break
+					}
+					if err := peer.fetchNodeData(chunk); err != nil {
+						glog.V(logger.Debug).Infof("peer %s received double work (state = %v)\n", peer.id, peer.state)
+						d.queue.deliverState(peer.id, nil)
+					}
+				}
+			} else if len(d.queue.stateFetching) == 0 {
+				if sched.Pending() == 0 {
+					glog.V(logger.Detail).Infoln("State sync: done. Took", time.Since(start))
+					return nil
+				}
+			} else {
+				// Drop the peers not delivering in time, their entries are
+				// put back into the pool for other peers to pick up.
+				d.queue.mu.Lock()
+				var badPeers []string
+				for pid, chunk := range d.queue.stateFetching {
+					if time.Since(chunk.itime) > 5*time.Second {
+						badPeers = append(badPeers, pid)
+						d.UnregisterPeer(pid)
+					}
+				}
+				d.queue.mu.Unlock()
+
+				for _, pid := range badPeers {
+					d.queue.deliverState(pid, nil)
+					if peer := d.peers[pid]; peer != nil {
+						peer.demote()
+					}
+				}
+			}
+		case <-d.quit:
+			return errTimeout
+		}
+	}
+}
+
 // Add an (unrequested) block to the downloader. This is usually done through the
 // NewBlockMsg by the protocol handler.
 func (d *Downloader) AddBlock(id string, block *types.Block, td *big.Int) {
@@ -353,23 +628,113 @@ func (d *Downloader) AddBlock(id string, block *types.Block, td *big.Int) {
 	d.queue.addBlock(id, block, td)
 
 	// if neither go ahead to process
-	if !(d.isFetchingHashes() || d.isDownloadingBlocks()) {
+	if !(d.isFetchingHeaders() || d.isDownloadingBlocks() || d.isSyncingState()) {
 		// Check if the parent of the received block is known.
 		// If the block is not know, request it otherwise, request.
 		phash := block.ParentHash()
 		if !d.hasBlock(phash) {
 			glog.V(logger.Detail).Infof("Missing parent %x, requires fetching\n", phash.Bytes()[:4])
-			d.syncCh <- syncPack{peer, peer.recentHash, true}
+			d.syncCh <- syncPack{peer, phash}
 		} else {
 			d.process()
 		}
 	}
 }
 
-// Deliver a chunk to the downloader. This is usually done through the BlocksMsg by
-// the protocol handler.
-func (d *Downloader) DeliverChunk(id string, blocks []*types.Block) {
-	d.blockCh <- blockPack{id, blocks}
+// AnnounceBlock notes the hash of a new block announced by a peer. If the block
+// is unknown and the downloader idle, the chain is synchronised with the peer,
+// starting at the announced block.
+func (d *Downloader) AnnounceBlock(id string, hash common.Hash) {
+	if d.hasBlock(hash) {
+		return
+	}
+	peer := d.peers.getPeer(id)
+	if peer == nil {
+		glog.V(logger.Detail).Infof("Ignored announcement from bad peer %s\n", id)
+		return
+	}
+	peer.mu.Lock()
+	peer.recentHash = hash
+	peer.mu.Unlock()
+
+	if !(d.isFetchingHeaders() || d.isDownloadingBlocks() || d.isSyncingState() || d.isProcessing()) {
+		select {
+		case d.syncCh <- syncPack{peer, hash}:
+		default:
+			// a synchronisation is already pending
+		}
+	}
+}
+
+// DeliverHeaders delivers a batch of headers to the downloader. This is usually
+// done through the BlockHeadersMsg by the protocol handler. Headers arriving
+// while no header chain is being fetched are dropped.
+func (d *Downloader) DeliverHeaders(id string, headers []*types.Header) {
+	if !d.isFetchingHeaders() {
+		glog.V(logger.Detail).Infof("Ignored unrequested headers from peer %s\n", id)
+		return
+	}
+	d.headerCh <- headerPack{id, headers}
+}
+
+// DeliverBodies delivers a batch of block bodies to the downloader. This is
+// usually done through the BlockBodiesMsg by the protocol handler. Bodies
+// arriving while no bodies are being downloaded are dropped.
+func (d *Downloader) DeliverBodies(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) {
+	if !d.isDownloadingBlocks() {
+		glog.V(logger.Detail).Infof("Ignored unrequested bodies from peer %s\n", id)
+		return
+	}
+	d.bodyCh <- bodyPack{id, transactions, uncles}
+}
+
+// DeliverReceipts delivers a batch of block receipts to the downloader. This is
+// usually done through the ReceiptsMsg by the protocol handler.
+func (d *Downloader) DeliverReceipts(id string, receipts [][]*types.Receipt) {
+	if !d.isDownloadingBlocks() {
+		glog.V(logger.Detail).Infof("Ignored unrequested receipts from peer %s\n", id)
+		return
+	}
+	d.receiptCh <- receiptPack{id, receipts}
+}
+
+// DeliverNodeData delivers a batch of state trie nodes and contract code to the
+// downloader. This is usually done through the NodeDataMsg by the protocol
+// handler.
+func (d *Downloader) DeliverNodeData(id string, data [][]byte) {
+	if !d.isSyncingState() {
+		glog.V(logger.Detail).Infof("Ignored unrequested node data from peer %s\n", id)
+		return
+	}
+	d.stateCh <- statePack{id, data}
+}
+
+// commitFastSync writes the downloaded blocks up to and including the fast
+// sync pivot along with their receipts and makes the pivot the head of the
+// chain. The remaining blocks are returned for regular processing.
+func (d *Downloader) commitFastSync(blocks types.Blocks) (types.Blocks, error) {
+	pivot := d.pivot
+	d.pivot = nil
+
+	var (
+		fast     types.Blocks
+		receipts []types.Receipts
+	)
+	for len(blocks) > 0 && blocks[0].NumberU64() <= pivot.Number.Uint64() {
+		fast = append(fast, blocks[0])
+		receipts = append(receipts, d.queue.receipts[blocks[0].Hash()])
+		blocks = blocks[1:]
+	}
+	d.queue.receipts = make(map[common.Hash]types.Receipts)
+
+	glog.V(logger.Debug).Infoln("Inserting receipt chain with", len(fast), "blocks")
+	if err := d.insertReceipts(fast, receipts); err != nil {
+		return nil, err
+	}
+	if err := d.commitHead(pivot.Hash()); err != nil {
+		return nil, err
+	}
+	return blocks, nil
 }
 
 func (d *Downloader) process() error {
@@ -384,6 +749,15 @@ func (d *Downloader) process() error {
 	types.BlockBy(types.Number).Sort(d.queue.blocks)
 	blocks := d.queue.blocks
 
+	if d.pivot != nil {
+		var err error
+		if blocks, err = d.commitFastSync(blocks); err != nil {
+			glog.V(logger.Debug).Infoln("Aborting fast sync:", err)
+			d.queue.reset()
+			return err
+		}
+	}
+
 	glog.V(logger.Debug).Infoln("Inserting chain with", len(blocks), "blocks")
 
 	var err error
@@ -400,7 +774,7 @@ func (d *Downloader) process() error {
 			// TODO change this. This shite
 			for i, block := range blocks[:max] {
 				if !d.hasBlock(block.ParentHash()) {
-					d.syncCh <- syncPack{d.peers.bestPeer(), block.Hash(), true}
+					d.syncCh <- syncPack{d.peers.bestPeer(), block.ParentHash()}
 					// remove processed blocks
 					blocks = blocks[i:]
 
@@ -421,14 +795,18 @@ func (d *Downloader) process() error {
 	return err
 }
 
-func (d *Downloader) isFetchingHashes() bool {
-	return atomic.LoadInt32(&d.fetchingHashes) == 1
+func (d *Downloader) isFetchingHeaders() bool {
+	return atomic.LoadInt32(&d.fetchingHeaders) == 1
 }
 
 func (d *Downloader) isDownloadingBlocks() bool {
 	return atomic.LoadInt32(&d.downloadingBlocks) == 1
 }
 
+func (d *Downloader) isSyncingState() bool {
+	return atomic.LoadInt32(&d.syncingState) == 1
+}
+
 func (d *Downloader) isProcessing() bool {
 	return atomic.LoadInt32(&d.processingBlocks) == 1
 }
@@ -1,69 +1,108 @@
 package downloader
 
 import (
-	"encoding/binary"
 	"math/big"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 )
 
-var knownHash = common.Hash{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-
-func createHashes(start, amount int) (hashes []common.Hash) {
-	hashes = make([]common.Hash, amount+1)
-	hashes[len(hashes)-1] = knownHash
-
-	for i := range hashes[:len(hashes)-1] {
-		binary.BigEndian.PutUint64(hashes[i][:8], uint64(i+2))
-	}
-
-	return
-}
-
-func createBlocksFromHashes(hashes []common.Hash) map[common.Hash]*types.Block {
-	blocks := make(map[common.Hash]*types.Block)
-	for i, hash := range hashes {
-		header := &types.Header{Number: big.NewInt(int64(i))}
-		blocks[hash] = types.NewBlockWithHeader(header)
-		blocks[hash].HeaderHash = hash
+// createChain creates a chain of amount blocks on top of a genesis block.
+// Every block contains a single transaction and receipt so its body and
+// receipts have to be fetched. All blocks share the given state root. The
+// blocks are returned newest first, the genesis block being last.
+func createChain(amount int, root common.Hash) []*types.Block {
+	genesis := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0)})
+	chain := []*types.Block{genesis}
+	for i := 1; i <= amount; i++ {
+		header := &types.Header{
+			ParentHash: chain[0].Hash(),
+			Number:     big.NewInt(int64(i)),
+			Root:       root,
+		}
+		block := types.NewBlockWithHeader(header)
+		block.SetTransactions(types.Transactions{types.NewTransactionMessage(common.Address{}, big.NewInt(int64(i)), common.Big0, common.Big0, nil)})
+		block.SetUncles(nil)
+		block.SetReceipts(types.Receipts{types.NewReceipt(root[:], big.NewInt(int64(i)))})
+		chain = append([]*types.Block{block}, chain...)
 	}
-
-	return blocks
+	return chain
 }
 
 type downloadTester struct {
 	downloader *Downloader
-	hashes     []common.Hash
+	chain      []*types.Block
 	blocks     map[common.Hash]*types.Block
 	t          *testing.T
-	pcount     int
 	done       chan bool
 
-	insertedBlocks int
+	srcDb *ethdb.MemDatabase // State database served to fast sync peers
+	dstDb *ethdb.MemDatabase // State database the downloader syncs into
+
+	insertedBlocks  int
+	receiptBlocks   types.Blocks // Blocks inserted through the receipt chain
+	chainBlocks     types.Blocks // Blocks inserted through full processing
+	headCommitted   common.Hash  // Hash of the committed fast sync head
+	committedStates int          // Number of state entries present at head commit
+
+	lock       sync.Mutex
+	badServers map[string]bool // Peers that served invalid state entries
+}
+
+func newTester(t *testing.T, chain []*types.Block) *downloadTester {
+	return newModeTester(t, FullSync, chain, nil)
 }
 
-func newTester(t *testing.T, hashes []common.Hash, blocks map[common.Hash]*types.Block) *downloadTester {
-	tester := &downloadTester{t: t, hashes: hashes, blocks: blocks, done: make(chan bool)}
-	downloader := New(tester.hasBlock, tester.insertChain, func() *big.Int { return new(big.Int) })
+func newModeTester(t *testing.T, mode SyncMode, chain []*types.Block, srcDb *ethdb.MemDatabase) *downloadTester {
+	dstDb, _ := ethdb.NewMemDatabase()
+	tester := &downloadTester{t: t, chain: chain, blocks: make(map[common.Hash]*types.Block), done: make(chan bool), srcDb: srcDb, dstDb: dstDb, badServers: make(map[string]bool)}
+	for _, block := range chain {
+		tester.blocks[block.Hash()] = block
+	}
+	downloader := New(mode, dstDb, tester.hasBlock, tester.getHeader, tester.validateHeader, tester.insertChain, tester.insertReceipts, tester.commitHead, func() *big.Int { return new(big.Int) })
 	tester.downloader = downloader
 
 	return tester
 }
 
+func (dl *downloadTester) genesis() *types.Block {
+	return dl.chain[len(dl.chain)-1]
+}
+
 func (dl *downloadTester) hasBlock(hash common.Hash) bool {
-	if knownHash == hash {
-		return true
+	return dl.genesis().Hash() == hash
+}
+
+func (dl *downloadTester) getHeader(hash common.Hash) *types.Header {
+	if dl.hasBlock(hash) {
+		return dl.genesis().Header()
 	}
-	return false
+	return nil
+}
+
+func (dl *downloadTester) validateHeader(header, parent *types.Header) error {
+	if header.ParentHash != parent.Hash() {
+		dl.t.Errorf("header %x validated against wrong parent %x", header.Hash(), parent.Hash())
+	}
+	return nil
 }
 
 func (dl *downloadTester) insertChain(blocks types.Blocks) error {
+	for _, block := range blocks {
+		if want := dl.blocks[block.Hash()]; want == nil || types.DeriveSha(block.Transactions()) != want.Header().TxHash {
+			dl.t.Errorf("inserted unknown or incomplete block %x", block.Hash())
+		}
+	}
 	dl.insertedBlocks += len(blocks)
+	dl.chainBlocks = append(dl.chainBlocks, blocks...)
 
 	if len(dl.blocks)-1 <= dl.insertedBlocks {
 		dl.done <- true
@@ -72,58 +111,328 @@ func (dl *downloadTester) insertChain(blocks types.Blocks) error {
 	return nil
 }
 
-func (dl *downloadTester) getHashes(hash common.Hash) error {
-	dl.downloader.HashCh <- dl.hashes
+func (dl *downloadTester) insertReceipts(blocks types.Blocks, receipts []types.Receipts) error {
+	for i, block := range blocks {
+		if want := dl.blocks[block.Hash()]; want == nil || types.DeriveSha(receipts[i]) != want.Header().ReceiptHash {
+			dl.t.Errorf("inserted unknown block or invalid receipts %x", block.Hash())
+		}
+	}
+	dl.insertedBlocks += len(blocks)
+	dl.receiptBlocks = append(dl.receiptBlocks, blocks...)
+
+	return nil
+}
+
+func (dl *downloadTester) commitHead(hash common.Hash) error {
+	dl.headCommitted = hash
+	dl.committedStates = len(dl.dstDb.Keys())
+
 	return nil
 }
 
-func (dl *downloadTester) getBlocks(id string) func([]common.Hash) error {
+func (dl *downloadTester) getHeaders(id string) func(common.Hash) error {
+	return func(hash common.Hash) error {
+		var headers []*types.Header
+		for block := dl.blocks[hash]; block != nil && len(headers) < maxHeaderFetch; block = dl.blocks[block.ParentHash()] {
+			headers = append(headers, block.Header())
+		}
+		go dl.downloader.DeliverHeaders(id, headers)
+
+		return nil
+	}
+}
+
+func (dl *downloadTester) getBodies(id string) func([]common.Hash) error {
 	return func(hashes []common.Hash) error {
-		blocks := make([]*types.Block, len(hashes))
+		transactions := make([][]*types.Transaction, len(hashes))
+		uncles := make([][]*types.Header, len(hashes))
 		for i, hash := range hashes {
-			blocks[i] = dl.blocks[hash]
+			transactions[i] = dl.blocks[hash].Transactions()
+			uncles[i] = dl.blocks[hash].Uncles()
 		}
 
-		go dl.downloader.DeliverChunk(id, blocks)
+		go dl.downloader.DeliverBodies(id, transactions, uncles)
+
+		return nil
+	}
+}
+
+func (dl *downloadTester) getReceipts(id string) func([]common.Hash) error {
+	return func(hashes []common.Hash) error {
+		receipts := make([][]*types.Receipt, len(hashes))
+		for i, hash := range hashes {
+			receipts[i] = dl.blocks[hash].Receipts()
+		}
+		go dl.downloader.DeliverReceipts(id, receipts)
+
+		return nil
+	}
+}
+
+func (dl *downloadTester) getNodeData(id string) func([]common.Hash) error {
+	return func(hashes []common.Hash) error {
+		var data [][]byte
+		for _, hash := range hashes {
+			if entry, _ := dl.srcDb.Get(hash[:]); len(entry) > 0 {
+				data = append(data, entry)
+			}
+		}
+		go dl.downloader.DeliverNodeData(id, data)
 
 		return nil
 	}
 }
 
 func (dl *downloadTester) newPeer(id string, td *big.Int, hash common.Hash) {
-	dl.pcount++
+	dl.downloader.RegisterPeer(id, td, hash, dl.getHeaders(id), dl.getBodies(id), nil, nil)
+}
 
-	dl.downloader.RegisterPeer(id, td, hash, dl.getHashes, dl.getBlocks(id))
+func (dl *downloadTester) newFastPeer(id string, td *big.Int, hash common.Hash) {
+	dl.downloader.RegisterPeer(id, td, hash, dl.getHeaders(id), dl.getBodies(id), dl.getReceipts(id), dl.getNodeData(id))
 }
 
 func (dl *downloadTester) badBlocksPeer(id string, td *big.Int, hash common.Hash) {
-	dl.pcount++
+	// This bad peer never returns any bodies
+	dl.downloader.RegisterPeer(id, td, hash, dl.getHeaders(id), func([]common.Hash) error {
+		return nil
+	}, nil, nil)
+}
+
+func (dl *downloadTester) badBodiesPeer(id string, td *big.Int, hash common.Hash) {
+	// This bad peer returns bodies that don't match the requested headers
+	dl.downloader.RegisterPeer(id, td, hash, dl.getHeaders(id), func(hashes []common.Hash) error {
+		transactions := make([][]*types.Transaction, len(hashes))
+		uncles := make([][]*types.Header, len(hashes))
+		go dl.downloader.DeliverBodies(id, transactions, uncles)
+
+		return nil
+	}, nil, nil)
+}
+
+func (dl *downloadTester) badStatePeer(id string, td *big.Int, hash common.Hash) {
+	// This bad peer returns garbage instead of the requested state entries
+	dl.downloader.RegisterPeer(id, td, hash, dl.getHeaders(id), dl.getBodies(id), dl.getReceipts(id), func(hashes []common.Hash) error {
+		data := make([][]byte, len(hashes))
+		for i := range hashes {
+			data[i] = []byte{0xde, 0xad}
+		}
+		dl.lock.Lock()
+		dl.badServers[id] = true
+		dl.lock.Unlock()
+
+		go dl.downloader.DeliverNodeData(id, data)
 
-	// This bad peer never returns any blocks
-	dl.downloader.RegisterPeer(id, td, hash, dl.getHashes, func([]common.Hash) error {
 		return nil
 	})
 }
 
+func (dl *downloadTester) wait(t *testing.T) {
+	select {
+	case <-dl.done:
+	case <-time.After(10 * time.Second): // XXX this could actually fail on a slow computer
+		t.Error("timout")
+	}
+}
+
 func TestDownload(t *testing.T) {
 	glog.SetV(logger.Detail)
 	glog.SetToStderr(true)
 
-	hashes := createHashes(0, 1000)
-	blocks := createBlocksFromHashes(hashes)
-	tester := newTester(t, hashes, blocks)
+	chain := createChain(1000, common.Hash{})
+	tester := newTester(t, chain)
 
-	tester.newPeer("peer1", big.NewInt(10000), hashes[0])
+	tester.newPeer("peer1", big.NewInt(10000), chain[0].Hash())
 	tester.newPeer("peer2", big.NewInt(0), common.Hash{})
 	tester.badBlocksPeer("peer3", big.NewInt(0), common.Hash{})
 	tester.badBlocksPeer("peer4", big.NewInt(0), common.Hash{})
 
-success:
-	select {
-	case <-tester.done:
-		break success
-	case <-time.After(10 * time.Second): // XXX this could actually fail on a slow computer
-		t.Error("timout")
+	tester.wait(t)
+}
+
+func TestDownloadBadBodies(t *testing.T) {
+	chain := createChain(1000, common.Hash{})
+	tester := newTester(t, chain)
+
+	tester.newPeer("peer1", big.NewInt(10000), chain[0].Hash())
+	tester.badBodiesPeer("peer2", big.NewInt(0), common.Hash{})
+	tester.badBodiesPeer("peer3", big.NewInt(0), common.Hash{})
+	tester.newPeer("peer4", big.NewInt(0), common.Hash{})
+
+	tester.wait(t)
+
+	if tester.downloader.peers.getPeer("peer2") != nil || tester.downloader.peers.getPeer("peer3") != nil {
+		t.Error("peers delivering invalid bodies weren't dropped")
+	}
+}
+
+// Tests that a block hash announced by a peer triggers synchronisation with
+// it, even if the peer's reported total difficulty wouldn't.
+func TestAnnounceBlock(t *testing.T) {
+	chain := createChain(100, common.Hash{})
+	tester := newTester(t, chain)
+
+	tester.newPeer("peer1", big.NewInt(0), common.Hash{})
+	tester.downloader.AnnounceBlock("peer1", chain[0].Hash())
+
+	tester.wait(t)
+}
+
+func TestQueueEmptyBodies(t *testing.T) {
+	q := newqueue()
+	header := &types.Header{Number: big.NewInt(1), TxHash: emptyTxRoot, UncleHash: emptyUncleHash}
+	q.putHeaders([]*types.Header{header})
+
+	if q.hashPool.Size() != 0 {
+		t.Errorf("empty body scheduled for fetching")
+	}
+	if len(q.blocks) != 1 || q.blocks[0].Hash() != header.Hash() {
+		t.Errorf("block with empty body not assembled")
+	}
+}
+
+func TestInvalidHeaderChain(t *testing.T) {
+	chain := createChain(10, common.Hash{})
+	tester := newTester(t, chain)
+
+	// This bad peer delivers a header chain with a gap in it
+	tester.downloader.RegisterPeer("peer1", big.NewInt(10000), chain[0].Hash(), func(common.Hash) error {
+		go tester.downloader.DeliverHeaders("peer1", []*types.Header{chain[0].Header(), chain[2].Header()})
+		return nil
+	}, tester.getBodies("peer1"), nil, nil)
+	peer := tester.downloader.peers.getPeer("peer1")
+
+	if err := tester.downloader.startFetchingHeaders(peer, chain[0].Hash()); err != errBadHeaderChain {
+		t.Errorf("expected %v, got %v", errBadHeaderChain, err)
+	}
+}
+
+// checkFastSync verifies that a fast sync of chain imported the blocks up to
+// the pivot through the receipt chain, committed the pivot as head only after
+// its state was complete and fully processed the blocks after it.
+func checkFastSync(t *testing.T, tester *downloadTester, chain []*types.Block, srcDb *ethdb.MemDatabase) {
+	pivot := chain[fsMinFullBlocks]
+	if len(tester.receiptBlocks) != len(chain)-1-fsMinFullBlocks {
+		t.Fatalf("receipt chain length mismatch: have %d, want %d", len(tester.receiptBlocks), len(chain)-1-fsMinFullBlocks)
+	}
+	if last := tester.receiptBlocks[len(tester.receiptBlocks)-1]; last.Hash() != pivot.Hash() {
+		t.Errorf("receipt chain head mismatch: have %x, want %x", last.Hash(), pivot.Hash())
+	}
+	if tester.headCommitted != pivot.Hash() {
+		t.Errorf("committed head mismatch: have %x, want %x", tester.headCommitted, pivot.Hash())
+	}
+	if len(tester.chainBlocks) != fsMinFullBlocks {
+		t.Errorf("processed block count mismatch: have %d, want %d", len(tester.chainBlocks), fsMinFullBlocks)
+	}
+	for _, block := range tester.chainBlocks {
+		if block.NumberU64() <= pivot.NumberU64() {
+			t.Errorf("block #%d before the pivot processed", block.NumberU64())
+		}
+	}
+	// All non empty state entries must have been retrieved before the commit.
+	// Only hash keyed entries are part of the state, the rest (e.g. secure
+	// trie key preimages) is local metadata.
+	want := 0
+	for _, key := range srcDb.Keys() {
+		if data, _ := srcDb.Get(key); len(key) == len(common.Hash{}) && len(data) > 0 {
+			want++
+			if have, _ := tester.dstDb.Get(key); len(have) == 0 {
+				t.Errorf("state entry %x missing", key)
+			}
+		}
+	}
+	if tester.committedStates < want {
+		t.Errorf("head committed with incomplete state: have %d entries, want %d", tester.committedStates, want)
+	}
+}
+
+func TestFastSync(t *testing.T) {
+	srcDb, _ := ethdb.NewMemDatabase()
+	root := core.MakeState(srcDb)
+	chain := createChain(300, root)
+	tester := newModeTester(t, FastSync, chain, srcDb)
+
+	tester.newFastPeer("peer1", big.NewInt(10000), chain[0].Hash())
+	tester.newFastPeer("peer2", big.NewInt(0), common.Hash{})
+	tester.newPeer("peer3", big.NewInt(0), common.Hash{})
+
+	tester.wait(t)
+	checkFastSync(t, tester, chain, srcDb)
+}
+
+func TestFastSyncBadState(t *testing.T) {
+	srcDb, _ := ethdb.NewMemDatabase()
+	root := core.MakeState(srcDb)
+	chain := createChain(300, root)
+	tester := newModeTester(t, FastSync, chain, srcDb)
+
+	tester.newFastPeer("peer1", big.NewInt(10000), chain[0].Hash())
+	tester.badStatePeer("peer2", big.NewInt(0), common.Hash{})
+	tester.badStatePeer("peer3", big.NewInt(0), common.Hash{})
+	tester.newFastPeer("peer4", big.NewInt(0), common.Hash{})
+
+	tester.wait(t)
+	checkFastSync(t, tester, chain, srcDb)
+
+	// Depending on scheduling the good peers may serve all of the state
+	tester.lock.Lock()
+	defer tester.lock.Unlock()
+	for id := range tester.badServers {
+		if tester.downloader.peers.getPeer(id) != nil {
+			t.Errorf("peer %s delivering invalid state wasn't dropped", id)
+		}
+	}
+}
+
+// Tests that fast sync falls back to full processing if the remote chain is
+// too short to pick a pivot from.
+func TestFastSyncShortChain(t *testing.T) {
+	chain := createChain(fsMinFullBlocks, common.Hash{})
+	tester := newModeTester(t, FastSync, chain, nil)
+
+	tester.newFastPeer("peer1", big.NewInt(10000), chain[0].Hash())
+	tester.newFastPeer("peer2", big.NewInt(0), common.Hash{})
+	tester.newFastPeer("peer3", big.NewInt(0), common.Hash{})
+
+	tester.wait(t)
+
+	if len(tester.receiptBlocks) != 0 || tester.headCommitted != (common.Hash{}) {
+		t.Errorf("short chain fast synced")
+	}
+	if len(tester.chainBlocks) != fsMinFullBlocks {
+		t.Errorf("processed block count mismatch: have %d, want %d", len(tester.chainBlocks), fsMinFullBlocks)
+	}
+}
+
+func TestQueueInvalidReceipts(t *testing.T) {
+	chain := createChain(2, common.Hash{})
+	q := newqueue()
+	q.putReceipts([]*types.Header{chain[0].Header(), chain[1].Header()})
+
+	p := newPeer("peer", big.NewInt(0), common.Hash{}, nil, nil, nil, nil)
+	q.getReceipts(p, maxReceiptFetch)
+	if err := q.deliverReceipts(p.id, [][]*types.Receipt{nil, nil}); err != errInvalidReceipts {
+		t.Errorf("expected %v, got %v", errInvalidReceipts, err)
+	}
+	if q.receiptPool.Size() != 2 {
+		t.Errorf("undelivered receipts not rescheduled: have %d, want %d", q.receiptPool.Size(), 2)
+	}
+}
+
+func TestQueueInvalidNodeData(t *testing.T) {
+	q := newqueue()
+	q.putState([]common.Hash{common.BytesToHash(crypto.Sha3([]byte{1})), common.BytesToHash(crypto.Sha3([]byte{2}))})
+
+	p := newPeer("peer", big.NewInt(0), common.Hash{}, nil, nil, nil, nil)
+	q.getState(p, maxStateFetch)
+	results, err := q.deliverState(p.id, [][]byte{{1}, {3}})
+	if err != errInvalidNodeData {
+		t.Errorf("expected %v, got %v", errInvalidNodeData, err)
+	}
+	if len(results) != 1 || results[0].Hash != common.BytesToHash(crypto.Sha3([]byte{1})) {
+		t.Errorf("valid entries not returned: %v", results)
+	}
+	if q.statePool.Size() != 1 || !q.statePool.Has(common.BytesToHash(crypto.Sha3([]byte{2}))) {
+		t.Errorf("undelivered entries not rescheduled")
 	}
 }
 
@@ -133,23 +442,13 @@ func TestMissing(t *testing.T) {
 	glog.SetV(logger.Detail)
 	glog.SetToStderr(true)
 
-	hashes := createHashes(0, 1000)
-	extraHashes := createHashes(1001, 1003)
-	blocks := createBlocksFromHashes(append(extraHashes, hashes...))
-	tester := newTester(t, hashes, blocks)
-
-	tester.newPeer("peer1", big.NewInt(10000), hashes[len(hashes)-1])
+	chain := createChain(1000, common.Hash{})
+	tester := newTester(t, chain)
 
-	hashes = append(extraHashes, hashes[:len(hashes)-1]...)
+	tester.newPeer("peer1", big.NewInt(10000), chain[1].Hash())
 	tester.newPeer("peer2", big.NewInt(0), common.Hash{})
 
-success1:
-	select {
-	case <-tester.done:
-		break success1
-	case <-time.After(10 * time.Second): // XXX this could actually fail on a slow computer
-		t.Error("timout")
-	}
+	tester.wait(t)
 
-	tester.downloader.AddBlock("peer2", blocks[hashes[len(hashes)-1]], big.NewInt(10001))
+	tester.downloader.AddBlock("peer2", chain[0], big.NewInt(10001))
 }
@@ -13,8 +13,10 @@ const (
 	idleState    = 4
 )
 
-type hashFetcherFn func(common.Hash) error
-type blockFetcherFn func([]common.Hash) error
+type headerFetcherFn func(common.Hash) error
+type bodyFetcherFn func([]common.Hash) error
+type receiptFetcherFn func([]common.Hash) error
+type stateFetcherFn func([]common.Hash) error
 
 // XXX make threadsafe!!!!
 type peers map[string]*peer
@@ -44,6 +46,28 @@ func (p peers) getPeer(id string) *peer {
 	return p[id]
 }
 
+// fastPeers returns the idle peers able to serve receipts and state data.
+func (p peers) fastPeers() []*peer {
+	var peers []*peer
+	for _, peer := range p.get(idleState) {
+		if peer.fastSync() {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// canFastSync reports whether any of the peers is able to serve fast sync
+// data.
+func (p peers) canFastSync() bool {
+	for _, peer := range p {
+		if peer.fastSync() {
+			return true
+		}
+	}
+	return false
+}
+
 func (p peers) bestPeer() *peer {
 	var peer *peer
 	for _, cp := range p {
@@ -64,34 +88,60 @@ type peer struct {
 	td         *big.Int
 	recentHash common.Hash
 
-	getHashes hashFetcherFn
-	getBlocks blockFetcherFn
+	getHeaders  headerFetcherFn
+	getBodies   bodyFetcherFn
+	getReceipts receiptFetcherFn // nil if the peer can't serve fast sync data
+	getNodeData stateFetcherFn   // nil if the peer can't serve fast sync data
 }
 
 // create a new peer
-func newPeer(id string, td *big.Int, hash common.Hash, getHashes hashFetcherFn, getBlocks blockFetcherFn) *peer {
-	return &peer{id: id, td: td, recentHash: hash, getHashes: getHashes, getBlocks: getBlocks, state: idleState}
+func newPeer(id string, td *big.Int, hash common.Hash, getHeaders headerFetcherFn, getBodies bodyFetcherFn, getReceipts receiptFetcherFn, getNodeData stateFetcherFn) *peer {
+	return &peer{
+		id:          id,
+		td:          td,
+		recentHash:  hash,
+		getHeaders:  getHeaders,
+		getBodies:   getBodies,
+		getReceipts: getReceipts,
+		getNodeData: getNodeData,
+		state:       idleState,
+	}
+}
+
+// fastSync reports whether the peer is able to serve receipts and state data.
+func (p *peer) fastSync() bool {
+	return p.getReceipts != nil && p.getNodeData != nil
 }
 
-// fetch a chunk using the peer
+// fetch requests the bodies of a chunk using the peer
 func (p *peer) fetch(chunk *chunk) error {
+	return p.request(chunk, p.getBodies)
+}
+
+// fetchReceipts requests the receipts of a chunk of blocks using the peer
+func (p *peer) fetchReceipts(chunk *chunk) error {
+	return p.request(chunk, p.getReceipts)
+}
+
+// fetchNodeData requests a chunk of state trie nodes and code using the peer
+func (p *peer) fetchNodeData(chunk *chunk) error {
+	return p.request(chunk, p.getNodeData)
+}
+
+func (p *peer) request(chunk *chunk, fetcher func([]common.Hash) error) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.state == workingState {
 		return errors.New("peer already fetching chunk")
 	}
+	if fetcher == nil {
+		return errors.New("peer can't serve request")
+	}
 
 	// set working state
 	p.state = workingState
-	// convert the set to a fetchable slice
-	hashes, i := make([]common.Hash, chunk.hashes.Size()), 0
-	chunk.hashes.Each(func(v interface{}) bool {
-		hashes[i] = v.(common.Hash)
-		i++
-		return true
-	})
-	p.getBlocks(hashes)
+	fetcher(chunk.hashes)
 
 	return nil
 }
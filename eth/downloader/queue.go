@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"errors"
 	"math"
 	"math/big"
 	"sync"
@@ -8,55 +9,112 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
 	"gopkg.in/fatih/set.v0"
 )
 
-// queue represents hashes that are either need fetching or are being fetched
+var (
+	errInvalidBody     = errors.New("block body doesn't match header")
+	errInvalidReceipts = errors.New("block receipts don't match header")
+	errInvalidNodeData = errors.New("unrequested state node data")
+
+	emptyTxRoot    = types.DeriveSha(types.Transactions{})
+	emptyUncleHash = types.CalcUncleHash(nil)
+)
+
+// queue represents headers whose block bodies either need fetching or are
+// being fetched. During fast sync it also tracks the blocks whose receipts
+// and the state entries that need fetching.
 type queue struct {
 	hashPool    *set.Set
 	fetchPool   *set.Set
 	blockHashes *set.Set
 
+	receiptPool *set.Set // Hashes of the blocks whose receipts need fetching
+	statePool   *set.Set // Hashes of the state entries that need fetching
+
 	mu       sync.Mutex
+	headers  map[common.Hash]*types.Header
 	fetching map[string]*chunk
 	blocks   []*types.Block
+
+	receiptHeaders  map[common.Hash]*types.Header  // Headers of the blocks pending receipts
+	receiptFetching map[string]*chunk              // Receipt chunks being fetched by peer
+	receipts        map[common.Hash]types.Receipts // Receipts retrieved for fast sync
+
+	stateFetching map[string]*chunk // State chunks being fetched by peer
 }
 
 func newqueue() *queue {
 	return &queue{
-		hashPool:    set.New(),
-		fetchPool:   set.New(),
-		blockHashes: set.New(),
-		fetching:    make(map[string]*chunk),
+		hashPool:        set.New(),
+		fetchPool:       set.New(),
+		blockHashes:     set.New(),
+		receiptPool:     set.New(),
+		statePool:       set.New(),
+		headers:         make(map[common.Hash]*types.Header),
+		fetching:        make(map[string]*chunk),
+		receiptHeaders:  make(map[common.Hash]*types.Header),
+		receiptFetching: make(map[string]*chunk),
+		receipts:        make(map[common.Hash]types.Receipts),
+		stateFetching:   make(map[string]*chunk),
 	}
 }
 
-// reserve a `max` set of hashes for `p` peer.
-func (c *queue) get(p *peer, max int) *chunk {
+// reset clears out the queue contents, dropping all pending tasks and any
+// downloaded but not yet processed data.
+func (c *queue) reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// return nothing if the pool has been depleted
-	if c.hashPool.Size() == 0 {
-		return nil
-	}
+	c.hashPool.Clear()
+	c.fetchPool.Clear()
+	c.blockHashes.Clear()
+	c.receiptPool.Clear()
+	c.statePool.Clear()
 
-	limit := int(math.Min(float64(max), float64(c.hashPool.Size())))
+	c.headers = make(map[common.Hash]*types.Header)
+	c.fetching = make(map[string]*chunk)
+	c.blocks = nil
+	c.receiptHeaders = make(map[common.Hash]*types.Header)
+	c.receiptFetching = make(map[string]*chunk)
+	c.receipts = make(map[common.Hash]types.Receipts)
+	c.stateFetching = make(map[string]*chunk)
+}
+
+// reserve takes up to max hashes out of pool. The caller must hold the lock.
+func reserve(pool *set.Set, max int) []common.Hash {
+	limit := int(math.Min(float64(max), float64(pool.Size())))
 	// Create a new set of hashes
-	hashes, i := set.New(), 0
-	c.hashPool.Each(func(v interface{}) bool {
-		if i == limit {
+	hashes := make([]common.Hash, 0, limit)
+	pool.Each(func(v interface{}) bool {
+		if len(hashes) == limit {
 			return false
 		}
-
-		hashes.Add(v)
-		i++
+		hashes = append(hashes, v.(common.Hash))
 
 		return true
 	})
-	// remove the fetchable hashes from hash pool
-	c.hashPool.Separate(hashes)
-	c.fetchPool.Merge(hashes)
+	for _, hash := range hashes {
+		pool.Remove(hash)
+	}
+	return hashes
+}
+
+// reserve a `max` set of hashes for `p` peer.
+func (c *queue) get(p *peer, max int) *chunk {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// return nothing if the pool has been depleted
+	if c.hashPool.Size() == 0 {
+		return nil
+	}
+	hashes := reserve(c.hashPool, max)
+	for _, hash := range hashes {
+		c.fetchPool.Add(hash)
+	}
 
 	// Create a new chunk for the seperated hashes. The time is being used
 	// to reset the chunk (timeout)
@@ -68,6 +126,35 @@ func (c *queue) get(p *peer, max int) *chunk {
 	return chunk
 }
 
+// getReceipts reserves a `max` set of block hashes whose receipts `p` peer
+// should fetch.
+func (c *queue) getReceipts(p *peer, max int) *chunk {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.receiptPool.Size() == 0 {
+		return nil
+	}
+	chunk := &chunk{reserve(c.receiptPool, max), time.Now()}
+	c.receiptFetching[p.id] = chunk
+
+	return chunk
+}
+
+// getState reserves a `max` set of state entry hashes for `p` peer.
+func (c *queue) getState(p *peer, max int) *chunk {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.statePool.Size() == 0 {
+		return nil
+	}
+	chunk := &chunk{reserve(c.statePool, max), time.Now()}
+	c.stateFetching[p.id] = chunk
+
+	return chunk
+}
+
 func (c *queue) has(hash common.Hash) bool {
 	return c.hashPool.Has(hash) || c.fetchPool.Has(hash)
 }
@@ -79,52 +166,187 @@ func (c *queue) addBlock(id string, block *types.Block, td *big.Int) {
 	// when adding a block make sure it doesn't already exist
 	if !c.blockHashes.Has(block.Hash()) {
 		c.hashPool.Remove(block.Hash())
+		delete(c.headers, block.Hash())
+		c.blockHashes.Add(block.Hash())
 		c.blocks = append(c.blocks, block)
 	}
 }
 
-// deliver delivers a chunk to the queue that was requested of the peer
-func (c *queue) deliver(id string, blocks []*types.Block) {
+// putHeaders schedules the bodies of the given (validated) headers for
+// fetching. Headers of blocks without transactions and uncles are turned
+// into blocks right away as there is nothing to download.
+func (c *queue) putHeaders(headers []*types.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, header := range headers {
+		hash := header.Hash()
+		if c.blockHashes.Has(hash) || c.fetchPool.Has(hash) {
+			continue
+		}
+		if header.TxHash == emptyTxRoot && header.UncleHash == emptyUncleHash {
+			c.blockHashes.Add(hash)
+			c.blocks = append(c.blocks, types.NewBlockWithHeader(header))
+			continue
+		}
+		c.headers[hash] = header
+		c.hashPool.Add(hash)
+	}
+}
+
+// deliver delivers the block bodies requested of the peer. Bodies are
+// matched to the requested headers in request order and checked against
+// the header's transaction root and uncle hash. Any hash that couldn't be
+// delivered is put back into the pool. An error is returned if the peer
+// delivered a body that doesn't belong to the header it was requested for.
+func (c *queue) deliver(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) (err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	chunk := c.fetching[id]
 	// If the chunk was never requested simply ignore it
-	if chunk != nil {
-		delete(c.fetching, id)
+	if chunk == nil {
+		return nil
+	}
+	delete(c.fetching, id)
 
-		// seperate the blocks and the hashes
-		blockHashes := chunk.fetchedHashes(blocks)
-		// merge block hashes
-		c.blockHashes.Merge(blockHashes)
-		// Add the blocks
-		c.blocks = append(c.blocks, blocks...)
+	delivered := 0
+	for i, hash := range chunk.hashes {
+		if i >= len(transactions) || i >= len(uncles) {
+			break
+		}
+		header := c.headers[hash]
+		if header == nil {
+			// the block arrived in the meantime (e.g. via NewBlockMsg)
+			delivered++
+			continue
+		}
+		if types.DeriveSha(types.Transactions(transactions[i])) != header.TxHash || types.CalcUncleHash(uncles[i]) != header.UncleHash {
+			err = errInvalidBody
+			break
+		}
+		c.blockHashes.Add(hash)
+		c.blocks = append(c.blocks, types.NewBlockWithHeader(header).WithBody(transactions[i], uncles[i]))
+		delete(c.headers, hash)
+		delivered++
+	}
 
-		// Add back whatever couldn't be delivered
-		c.hashPool.Merge(chunk.hashes)
-		c.fetchPool.Separate(chunk.hashes)
+	// Add back whatever couldn't be delivered
+	for i, hash := range chunk.hashes {
+		c.fetchPool.Remove(hash)
+		if i >= delivered && !c.blockHashes.Has(hash) {
+			c.hashPool.Add(hash)
+		}
 	}
+	return err
 }
 
-// puts puts sets of hashes on to the queue for fetching
-func (c *queue) put(hashes *set.Set) {
+// putReceipts schedules the receipts of the given (validated) headers for
+// fetching. Blocks without transactions have no receipts to download.
+func (c *queue) putReceipts(headers []*types.Header) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.hashPool.Merge(hashes)
+	for _, header := range headers {
+		hash := header.Hash()
+		if _, ok := c.receipts[hash]; ok {
+			continue
+		}
+		if header.ReceiptHash == emptyTxRoot {
+			c.receipts[hash] = types.Receipts{}
+			continue
+		}
+		c.receiptHeaders[hash] = header
+		c.receiptPool.Add(hash)
+	}
 }
 
-type chunk struct {
-	hashes *set.Set
-	itime  time.Time
+// deliverReceipts delivers the block receipts requested of the peer. Like
+// bodies the receipts are matched in request order and checked against the
+// receipt root of the header.
+func (c *queue) deliverReceipts(id string, receipts [][]*types.Receipt) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chunk := c.receiptFetching[id]
+	// If the chunk was never requested simply ignore it
+	if chunk == nil {
+		return nil
+	}
+	delete(c.receiptFetching, id)
+
+	delivered := 0
+	for i, hash := range chunk.hashes {
+		if i >= len(receipts) {
+			break
+		}
+		header := c.receiptHeaders[hash]
+		if types.DeriveSha(types.Receipts(receipts[i])) != header.ReceiptHash {
+			err = errInvalidReceipts
+			break
+		}
+		c.receipts[hash] = receipts[i]
+		delete(c.receiptHeaders, hash)
+		delivered++
+	}
+
+	// Add back whatever couldn't be delivered
+	for _, hash := range chunk.hashes[delivered:] {
+		c.receiptPool.Add(hash)
+	}
+	return err
+}
+
+// putState schedules the given state entry hashes for fetching.
+func (c *queue) putState(hashes []common.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, hash := range hashes {
+		c.statePool.Add(hash)
+	}
 }
 
-func (ch *chunk) fetchedHashes(blocks []*types.Block) *set.Set {
-	fhashes := set.New()
-	for _, block := range blocks {
-		fhashes.Add(block.Hash())
+// deliverState delivers the state entries requested of the peer. Entries are
+// matched by their hash, any entry not requested is an error. Hashes that
+// weren't delivered are put back into the pool.
+func (c *queue) deliverState(id string, data [][]byte) ([]trie.SyncResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chunk := c.stateFetching[id]
+	// If the chunk was never requested simply ignore it
+	if chunk == nil {
+		return nil, nil
+	}
+	delete(c.stateFetching, id)
+
+	pending := make(map[common.Hash]bool)
+	for _, hash := range chunk.hashes {
+		pending[hash] = true
+	}
+	var (
+		results []trie.SyncResult
+		err     error
+	)
+	for _, blob := range data {
+		hash := common.BytesToHash(crypto.Sha3(blob))
+		if !pending[hash] {
+			err = errInvalidNodeData
+			break
+		}
+		results = append(results, trie.SyncResult{Hash: hash, Data: blob})
+		delete(pending, hash)
+	}
+
+	// Add back whatever couldn't be delivered
+	for hash := range pending {
+		c.statePool.Add(hash)
 	}
-	ch.hashes.Separate(fhashes)
+	return results, err
+}
 
-	return fhashes
+type chunk struct {
+	hashes []common.Hash
+	itime  time.Time
 }
@@ -0,0 +1,169 @@
+package eth
+
+import (
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+	maxKnownTxs    = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
+	maxKnownBlocks = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
+)
+
+// markTransaction marks a transaction as known for the peer, ensuring that it
+// will never be propagated to this particular peer.
+func (self *ethProtocol) markTransaction(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known transaction hash
+	for self.knownTxs.Size() >= maxKnownTxs {
+		self.knownTxs.Pop()
+	}
+	self.knownTxs.Add(hash)
+}
+
+// markBlock marks a block as known for the peer, ensuring that it will never be
+// propagated to this particular peer.
+func (self *ethProtocol) markBlock(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known block hash
+	for self.knownBlocks.Size() >= maxKnownBlocks {
+		self.knownBlocks.Pop()
+	}
+	self.knownBlocks.Add(hash)
+}
+
+// sendTransactions sends transactions to the peer and includes the hashes
+// in its transaction hash set for future reference.
+func (self *ethProtocol) sendTransactions(txs types.Transactions) error {
+	for _, tx := range txs {
+		self.markTransaction(tx.Hash())
+	}
+	return p2p.Send(self.rw, TxMsg, txs)
+}
+
+// sendNewBlock propagates an entire block to the peer.
+func (self *ethProtocol) sendNewBlock(block *types.Block, td *big.Int) error {
+	self.markBlock(block.Hash())
+	return p2p.Send(self.rw, NewBlockMsg, &newBlockMsgData{block, td})
+}
+
+// sendNewBlockHashes announces the availability of a number of blocks through
+// a hash notification.
+func (self *ethProtocol) sendNewBlockHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		self.markBlock(hash)
+	}
+	return p2p.Send(self.rw, NewBlockHashesMsg, hashes)
+}
+
+// peerSet represents the collection of active peers currently participating in
+// the eth protocol, used to propagate transactions and blocks only to the
+// peers not yet knowing about them.
+type peerSet struct {
+	peers map[string]*ethProtocol
+	lock  sync.RWMutex
+}
+
+// newPeerSet creates a new peer set to track the active participants.
+func newPeerSet() *peerSet {
+	return &peerSet{
+		peers: make(map[string]*ethProtocol),
+	}
+}
+
+// register injects a new peer into the working set.
+func (ps *peerSet) register(p *ethProtocol) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.peers[p.id] = p
+}
+
+// unregister removes a remote peer from the active set.
+func (ps *peerSet) unregister(id string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	delete(ps.peers, id)
+}
+
+// len returns the current number of peers in the set.
+func (ps *peerSet) len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
+
+// peersWithoutTx retrieves a list of peers that do not have a given transaction
+// in their set of known hashes.
+func (ps *peerSet) peersWithoutTx(hash common.Hash) []*ethProtocol {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*ethProtocol, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownTxs.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// peersWithoutBlock retrieves a list of peers that do not have a given block
+// in their set of known hashes.
+func (ps *peerSet) peersWithoutBlock(hash common.Hash) []*ethProtocol {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*ethProtocol, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownBlocks.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// broadcastTransactions sends a batch of transactions to every peer, leaving
+// out the ones a peer already knows about.
+func (ps *peerSet) broadcastTransactions(txs types.Transactions) {
+	batches := make(map[*ethProtocol]types.Transactions)
+	for _, tx := range txs {
+		for _, p := range ps.peersWithoutTx(tx.Hash()) {
+			batches[p] = append(batches[p], tx)
+		}
+	}
+	for p, batch := range batches {
+		if err := p.sendTransactions(batch); err != nil {
+			glog.V(logger.Debug).Infof("peer %s: failed to send %d transactions: %v", p.id, len(batch), err)
+		}
+	}
+}
+
+// broadcastBlock propagates a new block to the peers not knowing about it: the
+// full block is pushed to the square root of them, the rest only get a hash
+// announcement and may retrieve the block if they need it. Peers unable to
+// handle announcements always receive the full block.
+func (ps *peerSet) broadcastBlock(block *types.Block, td *big.Int) {
+	hash := block.Hash()
+	peers := ps.peersWithoutBlock(hash)
+
+	transfer := int(math.Sqrt(float64(len(peers))))
+	for i, p := range peers {
+		var err error
+		if i < transfer || !p.hashAnnounce() {
+			err = p.sendNewBlock(block, td)
+		} else {
+			err = p.sendNewBlockHashes([]common.Hash{hash})
+		}
+		if err != nil {
+			glog.V(logger.Debug).Infof("peer %s: failed to propagate block %x: %v", p.id, hash[:4], err)
+		}
+	}
+}
@@ -11,17 +11,41 @@ import (
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/fatih/set.v0"
 )
 
 const (
-	ProtocolVersion    = 60
+	ProtocolVersion    = 63
 	NetworkId          = 0
-	ProtocolLength     = uint64(8)
 	ProtocolMaxMsgSize = 10 * 1024 * 1024
 	maxHashes          = 256
 	maxBlocks          = 64
+	maxHeaders         = 512
+	maxBodies          = 128
+	maxReceipts        = 256
+	maxNodeData        = 384
+
+	// headerSyncVersion is the first protocol version supporting header
+	// first synchronisation through the downloader.
+	headerSyncVersion = 61
+
+	// fastSyncVersion is the first protocol version able to serve the
+	// receipts and state data needed for fast synchronisation.
+	fastSyncVersion = 62
+
+	// hashAnnounceVersion is the first protocol version accepting new
+	// block announcements by hash instead of the full block.
+	hashAnnounceVersion = 63
 )
 
+// emptyReceiptRoot is the receipt root of blocks without transactions, which
+// need no stored receipts to be served.
+var emptyReceiptRoot = types.DeriveSha(types.Receipts{})
+
+// ProtocolLengths are the number of implemented message codes corresponding
+// to the different protocol versions.
+var ProtocolLengths = map[int]uint64{60: 8, 61: 12, 62: 16, 63: 17}
+
 // eth protocol message codes
 const (
 	StatusMsg = iota
@@ -32,6 +56,15 @@ const (
 	GetBlocksMsg
 	BlocksMsg
 	NewBlockMsg
+	GetBlockHeadersMsg
+	BlockHeadersMsg
+	GetBlockBodiesMsg
+	BlockBodiesMsg
+	GetNodeDataMsg
+	NodeDataMsg
+	GetReceiptsMsg
+	ReceiptsMsg
+	NewBlockHashesMsg
 )
 
 const (
@@ -64,12 +97,17 @@ type ethProtocol struct {
 	txPool          txPool
 	chainManager    chainManager
 	blockPool       blockPool
+	downloader      headerDownloader
+	peers           *peerSet
 	peer            *p2p.Peer
 	id              string
 	rw              p2p.MsgReadWriter
 	errors          *errs.Errors
 	protocolVersion int
 	networkId       int
+
+	knownTxs    *set.Set // Set of transaction hashes known to be known by this peer
+	knownBlocks *set.Set // Set of block hashes known to be known by this peer
 }
 
 // backend is the interface the ethereum protocol backend should implement
@@ -81,7 +119,10 @@ type txPool interface {
 
 type chainManager interface {
 	GetBlockHashesFromHash(hash common.Hash, amount uint64) (hashes []common.Hash)
+	GetHeadersFromHash(hash common.Hash, amount uint64) (headers []*types.Header)
 	GetBlock(hash common.Hash) (block *types.Block)
+	GetBlockReceipts(hash common.Hash) types.Receipts
+	GetNodeData(hash common.Hash) []byte
 	Status() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash)
 }
 
@@ -92,6 +133,20 @@ type blockPool interface {
 	RemovePeer(peerId string)
 }
 
+// headerDownloader synchronises the chain header first with peers running
+// protocol version 61 or later, fast syncing with peers running version 62
+// or later
+type headerDownloader interface {
+	RegisterPeer(id string, td *big.Int, hash common.Hash, getHeaders func(common.Hash) error, getBodies func([]common.Hash) error, getReceipts func([]common.Hash) error, getNodeData func([]common.Hash) error) error
+	UnregisterPeer(id string)
+	AddBlock(id string, block *types.Block, td *big.Int)
+	AnnounceBlock(id string, hash common.Hash)
+	DeliverHeaders(id string, headers []*types.Header)
+	DeliverBodies(id string, transactions [][]*types.Transaction, uncles [][]*types.Header)
+	DeliverReceipts(id string, receipts [][]*types.Receipt)
+	DeliverNodeData(id string, data [][]byte)
+}
+
 // message structs used for RLP serialization
 type newBlockMsgData struct {
 	Block *types.Block
@@ -103,6 +158,19 @@ type getBlockHashesMsgData struct {
 	Amount uint64
 }
 
+// getBlockHeadersMsgData requests the header of the block with the given
+// hash followed by the headers of its ancestors
+type getBlockHeadersMsgData struct {
+	Hash   common.Hash
+	Amount uint64
+}
+
+// blockBodyMsgData is the network encoding of a block's body
+type blockBodyMsgData struct {
+	Transactions []*types.Transaction
+	Uncles       []*types.Header
+}
+
 type statusMsgData struct {
 	ProtocolVersion uint32
 	NetworkId       uint32
@@ -114,25 +182,30 @@ type statusMsgData struct {
 // main entrypoint, wrappers starting a server running the eth protocol
 // use this constructor to attach the protocol ("class") to server caps
 // the Dev p2p layer then runs the protocol instance on each peer
-func EthProtocol(protocolVersion, networkId int, txPool txPool, chainManager chainManager, blockPool blockPool) p2p.Protocol {
+// peers running protocol version 61 or later are synchronised with the
+// downloader, older ones with the block pool
+// the running peers are tracked in peers for block and transaction propagation
+func EthProtocol(protocolVersion, networkId int, txPool txPool, chainManager chainManager, blockPool blockPool, downloader headerDownloader, peers *peerSet) p2p.Protocol {
 	return p2p.Protocol{
 		Name:    "eth",
 		Version: uint(protocolVersion),
-		Length:  ProtocolLength,
+		Length:  ProtocolLengths[protocolVersion],
 		Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
-			return runEthProtocol(protocolVersion, networkId, txPool, chainManager, blockPool, peer, rw)
+			return runEthProtocol(protocolVersion, networkId, txPool, chainManager, blockPool, downloader, peers, peer, rw)
 		},
 	}
 }
 
 // the main loop that handles incoming messages
-// note RemovePeer in the post-disconnect hook
-func runEthProtocol(protocolVersion, networkId int, txPool txPool, chainManager chainManager, blockPool blockPool, peer *p2p.Peer, rw p2p.MsgReadWriter) (err error) {
+// note RemovePeer/UnregisterPeer in the post-disconnect hook
+func runEthProtocol(protocolVersion, networkId int, txPool txPool, chainManager chainManager, blockPool blockPool, downloader headerDownloader, peers *peerSet, peer *p2p.Peer, rw p2p.MsgReadWriter) (err error) {
 	id := peer.ID()
 	self := &ethProtocol{
 		txPool:          txPool,
 		chainManager:    chainManager,
 		blockPool:       blockPool,
+		downloader:      downloader,
+		peers:           peers,
 		rw:              rw,
 		peer:            peer,
 		protocolVersion: protocolVersion,
@@ -141,18 +214,27 @@ func runEthProtocol(protocolVersion, networkId int, txPool txPool, chainManager
 			Package: "ETH",
 			Errors:  errorToString,
 		},
-		id: fmt.Sprintf("%x", id[:8]),
+		id:          fmt.Sprintf("%x", id[:8]),
+		knownTxs:    set.New(),
+		knownBlocks: set.New(),
 	}
 
 	// handshake.
 	if err := self.handleStatus(); err != nil {
 		return err
 	}
-	defer self.blockPool.RemovePeer(self.id)
+	if self.headerSync() {
+		defer self.downloader.UnregisterPeer(self.id)
+	} else {
+		defer self.blockPool.RemovePeer(self.id)
+	}
+
+	self.peers.register(self)
+	defer self.peers.unregister(self.id)
 
 	// propagate existing transactions. new transactions appearing
 	// after this will be sent via broadcasts.
-	if err := p2p.Send(rw, TxMsg, txPool.GetTransactions()); err != nil {
+	if err := self.sendTransactions(txPool.GetTransactions()); err != nil {
 		return err
 	}
 
@@ -190,6 +272,7 @@ func (self *ethProtocol) handle() error {
 			if tx == nil {
 				return self.protoError(ErrDecode, "transaction %d is nil", i)
 			}
+			self.markTransaction(tx.Hash())
 			jsonlogger.LogJson(&logger.EthTxReceived{
 				TxHash:   tx.Hash().Hex(),
 				RemoteId: self.peer.ID().String(),
@@ -275,6 +358,7 @@ func (self *ethProtocol) handle() error {
 			if err := block.ValidateFields(); err != nil {
 				return self.protoError(ErrDecode, "block validation %v: %v", msg, err)
 			}
+			self.markBlock(block.Hash())
 			self.blockPool.AddBlock(&block, self.id)
 		}
 
@@ -287,6 +371,7 @@ func (self *ethProtocol) handle() error {
 			return self.protoError(ErrDecode, "block validation %v: %v", msg, err)
 		}
 		hash := request.Block.Hash()
+		self.markBlock(hash)
 		_, chainHead, _ := self.chainManager.Status()
 
 		jsonlogger.LogJson(&logger.EthChainReceivedNewBlock{
@@ -296,6 +381,10 @@ func (self *ethProtocol) handle() error {
 			BlockPrevHash: request.Block.ParentHash().Hex(),
 			RemoteId:      self.peer.ID().String(),
 		})
+		if self.headerSync() {
+			self.downloader.AddBlock(self.id, request.Block, request.TD)
+			break
+		}
 		// to simplify backend interface adding a new block
 		// uses AddPeer followed by AddBlock only if peer is the best peer
 		// (or selected as new best peer)
@@ -303,12 +392,198 @@ func (self *ethProtocol) handle() error {
 			self.blockPool.AddBlock(request.Block, self.id)
 		}
 
+	case GetBlockHeadersMsg:
+		var request getBlockHeadersMsgData
+		if err := msg.Decode(&request); err != nil {
+			return self.protoError(ErrDecode, "->msg %v: %v", msg, err)
+		}
+
+		if request.Amount > maxHeaders {
+			request.Amount = maxHeaders
+		}
+		headers := self.chainManager.GetHeadersFromHash(request.Hash, request.Amount)
+		return p2p.Send(self.rw, BlockHeadersMsg, headers)
+
+	case BlockHeadersMsg:
+		var headers []*types.Header
+		if err := msg.Decode(&headers); err != nil {
+			return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, header := range headers {
+			if header == nil {
+				return self.protoError(ErrDecode, "header %d is nil", i)
+			}
+		}
+		self.downloader.DeliverHeaders(self.id, headers)
+
+	case GetBlockBodiesMsg:
+		msgStream := rlp.NewStream(msg.Payload)
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+
+		// bodies are matched to the requested hashes by position, so
+		// serving stops at the first block we don't have
+		var bodies []*blockBodyMsgData
+		for len(bodies) < maxBodies {
+			var hash common.Hash
+			err := msgStream.Decode(&hash)
+			if err == rlp.EOL {
+				break
+			} else if err != nil {
+				return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+			}
+
+			block := self.chainManager.GetBlock(hash)
+			if block == nil {
+				break
+			}
+			bodies = append(bodies, &blockBodyMsgData{block.Transactions(), block.Uncles()})
+		}
+		return p2p.Send(self.rw, BlockBodiesMsg, bodies)
+
+	case BlockBodiesMsg:
+		var bodies []*blockBodyMsgData
+		if err := msg.Decode(&bodies); err != nil {
+			return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		transactions := make([][]*types.Transaction, len(bodies))
+		uncles := make([][]*types.Header, len(bodies))
+		for i, body := range bodies {
+			if body == nil {
+				return self.protoError(ErrDecode, "body %d is nil", i)
+			}
+			for j, tx := range body.Transactions {
+				if tx == nil {
+					return self.protoError(ErrDecode, "body %d: transaction %d is nil", i, j)
+				}
+			}
+			for j, uncle := range body.Uncles {
+				if uncle == nil {
+					return self.protoError(ErrDecode, "body %d: uncle %d is nil", i, j)
+				}
+			}
+			transactions[i], uncles[i] = body.Transactions, body.Uncles
+		}
+		self.downloader.DeliverBodies(self.id, transactions, uncles)
+
+	case GetNodeDataMsg:
+		if !self.fastSync() {
+			return self.protoError(ErrInvalidMsgCode, "%v", msg.Code)
+		}
+		msgStream := rlp.NewStream(msg.Payload)
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+
+		// node data is matched to the requests by hash, so entries we
+		// don't have are simply skipped
+		var data [][]byte
+		for len(data) < maxNodeData {
+			var hash common.Hash
+			err := msgStream.Decode(&hash)
+			if err == rlp.EOL {
+				break
+			} else if err != nil {
+				return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+			}
+			if entry := self.chainManager.GetNodeData(hash); len(entry) > 0 {
+				data = append(data, entry)
+			}
+		}
+		return p2p.Send(self.rw, NodeDataMsg, data)
+
+	case NodeDataMsg:
+		if !self.fastSync() {
+			return self.protoError(ErrInvalidMsgCode, "%v", msg.Code)
+		}
+		var data [][]byte
+		if err := msg.Decode(&data); err != nil {
+			return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		self.downloader.DeliverNodeData(self.id, data)
+
+	case GetReceiptsMsg:
+		if !self.fastSync() {
+			return self.protoError(ErrInvalidMsgCode, "%v", msg.Code)
+		}
+		msgStream := rlp.NewStream(msg.Payload)
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+
+		// receipts are matched to the requested hashes by position, so
+		// serving stops at the first block we don't have receipts for
+		var receipts []types.Receipts
+		for len(receipts) < maxReceipts {
+			var hash common.Hash
+			err := msgStream.Decode(&hash)
+			if err == rlp.EOL {
+				break
+			} else if err != nil {
+				return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+			}
+			results := self.chainManager.GetBlockReceipts(hash)
+			if results == nil {
+				if block := self.chainManager.GetBlock(hash); block == nil || block.Header().ReceiptHash != emptyReceiptRoot {
+					break
+				}
+			}
+			receipts = append(receipts, results)
+		}
+		return p2p.Send(self.rw, ReceiptsMsg, receipts)
+
+	case ReceiptsMsg:
+		if !self.fastSync() {
+			return self.protoError(ErrInvalidMsgCode, "%v", msg.Code)
+		}
+		var receipts [][]*types.Receipt
+		if err := msg.Decode(&receipts); err != nil {
+			return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, list := range receipts {
+			for j, receipt := range list {
+				if receipt == nil {
+					return self.protoError(ErrDecode, "receipts %d: receipt %d is nil", i, j)
+				}
+			}
+		}
+		self.downloader.DeliverReceipts(self.id, receipts)
+
+	case NewBlockHashesMsg:
+		if !self.hashAnnounce() {
+			return self.protoError(ErrInvalidMsgCode, "%v", msg.Code)
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return self.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for _, hash := range hashes {
+			self.markBlock(hash)
+			self.downloader.AnnounceBlock(self.id, hash)
+		}
+
 	default:
 		return self.protoError(ErrInvalidMsgCode, "%v", msg.Code)
 	}
 	return nil
 }
 
+// headerSync reports whether the peer is synchronised header first.
+func (self *ethProtocol) headerSync() bool {
+	return self.protocolVersion >= headerSyncVersion
+}
+
+// fastSync reports whether the peer can exchange fast sync data.
+func (self *ethProtocol) fastSync() bool {
+	return self.protocolVersion >= fastSyncVersion
+}
+
+// hashAnnounce reports whether the peer accepts new blocks announced by hash.
+func (self *ethProtocol) hashAnnounce() bool {
+	return self.protocolVersion >= hashAnnounceVersion
+}
+
 func (self *ethProtocol) handleStatus() error {
 	if err := self.sendStatus(); err != nil {
 		return err
@@ -345,9 +620,19 @@ func (self *ethProtocol) handleStatus() error {
 		return self.protoError(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, self.protocolVersion)
 	}
 
-	_, suspended := self.blockPool.AddPeer(status.TD, status.CurrentBlock, self.id, self.requestBlockHashes, self.requestBlocks, self.protoErrorDisconnect)
-	if suspended {
-		return self.protoError(ErrSuspendedPeer, "")
+	if self.headerSync() {
+		var requestReceipts, requestNodeData func([]common.Hash) error
+		if self.fastSync() {
+			requestReceipts, requestNodeData = self.requestReceipts, self.requestNodeData
+		}
+		if err := self.downloader.RegisterPeer(self.id, status.TD, status.CurrentBlock, self.requestHeaders, self.requestBodies, requestReceipts, requestNodeData); err != nil {
+			return err
+		}
+	} else {
+		_, suspended := self.blockPool.AddPeer(status.TD, status.CurrentBlock, self.id, self.requestBlockHashes, self.requestBlocks, self.protoErrorDisconnect)
+		if suspended {
+			return self.protoError(ErrSuspendedPeer, "")
+		}
 	}
 
 	self.peer.Debugf("Peer is [eth] capable (%d/%d). TD=%v H=%x\n", status.ProtocolVersion, status.NetworkId, status.TD, status.CurrentBlock[:4])
@@ -365,6 +650,26 @@ func (self *ethProtocol) requestBlocks(hashes []common.Hash) error {
 	return p2p.Send(self.rw, GetBlocksMsg, hashes)
 }
 
+func (self *ethProtocol) requestHeaders(from common.Hash) error {
+	self.peer.Debugf("fetching headers (%d) %x...\n", maxHeaders, from[0:4])
+	return p2p.Send(self.rw, GetBlockHeadersMsg, getBlockHeadersMsgData{from, maxHeaders})
+}
+
+func (self *ethProtocol) requestBodies(hashes []common.Hash) error {
+	self.peer.Debugf("fetching %v block bodies", len(hashes))
+	return p2p.Send(self.rw, GetBlockBodiesMsg, hashes)
+}
+
+func (self *ethProtocol) requestReceipts(hashes []common.Hash) error {
+	self.peer.Debugf("fetching %v block receipts", len(hashes))
+	return p2p.Send(self.rw, GetReceiptsMsg, hashes)
+}
+
+func (self *ethProtocol) requestNodeData(hashes []common.Hash) error {
+	self.peer.Debugf("fetching %v state entries", len(hashes))
+	return p2p.Send(self.rw, GetNodeDataMsg, hashes)
+}
+
 func (self *ethProtocol) protoError(code int, format string, params ...interface{}) (err *errs.Error) {
 	err = self.errors.New(code, format, params...)
 	//err.Log(self.peer.Logger)
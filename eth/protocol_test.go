@@ -8,12 +8,14 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/errs"
 	ethlogger "github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"gopkg.in/fatih/set.v0"
 )
 
 var logsys = ethlogger.NewStdLogSystem(os.Stdout, log.LstdFlags, ethlogger.LogLevel(ethlogger.DebugDetailLevel))
@@ -34,7 +36,10 @@ type testTxPool struct {
 
 type testChainManager struct {
 	getBlockHashes func(hash common.Hash, amount uint64) (hashes []common.Hash)
+	getHeaders     func(hash common.Hash, amount uint64) (headers []*types.Header)
 	getBlock       func(hash common.Hash) *types.Block
+	getReceipts    func(hash common.Hash) types.Receipts
+	getNodeData    func(hash common.Hash) []byte
 	status         func() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash)
 }
 
@@ -45,6 +50,17 @@ type testBlockPool struct {
 	removePeer     func(peerId string)
 }
 
+type testDownloader struct {
+	registerPeer    func(id string, td *big.Int, hash common.Hash, getHeaders func(common.Hash) error, getBodies func([]common.Hash) error, getReceipts func([]common.Hash) error, getNodeData func([]common.Hash) error) error
+	unregisterPeer  func(id string)
+	addBlock        func(id string, block *types.Block, td *big.Int)
+	announceBlock   func(id string, hash common.Hash)
+	deliverHeaders  func(id string, headers []*types.Header)
+	deliverBodies   func(id string, transactions [][]*types.Transaction, uncles [][]*types.Header)
+	deliverReceipts func(id string, receipts [][]*types.Receipt)
+	deliverNodeData func(id string, data [][]byte)
+}
+
 func (self *testTxPool) AddTransactions(txs []*types.Transaction) {
 	if self.addTransactions != nil {
 		self.addTransactions(txs)
@@ -60,6 +76,13 @@ func (self *testChainManager) GetBlockHashesFromHash(hash common.Hash, amount ui
 	return
 }
 
+func (self *testChainManager) GetHeadersFromHash(hash common.Hash, amount uint64) (headers []*types.Header) {
+	if self.getHeaders != nil {
+		headers = self.getHeaders(hash, amount)
+	}
+	return
+}
+
 func (self *testChainManager) Status() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash) {
 	if self.status != nil {
 		td, currentBlock, genesisBlock = self.status()
@@ -78,6 +101,20 @@ func (self *testChainManager) GetBlock(hash common.Hash) (block *types.Block) {
 	return
 }
 
+func (self *testChainManager) GetBlockReceipts(hash common.Hash) (receipts types.Receipts) {
+	if self.getReceipts != nil {
+		receipts = self.getReceipts(hash)
+	}
+	return
+}
+
+func (self *testChainManager) GetNodeData(hash common.Hash) (data []byte) {
+	if self.getNodeData != nil {
+		data = self.getNodeData(hash)
+	}
+	return
+}
+
 func (self *testBlockPool) AddBlockHashes(next func() (common.Hash, bool), peerId string) {
 	if self.addBlockHashes != nil {
 		self.addBlockHashes(next, peerId)
@@ -103,6 +140,55 @@ func (self *testBlockPool) RemovePeer(peerId string) {
 	}
 }
 
+func (self *testDownloader) RegisterPeer(id string, td *big.Int, hash common.Hash, getHeaders func(common.Hash) error, getBodies func([]common.Hash) error, getReceipts func([]common.Hash) error, getNodeData func([]common.Hash) error) error {
+	if self.registerPeer != nil {
+		return self.registerPeer(id, td, hash, getHeaders, getBodies, getReceipts, getNodeData)
+	}
+	return nil
+}
+
+func (self *testDownloader) UnregisterPeer(id string) {
+	if self.unregisterPeer != nil {
+		self.unregisterPeer(id)
+	}
+}
+
+func (self *testDownloader) AddBlock(id string, block *types.Block, td *big.Int) {
+	if self.addBlock != nil {
+		self.addBlock(id, block, td)
+	}
+}
+
+func (self *testDownloader) AnnounceBlock(id string, hash common.Hash) {
+	if self.announceBlock != nil {
+		self.announceBlock(id, hash)
+	}
+}
+
+func (self *testDownloader) DeliverHeaders(id string, headers []*types.Header) {
+	if self.deliverHeaders != nil {
+		self.deliverHeaders(id, headers)
+	}
+}
+
+func (self *testDownloader) DeliverBodies(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) {
+	if self.deliverBodies != nil {
+		self.deliverBodies(id, transactions, uncles)
+	}
+}
+
+func (self *testDownloader) DeliverReceipts(id string, receipts [][]*types.Receipt) {
+	if self.deliverReceipts != nil {
+		self.deliverReceipts(id, receipts)
+	}
+}
+
+func (self *testDownloader) DeliverNodeData(id string, data [][]byte) {
+	if self.deliverNodeData != nil {
+		self.deliverNodeData(id, data)
+	}
+}
+
 func testPeer() *p2p.Peer {
 	var id discover.NodeID
 	pk := crypto.GenerateNewKeyPair().PublicKey
@@ -113,24 +199,30 @@ func testPeer() *p2p.Peer {
 type ethProtocolTester struct {
 	p2p.MsgReadWriter // writing to the tester feeds the protocol
 
-	quit         chan error
-	pipe         *p2p.MsgPipeRW    // the protocol read/writes on this end
-	txPool       *testTxPool       // txPool
-	chainManager *testChainManager // chainManager
-	blockPool    *testBlockPool    // blockPool
-	t            *testing.T
+	quit            chan error
+	pipe            *p2p.MsgPipeRW    // the protocol read/writes on this end
+	txPool          *testTxPool       // txPool
+	chainManager    *testChainManager // chainManager
+	blockPool       *testBlockPool    // blockPool
+	downloader      *testDownloader   // downloader
+	peers           *peerSet          // set of running peers
+	protocolVersion int
+	t               *testing.T
 }
 
 func newEth(t *testing.T) *ethProtocolTester {
 	p1, p2 := p2p.MsgPipe()
 	return &ethProtocolTester{
-		MsgReadWriter: p1,
-		quit:          make(chan error, 1),
-		pipe:          p2,
-		txPool:        &testTxPool{},
-		chainManager:  &testChainManager{},
-		blockPool:     &testBlockPool{},
-		t:             t,
+		MsgReadWriter:   p1,
+		quit:            make(chan error, 1),
+		pipe:            p2,
+		txPool:          &testTxPool{},
+		chainManager:    &testChainManager{},
+		blockPool:       &testBlockPool{},
+		downloader:      &testDownloader{},
+		peers:           newPeerSet(),
+		protocolVersion: ProtocolVersion,
+		t:               t,
 	}
 }
 
@@ -163,7 +255,7 @@ func (self *ethProtocolTester) checkError(expCode int, delay time.Duration) (err
 }
 
 func (self *ethProtocolTester) run() {
-	err := runEthProtocol(ProtocolVersion, NetworkId, self.txPool, self.chainManager, self.blockPool, testPeer(), self.pipe)
+	err := runEthProtocol(self.protocolVersion, NetworkId, self.txPool, self.chainManager, self.blockPool, self.downloader, self.peers, testPeer(), self.pipe)
 	self.quit <- err
 }
 
@@ -171,7 +263,7 @@ func (self *ethProtocolTester) handshake(t *testing.T, mock bool) {
 	td, currentBlock, genesis := self.chainManager.Status()
 	// first outgoing msg should be StatusMsg.
 	err := p2p.ExpectMsg(self, StatusMsg, &statusMsgData{
-		ProtocolVersion: ProtocolVersion,
+		ProtocolVersion: uint32(self.protocolVersion),
 		NetworkId:       NetworkId,
 		TD:              td,
 		CurrentBlock:    currentBlock,
@@ -181,7 +273,7 @@ func (self *ethProtocolTester) handshake(t *testing.T, mock bool) {
 		t.Fatalf("incorrect outgoing status: %v", err)
 	}
 	if mock {
-		go p2p.Send(self, StatusMsg, &statusMsgData{ProtocolVersion, NetworkId, td, currentBlock, genesis})
+		go p2p.Send(self, StatusMsg, &statusMsgData{uint32(self.protocolVersion), NetworkId, td, currentBlock, genesis})
 	}
 }
 
@@ -228,6 +320,7 @@ func TestStatusMsgErrors(t *testing.T) {
 func TestNewBlockMsg(t *testing.T) {
 	// logInit()
 	eth := newEth(t)
+	eth.protocolVersion = 60
 
 	var disconnected bool
 	eth.blockPool.removePeer = func(peerId string) {
@@ -293,6 +386,7 @@ func TestNewBlockMsg(t *testing.T) {
 func TestBlockMsg(t *testing.T) {
 	// logInit()
 	eth := newEth(t)
+	eth.protocolVersion = 60
 	blocks := make(chan *types.Block)
 	eth.blockPool.addBlock = func(block *types.Block, peerId string) (err error) {
 		blocks <- block
@@ -398,3 +492,412 @@ func TestTransactionsMsg(t *testing.T) {
 	eth.checkError(ErrDecode, delay)
 
 }
+
+func TestNewBlockMsgHeaderSync(t *testing.T) {
+	eth := newEth(t)
+
+	registered := make(chan string, 1)
+	eth.downloader.registerPeer = func(id string, td *big.Int, hash common.Hash, getHeaders func(common.Hash) error, getBodies func([]common.Hash) error, getReceipts func([]common.Hash) error, getNodeData func([]common.Hash) error) error {
+		registered <- id
+		return nil
+	}
+	eth.blockPool.addPeer = func(td *big.Int, currentBlock common.Hash, peerId string, requestHashes func(common.Hash) error, requestBlocks func([]common.Hash) error, peerError func(*errs.Error)) (best bool, suspended bool) {
+		t.Errorf("header synced peer added to block pool")
+		return
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	var delay = 1 * time.Second
+	select {
+	case <-registered:
+	case <-time.After(delay):
+		t.Fatalf("peer not registered with downloader after %v", delay)
+	}
+
+	tds := make(chan *big.Int)
+	eth.downloader.addBlock = func(id string, block *types.Block, td *big.Int) {
+		tds <- td
+	}
+	block := types.NewBlock(common.Hash{1}, common.Address{1}, common.Hash{1}, common.Big1, 1, []byte("extra"))
+	go p2p.Send(eth, NewBlockMsg, &newBlockMsgData{block, common.Big2})
+
+	select {
+	case td := <-tds:
+		if td.Cmp(common.Big2) != 0 {
+			t.Errorf("incorrect td %v, expected %v", td, common.Big2)
+		}
+	case <-time.After(delay):
+		t.Errorf("no block added after %v", delay)
+	case err := <-eth.quit:
+		t.Errorf("no error expected, got %v", err)
+	}
+}
+
+func TestGetBlockHeadersMsg(t *testing.T) {
+	eth := newEth(t)
+
+	headers := []*types.Header{{Number: big.NewInt(2)}, {Number: big.NewInt(1)}}
+	eth.chainManager.getHeaders = func(hash common.Hash, amount uint64) []*types.Header {
+		if hash != (common.Hash{1}) {
+			t.Errorf("incorrect origin %x, expected %x", hash, common.Hash{1})
+		}
+		if amount != maxHeaders {
+			t.Errorf("incorrect amount %d, expected %d", amount, maxHeaders)
+		}
+		return headers
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	go p2p.Send(eth, GetBlockHeadersMsg, getBlockHeadersMsgData{common.Hash{1}, 2 * maxHeaders})
+	if err := p2p.ExpectMsg(eth, BlockHeadersMsg, headers); err != nil {
+		t.Errorf("headers expected, got %v", err)
+	}
+}
+
+func TestGetBlockBodiesMsg(t *testing.T) {
+	eth := newEth(t)
+
+	blocks := make(map[common.Hash]*types.Block)
+	for i := byte(1); i <= 2; i++ {
+		block := types.NewBlock(common.Hash{i}, common.Address{i}, common.Hash{i}, common.Big1, uint64(i), nil)
+		block.AddTransaction(types.NewTransactionMessage(common.Address{i}, common.Big1, common.Big1, common.Big1, nil))
+		blocks[common.Hash{i}] = block
+	}
+	eth.chainManager.getBlock = func(hash common.Hash) *types.Block {
+		return blocks[hash]
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	// serving stops at the first unknown block
+	go p2p.Send(eth, GetBlockBodiesMsg, []common.Hash{{1}, {3}, {2}})
+	want := []*blockBodyMsgData{{blocks[common.Hash{1}].Transactions(), blocks[common.Hash{1}].Uncles()}}
+	if err := p2p.ExpectMsg(eth, BlockBodiesMsg, want); err != nil {
+		t.Errorf("bodies expected, got %v", err)
+	}
+}
+
+func TestBlockBodiesMsg(t *testing.T) {
+	eth := newEth(t)
+
+	type delivery struct {
+		transactions [][]*types.Transaction
+		uncles       [][]*types.Header
+	}
+	deliveries := make(chan delivery)
+	eth.downloader.deliverBodies = func(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) {
+		deliveries <- delivery{transactions, uncles}
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	tx := types.NewTransactionMessage(common.Address{1}, common.Big1, common.Big1, common.Big1, nil)
+	uncle := &types.Header{Number: common.Big1}
+	go p2p.Send(eth, BlockBodiesMsg, []*blockBodyMsgData{{[]*types.Transaction{tx}, nil}, {nil, []*types.Header{uncle}}})
+
+	var delay = 1 * time.Second
+	select {
+	case d := <-deliveries:
+		if len(d.transactions) != 2 || len(d.uncles) != 2 {
+			t.Fatalf("incorrect number of bodies delivered: %d transaction lists, %d uncle lists", len(d.transactions), len(d.uncles))
+		}
+		if len(d.transactions[0]) != 1 || d.transactions[0][0].Hash() != tx.Hash() {
+			t.Errorf("incorrect transactions %v", d.transactions[0])
+		}
+		if len(d.uncles[1]) != 1 || d.uncles[1][0].Hash() != uncle.Hash() {
+			t.Errorf("incorrect uncles %v", d.uncles[1])
+		}
+	case <-time.After(delay):
+		t.Errorf("no bodies delivered after %v", delay)
+	case err := <-eth.quit:
+		t.Errorf("no error expected, got %v", err)
+	}
+
+	go p2p.Send(eth, BlockBodiesMsg, []interface{}{[]interface{}{[]interface{}{[]interface{}{}}, []interface{}{}}})
+	eth.checkError(ErrDecode, delay)
+}
+
+func TestGetNodeDataMsg(t *testing.T) {
+	eth := newEth(t)
+
+	entries := map[common.Hash][]byte{
+		common.BytesToHash(crypto.Sha3([]byte{1})): {1},
+		common.BytesToHash(crypto.Sha3([]byte{2})): {2},
+	}
+	eth.chainManager.getNodeData = func(hash common.Hash) []byte {
+		return entries[hash]
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	// unknown entries are skipped
+	go p2p.Send(eth, GetNodeDataMsg, []common.Hash{common.BytesToHash(crypto.Sha3([]byte{1})), {3}, common.BytesToHash(crypto.Sha3([]byte{2}))})
+	if err := p2p.ExpectMsg(eth, NodeDataMsg, [][]byte{{1}, {2}}); err != nil {
+		t.Errorf("node data expected, got %v", err)
+	}
+}
+
+func TestGetReceiptsMsg(t *testing.T) {
+	eth := newEth(t)
+
+	receipt := types.NewReceipt([]byte{1}, common.Big1)
+	receipt.SetLogs(state.Logs{state.NewLog(common.Address{1}, []common.Hash{{2}}, []byte{3}, 1)})
+
+	receipts := map[common.Hash]types.Receipts{
+		{1}: {receipt},
+	}
+	eth.chainManager.getReceipts = func(hash common.Hash) types.Receipts {
+		return receipts[hash]
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	// serving stops at the first unknown block
+	go p2p.Send(eth, GetReceiptsMsg, []common.Hash{{1}, {3}, {1}})
+	if err := p2p.ExpectMsg(eth, ReceiptsMsg, []types.Receipts{receipts[common.Hash{1}]}); err != nil {
+		t.Errorf("receipts expected, got %v", err)
+	}
+}
+
+func TestReceiptsMsg(t *testing.T) {
+	eth := newEth(t)
+
+	deliveries := make(chan [][]*types.Receipt)
+	eth.downloader.deliverReceipts = func(id string, receipts [][]*types.Receipt) {
+		deliveries <- receipts
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	receipt := types.NewReceipt([]byte{1}, common.Big1)
+	receipt.SetLogs(state.Logs{state.NewLog(common.Address{1}, []common.Hash{{2}}, []byte{3}, 1)})
+	go p2p.Send(eth, ReceiptsMsg, []types.Receipts{{receipt}, {}})
+
+	var delay = 1 * time.Second
+	select {
+	case receipts := <-deliveries:
+		if len(receipts) != 2 || len(receipts[0]) != 1 || len(receipts[1]) != 0 {
+			t.Fatalf("incorrect receipts delivered: %v", receipts)
+		}
+		if types.DeriveSha(types.Receipts(receipts[0])) != types.DeriveSha(types.Receipts{receipt}) {
+			t.Errorf("incorrect receipt %v", receipts[0][0])
+		}
+	case <-time.After(delay):
+		t.Errorf("no receipts delivered after %v", delay)
+	case err := <-eth.quit:
+		t.Errorf("no error expected, got %v", err)
+	}
+}
+
+func TestFastSyncMsgVersion(t *testing.T) {
+	eth := newEth(t)
+	eth.protocolVersion = 61
+
+	var registered bool
+	eth.downloader.registerPeer = func(id string, td *big.Int, hash common.Hash, getHeaders func(common.Hash) error, getBodies func([]common.Hash) error, getReceipts func([]common.Hash) error, getNodeData func([]common.Hash) error) error {
+		if getReceipts != nil || getNodeData != nil {
+			t.Errorf("fast sync fetchers registered for protocol version 61")
+		}
+		registered = true
+		return nil
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	if !registered {
+		t.Errorf("peer not registered with the downloader")
+	}
+	go p2p.Send(eth, GetNodeDataMsg, []common.Hash{{1}})
+	eth.checkError(ErrInvalidMsgCode, 1*time.Second)
+}
+
+func TestNewBlockHashesMsg(t *testing.T) {
+	eth := newEth(t)
+
+	announces := make(chan common.Hash, 2)
+	eth.downloader.announceBlock = func(id string, hash common.Hash) {
+		announces <- hash
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	go p2p.Send(eth, NewBlockHashesMsg, []common.Hash{{1}, {2}})
+
+	var delay = 1 * time.Second
+	for _, want := range []common.Hash{{1}, {2}} {
+		select {
+		case hash := <-announces:
+			if hash != want {
+				t.Errorf("announced hash mismatch: have %x, want %x", hash, want)
+			}
+		case <-time.After(delay):
+			t.Fatalf("no announcement after %v", delay)
+		case err := <-eth.quit:
+			t.Fatalf("no error expected, got %v", err)
+		}
+	}
+}
+
+func TestNewBlockHashesMsgVersion(t *testing.T) {
+	eth := newEth(t)
+	eth.protocolVersion = 62
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	go p2p.Send(eth, NewBlockHashesMsg, []common.Hash{{1}})
+	eth.checkError(ErrInvalidMsgCode, 1*time.Second)
+}
+
+// Tests that transactions received from a peer are not propagated back to it.
+func TestTransactionPropagation(t *testing.T) {
+	eth := newEth(t)
+
+	added := make(chan struct{})
+	eth.txPool.addTransactions = func([]*types.Transaction) {
+		added <- struct{}{}
+	}
+	go eth.run()
+
+	eth.handshake(t, true)
+	err := p2p.ExpectMsg(eth, TxMsg, []interface{}{})
+	if err != nil {
+		t.Errorf("transactions expected, got %v", err)
+	}
+	known := types.NewTransactionMessage(common.Address{1}, common.Big1, common.Big1, common.Big1, nil)
+	unknown := types.NewTransactionMessage(common.Address{2}, common.Big1, common.Big1, common.Big1, nil)
+
+	go p2p.Send(eth, TxMsg, []*types.Transaction{known})
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatalf("transaction not received")
+	}
+	go eth.peers.broadcastTransactions(types.Transactions{known, unknown})
+	if err := p2p.ExpectMsg(eth, TxMsg, []*types.Transaction{unknown}); err != nil {
+		t.Errorf("propagated transactions mismatch: %v", err)
+	}
+	if peers := eth.peers.peersWithoutTx(unknown.Hash()); len(peers) != 0 {
+		t.Errorf("propagated transaction not marked known")
+	}
+}
+
+// newTestPropagationPeers creates a peer set of n peers running the current
+// protocol version, returning the remote ends of their message pipes.
+func newTestPropagationPeers(n int) (*peerSet, []*p2p.MsgPipeRW) {
+	peers := newPeerSet()
+	remotes := make([]*p2p.MsgPipeRW, n)
+	for i := 0; i < n; i++ {
+		local, remote := p2p.MsgPipe()
+		peers.register(&ethProtocol{
+			id:              string(rune('a' + i)),
+			rw:              local,
+			protocolVersion: ProtocolVersion,
+			knownTxs:        set.New(),
+			knownBlocks:     set.New(),
+		})
+		remotes[i] = remote
+	}
+	return peers, remotes
+}
+
+// Tests that a new block is pushed in full to the square root of the peers
+// and announced by hash to the rest, never reaching the same peer twice.
+func TestBlockPropagation(t *testing.T) {
+	peers, remotes := newTestPropagationPeers(9)
+	block := types.NewBlock(common.Hash{1}, common.Address{}, common.Hash{}, common.Big1, 0, nil)
+
+	codes := make(chan uint64, len(remotes))
+	for _, remote := range remotes {
+		go func(remote *p2p.MsgPipeRW) {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+			codes <- msg.Code
+		}(remote)
+	}
+	peers.broadcastBlock(block, common.Big1)
+
+	var blocks, hashes int
+	for i := 0; i < len(remotes); i++ {
+		select {
+		case code := <-codes:
+			switch code {
+			case NewBlockMsg:
+				blocks++
+			case NewBlockHashesMsg:
+				hashes++
+			default:
+				t.Errorf("unexpected message code %d", code)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("block not propagated to all peers")
+		}
+	}
+	if blocks != 3 || hashes != 6 {
+		t.Errorf("propagation mismatch: have %d blocks, %d hashes, want 3 blocks, 6 hashes", blocks, hashes)
+	}
+	if left := peers.peersWithoutBlock(block.Hash()); len(left) != 0 {
+		t.Errorf("%d peers not marked as knowing the block", len(left))
+	}
+}
+
+// Tests that the known transaction and block sets don't grow unbounded.
+func TestKnownSetsBounded(t *testing.T) {
+	p := &ethProtocol{knownTxs: set.New(), knownBlocks: set.New()}
+	for i := 0; i < maxKnownTxs+10; i++ {
+		p.markTransaction(common.BigToHash(big.NewInt(int64(i))))
+	}
+	for i := 0; i < maxKnownBlocks+10; i++ {
+		p.markBlock(common.BigToHash(big.NewInt(int64(i))))
+	}
+	if size := p.knownTxs.Size(); size != maxKnownTxs {
+		t.Errorf("known transactions size mismatch: have %d, want %d", size, maxKnownTxs)
+	}
+	if size := p.knownBlocks.Size(); size != maxKnownBlocks {
+		t.Errorf("known blocks size mismatch: have %d, want %d", size, maxKnownBlocks)
+	}
+}
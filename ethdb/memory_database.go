@@ -39,6 +39,15 @@ func (db *MemDatabase) GetKeys() []*common.Key {
 }
 */
 
+// Keys returns all the keys currently stored in the database.
+func (db *MemDatabase) Keys() [][]byte {
+	keys := [][]byte{}
+	for key := range db.db {
+		keys = append(keys, []byte(key))
+	}
+	return keys
+}
+
 func (db *MemDatabase) Delete(key []byte) error {
 	delete(db.db, string(key))
 
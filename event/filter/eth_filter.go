@@ -27,8 +27,15 @@ func NewFilterManager(mux *event.TypeMux) *FilterManager {
 	}
 }
 
+// Start delivers events to the installed filters. Events posted after Start
+// returns are not missed.
 func (self *FilterManager) Start() {
-	go self.filterLoop()
+	events := self.eventMux.Subscribe(
+		//core.PendingBlockEvent{},
+		core.ChainEvent{},
+		core.TxPreEvent{},
+		state.Logs(nil))
+	go self.filterLoop(events)
 }
 
 func (self *FilterManager) Stop() {
@@ -61,14 +68,7 @@ func (self *FilterManager) GetFilter(id int) *core.Filter {
 	return self.filters[id]
 }
 
-func (self *FilterManager) filterLoop() {
-	// Subscribe to events
-	events := self.eventMux.Subscribe(
-		//core.PendingBlockEvent{},
-		core.ChainEvent{},
-		core.TxPreEvent{},
-		state.Logs(nil))
-
+func (self *FilterManager) filterLoop(events event.Subscription) {
 out:
 	for {
 		select {
@@ -0,0 +1,224 @@
+package les
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// LightNode is the light side of the les protocol. It keeps a header chain in
+// sync with the connected servers and retrieves anything else on demand.
+type LightNode struct {
+	networkId int
+	chain     *LightChain
+	odr       *odr
+
+	synchronising int32 // Flag whether header synchronisation is running
+}
+
+// NewLightNode creates a light node keeping its header chain in chainDb.
+func NewLightNode(networkId int, chainDb common.Database, genesis *types.Block, validate headerValidatorFn) *LightNode {
+	return &LightNode{
+		networkId: networkId,
+		chain:     NewLightChain(chainDb, genesis, validate),
+		odr:       newOdr(),
+	}
+}
+
+// Protocol returns the light side of the les protocol to be registered with
+// the p2p server.
+func (self *LightNode) Protocol() p2p.Protocol {
+	return p2p.Protocol{
+		Name:    "les",
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run:     self.runPeer,
+	}
+}
+
+// Chain returns the header chain of the light node.
+func (self *LightNode) Chain() *LightChain {
+	return self.chain
+}
+
+// State returns a view of the state at the current head of the header chain.
+func (self *LightNode) State() *LightState {
+	return NewLightState(self.chain.CurrentHeader(), self.odr)
+}
+
+// GetBlockReceipts retrieves the transaction receipts of a known block.
+func (self *LightNode) GetBlockReceipts(hash common.Hash) (types.Receipts, error) {
+	header := self.chain.GetHeader(hash)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	if header.ReceiptHash == emptyReceiptRoot {
+		return types.Receipts{}, nil
+	}
+	req := &ReceiptsRequest{Header: header}
+	if err := self.odr.retrieve(req); err != nil {
+		return nil, err
+	}
+	return req.Receipts, nil
+}
+
+// runPeer is the main loop talking to a single server.
+func (self *LightNode) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	peer := newPeer(p, rw)
+
+	td, head, genesis := self.chain.Status()
+	status, err := peer.handshake(self.networkId, &statusData{
+		ProtocolVersion: ProtocolVersion,
+		NetworkId:       uint32(self.networkId),
+		TD:              td,
+		CurrentBlock:    head,
+		GenesisBlock:    genesis,
+	})
+	if err != nil {
+		return err
+	}
+	if status.BufLimit == 0 {
+		return peer.protoError(ErrUselessPeer, "not serving light clients")
+	}
+	peer.fcServer = flowcontrol.NewServerNode(&flowcontrol.ServerParams{
+		BufLimit:    status.BufLimit,
+		MinRecharge: status.MinRecharge,
+	})
+	peer.fcCosts = status.CostList.decode()
+
+	self.odr.register(peer)
+	defer self.odr.unregister(peer)
+
+	go self.synchronise(peer)
+
+	for {
+		if err := self.handleMsg(peer); err != nil {
+			return err
+		}
+	}
+}
+
+func (self *LightNode) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return p.protoError(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	// make sure that the payload has been fully consumed
+	defer msg.Discard()
+
+	var (
+		reqID, bv uint64
+		reply     interface{}
+	)
+	switch msg.Code {
+	case StatusMsg:
+		return p.protoError(ErrExtraStatusMsg, "")
+
+	case AnnounceMsg:
+		var announce announceData
+		if err := msg.Decode(&announce); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.setHead(announce.Hash, announce.TD)
+		go self.synchronise(p)
+		return nil
+
+	case BlockHeadersMsg:
+		var data blockHeadersData
+		if err := msg.Decode(&data); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, header := range data.Headers {
+			if header == nil {
+				return p.protoError(ErrDecode, "header %d is nil", i)
+			}
+		}
+		reqID, bv, reply = data.ReqID, data.BV, data.Headers
+
+	case ReceiptsMsg:
+		var data receiptsData
+		if err := msg.Decode(&data); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, list := range data.Receipts {
+			for j, receipt := range list {
+				if receipt == nil {
+					return p.protoError(ErrDecode, "receipts %d: receipt %d is nil", i, j)
+				}
+			}
+		}
+		reqID, bv, reply = data.ReqID, data.BV, data.Receipts
+
+	case CodeMsg:
+		var data codeData
+		if err := msg.Decode(&data); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		reqID, bv, reply = data.ReqID, data.BV, data.Data
+
+	case ProofsMsg:
+		var data proofsData
+		if err := msg.Decode(&data); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		reqID, bv, reply = data.ReqID, data.BV, data.Proofs
+
+	default:
+		return p.protoError(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	p.fcServer.GotReply(reqID, bv)
+	self.odr.deliver(p, msg.Code, reqID, reply)
+
+	return nil
+}
+
+// synchronise retrieves the headers leading up to a server's head if it has
+// a higher total difficulty than our own head, walking back from it until a
+// known header is found.
+func (self *LightNode) synchronise(p *peer) {
+	if !atomic.CompareAndSwapInt32(&self.synchronising, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&self.synchronising, 0)
+
+	hash, td := p.head()
+	if td.Cmp(self.chain.Td()) <= 0 {
+		return
+	}
+	glog.V(logger.Debug).Infof("peer %s: synchronising headers up to %x, TD=%v", p.id, hash[:4], td)
+
+	var headers []*types.Header
+	for from := hash; !self.chain.HasHeader(from); {
+		req := &headersRequest{Hash: from, Amount: MaxHeaderFetch}
+		if err := self.odr.retrieveFrom(p, req); err != nil {
+			glog.V(logger.Debug).Infof("peer %s: header synchronisation failed: %v", p.id, err)
+			return
+		}
+		for _, header := range req.Headers {
+			if self.chain.HasHeader(header.Hash()) {
+				break
+			}
+			headers = append(headers, header)
+		}
+		from = headers[len(headers)-1].ParentHash
+	}
+	// Headers were collected from the head backwards, insert them in order
+	for i, j := 0, len(headers)-1; i < j; i, j = i+1, j-1 {
+		headers[i], headers[j] = headers[j], headers[i]
+	}
+	if n, err := self.chain.InsertHeaderChain(headers); err != nil {
+		glog.V(logger.Debug).Infof("peer %s: invalid header #%d: %v", p.id, n, err)
+		p.Disconnect(p2p.DiscSubprotocolError)
+		return
+	}
+	head := self.chain.CurrentHeader()
+	glog.V(logger.Info).Infof("Light chain synchronised to #%v [%x…]", head.Number, head.Hash().Bytes()[:4])
+}
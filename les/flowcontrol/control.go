@@ -0,0 +1,164 @@
+// Package flowcontrol implements the request credit bookkeeping of the light
+// client protocol. A server assigns every client a buffer of credits that is
+// recharged at a fixed rate; every request has a cost that is deducted from
+// it. Clients keep an estimate of their buffer so they never send requests a
+// well behaving server would have to reject.
+package flowcontrol
+
+import (
+	"sync"
+	"time"
+)
+
+// fcTimeConst is the time unit the recharge rate is specified in.
+const fcTimeConst = time.Millisecond
+
+// ServerParams are the flow control parameters a server assigns to a client.
+type ServerParams struct {
+	BufLimit    uint64 // Maximum (and initial) value of the request buffer
+	MinRecharge uint64 // Buffer recharge per millisecond
+}
+
+// recharge returns the buffer value after recharging value for the time
+// elapsed between then and now, capped at the buffer limit.
+func (self *ServerParams) recharge(value uint64, then, now time.Time) uint64 {
+	if dt := now.Sub(then); dt > 0 {
+		gain := uint64(dt/fcTimeConst) * self.MinRecharge
+		if gain >= self.BufLimit-value {
+			return self.BufLimit
+		}
+		value += gain
+	}
+	return value
+}
+
+// ClientNode is the server side record of the request buffer of a connected
+// client.
+type ClientNode struct {
+	params   *ServerParams
+	bufValue uint64
+	lastTime time.Time
+
+	lock sync.Mutex
+}
+
+// NewClientNode creates the buffer record of a newly connected client.
+func NewClientNode(params *ServerParams) *ClientNode {
+	return &ClientNode{
+		params:   params,
+		bufValue: params.BufLimit,
+		lastTime: time.Now(),
+	}
+}
+
+// AcceptRequest deducts the cost of a request from the client's buffer. It
+// returns the remaining buffer value and whether the buffer was large enough
+// for the request to be served. Rejected requests don't change the buffer.
+func (self *ClientNode) AcceptRequest(cost uint64) (uint64, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	now := time.Now()
+	self.bufValue = self.params.recharge(self.bufValue, self.lastTime, now)
+	self.lastTime = now
+
+	if cost > self.bufValue {
+		return self.bufValue, false
+	}
+	self.bufValue -= cost
+	return self.bufValue, true
+}
+
+// ServerNode is the client side estimate of its request buffer at a server.
+type ServerNode struct {
+	params      *ServerParams
+	bufEstimate uint64
+	lastTime    time.Time
+	pending     map[uint64]uint64 // Costs of the requests sent but not yet answered
+
+	lock sync.Mutex
+}
+
+// NewServerNode creates the buffer estimate for a newly connected server.
+func NewServerNode(params *ServerParams) *ServerNode {
+	return &ServerNode{
+		params:      params,
+		bufEstimate: params.BufLimit,
+		lastTime:    time.Now(),
+		pending:     make(map[uint64]uint64),
+	}
+}
+
+// recalc recharges the buffer estimate up to the current time.
+func (self *ServerNode) recalc() {
+	now := time.Now()
+	self.bufEstimate = self.params.recharge(self.bufEstimate, self.lastTime, now)
+	self.lastTime = now
+}
+
+// CanSend returns the time to wait until a request of the given cost can be
+// sent to the server, or zero if it can be sent right away.
+func (self *ServerNode) CanSend(cost uint64) time.Duration {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.recalc()
+	if cost <= self.bufEstimate {
+		return 0
+	}
+	if cost > self.params.BufLimit || self.params.MinRecharge == 0 {
+		// The request can never be served, don't wait forever for it
+		return -1
+	}
+	return time.Duration((cost-self.bufEstimate+self.params.MinRecharge-1)/self.params.MinRecharge) * fcTimeConst
+}
+
+// QueueRequest deducts the cost of a request about to be sent from the buffer
+// estimate.
+func (self *ServerNode) QueueRequest(reqID, cost uint64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.recalc()
+	if cost > self.bufEstimate {
+		self.bufEstimate = 0
+	} else {
+		self.bufEstimate -= cost
+	}
+	self.pending[reqID] = cost
+}
+
+// GotReply updates the buffer estimate with the buffer value reported by the
+// server in its reply to a request. Costs of requests still in flight are
+// deducted again, since the server may not have received them yet.
+func (self *ServerNode) GotReply(reqID, bv uint64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if _, ok := self.pending[reqID]; !ok {
+		return
+	}
+	delete(self.pending, reqID)
+
+	if bv > self.params.BufLimit {
+		bv = self.params.BufLimit
+	}
+	for _, cost := range self.pending {
+		if cost > bv {
+			bv = 0
+			break
+		}
+		bv -= cost
+	}
+	self.bufEstimate = bv
+	self.lastTime = time.Now()
+}
+
+// CancelRequest forgets a request that will never be answered (e.g. timed out),
+// so its cost doesn't keep lowering future buffer estimates.
+func (self *ServerNode) CancelRequest(reqID uint64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	delete(self.pending, reqID)
+}
@@ -0,0 +1,57 @@
+package flowcontrol
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the server side buffer rejects requests exceeding it and
+// recharges over time.
+func TestClientNodeBuffer(t *testing.T) {
+	params := &ServerParams{BufLimit: 1000, MinRecharge: 100}
+	node := NewClientNode(params)
+
+	if bv, ok := node.AcceptRequest(600); !ok || bv != 400 {
+		t.Fatalf("first request: have %v/%v, want 400/true", bv, ok)
+	}
+	if _, ok := node.AcceptRequest(600); ok {
+		t.Fatalf("request exceeding the buffer accepted")
+	}
+	node.lastTime = node.lastTime.Add(-3 * fcTimeConst)
+	if bv, ok := node.AcceptRequest(600); !ok || bv != 100 {
+		t.Fatalf("recharged request: have %v/%v, want 100/true", bv, ok)
+	}
+	node.lastTime = node.lastTime.Add(-time.Hour)
+	if bv, ok := node.AcceptRequest(0); !ok || bv != params.BufLimit {
+		t.Fatalf("buffer not capped: have %v/%v, want %v/true", bv, ok, params.BufLimit)
+	}
+}
+
+// Tests that the client side buffer estimate never allows more than the server
+// would accept and is corrected by the server's replies.
+func TestServerNodeEstimate(t *testing.T) {
+	params := &ServerParams{BufLimit: 1000, MinRecharge: 100}
+	node := NewServerNode(params)
+
+	if wait := node.CanSend(600); wait != 0 {
+		t.Fatalf("initial request delayed by %v", wait)
+	}
+	node.QueueRequest(1, 600)
+	node.QueueRequest(2, 300)
+
+	if wait := node.CanSend(600); wait <= 0 {
+		t.Fatalf("overflowing request not delayed: %v", wait)
+	}
+	if wait := node.CanSend(2000); wait >= 0 {
+		t.Fatalf("unservable request not rejected: %v", wait)
+	}
+	// The server only processed the first request, the second is still in flight
+	node.GotReply(1, 700)
+	if node.bufEstimate != 400 {
+		t.Fatalf("buffer estimate mismatch: have %v, want 400", node.bufEstimate)
+	}
+	node.CancelRequest(2)
+	if len(node.pending) != 0 {
+		t.Fatalf("pending requests remained: %v", node.pending)
+	}
+}
@@ -0,0 +1,334 @@
+package les
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/errs"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// testChain is a full chain served to light nodes, consisting of headers on
+// top of a common test state.
+type testChain struct {
+	lock     sync.RWMutex
+	headers  map[common.Hash]*types.Header
+	receipts map[common.Hash]types.Receipts
+	chain    []*types.Header
+	root     common.Hash
+}
+
+// newTestChain creates a chain of n headers on top of a genesis, all sharing
+// the same state root. Every fourth block contains a receipt.
+func newTestChain(root common.Hash, n int) *testChain {
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1), Root: root, ReceiptHash: emptyReceiptRoot}
+	chain := &testChain{
+		headers:  map[common.Hash]*types.Header{genesis.Hash(): genesis},
+		receipts: make(map[common.Hash]types.Receipts),
+		chain:    []*types.Header{genesis},
+		root:     root,
+	}
+	chain.extend(n)
+	return chain
+}
+
+// extend appends n new headers to the chain, returning the new head.
+func (self *testChain) extend(n int) *types.Header {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for i := 0; i < n; i++ {
+		parent := self.chain[len(self.chain)-1]
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number, common.Big1),
+			Difficulty:  big.NewInt(1),
+			Root:        self.root,
+			ReceiptHash: emptyReceiptRoot,
+		}
+		var receipts types.Receipts
+		if header.Number.Int64()%4 == 0 {
+			receipt := types.NewReceipt(self.root[:], big.NewInt(21000))
+			receipt.SetLogs(state.Logs{&state.Log{Address: common.Address{byte(i)}, Data: []byte{byte(i)}}})
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			receipts = types.Receipts{receipt}
+			header.ReceiptHash = types.DeriveSha(receipts)
+		}
+		self.headers[header.Hash()] = header
+		self.receipts[header.Hash()] = receipts
+		self.chain = append(self.chain, header)
+	}
+	return self.chain[len(self.chain)-1]
+}
+
+func (self *testChain) genesis() *types.Block {
+	return types.NewBlockWithHeader(self.chain[0])
+}
+
+func (self *testChain) Status() (*big.Int, common.Hash, common.Hash) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return big.NewInt(int64(len(self.chain))), self.chain[len(self.chain)-1].Hash(), self.chain[0].Hash()
+}
+
+func (self *testChain) GetHeadersFromHash(hash common.Hash, amount uint64) (headers []*types.Header) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	for header := self.headers[hash]; header != nil && uint64(len(headers)) < amount; header = self.headers[header.ParentHash] {
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+func (self *testChain) GetHeader(hash common.Hash) *types.Header {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return self.headers[hash]
+}
+
+func (self *testChain) GetBlockReceipts(hash common.Hash) types.Receipts {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return self.receipts[hash]
+}
+
+func testPeer() *p2p.Peer {
+	var id discover.NodeID
+	copy(id[:], crypto.GenerateNewKeyPair().PublicKey)
+	return p2p.NewPeer(id, "test peer", []p2p.Cap{})
+}
+
+// lesTester is a server and a light node connected over a message pipe.
+type lesTester struct {
+	chain  *testChain
+	srcDb  *ethdb.MemDatabase
+	mux    *event.TypeMux
+	server *Server
+	light  *LightNode
+
+	serverErr, lightErr chan error
+}
+
+func newLesTester(t *testing.T, blocks int) *lesTester {
+	srcDb, _ := ethdb.NewMemDatabase()
+	root := core.MakeState(srcDb)
+	chain := newTestChain(root, blocks)
+	mux := new(event.TypeMux)
+
+	lightDb, _ := ethdb.NewMemDatabase()
+	validate := func(header, parent *types.Header) error { return nil }
+
+	tester := &lesTester{
+		chain:     chain,
+		srcDb:     srcDb,
+		mux:       mux,
+		server:    NewServer(0, chain, srcDb, mux),
+		light:     NewLightNode(0, lightDb, chain.genesis(), validate),
+		serverErr: make(chan error, 1),
+		lightErr:  make(chan error, 1),
+	}
+	tester.server.Start()
+
+	app, net := p2p.MsgPipe()
+	go func() { tester.serverErr <- tester.server.runPeer(testPeer(), app) }()
+	go func() { tester.lightErr <- tester.light.runPeer(testPeer(), net) }()
+
+	return tester
+}
+
+func (self *lesTester) stop() {
+	self.server.Stop()
+	self.mux.Stop()
+}
+
+// waitSync waits until the light node's head is the given header.
+func (self *lesTester) waitSync(t *testing.T, head *types.Header) {
+	for timeout := time.After(5 * time.Second); ; {
+		if self.light.Chain().CurrentHeader().Hash() == head.Hash() {
+			return
+		}
+		select {
+		case err := <-self.lightErr:
+			t.Fatalf("light node failed: %v", err)
+		case err := <-self.serverErr:
+			t.Fatalf("server failed: %v", err)
+		case <-timeout:
+			t.Fatalf("light chain not synchronised: have #%v, want #%v", self.light.Chain().CurrentHeader().Number, head.Number)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Tests that a light node synchronises its header chain with a server upon
+// connecting, and follows the new heads the server announces.
+func TestLightSync(t *testing.T) {
+	tester := newLesTester(t, 2*MaxHeaderFetch+10)
+	defer tester.stop()
+
+	head := tester.chain.chain[len(tester.chain.chain)-1]
+	tester.waitSync(t, head)
+
+	for _, header := range tester.chain.chain {
+		if tester.light.Chain().GetHeader(header.Hash()) == nil {
+			t.Fatalf("header #%v missing from the light chain", header.Number)
+		}
+	}
+	head = tester.chain.extend(10)
+	tester.mux.Post(core.ChainHeadEvent{Block: types.NewBlockWithHeader(head)})
+	tester.waitSync(t, head)
+}
+
+// Tests that a light node can retrieve accounts, contract code and storage
+// entries on demand, verified against its header chain.
+func TestLightState(t *testing.T) {
+	tester := newLesTester(t, 16)
+	defer tester.stop()
+
+	tester.waitSync(t, tester.chain.chain[len(tester.chain.chain)-1])
+
+	full := state.New(tester.chain.root, tester.srcDb)
+	light := tester.light.State()
+
+	for i := byte(1); i <= 34; i++ {
+		addr := common.BytesToAddress([]byte{i})
+
+		balance, err := light.GetBalance(addr)
+		if err != nil {
+			t.Fatalf("account %x: failed to retrieve balance: %v", addr, err)
+		}
+		if want := full.GetBalance(addr); balance.Cmp(want) != 0 {
+			t.Errorf("account %x: balance mismatch: have %v, want %v", addr, balance, want)
+		}
+		nonce, err := light.GetNonce(addr)
+		if err != nil {
+			t.Fatalf("account %x: failed to retrieve nonce: %v", addr, err)
+		}
+		if want := full.GetNonce(addr); nonce != want {
+			t.Errorf("account %x: nonce mismatch: have %v, want %v", addr, nonce, want)
+		}
+		code, err := light.GetCode(addr)
+		if err != nil {
+			t.Fatalf("account %x: failed to retrieve code: %v", addr, err)
+		}
+		if want := full.GetCode(addr); !bytes.Equal(code, want) {
+			t.Errorf("account %x: code mismatch: have %x, want %x", addr, code, want)
+		}
+		for _, key := range []common.Hash{common.BytesToHash([]byte{i}), common.BytesToHash([]byte{i, i}), common.BytesToHash([]byte{0xff})} {
+			value, err := light.GetState(addr, key)
+			if err != nil {
+				t.Fatalf("account %x, key %x: failed to retrieve storage: %v", addr, key, err)
+			}
+			if want := full.GetState(addr, key); !bytes.Equal(value, want) {
+				t.Errorf("account %x, key %x: storage mismatch: have %x, want %x", addr, key, value, want)
+			}
+		}
+	}
+}
+
+// Tests that a light node can retrieve and verify block receipts on demand.
+func TestLightReceipts(t *testing.T) {
+	tester := newLesTester(t, 16)
+	defer tester.stop()
+
+	tester.waitSync(t, tester.chain.chain[len(tester.chain.chain)-1])
+
+	for _, header := range tester.chain.chain {
+		receipts, err := tester.light.GetBlockReceipts(header.Hash())
+		if err != nil {
+			t.Fatalf("block #%v: failed to retrieve receipts: %v", header.Number, err)
+		}
+		if have, want := types.DeriveSha(receipts), header.ReceiptHash; have != want {
+			t.Errorf("block #%v: receipt root mismatch: have %x, want %x", header.Number, have, want)
+		}
+	}
+	if _, err := tester.light.GetBlockReceipts(common.Hash{0x01}); err != errUnknownBlock {
+		t.Errorf("unknown block error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+}
+
+// Tests that a server rejects requests exceeding the client's buffer.
+func TestRequestRejected(t *testing.T) {
+	srcDb, _ := ethdb.NewMemDatabase()
+	root := core.MakeState(srcDb)
+	chain := newTestChain(root, 16)
+	server := NewServer(0, chain, srcDb, new(event.TypeMux))
+	server.params = &flowcontrol.ServerParams{
+		BufLimit:    server.costTable.cost(GetBlockHeadersMsg, MaxHeaderFetch),
+		MinRecharge: 1,
+	}
+	app, net := p2p.MsgPipe()
+	errc := make(chan error, 1)
+	go func() { errc <- server.runPeer(testPeer(), app) }()
+
+	td, head, genesis := chain.Status()
+	client := newPeer(testPeer(), net)
+	if _, err := client.handshake(0, &statusData{ProtocolVersion: ProtocolVersion, TD: td, CurrentBlock: head, GenesisBlock: genesis}); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	// The first request fits into the buffer, the second one doesn't
+	if err := p2p.Send(net, GetBlockHeadersMsg, &getBlockHeadersData{1, head, MaxHeaderFetch}); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	msg, err := net.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	msg.Discard()
+	go p2p.Send(net, GetBlockHeadersMsg, &getBlockHeadersData{2, head, MaxHeaderFetch})
+
+	select {
+	case err := <-errc:
+		if perr, ok := err.(*errs.Error); !ok || perr.Code != ErrRequestRejected {
+			t.Errorf("error mismatch: have %v, want code %d", err, ErrRequestRejected)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("overflowing request not rejected")
+	}
+}
+
+// Tests that tampered proofs and mismatching replies are not accepted.
+func TestInvalidProof(t *testing.T) {
+	srcDb, _ := ethdb.NewMemDatabase()
+	root := core.MakeState(srcDb)
+	header := &types.Header{Root: root}
+	addr := common.BytesToAddress([]byte{4})
+
+	statedb := state.New(root, srcDb)
+	proof := append(statedb.GetProof(addr), statedb.GetStorageProof(addr, common.BytesToHash([]byte{4}))...)
+
+	req := &ProofRequest{Header: header, Addr: addr, Key: []byte{4}}
+	if !req.valid(ProofsMsg, [][][]byte{proof}) {
+		t.Fatalf("valid proof rejected")
+	}
+	if !bytes.Equal(common.NewValueFromBytes(req.Value).Bytes(), []byte{5}) {
+		t.Fatalf("storage value mismatch: have %x, want %x", req.Value, []byte{5})
+	}
+	if req.valid(CodeMsg, [][][]byte{proof}) {
+		t.Errorf("proof accepted as reply of the wrong type")
+	}
+	if req.valid(ProofsMsg, [][][]byte{proof[1:]}) {
+		t.Errorf("incomplete proof accepted")
+	}
+	tampered := make([][]byte, len(proof))
+	copy(tampered, proof)
+	tampered[len(tampered)-1] = append(common.CopyBytes(tampered[len(tampered)-1][:len(tampered[len(tampered)-1])-1]), 0xff)
+	if req.valid(ProofsMsg, [][][]byte{tampered}) {
+		t.Errorf("tampered proof accepted")
+	}
+}
@@ -0,0 +1,165 @@
+package les
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	headerPrefix = []byte("light-header-") // headerPrefix + hash -> header and total difficulty
+	headKey      = []byte("light-head")    // hash of the current head header
+
+	errUnknownParent = errors.New("unknown parent")
+	errUnknownBlock  = errors.New("unknown block")
+)
+
+// headerValidatorFn is a callback type for verifying a header against its
+// parent (difficulty, gas limit, proof of work).
+type headerValidatorFn func(header, parent *types.Header) error
+
+// headerEntry is the database representation of a header and its total
+// difficulty.
+type headerEntry struct {
+	Header *types.Header
+	TD     *big.Int
+}
+
+// LightChain is the header chain kept by a light node. Headers are accepted
+// only if they link up with a known parent and pass validation, and the one
+// with the highest total difficulty becomes the head.
+type LightChain struct {
+	db       common.Database
+	genesis  *types.Header
+	validate headerValidatorFn
+
+	mu      sync.RWMutex
+	current *types.Header
+	td      *big.Int
+}
+
+// NewLightChain creates a header chain on top of the given database, starting
+// from the genesis block if the database is empty.
+func NewLightChain(db common.Database, genesis *types.Block, validate headerValidatorFn) *LightChain {
+	self := &LightChain{
+		db:       db,
+		genesis:  genesis.Header(),
+		validate: validate,
+	}
+	if self.getEntry(genesis.Hash()) == nil {
+		self.putEntry(&headerEntry{genesis.Header(), genesis.Difficulty()})
+	}
+	self.current, self.td = self.genesis, genesis.Difficulty()
+
+	if data, _ := db.Get(headKey); len(data) > 0 {
+		if entry := self.getEntry(common.BytesToHash(data)); entry != nil {
+			self.current, self.td = entry.Header, entry.TD
+		}
+	}
+	glog.V(logger.Info).Infof("Light chain head #%v [%x…] TD=%v", self.current.Number, self.current.Hash().Bytes()[:4], self.td)
+
+	return self
+}
+
+// Genesis returns the genesis header of the chain.
+func (self *LightChain) Genesis() *types.Header {
+	return self.genesis
+}
+
+// CurrentHeader returns the head of the chain.
+func (self *LightChain) CurrentHeader() *types.Header {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	return self.current
+}
+
+// Td returns the total difficulty of the head of the chain.
+func (self *LightChain) Td() *big.Int {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	return new(big.Int).Set(self.td)
+}
+
+// Status returns the total difficulty and hash of the head of the chain along
+// with the genesis hash.
+func (self *LightChain) Status() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	return new(big.Int).Set(self.td), self.current.Hash(), self.genesis.Hash()
+}
+
+// GetHeader retrieves a known header by hash.
+func (self *LightChain) GetHeader(hash common.Hash) *types.Header {
+	if entry := self.getEntry(hash); entry != nil {
+		return entry.Header
+	}
+	return nil
+}
+
+// HasHeader checks whether a header is known.
+func (self *LightChain) HasHeader(hash common.Hash) bool {
+	data, _ := self.db.Get(append(headerPrefix, hash[:]...))
+	return len(data) > 0
+}
+
+// InsertHeaderChain validates and stores a batch of headers ordered by
+// ascending number, each of which must link up with a previously known or
+// inserted header. It returns the index of the failing header on error.
+func (self *LightChain) InsertHeaderChain(headers []*types.Header) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for i, header := range headers {
+		hash := header.Hash()
+		if self.HasHeader(hash) {
+			continue
+		}
+		parent := self.getEntry(header.ParentHash)
+		if parent == nil {
+			return i, fmt.Errorf("header #%v [%x…]: %v", header.Number, hash[:4], errUnknownParent)
+		}
+		if err := self.validate(header, parent.Header); err != nil {
+			return i, fmt.Errorf("header #%v [%x…]: %v", header.Number, hash[:4], err)
+		}
+		td := new(big.Int).Add(parent.TD, header.Difficulty)
+		self.putEntry(&headerEntry{header, td})
+
+		if td.Cmp(self.td) > 0 {
+			self.current, self.td = header, td
+			self.db.Put(headKey, hash[:])
+		}
+	}
+	return len(headers), nil
+}
+
+func (self *LightChain) getEntry(hash common.Hash) *headerEntry {
+	data, _ := self.db.Get(append(headerPrefix, hash[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	entry := new(headerEntry)
+	if err := rlp.DecodeBytes(data, entry); err != nil {
+		glog.V(logger.Error).Infof("invalid light header entry %x: %v", hash, err)
+		return nil
+	}
+	return entry
+}
+
+func (self *LightChain) putEntry(entry *headerEntry) {
+	data, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		panic(err)
+	}
+	hash := entry.Header.Hash()
+	self.db.Put(append(headerPrefix, hash[:]...), data)
+}
@@ -0,0 +1,285 @@
+package les
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// requestTimeout is the time allowed for a server to answer a request before
+// it is retried with another server.
+var requestTimeout = 10 * time.Second
+
+var (
+	errNoPeers      = errors.New("no suitable peers available")
+	errNotDelivered = errors.New("request not answered by any peer")
+)
+
+// odrRequest is a piece of data retrieved on demand from a server and
+// verified before being accepted.
+type odrRequest interface {
+	// send requests the data from a server under the given request id.
+	send(reqID uint64, p *peer) error
+
+	// valid checks whether the reply message delivered for the request
+	// is correct, retaining the results if so.
+	valid(code uint64, reply interface{}) bool
+}
+
+// pendingRequest is a request sent to a server and waiting for its reply.
+type pendingRequest struct {
+	peer    *peer
+	deliver chan *delivery
+}
+
+// delivery is a reply message delivered by a server.
+type delivery struct {
+	code  uint64
+	reply interface{}
+}
+
+// odr dispatches on demand retrieval requests to the connected servers and
+// matches the replies to them.
+type odr struct {
+	lock    sync.Mutex
+	peers   map[string]*peer
+	pending map[uint64]*pendingRequest
+	nextID  uint64
+}
+
+func newOdr() *odr {
+	return &odr{
+		peers:   make(map[string]*peer),
+		pending: make(map[uint64]*pendingRequest),
+	}
+}
+
+func (self *odr) register(p *peer) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.peers[p.id] = p
+}
+
+func (self *odr) unregister(p *peer) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	delete(self.peers, p.id)
+}
+
+// deliver hands a reply received from a server to the request waiting for
+// it. Replies to unknown (e.g. timed out) requests are dropped.
+func (self *odr) deliver(p *peer, code, reqID uint64, reply interface{}) {
+	self.lock.Lock()
+	req, ok := self.pending[reqID]
+	if ok && req.peer == p {
+		delete(self.pending, reqID)
+	}
+	self.lock.Unlock()
+
+	if !ok || req.peer != p {
+		glog.V(logger.Debug).Infof("peer %s: dropping unrequested reply %d (code %d)", p.id, reqID, code)
+		return
+	}
+	req.deliver <- &delivery{code, reply}
+}
+
+// retrieve fetches the data of a request from any connected server, retrying
+// with the next one if a server fails to deliver valid data.
+func (self *odr) retrieve(req odrRequest) error {
+	self.lock.Lock()
+	peers := make([]*peer, 0, len(self.peers))
+	for _, p := range self.peers {
+		peers = append(peers, p)
+	}
+	self.lock.Unlock()
+
+	if len(peers) == 0 {
+		return errNoPeers
+	}
+	for _, p := range peers {
+		if self.retrieveFrom(p, req) == nil {
+			return nil
+		}
+	}
+	return errNotDelivered
+}
+
+// retrieveFrom fetches the data of a request from a single server. Servers
+// delivering invalid data are disconnected.
+func (self *odr) retrieveFrom(p *peer, req odrRequest) error {
+	pending := &pendingRequest{peer: p, deliver: make(chan *delivery, 1)}
+
+	self.lock.Lock()
+	self.nextID++
+	reqID := self.nextID
+	self.pending[reqID] = pending
+	self.lock.Unlock()
+
+	defer func() {
+		self.lock.Lock()
+		delete(self.pending, reqID)
+		self.lock.Unlock()
+	}()
+
+	if err := req.send(reqID, p); err != nil {
+		p.fcServer.CancelRequest(reqID)
+		return err
+	}
+	select {
+	case d := <-pending.deliver:
+		if !req.valid(d.code, d.reply) {
+			p.Disconnect(p2p.DiscSubprotocolError)
+			return p.protoError(ErrUnexpectedResponse, "invalid reply to request %d (code %d)", reqID, d.code)
+		}
+		return nil
+
+	case <-time.After(requestTimeout):
+		p.fcServer.CancelRequest(reqID)
+		glog.V(logger.Debug).Infof("peer %s: request %d timed out", p.id, reqID)
+		return errNotDelivered
+	}
+}
+
+// headersRequest retrieves a header and its ancestors from a server.
+type headersRequest struct {
+	Hash   common.Hash
+	Amount int
+
+	Headers []*types.Header // Retrieved headers, ordered by descending number
+}
+
+func (self *headersRequest) send(reqID uint64, p *peer) error {
+	return p.requestHeaders(reqID, self.Hash, self.Amount)
+}
+
+func (self *headersRequest) valid(code uint64, reply interface{}) bool {
+	headers, ok := reply.([]*types.Header)
+	if code != BlockHeadersMsg || !ok || len(headers) == 0 || len(headers) > self.Amount {
+		return false
+	}
+	if headers[0].Hash() != self.Hash {
+		return false
+	}
+	for i := 1; i < len(headers); i++ {
+		if headers[i-1].ParentHash != headers[i].Hash() {
+			return false
+		}
+	}
+	self.Headers = headers
+	return true
+}
+
+// ReceiptsRequest retrieves the transaction receipts of a block, verified
+// against the receipt root of its header.
+type ReceiptsRequest struct {
+	Header *types.Header
+
+	Receipts types.Receipts // Retrieved receipts
+}
+
+func (self *ReceiptsRequest) send(reqID uint64, p *peer) error {
+	return p.requestReceipts(reqID, []common.Hash{self.Header.Hash()})
+}
+
+func (self *ReceiptsRequest) valid(code uint64, reply interface{}) bool {
+	receipts, ok := reply.([]types.Receipts)
+	if code != ReceiptsMsg || !ok || len(receipts) != 1 {
+		return false
+	}
+	if types.DeriveSha(receipts[0]) != self.Header.ReceiptHash {
+		return false
+	}
+	self.Receipts = receipts[0]
+	return true
+}
+
+// CodeRequest retrieves a contract code by its hash.
+type CodeRequest struct {
+	Hash common.Hash
+
+	Data []byte // Retrieved code
+}
+
+func (self *CodeRequest) send(reqID uint64, p *peer) error {
+	return p.requestCode(reqID, []common.Hash{self.Hash})
+}
+
+func (self *CodeRequest) valid(code uint64, reply interface{}) bool {
+	data, ok := reply.([][]byte)
+	if code != CodeMsg || !ok || len(data) != 1 {
+		return false
+	}
+	if common.BytesToHash(crypto.Sha3(data[0])) != self.Hash {
+		return false
+	}
+	self.Data = data[0]
+	return true
+}
+
+// Account is the content of an account in the state trie.
+type Account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// ProofRequest retrieves an account from the state of a block, and optionally
+// one of its storage entries, verifying their merkle proofs against the state
+// root of the block's header.
+type ProofRequest struct {
+	Header *types.Header
+	Addr   common.Address
+	Key    []byte // Storage key to retrieve, nil if the account suffices
+
+	Account *Account // Retrieved account, nil if it doesn't exist
+	Value   []byte   // Retrieved raw storage value, nil if it doesn't exist
+}
+
+func (self *ProofRequest) send(reqID uint64, p *peer) error {
+	return p.requestProofs(reqID, []*ProofReq{{self.Header.Hash(), self.Addr[:], self.Key}})
+}
+
+func (self *ProofRequest) valid(code uint64, reply interface{}) bool {
+	proofs, ok := reply.([][][]byte)
+	if code != ProofsMsg || !ok || len(proofs) != 1 {
+		return false
+	}
+	data, err := trie.VerifyProof(self.Header.Root, crypto.Sha3(self.Addr[:]), proofs[0])
+	if err != nil {
+		glog.V(logger.Debug).Infof("invalid account proof: %v", err)
+		return false
+	}
+	self.Account, self.Value = nil, nil
+	if data == nil {
+		return true
+	}
+	account := new(Account)
+	if err := rlp.Decode(bytes.NewReader(data), account); err != nil {
+		return false
+	}
+	self.Account = account
+
+	if len(self.Key) == 0 {
+		return true
+	}
+	key := common.BytesToHash(self.Key)
+	if self.Value, err = trie.VerifyProof(account.Root, crypto.Sha3(key[:]), proofs[0]); err != nil {
+		glog.V(logger.Debug).Infof("invalid storage proof: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package les
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/errs"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+var errRequestTooCostly = errors.New("request exceeds the server's buffer limit")
+
+// peer is a remote node running the les protocol, either a light client
+// connected to our server or a server our light node retrieves data from.
+type peer struct {
+	*p2p.Peer
+	rw     p2p.MsgReadWriter
+	id     string
+	errors *errs.Errors
+
+	headLock sync.RWMutex
+	headHash common.Hash
+	headTd   *big.Int
+
+	fcClient *flowcontrol.ClientNode // Server side: request buffer of the client
+	fcServer *flowcontrol.ServerNode // Light side: estimate of our buffer at the server
+	fcCosts  requestCostTable        // Light side: request costs announced by the server
+}
+
+func newPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	id := p.ID()
+	return &peer{
+		Peer: p,
+		rw:   rw,
+		id:   fmt.Sprintf("%x", id[:8]),
+		errors: &errs.Errors{
+			Package: "LES",
+			Errors:  errorToString,
+		},
+	}
+}
+
+// handshake exchanges the status messages with the remote peer, verifying
+// that both sides are on the same chain and network.
+func (self *peer) handshake(networkId int, status *statusData) (*statusData, error) {
+	// Send our status concurrently, writes may block until the remote reads
+	errc := make(chan error, 1)
+	go func() {
+		errc <- p2p.Send(self.rw, StatusMsg, status)
+	}()
+
+	msg, err := self.rw.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Discard()
+
+	if msg.Code != StatusMsg {
+		return nil, self.protoError(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return nil, self.protoError(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	var remote statusData
+	if err := msg.Decode(&remote); err != nil {
+		return nil, self.protoError(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if remote.GenesisBlock != status.GenesisBlock {
+		return nil, self.protoError(ErrGenesisBlockMismatch, "%x (!= %x)", remote.GenesisBlock, status.GenesisBlock)
+	}
+	if int(remote.NetworkId) != networkId {
+		return nil, self.protoError(ErrNetworkIdMismatch, "%d (!= %d)", remote.NetworkId, networkId)
+	}
+	if remote.ProtocolVersion != ProtocolVersion {
+		return nil, self.protoError(ErrProtocolVersionMismatch, "%d (!= %d)", remote.ProtocolVersion, ProtocolVersion)
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	self.setHead(remote.CurrentBlock, remote.TD)
+
+	glog.V(logger.Detail).Infof("peer %s: [les] capable, TD=%v H=%x", self.id, remote.TD, remote.CurrentBlock[:4])
+	return &remote, nil
+}
+
+// head retrieves the hash and total difficulty of the peer's current head.
+func (self *peer) head() (common.Hash, *big.Int) {
+	self.headLock.RLock()
+	defer self.headLock.RUnlock()
+
+	return self.headHash, new(big.Int).Set(self.headTd)
+}
+
+// setHead updates the peer's current head.
+func (self *peer) setHead(hash common.Hash, td *big.Int) {
+	self.headLock.Lock()
+	defer self.headLock.Unlock()
+
+	self.headHash, self.headTd = hash, new(big.Int).Set(td)
+}
+
+// request sends a request of amount items to a server, waiting first until
+// our estimated buffer at the server allows it to be served.
+func (self *peer) request(code, reqID uint64, amount int, data interface{}) error {
+	cost := self.fcCosts.cost(code, amount)
+	for {
+		wait := self.fcServer.CanSend(cost)
+		if wait == 0 {
+			break
+		}
+		if wait < 0 {
+			return errRequestTooCostly
+		}
+		time.Sleep(wait)
+	}
+	self.fcServer.QueueRequest(reqID, cost)
+
+	return p2p.Send(self.rw, code, data)
+}
+
+func (self *peer) requestHeaders(reqID uint64, from common.Hash, amount int) error {
+	glog.V(logger.Debug).Infof("peer %s: fetching %d headers from %x", self.id, amount, from[:4])
+	return self.request(GetBlockHeadersMsg, reqID, amount, &getBlockHeadersData{reqID, from, uint64(amount)})
+}
+
+func (self *peer) requestReceipts(reqID uint64, hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("peer %s: fetching %d block receipts", self.id, len(hashes))
+	return self.request(GetReceiptsMsg, reqID, len(hashes), &getHashesData{reqID, hashes})
+}
+
+func (self *peer) requestCode(reqID uint64, hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("peer %s: fetching %d contract codes", self.id, len(hashes))
+	return self.request(GetCodeMsg, reqID, len(hashes), &getHashesData{reqID, hashes})
+}
+
+func (self *peer) requestProofs(reqID uint64, reqs []*ProofReq) error {
+	glog.V(logger.Debug).Infof("peer %s: fetching %d merkle proofs", self.id, len(reqs))
+	return self.request(GetProofsMsg, reqID, len(reqs), &getProofsData{reqID, reqs})
+}
+
+func (self *peer) protoError(code int, format string, params ...interface{}) (err *errs.Error) {
+	err = self.errors.New(code, format, params...)
+	err.Log(glog.V(logger.Info))
+	return
+}
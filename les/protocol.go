@@ -0,0 +1,169 @@
+// Package les implements the Light Ethereum Subprotocol. Server nodes answer
+// header, receipt, code and merkle proof requests of light nodes, which only
+// keep a header chain and retrieve any state they need on demand, verifying
+// it against the state roots of their headers.
+package les
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	ProtocolVersion    = 1
+	ProtocolLength     = 10
+	ProtocolMaxMsgSize = 10 * 1024 * 1024
+
+	MaxHeaderFetch  = 192 // Amount of block headers to be fetched per request
+	MaxReceiptFetch = 128 // Amount of transaction receipts to be fetched per request
+	MaxCodeFetch    = 64  // Amount of contract codes to be fetched per request
+	MaxProofsFetch  = 64  // Amount of merkle proofs to be fetched per request
+)
+
+// les protocol message codes
+const (
+	StatusMsg = iota
+	AnnounceMsg
+	GetBlockHeadersMsg
+	BlockHeadersMsg
+	GetReceiptsMsg
+	ReceiptsMsg
+	GetCodeMsg
+	CodeMsg
+	GetProofsMsg
+	ProofsMsg
+)
+
+const (
+	ErrMsgTooLarge = iota
+	ErrDecode
+	ErrInvalidMsgCode
+	ErrProtocolVersionMismatch
+	ErrNetworkIdMismatch
+	ErrGenesisBlockMismatch
+	ErrNoStatusMsg
+	ErrExtraStatusMsg
+	ErrUselessPeer
+	ErrRequestRejected
+	ErrUnexpectedResponse
+)
+
+var errorToString = map[int]string{
+	ErrMsgTooLarge:             "Message too long",
+	ErrDecode:                  "Invalid message",
+	ErrInvalidMsgCode:          "Invalid message code",
+	ErrProtocolVersionMismatch: "Protocol version mismatch",
+	ErrNetworkIdMismatch:       "NetworkId mismatch",
+	ErrGenesisBlockMismatch:    "Genesis block mismatch",
+	ErrNoStatusMsg:             "No status message",
+	ErrExtraStatusMsg:          "Extra status message",
+	ErrUselessPeer:             "Useless peer",
+	ErrRequestRejected:         "Request rejected",
+	ErrUnexpectedResponse:      "Unexpected response",
+}
+
+// statusData is the handshake message of the protocol. Servers also announce
+// the flow control parameters and request costs they apply to the client,
+// light nodes leave them empty.
+type statusData struct {
+	ProtocolVersion uint32
+	NetworkId       uint32
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+	BufLimit        uint64
+	MinRecharge     uint64
+	CostList        requestCostList
+}
+
+// announceData is the network packet announcing a new chain head.
+type announceData struct {
+	Hash   common.Hash
+	Number uint64
+	TD     *big.Int
+}
+
+// getBlockHeadersData requests the header of the block with the given hash
+// followed by the headers of its ancestors.
+type getBlockHeadersData struct {
+	ReqID  uint64
+	Hash   common.Hash
+	Amount uint64
+}
+
+// getHashesData requests entries (receipts or code) identified by hash.
+type getHashesData struct {
+	ReqID  uint64
+	Hashes []common.Hash
+}
+
+// ProofReq requests the merkle proof of an account in the state of a block,
+// and optionally the proof of one of its storage entries. Keys are unhashed.
+type ProofReq struct {
+	BHash  common.Hash
+	AccKey []byte
+	Key    []byte
+}
+
+// getProofsData requests a batch of merkle proofs.
+type getProofsData struct {
+	ReqID uint64
+	Reqs  []*ProofReq
+}
+
+// Every reply carries the id of the request it answers and the remaining
+// flow control buffer value of the client.
+
+type blockHeadersData struct {
+	ReqID, BV uint64
+	Headers   []*types.Header
+}
+
+type receiptsData struct {
+	ReqID, BV uint64
+	Receipts  []types.Receipts
+}
+
+type codeData struct {
+	ReqID, BV uint64
+	Data      [][]byte
+}
+
+type proofsData struct {
+	ReqID, BV uint64
+	Proofs    [][][]byte
+}
+
+// requestCostList is the network encoding of the costs of the requests.
+type requestCostList []struct {
+	MsgCode, BaseCost, ReqCost uint64
+}
+
+// requestCosts is the cost of a request: a fixed base cost and a cost for
+// every item requested.
+type requestCosts struct {
+	baseCost, reqCost uint64
+}
+
+type requestCostTable map[uint64]*requestCosts
+
+// decode converts a cost list received over the network into a lookup table.
+func (list requestCostList) decode() requestCostTable {
+	table := make(requestCostTable)
+	for _, e := range list {
+		table[e.MsgCode] = &requestCosts{e.BaseCost, e.ReqCost}
+	}
+	return table
+}
+
+// cost returns the maximum cost of a request for amount items, or zero if the
+// request is unknown.
+func (table requestCostTable) cost(code uint64, amount int) uint64 {
+	costs, ok := table[code]
+	if !ok {
+		return 0
+	}
+	return costs.baseCost + uint64(amount)*costs.reqCost
+}
@@ -0,0 +1,273 @@
+package les
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// defaultServerParams are the flow control parameters assigned to clients.
+var defaultServerParams = flowcontrol.ServerParams{
+	BufLimit:    300000000,
+	MinRecharge: 50000,
+}
+
+// defaultCosts are the request costs charged to clients.
+var defaultCosts = requestCostList{
+	{GetBlockHeadersMsg, 150000, 30000},
+	{GetReceiptsMsg, 150000, 100000},
+	{GetCodeMsg, 150000, 200000},
+	{GetProofsMsg, 150000, 250000},
+}
+
+// serverChain is the interface of the full chain the server answers requests
+// from, implemented by core.ChainManager.
+type serverChain interface {
+	Status() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash)
+	GetHeadersFromHash(hash common.Hash, amount uint64) []*types.Header
+	GetHeader(hash common.Hash) *types.Header
+	GetBlockReceipts(hash common.Hash) types.Receipts
+}
+
+// Server answers the requests of light nodes from a full chain and its state
+// database, announcing new chain heads to them.
+type Server struct {
+	networkId int
+	chain     serverChain
+	stateDb   common.Database
+	eventMux  *event.TypeMux
+	headSub   event.Subscription
+
+	params    *flowcontrol.ServerParams
+	costs     requestCostList
+	costTable requestCostTable
+
+	lock  sync.RWMutex
+	peers map[string]*peer
+}
+
+// NewServer creates a light protocol server on top of a full chain.
+func NewServer(networkId int, chain serverChain, stateDb common.Database, eventMux *event.TypeMux) *Server {
+	params := defaultServerParams
+	return &Server{
+		networkId: networkId,
+		chain:     chain,
+		stateDb:   stateDb,
+		eventMux:  eventMux,
+		params:    &params,
+		costs:     defaultCosts,
+		costTable: defaultCosts.decode(),
+		peers:     make(map[string]*peer),
+	}
+}
+
+// Protocol returns the server side of the les protocol to be registered with
+// the p2p server.
+func (self *Server) Protocol() p2p.Protocol {
+	return p2p.Protocol{
+		Name:    "les",
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run:     self.runPeer,
+	}
+}
+
+// Start starts announcing new chain heads to the connected light nodes.
+func (self *Server) Start() {
+	self.headSub = self.eventMux.Subscribe(core.ChainHeadEvent{})
+	go self.announceLoop()
+}
+
+// Stop terminates the head announcements.
+func (self *Server) Stop() {
+	self.headSub.Unsubscribe() // quits announceLoop
+}
+
+func (self *Server) announceLoop() {
+	for obj := range self.headSub.Chan() {
+		block := obj.(core.ChainHeadEvent).Block
+		td, _, _ := self.chain.Status()
+		announce := &announceData{block.Hash(), block.NumberU64(), td}
+
+		self.lock.RLock()
+		for _, p := range self.peers {
+			go p2p.Send(p.rw, AnnounceMsg, announce)
+		}
+		self.lock.RUnlock()
+	}
+}
+
+// runPeer is the main loop serving a single light node.
+func (self *Server) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	peer := newPeer(p, rw)
+
+	td, head, genesis := self.chain.Status()
+	if _, err := peer.handshake(self.networkId, &statusData{
+		ProtocolVersion: ProtocolVersion,
+		NetworkId:       uint32(self.networkId),
+		TD:              td,
+		CurrentBlock:    head,
+		GenesisBlock:    genesis,
+		BufLimit:        self.params.BufLimit,
+		MinRecharge:     self.params.MinRecharge,
+		CostList:        self.costs,
+	}); err != nil {
+		return err
+	}
+	peer.fcClient = flowcontrol.NewClientNode(self.params)
+
+	self.lock.Lock()
+	self.peers[peer.id] = peer
+	self.lock.Unlock()
+
+	defer func() {
+		self.lock.Lock()
+		delete(self.peers, peer.id)
+		self.lock.Unlock()
+	}()
+
+	for {
+		if err := self.handleMsg(peer); err != nil {
+			return err
+		}
+	}
+}
+
+// accept charges a request of amount items to the client's buffer.
+func (self *Server) accept(p *peer, code uint64, amount int) (uint64, error) {
+	bv, ok := p.fcClient.AcceptRequest(self.costTable.cost(code, amount))
+	if !ok {
+		return 0, p.protoError(ErrRequestRejected, "code %d, %d items", code, amount)
+	}
+	return bv, nil
+}
+
+func (self *Server) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return p.protoError(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	// make sure that the payload has been fully consumed
+	defer msg.Discard()
+
+	switch msg.Code {
+	case StatusMsg:
+		return p.protoError(ErrExtraStatusMsg, "")
+
+	case GetBlockHeadersMsg:
+		var req getBlockHeadersData
+		if err := msg.Decode(&req); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if req.Amount > MaxHeaderFetch {
+			req.Amount = MaxHeaderFetch
+		}
+		bv, err := self.accept(p, msg.Code, int(req.Amount))
+		if err != nil {
+			return err
+		}
+		headers := self.chain.GetHeadersFromHash(req.Hash, req.Amount)
+		return p2p.Send(p.rw, BlockHeadersMsg, &blockHeadersData{req.ReqID, bv, headers})
+
+	case GetReceiptsMsg:
+		var req getHashesData
+		if err := msg.Decode(&req); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(req.Hashes) > MaxReceiptFetch {
+			req.Hashes = req.Hashes[:MaxReceiptFetch]
+		}
+		bv, err := self.accept(p, msg.Code, len(req.Hashes))
+		if err != nil {
+			return err
+		}
+		// receipts are matched to the requested hashes by position, so
+		// serving stops at the first block we don't have receipts for
+		receipts := make([]types.Receipts, 0, len(req.Hashes))
+		for _, hash := range req.Hashes {
+			results := self.chain.GetBlockReceipts(hash)
+			if results == nil {
+				if header := self.chain.GetHeader(hash); header == nil || header.ReceiptHash != emptyReceiptRoot {
+					break
+				}
+			}
+			receipts = append(receipts, results)
+		}
+		return p2p.Send(p.rw, ReceiptsMsg, &receiptsData{req.ReqID, bv, receipts})
+
+	case GetCodeMsg:
+		var req getHashesData
+		if err := msg.Decode(&req); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(req.Hashes) > MaxCodeFetch {
+			req.Hashes = req.Hashes[:MaxCodeFetch]
+		}
+		bv, err := self.accept(p, msg.Code, len(req.Hashes))
+		if err != nil {
+			return err
+		}
+		// code is matched to the requests by position, so serving stops
+		// at the first unknown entry
+		data := make([][]byte, 0, len(req.Hashes))
+		for _, hash := range req.Hashes {
+			code, _ := self.stateDb.Get(hash[:])
+			if len(code) == 0 {
+				break
+			}
+			data = append(data, code)
+		}
+		return p2p.Send(p.rw, CodeMsg, &codeData{req.ReqID, bv, data})
+
+	case GetProofsMsg:
+		var req getProofsData
+		if err := msg.Decode(&req); err != nil {
+			return p.protoError(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(req.Reqs) > MaxProofsFetch {
+			req.Reqs = req.Reqs[:MaxProofsFetch]
+		}
+		bv, err := self.accept(p, msg.Code, len(req.Reqs))
+		if err != nil {
+			return err
+		}
+		// proofs are matched to the requests by position, so serving stops
+		// at the first unknown block
+		proofs := make([][][]byte, 0, len(req.Reqs))
+		for i, r := range req.Reqs {
+			if r == nil {
+				return p.protoError(ErrDecode, "proof request %d is nil", i)
+			}
+			header := self.chain.GetHeader(r.BHash)
+			if header == nil {
+				break
+			}
+			statedb := state.New(header.Root, self.stateDb)
+			addr := common.BytesToAddress(r.AccKey)
+
+			proof := statedb.GetProof(addr)
+			if len(r.Key) > 0 {
+				proof = append(proof, statedb.GetStorageProof(addr, common.BytesToHash(r.Key))...)
+			}
+			proofs = append(proofs, proof)
+		}
+		return p2p.Send(p.rw, ProofsMsg, &proofsData{req.ReqID, bv, proofs})
+
+	default:
+		return p.protoError(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+}
+
+// emptyReceiptRoot is the receipt root of blocks without transactions, which
+// need no stored receipts to be served.
+var emptyReceiptRoot = types.DeriveSha(types.Receipts{})
@@ -0,0 +1,92 @@
+package les
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var emptyCodeHash = crypto.Sha3(nil)
+
+// LightState is a read only view of the state of a block. Accounts, storage
+// entries and contract code are retrieved on demand from the servers, and
+// verified against the state root of the block's header.
+type LightState struct {
+	header *types.Header
+	odr    *odr
+}
+
+// NewLightState creates a view of the state of the given header.
+func NewLightState(header *types.Header, odr *odr) *LightState {
+	return &LightState{header: header, odr: odr}
+}
+
+// Header returns the header of the block whose state is viewed.
+func (self *LightState) Header() *types.Header {
+	return self.header
+}
+
+// account retrieves an account, and optionally one of its storage entries.
+func (self *LightState) account(addr common.Address, key []byte) (*ProofRequest, error) {
+	req := &ProofRequest{Header: self.header, Addr: addr, Key: key}
+	if err := self.odr.retrieve(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetBalance retrieves the balance of an account, zero if it doesn't exist.
+func (self *LightState) GetBalance(addr common.Address) (*big.Int, error) {
+	req, err := self.account(addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.Account == nil {
+		return new(big.Int), nil
+	}
+	return req.Account.Balance, nil
+}
+
+// GetNonce retrieves the nonce of an account, zero if it doesn't exist.
+func (self *LightState) GetNonce(addr common.Address) (uint64, error) {
+	req, err := self.account(addr, nil)
+	if err != nil {
+		return 0, err
+	}
+	if req.Account == nil {
+		return 0, nil
+	}
+	return req.Account.Nonce, nil
+}
+
+// GetCode retrieves the contract code of an account, nil if it has none.
+func (self *LightState) GetCode(addr common.Address) ([]byte, error) {
+	req, err := self.account(addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.Account == nil || bytes.Equal(req.Account.CodeHash, emptyCodeHash) {
+		return nil, nil
+	}
+	code := &CodeRequest{Hash: common.BytesToHash(req.Account.CodeHash)}
+	if err := self.odr.retrieve(code); err != nil {
+		return nil, err
+	}
+	return code.Data, nil
+}
+
+// GetState retrieves a storage entry of an account, in the same format as
+// the full node's state.StateDB does.
+func (self *LightState) GetState(addr common.Address, key common.Hash) ([]byte, error) {
+	req, err := self.account(addr, key[:])
+	if err != nil {
+		return nil, err
+	}
+	if req.Account == nil {
+		return nil, nil
+	}
+	return common.NewValueFromBytes(req.Value).Bytes(), nil
+}
@@ -3,11 +3,10 @@ package miner
 import (
 	"sync"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
-	"github.com/ethereum/go-ethereum/pow"
 )
 
 type CpuMiner struct {
@@ -17,21 +16,24 @@ type CpuMiner struct {
 	quitCurrentOp chan struct{}
 	returnCh      chan<- *types.Block
 
-	index int
-	pow   pow.PoW
+	index  int
+	engine consensus.Engine
+	chain  consensus.ChainReader
 }
 
-func NewCpuMiner(index int, pow pow.PoW) *CpuMiner {
+// NewCpuMiner creates an agent which seals blocks of chain with engine.
+func NewCpuMiner(index int, engine consensus.Engine, chain consensus.ChainReader) *CpuMiner {
 	miner := &CpuMiner{
-		pow:   pow,
-		index: index,
+		engine: engine,
+		chain:  chain,
+		index:  index,
 	}
 
 	return miner
 }
 
 func (self *CpuMiner) Work() chan<- *types.Block          { return self.c }
-func (self *CpuMiner) Pow() pow.PoW                       { return self.pow }
+func (self *CpuMiner) Engine() consensus.Engine           { return self.engine }
 func (self *CpuMiner) SetReturnCh(ch chan<- *types.Block) { self.returnCh = ch }
 
 func (self *CpuMiner) Stop() {
@@ -85,16 +87,16 @@ func (self *CpuMiner) mine(block *types.Block) {
 	self.chMu.Unlock()
 
 	// Mine
-	nonce, mixDigest, _ := self.pow.Search(block, self.quitCurrentOp)
-	if nonce != 0 {
-		block.SetNonce(nonce)
-		block.Header().MixDigest = common.BytesToHash(mixDigest)
-		self.returnCh <- block
-	} else {
-		self.returnCh <- nil
+	sealed, err := self.engine.Seal(self.chain, block, self.quitCurrentOp)
+	if err != nil {
+		glog.V(logger.Warn).Infof("agent[%d]: sealing block #%v failed: %v\n", self.index, block.Number(), err)
 	}
+	self.returnCh <- sealed
 }
 
 func (self *CpuMiner) GetHashRate() int64 {
-	return self.pow.GetHashrate()
+	if pow, ok := self.engine.(consensus.PoW); ok {
+		return pow.Hashrate()
+	}
+	return 0
 }
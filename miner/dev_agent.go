@@ -0,0 +1,107 @@
+package miner
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// importTime is how long work for the number of the last sealed block is
+// considered to be built before that block was imported.
+const importTime = time.Second
+
+// DevAgent seals the blocks of development chains, whose engine needs no
+// proof of work. Work with transactions is sealed as soon as its time stamp
+// has come. Empty work is sealed every period, or never if period is zero.
+type DevAgent struct {
+	engine consensus.Engine
+	chain  consensus.ChainReader
+	period time.Duration
+
+	c        chan *types.Block
+	quit     chan struct{}
+	returnCh chan<- *types.Block
+}
+
+func NewDevAgent(engine consensus.Engine, chain consensus.ChainReader, period time.Duration) *DevAgent {
+	return &DevAgent{engine: engine, chain: chain, period: period}
+}
+
+func (self *DevAgent) Work() chan<- *types.Block          { return self.c }
+func (self *DevAgent) SetReturnCh(ch chan<- *types.Block) { self.returnCh = ch }
+func (self *DevAgent) GetHashRate() int64                 { return 0 }
+
+func (self *DevAgent) Start() {
+	self.quit = make(chan struct{})
+	self.c = make(chan *types.Block, 1)
+
+	go self.update()
+}
+
+func (self *DevAgent) Stop() {
+	close(self.quit)
+}
+
+func (self *DevAgent) update() {
+	var (
+		work  *types.Block
+		ready <-chan time.Time // fires when work is to be sealed
+		timer <-chan time.Time
+
+		last     *types.Block // last sealed block
+		sealedAt time.Time
+	)
+	if self.period > 0 {
+		ticker := time.NewTicker(self.period)
+		defer ticker.Stop()
+		timer = ticker.C
+	}
+
+	for {
+		select {
+		case block := <-self.c:
+			if last != nil && block.Number().Cmp(last.Number()) <= 0 && time.Since(sealedAt) < importTime {
+				// sealing it would make a sibling of the last block
+				continue
+			}
+			// newer work replaces the old, e.g. after a transaction
+			work, ready = block, nil
+			if len(block.Transactions()) > 0 {
+				ready = time.After(untilTime(block))
+			}
+		case <-timer:
+			if work != nil && ready == nil {
+				ready = time.After(untilTime(work))
+			}
+		case <-ready:
+			if self.seal(work) {
+				last, sealedAt = work, time.Now()
+			}
+			work, ready = nil, nil
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+func (self *DevAgent) seal(block *types.Block) bool {
+	sealed, err := self.engine.Seal(self.chain, block, self.quit)
+	if err != nil {
+		glog.V(logger.Warn).Infof("dev agent: sealing block #%v failed: %v\n", block.Number(), err)
+		return false
+	}
+	if sealed == nil {
+		return false
+	}
+	self.returnCh <- sealed
+	return true
+}
+
+// untilTime returns how long it takes until the time stamp of block, which
+// may be ahead of the clock if blocks are made faster than once a second.
+func untilTime(block *types.Block) time.Duration {
+	return time.Unix(int64(block.Time()), 0).Sub(time.Now())
+}
@@ -0,0 +1,124 @@
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newDevWork creates work for block number with the given time stamp,
+// carrying a transaction if withTx is set.
+func newDevWork(number int64, time uint64, withTx bool) *types.Block {
+	block := types.NewBlockWithHeader(&types.Header{
+		Number:     big.NewInt(number),
+		Difficulty: big.NewInt(1),
+		GasLimit:   big.NewInt(3141592),
+		GasUsed:    new(big.Int),
+		Time:       time,
+	})
+	if withTx {
+		tx := types.NewTransactionMessage(common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+		block.SetTransactions(types.Transactions{tx})
+	}
+	return block
+}
+
+// startDevAgent starts a dev agent sealing with the instant engine into the
+// returned channel.
+func startDevAgent(period time.Duration) (*DevAgent, chan *types.Block) {
+	returnCh := make(chan *types.Block, 10)
+	agent := NewDevAgent(consensus.NewInstant(), nil, period)
+	agent.SetReturnCh(returnCh)
+	agent.Start()
+	return agent, returnCh
+}
+
+func waitSealed(t *testing.T, returnCh chan *types.Block, want *types.Block, timeout time.Duration) {
+	select {
+	case block := <-returnCh:
+		if block != want {
+			t.Fatalf("sealed block #%v, want #%v", block.Number(), want.Number())
+		}
+	case <-time.After(timeout):
+		t.Fatalf("block #%v not sealed within %v", want.Number(), timeout)
+	}
+}
+
+func checkNotSealed(t *testing.T, returnCh chan *types.Block, wait time.Duration) {
+	select {
+	case block := <-returnCh:
+		t.Fatalf("sealed block #%v, want none", block.Number())
+	case <-time.After(wait):
+	}
+}
+
+func TestDevAgentSealsTransactions(t *testing.T) {
+	agent, returnCh := startDevAgent(0)
+	defer agent.Stop()
+
+	now := uint64(time.Now().Unix())
+	work := newDevWork(1, now, true)
+	agent.Work() <- work
+	waitSealed(t, returnCh, work, 500*time.Millisecond)
+
+	// without a period, empty work is never sealed
+	agent.Work() <- newDevWork(2, now, false)
+	checkNotSealed(t, returnCh, 200*time.Millisecond)
+}
+
+func TestDevAgentSealsEmptyEveryPeriod(t *testing.T) {
+	period := 100 * time.Millisecond
+	start := time.Now()
+	agent, returnCh := startDevAgent(period)
+	defer agent.Stop()
+
+	work := newDevWork(1, uint64(start.Unix()), false)
+	agent.Work() <- work
+	waitSealed(t, returnCh, work, 5*period)
+	if elapsed := time.Since(start); elapsed < period {
+		t.Errorf("empty block sealed after %v, before the period of %v", elapsed, period)
+	}
+}
+
+func TestDevAgentSkipsSiblings(t *testing.T) {
+	agent, returnCh := startDevAgent(0)
+	defer agent.Stop()
+
+	now := uint64(time.Now().Unix())
+	work := newDevWork(1, now, true)
+	agent.Work() <- work
+	waitSealed(t, returnCh, work, 500*time.Millisecond)
+
+	// work for the same number was built before the block got imported
+	agent.Work() <- newDevWork(1, now, true)
+	checkNotSealed(t, returnCh, 200*time.Millisecond)
+
+	work = newDevWork(2, now, true)
+	agent.Work() <- work
+	waitSealed(t, returnCh, work, 500*time.Millisecond)
+
+	// past the import time, the last block did not make it into the chain
+	time.Sleep(importTime)
+	work = newDevWork(2, now, true)
+	agent.Work() <- work
+	waitSealed(t, returnCh, work, 500*time.Millisecond)
+}
+
+func TestDevAgentWaitsForTime(t *testing.T) {
+	agent, returnCh := startDevAgent(0)
+	defer agent.Stop()
+
+	// the time stamp is at least a second ahead of the clock
+	stamp := uint64(time.Now().Unix()) + 2
+	work := newDevWork(1, stamp, true)
+	agent.Work() <- work
+	checkNotSealed(t, returnCh, 500*time.Millisecond)
+	waitSealed(t, returnCh, work, 3*time.Second)
+	if now := time.Now().Unix(); now < int64(stamp) {
+		t.Errorf("block with time %d sealed at %d", stamp, now)
+	}
+}
@@ -1,14 +1,16 @@
 package miner
 
 import (
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/pow"
 )
 
 type Miner struct {
@@ -18,15 +20,20 @@ type Miner struct {
 
 	mining bool
 	eth    core.Backend
-	pow    pow.PoW
+	engine consensus.Engine
+	am     *accounts.Manager
 }
 
-func New(eth core.Backend, pow pow.PoW, minerThreads int) *Miner {
+// New creates a miner for the chain of eth, which seals blocks with the
+// consensus engine of its block processor. Engines which seal with a
+// signature sign with the unlocked accounts of am.
+func New(eth core.Backend, am *accounts.Manager, minerThreads int) *Miner {
 	// note: minerThreads is currently ignored because
 	// ethash is not thread safe.
-	miner := &Miner{eth: eth, pow: pow, worker: newWorker(common.Address{}, eth)}
+	engine := eth.BlockProcessor().Engine()
+	miner := &Miner{eth: eth, engine: engine, am: am, worker: newWorker(common.Address{}, eth)}
 	for i := 0; i < minerThreads; i++ {
-		miner.worker.register(NewCpuMiner(i, pow))
+		miner.worker.register(NewCpuMiner(i, engine, eth.ChainManager()))
 	}
 
 	return miner
@@ -36,15 +43,32 @@ func (self *Miner) Mining() bool {
 	return self.mining
 }
 
-func (self *Miner) Start(coinbase common.Address) {
+// Start starts sealing blocks paying to coinbase. If the engine seals with
+// a signature, coinbase is also the signer and must be unlocked.
+func (self *Miner) Start(coinbase common.Address) error {
+	if engine, ok := self.engine.(consensus.Authorizer); ok {
+		if _, err := self.am.Policy(coinbase.Bytes()); err == accounts.ErrLocked {
+			return fmt.Errorf("signer %x is locked", coinbase)
+		}
+		am := self.am
+		engine.Authorize(coinbase, func(signer common.Address, hash []byte) ([]byte, error) {
+			return am.Sign(accounts.Account{Address: signer.Bytes()}, hash)
+		})
+	}
+
 	self.mining = true
 	self.worker.coinbase = coinbase
 
-	self.pow.(*ethash.Ethash).UpdateDAG()
+	if engine, ok := self.engine.(*consensus.ProofOfWork); ok {
+		if pow, ok := engine.PoW().(*ethash.Ethash); ok {
+			pow.UpdateDAG()
+		}
+	}
 
 	self.worker.start()
 
 	self.worker.commitNewWork()
+	return nil
 }
 
 func (self *Miner) Register(agent Agent) {
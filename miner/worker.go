@@ -9,13 +9,13 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
-	"github.com/ethereum/go-ethereum/pow"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -66,12 +66,12 @@ type worker struct {
 	recv   chan *types.Block
 	mux    *event.TypeMux
 	quit   chan struct{}
-	pow    pow.PoW
 	atWork int64
 
-	eth   core.Backend
-	chain *core.ChainManager
-	proc  *core.BlockProcessor
+	eth    core.Backend
+	chain  *core.ChainManager
+	proc   *core.BlockProcessor
+	engine consensus.Engine
 
 	coinbase common.Address
 	extra    []byte
@@ -95,6 +95,7 @@ func newWorker(coinbase common.Address, eth core.Backend) *worker {
 		recv:           make(chan *types.Block),
 		chain:          eth.ChainManager(),
 		proc:           eth.BlockProcessor(),
+		engine:         eth.BlockProcessor().Engine(),
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
 		txQueue:        make(map[common.Hash]*types.Transaction),
@@ -165,7 +166,9 @@ out:
 				self.possibleUncles[ev.Block.Hash()] = ev.Block
 				self.uncleMu.Unlock()
 			case core.TxPreEvent:
-				if atomic.LoadInt64(&self.mining) == 0 {
+				// a proof of work search isn't restarted for every
+				// transaction, other engines get work including it
+				if _, pow := self.engine.(consensus.PoW); !pow || atomic.LoadInt64(&self.mining) == 0 {
 					self.commitNewWork()
 				}
 			}
@@ -241,12 +244,16 @@ func (self *worker) makeCurrent() {
 	}
 	block.Header().Extra = self.extra
 
+	parent := self.chain.GetBlock(block.ParentHash())
+	if err := self.engine.Prepare(self.chain, block.Header(), parent.Header()); err != nil {
+		glog.V(logger.Debug).Infof("preparing block #%v failed: %v\n", block.Number(), err)
+	}
+
 	self.current = env(block, self.eth)
 	for _, ancestor := range self.chain.GetAncestors(block, 7) {
 		self.current.family.Add(ancestor.Hash())
 	}
 
-	parent := self.chain.GetBlock(self.current.block.ParentHash())
 	self.current.coinbase.SetGasPool(core.CalcGasLimit(parent, self.current.block))
 }
 
@@ -297,8 +304,10 @@ func (self *worker) commitNewWork() {
 		uncles    []*types.Header
 		badUncles []common.Hash
 	)
+	// only proof of work pays for uncles
+	_, pow := self.engine.(consensus.PoW)
 	for hash, uncle := range self.possibleUncles {
-		if len(uncles) == 2 {
+		if len(uncles) == 2 || !pow {
 			break
 		}
 
@@ -326,19 +335,13 @@ func (self *worker) commitNewWork() {
 
 	self.current.block.SetUncles(uncles)
 
-	core.AccumulateRewards(self.current.state, self.current.block)
+	self.engine.Finalize(self.chain, self.current.state, self.current.block)
 
 	self.current.state.Update()
 
 	self.push()
 }
 
-var (
-	inclusionReward = new(big.Int).Div(core.BlockReward, big.NewInt(32))
-	_uncleReward    = new(big.Int).Mul(core.BlockReward, big.NewInt(15))
-	uncleReward     = new(big.Int).Div(_uncleReward, big.NewInt(16))
-)
-
 func (self *worker) commitUncle(uncle *types.Header) error {
 	if self.current.uncles.Has(uncle.Hash()) {
 		// Error not unique
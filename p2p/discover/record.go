@@ -0,0 +1,150 @@
+package discover
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// maxRecordSize is the maximum encoded size of a node record. Records
+// are sent in a single UDP packet and must leave room for the packet
+// header.
+const maxRecordSize = 1024
+
+var (
+	errRecordTooBig  = errors.New("record too big")
+	errMissingSig    = errors.New("record not signed")
+	errUnsortedPairs = errors.New("record keys not sorted/unique")
+)
+
+// Record is a signed, versioned node record. In addition to the
+// endpoint of a node, it carries arbitrary key/value metadata which
+// nodes use to advertise their capabilities, e.g. which sub-protocols
+// they run.
+//
+// Every change to a record must increase its sequence number so
+// other nodes can tell which of two records is more recent. The
+// signature covers all fields except itself. The node ID is not part
+// of the encoding, it is recovered from the signature.
+type Record struct {
+	Seq   uint64
+	IP    net.IP
+	UDP   uint16
+	TCP   uint16
+	Pairs []Pair // sorted by key
+
+	Sig []byte
+	id  NodeID // set by Sign and Verify
+}
+
+// Pair is a key/value entry of a node record.
+type Pair struct {
+	Key   string
+	Value []byte
+}
+
+// recordContent is the signed part of a record.
+type recordContent struct {
+	Seq   uint64
+	IP    net.IP
+	UDP   uint16
+	TCP   uint16
+	Pairs []Pair
+}
+
+// ID returns the ID of the node that signed the record. It is only
+// valid after a successful call to Sign or Verify.
+func (r *Record) ID() NodeID {
+	return r.id
+}
+
+// Node returns the endpoint described by the record.
+func (r *Record) Node() *Node {
+	return &Node{ID: r.id, IP: r.IP, DiscPort: int(r.UDP), TCPPort: int(r.TCP)}
+}
+
+// Get returns the value stored for key or nil if the record has no
+// such key.
+func (r *Record) Get(key string) []byte {
+	i := sort.Search(len(r.Pairs), func(i int) bool { return r.Pairs[i].Key >= key })
+	if i < len(r.Pairs) && r.Pairs[i].Key == key {
+		return r.Pairs[i].Value
+	}
+	return nil
+}
+
+// Set adds or replaces the value of key. Changing the record
+// invalidates its signature, it must be signed again afterwards.
+func (r *Record) Set(key string, value []byte) {
+	r.Sig = nil
+	i := sort.Search(len(r.Pairs), func(i int) bool { return r.Pairs[i].Key >= key })
+	if i < len(r.Pairs) && r.Pairs[i].Key == key {
+		r.Pairs[i].Value = value
+		return
+	}
+	r.Pairs = append(r.Pairs, Pair{})
+	copy(r.Pairs[i+1:], r.Pairs[i:])
+	r.Pairs[i] = Pair{Key: key, Value: value}
+}
+
+// Sign signs the record with the given key.
+func (r *Record) Sign(priv *ecdsa.PrivateKey) error {
+	hash, err := r.sigHash()
+	if err != nil {
+		return err
+	}
+	sig, err := crypto.Sign(hash, priv)
+	if err != nil {
+		return err
+	}
+	r.Sig, r.id = sig, PubkeyID(&priv.PublicKey)
+	if enc, _ := rlp.EncodeToBytes(r); len(enc) > maxRecordSize {
+		r.Sig, r.id = nil, NodeID{}
+		return errRecordTooBig
+	}
+	return nil
+}
+
+// Verify checks the signature of the record and recovers the ID of
+// the signer.
+func (r *Record) Verify() error {
+	if len(r.Sig) == 0 {
+		return errMissingSig
+	}
+	for i := 1; i < len(r.Pairs); i++ {
+		if r.Pairs[i-1].Key >= r.Pairs[i].Key {
+			return errUnsortedPairs
+		}
+	}
+	hash, err := r.sigHash()
+	if err != nil {
+		return err
+	}
+	id, err := recoverNodeID(hash, r.Sig)
+	if err != nil {
+		return fmt.Errorf("invalid record signature: %v", err)
+	}
+	r.id = id
+	return nil
+}
+
+func (r *Record) sigHash() ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(recordContent{r.Seq, r.IP, r.UDP, r.TCP, r.Pairs})
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sha3(enc), nil
+}
+
+func (r *Record) copy() *Record {
+	cpy := *r
+	cpy.IP = append(net.IP(nil), r.IP...)
+	cpy.Pairs = append([]Pair(nil), r.Pairs...)
+	cpy.Sig = append([]byte(nil), r.Sig...)
+	return &cpy
+}
@@ -0,0 +1,90 @@
+package discover
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestRecord_signVerify(t *testing.T) {
+	key := newkey()
+	rec := &Record{Seq: 1, IP: net.IP{10, 0, 1, 16}, UDP: 30303, TCP: 30304}
+	rec.Set("shh", []byte{2})
+	rec.Set("eth", []byte{61})
+	if err := rec.Sign(key); err != nil {
+		t.Fatalf("sign error: %v", err)
+	}
+	if rec.ID() != PubkeyID(&key.PublicKey) {
+		t.Errorf("ID mismatch after Sign: got %v", rec.ID())
+	}
+
+	enc, err := rlp.EncodeToBytes(rec)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	dec := new(Record)
+	if err := rlp.DecodeBytes(enc, dec); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if err := dec.Verify(); err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+	if dec.ID() != PubkeyID(&key.PublicKey) {
+		t.Errorf("ID mismatch after Verify: got %v", dec.ID())
+	}
+	if !bytes.Equal(dec.Get("eth"), []byte{61}) || !bytes.Equal(dec.Get("shh"), []byte{2}) {
+		t.Errorf("pairs mismatch: %v", dec.Pairs)
+	}
+	if dec.Get("les") != nil {
+		t.Errorf("got value for missing key")
+	}
+	n := dec.Node()
+	if n.ID != dec.ID() || !n.IP.Equal(rec.IP) || n.DiscPort != 30303 || n.TCPPort != 30304 {
+		t.Errorf("node mismatch: %v", n)
+	}
+
+	// tampering with the content changes the recovered ID.
+	dec.Seq++
+	if err := dec.Verify(); err == nil && dec.ID() == PubkeyID(&key.PublicKey) {
+		t.Errorf("tampered record verified")
+	}
+}
+
+func TestRecord_set(t *testing.T) {
+	rec := new(Record)
+	for _, k := range []string{"c", "a", "d", "b", "a"} {
+		rec.Set(k, []byte(k))
+	}
+	want := []string{"a", "b", "c", "d"}
+	if len(rec.Pairs) != len(want) {
+		t.Fatalf("wrong number of pairs: got %d, want %d", len(rec.Pairs), len(want))
+	}
+	for i, k := range want {
+		if rec.Pairs[i].Key != k {
+			t.Errorf("pair %d: got key %q, want %q", i, rec.Pairs[i].Key, k)
+		}
+	}
+
+	// Set invalidates the signature.
+	rec.Sign(newkey())
+	rec.Set("a", nil)
+	if err := rec.Verify(); err != errMissingSig {
+		t.Errorf("got error %v, want %v", err, errMissingSig)
+	}
+}
+
+func TestRecord_invalid(t *testing.T) {
+	rec := new(Record)
+	rec.Set("big", make([]byte, maxRecordSize))
+	if err := rec.Sign(newkey()); err != errRecordTooBig {
+		t.Errorf("got error %v, want %v", err, errRecordTooBig)
+	}
+
+	rec = &Record{Pairs: []Pair{{"b", nil}, {"a", nil}}}
+	rec.Sign(newkey())
+	if err := rec.Verify(); err != errUnsortedPairs {
+		t.Errorf("got error %v, want %v", err, errUnsortedPairs)
+	}
+}
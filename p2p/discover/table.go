@@ -7,6 +7,8 @@
 package discover
 
 import (
+	"crypto/ecdsa"
+	"errors"
 	"net"
 	"sort"
 	"sync"
@@ -21,10 +23,15 @@ const (
 )
 
 type Table struct {
-	mutex   sync.Mutex        // protects buckets, their content, and nursery
+	mutex   sync.Mutex        // protects buckets, their content, nursery, rec and regtopics
 	buckets [nBuckets]*bucket // index of known nodes by distance
 	nursery []*Node           // bootstrap nodes
 
+	priv      *ecdsa.PrivateKey // signs rec, nil if there is no network listener
+	rec       *Record           // signed record of the local node
+	regtopics map[Topic]bool    // topics the local node is advertised under
+	topics    *topicTable       // advertisements registered by other nodes
+
 	bondmu    sync.Mutex
 	bonding   map[NodeID]*bondproc
 	bondslots chan struct{} // limits total number of active bonding processes
@@ -47,6 +54,9 @@ type transport interface {
 	ping(NodeID, *net.UDPAddr) error
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestRecord(toid NodeID, addr *net.UDPAddr) (*Record, error)
+	registerTopics(toid NodeID, addr *net.UDPAddr, topics []Topic) error
+	topicQuery(toid NodeID, addr *net.UDPAddr, topic Topic) ([]*Node, error)
 	close()
 }
 
@@ -65,6 +75,9 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr) *Table {
 		self:      newNode(ourID, ourAddr),
 		bonding:   make(map[NodeID]*bondproc),
 		bondslots: make(chan struct{}, maxBondingPingPongs),
+		rec:       &Record{IP: ourAddr.IP, UDP: uint16(ourAddr.Port), TCP: uint16(ourAddr.Port)},
+		regtopics: make(map[Topic]bool),
+		topics:    newTopicTable(),
 	}
 	for i := 0; i < cap(tab.bondslots); i++ {
 		tab.bondslots <- struct{}{}
@@ -80,6 +93,112 @@ func (tab *Table) Self() *Node {
 	return tab.self
 }
 
+// Record returns a copy of the signed record of the local node.
+func (tab *Table) Record() *Record {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+	return tab.rec.copy()
+}
+
+// SetRecordValue sets key to value in the record of the local node.
+// The record's sequence number is increased and it is signed again.
+// Nodes requesting our record afterwards will see the new value.
+func (tab *Table) SetRecordValue(key string, value []byte) error {
+	if tab.priv == nil {
+		return errors.New("no key to sign the node record")
+	}
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+	rec := tab.rec.copy()
+	rec.Set(key, value)
+	rec.Seq++
+	if err := rec.Sign(tab.priv); err != nil {
+		return err
+	}
+	tab.rec = rec
+	return nil
+}
+
+// RequestRecord retrieves the signed record of the given node.
+// An error is returned if the node does not respond or if the
+// record was not signed by the node.
+func (tab *Table) RequestRecord(n *Node) (*Record, error) {
+	if _, err := tab.bond(false, n.ID, n.addr(), uint16(n.TCPPort)); err != nil {
+		return nil, err
+	}
+	rec, err := tab.net.requestRecord(n.ID, n.addr())
+	if err != nil {
+		return nil, err
+	}
+	if err := rec.Verify(); err != nil {
+		return nil, err
+	}
+	if rec.ID() != n.ID {
+		return nil, errRecordMismatch
+	}
+	return rec, nil
+}
+
+// RegisterTopic advertises the local node under the given topic.
+// The advertisement is placed at the nodes closest to the hash of the
+// topic. It is renewed periodically until the table is closed.
+func (tab *Table) RegisterTopic(topic Topic) {
+	tab.mutex.Lock()
+	tab.regtopics[topic] = true
+	tab.mutex.Unlock()
+	tab.registerTopic(topic)
+}
+
+// SearchTopic performs a network search for nodes advertised under
+// the given topic. It asks the nodes closest to the hash of the topic
+// for the advertisements they store.
+func (tab *Table) SearchTopic(topic Topic) []*Node {
+	var (
+		closest = tab.Lookup(topic.target())
+		reply   = make(chan []*Node, len(closest))
+		seen    = map[NodeID]bool{tab.self.ID: true}
+		result  []*Node
+	)
+	for _, n := range closest {
+		go func(n *Node) {
+			r, _ := tab.net.topicQuery(n.ID, n.addr(), topic)
+			reply <- r
+		}(n)
+	}
+	// include advertisements stored by us
+	found := tab.topics.get(topic, maxTopicAds, time.Now())
+	for _ = range closest {
+		found = append(found, <-reply...)
+	}
+	for _, n := range found {
+		if !seen[n.ID] {
+			seen[n.ID] = true
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func (tab *Table) registerTopic(topic Topic) {
+	for _, n := range tab.Lookup(topic.target()) {
+		tab.net.registerTopics(n.ID, n.addr(), []Topic{topic})
+	}
+}
+
+// refreshTopics renews the advertisements of all registered topics
+// before they expire.
+func (tab *Table) refreshTopics() {
+	tab.mutex.Lock()
+	topics := make([]Topic, 0, len(tab.regtopics))
+	for topic := range tab.regtopics {
+		topics = append(topics, topic)
+	}
+	tab.mutex.Unlock()
+	for _, topic := range topics {
+		tab.registerTopic(topic)
+	}
+}
+
 // Close terminates the network listener.
 func (tab *Table) Close() {
 	tab.net.close()
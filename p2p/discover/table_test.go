@@ -125,6 +125,15 @@ func newPingRecorder() *pingRecorder {
 func (t *pingRecorder) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node, error) {
 	panic("findnode called on pingRecorder")
 }
+func (t *pingRecorder) requestRecord(toid NodeID, toaddr *net.UDPAddr) (*Record, error) {
+	panic("requestRecord called on pingRecorder")
+}
+func (t *pingRecorder) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	panic("registerTopics called on pingRecorder")
+}
+func (t *pingRecorder) topicQuery(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	panic("topicQuery called on pingRecorder")
+}
 func (t *pingRecorder) close() {
 	panic("close called on pingRecorder")
 }
@@ -273,6 +282,16 @@ func (t findnodeOracle) close()                                      {}
 func (t findnodeOracle) waitping(from NodeID) error                  { return nil }
 func (t findnodeOracle) ping(toid NodeID, toaddr *net.UDPAddr) error { return nil }
 
+func (t findnodeOracle) requestRecord(toid NodeID, toaddr *net.UDPAddr) (*Record, error) {
+	return nil, errTimeout
+}
+func (t findnodeOracle) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	return nil
+}
+func (t findnodeOracle) topicQuery(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	return nil, errTimeout
+}
+
 func hasDuplicates(slice []*Node) bool {
 	seen := make(map[NodeID]bool)
 	for _, e := range slice {
@@ -0,0 +1,90 @@
+package discover
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	topicTTL              = 15 * time.Minute // lifetime of a topic advertisement
+	topicRegisterInterval = 10 * time.Minute // re-registration interval, must be < topicTTL
+	maxTopicAds           = 64               // advertisements stored per topic
+	maxRegisterTopics     = 16               // topics accepted per topicRegister packet
+)
+
+// Topic is an identifier under which nodes can advertise themselves,
+// usually the name of a sub-protocol they run. Advertisements are
+// stored by the nodes closest to the hash of the topic.
+type Topic string
+
+// target returns the node ID closest to which advertisements for the
+// topic are stored.
+func (t Topic) target() (id NodeID) {
+	h := crypto.Sha3([]byte(t))
+	copy(id[:], h)
+	copy(id[len(h):], crypto.Sha3(h))
+	return id
+}
+
+// topicTable stores the advertisements that other nodes have
+// registered with us.
+type topicTable struct {
+	mu  sync.Mutex
+	ads map[Topic]map[NodeID]*topicAd
+}
+
+type topicAd struct {
+	node    *Node
+	expires time.Time
+}
+
+func newTopicTable() *topicTable {
+	return &topicTable{ads: make(map[Topic]map[NodeID]*topicAd)}
+}
+
+// add stores an advertisement of n for topic, replacing the one
+// closest to expiry if the topic has too many of them.
+func (tt *topicTable) add(topic Topic, n *Node, now time.Time) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	ads := tt.ads[topic]
+	if ads == nil {
+		ads = make(map[NodeID]*topicAd)
+		tt.ads[topic] = ads
+	}
+	if ads[n.ID] == nil && len(ads) >= maxTopicAds {
+		var oldest *topicAd
+		for _, ad := range ads {
+			if oldest == nil || ad.expires.Before(oldest.expires) {
+				oldest = ad
+			}
+		}
+		delete(ads, oldest.node.ID)
+	}
+	ads[n.ID] = &topicAd{node: n, expires: now.Add(topicTTL)}
+}
+
+// get returns up to max nodes advertised for topic, dropping expired
+// advertisements along the way.
+func (tt *topicTable) get(topic Topic, max int, now time.Time) []*Node {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	var nodes []*Node
+	for id, ad := range tt.ads[topic] {
+		if ad.expires.Before(now) {
+			delete(tt.ads[topic], id)
+			continue
+		}
+		if len(nodes) < max {
+			nodes = append(nodes, ad.node)
+		}
+	}
+	if len(tt.ads[topic]) == 0 {
+		delete(tt.ads, topic)
+	}
+	return nodes
+}
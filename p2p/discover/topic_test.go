@@ -0,0 +1,36 @@
+package discover
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopicTable(t *testing.T) {
+	tt := newTopicTable()
+	now := time.Now()
+	for i := 0; i < maxTopicAds+5; i++ {
+		tt.add("shh", &Node{ID: NodeID{byte(i)}}, now.Add(time.Duration(i)*time.Second))
+	}
+	if n := len(tt.get("shh", maxTopicAds*2, now)); n != maxTopicAds {
+		t.Errorf("wrong number of ads: got %d, want %d", n, maxTopicAds)
+	}
+	// the oldest ads were replaced.
+	for _, n := range tt.get("shh", maxTopicAds, now) {
+		if n.ID[0] < 5 {
+			t.Errorf("ad for %x not replaced", n.ID[0])
+		}
+	}
+	if n := len(tt.get("shh", 10, now)); n != 10 {
+		t.Errorf("limit not respected: got %d nodes", n)
+	}
+	if n := len(tt.get("eth", 10, now)); n != 0 {
+		t.Errorf("got %d nodes for unknown topic", n)
+	}
+	// all ads expire.
+	if n := len(tt.get("shh", maxTopicAds, now.Add(2*topicTTL))); n != 0 {
+		t.Errorf("got %d nodes after expiry", n)
+	}
+	if len(tt.ads) != 0 {
+		t.Errorf("expired topic not removed")
+	}
+}
@@ -25,6 +25,7 @@ var (
 	errBadVersion       = errors.New("version mismatch")
 	errUnsolicitedReply = errors.New("unsolicited reply")
 	errUnknownNode      = errors.New("unknown node")
+	errRecordMismatch   = errors.New("record signed by wrong node")
 	errTimeout          = errors.New("RPC timeout")
 	errClosed           = errors.New("socket closed")
 )
@@ -44,6 +45,13 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+
+	// extended packet set: node records and topics
+	requestRecordPacket
+	recordPacket
+	topicRegisterPacket
+	topicQueryPacket
+	topicNodesPacket
 )
 
 // RPC request structures
@@ -73,6 +81,35 @@ type (
 		Nodes      []*Node
 		Expiration uint64
 	}
+
+	requestRecord struct {
+		Expiration uint64
+	}
+
+	// reply to requestRecord
+	record struct {
+		Record     Record
+		Expiration uint64
+	}
+
+	// topicRegister asks the recipient to advertise the sender
+	// under the given topics. There is no reply.
+	topicRegister struct {
+		Topics     []Topic
+		Expiration uint64
+	}
+
+	topicQuery struct {
+		Topic      Topic
+		Expiration uint64
+	}
+
+	// reply to topicQuery
+	topicNodes struct {
+		Topic      Topic
+		Nodes      []*Node
+		Expiration uint64
+	}
 )
 
 type rpcNode struct {
@@ -144,7 +181,8 @@ type reply struct {
 }
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
-func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface) (*Table, error) {
+// The signed record of the local node advertises tcpPort for RLPx.
+func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, tcpPort int) (*Table, error) {
 	addr, err := net.ResolveUDPAddr("udp", laddr)
 	if err != nil {
 		return nil, err
@@ -153,12 +191,16 @@ func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface) (*Table
 	if err != nil {
 		return nil, err
 	}
-	tab, _ := newUDP(priv, conn, natm)
+	tab, _, err := newUDP(priv, conn, natm, tcpPort)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
 	glog.V(logger.Info).Infoln("Listening,", tab.self)
 	return tab, nil
 }
 
-func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface) (*Table, *udp) {
+func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, tcpPort int) (*Table, *udp, error) {
 	udp := &udp{
 		conn:       c,
 		priv:       priv,
@@ -177,9 +219,14 @@ func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface) (*Table, *udp) {
 		}
 	}
 	udp.Table = newTable(udp, PubkeyID(&priv.PublicKey), realaddr)
+	udp.Table.priv = priv
+	udp.Table.rec.TCP = uint16(tcpPort)
+	if err := udp.Table.rec.Sign(priv); err != nil {
+		return nil, nil, err
+	}
 	go udp.loop()
 	go udp.readLoop()
-	return udp.Table, udp
+	return udp.Table, udp, nil
 }
 
 func (t *udp) close() {
@@ -228,6 +275,56 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 	return nodes, err
 }
 
+// requestRecord asks the given node for its signed node record.
+// The record is not verified.
+func (t *udp) requestRecord(toid NodeID, toaddr *net.UDPAddr) (*Record, error) {
+	var rec *Record
+	errc := t.pending(toid, recordPacket, func(r interface{}) bool {
+		rec = &r.(*record).Record
+		return true
+	})
+	t.send(toaddr, requestRecordPacket, requestRecord{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// registerTopics asks the given node to advertise us under topics.
+func (t *udp) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	return t.send(toaddr, topicRegisterPacket, topicRegister{
+		Topics:     topics,
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+}
+
+// topicQuery sends a topicQuery request to the given node and waits
+// for the nodes it knows to be advertised under topic.
+func (t *udp) topicQuery(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	var nodes []*Node
+	errc := t.pending(toid, topicNodesPacket, func(r interface{}) bool {
+		reply := r.(*topicNodes)
+		if reply.Topic != topic {
+			// reply to a concurrent query for another topic
+			return false
+		}
+		for _, n := range reply.Nodes {
+			if n.isValid() {
+				nodes = append(nodes, n)
+			}
+		}
+		return true
+	})
+	t.send(toaddr, topicQueryPacket, topicQuery{
+		Topic:      topic,
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	err := <-errc
+	return nodes, err
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id NodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -261,9 +358,11 @@ func (t *udp) loop() {
 		nextDeadline time.Time
 		timeout      = time.NewTimer(0)
 		refresh      = time.NewTicker(refreshInterval)
+		topics       = time.NewTicker(topicRegisterInterval)
 	)
 	<-timeout.C // ignore first timeout
 	defer refresh.Stop()
+	defer topics.Stop()
 	defer timeout.Stop()
 
 	rearmTimeout := func() {
@@ -280,6 +379,9 @@ func (t *udp) loop() {
 		case <-refresh.C:
 			go t.refresh()
 
+		case <-topics.C:
+			go t.refreshTopics()
+
 		case <-t.closing:
 			for _, p := range pending {
 				p.errc <- errClosed
@@ -410,6 +512,16 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case requestRecordPacket:
+		req = new(requestRecord)
+	case recordPacket:
+		req = new(record)
+	case topicRegisterPacket:
+		req = new(topicRegister)
+	case topicQueryPacket:
+		req = new(topicQuery)
+	case topicNodesPacket:
+		req = new(topicNodes)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -480,6 +592,78 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byt
 	return nil
 }
 
+func (req *requestRecord) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if t.db.get(fromID) == nil {
+		// Records are larger than the request, see findnode.
+		return errUnknownNode
+	}
+	t.send(from, recordPacket, record{
+		Record:     *t.Record(),
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	return nil
+}
+
+func (req *record) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.handleReply(fromID, recordPacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *topicRegister) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	// Only bonded nodes can register. This ensures that the
+	// advertised endpoint has actually been verified.
+	n := t.db.get(fromID)
+	if n == nil {
+		return errUnknownNode
+	}
+	topics := req.Topics
+	if len(topics) > maxRegisterTopics {
+		topics = topics[:maxRegisterTopics]
+	}
+	now := time.Now()
+	for _, topic := range topics {
+		t.topics.add(topic, n, now)
+	}
+	return nil
+}
+
+func (req *topicQuery) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if t.db.get(fromID) == nil {
+		// No bond exists, see findnode.
+		return errUnknownNode
+	}
+	t.send(from, topicNodesPacket, topicNodes{
+		Topic:      req.Topic,
+		Nodes:      t.topics.get(req.Topic, bucketSize, time.Now()),
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	return nil
+}
+
+func (req *topicNodes) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.handleReply(fromID, topicNodesPacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
@@ -41,7 +41,7 @@ func newUDPTest(t *testing.T) *udpTest {
 		remotekey:  newkey(),
 		remoteaddr: &net.UDPAddr{IP: net.IP{1, 2, 3, 4}, Port: 30303},
 	}
-	test.table, test.udp = newUDP(test.localkey, test.pipe, nil)
+	test.table, test.udp, _ = newUDP(test.localkey, test.pipe, nil, 30303)
 	return test
 }
 
@@ -105,6 +105,11 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, pongPacket, &pong{ReplyTok: []byte{}, Expiration: futureExp})
 	test.packetIn(errUnknownNode, findnodePacket, &findnode{Expiration: futureExp})
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
+	test.packetIn(errUnknownNode, requestRecordPacket, &requestRecord{Expiration: futureExp})
+	test.packetIn(errUnsolicitedReply, recordPacket, &record{Expiration: futureExp})
+	test.packetIn(errUnknownNode, topicRegisterPacket, &topicRegister{Topics: []Topic{"foo"}, Expiration: futureExp})
+	test.packetIn(errUnknownNode, topicQueryPacket, &topicQuery{Topic: "foo", Expiration: futureExp})
+	test.packetIn(errUnsolicitedReply, topicNodesPacket, &topicNodes{Topic: "foo", Expiration: futureExp})
 }
 
 func TestUDP_pingTimeout(t *testing.T) {
@@ -263,6 +268,135 @@ func TestUDP_successfulPing(t *testing.T) {
 	}
 }
 
+func TestUDP_requestRecord(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	if err := test.table.SetRecordValue("shh", []byte{2}); err != nil {
+		t.Fatalf("SetRecordValue error: %v", err)
+	}
+	test.table.db.add(PubkeyID(&test.remotekey.PublicKey), test.remoteaddr, 99)
+
+	// check that the signed local record is returned.
+	test.packetIn(nil, requestRecordPacket, &requestRecord{Expiration: futureExp})
+	test.waitPacketOut(func(p *record) {
+		if err := p.Record.Verify(); err != nil {
+			t.Fatalf("record verify error: %v", err)
+		}
+		if p.Record.ID() != test.table.self.ID {
+			t.Errorf("record signed by wrong node: %v", p.Record.ID())
+		}
+		if p.Record.Seq != 1 {
+			t.Errorf("wrong record sequence number: got %d, want 1", p.Record.Seq)
+		}
+		if !bytes.Equal(p.Record.Get("shh"), []byte{2}) {
+			t.Errorf("wrong record value: got %x", p.Record.Get("shh"))
+		}
+	})
+}
+
+func TestUDP_requestRecordReply(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	rid := PubkeyID(&test.remotekey.PublicKey)
+	resultc, errc := make(chan *Record), make(chan error)
+	go func() {
+		rec, err := test.udp.requestRecord(rid, test.remoteaddr)
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- rec
+		}
+	}()
+	test.waitPacketOut(func(p *requestRecord) {})
+
+	rec := Record{Seq: 5, IP: test.remoteaddr.IP, UDP: 30303, TCP: 30303}
+	rec.Set("eth", []byte{61})
+	rec.Sign(test.remotekey)
+	test.packetIn(nil, recordPacket, &record{Record: rec, Expiration: futureExp})
+
+	select {
+	case result := <-resultc:
+		if err := result.Verify(); err != nil {
+			t.Fatalf("record verify error: %v", err)
+		}
+		if result.ID() != rid || result.Seq != 5 || !bytes.Equal(result.Get("eth"), []byte{61}) {
+			t.Errorf("record mismatch: %+v", result)
+		}
+	case err := <-errc:
+		t.Errorf("requestRecord error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("requestRecord did not return within 5 seconds")
+	}
+}
+
+func TestUDP_topicRegisterQuery(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	rid := PubkeyID(&test.remotekey.PublicKey)
+	test.table.db.add(rid, test.remoteaddr, 99)
+
+	// the remote node registers itself and queries the topic.
+	test.packetIn(nil, topicRegisterPacket, &topicRegister{Topics: []Topic{"shh", "les"}, Expiration: futureExp})
+	test.packetIn(nil, topicQueryPacket, &topicQuery{Topic: "shh", Expiration: futureExp})
+	test.waitPacketOut(func(p *topicNodes) {
+		if p.Topic != "shh" {
+			t.Errorf("wrong topic: got %q, want %q", p.Topic, "shh")
+		}
+		if len(p.Nodes) != 1 || p.Nodes[0].ID != rid {
+			t.Errorf("wrong nodes: got %v, want only %v", p.Nodes, rid)
+		}
+	})
+	test.packetIn(nil, topicQueryPacket, &topicQuery{Topic: "eth", Expiration: futureExp})
+	test.waitPacketOut(func(p *topicNodes) {
+		if len(p.Nodes) != 0 {
+			t.Errorf("got nodes for unregistered topic: %v", p.Nodes)
+		}
+	})
+}
+
+func TestUDP_topicQueryReply(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	rid := PubkeyID(&test.remotekey.PublicKey)
+	resultc, errc := make(chan []*Node), make(chan error)
+	go func() {
+		ns, err := test.udp.topicQuery(rid, test.remoteaddr, "shh")
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- ns
+		}
+	}()
+	test.waitPacketOut(func(p *topicQuery) {
+		if p.Topic != "shh" {
+			t.Errorf("wrong topic: got %q, want %q", p.Topic, "shh")
+		}
+	})
+
+	// replies for other topics are ignored.
+	list := []*Node{
+		MustParseNode("enode://ba85011c70bcc5c04d8607d3a0ed29aa6179c092cbdda10d5d32684fb33ed01bd94f588ca8f91ac48318087dcb02eaf36773a7a453f0eedd6742af668097b29c@10.0.1.16:30303"),
+		MustParseNode("enode://81fa361d25f157cd421c60dcc28d8dac5ef6a89476633339c5df30287474520caca09627da18543d9079b5b288698b542d56167aa5c09111e55acdbbdf2ef799@10.0.1.16:30303"),
+	}
+	test.packetIn(nil, topicNodesPacket, &topicNodes{Topic: "eth", Nodes: list[:1], Expiration: futureExp})
+	test.packetIn(nil, topicNodesPacket, &topicNodes{Topic: "shh", Nodes: list[1:], Expiration: futureExp})
+
+	select {
+	case result := <-resultc:
+		if !reflect.DeepEqual(result, list[1:]) {
+			t.Errorf("nodes mismatch:\n  got:  %v\n  want: %v", result, list[1:])
+		}
+	case err := <-errc:
+		t.Errorf("topicQuery error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("topicQuery did not return within 5 seconds")
+	}
+}
+
 func find(tab *Table, id NodeID) *Node {
 	for _, b := range tab.buckets {
 		for _, e := range b.entries {
@@ -84,6 +84,13 @@ type Server struct {
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool
 
+	// If RequiredCaps is set, the server only dials nodes whose signed
+	// discovery record advertises all of the given capabilities.
+	// Candidates are searched under the discovery topic named after
+	// the first required capability. Inbound connections are not
+	// affected.
+	RequiredCaps []Cap
+
 	// Hooks for testing. These are useful because we can inhibit
 	// the whole protocol stack.
 	setupFunc
@@ -196,12 +203,28 @@ func (srv *Server) Start() (err error) {
 		srv.setupFunc = setupConn
 	}
 
+	// the TCP port is bound first, the node record advertises it
+	var tcpPort int
+	udpAddr := srv.ListenAddr
+	if srv.ListenAddr != "" {
+		if err := srv.listen(); err != nil {
+			return err
+		}
+		tcpPort = srv.listener.Addr().(*net.TCPAddr).Port
+	}
+
 	// node table
-	ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT)
+	ntab, err := discover.ListenUDP(srv.PrivateKey, udpAddr, srv.NAT, tcpPort)
 	if err != nil {
+		if srv.listener != nil {
+			srv.listener.Close()
+		}
 		return err
 	}
 	srv.ntab = ntab
+	if err := srv.advertiseCaps(); err != nil {
+		return err
+	}
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: ntab.Self().ID}
@@ -210,10 +233,8 @@ func (srv *Server) Start() (err error) {
 	}
 
 	// listen/dial
-	if srv.ListenAddr != "" {
-		if err := srv.startListening(); err != nil {
-			return err
-		}
+	if srv.listener != nil {
+		srv.startListening()
 	}
 	if srv.Dialer == nil {
 		srv.Dialer = &net.Dialer{Timeout: defaultDialTimeout}
@@ -230,14 +251,18 @@ func (srv *Server) Start() (err error) {
 	return nil
 }
 
-func (srv *Server) startListening() error {
+func (srv *Server) listen() error {
 	listener, err := net.Listen("tcp", srv.ListenAddr)
 	if err != nil {
 		return err
 	}
-	laddr := listener.Addr().(*net.TCPAddr)
-	srv.ListenAddr = laddr.String()
+	srv.ListenAddr = listener.Addr().String()
 	srv.listener = listener
+	return nil
+}
+
+func (srv *Server) startListening() {
+	laddr := srv.listener.Addr().(*net.TCPAddr)
 	srv.loopWG.Add(1)
 	go srv.listenLoop()
 	if !laddr.IP.IsLoopback() && srv.NAT != nil {
@@ -247,7 +272,6 @@ func (srv *Server) startListening() error {
 			srv.loopWG.Done()
 		}()
 	}
-	return nil
 }
 
 // Stop terminates the server and all active peer connections.
@@ -346,6 +370,9 @@ func (srv *Server) dialLoop() {
 	}
 
 	srv.ntab.Bootstrap(srv.BootstrapNodes)
+	for _, name := range srv.protocolNames() {
+		go srv.ntab.RegisterTopic(discover.Topic(name))
+	}
 	for {
 		select {
 		case <-refresh.C:
@@ -354,11 +381,7 @@ func (srv *Server) dialLoop() {
 			needpeers := len(srv.peers) < srv.MaxPeers
 			srv.lock.RUnlock()
 			if needpeers {
-				go func() {
-					var target discover.NodeID
-					rand.Read(target[:])
-					findresults <- srv.ntab.Lookup(target)
-				}()
+				go func() { findresults <- srv.findPeers() }()
 			} else {
 				// Make sure we check again if the peer count falls
 				// below MaxPeers.
@@ -384,6 +407,88 @@ func (srv *Server) dialLoop() {
 	}
 }
 
+// findPeers returns dial candidates. Without RequiredCaps, these are
+// the nodes closest to a random target. Otherwise only nodes whose
+// record advertises all required capabilities are returned.
+func (srv *Server) findPeers() []*discover.Node {
+	if len(srv.RequiredCaps) == 0 {
+		var target discover.NodeID
+		rand.Read(target[:])
+		return srv.ntab.Lookup(target)
+	}
+	candidates := srv.ntab.SearchTopic(discover.Topic(srv.RequiredCaps[0].Name))
+	results := make(chan *discover.Node, len(candidates))
+	for _, n := range candidates {
+		go func(n *discover.Node) {
+			rec, err := srv.ntab.RequestRecord(n)
+			if err != nil || !recordHasCaps(rec, srv.RequiredCaps) {
+				results <- nil
+				return
+			}
+			// the signed record is more reliable than the advertisement.
+			results <- rec.Node()
+		}(n)
+	}
+	var capable []*discover.Node
+	for _ = range candidates {
+		if n := <-results; n != nil {
+			capable = append(capable, n)
+		}
+	}
+	glog.V(logger.Debug).Infof("Found %d of %d nodes with caps %v\n", len(capable), len(candidates), srv.RequiredCaps)
+	return capable
+}
+
+// advertiseCaps publishes the versions of all protocols run by the
+// server in the local discovery record, keyed by protocol name.
+func (srv *Server) advertiseCaps() error {
+	versions := make(map[string][]uint)
+	for _, p := range srv.Protocols {
+		versions[p.Name] = append(versions[p.Name], p.Version)
+	}
+	for _, name := range srv.protocolNames() {
+		enc, err := rlp.EncodeToBytes(versions[name])
+		if err != nil {
+			return err
+		}
+		if err := srv.ntab.SetRecordValue(name, enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protocolNames returns the distinct names of the server's protocols.
+func (srv *Server) protocolNames() (names []string) {
+	seen := make(map[string]bool)
+	for _, p := range srv.Protocols {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// recordHasCaps reports whether rec advertises all of the given
+// capabilities.
+func recordHasCaps(rec *discover.Record, caps []Cap) bool {
+outer:
+	for _, cap := range caps {
+		var versions []uint
+		if err := rlp.DecodeBytes(rec.Get(cap.Name), &versions); err != nil {
+			return false
+		}
+		for _, v := range versions {
+			if v == cap.Version {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
 func (srv *Server) dialNode(dest *discover.Node) {
 	addr := &net.TCPAddr{IP: dest.IP, Port: dest.TCPPort}
 	glog.V(logger.Debug).Infof("Dialing %v\n", dest)
@@ -219,6 +219,54 @@ func TestServerDisconnectAtCap(t *testing.T) {
 	}
 }
 
+// This test checks that the server advertises its protocols
+// in the discovery record.
+func TestServerRecordCaps(t *testing.T) {
+	defer testlog(t).detach()
+
+	srv := &Server{
+		ListenAddr: "127.0.0.1:0",
+		PrivateKey: newkey(),
+		MaxPeers:   10,
+		NoDial:     true,
+		Protocols: []Protocol{
+			{Name: "eth", Version: 60},
+			{Name: "eth", Version: 61},
+			{Name: "shh", Version: 2},
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	rec := srv.ntab.Record()
+	if err := rec.Verify(); err != nil {
+		t.Fatalf("record verify error: %v", err)
+	}
+	if rec.ID() != srv.Self().ID {
+		t.Errorf("record signed by wrong node: %v", rec.ID())
+	}
+	if port := srv.listener.Addr().(*net.TCPAddr).Port; int(rec.TCP) != port {
+		t.Errorf("record TCP port %d, want listening port %d", rec.TCP, port)
+	}
+	tests := []struct {
+		caps []Cap
+		want bool
+	}{
+		{caps: nil, want: true},
+		{caps: []Cap{{"eth", 60}}, want: true},
+		{caps: []Cap{{"eth", 61}, {"shh", 2}}, want: true},
+		{caps: []Cap{{"eth", 62}}, want: false},
+		{caps: []Cap{{"eth", 61}, {"bzz", 0}}, want: false},
+	}
+	for i, test := range tests {
+		if got := recordHasCaps(rec, test.caps); got != test.want {
+			t.Errorf("test %d: recordHasCaps(%v) = %v, want %v", i, test.caps, got, test.want)
+		}
+	}
+}
+
 func newkey() *ecdsa.PrivateKey {
 	key, err := crypto.GenerateKey()
 	if err != nil {
@@ -0,0 +1,275 @@
+package params
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+)
+
+// Difficulty algorithms of a rule set.
+const (
+	FrontierDifficulty  = "frontier"  // up or down by 1/2048 around an 8 second block time
+	HomesteadDifficulty = "homestead" // proportional to the distance from a 10-19 second block time
+)
+
+// GasTable holds the prices of the operations whose gas cost is part of the
+// rules of a chain rather than a protocol constant.
+type GasTable struct {
+	Balance     *big.Int `json:"balance"`
+	ExtcodeSize *big.Int `json:"extcodeSize"`
+	ExtcodeCopy *big.Int `json:"extcodeCopy"` // base price, the copied words are paid for separately
+	SLoad       *big.Int `json:"sload"`
+	Calls       *big.Int `json:"calls"` // base price of CALL and CALLCODE
+	Suicide     *big.Int `json:"suicide"`
+	ExpByte     *big.Int `json:"expByte"` // per byte of the EXP exponent
+
+	Tx            *big.Int `json:"tx"`            // intrinsic gas of a message call transaction
+	TxCreate      *big.Int `json:"txCreate"`      // intrinsic gas of a contract creation transaction
+	TxDataZero    *big.Int `json:"txDataZero"`    // per zero byte of transaction data
+	TxDataNonZero *big.Int `json:"txDataNonZero"` // per non-zero byte of transaction data
+}
+
+// RuleSet are the consensus rules of the blocks from Block on, up to the
+// next rule set of the chain.
+type RuleSet struct {
+	Name  string   `json:"name"`
+	Block *big.Int `json:"block"`
+
+	Gas         *GasTable `json:"gas"`
+	Difficulty  string    `json:"difficulty"`  // difficulty algorithm, FrontierDifficulty or HomesteadDifficulty
+	DisabledOps []string  `json:"disabledOps"` // names of the op codes which are invalid
+	BlockReward *big.Int  `json:"blockReward"` // paid to the miner, uncles get a share of it
+}
+
+// ChainConfig maps block numbers to the consensus rules of a chain. The rule
+// sets are ordered by the block they activate at, the first one at the
+// genesis block.
+type ChainConfig struct {
+	Forks []*RuleSet `json:"forks"`
+
+	// Authority makes the chain a proof of authority chain, sealed by a set
+	// of signers instead of proof of work. The difficulty algorithm and the
+	// block reward of the rule sets are unused then.
+	Authority *AuthorityConfig `json:"authority,omitempty"`
+}
+
+// AuthorityConfig are the settings of a chain sealed by authorised signers.
+type AuthorityConfig struct {
+	Period  uint64   `json:"period"`  // minimum seconds between blocks, 0 seals only blocks with transactions
+	Epoch   uint64   `json:"epoch"`   // number of blocks after which votes are reset and the signers listed
+	Signers []string `json:"signers"` // hex addresses of the signers of the genesis block
+}
+
+// DefaultAuthorityEpoch is the epoch of authority chains which don't set one.
+const DefaultAuthorityEpoch = 30000
+
+// IsOpCode reports whether name is the name of an op code. It is set by the
+// vm package, which params can't import, and Validate rejects rule sets
+// disabling unknown op codes through it.
+var IsOpCode func(name string) bool
+
+var (
+	// FrontierGasTable are the gas prices of the frontier release.
+	FrontierGasTable = &GasTable{
+		Balance:     big.NewInt(20),
+		ExtcodeSize: big.NewInt(20),
+		ExtcodeCopy: big.NewInt(20),
+		SLoad:       SloadGas,
+		Calls:       CallGas,
+		Suicide:     big.NewInt(0),
+		ExpByte:     ExpByteGas,
+
+		Tx:            TxGas,
+		TxCreate:      TxGas,
+		TxDataZero:    TxDataZeroGas,
+		TxDataNonZero: TxDataNonZeroGas,
+	}
+
+	// FrontierRules are the rules the chain started out with.
+	FrontierRules = &RuleSet{
+		Name:        "frontier",
+		Block:       big.NewInt(0),
+		Gas:         FrontierGasTable,
+		Difficulty:  FrontierDifficulty,
+		BlockReward: big.NewInt(1.5e+18),
+	}
+
+	// DefaultChainConfig is the configuration of the main chain.
+	DefaultChainConfig = &ChainConfig{Forks: []*RuleSet{FrontierRules}}
+)
+
+// Rules returns the rule set of the block with the given number.
+func (self *ChainConfig) Rules(number *big.Int) *RuleSet {
+	for i := len(self.Forks) - 1; i > 0; i-- {
+		if number.Cmp(self.Forks[i].Block) >= 0 {
+			return self.Forks[i]
+		}
+	}
+	return self.Forks[0]
+}
+
+// Validate checks the configuration and completes its rule sets: anything a
+// rule set leaves out is inherited from the rule set before it, so a fork
+// only needs to list what it changes. An empty list of disabled op codes
+// enables all of them again.
+func (self *ChainConfig) Validate() error {
+	if len(self.Forks) == 0 {
+		return fmt.Errorf("chain config has no rule sets")
+	}
+	for i, rules := range self.Forks {
+		if rules.Name == "" {
+			rules.Name = fmt.Sprintf("fork %d", i)
+		}
+		if i == 0 {
+			if rules.Block == nil {
+				rules.Block = new(big.Int)
+			}
+			if rules.Block.Sign() != 0 {
+				return fmt.Errorf("%s: the first rule set must start at the genesis block", rules.Name)
+			}
+			if err := rules.complete(); err != nil {
+				return fmt.Errorf("%s: %v", rules.Name, err)
+			}
+			continue
+		}
+
+		parent := self.Forks[i-1]
+		if rules.Block == nil || rules.Block.Cmp(parent.Block) <= 0 {
+			return fmt.Errorf("%s: activation block must be after block %v of %s", rules.Name, parent.Block, parent.Name)
+		}
+		rules.inherit(parent)
+		if err := rules.complete(); err != nil {
+			return fmt.Errorf("%s: %v", rules.Name, err)
+		}
+	}
+	if self.Authority != nil {
+		if err := self.Authority.validate(); err != nil {
+			return fmt.Errorf("authority: %v", err)
+		}
+	}
+	return nil
+}
+
+func (self *AuthorityConfig) validate() error {
+	if self.Epoch == 0 {
+		self.Epoch = DefaultAuthorityEpoch
+	}
+	if len(self.Signers) == 0 {
+		return fmt.Errorf("no signers")
+	}
+	for _, signer := range self.Signers {
+		addr, err := hex.DecodeString(strings.TrimPrefix(signer, "0x"))
+		if err != nil || len(addr) != 20 {
+			return fmt.Errorf("invalid signer address %q", signer)
+		}
+	}
+	return nil
+}
+
+// inherit fills in the settings missing from self with those of parent.
+func (self *RuleSet) inherit(parent *RuleSet) {
+	if self.Gas == nil {
+		self.Gas = parent.Gas
+	} else {
+		gas := *self.Gas
+		have, from := gas.prices(), parent.Gas.prices()
+		for i := range have {
+			if *have[i].price == nil {
+				*have[i].price = *from[i].price
+			}
+		}
+		self.Gas = &gas
+	}
+	if self.Difficulty == "" {
+		self.Difficulty = parent.Difficulty
+	}
+	if self.DisabledOps == nil {
+		self.DisabledOps = parent.DisabledOps
+	}
+	if self.BlockReward == nil {
+		self.BlockReward = parent.BlockReward
+	}
+}
+
+// complete returns an error if a setting of the rule set is missing or
+// invalid.
+func (self *RuleSet) complete() error {
+	if self.Gas == nil {
+		return fmt.Errorf("gas table missing")
+	}
+	for _, p := range self.Gas.prices() {
+		if *p.price == nil {
+			return fmt.Errorf("gas price %q missing", p.name)
+		}
+	}
+	switch self.Difficulty {
+	case FrontierDifficulty, HomesteadDifficulty:
+	case "":
+		return fmt.Errorf("difficulty algorithm missing")
+	default:
+		return fmt.Errorf("unknown difficulty algorithm %q", self.Difficulty)
+	}
+	if self.BlockReward == nil {
+		return fmt.Errorf("block reward missing")
+	}
+	if IsOpCode != nil {
+		for _, name := range self.DisabledOps {
+			if !IsOpCode(name) {
+				return fmt.Errorf("unknown op code %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+type gasPrice struct {
+	name  string
+	price **big.Int
+}
+
+func (self *GasTable) prices() []gasPrice {
+	return []gasPrice{
+		{"balance", &self.Balance},
+		{"extcodeSize", &self.ExtcodeSize},
+		{"extcodeCopy", &self.ExtcodeCopy},
+		{"sload", &self.SLoad},
+		{"calls", &self.Calls},
+		{"suicide", &self.Suicide},
+		{"expByte", &self.ExpByte},
+		{"tx", &self.Tx},
+		{"txCreate", &self.TxCreate},
+		{"txDataZero", &self.TxDataZero},
+		{"txDataNonZero", &self.TxDataNonZero},
+	}
+}
+
+// LoadChainConfig reads a chain configuration from a JSON file. The file
+// holds either the configuration itself or a genesis description with the
+// configuration under "config".
+func LoadChainConfig(file string) (*ChainConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var genesis struct {
+		Config *ChainConfig `json:"config"`
+	}
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	config := genesis.Config
+	if config == nil {
+		config = new(ChainConfig)
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,161 @@
+package params
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	config := &ChainConfig{Forks: []*RuleSet{
+		{Name: "a", Block: big.NewInt(0)},
+		{Name: "b", Block: big.NewInt(10)},
+		{Name: "c", Block: big.NewInt(20)},
+	}}
+	for number, want := range map[int64]string{0: "a", 9: "a", 10: "b", 19: "b", 20: "c", 1000: "c"} {
+		if rules := config.Rules(big.NewInt(number)); rules.Name != want {
+			t.Errorf("block %d: got rules %s, want %s", number, rules.Name, want)
+		}
+	}
+}
+
+func TestValidateInherits(t *testing.T) {
+	config := &ChainConfig{Forks: []*RuleSet{
+		FrontierRules,
+		{Block: big.NewInt(100), Gas: &GasTable{SLoad: big.NewInt(200)}, DisabledOps: []string{"CALLCODE"}},
+		{Block: big.NewInt(200), Difficulty: HomesteadDifficulty, DisabledOps: []string{}},
+	}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	fork := config.Forks[1]
+	if fork.Gas.SLoad.Int64() != 200 || fork.Gas.Calls.Cmp(FrontierGasTable.Calls) != 0 {
+		t.Errorf("fork 1: gas table not merged: sload %v, calls %v", fork.Gas.SLoad, fork.Gas.Calls)
+	}
+	if fork.Difficulty != FrontierDifficulty || fork.BlockReward.Cmp(FrontierRules.BlockReward) != 0 {
+		t.Errorf("fork 1: difficulty %q and reward %v not inherited", fork.Difficulty, fork.BlockReward)
+	}
+	if FrontierGasTable.SLoad.Cmp(SloadGas) != 0 {
+		t.Errorf("frontier gas table modified: sload %v", FrontierGasTable.SLoad)
+	}
+
+	fork = config.Forks[2]
+	if fork.Gas.SLoad.Int64() != 200 || fork.Difficulty != HomesteadDifficulty || len(fork.DisabledOps) != 0 {
+		t.Errorf("fork 2: got sload %v, difficulty %q, disabled ops %v", fork.Gas.SLoad, fork.Difficulty, fork.DisabledOps)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		forks []*RuleSet
+		err   string
+	}{
+		{nil, "no rule sets"},
+		{[]*RuleSet{{Name: "a", Block: big.NewInt(1)}}, "must start at the genesis block"},
+		{[]*RuleSet{{Name: "a", Gas: FrontierGasTable, Difficulty: FrontierDifficulty}}, "block reward missing"},
+		{[]*RuleSet{{Name: "a", Gas: &GasTable{}, Difficulty: FrontierDifficulty}}, `gas price "balance" missing`},
+		{[]*RuleSet{{Name: "a", Gas: FrontierGasTable, Difficulty: "fast", BlockReward: big.NewInt(1)}}, `unknown difficulty algorithm "fast"`},
+		{[]*RuleSet{FrontierRules, {Name: "b"}}, "activation block must be after block 0"},
+		{[]*RuleSet{FrontierRules, {Name: "b", Block: big.NewInt(10)}, {Name: "c", Block: big.NewInt(10)}}, "activation block must be after block 10"},
+	}
+	for i, test := range tests {
+		config := &ChainConfig{Forks: test.forks}
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d: got error %v, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestValidateDisabledOps(t *testing.T) {
+	defer func(isOpCode func(string) bool) { IsOpCode = isOpCode }(IsOpCode)
+	IsOpCode = func(name string) bool { return name == "CALLCODE" }
+
+	config := &ChainConfig{Forks: []*RuleSet{FrontierRules, {Name: "b", Block: big.NewInt(10), DisabledOps: []string{"CALLCODE"}}}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("known op code: %v", err)
+	}
+	config = &ChainConfig{Forks: []*RuleSet{FrontierRules, {Name: "b", Block: big.NewInt(10), DisabledOps: []string{"CALCODE"}}}}
+	if err := config.Validate(); err == nil || err.Error() != `b: unknown op code "CALCODE"` {
+		t.Errorf("unknown op code: got error %v", err)
+	}
+}
+
+func TestValidateAuthority(t *testing.T) {
+	tests := []struct {
+		authority *AuthorityConfig
+		err       string
+	}{
+		{&AuthorityConfig{}, "authority: no signers"},
+		{&AuthorityConfig{Signers: []string{"0x1234"}}, `authority: invalid signer address "0x1234"`},
+		{&AuthorityConfig{Signers: []string{"0x8888f1f195afa192cfee860698584c030f4c9dbz"}}, "authority: invalid signer address"},
+	}
+	for i, test := range tests {
+		config := &ChainConfig{Forks: []*RuleSet{FrontierRules}, Authority: test.authority}
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d: got error %v, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestLoadChainConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chainconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	forks := `{"forks": [
+		{"name": "frontier", "block": 0, "difficulty": "frontier", "blockReward": 1500000000000000000,
+		 "gas": {"balance": 20, "extcodeSize": 20, "extcodeCopy": 20, "sload": 50, "calls": 40, "suicide": 0,
+		         "expByte": 10, "tx": 21000, "txCreate": 21000, "txDataZero": 4, "txDataNonZero": 68}},
+		{"name": "homestead", "block": 1000, "difficulty": "homestead", "gas": {"txCreate": 53000}}
+	]}`
+	for name, content := range map[string]string{
+		"config.json":  forks,
+		"genesis.json": `{"nonce": "0x42", "config": ` + forks + `}`,
+	} {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadChainConfig(file)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if rules := config.Rules(big.NewInt(999)); rules.Name != "frontier" || rules.Gas.TxCreate.Int64() != 21000 {
+			t.Errorf("%s: block 999: got rules %s with create gas %v", name, rules.Name, rules.Gas.TxCreate)
+		}
+		if rules := config.Rules(big.NewInt(1000)); rules.Name != "homestead" || rules.Gas.TxCreate.Int64() != 53000 || rules.Gas.Tx.Int64() != 21000 {
+			t.Errorf("%s: block 1000: got rules %s with create gas %v and tx gas %v", name, rules.Name, rules.Gas.TxCreate, rules.Gas.Tx)
+		}
+	}
+}
+
+func TestLoadAuthorityConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chainconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	content := `{"forks": [{"name": "frontier", "block": 0, "difficulty": "frontier", "blockReward": 0, "gas": {
+			"balance": 20, "extcodeSize": 20, "extcodeCopy": 20, "sload": 50, "calls": 40, "suicide": 0,
+			"expByte": 10, "tx": 21000, "txCreate": 21000, "txDataZero": 4, "txDataNonZero": 68}}],
+		"authority": {"period": 5, "signers": ["0x8888f1f195afa192cfee860698584c030f4c9db1"]}}`
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadChainConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Authority == nil || config.Authority.Period != 5 || config.Authority.Epoch != DefaultAuthorityEpoch || len(config.Authority.Signers) != 1 {
+		t.Errorf("got authority config %+v", config.Authority)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
@@ -172,6 +173,50 @@ func (api *EthereumApi) GetRequestReply(req *RpcRequest, reply *interface{}) err
 			return err
 		}
 		*reply = v
+	case "eth_sign":
+		args := new(SignArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+
+		v, err := api.xeth().Sign(args.From, args.Data, false)
+		if err != nil {
+			return err
+		}
+		*reply = v
+	case "personal_ecRecover":
+		args := new(EcRecoverArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+
+		v, err := ecRecover(common.FromHex(args.Data), common.FromHex(args.Sig))
+		if err != nil {
+			return err
+		}
+		*reply = newHexData(v)
+	case "eth_signTransaction":
+		args := new(NewTxArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+
+		v, err := api.xeth().SignTransaction(args.From, args.To, args.Value.String(), args.Gas.String(), args.GasPrice.String(), args.Data)
+		if err != nil {
+			return err
+		}
+		*reply = v
+	case "eth_sendRawTransaction":
+		args := new(SendRawTxArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+
+		v, err := api.xeth().PushTx(args.Tx)
+		if err != nil {
+			return err
+		}
+		*reply = v
 	case "eth_call":
 		args := new(CallArgs)
 		if err := json.Unmarshal(req.Params, &args); err != nil {
@@ -482,3 +527,24 @@ func (api *EthereumApi) GetRequestReply(req *RpcRequest, reply *interface{}) err
 	rpclogger.DebugDetailf("Reply: %T %s", reply, reply)
 	return nil
 }
+
+// ecRecover returns the address of the account that signed data with eth_sign.
+// The signature is [R || S || V] with V 27 or 28.
+func ecRecover(data, sig []byte) ([]byte, error) {
+	if len(sig) != 65 {
+		return nil, NewValidationError("sig", "must be 65 bytes long")
+	}
+	if sig[64] != 27 && sig[64] != 28 {
+		return nil, NewValidationError("sig", "V must be 27 or 28")
+	}
+	rsv := append([]byte{}, sig...)
+	rsv[64] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash(data), rsv)
+	if err != nil {
+		return nil, err
+	}
+	if pub.X == nil {
+		return nil, NewValidationError("sig", "is invalid")
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -1,11 +1,16 @@
 package rpc
 
 import (
+	"bytes"
 	"encoding/json"
 	// "sync"
 	"testing"
 	// "time"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
 	// "github.com/ethereum/go-ethereum/xeth"
 )
 
@@ -26,6 +31,39 @@ func TestWeb3Sha3(t *testing.T) {
 	}
 }
 
+func TestEcRecover(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	data := []byte("hello world")
+	sig, err := crypto.Sign(accounts.TextHash(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] += 27
+
+	jsonstr := `{"jsonrpc":"2.0","method":"personal_ecRecover","params":["` + common.ToHex(data) + `","` + common.ToHex(sig) + `"],"id":64}`
+	api := &EthereumApi{}
+
+	var req RpcRequest
+	json.Unmarshal([]byte(jsonstr), &req)
+
+	var response interface{}
+	if err := api.GetRequestReply(&req, &response); err != nil {
+		t.Fatal(err)
+	}
+	expected := crypto.PubkeyToAddress(key.PublicKey)
+	if got := response.(*hexdata).data; !bytes.Equal(got, expected) {
+		t.Errorf("Expected %x got %x", expected, got)
+	}
+
+	// the signature of other data recovers another address
+	if got, _ := ecRecover([]byte("hello"), sig); bytes.Equal(got, expected) {
+		t.Errorf("Recovered signer of other data")
+	}
+	if _, err := ecRecover(data, sig[:64]); err == nil {
+		t.Errorf("Expected error for short signature")
+	}
+}
+
 // func TestDbStr(t *testing.T) {
 // 	jsonput := `{"jsonrpc":"2.0","method":"db_putString","params":["testDB","myKey","myString"],"id":64}`
 // 	jsonget := `{"jsonrpc":"2.0","method":"db_getString","params":["testDB","myKey"],"id":64}`
@@ -617,6 +617,89 @@ func (args *Sha3Args) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+type SignArgs struct {
+	From string
+	Data string
+}
+
+func (args *SignArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return NewInsufficientParamsError(len(obj), 2)
+	}
+
+	from, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("from", "not a string")
+	}
+	args.From = from
+
+	data, ok := obj[1].(string)
+	if !ok {
+		return NewInvalidTypeError("data", "not a string")
+	}
+	args.Data = data
+
+	return nil
+}
+
+type EcRecoverArgs struct {
+	Data string
+	Sig  string
+}
+
+func (args *EcRecoverArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return NewInsufficientParamsError(len(obj), 2)
+	}
+
+	data, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("data", "not a string")
+	}
+	args.Data = data
+
+	sig, ok := obj[1].(string)
+	if !ok {
+		return NewInvalidTypeError("sig", "not a string")
+	}
+	args.Sig = sig
+
+	return nil
+}
+
+type SendRawTxArgs struct {
+	Tx string
+}
+
+func (args *SendRawTxArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return NewInsufficientParamsError(len(obj), 1)
+	}
+
+	tx, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("tx", "not a string")
+	}
+	args.Tx = tx
+
+	return nil
+}
+
 type BlockFilterArgs struct {
 	Earliest int64
 	Latest   int64
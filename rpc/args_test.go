@@ -156,6 +156,93 @@ func TestSha3ArgsDataInvalid(t *testing.T) {
 	}
 }
 
+func TestSignArgs(t *testing.T) {
+	input := `["0x407d73d8a49eeb85d32cf465507dd71d507100c1", "0x68656c6c6f20776f726c64"]`
+
+	args := new(SignArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.From != "0x407d73d8a49eeb85d32cf465507dd71d507100c1" {
+		t.Errorf("From should be %v but is %v", "0x407d73d8a49eeb85d32cf465507dd71d507100c1", args.From)
+	}
+
+	if args.Data != "0x68656c6c6f20776f726c64" {
+		t.Errorf("Data should be %v but is %v", "0x68656c6c6f20776f726c64", args.Data)
+	}
+}
+
+func TestSignArgsEmpty(t *testing.T) {
+	input := `["0x407d73d8a49eeb85d32cf465507dd71d507100c1"]`
+
+	args := new(SignArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSignArgsDataInvalid(t *testing.T) {
+	input := `["0x407d73d8a49eeb85d32cf465507dd71d507100c1", 4]`
+
+	args := new(SignArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSendRawTxArgs(t *testing.T) {
+	input := `["0xf86c078502540be400"]`
+
+	args := new(SendRawTxArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Tx != "0xf86c078502540be400" {
+		t.Errorf("Tx should be %v but is %v", "0xf86c078502540be400", args.Tx)
+	}
+}
+
+func TestSendRawTxArgsEmpty(t *testing.T) {
+	input := `[]`
+
+	args := new(SendRawTxArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestEcRecoverArgs(t *testing.T) {
+	input := `["0x68656c6c6f20776f726c64", "0x1234"]`
+
+	args := new(EcRecoverArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Data != "0x68656c6c6f20776f726c64" {
+		t.Errorf("Data should be %v but is %v", "0x68656c6c6f20776f726c64", args.Data)
+	}
+
+	if args.Sig != "0x1234" {
+		t.Errorf("Sig should be %v but is %v", "0x1234", args.Sig)
+	}
+}
+
+func TestEcRecoverArgsSigInvalid(t *testing.T) {
+	input := `["0x68656c6c6f20776f726c64", false]`
+
+	args := new(EcRecoverArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
 func TestGetBalanceArgs(t *testing.T) {
 	input := `["0x407d73d8a49eeb85d32cf465507dd71d507100c1", "0x1f"]`
 	expected := new(GetBalanceArgs)
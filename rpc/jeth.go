@@ -22,9 +22,12 @@ func (self *Jeth) err(code int, msg string, id interface{}) (response otto.Value
 	rpcerr := &RpcErrorObject{code, msg}
 	self.re.Set("ret_jsonrpc", jsonrpcver)
 	self.re.Set("ret_id", id)
-	self.re.Set("ret_error", rpcerr)
+
+	// pass the error as JSON so its fields get their JSON names
+	res, _ := json.Marshal(rpcerr)
+	self.re.Set("ret_error", string(res))
 	response, _ = self.re.Run(`
-		ret_response = { jsonrpc: ret_jsonrpc, id: ret_id, error: ret_error };
+		ret_response = { jsonrpc: ret_jsonrpc, id: ret_id, error: JSON.parse(ret_error) };
 	`)
 	return
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/big"
 	"runtime"
@@ -268,12 +269,25 @@ func LoadJSON(file string, val interface{}) error {
 	if err != nil {
 		return err
 	}
+	return unmarshalJSON(file, content, val)
+}
+
+// ReadJSON reads JSON test fixtures from r.
+func ReadJSON(r io.Reader, val interface{}) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return unmarshalJSON("input", content, val)
+}
+
+func unmarshalJSON(name string, content []byte, val interface{}) error {
 	if err := json.Unmarshal(content, val); err != nil {
 		if syntaxerr, ok := err.(*json.SyntaxError); ok {
 			line := findLine(content, syntaxerr.Offset)
-			return fmt.Errorf("JSON syntax error at %v:%v: %v", file, line, err)
+			return fmt.Errorf("JSON syntax error at %v:%v: %v", name, line, err)
 		}
-		return fmt.Errorf("JSON unmarshal error in %v: %v", file, err)
+		return fmt.Errorf("JSON unmarshal error in %v: %v", name, err)
 	}
 	return nil
 }
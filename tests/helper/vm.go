@@ -9,9 +9,18 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// VmType is the type of VM the tests are run with.
+	VmType = vm.StdVmTy
+	// ChainConfig holds the rules the tests are run with.
+	ChainConfig = params.DefaultChainConfig
 )
 
 type Env struct {
+	vmType       vm.Type
 	depth        int
 	state        *state.StateDB
 	skipTransfer bool
@@ -27,14 +36,15 @@ type Env struct {
 	difficulty *big.Int
 	gasLimit   *big.Int
 
-	logs state.Logs
+	structLogs []vm.StructLog
 
 	vmTest bool
 }
 
 func NewEnv(state *state.StateDB) *Env {
 	return &Env{
-		state: state,
+		vmType: VmType,
+		state:  state,
 	}
 }
 
@@ -62,12 +72,24 @@ func (self *Env) Time() int64              { return self.time }
 func (self *Env) Difficulty() *big.Int     { return self.difficulty }
 func (self *Env) State() *state.StateDB    { return self.state }
 func (self *Env) GasLimit() *big.Int       { return self.gasLimit }
-func (self *Env) VmType() vm.Type          { return vm.StdVmTy }
+func (self *Env) VmType() vm.Type          { return self.vmType }
+func (self *Env) Rules() *params.RuleSet {
+	if self.number == nil {
+		return ChainConfig.Rules(common.Big0)
+	}
+	return ChainConfig.Rules(self.number)
+}
 func (self *Env) GetHash(n uint64) common.Hash {
 	return common.BytesToHash(crypto.Sha3([]byte(big.NewInt(int64(n)).String())))
 }
 func (self *Env) AddLog(log *state.Log) {
-	self.logs = append(self.logs, log)
+	self.state.AddLog(log)
+}
+func (self *Env) AddStructLog(log vm.StructLog) {
+	self.structLogs = append(self.structLogs, log)
+}
+func (self *Env) StructLogs() []vm.StructLog {
+	return self.structLogs
 }
 func (self *Env) Depth() int     { return self.depth }
 func (self *Env) SetDepth(i int) { self.depth = i }
@@ -153,7 +175,7 @@ func RunVm(state *state.StateDB, env, exec map[string]string) ([]byte, state.Log
 	vmenv.initial = true
 	ret, err := vmenv.Call(caller, to, data, gas, price, value)
 
-	return ret, vmenv.logs, vmenv.Gas, err
+	return ret, state.Logs(), vmenv.Gas, err
 }
 
 func RunState(statedb *state.StateDB, env, tx map[string]string) ([]byte, state.Logs, *big.Int, error) {
@@ -163,6 +185,7 @@ func RunState(statedb *state.StateDB, env, tx map[string]string) ([]byte, state.
 		gas        = common.Big(tx["gasLimit"])
 		price      = common.Big(tx["gasPrice"])
 		value      = common.Big(tx["value"])
+		nonce      = common.Big(tx["nonce"]).Uint64()
 		caddr      = common.HexToAddress(env["currentCoinbase"])
 	)
 
@@ -178,7 +201,7 @@ func RunState(statedb *state.StateDB, env, tx map[string]string) ([]byte, state.
 	coinbase := statedb.GetOrNewStateObject(caddr)
 	coinbase.SetGasPool(common.Big(env["currentGasLimit"]))
 
-	message := NewMessage(common.BytesToAddress(keyPair.Address()), to, data, value, gas, price)
+	message := NewMessage(common.BytesToAddress(keyPair.Address()), to, data, value, gas, price, nonce)
 	vmenv := NewEnvFromMap(statedb, env, tx)
 	vmenv.origin = common.BytesToAddress(keyPair.Address())
 	ret, _, err := core.ApplyMessage(vmenv, message, coinbase)
@@ -187,7 +210,7 @@ func RunState(statedb *state.StateDB, env, tx map[string]string) ([]byte, state.
 	}
 	statedb.Update()
 
-	return ret, vmenv.logs, vmenv.Gas, err
+	return ret, statedb.Logs(), vmenv.Gas, err
 }
 
 type Message struct {
@@ -195,10 +218,11 @@ type Message struct {
 	to                *common.Address
 	value, gas, price *big.Int
 	data              []byte
+	nonce             uint64
 }
 
-func NewMessage(from common.Address, to *common.Address, data []byte, value, gas, price *big.Int) Message {
-	return Message{from, to, value, gas, price, data}
+func NewMessage(from common.Address, to *common.Address, data []byte, value, gas, price *big.Int, nonce uint64) Message {
+	return Message{from, to, value, gas, price, data, nonce}
 }
 
 func (self Message) Hash() []byte                  { return nil }
@@ -207,5 +231,5 @@ func (self Message) To() *common.Address           { return self.to }
 func (self Message) GasPrice() *big.Int            { return self.price }
 func (self Message) Gas() *big.Int                 { return self.gas }
 func (self Message) Value() *big.Int               { return self.value }
-func (self Message) Nonce() uint64                 { return 0 }
+func (self Message) Nonce() uint64                 { return self.nonce }
 func (self Message) Data() []byte                  { return self.data }
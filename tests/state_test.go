@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var stateTestDir = filepath.Join("files", "StateTests")
+
+// stateSkipTests are state tests known to fail.
+var stateSkipTests []string
+
+// slowStateTests are the state test files skipped by the compiled VM test.
+var slowStateTests = map[string]bool{
+	"stMemoryStressTest.json":        true,
+	"stQuadraticComplexityTest.json": true,
+}
+
+func TestStateExample(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stExample.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateSystemOperations(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stSystemOperationsTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatePreCompiledContracts(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stPreCompiledContracts.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateRecursiveCreate(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stRecursiveCreate.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateSpecial(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stSpecialTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateRefund(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stRefundTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateBlockHash(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stBlockHashTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateInitCode(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stInitCodeTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateLog(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stLogTests.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateTransaction(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stTransactionTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCallCreateCallCode(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stCallCreateCallCodeTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stMemoryTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryStress(t *testing.T) {
+	t.Skip() // needs too much memory
+	fn := filepath.Join(stateTestDir, "stMemoryStressTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuadraticComplexity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	fn := filepath.Join(stateTestDir, "stQuadraticComplexityTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSolidity(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stSolidityTest.json")
+	if err := RunStateTests(fn, stateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStateRandom(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(stateTestDir, "RandomTests", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range files {
+		if err := RunStateTests(fn, stateSkipTests); err != nil {
+			t.Errorf("%s: %v", fn, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/tests/helper"
+)
+
+// State Test JSON Format
+type StateTest struct {
+	Env           VmEnv
+	Transaction   map[string]string
+	Logs          []Log
+	Out           string
+	Post          map[string]Account
+	Pre           map[string]Account
+	PostStateRoot string
+}
+
+// LoadStateTests loads a state test JSON file.
+func LoadStateTests(file string) (map[string]StateTest, error) {
+	tests := make(map[string]StateTest)
+	if err := LoadJSON(file, &tests); err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+// RunStateTests runs the state tests of the given file. It returns an
+// error describing the failed tests, if any.
+func RunStateTests(file string, skipTests []string) error {
+	tests, err := LoadStateTests(file)
+	if err != nil {
+		return err
+	}
+	return runStateTests(tests, skipTests).Err()
+}
+
+// RunStateTestsWithReader runs the state tests read from r and returns the
+// result of every test.
+func RunStateTestsWithReader(r io.Reader, skipTests []string) (Results, error) {
+	tests := make(map[string]StateTest)
+	if err := ReadJSON(r, &tests); err != nil {
+		return nil, err
+	}
+	return runStateTests(tests, skipTests), nil
+}
+
+func runStateTests(tests map[string]StateTest, skipTests []string) Results {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	return runTests(names, skipTests, func(name string) error {
+		return runStateTest(tests[name])
+	})
+}
+
+func runStateTest(test StateTest) error {
+	db, _ := ethdb.NewMemDatabase()
+	statedb := state.New(common.Hash{}, db)
+	insertAccounts(db, statedb, test.Pre)
+
+	// Failing transactions are part of the tests, the post state is what
+	// counts.
+	ret, logs, _, _ := helper.RunState(statedb, test.Env.values(), test.Transaction)
+
+	if out := helper.FromHex(test.Out); !bytes.Equal(ret, out) {
+		return fmt.Errorf("output mismatch: want %x, got %x", out, ret)
+	}
+	if err := checkAccounts(statedb, test.Post, true); err != nil {
+		return err
+	}
+	statedb.Sync()
+	if root := common.HexToHash(test.PostStateRoot); statedb.Root() != root {
+		return fmt.Errorf("post state root mismatch: want %x, got %x", root, statedb.Root())
+	}
+	if test.Logs != nil {
+		if err := checkLogs(test.Logs, logs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
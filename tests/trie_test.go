@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var trieTestDir = filepath.Join("files", "TrieTests")
+
+func TestTrieAnyOrder(t *testing.T) {
+	fn := filepath.Join(trieTestDir, "trieanyorder.json")
+	if err := RunTrieTests(fn, false, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTrie(t *testing.T) {
+	fn := filepath.Join(trieTestDir, "trietest.json")
+	if err := RunTrieTests(fn, false, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSecureTrieAnyOrder(t *testing.T) {
+	fn := filepath.Join(trieTestDir, "trieanyorder_secureTrie.json")
+	if err := RunTrieTests(fn, true, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSecureTrie(t *testing.T) {
+	fn := filepath.Join(trieTestDir, "trietest_secureTrie.json")
+	if err := RunTrieTests(fn, true, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHexEncodedSecureTrie(t *testing.T) {
+	fn := filepath.Join(trieTestDir, "hex_encoded_securetrie_test.json")
+	if err := RunTrieTests(fn, true, nil); err != nil {
+		t.Error(err)
+	}
+}
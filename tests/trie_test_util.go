@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Trie Test JSON Format
+//
+// The input is either an object of key/value pairs which may be inserted
+// in any order, or a list of [key, value] pairs applied in order where a
+// null value deletes the key. Keys and values starting with 0x are hex
+// encoded.
+type TrieTest struct {
+	In   json.RawMessage
+	Root string
+}
+
+// trieOp is a single update of a trie test.
+type trieOp struct {
+	key, value []byte
+	delete     bool
+}
+
+// LoadTrieTests loads a trie test JSON file.
+func LoadTrieTests(file string) (map[string]TrieTest, error) {
+	tests := make(map[string]TrieTest)
+	if err := LoadJSON(file, &tests); err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+// RunTrieTests runs the trie tests of the given file. If secure is set, the
+// tests are run against a secure trie, which hashes its keys. It returns an
+// error describing the failed tests, if any.
+func RunTrieTests(file string, secure bool, skipTests []string) error {
+	tests, err := LoadTrieTests(file)
+	if err != nil {
+		return err
+	}
+	return runTrieTests(tests, secure, skipTests).Err()
+}
+
+// RunTrieTestsWithReader runs the trie tests read from r and returns the
+// result of every test.
+func RunTrieTestsWithReader(r io.Reader, secure bool, skipTests []string) (Results, error) {
+	tests := make(map[string]TrieTest)
+	if err := ReadJSON(r, &tests); err != nil {
+		return nil, err
+	}
+	return runTrieTests(tests, secure, skipTests), nil
+}
+
+func runTrieTests(tests map[string]TrieTest, secure bool, skipTests []string) Results {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	return runTests(names, skipTests, func(name string) error {
+		return runTrieTest(tests[name], secure)
+	})
+}
+
+func runTrieTest(test TrieTest, secure bool) error {
+	ops, err := test.ops()
+	if err != nil {
+		return err
+	}
+
+	db, _ := ethdb.NewMemDatabase()
+	var tr interface {
+		Update(key, value []byte) trie.Node
+		Delete(key []byte) trie.Node
+		Hash() []byte
+	}
+	if secure {
+		tr = trie.NewSecure(nil, db)
+	} else {
+		tr = trie.New(nil, db)
+	}
+	for _, op := range ops {
+		if op.delete {
+			tr.Delete(op.key)
+		} else {
+			tr.Update(op.key, op.value)
+		}
+	}
+
+	if root := common.HexToHash(test.Root); common.BytesToHash(tr.Hash()) != root {
+		return fmt.Errorf("root mismatch: want %x, got %x", root, tr.Hash())
+	}
+	return nil
+}
+
+// ops decodes the input of the test into trie updates.
+func (self TrieTest) ops() ([]trieOp, error) {
+	var ordered [][]*string
+	if err := json.Unmarshal(self.In, &ordered); err == nil {
+		ops := make([]trieOp, len(ordered))
+		for i, kv := range ordered {
+			if len(kv) != 2 || kv[0] == nil {
+				return nil, fmt.Errorf("invalid input pair %d", i)
+			}
+			ops[i].key = trieBytes(*kv[0])
+			if kv[1] == nil {
+				ops[i].delete = true
+			} else {
+				ops[i].value = trieBytes(*kv[1])
+			}
+		}
+		return ops, nil
+	}
+
+	var anyorder map[string]string
+	if err := json.Unmarshal(self.In, &anyorder); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	keys := make([]string, 0, len(anyorder))
+	for key := range anyorder {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ops := make([]trieOp, len(keys))
+	for i, key := range keys {
+		ops[i] = trieOp{key: trieBytes(key), value: trieBytes(anyorder[key])}
+	}
+	return ops, nil
+}
+
+func trieBytes(s string) []byte {
+	if strings.HasPrefix(s, "0x") {
+		return common.FromHex(s)
+	}
+	return []byte(s)
+}
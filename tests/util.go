@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/tests/helper"
+)
+
+// Account is an account of the pre or post state of a state or VM test.
+type Account struct {
+	Balance string
+	Code    string
+	Nonce   string
+	Storage map[string]string
+}
+
+// Log is a log entry expected by a state or VM test.
+type Log struct {
+	AddressF string   `json:"address"`
+	DataF    string   `json:"data"`
+	TopicsF  []string `json:"topics"`
+	BloomF   string   `json:"bloom"`
+}
+
+// VmEnv is the block environment of a state or VM test.
+type VmEnv struct {
+	CurrentCoinbase   string
+	CurrentDifficulty string
+	CurrentGasLimit   string
+	CurrentNumber     string
+	CurrentTimestamp  interface{}
+	PreviousHash      string
+}
+
+// values converts the environment to the form expected by tests/helper.
+func (self VmEnv) values() map[string]string {
+	env := map[string]string{
+		"currentCoinbase":   self.CurrentCoinbase,
+		"currentDifficulty": self.CurrentDifficulty,
+		"currentGasLimit":   self.CurrentGasLimit,
+		"currentNumber":     self.CurrentNumber,
+		"previousHash":      self.PreviousHash,
+	}
+	// the timestamp is a number in some of the fixtures
+	switch ts := self.CurrentTimestamp.(type) {
+	case float64:
+		env["currentTimestamp"] = strconv.FormatInt(int64(ts), 10)
+	case string:
+		env["currentTimestamp"] = ts
+	}
+	return env
+}
+
+// TestResult is the outcome of a single test of a fixture file.
+type TestResult struct {
+	Name    string `json:"name"`
+	Pass    bool   `json:"pass"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Results are the outcomes of the tests of a fixture file, ordered by name.
+type Results []TestResult
+
+// Err returns an error listing the failed tests, or nil if none failed.
+func (self Results) Err() error {
+	var failed []string
+	for _, r := range self {
+		if !r.Pass && !r.Skipped {
+			failed = append(failed, fmt.Sprintf("%s: %s", r.Name, r.Error))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d tests failed:\n%s", len(failed), len(self), strings.Join(failed, "\n"))
+}
+
+// runTests runs every named test in name order using run, skipping the
+// tests listed in skipTests.
+func runTests(names []string, skipTests []string, run func(name string) error) Results {
+	skip := make(map[string]bool, len(skipTests))
+	for _, name := range skipTests {
+		skip[name] = true
+	}
+	sort.Strings(names)
+
+	results := make(Results, len(names))
+	for i, name := range names {
+		results[i].Name = name
+		if skip[name] {
+			results[i].Skipped = true
+			continue
+		}
+		if err := run(name); err != nil {
+			results[i].Error = err.Error()
+		} else {
+			results[i].Pass = true
+		}
+	}
+	return results
+}
+
+// insertAccounts adds the pre state accounts of a test to statedb.
+func insertAccounts(db common.Database, statedb *state.StateDB, accounts map[string]Account) {
+	for addr, account := range accounts {
+		obj := state.NewStateObject(common.HexToAddress(addr), db)
+		obj.SetBalance(common.Big(account.Balance))
+		obj.SetCode(helper.FromHex(account.Code))
+		obj.SetNonce(common.Big(account.Nonce).Uint64())
+		for key, value := range account.Storage {
+			obj.SetState(common.HexToHash(key), common.NewValue(helper.FromHex(value)))
+		}
+		statedb.SetStateObject(obj)
+	}
+}
+
+// checkAccounts compares the storage of the accounts in statedb with the
+// expected post state. If full is set, balances, nonces and code are
+// compared as well.
+func checkAccounts(statedb *state.StateDB, post map[string]Account, full bool) error {
+	for addr, account := range post {
+		obj := statedb.GetStateObject(common.HexToAddress(addr))
+		if obj == nil {
+			// An account without storage can't be told apart from a
+			// missing one when only the storage is compared.
+			if !full && len(account.Storage) == 0 {
+				continue
+			}
+			return fmt.Errorf("account %s missing", addr)
+		}
+		if full {
+			if balance := common.Big(account.Balance); obj.Balance().Cmp(balance) != 0 {
+				return fmt.Errorf("account %s: balance mismatch: want %v, got %v", addr, balance, obj.Balance())
+			}
+			if nonce := common.Big(account.Nonce).Uint64(); obj.Nonce() != nonce {
+				return fmt.Errorf("account %s: nonce mismatch: want %d, got %d", addr, nonce, obj.Nonce())
+			}
+			if code := helper.FromHex(account.Code); !bytes.Equal(obj.Code(), code) {
+				return fmt.Errorf("account %s: code mismatch: want %x, got %x", addr, code, obj.Code())
+			}
+		}
+		for key, value := range account.Storage {
+			want := helper.FromHex(value)
+			if have := obj.GetState(common.HexToHash(key)).Bytes(); !bytes.Equal(have, want) {
+				return fmt.Errorf("account %s: storage %s mismatch: want %x, got %x", addr, key, want, have)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLogs compares the logs created by a test with the expected ones.
+func checkLogs(want []Log, have state.Logs) error {
+	if len(want) != len(have) {
+		return fmt.Errorf("log count mismatch: want %d, got %d", len(want), len(have))
+	}
+	for i, log := range want {
+		if common.HexToAddress(log.AddressF) != have[i].Address {
+			return fmt.Errorf("log %d: address mismatch: want %s, got %x", i, log.AddressF, have[i].Address)
+		}
+		if data := helper.FromHex(log.DataF); !bytes.Equal(have[i].Data, data) {
+			return fmt.Errorf("log %d: data mismatch: want %x, got %x", i, data, have[i].Data)
+		}
+		if len(log.TopicsF) != len(have[i].Topics) {
+			return fmt.Errorf("log %d: topic count mismatch: want %d, got %d", i, len(log.TopicsF), len(have[i].Topics))
+		}
+		for j, topic := range log.TopicsF {
+			if common.HexToHash(topic) != have[i].Topics[j] {
+				return fmt.Errorf("log %d: topic %d mismatch: want %s, got %x", i, j, topic, have[i].Topics[j])
+			}
+		}
+		bloom := common.LeftPadBytes(types.LogsBloom(state.Logs{have[i]}).Bytes(), 256)
+		if want := helper.FromHex(log.BloomF); !bytes.Equal(bloom, want) {
+			return fmt.Errorf("log %d: bloom mismatch: want %x, got %x", i, want, bloom)
+		}
+	}
+	return nil
+}
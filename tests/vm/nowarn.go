@@ -1,3 +0,0 @@
-// This silences the warning given by 'go install ./...'.
-
-package vm
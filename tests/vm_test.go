@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/tests/helper"
+)
+
+var vmTestDir = filepath.Join("files", "VMTests")
+
+// vmSkipTests are VM tests known to fail.
+var vmSkipTests = []string{
+	// TODO: CREATE with a value above the balance of the caller
+	// costs less gas than expected.
+	"createNameRegistratorValueTooHigh",
+}
+
+func TestVMArithmetic(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmArithmeticTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBitwiseLogicOperation(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmBitwiseLogicOperationTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBlockInfo(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmBlockInfoTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEnvironmentalInfo(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmEnvironmentalInfoTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFlowOperation(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmIOandFlowOperationsTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLogTest(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmLogTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPerformance(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmPerformanceTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPushDupSwap(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmPushDupSwapTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVMSha3(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmSha3Test.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVMSystemOperations(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmSystemOperationsTest.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVm(t *testing.T) {
+	fn := filepath.Join(vmTestDir, "vmtests.json")
+	if err := RunVmTests(fn, vmSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVMRandom(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(vmTestDir, "RandomTests", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range files {
+		if err := RunVmTests(fn, vmSkipTests); err != nil {
+			t.Errorf("%s: %v", fn, err)
+		}
+	}
+}
+
+// TestCompiledVm runs the VM and state tests with the compiled VM, which
+// must give the same results as the interpreter.
+func TestCompiledVm(t *testing.T) {
+	helper.VmType = vm.CompiledVmTy
+	defer func() { helper.VmType = vm.StdVmTy }()
+
+	vmFiles, err := filepath.Glob(filepath.Join(vmTestDir, "vm*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range vmFiles {
+		if err := RunVmTests(fn, vmSkipTests); err != nil {
+			t.Errorf("%s: %v", fn, err)
+		}
+	}
+	stateFiles, err := filepath.Glob(filepath.Join(stateTestDir, "st*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range stateFiles {
+		if slowStateTests[filepath.Base(fn)] {
+			continue
+		}
+		if err := RunStateTests(fn, stateSkipTests); err != nil {
+			t.Errorf("%s: %v", fn, err)
+		}
+	}
+}
+
+// BenchmarkVMTests runs the code of all VM tests over and over, like hot
+// contracts that are called many times per block.
+func BenchmarkVMTests(b *testing.B) {
+	benchmarkVMTests(b, vm.StdVmTy)
+}
+
+func BenchmarkCompiledVMTests(b *testing.B) {
+	benchmarkVMTests(b, vm.CompiledVmTy)
+}
+
+func benchmarkVMTests(b *testing.B, typ vm.Type) {
+	helper.VmType = typ
+	defer func() { helper.VmType = vm.StdVmTy }()
+
+	files, err := filepath.Glob(filepath.Join(vmTestDir, "vm*Test.json"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var tests []VmTest
+	for _, fn := range files {
+		if filepath.Base(fn) == "vmPerformanceTest.json" {
+			continue
+		}
+		fileTests, err := LoadVmTests(fn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, test := range fileTests {
+			tests = append(tests, test)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, test := range tests {
+			runVmTest(test)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/tests/helper"
+)
+
+// VM Test JSON Format
+type VmTest struct {
+	Callcreates interface{}
+	Env         VmEnv
+	Exec        map[string]string
+	Logs        []Log
+	Gas         string
+	Out         string
+	Post        map[string]Account
+	Pre         map[string]Account
+}
+
+// LoadVmTests loads a VM test JSON file.
+func LoadVmTests(file string) (map[string]VmTest, error) {
+	tests := make(map[string]VmTest)
+	if err := LoadJSON(file, &tests); err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+// RunVmTests runs the VM tests of the given file. It returns an error
+// describing the failed tests, if any.
+func RunVmTests(file string, skipTests []string) error {
+	tests, err := LoadVmTests(file)
+	if err != nil {
+		return err
+	}
+	return runVmTests(tests, skipTests).Err()
+}
+
+// RunVmTestsWithReader runs the VM tests read from r and returns the
+// result of every test.
+func RunVmTestsWithReader(r io.Reader, skipTests []string) (Results, error) {
+	tests := make(map[string]VmTest)
+	if err := ReadJSON(r, &tests); err != nil {
+		return nil, err
+	}
+	return runVmTests(tests, skipTests), nil
+}
+
+func runVmTests(tests map[string]VmTest, skipTests []string) Results {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	return runTests(names, skipTests, func(name string) error {
+		return runVmTest(tests[name])
+	})
+}
+
+func runVmTest(test VmTest) error {
+	db, _ := ethdb.NewMemDatabase()
+	statedb := state.New(common.Hash{}, db)
+	insertAccounts(db, statedb, test.Pre)
+
+	ret, logs, gas, err := helper.RunVm(statedb, test.Env.values(), test.Exec)
+
+	// Tests without a post state expect the execution to fail.
+	if test.Post == nil {
+		if err == nil {
+			return fmt.Errorf("execution succeeded, expected an error")
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("execution failed: %v", err)
+	}
+
+	if out := helper.FromHex(test.Out); !bytes.Equal(ret, out) {
+		return fmt.Errorf("output mismatch: want %x, got %x", out, ret)
+	}
+	if want := common.Big(test.Gas); gas.Cmp(want) != 0 {
+		return fmt.Errorf("gas mismatch: want %v, got %v", want, gas)
+	}
+	// Value transfers are not executed by VM tests, so only the storage of
+	// the post state is checked.
+	if err := checkAccounts(statedb, test.Post, false); err != nil {
+		return err
+	}
+	if test.Logs != nil {
+		if err := checkLogs(test.Logs, logs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
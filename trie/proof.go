@@ -0,0 +1,148 @@
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrProofMissingNode is returned by the proof verification if a node needed
+// for walking the path of a key is not contained within the proof.
+var ErrProofMissingNode = errors.New("proof node missing")
+
+// Prove constructs a merkle proof for key. The result contains all hash
+// referenced nodes on the path to the value at key, starting with the root
+// node. The value itself is also included in the last node and can be
+// retrieved by verifying the proof.
+//
+// If the trie does not contain a value for key, the returned proof contains
+// all nodes of the longest existing prefix of the key (at least the root node),
+// ending with the node that proves the absence of the key.
+func (self *Trie) Prove(key []byte) [][]byte {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.root == nil {
+		return nil
+	}
+	data := self.cache.Get(self.Hash())
+	if data == nil {
+		// Root nodes are never embedded, but small ones don't get stored either
+		data = common.Encode(self.root)
+	}
+	proof := [][]byte{data}
+
+	key = CompactHexDecode(string(key))
+	for node := common.NewValueFromBytes(data); ; {
+		ref, rest := proofStep(node, key)
+		if ref == nil || len(rest) == 0 {
+			return proof
+		}
+		key = rest
+
+		if ref.IsList() {
+			node = ref
+			continue
+		}
+		hash := ref.Bytes()
+		if len(hash) != 32 {
+			return proof
+		}
+		if data = self.cache.Get(hash); data == nil {
+			return proof
+		}
+		proof = append(proof, data)
+		node = common.NewValueFromBytes(data)
+	}
+}
+
+// VerifyProof checks a merkle proof against the given root hash. The proof
+// nodes may be in any order, the path of key is walked by looking them up by
+// hash. The value for key is returned if the proof is valid, or nil if the
+// proof proves the absence of the key. An error is returned if the proof is
+// invalid or incomplete.
+func VerifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	nodes := make(map[common.Hash][]byte)
+	for _, data := range proof {
+		nodes[common.BytesToHash(crypto.Sha3(data))] = data
+	}
+	if root == emptyRoot {
+		return nil, nil
+	}
+	data, ok := nodes[root]
+	if !ok {
+		return nil, fmt.Errorf("root %x: %v", root, ErrProofMissingNode)
+	}
+	key = CompactHexDecode(string(key))
+	for node := common.NewValueFromBytes(data); ; {
+		if !node.IsList() || (node.Len() != 2 && node.Len() != 17) {
+			return nil, fmt.Errorf("invalid trie node: %v", node)
+		}
+		ref, rest := proofStep(node, key)
+		if ref == nil {
+			return nil, nil
+		}
+		if len(rest) == 0 {
+			return ref.Bytes(), nil
+		}
+		key = rest
+
+		if ref.IsList() {
+			node = ref
+			continue
+		}
+		hash := ref.Bytes()
+		switch len(hash) {
+		case 0:
+			return nil, nil
+		case 32:
+		default:
+			return nil, fmt.Errorf("invalid node reference: %x", hash)
+		}
+		if data, ok = nodes[common.BytesToHash(hash)]; !ok {
+			return nil, fmt.Errorf("node %x: %v", hash, ErrProofMissingNode)
+		}
+		node = common.NewValueFromBytes(data)
+	}
+}
+
+// proofStep descends one level from a decoded trie node along the (terminated)
+// nibble key, returning the child reference and the remaining key. A nil child
+// means the key is not present within the trie. An empty remaining key means
+// the child is the value itself.
+func proofStep(node *common.Value, key []byte) (*common.Value, []byte) {
+	switch node.Len() {
+	case 2:
+		if node.Get(0).Len() == 0 {
+			return nil, nil
+		}
+		k := CompactDecode(string(node.Get(0).Bytes()))
+		if len(key) < len(k) || !bytes.Equal(k, key[:len(k)]) {
+			return nil, nil
+		}
+		return node.Get(1), key[len(k):]
+
+	case 17:
+		if key[0] == 16 {
+			if value := node.Get(16); value.Len() > 0 {
+				return value, key[1:]
+			}
+			return nil, nil
+		}
+		child := node.Get(int(key[0]))
+		if !child.IsList() && child.Len() == 0 {
+			return nil, nil
+		}
+		return child, key[1:]
+	}
+	return nil, nil
+}
+
+// Prove constructs a merkle proof for key, hashing it first as done by all
+// other secure trie operations.
+func (self *SecureTrie) Prove(key []byte) [][]byte {
+	return self.Trie.Prove(crypto.Sha3(key))
+}
@@ -0,0 +1,93 @@
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that a proof can be generated and verified for every key of a trie.
+func TestProof(t *testing.T) {
+	_, trie, content := makeTestTrie()
+	root := common.BytesToHash(trie.Root())
+
+	for key, val := range content {
+		proof := trie.Prove([]byte(key))
+		if len(proof) == 0 {
+			t.Fatalf("missing proof for key %x", key)
+		}
+		have, err := VerifyProof(root, []byte(key), proof)
+		if err != nil {
+			t.Fatalf("failed to verify proof for key %x: %v", key, err)
+		}
+		if !bytes.Equal(have, val) {
+			t.Fatalf("proven value mismatch for key %x: have %x, want %x", key, have, val)
+		}
+	}
+}
+
+// Tests that the absence of a key can be proven.
+func TestMissingKeyProof(t *testing.T) {
+	_, trie, _ := makeTestTrie()
+	root := common.BytesToHash(trie.Root())
+
+	for _, key := range [][]byte{common.LeftPadBytes([]byte{3, 0}, 32), common.LeftPadBytes([]byte{1, 0, 0}, 32), {0x01}} {
+		proof := trie.Prove(key)
+		if len(proof) == 0 {
+			t.Fatalf("missing proof for key %x", key)
+		}
+		val, err := VerifyProof(root, key, proof)
+		if err != nil {
+			t.Fatalf("failed to verify proof for key %x: %v", key, err)
+		}
+		if val != nil {
+			t.Fatalf("value %x proven for missing key %x", val, key)
+		}
+	}
+}
+
+// Tests that incomplete or tampered proofs are rejected.
+func TestBadProof(t *testing.T) {
+	_, trie, content := makeTestTrie()
+	root := common.BytesToHash(trie.Root())
+
+	for key := range content {
+		proof := trie.Prove([]byte(key))
+		if len(proof) < 2 {
+			t.Fatalf("proof too short for key %x: %d nodes", key, len(proof))
+		}
+		if _, err := VerifyProof(root, []byte(key), proof[:len(proof)-1]); err == nil {
+			t.Fatalf("incomplete proof accepted for key %x", key)
+		}
+		tampered := make([][]byte, len(proof))
+		copy(tampered, proof)
+		tampered[0] = common.CopyBytes(tampered[0])
+		tampered[0][len(tampered[0])-1] ^= 0x01
+		if _, err := VerifyProof(root, []byte(key), tampered); err == nil {
+			t.Fatalf("tampered proof accepted for key %x", key)
+		}
+		break
+	}
+}
+
+// Tests that proofs of a secure trie verify against hashed keys.
+func TestSecureProof(t *testing.T) {
+	trie := NewSecure(nil, make(Db))
+	for i := byte(0); i < 100; i++ {
+		trie.Update([]byte{i}, bytes.Repeat([]byte{i}, 40))
+	}
+	trie.Commit()
+	root := common.BytesToHash(trie.Root())
+
+	for i := byte(0); i < 100; i++ {
+		val, err := VerifyProof(root, crypto.Sha3([]byte{i}), trie.Prove([]byte{i}))
+		if err != nil {
+			t.Fatalf("failed to verify proof for key %x: %v", i, err)
+		}
+		if !bytes.Equal(val, bytes.Repeat([]byte{i}, 40)) {
+			t.Fatalf("proven value mismatch for key %x: have %x", i, val)
+		}
+	}
+}
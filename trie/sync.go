@@ -0,0 +1,245 @@
+package trie
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrNotRequested is returned by the trie sync when it's requested to process
+	// a node it did not request.
+	ErrNotRequested = errors.New("not requested")
+
+	emptyRoot = common.BytesToHash(crypto.Sha3(common.Encode("")))
+	emptyCode = common.BytesToHash(crypto.Sha3(nil))
+)
+
+// SyncResult is a simple list to return missing nodes along with their request
+// hashes.
+type SyncResult struct {
+	Hash common.Hash // Hash of the originally unknown trie node
+	Data []byte      // Data content of the retrieved node
+}
+
+// TrieSyncLeafCallback is a callback type invoked when a trie sync reaches a
+// leaf node. It's used by state syncing to check if the leaf node requires some
+// further data syncing (e.g. the storage trie or code of an account).
+type TrieSyncLeafCallback func(leaf []byte, parent common.Hash) error
+
+// request represents a scheduled or already in-flight retrieval request of a
+// trie node or a raw database entry (e.g. contract code).
+type request struct {
+	hash common.Hash // Hash of the node data content to retrieve
+	data []byte      // Data content of the node, cached until all subtrees complete
+	raw  bool        // Whether this is a raw entry (code) or a trie node
+
+	parents []*request // Parent state nodes referencing this entry (notify all upon completion)
+	deps    int        // Number of dependencies before allowed to commit this node
+
+	callback TrieSyncLeafCallback // Callback to invoke if a leaf node it reached on this branch
+}
+
+// TrieSync is the main state trie synchronisation scheduler, which provides yet
+// unknown trie hashes to retrieve, accepts node data associated with said hashes
+// and reconstructs the trie step by step until all is done. A node is only ever
+// written into the database once all of its children are present, so a node
+// found in the database always roots a complete subtrie.
+type TrieSync struct {
+	database Backend                  // Persistent database to check for existing entries
+	requests map[common.Hash]*request // Pending requests pertaining to a key hash
+	queue    []common.Hash            // Hashes scheduled but not yet handed out for retrieval
+}
+
+// NewTrieSync creates a new trie data download scheduler.
+func NewTrieSync(root common.Hash, database Backend, callback TrieSyncLeafCallback) *TrieSync {
+	ts := &TrieSync{
+		database: database,
+		requests: make(map[common.Hash]*request),
+	}
+	ts.AddSubTrie(root, common.Hash{}, callback)
+	return ts
+}
+
+// AddSubTrie registers a new trie to the sync code, rooted at the designated
+// parent. A zero parent hash denotes a trie without a parent (e.g. the root).
+func (self *TrieSync) AddSubTrie(root, parent common.Hash, callback TrieSyncLeafCallback) {
+	if root == emptyRoot {
+		return
+	}
+	self.schedule(&request{hash: root, callback: callback}, parent)
+}
+
+// AddRawEntry schedules the direct retrieval of a state entry that should not
+// be interpreted as a trie node, but rather accepted and stored into the
+// database as is. This method's goal is to support misc state metadata
+// retrievals (e.g. contract code).
+func (self *TrieSync) AddRawEntry(hash, parent common.Hash) {
+	if hash == emptyCode {
+		return
+	}
+	self.schedule(&request{hash: hash, raw: true}, parent)
+}
+
+// schedule inserts a new request into the retrieval queue, linking it to its
+// parent. Entries already present in the database are skipped and entries
+// already pending only get an additional parent reference.
+func (self *TrieSync) schedule(req *request, parent common.Hash) {
+	if data, _ := self.database.Get(req.hash[:]); len(data) > 0 {
+		return
+	}
+	var ancestor *request
+	if parent != (common.Hash{}) {
+		ancestor = self.requests[parent]
+		if ancestor == nil {
+			panic(fmt.Sprintf("sub-trie ancestor not found: %x", parent))
+		}
+	}
+	if old, ok := self.requests[req.hash]; ok {
+		if ancestor != nil {
+			old.parents = append(old.parents, ancestor)
+			ancestor.deps++
+		}
+		return
+	}
+	if ancestor != nil {
+		req.parents = append(req.parents, ancestor)
+		ancestor.deps++
+	}
+	self.requests[req.hash] = req
+	self.queue = append(self.queue, req.hash)
+}
+
+// Missing retrieves up to max of the known but not yet requested trie node
+// hashes, removing them from the retrieval queue. A max of zero means no
+// limit. Hashes whose retrieval failed need to be fed back through Reschedule.
+func (self *TrieSync) Missing(max int) []common.Hash {
+	if max == 0 || max > len(self.queue) {
+		max = len(self.queue)
+	}
+	hashes := make([]common.Hash, max)
+	copy(hashes, self.queue)
+	self.queue = self.queue[max:]
+
+	return hashes
+}
+
+// Reschedule puts previously handed out but undelivered hashes back into the
+// retrieval queue.
+func (self *TrieSync) Reschedule(hashes []common.Hash) {
+	for _, hash := range hashes {
+		if req, ok := self.requests[hash]; ok && req.data == nil {
+			self.queue = append(self.queue, hash)
+		}
+	}
+}
+
+// Process injects a batch of retrieved trie nodes data, returning the number of
+// entries committed to the database and any error that occurred.
+func (self *TrieSync) Process(results []SyncResult) (int, error) {
+	committed := 0
+	for i, item := range results {
+		req := self.requests[item.Hash]
+		if req == nil {
+			return committed, fmt.Errorf("item #%d: %v", i, ErrNotRequested)
+		}
+		if req.data != nil {
+			continue
+		}
+		if hash := common.BytesToHash(crypto.Sha3(item.Data)); hash != item.Hash {
+			return committed, fmt.Errorf("item #%d: hash mismatch: have %x, want %x", i, hash, item.Hash)
+		}
+		req.data = item.Data
+
+		if !req.raw {
+			node := common.NewValueFromBytes(item.Data)
+			if err := self.children(req, node); err != nil {
+				return committed, fmt.Errorf("item #%d: %v", i, err)
+			}
+		}
+		if req.deps == 0 {
+			committed += self.commit(req)
+		}
+	}
+	return committed, nil
+}
+
+// Pending returns the number of state entries currently pending for download.
+func (self *TrieSync) Pending() int {
+	return len(self.requests)
+}
+
+// children walks a decoded trie node, scheduling the retrieval of all hash
+// referenced children and invoking the leaf callback on all values. Children
+// small enough to be embedded into their parent are walked recursively.
+func (self *TrieSync) children(req *request, node *common.Value) error {
+	if !node.IsList() {
+		return fmt.Errorf("invalid trie node: %v", node)
+	}
+	switch node.Len() {
+	case 2:
+		if node.Get(0).Len() == 0 {
+			return fmt.Errorf("invalid short node: empty key")
+		}
+		key := CompactDecode(string(node.Get(0).Bytes()))
+		if key[len(key)-1] == 16 {
+			return self.leaf(req, node.Get(1).Bytes())
+		}
+		return self.child(req, node.Get(1))
+
+	case 17:
+		for i := 0; i < 16; i++ {
+			if err := self.child(req, node.Get(i)); err != nil {
+				return err
+			}
+		}
+		if value := node.Get(16).Bytes(); len(value) > 0 {
+			return self.leaf(req, value)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid trie node: %d items", node.Len())
+}
+
+// child schedules a single child reference of a trie node.
+func (self *TrieSync) child(req *request, ref *common.Value) error {
+	if ref.IsList() {
+		return self.children(req, ref)
+	}
+	switch hash := ref.Bytes(); len(hash) {
+	case 0:
+		return nil
+	case 32:
+		self.schedule(&request{hash: common.BytesToHash(hash), callback: req.callback}, req.hash)
+		return nil
+	default:
+		return fmt.Errorf("invalid node reference: %x", hash)
+	}
+}
+
+// leaf invokes the leaf callback, if any, on a value found in a trie node.
+func (self *TrieSync) leaf(req *request, value []byte) error {
+	if req.callback == nil {
+		return nil
+	}
+	return req.callback(value, req.hash)
+}
+
+// commit finalizes a retrieval request and stores it into the database. If any
+// of the referencing parent requests complete due to this commit, they are also
+// committed themselves. The number of committed entries is returned.
+func (self *TrieSync) commit(req *request) int {
+	self.database.Put(req.hash[:], req.data)
+	delete(self.requests, req.hash)
+
+	committed := 1
+	for _, parent := range req.parents {
+		parent.deps--
+		if parent.deps == 0 && parent.data != nil {
+			committed += self.commit(parent)
+		}
+	}
+	return committed
+}
@@ -0,0 +1,187 @@
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// makeTestTrie create a sample test trie to test node-wise reconstruction.
+func makeTestTrie() (Db, *Trie, map[string][]byte) {
+	// Create an empty trie
+	db := make(Db)
+	trie := New(nil, db)
+
+	// Fill it with some arbitrary data
+	content := make(map[string][]byte)
+	for i := byte(0); i < 255; i++ {
+		key, val := common.LeftPadBytes([]byte{1, i}, 32), []byte{i}
+		content[string(key)] = val
+		trie.Update(key, val)
+
+		key, val = common.LeftPadBytes([]byte{2, i}, 32), []byte{i}
+		content[string(key)] = val
+		trie.Update(key, val)
+	}
+	trie.Commit()
+
+	return db, trie, content
+}
+
+// checkTrieContents cross references a reconstructed trie with an expected
+// data content map.
+func checkTrieContents(t *testing.T, db Db, root []byte, content map[string][]byte) {
+	trie := New(root, db)
+	for key, val := range content {
+		if have := trie.Get([]byte(key)); !bytes.Equal(have, val) {
+			t.Errorf("entry %x: content mismatch: have %x, want %x", key, have, val)
+		}
+	}
+}
+
+// syncTrie fetches the data for all requested hashes from the source database
+// in batches of the given size until the scheduler has nothing left pending.
+func syncTrie(t *testing.T, sched *TrieSync, srcDb Db, batch int) {
+	for queue := sched.Missing(batch); len(queue) > 0; queue = sched.Missing(batch) {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, _ := srcDb.Get(hash[:])
+			if data == nil {
+				t.Fatalf("failed to retrieve node data for %x", hash)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process results: %v", err)
+		}
+	}
+	if pending := sched.Pending(); pending != 0 {
+		t.Fatalf("sync finished with %d pending requests", pending)
+	}
+}
+
+// Tests that an empty trie is not scheduled for syncing.
+func TestEmptyTrieSync(t *testing.T) {
+	emptyA := New(nil, make(Db))
+	emptyB := New(emptyRoot[:], make(Db))
+
+	for i, trie := range []*Trie{emptyA, emptyB} {
+		if req := NewTrieSync(common.BytesToHash(trie.Root()), make(Db), nil).Missing(1); len(req) != 0 {
+			t.Errorf("test %d: content requested for empty trie: %v", i, req)
+		}
+	}
+}
+
+// Tests that given a root hash, a trie can sync iteratively on a single thread,
+// requesting retrieval tasks and returning all of them in one go.
+func TestIterativeTrieSyncIndividual(t *testing.T) { testIterativeTrieSync(t, 1) }
+func TestIterativeTrieSyncBatched(t *testing.T)    { testIterativeTrieSync(t, 100) }
+func TestIterativeTrieSyncUnlimited(t *testing.T)  { testIterativeTrieSync(t, 0) }
+
+func testIterativeTrieSync(t *testing.T, batch int) {
+	srcDb, srcTrie, srcData := makeTestTrie()
+
+	dstDb := make(Db)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	syncTrie(t, sched, srcDb, batch)
+
+	checkTrieContents(t, dstDb, srcTrie.Root(), srcData)
+}
+
+// Tests that the trie scheduler can correctly reconstruct the state even if only
+// partial results are returned, and the others sent only later.
+func TestIterativeDelayedTrieSync(t *testing.T) {
+	srcDb, srcTrie, srcData := makeTestTrie()
+
+	dstDb := make(Db)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+
+	for queue := sched.Missing(10000); len(queue) > 0; queue = sched.Missing(10000) {
+		// Sync only half of the scheduled nodes, reschedule the rest
+		half := queue[:len(queue)/2+1]
+		results := make([]SyncResult, len(half))
+		for i, hash := range half {
+			data, _ := srcDb.Get(hash[:])
+			results[i] = SyncResult{hash, data}
+		}
+		if _, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process results: %v", err)
+		}
+		sched.Reschedule(queue[len(half):])
+	}
+	checkTrieContents(t, dstDb, srcTrie.Root(), srcData)
+}
+
+// Tests that a trie sync will not request nodes multiple times, even if they
+// have such references.
+func TestDuplicateAvoidanceTrieSync(t *testing.T) {
+	srcDb, srcTrie, srcData := makeTestTrie()
+
+	dstDb := make(Db)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+
+	requested := make(map[common.Hash]struct{})
+	for queue := sched.Missing(0); len(queue) > 0; queue = sched.Missing(0) {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			if _, ok := requested[hash]; ok {
+				t.Errorf("hash %x already requested once", hash)
+			}
+			requested[hash] = struct{}{}
+
+			data, _ := srcDb.Get(hash[:])
+			results[i] = SyncResult{hash, data}
+		}
+		if _, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process results: %v", err)
+		}
+	}
+	checkTrieContents(t, dstDb, srcTrie.Root(), srcData)
+}
+
+// Tests that at any moment during syncing, only complete sub-tries are in the
+// database, i.e. no node is committed before all of its children are.
+func TestIncompleteTrieSync(t *testing.T) {
+	srcDb, srcTrie, _ := makeTestTrie()
+
+	dstDb := make(Db)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+
+	added := []common.Hash{}
+	for queue := sched.Missing(1); len(queue) > 0; queue = sched.Missing(1) {
+		data, _ := srcDb.Get(queue[0][:])
+		if _, err := sched.Process([]SyncResult{{queue[0], data}}); err != nil {
+			t.Fatalf("failed to process result: %v", err)
+		}
+		for key := range dstDb {
+			hash := common.BytesToHash([]byte(key))
+			if _, ok := sched.requests[hash]; ok {
+				t.Fatalf("node %x committed while still pending", hash)
+			}
+		}
+		added = append(added, queue[0])
+	}
+	if dstDb[string(srcTrie.Root())] == nil {
+		t.Fatalf("root node not committed")
+	}
+	if len(dstDb) != len(added) {
+		t.Fatalf("committed node count mismatch: have %d, want %d", len(dstDb), len(added))
+	}
+}
+
+// Tests that invalid or unrequested node data is rejected.
+func TestInvalidTrieSyncData(t *testing.T) {
+	srcDb, srcTrie, _ := makeTestTrie()
+
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), make(Db), nil)
+	queue := sched.Missing(1)
+
+	if _, err := sched.Process([]SyncResult{{queue[0], []byte{0x01, 0x02}}}); err == nil {
+		t.Errorf("mismatching data accepted")
+	}
+	data, _ := srcDb.Get(queue[0][:])
+	if _, err := sched.Process([]SyncResult{{common.Hash{0x01}, data}}); err == nil {
+		t.Errorf("unrequested data accepted")
+	}
+}
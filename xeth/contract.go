@@ -0,0 +1,127 @@
+package xeth
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// The methods in this file implement bind.ContractBackend, allowing generated
+// contract bindings to operate on the chain of the local node.
+var _ bind.ContractBackend = (*XEth)(nil)
+
+// ContractCall executes a call to the contract against a copy of the current
+// or pending state and returns its output.
+func (self *XEth) ContractCall(contract common.Address, data []byte, pending bool) ([]byte, error) {
+	statedb, block := self.contractState(pending)
+	msg := callmsg{
+		from:     statedb.GetOrNewStateObject(common.Address{}),
+		to:       &contract,
+		gas:      new(big.Int).Set(block.GasLimit()),
+		gasPrice: new(big.Int),
+		value:    new(big.Int),
+		data:     data,
+	}
+	vmenv := core.NewEnv(statedb, self.backend.ChainManager(), msg, block)
+
+	return vmenv.Call(msg.from, contract, msg.data, msg.gas, msg.gasPrice, msg.value)
+}
+
+// PendingAccountNonce reserves the next nonce of the account in the managed
+// transaction state, like Transact does.
+func (self *XEth) PendingAccountNonce(account common.Address) (uint64, error) {
+	if self.light != nil {
+		return self.light.State().GetNonce(account)
+	}
+	return self.backend.ChainManager().TxState().NewNonce(account), nil
+}
+
+// SuggestGasPrice returns the default gas price of transactions.
+func (self *XEth) SuggestGasPrice() (*big.Int, error) {
+	return DefaultGasPrice(), nil
+}
+
+// EstimateGasLimit executes the transaction against a copy of the pending
+// state and returns the gas it used. The limit of the pending block is
+// available to the execution.
+func (self *XEth) EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error) {
+	statedb, block := self.contractState(true)
+	msg := callmsg{
+		from:     statedb.GetOrNewStateObject(sender),
+		to:       contract,
+		gas:      new(big.Int).Set(block.GasLimit()),
+		gasPrice: new(big.Int),
+		value:    value,
+		data:     data,
+	}
+	coinbase := statedb.GetOrNewStateObject(block.Coinbase())
+	coinbase.SetGasPool(block.GasLimit())
+
+	vmenv := core.NewEnv(statedb, self.backend.ChainManager(), msg, block)
+	_, gas, err := core.ApplyMessage(vmenv, msg, coinbase)
+	if err != nil {
+		return nil, err
+	}
+	return gas, nil
+}
+
+// SendTransaction adds the signed transaction to the transaction pool.
+func (self *XEth) SendTransaction(tx *types.Transaction) error {
+	return self.backend.TxPool().Add(tx)
+}
+
+// FilterLogs returns the logs of the blocks from earliest to latest that match
+// the addresses and topics.
+func (self *XEth) FilterLogs(earliest, latest int64, addresses []common.Address, topics [][]common.Hash) (state.Logs, error) {
+	filter := core.NewFilter(self.backend)
+	filter.SetEarliestBlock(earliest)
+	filter.SetLatestBlock(latest)
+	filter.SetAddress(addresses)
+	filter.SetTopics(topics)
+
+	return filter.Find(), nil
+}
+
+// SubscribeLogs installs a filter delivering the matching logs of new blocks
+// to sink. Delivery blocks until sink accepts the log or the returned function
+// is called.
+func (self *XEth) SubscribeLogs(addresses []common.Address, topics [][]common.Hash, sink chan<- *state.Log) (func(), error) {
+	quit := make(chan struct{})
+
+	filter := core.NewFilter(self.backend)
+	filter.SetAddress(addresses)
+	filter.SetTopics(topics)
+	filter.LogsCallback = func(logs state.Logs) {
+		for _, log := range logs {
+			select {
+			case sink <- log:
+			case <-quit:
+				return
+			}
+		}
+	}
+	id := self.filterManager.InstallFilter(filter)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			self.filterManager.UninstallFilter(id)
+		})
+	}, nil
+}
+
+// contractState returns a copy of the current or pending state along with
+// the block it belongs to.
+func (self *XEth) contractState(pending bool) (*state.StateDB, *types.Block) {
+	if pending {
+		return self.backend.Miner().PendingState().Copy(), self.backend.Miner().PendingBlock()
+	}
+	block := self.CurrentBlock()
+	return state.New(block.Root(), self.backend.StateDb()), block
+}
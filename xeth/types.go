@@ -7,6 +7,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/asm"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -152,7 +153,8 @@ func NewTx(tx *types.Transaction) *Transaction {
 
 	var data string
 	if createsContract {
-		data = strings.Join(core.Disassemble(tx.Data()), "\n")
+		lines, _ := asm.Disassemble(tx.Data())
+		data = strings.Join(lines, "\n")
 	} else {
 		data = common.ToHex(tx.Data())
 	}
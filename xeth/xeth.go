@@ -17,6 +17,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/event/filter"
+	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/miner"
@@ -38,6 +39,7 @@ type XEth struct {
 
 	state   *State
 	whisper *Whisper
+	light   *les.LightNode // set if the backend runs as a light node
 
 	quit          chan struct{}
 	filterManager *filter.FilterManager
@@ -67,6 +69,7 @@ func New(eth *eth.Ethereum, frontend Frontend) *XEth {
 		logs:          make(map[int]*logFilter),
 		messages:      make(map[int]*whisperFilter),
 		agent:         miner.NewRemoteAgent(),
+		light:         eth.LightNode(),
 	}
 	eth.Miner().Register(xeth.agent)
 
@@ -320,27 +323,56 @@ func (self *XEth) NumberToHuman(balance string) string {
 }
 
 func (self *XEth) StorageAt(addr, storageAddr string) string {
+	if self.light != nil {
+		value, err := self.light.State().GetState(common.HexToAddress(addr), common.HexToHash(storageAddr))
+		if err != nil {
+			glog.V(logger.Debug).Infof("light storage retrieval failed: %v", err)
+		}
+		return common.ToHex(value)
+	}
 	return common.ToHex(self.State().state.GetState(common.HexToAddress(addr), common.HexToHash(storageAddr)))
 }
 
 func (self *XEth) BalanceAt(addr string) string {
+	if self.light != nil {
+		balance, err := self.light.State().GetBalance(common.HexToAddress(addr))
+		if err != nil {
+			glog.V(logger.Debug).Infof("light balance retrieval failed: %v", err)
+			return common.ToHex(nil)
+		}
+		return common.ToHex(balance.Bytes())
+	}
 	return common.ToHex(self.State().state.GetBalance(common.HexToAddress(addr)).Bytes())
 }
 
 func (self *XEth) TxCountAt(address string) int {
+	if self.light != nil {
+		nonce, err := self.light.State().GetNonce(common.HexToAddress(address))
+		if err != nil {
+			glog.V(logger.Debug).Infof("light nonce retrieval failed: %v", err)
+		}
+		return int(nonce)
+	}
 	return int(self.State().state.GetNonce(common.HexToAddress(address)))
 }
 
 func (self *XEth) CodeAt(address string) string {
-	return common.ToHex(self.State().state.GetCode(common.HexToAddress(address)))
+	return common.ToHex(self.CodeAtBytes(address))
 }
 
 func (self *XEth) CodeAtBytes(address string) []byte {
+	if self.light != nil {
+		code, err := self.light.State().GetCode(common.HexToAddress(address))
+		if err != nil {
+			glog.V(logger.Debug).Infof("light code retrieval failed: %v", err)
+		}
+		return code
+	}
 	return self.State().SafeGet(address).Code()
 }
 
 func (self *XEth) IsContract(address string) bool {
-	return len(self.State().SafeGet(address).Code()) > 0
+	return len(self.CodeAtBytes(address)) > 0
 }
 
 func (self *XEth) SecretToAddress(key string) string {
@@ -556,8 +588,12 @@ func (self *XEth) FromNumber(str string) string {
 	return common.BigD(common.FromHex(str)).String()
 }
 
+// PushTx adds a signed, RLP encoded transaction to the transaction pool.
 func (self *XEth) PushTx(encodedTx string) (string, error) {
-	tx := types.NewTransactionFromBytes(common.FromHex(encodedTx))
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(encodedTx), tx); err != nil {
+		return "", fmt.Errorf("invalid transaction: %v", err)
+	}
 	err := self.backend.TxPool().Add(tx)
 	if err != nil {
 		return "", err
@@ -584,9 +620,10 @@ func (self *XEth) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr st
 		from = statedb.GetOrNewStateObject(common.HexToAddress(fromStr))
 	}
 
+	to := common.HexToAddress(toStr)
 	msg := callmsg{
 		from:     from,
-		to:       common.HexToAddress(toStr),
+		to:       &to,
 		gas:      common.Big(gasStr),
 		gasPrice: common.Big(gasPriceStr),
 		value:    common.Big(valueStr),
@@ -604,20 +641,12 @@ func (self *XEth) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr st
 	block := self.CurrentBlock()
 	vmenv := core.NewEnv(statedb, self.backend.ChainManager(), msg, block)
 
-	res, err := vmenv.Call(msg.from, msg.to, msg.data, msg.gas, msg.gasPrice, msg.value)
+	res, err := vmenv.Call(msg.from, to, msg.data, msg.gas, msg.gasPrice, msg.value)
 	return common.ToHex(res), err
 }
 
 func (self *XEth) Transact(fromStr, toStr, valueStr, gasStr, gasPriceStr, codeStr string) (string, error) {
-	var (
-		from             = common.HexToAddress(fromStr)
-		to               = common.HexToAddress(toStr)
-		value            = common.NewValue(valueStr)
-		gas              = common.Big(gasStr)
-		price            = common.Big(gasPriceStr)
-		data             []byte
-		contractCreation bool
-	)
+	from := common.HexToAddress(fromStr)
 
 	// TODO if no_private_key then
 	//if _, exists := p.register[args.From]; exists {
@@ -641,27 +670,7 @@ func (self *XEth) Transact(fromStr, toStr, valueStr, gasStr, gasPriceStr, codeSt
 		}
 	*/
 
-	// TODO: align default values to have the same type, e.g. not depend on
-	// common.Value conversions later on
-	if gas.Cmp(big.NewInt(0)) == 0 {
-		gas = DefaultGas()
-	}
-
-	if price.Cmp(big.NewInt(0)) == 0 {
-		price = DefaultGasPrice()
-	}
-
-	data = common.FromHex(codeStr)
-	if len(toStr) == 0 {
-		contractCreation = true
-	}
-
-	var tx *types.Transaction
-	if contractCreation {
-		tx = types.NewContractCreationTx(value.BigInt(), gas, price, data)
-	} else {
-		tx = types.NewTransactionMessage(to, value.BigInt(), gas, price, data)
-	}
+	tx := NewTransaction(toStr, valueStr, gasStr, gasPriceStr, codeStr)
 
 	state := self.backend.ChainManager().TxState()
 	nonce := state.NewNonce(from)
@@ -674,7 +683,7 @@ func (self *XEth) Transact(fromStr, toStr, valueStr, gasStr, gasPriceStr, codeSt
 		return "", err
 	}
 
-	if contractCreation {
+	if tx.To() == nil {
 		addr := core.AddressFromMessage(tx)
 		glog.V(logger.Info).Infof("Contract addr %x\n", addr)
 
@@ -683,8 +692,83 @@ func (self *XEth) Transact(fromStr, toStr, valueStr, gasStr, gasPriceStr, codeSt
 	return tx.Hash().Hex(), nil
 }
 
+// SignTransaction creates and signs a transaction like Transact, but returns
+// its RLP encoding instead of submitting it. The transaction gets the next
+// nonce of the sender, which is not reserved for it.
+func (self *XEth) SignTransaction(fromStr, toStr, valueStr, gasStr, gasPriceStr, codeStr string) (string, error) {
+	from := common.HexToAddress(fromStr)
+	tx := NewTransaction(toStr, valueStr, gasStr, gasPriceStr, codeStr)
+	tx.SetNonce(self.backend.ChainManager().TxState().GetNonce(from))
+
+	if err := self.sign(tx, from, false); err != nil {
+		return "", err
+	}
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return "", err
+	}
+	return common.ToHex(enc), nil
+}
+
+// NewTransaction creates an unsigned transaction without nonce, filling in
+// the default gas and gas price if they are zero. An empty toStr creates a
+// contract.
+func NewTransaction(toStr, valueStr, gasStr, gasPriceStr, codeStr string) *types.Transaction {
+	var (
+		to    = common.HexToAddress(toStr)
+		value = common.NewValue(valueStr)
+		gas   = common.Big(gasStr)
+		price = common.Big(gasPriceStr)
+		data  = common.FromHex(codeStr)
+	)
+
+	// TODO: align default values to have the same type, e.g. not depend on
+	// common.Value conversions later on
+	if gas.Cmp(big.NewInt(0)) == 0 {
+		gas = DefaultGas()
+	}
+
+	if price.Cmp(big.NewInt(0)) == 0 {
+		price = DefaultGasPrice()
+	}
+
+	if len(toStr) == 0 {
+		return types.NewContractCreationTx(value.BigInt(), gas, price, data)
+	}
+	return types.NewTransactionMessage(to, value.BigInt(), gas, price, data)
+}
+
+// Sign signs the text hash of data with the given account, asking the
+// frontend to unlock it if needed. The signature is returned as hex
+// [R || S || V] with V 27 or 28, like the signature of a transaction.
+func (self *XEth) Sign(fromStr, dataStr string, didUnlock bool) (string, error) {
+	var (
+		from = common.HexToAddress(fromStr)
+		hash = accounts.TextHash(common.FromHex(dataStr))
+	)
+	sig, err := self.backend.AccountManager().Sign(accounts.Account{Address: from.Bytes()}, hash)
+	if err == accounts.ErrLocked {
+		if didUnlock {
+			return "", fmt.Errorf("signer account still locked after successful unlock")
+		}
+		if !self.frontend.UnlockAccount(from.Bytes()) {
+			return "", fmt.Errorf("could not unlock signer account")
+		}
+		// retry signing, the account should now be unlocked.
+		return self.Sign(fromStr, dataStr, true)
+	} else if err != nil {
+		return "", err
+	}
+	sig[64] += 27
+	return common.ToHex(sig), nil
+}
+
 func (self *XEth) sign(tx *types.Transaction, from common.Address, didUnlock bool) error {
-	sig, err := self.backend.AccountManager().Sign(accounts.Account{Address: from.Bytes()}, tx.Hash().Bytes())
+	var to []byte
+	if addr := tx.To(); addr != nil {
+		to = addr.Bytes()
+	}
+	sig, err := self.backend.AccountManager().SignTx(accounts.Account{Address: from.Bytes()}, to, tx.Value(), tx.Gas(), tx.GasPrice(), tx.Hash().Bytes())
 	if err == accounts.ErrLocked {
 		if didUnlock {
 			return fmt.Errorf("sender account still locked after successful unlock")
@@ -697,14 +781,13 @@ func (self *XEth) sign(tx *types.Transaction, from common.Address, didUnlock boo
 	} else if err != nil {
 		return err
 	}
-	tx.SetSignatureValues(sig)
-	return nil
+	return tx.SetSignatureValues(sig)
 }
 
 // callmsg is the message type used for call transations.
 type callmsg struct {
 	from          *state.StateObject
-	to            common.Address
+	to            *common.Address // nil means contract creation
 	gas, gasPrice *big.Int
 	value         *big.Int
 	data          []byte
@@ -713,7 +796,7 @@ type callmsg struct {
 // accessor boilerplate to implement core.Message
 func (m callmsg) From() (common.Address, error) { return m.from.Address(), nil }
 func (m callmsg) Nonce() uint64                 { return m.from.Nonce() }
-func (m callmsg) To() *common.Address           { return &m.to }
+func (m callmsg) To() *common.Address           { return m.to }
 func (m callmsg) GasPrice() *big.Int            { return m.gasPrice }
 func (m callmsg) Gas() *big.Int                 { return m.gas }
 func (m callmsg) Value() *big.Int               { return m.value }